@@ -3,23 +3,69 @@ package stationi2c
 import (
 	"context"
 	"sync"
+	"time"
 
 	i2c "github.com/d2r2/go-i2c"
 	"github.com/d2r2/go-logger"
 	"github.com/edaniels/golog"
 	"github.com/pkg/errors"
+	"go.uber.org/multierr"
 	"go.viam.com/utils"
 
-	"go.viam.com/rdk/components/movementsensor"
 	"go.viam.com/rdk/components/sensor"
 	"go.viam.com/rdk/resource"
+	rutils "go.viam.com/rdk/utils"
+
+	rtkutils "rtksystem/rtk-utils"
+)
+
+var (
+	Model = resource.NewModel("viam-labs", "sensor", "correction-station-i2c")
 )
 
+// Classes of the errors of the station's worker.
 var (
-	Model               = resource.NewModel("viam-labs", "sensor", "correction-station-i2c")
-	errRequiredAccuracy = errors.New("required accuracy can be a fixed number 1-5, 5 being the highest accuracy")
+	classOpenI2C  = rtkutils.ErrorClass{Category: rtkutils.ErrorI2C, Op: rtkutils.OpOpen}
+	classReadI2C  = rtkutils.ErrorClass{Category: rtkutils.ErrorI2C, Op: rtkutils.OpRead}
+	classCloseI2C = rtkutils.ErrorClass{Category: rtkutils.ErrorI2C, Op: rtkutils.OpClose}
 )
 
+// recoveryPolicy is how the station recovers from the errors of its worker.
+var recoveryPolicy = rtkutils.RecoveryPolicy{
+	Actions: map[rtkutils.ErrorClass]rtkutils.RecoveryAction{
+		// the bus is opened for every read, so retrying also reopens it
+		classOpenI2C: rtkutils.Retry,
+		classReadI2C: rtkutils.Retry,
+		// a handle left open may keep other devices off the bus
+		classCloseI2C: rtkutils.Surface,
+	},
+	MaxFailures: 30,
+}
+
+// configMigration maps the keys of old configs to the current ones.
+var configMigration = rtkutils.ConfigMigration{
+	Protocol: "i2c",
+	Nested:   []string{"i2c_attributes"},
+	Aliases: map[string]string{
+		"required_time": "required_time_sec",
+	},
+	Dropped: []string{"children", "svin"},
+}
+
+// convertAttributes converts the attributes of a config, migrating the keys of old configs.
+func convertAttributes(attributes rutils.AttributeMap) (*Config, error) {
+	migrated, warnings, err := configMigration.Migrate(attributes)
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := resource.TransformAttributeMap[*Config](migrated)
+	if err != nil {
+		return nil, err
+	}
+	cfg.warnings = warnings
+	return cfg, nil
+}
+
 func init() {
 	resource.RegisterComponent(
 		sensor.API,
@@ -35,8 +81,13 @@ func init() {
 				if err != nil {
 					return nil, err
 				}
+				for _, warning := range newConf.warnings {
+					logger.Warn(warning)
+				}
+				logger.Warnf("%s is deprecated, use viam-labs:sensor:correction-station with a i2c input instead", Model.Name)
 				return newRTKStationI2C(ctx, deps, conf.ResourceName(), newConf, logger)
 			},
+			AttributeMapConverter: convertAttributes,
 		})
 }
 
@@ -45,9 +96,40 @@ type Config struct {
 	RequiredAccuracy float64 `json:"required_accuracy,omitempty"`
 	RequiredTime     int     `json:"required_time_sec,omitempty"`
 
+	// The rtcm stream is also written to files in this directory, rotated every rtcm_record_rotate_min
+	RecordDir           string  `json:"rtcm_record_dir,omitempty"`
+	RecordRotateMinutes float64 `json:"rtcm_record_rotate_min,omitempty"`
+	RecordKeepFiles     int     `json:"rtcm_record_keep_files,omitempty"`
+
+	// Forwarded corrections are re-referenced to a virtual base at this position, see rtkutils.VirtualBase
+	VirtualBaseLat float64 `json:"virtual_base_lat,omitempty"`
+	VirtualBaseLng float64 `json:"virtual_base_lng,omitempty"`
+	VirtualBaseAlt float64 `json:"virtual_base_alt_m,omitempty"`
+
 	I2CBus      int `json:"i2c_bus"`
 	I2CAddr     int `json:"i2c_addr"`
 	I2CBaudRate int `json:"i2c_baud_rate,omitempty"`
+
+	// The u-blox receiver ConfigureBaseRTKStation configures, zed-f9p by default. Only set by the
+	// correction-station model, see rtkutils.Receiver
+	Receiver string `json:"-"`
+
+	// Whether ConfigureBaseRTKStation also enables the UBX-RXM-SFRBX subframes a BaseMonitor needs.
+	// Only set by the correction-station model
+	EphemerisOutput bool `json:"-"`
+
+	// The receiver port ConfigureBaseRTKStation enables rtcm output on, the i2c port by default. Only set by
+	// the correction-station model, see rtkutils.UBXPort
+	RTCMOutputPort string `json:"-"`
+
+	// Validate checks that the i2c bus exists unless this is set
+	SkipDeviceCheck bool `json:"skip_device_check,omitempty"`
+
+	// How long to wait after an i2c read that returned no data, 50ms by default
+	PollIntervalMs int `json:"poll_interval_ms,omitempty"`
+
+	// warnings about deprecated keys found by convertAttributes
+	warnings []string
 }
 
 // Validate ensures all parts of the config are valid.
@@ -56,8 +138,11 @@ func (cfg *Config) Validate(path string) ([]string, error) {
 	if cfg.RequiredAccuracy == 0 {
 		return nil, utils.NewConfigValidationFieldRequiredError(path, "required_accuracy")
 	}
+	if cfg.RequiredAccuracy < 0 {
+		return nil, errors.Errorf("%s: required_accuracy is the survey accuracy in meters and must be positive", path)
+	}
 	if cfg.RequiredTime == 0 {
-		return nil, utils.NewConfigValidationFieldRequiredError(path, "required_time")
+		return nil, utils.NewConfigValidationFieldRequiredError(path, "required_time_sec")
 	}
 
 	if cfg.I2CBus == 0 {
@@ -66,6 +151,20 @@ func (cfg *Config) Validate(path string) ([]string, error) {
 	if cfg.I2CAddr == 0 {
 		return nil, utils.NewConfigValidationFieldRequiredError(path, "i2c_addr")
 	}
+	if err := rtkutils.ValidateI2CAddr("i2c_addr", cfg.I2CAddr); err != nil {
+		return nil, errors.Wrap(err, path)
+	}
+	if !cfg.SkipDeviceCheck {
+		if err := rtkutils.ValidateDevicePath("i2c_bus", rtkutils.I2CBusPath(cfg.I2CBus)); err != nil {
+			return nil, errors.Wrap(err, path)
+		}
+	}
+	if err := rtkutils.ValidateVirtualBase(cfg.VirtualBaseLat, cfg.VirtualBaseLng); err != nil {
+		return nil, errors.Wrap(err, path)
+	}
+	if err := rtkutils.ValidatePollInterval(cfg.PollIntervalMs); err != nil {
+		return nil, errors.Wrap(err, path)
+	}
 
 	return deps, nil
 }
@@ -79,9 +178,23 @@ type rtkStationI2C struct {
 
 	cancelCtx               context.Context
 	cancelFunc              func()
+	closed                  rtkutils.CloseOnce
 	activeBackgroundWorkers sync.WaitGroup
 
-	err movementsensor.LastError
+	conf       *Config
+	rtcmFrames rtkutils.Counter      // valid frames read from the receiver
+	replies    rtkutils.ReplyWatcher // replies of the receiver to the self test, among the corrections read
+
+	// the receiver wasn't there when the station was built, it is configured once it is
+	waitForReceiver bool
+
+	corrections rtkutils.RTCMBuffer    // recent frames served to remote rovers
+	rtcmFiles   *rtkutils.RTCMFileSink // recording of the rtcm stream, if enabled
+	vrs         *rtkutils.VirtualBase  // re-references forwarded corrections, if enabled
+
+	err      *rtkutils.ErrorHistory
+	recovery *rtkutils.Recovery
+	workers  *rtkutils.Supervisor
 }
 
 type i2cBusAddr struct {
@@ -97,23 +210,38 @@ func newRTKStationI2C(
 	logger golog.Logger,
 ) (sensor.Sensor, error) {
 
-	cancelCtx, cancelFunc := context.WithCancel(context.Background())
+	cancelCtx, cancelFunc := context.WithCancel(rtkutils.ModuleContext())
 
 	r := &rtkStationI2C{
 		Named:      name.AsNamed(),
 		cancelCtx:  cancelCtx,
 		cancelFunc: cancelFunc,
 		logger:     logger,
-		err:        movementsensor.NewLastError(1, 1),
+		err:        rtkutils.NewErrorHistory(),
 	}
+	r.recovery = rtkutils.NewRecovery(recoveryPolicy, r.err, logger)
+	r.workers = rtkutils.NewSupervisor(cancelCtx, &r.activeBackgroundWorkers, logger)
 
 	r.logger.Debug("configuring the base station")
 
 	err := ConfigureBaseRTKStation(newConf)
-	if err != nil {
+	if rtkutils.IsDeviceAbsent(err) {
+		r.logger.Warnf("receiver at %#x on i2c bus %d isn't there yet, waiting for it: %s", newConf.I2CAddr, newConf.I2CBus, err)
+		r.waitForReceiver = true
+	} else if err != nil {
 		r.logger.Warn("rtk base station could not be configured")
 	}
 
+	r.conf = newConf
+
+	r.vrs = rtkutils.NewVirtualBase(newConf.VirtualBaseLat, newConf.VirtualBaseLng, newConf.VirtualBaseAlt)
+	r.rtcmFiles, err = rtkutils.NewRTCMFileSink(newConf.RecordDir,
+		time.Duration(newConf.RecordRotateMinutes*float64(time.Minute)), newConf.RecordKeepFiles)
+	if err != nil {
+		cancelFunc()
+		return nil, err
+	}
+
 	// Init correction source
 	r.i2cPath.addr = byte(newConf.I2CAddr)
 	r.i2cPath.bus = newConf.I2CBus
@@ -121,81 +249,208 @@ func newRTKStationI2C(
 	r.logger.Debug("Starting the i2c station")
 
 	r.start(ctx)
+	rtkutils.TrackResource(r)
 	return r, r.err.Get()
 }
 
 // Start starts reading from the correction source and sends corrections the i2c buffer.
 func (r *rtkStationI2C) start(ctx context.Context) {
-	r.activeBackgroundWorkers.Add(1)
-	utils.PanicCapturingGo(func() {
-		defer r.activeBackgroundWorkers.Done()
-
+	r.workers.Go("rtcm reader", func() error {
 		if err := r.cancelCtx.Err(); err != nil {
-			return
+			return nil
 		}
 		select {
 		case <-r.cancelCtx.Done():
-			return
+			return nil
 		default:
 		}
 
-		var err error
+		if r.waitForReceiver {
+			if !r.awaitReceiver() {
+				return nil
+			}
+			r.waitForReceiver = false
+		}
+
 		// change log level
 		logger.ChangePackageLogLevel("i2c", logger.InfoLevel)
 
 		buf := make([]byte, 1024)
+		var partial []byte // start of a frame cut off by the end of the previous read
 
-		for err == nil {
+		for {
 			select {
 			case <-r.cancelCtx.Done():
-				return
+				return nil
 			default:
 			}
 
-			// Open I2C handle every time
-			r.i2cBus, err = i2c.NewI2C(r.i2cPath.addr, r.i2cPath.bus)
-			r.err.Set(err)
-
-			// Read correction data
-			_, err = r.i2cBus.ReadBytes(buf)
-			r.err.Set(err)
+			n, class, err := r.readCorrections(buf)
 			if err != nil {
-				r.logger.Errorf("can't read bytes from i2c buffer: %s", err)
-				return
+				if r.cancelCtx.Err() != nil || r.recovery.Handle(r.cancelCtx, class, err) == rtkutils.Rebuild {
+					return rtkutils.ErrRebuildRequired
+				}
+				// the buffer was read before the bus failed to close
+				if class != classCloseI2C {
+					continue
+				}
+			}
+			r.replies.Observe(buf[:n])
+			if rtkutils.IsIdle(buf[:n]) {
+				// the receiver has no new corrections yet
+				utils.SelectContextOrWait(r.cancelCtx, rtkutils.PollInterval(r.conf.PollIntervalMs, rtkutils.DefaultPollInterval))
+				continue
+			}
+			var frames [][]byte
+			frames, partial = rtkutils.SplitRTCMFrames(append(partial, buf[:n]...))
+			partial = append([]byte(nil), partial...)
+			r.rtcmFrames.Add(uint64(len(frames)))
+			for _, frame := range frames {
+				frame, ok := r.vrs.TransformFrame(frame)
+				if !ok {
+					continue
+				}
+				r.corrections.Add(frame)
+				if _, err := r.rtcmFiles.Write(frame); err != nil {
+					r.logger.Warnf("failed to record rtcm frame: %s", err)
+				}
 			}
+		}
+	})
+}
 
-			// close I2C handle
-			err = r.i2cBus.Close()
-			r.err.Set(err)
-			r.i2cBus = nil
+// awaitReceiver configures the receiver once it is there, retrying until it is. It returns false
+// if the worker must stop instead.
+func (r *rtkStationI2C) awaitReceiver() bool {
+	for {
+		err := ConfigureBaseRTKStation(r.conf)
+		if err == nil || !rtkutils.IsDeviceAbsent(err) {
 			if err != nil {
-				r.logger.Errorf("failed to close i2c handle: %s", err)
-				return
+				r.logger.Warnf("rtk base station could not be configured: %s", err)
 			}
+			r.recovery.Succeeded(classOpenI2C)
+			return true
 		}
+		if r.cancelCtx.Err() != nil || r.recovery.Handle(r.cancelCtx, classOpenI2C, err) == rtkutils.Rebuild {
+			return false
+		}
+	}
+}
+
+// readCorrections reads the correction buffer of the receiver into buf. The i2c handle is opened
+// and closed for every read. It returns the class of a failure.
+func (r *rtkStationI2C) readCorrections(buf []byte) (int, rtkutils.ErrorClass, error) {
+	var err error
+	r.i2cBus, err = i2c.NewI2C(r.i2cPath.addr, r.i2cPath.bus)
+	if err != nil {
+		r.i2cBus = nil
+		return 0, classOpenI2C, err
+	}
+	r.recovery.Succeeded(classOpenI2C)
+
+	n, err := r.i2cBus.ReadBytes(buf)
+	closeErr := r.i2cBus.Close()
+	r.i2cBus = nil
+	if err != nil {
+		return 0, classReadI2C, err
+	}
+	r.recovery.Succeeded(classReadI2C)
+	if closeErr != nil {
+		return n, classCloseI2C, closeErr
+	}
+	r.recovery.Succeeded(classCloseI2C)
+	return n, rtkutils.ErrorClass{}, nil
+}
+
+// DoCommand runs the commands supported by the station.
+func (r *rtkStationI2C) DoCommand(ctx context.Context, cmd map[string]interface{}) (map[string]interface{}, error) {
+	switch cmd[rtkutils.CommandKey] {
+	case rtkutils.SelfTestCommand:
+		return r.selfTest(ctx, rtkutils.SelfTestTimeout(cmd)), nil
+	case rtkutils.ProvisionCommand:
+		return Provision(ctx, r.conf, cmd)
+	case rtkutils.ReadRTCMCommand:
+		return r.corrections.ReadRTCM(cmd), nil
+	case rtkutils.ErrorsCommand:
+		return r.err.ErrorsResult(cmd), nil
+	case rtkutils.VersionCommand:
+		return rtkutils.VersionResult(), nil
+	default:
+		return nil, resource.ErrDoUnimplemented
+	}
+}
+
+// selfTest checks that the i2c address responds, that the receiver accepts configuration and that it outputs rtcm frames.
+func (r *rtkStationI2C) selfTest(ctx context.Context, timeout time.Duration) map[string]interface{} {
+	report := rtkutils.NewReport()
+
+	report.Check("i2c_addr_ack", rtkutils.CheckI2CAck(r.i2cPath.addr, r.i2cPath.bus))
+	report.Check("receiver_config", r.checkReceiverConfig(ctx, timeout))
+	report.Check("rtcm_frames", rtkutils.WaitForIncrease(ctx, timeout, "rtcm frames", r.rtcmFrames.Get))
+
+	return report.Result()
+}
+
+// checkReceiverConfig re-sends the rtcm 1005 output rate the station already uses, or the probe of receivers that aren't
+// configured with UBX, and waits for the receiver to acknowledge it among the corrections the station reads.
+func (r *rtkStationI2C) checkReceiverConfig(ctx context.Context, timeout time.Duration) error {
+	receiver, err := rtkutils.Receiver(r.conf.Receiver)
+	if err != nil {
+		return err
+	}
+
+	packet, ack, nak := receiver.ProbePacket, receiver.ProbeAck, receiver.ProbeNak
+	if packet == nil {
+		packet = rtkutils.UBXMessageRatePacket(ubxRtcmMsb, rtkutils.UBXRTCM1005, byte(receiver.RTCMOutput[rtkutils.UBXRTCM1005]))
+		ack, nak = rtkutils.UBXAckPackets(ubxClassCfg, ubxCfgMsg)
+	}
+	return r.replies.Await(ctx, timeout, ack, nak, func() error {
+		// only written, the reply is read by the station
+		i2cBus, err := i2c.NewI2C(r.i2cPath.addr, r.i2cPath.bus)
+		if err != nil {
+			return err
+		}
+		_, err = i2cBus.WriteBytes(packet)
+		return multierr.Combine(err, i2cBus.Close())
 	})
 }
 
 // Close shuts down the rtkStation.
 func (r *rtkStationI2C) Close(ctx context.Context) error {
+	return r.closed.Close(func() error { return r.close(ctx) })
+}
+
+func (r *rtkStationI2C) close(ctx context.Context) error {
+	rtkutils.UntrackResource(r)
 	r.cancelFunc()
-	r.activeBackgroundWorkers.Wait()
+	if !rtkutils.WaitWithTimeout(ctx, &r.activeBackgroundWorkers, rtkutils.DefaultShutdownTimeout) {
+		r.logger.Warn("timed out waiting for background workers to stop")
+	}
 
 	if r.i2cBus != nil {
 		err := r.i2cBus.Close()
-		r.err.Set(err)
+		r.err.Set(rtkutils.ErrorI2C, err)
 		if err != nil {
 			r.logger.Errorf("failed to close i2c bus: %s", err)
 		}
 	}
 	r.i2cBus = nil
 
+	if err := r.rtcmFiles.Close(); err != nil {
+		r.logger.Errorf("failed to close the rtcm recording: %s", err)
+	}
+
 	if err := r.err.Get(); err != nil && !errors.Is(err, context.Canceled) {
 		return err
 	}
 	return nil
 }
 
+// Readings returns the state of the station and how many corrections it read.
 func (r *rtkStationI2C) Readings(ctx context.Context, extra map[string]interface{}) (map[string]interface{}, error) {
-	return map[string]interface{}{}, errors.New("unimplemented")
+	return map[string]interface{}{
+		rtkutils.StateKey:    rtkutils.DeviceState(rtkutils.StationState(r.err, &r.rtcmFrames, true, time.Now()), r.recovery),
+		"rtcm_frames":        r.rtcmFrames.Get(),
+		rtkutils.RestartsKey: r.workers.Restarts(),
+	}, nil
 }
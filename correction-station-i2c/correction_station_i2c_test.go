@@ -2,6 +2,7 @@ package stationi2c
 
 import (
 	"context"
+	"errors"
 	"testing"
 
 	"github.com/edaniels/golog"
@@ -31,8 +32,30 @@ func TestValidate(t *testing.T) {
 				RequiredTime:     200,
 				I2CBus:           testBus,
 				I2CAddr:          testi2cAddr,
+				SkipDeviceCheck:  true,
 			},
 		},
+		{
+			name: "an i2c address out of range should result in error",
+			config: &Config{
+				RequiredAccuracy: 4,
+				RequiredTime:     200,
+				I2CBus:           testBus,
+				I2CAddr:          0x78,
+				SkipDeviceCheck:  true,
+			},
+			expectedErr: errors.New("path: i2c_addr 0x78 is not a valid i2c address, devices use 0x8 to 0x77 (u-blox receivers default to 0x42)"),
+		},
+		{
+			name: "an i2c bus that doesn't exist should result in error",
+			config: &Config{
+				RequiredAccuracy: 4,
+				RequiredTime:     200,
+				I2CBus:           99,
+				I2CAddr:          testi2cAddr,
+			},
+			expectedErr: errors.New("path: i2c_bus \"/dev/i2c-99\" doesn't exist, check that the device is connected, or set skip_device_check if it is only connected later"),
+		},
 		{
 			name: "a config with no RequiredAccuracy should result in error",
 			config: &Config{
@@ -49,17 +72,18 @@ func TestValidate(t *testing.T) {
 				I2CBus:           testBus,
 				I2CAddr:          testi2cAddr,
 			},
-			expectedErr: utils.NewConfigValidationFieldRequiredError(path, "required_time"),
+			expectedErr: utils.NewConfigValidationFieldRequiredError(path, "required_time_sec"),
 		},
 		{
-			name: "The required accuracy can only be values 1-5",
+			name: "The required accuracy is in meters and can't be negative",
 			config: &Config{
-				RequiredAccuracy: 6,
+				RequiredAccuracy: -1,
 				RequiredTime:     200,
 				I2CBus:           testBus,
 				I2CAddr:          testi2cAddr,
+				SkipDeviceCheck:  true,
 			},
-			expectedErr: errRequiredAccuracy,
+			expectedErr: errors.New("path: required_accuracy is the survey accuracy in meters and must be positive"),
 		},
 	}
 	for _, tc := range tests {
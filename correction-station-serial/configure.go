@@ -5,17 +5,13 @@ import (
 	"io"
 
 	"github.com/jacobsa/go-serial/serial"
+
+	rtkutils "rtksystem/rtk-utils"
 )
 
 const (
 	ubxSynch1      = 0xB5
 	ubxSynch2      = 0x62
-	ubxRtcm1005    = 0x05 // Stationary RTK reference ARP
-	ubxRtcm1074    = 0x4A // GPS MSM4
-	ubxRtcm1084    = 0x54 // GLONASS MSM4
-	ubxRtcm1094    = 0x5E // Galileo MSM4
-	ubxRtcm1124    = 0x7C // BeiDou MSM4
-	ubxRtcm1230    = 0xE6 // GLONASS code-phase biases, set to once every 10 seconds
 	uart2          = 2
 	usb            = 3
 	ubxRtcmMsb     = 0xF5
@@ -25,7 +21,6 @@ const (
 	maxPayloadSize = 256
 	ubxCfgCfg      = 0x09
 	ubxCfgPrt      = 0x00
-	comTypeRTCM3   = (1 << 5)
 
 	ubxNmeaMsb = 0xF0 // All NMEA enable commands have 0xF0 as MSB. Equal to UBX_CLASS_NMEA
 	ubxNmeaGga = 0x00 // GxGGA (Global positioning system fix data)
@@ -39,15 +34,6 @@ const (
 	svinModeDisable = 0x00
 )
 
-var rtcmMsgs = map[int]int{
-	ubxRtcm1005: 1,
-	ubxRtcm1074: 1,
-	ubxRtcm1084: 1,
-	ubxRtcm1094: 1,
-	ubxRtcm1124: 1,
-	ubxRtcm1230: 5,
-}
-
 var nmeaMsgs = map[int]int{
 	ubxNmeaGll: 1,
 	ubxNmeaGsa: 1,
@@ -66,9 +52,11 @@ type configCommand struct {
 
 	msgsToEnable  map[int]int
 	msgsToDisable map[int]int
+	ephemerides   bool // also output the GPS subframes of RXM-SFRBX
 
-	portID    int
-	writePort io.ReadWriteCloser
+	portID       int
+	extraPortIDs []int // other ports messages are enabled and disabled on
+	writePort    io.ReadWriteCloser
 }
 
 // ConfigureBaseRTKStation configures an RTK chip to act as a base station and send correction data.
@@ -77,18 +65,36 @@ func ConfigureBaseRTKStation(newConf *Config) error {
 	requiredAcc := newConf.RequiredAccuracy
 	observationTime := newConf.RequiredTime
 
+	receiver, err := rtkutils.Receiver(newConf.Receiver)
+	if err != nil {
+		return err
+	}
+
 	c := &configCommand{
 		requiredAcc:     requiredAcc,
 		observationTime: observationTime,
-		msgsToEnable:    rtcmMsgs, // defaults
+		msgsToEnable:    receiver.RTCMOutput,
 		msgsToDisable:   nmeaMsgs, // defaults
+		ephemerides:     newConf.EphemerisOutput,
+	}
+
+	err = c.openSerial(newConf)
+	if err != nil {
+		return err
 	}
+	//nolint:errcheck
+	defer c.Close(context.Background())
 
-	err := c.openSerial(newConf)
+	// boards wire the port the station reads from differently, rtcm goes out on the configured one
+	c.portID, err = rtkutils.UBXPort(newConf.RTCMOutputPort, c.portID)
 	if err != nil {
 		return err
 	}
 
+	if receiver.BasePackets != nil {
+		return c.writeAll(receiver.BasePackets(requiredAcc, observationTime))
+	}
+
 	if err := c.setRTCMOutput(); err != nil {
 		return err
 	}
@@ -98,6 +104,13 @@ func ConfigureBaseRTKStation(newConf *Config) error {
 		return err
 	}
 
+	// the subframes are read by the station to check that the base doesn't move
+	if c.ephemerides {
+		if err := c.enableMessageCommand(rtkutils.UBXClassRxm, rtkutils.UBXRxmSfrbx, c.portID, 1); err != nil {
+			return err
+		}
+	}
+
 	// disable NMEA message sending.
 	err = c.disableAll(ubxNmeaMsb)
 	if err != nil {
@@ -113,6 +126,57 @@ func ConfigureBaseRTKStation(newConf *Config) error {
 	return nil
 }
 
+// Provision configures a factory fresh receiver as a base station: rtcm output on the port the radio
+// is wired to (uart2 by default), nmea disabled there and survey-in with the configured accuracy and time,
+// which can be overridden by the command. Every step is recorded in the returned report.
+func Provision(ctx context.Context, newConf *Config, cmd map[string]interface{}) (map[string]interface{}, error) {
+	port, err := rtkutils.UBXPortArg(cmd, "rtcm_output_port", uart2)
+	if err != nil {
+		return nil, err
+	}
+	baudRate := rtkutils.IntArg(cmd, "baud_rate", 38400)
+	receiver, err := rtkutils.Receiver(newConf.Receiver)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &configCommand{
+		requiredAcc:     newConf.RequiredAccuracy,
+		observationTime: rtkutils.IntArg(cmd, "required_time_sec", newConf.RequiredTime),
+		msgsToEnable:    receiver.RTCMOutput,
+		msgsToDisable:   nmeaMsgs,
+	}
+	if acc, ok := cmd["required_accuracy"].(float64); ok {
+		c.requiredAcc = acc
+	}
+
+	report := rtkutils.NewReport()
+	report.Check("open_receiver", c.openSerial(newConf))
+	if !report.Passed() {
+		return report.Result(), nil
+	}
+	if receiver.BasePackets != nil {
+		// the receiver outputs corrections on the port it is read from once it is a base
+		report.Check("enable_base_mode", c.writeAll(receiver.BasePackets(c.requiredAcc, c.observationTime)))
+		report.Check("close_receiver", c.Close(ctx))
+		return report.Result(), nil
+	}
+	// keep rtcm going to the port the station reads from as well as the requested one
+	if port != c.portID {
+		c.extraPortIDs = []int{port}
+	}
+
+	protocols := uint16(rtkutils.UBXProtoUBX | rtkutils.UBXProtoRTCM3)
+	payload := rtkutils.UBXPortConfigPayload(port, baudRate, protocols, protocols)
+	report.Check("enable_rtcm_output_port", c.sendCommand(ubxClassCfg, ubxCfgPrt, len(payload), payload))
+	report.Check("enable_rtcm_messages", c.enableAll(ubxRtcmMsb))
+	report.Check("disable_nmea_messages", c.disableAll(ubxNmeaMsb))
+	report.Check("enable_survey_in", c.enableSVIN())
+	report.Check("close_receiver", c.Close(ctx))
+
+	return report.Result(), nil
+}
+
 func (c *configCommand) openSerial(newConf *Config) error {
 
 	portName := newConf.SerialPath
@@ -145,13 +209,14 @@ func (c *configCommand) openSerial(newConf *Config) error {
 
 // ensure the chip can output RTCM correction messages.
 func (c *configCommand) setRTCMOutput() error {
-	cls := ubxClassCfg
-	id := ubxCfgPrt
-	msgLen := 15
-	payloadCfg := make([]byte, 15)
-	payloadCfg[14] = comTypeRTCM3
+	outProto := uint16(rtkutils.UBXProtoRTCM3)
+	if c.ephemerides {
+		outProto |= rtkutils.UBXProtoUBX
+	}
+	// the uarts take the baud rate with the protocols, keep it at the one the station reads at
+	payloadCfg := rtkutils.UBXPortConfigPayload(c.portID, int(c.baudRate), rtkutils.UBXProtoUBX|rtkutils.UBXProtoRTCM3, outProto)
 
-	err := c.sendCommand(cls, id, msgLen, payloadCfg)
+	err := c.sendCommand(ubxClassCfg, ubxCfgPrt, len(payloadCfg), payloadCfg)
 
 	if err != nil {
 		return err
@@ -181,6 +246,11 @@ func (c *configCommand) sendCommand(cls, id, msgLen int, payloadCfg []byte) erro
 	packet[len(packet)-1] = byte(checksumB)
 	packet[len(packet)-2] = byte(checksumA)
 
+	return c.write(packet)
+}
+
+// write writes a packet to the receiver and waits for its ack response.
+func (c *configCommand) write(packet []byte) error {
 	_, err := c.writePort.Write(packet)
 	if err != nil {
 		return err
@@ -195,6 +265,16 @@ func (c *configCommand) sendCommand(cls, id, msgLen int, payloadCfg []byte) erro
 	return nil
 }
 
+// writeAll writes every packet to the receiver, stopping at the first that fails.
+func (c *configCommand) writeAll(packets [][]byte) error {
+	for _, packet := range packets {
+		if err := c.write(packet); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (c *configCommand) disableAll(msb int) error {
 	for msg := range c.msgsToDisable {
 		err := c.disableMessageCommand(msb, msg, c.portID)
@@ -332,6 +412,9 @@ func (c *configCommand) enableMessageCommand(msgClass, messageNumber, portID, se
 	payloadCfg[2+portID] = byte(sendRate)
 	// default to enable usb on with same sendRate
 	payloadCfg[2+usb] = byte(sendRate)
+	for _, extraPortID := range c.extraPortIDs {
+		payloadCfg[2+extraPortID] = byte(sendRate)
+	}
 
 	return c.sendCommand(cls, id, msgLen, payloadCfg)
 }
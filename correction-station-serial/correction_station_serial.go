@@ -3,22 +3,67 @@ package stationserial
 import (
 	"context"
 	"errors"
+	"fmt"
 	"io"
+	"net"
 	"sync"
+	"time"
 
 	"github.com/edaniels/golog"
 	"github.com/go-gnss/rtcm/rtcm3"
 	"github.com/jacobsa/go-serial/serial"
-	"go.viam.com/rdk/components/movementsensor"
 	"go.viam.com/rdk/components/sensor"
 	"go.viam.com/rdk/resource"
+	rutils "go.viam.com/rdk/utils"
 	"go.viam.com/utils"
+
+	rtkutils "rtksystem/rtk-utils"
 )
 
 var (
 	Model = resource.NewModel("viam-labs", "sensor", "correction-station-serial")
 )
 
+// Classes of the errors of the station's worker.
+var (
+	classOpenSerial = rtkutils.ErrorClass{Category: rtkutils.ErrorSerial, Op: rtkutils.OpOpen}
+	classReadRTCM   = rtkutils.ErrorClass{Category: rtkutils.ErrorRTCM, Op: rtkutils.OpRead}
+)
+
+// recoveryPolicy is how the station recovers from the errors of its worker.
+var recoveryPolicy = rtkutils.RecoveryPolicy{
+	Actions: map[rtkutils.ErrorClass]rtkutils.RecoveryAction{
+		// a port that fails while open was most likely unplugged, reopen it until it is back
+		classReadRTCM:   rtkutils.Reopen,
+		classOpenSerial: rtkutils.Retry,
+	},
+	MaxFailures: 30,
+}
+
+// configMigration maps the keys of old configs to the current ones.
+var configMigration = rtkutils.ConfigMigration{
+	Protocol: "serial",
+	Nested:   []string{"serial_attributes"},
+	Aliases: map[string]string{
+		"required_time": "required_time_sec",
+	},
+	Dropped: []string{"children", "svin"},
+}
+
+// convertAttributes converts the attributes of a config, migrating the keys of old configs.
+func convertAttributes(attributes rutils.AttributeMap) (*Config, error) {
+	migrated, warnings, err := configMigration.Migrate(attributes)
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := resource.TransformAttributeMap[*Config](migrated)
+	if err != nil {
+		return nil, err
+	}
+	cfg.warnings = warnings
+	return cfg, nil
+}
+
 func init() {
 	resource.RegisterComponent(
 		sensor.API,
@@ -34,8 +79,13 @@ func init() {
 				if err != nil {
 					return nil, err
 				}
+				for _, warning := range newConf.warnings {
+					logger.Warn(warning)
+				}
+				logger.Warnf("%s is deprecated, use viam-labs:sensor:correction-station with a serial input instead", Model.Name)
 				return newRTKStationSerial(ctx, deps, conf.ResourceName(), newConf, logger)
 			},
+			AttributeMapConverter: convertAttributes,
 		})
 }
 
@@ -43,29 +93,101 @@ type Config struct {
 	RequiredAccuracy float64 `json:"required_accuracy,omitempty"`
 	RequiredTime     int     `json:"required_time_sec,omitempty"`
 
+	// The rtcm stream is also written to files in this directory, rotated every rtcm_record_rotate_min
+	RecordDir           string  `json:"rtcm_record_dir,omitempty"`
+	RecordRotateMinutes float64 `json:"rtcm_record_rotate_min,omitempty"`
+	RecordKeepFiles     int     `json:"rtcm_record_keep_files,omitempty"`
+
+	// Forwarded corrections are re-referenced to a virtual base at this position, see rtkutils.VirtualBase
+	VirtualBaseLat float64 `json:"virtual_base_lat,omitempty"`
+	VirtualBaseLng float64 `json:"virtual_base_lng,omitempty"`
+	VirtualBaseAlt float64 `json:"virtual_base_alt_m,omitempty"`
+
 	SerialPath     string `json:"serial_path"`
 	SerialBaudRate int    `json:"serial_baud_rate,omitempty"`
 
+	// The u-blox receiver ConfigureBaseRTKStation configures, zed-f9p by default. Only set by the
+	// correction-station model, see rtkutils.Receiver
+	Receiver string `json:"-"`
+
+	// Whether ConfigureBaseRTKStation also enables the UBX-RXM-SFRBX subframes a BaseMonitor needs.
+	// Only set by the correction-station model
+	EphemerisOutput bool `json:"-"`
+
+	// The receiver port ConfigureBaseRTKStation enables rtcm output on, uart2 by default. Only set by
+	// the correction-station model, see rtkutils.UBXPort
+	RTCMOutputPort string `json:"-"`
+
+	// Validate checks that the serial ports exist unless this is set, for receivers connected later
+	SkipDeviceCheck bool `json:"skip_device_check,omitempty"`
+
+	// Relay mode: corrections are read from an NTRIP caster instead of serial_path
+	// and rebroadcast over the radio on radio_serial_path and/or to udp_output_addr
+	NtripURL        string `json:"ntrip_url,omitempty"`
+	NtripMountpoint string `json:"ntrip_mountpoint,omitempty"`
+	NtripUsername   string `json:"ntrip_username,omitempty"`
+	NtripPassword   string `json:"ntrip_password,omitempty"`
+	RadioSerialPath string `json:"radio_serial_path,omitempty"`
+	RadioBaudRate   int    `json:"radio_baud_rate,omitempty"`
+	UDPOutputAddr   string `json:"udp_output_addr,omitempty"`
+
 	// TestChan is a fake "serial" path for test use only
 	TestChan chan []uint8 `json:"-"`
+
+	// warnings about deprecated keys found by convertAttributes
+	warnings []string
 }
 
 // Validate ensures all parts of the config are valid.
 func (cfg *Config) Validate(path string) ([]string, error) {
 	var deps []string
+	if err := rtkutils.ValidateVirtualBase(cfg.VirtualBaseLat, cfg.VirtualBaseLng); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if cfg.NtripURL != "" {
+		if err := cfg.ntripConfig().Validate(); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		if cfg.RadioSerialPath == "" && cfg.UDPOutputAddr == "" {
+			return nil, utils.NewConfigValidationFieldRequiredError(path, "radio_serial_path")
+		}
+		if cfg.RadioSerialPath != "" && !cfg.SkipDeviceCheck {
+			if err := rtkutils.ValidateDevicePath("radio_serial_path", cfg.RadioSerialPath); err != nil {
+				return nil, fmt.Errorf("%s: %w", path, err)
+			}
+		}
+		return deps, nil
+	}
 	if cfg.RequiredAccuracy == 0 {
 		return nil, utils.NewConfigValidationFieldRequiredError(path, "required_accuracy")
 	}
+	if cfg.RequiredAccuracy < 0 {
+		return nil, fmt.Errorf("%s: required_accuracy is the survey accuracy in meters and must be positive", path)
+	}
 	if cfg.RequiredTime == 0 {
 		return nil, utils.NewConfigValidationFieldRequiredError(path, "required_time_sec")
 	}
 	if cfg.SerialPath == "" {
 		return nil, utils.NewConfigValidationFieldRequiredError(path, "serial_path")
 	}
+	if !cfg.SkipDeviceCheck {
+		if err := rtkutils.ValidateDevicePath("serial_path", cfg.SerialPath); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	}
 
 	return deps, nil
 }
 
+func (cfg *Config) ntripConfig() rtkutils.NtripConfig {
+	return rtkutils.NtripConfig{
+		URL:        cfg.NtripURL,
+		Mountpoint: cfg.NtripMountpoint,
+		Username:   cfg.NtripUsername,
+		Password:   cfg.NtripPassword,
+	}
+}
+
 type rtkStationSerial struct {
 	resource.Named
 	resource.AlwaysRebuild
@@ -73,11 +195,28 @@ type rtkStationSerial struct {
 
 	cancelCtx               context.Context
 	cancelFunc              func()
+	closed                  rtkutils.CloseOnce
 	activeBackgroundWorkers sync.WaitGroup
 
-	reader io.ReadCloser // reads all messages from serial port
+	reader     io.ReadCloser // reads all messages from serial port
+	readerMu   sync.Mutex
+	conf       *Config
+	rtcmFrames rtkutils.Counter      // valid frames read from the receiver
+	replies    rtkutils.ReplyWatcher // replies of the receiver to the self test, among the frames read
+
+	// the receiver wasn't there when the station was built, it is configured and opened once it is
+	waitForReceiver bool
+
+	corrections rtkutils.RTCMBuffer    // recent frames served to remote rovers
+	rtcmFiles   *rtkutils.RTCMFileSink // recording of the rtcm stream, if enabled
+	vrs         *rtkutils.VirtualBase  // re-references forwarded corrections, if enabled
+
+	ntrip  *rtkutils.NtripReader // upstream caster read instead of the receiver in relay mode
+	relays []io.WriteCloser      // radio and udp outputs corrections are rebroadcast to in relay mode
 
-	err movementsensor.LastError
+	err      *rtkutils.ErrorHistory
+	recovery *rtkutils.Recovery
+	workers  *rtkutils.Supervisor
 }
 
 func newRTKStationSerial(
@@ -88,43 +227,75 @@ func newRTKStationSerial(
 	logger golog.Logger,
 ) (sensor.Sensor, error) {
 
-	cancelCtx, cancelFunc := context.WithCancel(context.Background())
+	cancelCtx, cancelFunc := context.WithCancel(rtkutils.ModuleContext())
 
 	r := &rtkStationSerial{
 		Named:      name.AsNamed(),
 		cancelCtx:  cancelCtx,
 		cancelFunc: cancelFunc,
 		logger:     logger,
-		err:        movementsensor.NewLastError(1, 1),
+		err:        rtkutils.NewErrorHistory(),
 	}
+	r.recovery = rtkutils.NewRecovery(recoveryPolicy, r.err, logger)
+	r.workers = rtkutils.NewSupervisor(cancelCtx, &r.activeBackgroundWorkers, logger)
 
 	// set a default baud rate if not specified in config
 	if newConf.SerialBaudRate == 0 {
 		newConf.SerialBaudRate = 38400
 	}
+	if newConf.RadioBaudRate == 0 {
+		newConf.RadioBaudRate = 38400
+	}
+
+	var err error
+	if newConf.NtripURL == "" {
+		r.logger.Debug("configuring the base station")
+		if err = ConfigureBaseRTKStation(newConf); err != nil {
+			r.logger.Warn("rtk base station could not be configured")
+		}
+	}
 
-	r.logger.Debug("configuring the base station")
+	r.conf = newConf
 
-	err := ConfigureBaseRTKStation(newConf)
+	r.vrs = rtkutils.NewVirtualBase(newConf.VirtualBaseLat, newConf.VirtualBaseLng, newConf.VirtualBaseAlt)
+	r.rtcmFiles, err = rtkutils.NewRTCMFileSink(newConf.RecordDir,
+		time.Duration(newConf.RecordRotateMinutes*float64(time.Minute)), newConf.RecordKeepFiles)
 	if err != nil {
-		r.logger.Warn("rtk base station could not be configured")
+		cancelFunc()
+		return nil, err
 	}
 
-	if newConf.TestChan == nil {
+	if newConf.NtripURL != "" {
+		if err := r.openRelays(); err != nil {
+			r.logger.Errorf("Error opening the relay outputs: %s", err)
+			cancelFunc()
+			r.rtcmFiles.Close()
+			r.closeRelays()
+			return nil, err
+		}
+		r.ntrip = rtkutils.NewNtripReader(cancelCtx, newConf.ntripConfig(), rtkutils.DefaultNtripRetryInterval, logger)
+		r.reader = r.ntrip
+	} else if newConf.TestChan == nil {
 		r.reader, err = r.openReader(newConf.SerialPath, newConf.SerialBaudRate)
-		if err != nil {
+		if rtkutils.IsDeviceAbsent(err) {
+			r.logger.Warnf("serial port %s isn't there yet, waiting for the receiver: %s", newConf.SerialPath, err)
+			r.reader, r.waitForReceiver = nil, true
+		} else if err != nil {
 			r.logger.Errorf("Error opening the serial port", err)
+			cancelFunc()
+			r.rtcmFiles.Close()
 			return nil, err
 		}
 	}
 
 	r.logger.Debug("Starting the serial station")
 	r.start(ctx)
+	rtkutils.TrackResource(r)
 
 	return r, r.err.Get()
 }
 
-func (r *rtkStationSerial) openReader(path string, baud int) (io.ReadCloser, error) {
+func (r *rtkStationSerial) openReader(path string, baud int) (io.ReadWriteCloser, error) {
 	options := serial.OpenOptions{
 		PortName:        path,
 		BaudRate:        uint(baud),
@@ -140,60 +311,272 @@ func (r *rtkStationSerial) openReader(path string, baud int) (io.ReadCloser, err
 	return port, nil
 }
 
+// awaitReceiver configures the receiver and opens its serial port once it is there, retrying until
+// it is. It returns false if the worker must stop instead.
+func (r *rtkStationSerial) awaitReceiver() bool {
+	for {
+		err := ConfigureBaseRTKStation(r.conf)
+		if err == nil || !rtkutils.IsDeviceAbsent(err) {
+			if err != nil {
+				r.logger.Warnf("rtk base station could not be configured: %s", err)
+			}
+			return r.reopenReader()
+		}
+		if r.cancelCtx.Err() != nil || r.recovery.Handle(r.cancelCtx, classOpenSerial, err) == rtkutils.Rebuild {
+			return false
+		}
+	}
+}
+
+// currentReader returns the reader corrections are read from, or nil if it isn't open.
+func (r *rtkStationSerial) currentReader() io.Reader {
+	r.readerMu.Lock()
+	defer r.readerMu.Unlock()
+	if r.reader == nil {
+		return nil
+	}
+	return r.reader
+}
+
+// reopenReader closes the serial port and opens it again, retrying until it opens. It returns false
+// if the worker must stop instead.
+func (r *rtkStationSerial) reopenReader() bool {
+	if r.ntrip != nil {
+		// the ntrip reader reconnects by itself
+		return true
+	}
+	for {
+		err := r.replaceReader()
+		if err == nil {
+			r.recovery.Succeeded(classOpenSerial)
+			return true
+		}
+		if r.cancelCtx.Err() != nil || r.recovery.Handle(r.cancelCtx, classOpenSerial, err) == rtkutils.Rebuild {
+			return false
+		}
+	}
+}
+
+// replaceReader closes the serial port and opens it again, unless the station is closing.
+func (r *rtkStationSerial) replaceReader() error {
+	r.readerMu.Lock()
+	defer r.readerMu.Unlock()
+	if err := r.cancelCtx.Err(); err != nil {
+		return err
+	}
+	if r.reader != nil {
+		if err := r.reader.Close(); err != nil {
+			r.err.Record(rtkutils.ErrorSerial, err)
+		}
+		r.reader = nil
+	}
+	reader, err := r.openReader(r.conf.SerialPath, r.conf.SerialBaudRate)
+	if err != nil {
+		return err
+	}
+	r.reader = reader
+	return nil
+}
+
+// openRelays opens the radio and udp outputs corrections are rebroadcast to in relay mode.
+func (r *rtkStationSerial) openRelays() error {
+	if r.conf.RadioSerialPath != "" {
+		radio, err := r.openReader(r.conf.RadioSerialPath, r.conf.RadioBaudRate)
+		if err != nil {
+			return err
+		}
+		r.relays = append(r.relays, radio)
+	}
+	if r.conf.UDPOutputAddr != "" {
+		conn, err := net.Dial("udp", r.conf.UDPOutputAddr)
+		if err != nil {
+			return err
+		}
+		r.relays = append(r.relays, conn)
+	}
+	return nil
+}
+
+// relay rebroadcasts a correction frame to every relay output.
+func (r *rtkStationSerial) relay(frame []byte) {
+	for _, w := range r.relays {
+		if _, err := w.Write(frame); err != nil {
+			r.logger.Debugf("failed to relay rtcm frame: %s", err)
+		}
+	}
+}
+
+func (r *rtkStationSerial) closeRelays() {
+	for _, w := range r.relays {
+		if err := w.Close(); err != nil {
+			r.logger.Errorf("failed to close relay output: %s", err)
+		}
+	}
+	r.relays = nil
+}
+
 // Start starts reading from the correction source and sends corrections to the radio/bluetooth.
 func (r *rtkStationSerial) start(ctx context.Context) {
-	r.activeBackgroundWorkers.Add(1)
-	utils.PanicCapturingGo(func() {
-		defer r.activeBackgroundWorkers.Done()
-
+	r.workers.Go("rtcm reader", func() error {
 		if err := r.cancelCtx.Err(); err != nil {
-			return
+			return nil
 		}
 		select {
 		case <-r.cancelCtx.Done():
-			return
+			return nil
 		default:
 		}
 
-		// Read the rctm messages just to make sure that they are coming in, return if not.
-		scanner := rtcm3.NewScanner(r.reader)
+		if r.waitForReceiver {
+			if !r.awaitReceiver() {
+				return nil
+			}
+			r.waitForReceiver = false
+		}
+		reader := r.currentReader()
+		if reader == nil {
+			return nil
+		}
+		scanner := rtcm3.NewScanner(r.replies.Reader(reader))
 
 		for {
 			select {
 			case <-r.cancelCtx.Done():
-				return
+				return nil
 			default:
 			}
 
 			msg, err := scanner.NextMessage()
 			if err != nil {
-				r.logger.Errorf("Error reading RTCM message: %s", err)
-				r.err.Set(err)
-				return
+				if r.cancelCtx.Err() != nil {
+					// the port was closed on shutdown
+					return nil
+				}
+				switch r.recovery.Handle(r.cancelCtx, classReadRTCM, err) {
+				case rtkutils.Rebuild:
+					return rtkutils.ErrRebuildRequired
+				case rtkutils.Reopen:
+					if !r.reopenReader() {
+						return nil
+					}
+					if reader = r.currentReader(); reader == nil {
+						return nil
+					}
+				}
+				scanner = rtcm3.NewScanner(r.replies.Reader(reader))
+				continue
 			}
+			r.recovery.Succeeded(classReadRTCM)
+			r.rtcmFrames.Add(1)
 			switch msg.(type) {
 			case rtcm3.MessageUnknown:
 				continue
 			default:
+				out, ok := r.vrs.Transform(msg)
+				if !ok {
+					continue
+				}
+				frame := rtcm3.EncapsulateMessage(out).Serialize()
+				r.corrections.Add(frame)
+				r.relay(frame)
+				if _, err := r.rtcmFiles.Write(frame); err != nil {
+					r.logger.Warnf("failed to record rtcm frame: %s", err)
+				}
 			}
 		}
 	})
 }
 
+// DoCommand runs the commands supported by the station.
+func (r *rtkStationSerial) DoCommand(ctx context.Context, cmd map[string]interface{}) (map[string]interface{}, error) {
+	switch cmd[rtkutils.CommandKey] {
+	case rtkutils.SelfTestCommand:
+		return r.selfTest(ctx, rtkutils.SelfTestTimeout(cmd)), nil
+	case rtkutils.ProvisionCommand:
+		return Provision(ctx, r.conf, cmd)
+	case rtkutils.ReadRTCMCommand:
+		return r.corrections.ReadRTCM(cmd), nil
+	case rtkutils.ErrorsCommand:
+		return r.err.ErrorsResult(cmd), nil
+	case rtkutils.VersionCommand:
+		return rtkutils.VersionResult(), nil
+	default:
+		return nil, resource.ErrDoUnimplemented
+	}
+}
+
+// selfTest checks that the port is open, that the receiver accepts configuration and that it outputs rtcm frames.
+// In relay mode it checks the connection to the ntrip caster instead of the receiver.
+func (r *rtkStationSerial) selfTest(ctx context.Context, timeout time.Duration) map[string]interface{} {
+	report := rtkutils.NewReport()
+
+	if r.ntrip != nil {
+		report.Check("ntrip_stream", rtkutils.WaitFor(ctx, timeout, "ntrip stream", func() bool { return r.ntrip.Err() == nil }))
+	} else {
+		var err error
+		if r.currentReader() == nil {
+			err = fmt.Errorf("serial port %s is not open", r.conf.SerialPath)
+		}
+		report.Check("serial_port_open", err)
+		report.Check("receiver_config", r.checkReceiverConfig(ctx, timeout))
+	}
+	report.Check("rtcm_frames", rtkutils.WaitForIncrease(ctx, timeout, "rtcm frames", r.rtcmFrames.Get))
+
+	return report.Result()
+}
+
+// checkReceiverConfig re-sends the rtcm 1005 output rate the station already uses, or the probe of receivers that aren't
+// configured with UBX, on the port corrections are read from, and waits for the receiver to acknowledge it among them.
+func (r *rtkStationSerial) checkReceiverConfig(ctx context.Context, timeout time.Duration) error {
+	receiver, err := rtkutils.Receiver(r.conf.Receiver)
+	if err != nil {
+		return err
+	}
+	port, ok := r.currentReader().(io.Writer)
+	if !ok {
+		return fmt.Errorf("serial port %s is not open for writing", r.conf.SerialPath)
+	}
+
+	packet, ack, nak := receiver.ProbePacket, receiver.ProbeAck, receiver.ProbeNak
+	if packet == nil {
+		packet = rtkutils.UBXMessageRatePacket(ubxRtcmMsb, rtkutils.UBXRTCM1005, byte(receiver.RTCMOutput[rtkutils.UBXRTCM1005]))
+		ack, nak = rtkutils.UBXAckPackets(ubxClassCfg, ubxCfgMsg)
+	}
+	return r.replies.Await(ctx, timeout, ack, nak, func() error {
+		_, err := port.Write(packet)
+		return err
+	})
+}
+
 // Close shuts down the rtkStation.
 func (r *rtkStationSerial) Close(ctx context.Context) error {
+	return r.closed.Close(func() error { return r.close(ctx) })
+}
+
+func (r *rtkStationSerial) close(ctx context.Context) error {
+	rtkutils.UntrackResource(r)
 	r.cancelFunc()
-	r.activeBackgroundWorkers.Wait()
 
-	// close correction reader
+	// close correction reader first so a read blocked on the port can return
+	r.readerMu.Lock()
 	if r.reader != nil {
 		err := r.reader.Close()
-		r.err.Set(err)
+		r.err.Set(rtkutils.ErrorSerial, err)
 		if err != nil {
 			r.logger.Errorf("failed to close the serial reader: %s", err)
 		}
+		r.reader = nil
+	}
+	r.readerMu.Unlock()
+
+	if !rtkutils.WaitWithTimeout(ctx, &r.activeBackgroundWorkers, rtkutils.DefaultShutdownTimeout) {
+		r.logger.Warn("timed out waiting for background workers to stop")
+	}
+	r.closeRelays()
+
+	if err := r.rtcmFiles.Close(); err != nil {
+		r.logger.Errorf("failed to close the rtcm recording: %s", err)
 	}
-	r.reader = nil
 
 	if err := r.err.Get(); err != nil && !errors.Is(err, context.Canceled) {
 		return err
@@ -202,7 +585,12 @@ func (r *rtkStationSerial) Close(ctx context.Context) error {
 	return nil
 }
 
-// Readings not supported.
+// Readings returns the state of the station and how many corrections it read.
 func (r *rtkStationSerial) Readings(ctx context.Context, extra map[string]interface{}) (map[string]interface{}, error) {
-	return map[string]interface{}{}, errors.New("unimplemented")
+	return map[string]interface{}{
+		rtkutils.StateKey: rtkutils.DeviceState(
+			rtkutils.StationState(r.err, &r.rtcmFrames, r.conf.NtripURL == "", time.Now()), r.recovery),
+		"rtcm_frames":        r.rtcmFrames.Get(),
+		rtkutils.RestartsKey: r.workers.Restarts(),
+	}, nil
 }
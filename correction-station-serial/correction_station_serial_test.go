@@ -2,13 +2,18 @@ package stationserial
 
 import (
 	"context"
+	"errors"
+	"io"
 	"testing"
+	"time"
 
 	"github.com/edaniels/golog"
 	"go.viam.com/rdk/components/movementsensor"
 	"go.viam.com/rdk/resource"
 	"go.viam.com/test"
 	"go.viam.com/utils"
+
+	rtkutils "rtksystem/rtk-utils"
 )
 
 const (
@@ -25,11 +30,21 @@ func TestValidate(t *testing.T) {
 	}{
 		{
 			name: "A valid config should result in no errors",
+			config: &Config{
+				RequiredAccuracy: 4,
+				RequiredTime:     200,
+				SerialPath:       testPath,
+				SkipDeviceCheck:  true,
+			},
+		},
+		{
+			name: "A serial path that doesn't exist should error",
 			config: &Config{
 				RequiredAccuracy: 4,
 				RequiredTime:     200,
 				SerialPath:       testPath,
 			},
+			expectedErr: errors.New("path: serial_path \"test-path\" doesn't exist, check that the device is connected, or set skip_device_check if it is only connected later"),
 		},
 		{
 			name: "a config with no RequiredAccuracy should result in error",
@@ -45,7 +60,17 @@ func TestValidate(t *testing.T) {
 				RequiredAccuracy: 4,
 				SerialPath:       testPath,
 			},
-			expectedErr: utils.NewConfigValidationFieldRequiredError(path, "required_time"),
+			expectedErr: utils.NewConfigValidationFieldRequiredError(path, "required_time_sec"),
+		},
+		{
+			name: "The required accuracy is in meters and can't be negative",
+			config: &Config{
+				RequiredAccuracy: -1,
+				RequiredTime:     200,
+				SerialPath:       testPath,
+				SkipDeviceCheck:  true,
+			},
+			expectedErr: errors.New("path: required_accuracy is the survey accuracy in meters and must be positive"),
 		},
 		{
 			name: "No serial path should error",
@@ -55,6 +80,23 @@ func TestValidate(t *testing.T) {
 			},
 			expectedErr: utils.NewConfigValidationFieldRequiredError(path, "serial_path"),
 		},
+		{
+			name: "A relay config needs no receiver settings",
+			config: &Config{
+				NtripURL:        "http://caster.example.com:2101",
+				NtripMountpoint: "MP",
+				RadioSerialPath: testPath,
+				SkipDeviceCheck: true,
+			},
+		},
+		{
+			name: "A relay config with no output should error",
+			config: &Config{
+				NtripURL:        "http://caster.example.com:2101",
+				NtripMountpoint: "MP",
+			},
+			expectedErr: utils.NewConfigValidationFieldRequiredError(path, "radio_serial_path"),
+		},
 	}
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
@@ -109,3 +151,62 @@ func TestNewSerialRTKStation(t *testing.T) {
 		})
 	}
 }
+
+// fakeReceiver replies to every message written to it.
+type fakeReceiver struct {
+	reply   []byte
+	replies chan []byte
+}
+
+func (f *fakeReceiver) Write(p []byte) (int, error) {
+	f.replies <- f.reply
+	return len(p), nil
+}
+
+func (f *fakeReceiver) Read(p []byte) (int, error) {
+	return copy(p, <-f.replies), nil
+}
+
+func (f *fakeReceiver) Close() error {
+	return nil
+}
+
+func TestCheckReceiverConfig(t *testing.T) {
+	ack, nak := rtkutils.UBXAckPackets(ubxClassCfg, ubxCfgMsg)
+
+	tests := []struct {
+		name        string
+		reply       []byte
+		expectedErr error
+	}{
+		{
+			name:  "A config the receiver acknowledges passes",
+			reply: append([]byte{0xD3, 0x00}, ack...),
+		},
+		{
+			name:        "A config the receiver rejects fails",
+			reply:       nak,
+			expectedErr: errors.New("receiver rejected the configuration"),
+		},
+		{
+			name:        "Rtcm read from the receiver is not an acknowledgement",
+			reply:       []byte{0xD3, 0x00, 0x13, 0x3E, 0xD0},
+			expectedErr: errors.New("receiver did not acknowledge the configuration within 50ms"),
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			receiver := &fakeReceiver{reply: tc.reply, replies: make(chan []byte, 1)}
+			r := &rtkStationSerial{reader: receiver, conf: &Config{SerialPath: testPath}}
+			// the station reads the reply along with its corrections
+			go io.Copy(io.Discard, r.replies.Reader(receiver))
+
+			err := r.checkReceiverConfig(context.Background(), 50*time.Millisecond)
+			if tc.expectedErr != nil {
+				test.That(t, err, test.ShouldBeError, tc.expectedErr)
+			} else {
+				test.That(t, err, test.ShouldBeNil)
+			}
+		})
+	}
+}
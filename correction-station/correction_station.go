@@ -0,0 +1,1223 @@
+// Package correctionstation implements a correction station that reads rtcm corrections from one
+// input transport and forwards them to any number of output transports.
+package correctionstation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/edaniels/golog"
+	"go.viam.com/rdk/components/sensor"
+	"go.viam.com/rdk/resource"
+	"go.viam.com/utils"
+
+	stationi2c "rtksystem/correction-station-i2c"
+	stationserial "rtksystem/correction-station-serial"
+	rtkutils "rtksystem/rtk-utils"
+)
+
+var (
+	Model = resource.NewModel("viam-labs", "sensor", "correction-station")
+)
+
+// Transports corrections are read from and forwarded to.
+const (
+	TransportSerial = "serial"
+	TransportI2C    = "i2c"
+	TransportTCP    = "tcp"
+	TransportUDP    = "udp"
+	TransportNtrip  = "ntrip"
+	// an output rovers and other stations connect to
+	TransportTCPServer = "tcp_server"
+)
+
+const defaultBaudRate = 38400
+
+func init() {
+	resource.RegisterComponent(
+		sensor.API,
+		Model,
+		resource.Registration[sensor.Sensor, *Config]{
+			Constructor: func(
+				ctx context.Context,
+				deps resource.Dependencies,
+				conf resource.Config,
+				logger golog.Logger,
+			) (sensor.Sensor, error) {
+				newConf, err := resource.NativeConfig[*Config](conf)
+				if err != nil {
+					return nil, err
+				}
+				return newCorrectionStation(ctx, deps, conf.ResourceName(), newConf, logger)
+			},
+		})
+}
+
+// Config is used for the correction-station attributes.
+type Config struct {
+	// Needed to survey the position of a receiver connected over serial or i2c
+	RequiredAccuracy float64 `json:"required_accuracy,omitempty"`
+	RequiredTime     int     `json:"required_time_sec,omitempty"`
+
+	Input   InputConfig    `json:"input"`
+	Outputs []OutputConfig `json:"outputs,omitempty"`
+
+	// The rtcm stream is also written to files in this directory, rotated every rtcm_record_rotate_min
+	RecordDir           string  `json:"rtcm_record_dir,omitempty"`
+	RecordRotateMinutes float64 `json:"rtcm_record_rotate_min,omitempty"`
+	RecordKeepFiles     int     `json:"rtcm_record_keep_files,omitempty"`
+
+	// Forwarded corrections are re-referenced to a virtual base at this position, see rtkutils.VirtualBase
+	VirtualBaseLat float64 `json:"virtual_base_lat,omitempty"`
+	VirtualBaseLng float64 `json:"virtual_base_lng,omitempty"`
+	VirtualBaseAlt float64 `json:"virtual_base_alt_m,omitempty"`
+
+	// A base_moved event is raised when the antenna is further than this from its surveyed position,
+	// see rtkutils.BaseMonitor
+	BaseMoveThreshold float64 `json:"base_move_threshold_m,omitempty"`
+
+	// The GPS ephemerides decoded from the receiver are also forwarded as 1019 messages, see
+	// rtkutils.EphemerisRelay
+	RelayEphemerides bool `json:"relay_ephemerides,omitempty"`
+
+	// Corrections are only forwarded during the minutes matching transmit_schedule, a cron expression
+	// such as "* 7-18 * * 1-5", in schedule_timezone (the system's by default). Outside of it the GNSS
+	// of a u-blox receiver on the input is stopped if schedule_low_power is set, see rtkutils.Schedule
+	TransmitSchedule string `json:"transmit_schedule,omitempty"`
+	ScheduleTimezone string `json:"schedule_timezone,omitempty"`
+	ScheduleLowPower bool   `json:"schedule_low_power,omitempty"`
+
+	// A sensor measuring the supply of the station in its "volts" reading, such as the battery of a
+	// solar powered base.
+	// Below min_voltage the station stops transmitting and saves its state, until the supply is back
+	// to resume_voltage (half a volt above min_voltage by default), see rtkutils.BrownoutGuard
+	VoltageSensor string  `json:"voltage_sensor,omitempty"`
+	MinVoltage    float64 `json:"min_voltage,omitempty"`
+	ResumeVoltage float64 `json:"resume_voltage,omitempty"`
+
+	// How long to wait after an i2c read that returned no data, 50ms by default
+	PollIntervalMs int `json:"poll_interval_ms,omitempty"`
+
+	// Validate checks that serial ports and i2c buses exist unless this is set, for devices connected later
+	SkipDeviceCheck bool `json:"skip_device_check,omitempty"`
+}
+
+// InputConfig is where the station reads corrections from: a receiver connected over serial or
+// i2c, another station or a radio served over tcp, or an NTRIP caster.
+type InputConfig struct {
+	Transport string `json:"transport"`
+
+	SerialPath     string `json:"serial_path,omitempty"`
+	SerialBaudRate int    `json:"serial_baud_rate,omitempty"`
+
+	I2CBus      int `json:"i2c_bus,omitempty"`
+	I2CAddr     int `json:"i2c_addr,omitempty"`
+	I2CBaudRate int `json:"i2c_baud_rate,omitempty"`
+
+	// How much is read from a serial or i2c input at once, rtkutils.DefaultReadBufferSize by default
+	ReadBufferSize int `json:"read_buffer_size,omitempty"`
+
+	// The u-blox receiver on a serial or i2c input, zed-f9p by default, see rtkutils.Receiver
+	Receiver string `json:"receiver,omitempty"`
+
+	// The port of the receiver on the input that outputs rtcm, one of uart1, uart2, usb or i2c. uart2
+	// by default on a serial input and i2c on an i2c input
+	RTCMOutputPort string `json:"rtcm_output_port,omitempty"`
+
+	// host:port of a tcp input
+	Addr string `json:"addr,omitempty"`
+
+	NtripURL        string `json:"ntrip_url,omitempty"`
+	NtripMountpoint string `json:"ntrip_mountpoint,omitempty"`
+	NtripUsername   string `json:"ntrip_username,omitempty"`
+	NtripPassword   string `json:"ntrip_password,omitempty"`
+
+	// TLS on a tcp or ntrip input, see rtkutils.TLSConfig
+	TLS         bool   `json:"tls,omitempty"`
+	TLSCAFile   string `json:"tls_ca_file,omitempty"`
+	TLSCertFile string `json:"tls_cert_file,omitempty"`
+	TLSKeyFile  string `json:"tls_key_file,omitempty"`
+}
+
+// OutputConfig is where the station forwards corrections to: a radio on a serial port, a tcp or
+// udp host:port, or the clients of a tcp server.
+type OutputConfig struct {
+	Transport string `json:"transport"`
+
+	SerialPath     string `json:"serial_path,omitempty"`
+	SerialBaudRate int    `json:"serial_baud_rate,omitempty"`
+
+	// host:port to connect to, or to listen on for a tcp_server output
+	Addr string `json:"addr,omitempty"`
+
+	// IP addresses and CIDR ranges of the clients allowed to connect to a tcp_server output, any if
+	// empty, and how many connections each may hold, see rtkutils.ClientACL
+	AllowedClients       []string `json:"allowed_clients,omitempty"`
+	MaxClientConnections int      `json:"max_client_connections,omitempty"`
+
+	// TLS on a tcp output, see rtkutils.TLSConfig
+	TLS         bool   `json:"tls,omitempty"`
+	TLSCAFile   string `json:"tls_ca_file,omitempty"`
+	TLSCertFile string `json:"tls_cert_file,omitempty"`
+	TLSKeyFile  string `json:"tls_key_file,omitempty"`
+
+	// Key shared with the rovers to authenticate the frames sent over a radio or udp, see
+	// rtkutils.FrameSigner
+	AuthKey string `json:"auth_key,omitempty"`
+
+	// What is written to a serial radio is wrapped in frames with a CRC and a sequence number, for
+	// rovers whose correction source sets radio_framing too, see rtkutils.RadioFramer
+	RadioFraming bool `json:"radio_framing,omitempty"`
+
+	// The corrections are sent in MAVLink GPS_RTCM_DATA messages, for an ArduPilot or PX4 autopilot
+	// on the other end of a telemetry radio or udp link to forward to its GPS, see rtkutils.MAVLinkRTCM
+	MAVLink bool `json:"mavlink,omitempty"`
+
+	// The output gets a status line every second instead of the corrections, for monitors that
+	// can't decode RTCM, see rtkutils.HeartbeatSentence
+	Heartbeat bool `json:"heartbeat,omitempty"`
+}
+
+// Validate ensures all parts of the config are valid.
+func (cfg *Config) Validate(path string) ([]string, error) {
+	var deps []string
+	if err := cfg.validateInput(path); err != nil {
+		return nil, err
+	}
+	if cfg.hasReceiver() {
+		if cfg.RequiredAccuracy == 0 {
+			return nil, utils.NewConfigValidationFieldRequiredError(path, "required_accuracy")
+		}
+		if cfg.RequiredTime == 0 {
+			return nil, utils.NewConfigValidationFieldRequiredError(path, "required_time_sec")
+		}
+		if err := rtkutils.ValidateBaseReceiver("input.receiver", cfg.Input.Receiver, cfg.BaseMoveThreshold > 0); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	} else if !cfg.hasCorrectionOutput() {
+		// corrections that don't come from a receiver have nowhere else to go
+		return nil, utils.NewConfigValidationFieldRequiredError(path, "outputs")
+	}
+	for i, output := range cfg.Outputs {
+		if err := cfg.validateOutput(path, fmt.Sprintf("outputs.%d", i), output); err != nil {
+			return nil, err
+		}
+	}
+	if err := rtkutils.ValidateVirtualBase(cfg.VirtualBaseLat, cfg.VirtualBaseLng); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if err := rtkutils.ValidateBaseMonitor(cfg.BaseMoveThreshold); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if err := rtkutils.ValidateEphemerisRelay(cfg.RelayEphemerides, cfg.hasReceiver() && cfg.ephemerisOutput()); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if err := rtkutils.ValidateSchedule(cfg.TransmitSchedule, cfg.ScheduleTimezone, cfg.ScheduleLowPower,
+		cfg.hasReceiver() && cfg.ubxReceiver()); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if err := rtkutils.ValidateBrownout(cfg.VoltageSensor, cfg.MinVoltage, cfg.ResumeVoltage); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if err := rtkutils.ValidatePollInterval(cfg.PollIntervalMs); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if cfg.VoltageSensor != "" {
+		deps = append(deps, cfg.VoltageSensor)
+	}
+
+	return deps, nil
+}
+
+func (cfg *Config) validateInput(path string) error {
+	input := cfg.Input
+	switch input.Transport {
+	case "":
+		return utils.NewConfigValidationFieldRequiredError(path, "input.transport")
+	case TransportSerial:
+		if input.SerialPath == "" {
+			return utils.NewConfigValidationFieldRequiredError(path, "input.serial_path")
+		}
+		if !cfg.SkipDeviceCheck {
+			if err := rtkutils.ValidateDevicePath("input.serial_path", input.SerialPath); err != nil {
+				return fmt.Errorf("%s: %w", path, err)
+			}
+		}
+	case TransportI2C:
+		if input.I2CBus == 0 {
+			return utils.NewConfigValidationFieldRequiredError(path, "input.i2c_bus")
+		}
+		if input.I2CAddr == 0 {
+			return utils.NewConfigValidationFieldRequiredError(path, "input.i2c_addr")
+		}
+		if err := rtkutils.ValidateI2CAddr("input.i2c_addr", input.I2CAddr); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		if !cfg.SkipDeviceCheck {
+			if err := rtkutils.ValidateDevicePath("input.i2c_bus", rtkutils.I2CBusPath(input.I2CBus)); err != nil {
+				return fmt.Errorf("%s: %w", path, err)
+			}
+		}
+	case TransportTCP:
+		if err := validateAddr(path, "input.addr", input.Addr); err != nil {
+			return err
+		}
+		if err := input.tlsConfig().Validate("input."); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+	case TransportNtrip:
+		if err := input.ntripConfig().Validate(); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+	default:
+		return fmt.Errorf("%s: input.transport %q isn't supported, use %s, %s, %s or %s",
+			path, input.Transport, TransportSerial, TransportI2C, TransportTCP, TransportNtrip)
+	}
+	if input.TLS && input.Transport != TransportTCP && input.Transport != TransportNtrip {
+		return fmt.Errorf("%s: input.tls is only supported over %s and %s", path, TransportTCP, TransportNtrip)
+	}
+	if input.ReadBufferSize != 0 && !cfg.hasReceiver() {
+		return fmt.Errorf("%s: input.read_buffer_size is only supported over %s and %s", path, TransportSerial, TransportI2C)
+	}
+	if err := rtkutils.ValidateReadBufferSize("input.read_buffer_size", input.ReadBufferSize); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	if input.RTCMOutputPort != "" && !cfg.hasReceiver() {
+		return fmt.Errorf("%s: input.rtcm_output_port is only supported over %s and %s", path, TransportSerial, TransportI2C)
+	}
+	if err := rtkutils.ValidateRTCMOutputPort("input.rtcm_output_port", input.RTCMOutputPort, input.Receiver); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
+
+func (cfg *Config) validateOutput(path, field string, output OutputConfig) error {
+	if err := rtkutils.ValidateAuthKey(field+".auth_key", output.AuthKey); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	if output.Heartbeat && output.AuthKey != "" {
+		return fmt.Errorf("%s: %s.auth_key isn't used with heartbeat, which sends no corrections", path, field)
+	}
+	if output.RadioFraming && output.Transport != TransportSerial {
+		return fmt.Errorf("%s: %s.radio_framing is only supported over %s", path, field, TransportSerial)
+	}
+	if output.Heartbeat && output.RadioFraming {
+		return fmt.Errorf("%s: %s.radio_framing isn't used with heartbeat, which sends no corrections", path, field)
+	}
+	if output.MAVLink && output.Transport == TransportTCPServer {
+		return fmt.Errorf("%s: %s.mavlink is only supported over %s, %s and %s", path, field, TransportSerial, TransportTCP, TransportUDP)
+	}
+	if output.MAVLink && (output.Heartbeat || output.AuthKey != "" || output.RadioFraming) {
+		return fmt.Errorf("%s: %s.mavlink can't be used with heartbeat, auth_key or radio_framing, autopilots only read MAVLink",
+			path, field)
+	}
+	if output.TLS && output.Transport != TransportTCP {
+		return fmt.Errorf("%s: %s.tls is only supported over %s", path, field, TransportTCP)
+	}
+	switch output.Transport {
+	case "":
+		return utils.NewConfigValidationFieldRequiredError(path, field+".transport")
+	case TransportSerial:
+		if output.SerialPath == "" {
+			return utils.NewConfigValidationFieldRequiredError(path, field+".serial_path")
+		}
+		if cfg.Input.Transport == TransportSerial && rtkutils.SameDevice(output.SerialPath, cfg.Input.SerialPath) {
+			return fmt.Errorf("%s: %s.serial_path is the input serial port, corrections can't be forwarded to where they are read from",
+				path, field)
+		}
+		if !cfg.SkipDeviceCheck {
+			if err := rtkutils.ValidateDevicePath(field+".serial_path", output.SerialPath); err != nil {
+				return fmt.Errorf("%s: %w", path, err)
+			}
+		}
+	case TransportTCP, TransportUDP:
+		if err := validateAddr(path, field+".addr", output.Addr); err != nil {
+			return err
+		}
+		if err := output.tlsConfig().Validate(field + "."); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+	case TransportTCPServer:
+		if err := validateAddr(path, field+".addr", output.Addr); err != nil {
+			return err
+		}
+		if err := output.clientACL().Validate(field + "."); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+	default:
+		return fmt.Errorf("%s: %s.transport %q isn't supported, use %s, %s, %s or %s",
+			path, field, output.Transport, TransportSerial, TransportTCP, TransportUDP, TransportTCPServer)
+	}
+	if output.Transport != TransportTCPServer && (len(output.AllowedClients) > 0 || output.MaxClientConnections != 0) {
+		return fmt.Errorf("%s: %s.allowed_clients and %s.max_client_connections are only used with %s",
+			path, field, field, TransportTCPServer)
+	}
+	return nil
+}
+
+// validateAddr checks that the host:port of field is set.
+func validateAddr(path, field, addr string) error {
+	if addr == "" {
+		return utils.NewConfigValidationFieldRequiredError(path, field)
+	}
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		return fmt.Errorf("%s: %s %q must be a host:port: %w", path, field, addr, err)
+	}
+	return nil
+}
+
+// hasCorrectionOutput reports whether an output gets the corrections rather than the heartbeat.
+func (cfg *Config) hasCorrectionOutput() bool {
+	for _, output := range cfg.Outputs {
+		if !output.Heartbeat {
+			return true
+		}
+	}
+	return false
+}
+
+// hasReceiver reports whether the input is a receiver the station configures and surveys with.
+func (cfg *Config) hasReceiver() bool {
+	return cfg.Input.Transport == TransportSerial || cfg.Input.Transport == TransportI2C
+}
+
+// serialStation returns the config the serial station uses to configure a receiver on the input.
+func (cfg *Config) serialStation() *stationserial.Config {
+	return &stationserial.Config{
+		RequiredAccuracy: cfg.RequiredAccuracy,
+		RequiredTime:     cfg.RequiredTime,
+		SerialPath:       cfg.Input.SerialPath,
+		SerialBaudRate:   baudRate(cfg.Input.SerialBaudRate),
+		Receiver:         cfg.Input.Receiver,
+		EphemerisOutput:  cfg.ephemerisOutput(),
+		RTCMOutputPort:   cfg.Input.RTCMOutputPort,
+	}
+}
+
+// i2cStation returns the config the i2c station uses to configure a receiver on the input.
+func (cfg *Config) i2cStation() *stationi2c.Config {
+	return &stationi2c.Config{
+		RequiredAccuracy: cfg.RequiredAccuracy,
+		RequiredTime:     cfg.RequiredTime,
+		I2CBus:           cfg.Input.I2CBus,
+		I2CAddr:          cfg.Input.I2CAddr,
+		I2CBaudRate:      cfg.Input.I2CBaudRate,
+		Receiver:         cfg.Input.Receiver,
+		EphemerisOutput:  cfg.ephemerisOutput(),
+		RTCMOutputPort:   cfg.Input.RTCMOutputPort,
+	}
+}
+
+// ephemerisOutput reports whether the receiver on the input is configured to output the GPS
+// subframes the sky view DOP, the base monitor and the ephemeris relay need, which u-blox receivers only output with
+// their raw measurements.
+func (cfg *Config) ephemerisOutput() bool {
+	profile, err := rtkutils.Receiver(cfg.Input.Receiver)
+	return err == nil && profile.RawOutput && profile.BasePackets == nil
+}
+
+// ubxReceiver reports whether the receiver on the input is configured with UBX.
+func (cfg *Config) ubxReceiver() bool {
+	profile, err := rtkutils.Receiver(cfg.Input.Receiver)
+	return err == nil && profile.BasePackets == nil
+}
+
+// effective returns a copy of the config with the defaults the station runs with filled in.
+func (cfg *Config) effective() Config {
+	conf := *cfg
+	input := &conf.Input
+	if cfg.hasReceiver() {
+		if profile, err := rtkutils.Receiver(input.Receiver); err == nil {
+			input.Receiver = profile.Name
+			if input.RTCMOutputPort == "" && profile.BasePackets == nil {
+				input.RTCMOutputPort = "uart2"
+				if input.Transport == TransportI2C {
+					input.RTCMOutputPort = TransportI2C
+				}
+			}
+		}
+		if input.ReadBufferSize == 0 {
+			input.ReadBufferSize = rtkutils.DefaultReadBufferSize
+		}
+	}
+	switch input.Transport {
+	case TransportSerial:
+		input.SerialBaudRate = baudRate(input.SerialBaudRate)
+	case TransportI2C:
+		input.I2CBaudRate = baudRate(input.I2CBaudRate)
+		if conf.PollIntervalMs == 0 {
+			conf.PollIntervalMs = int(rtkutils.DefaultPollInterval.Milliseconds())
+		}
+	}
+	conf.Outputs = make([]OutputConfig, len(cfg.Outputs))
+	for i, output := range cfg.Outputs {
+		if output.Transport == TransportSerial {
+			output.SerialBaudRate = baudRate(output.SerialBaudRate)
+		}
+		conf.Outputs[i] = output
+	}
+	if conf.RecordDir != "" {
+		if conf.RecordRotateMinutes == 0 {
+			conf.RecordRotateMinutes = rtkutils.DefaultRTCMRotateInterval.Minutes()
+		}
+		if conf.RecordKeepFiles == 0 {
+			conf.RecordKeepFiles = rtkutils.DefaultRTCMKeepFiles
+		}
+	}
+	return conf
+}
+
+func (input InputConfig) ntripConfig() rtkutils.NtripConfig {
+	return rtkutils.NtripConfig{
+		URL:        input.NtripURL,
+		Mountpoint: input.NtripMountpoint,
+		Username:   input.NtripUsername,
+		Password:   input.NtripPassword,
+		TLS:        input.tlsConfig(),
+	}
+}
+
+func (input InputConfig) tlsConfig() rtkutils.TLSConfig {
+	return rtkutils.TLSConfig{
+		Enabled:  input.TLS,
+		CAFile:   input.TLSCAFile,
+		CertFile: input.TLSCertFile,
+		KeyFile:  input.TLSKeyFile,
+	}
+}
+
+func (output OutputConfig) clientACL() rtkutils.ClientACL {
+	return rtkutils.ClientACL{Allowed: output.AllowedClients, MaxPerClient: output.MaxClientConnections}
+}
+
+func (output OutputConfig) tlsConfig() rtkutils.TLSConfig {
+	return rtkutils.TLSConfig{
+		Enabled:  output.TLS,
+		CAFile:   output.TLSCAFile,
+		CertFile: output.TLSCertFile,
+		KeyFile:  output.TLSKeyFile,
+	}
+}
+
+// baudRate returns the baud rate of a serial port, 38400 if it isn't set.
+func baudRate(baud int) int {
+	if baud == 0 {
+		return defaultBaudRate
+	}
+	return baud
+}
+
+type correctionStation struct {
+	resource.Named
+	resource.AlwaysRebuild
+	logger golog.Logger
+
+	cancelCtx               context.Context
+	cancelFunc              func()
+	closed                  rtkutils.CloseOnce
+	activeBackgroundWorkers sync.WaitGroup
+
+	conf       *Config
+	rtcmFrames rtkutils.Counter // valid frames read from the input
+
+	input   io.ReadCloser // the open input, nil while it is being reopened
+	inputMu sync.Mutex
+	// how full the reads of a serial or i2c input get, nil for other inputs
+	inputBuffer *rtkutils.ReadBufferUsage
+	ntrip       *rtkutils.NtripReader // the input if it is an ntrip caster, kept for the self test
+	outputs     []*output             // only used by the rtcm reader worker until it stops, but for their servers
+	heartbeats  []*output             // only used by the heartbeat worker until it stops
+	bytesSent   rtkutils.Counter      // bytes of corrections forwarded to an output
+
+	// the receiver wasn't there when the station was built, or lost its configuration, it is
+	// configured once it is there
+	waitForReceiver bool
+	// frames read before the receiver was last configured, one that sent frames since and sends
+	// nmea instead lost its configuration
+	configuredFrames uint64
+	configResets     rtkutils.Counter
+
+	corrections rtkutils.RTCMBuffer    // recent frames served to remote rovers
+	rtcmFiles   *rtkutils.RTCMFileSink // recording of the rtcm stream, if enabled
+	vrs         *rtkutils.VirtualBase  // re-references forwarded corrections, if enabled
+	monitor     *rtkutils.BaseMonitor  // checks the base stays where it was surveyed, if enabled
+	skyView     *rtkutils.SkyView
+	glonass     rtkutils.GLONASSBiasCheck   // warns of GLONASS MSM read without code-phase biases
+	stations    rtkutils.RTCMStationHistory // reference stations of the input over time
+	ephemerides *rtkutils.EphemerisRelay    // forwards the ephemerides of the receiver, if enabled
+	decoder     *rtkutils.StreamDecoder     // passes the messages of the input to the monitor, sky view and relay
+	inputCheck  *rtkutils.InputCheck        // recognizes an input sending nmea or ubx instead of rtcm
+	events      *rtkutils.EventLog
+
+	schedule      *rtkutils.Schedule      // when corrections are forwarded, nil for always
+	brownout      *rtkutils.BrownoutGuard // nil without a voltage sensor
+	voltageSensor sensor.Sensor
+	gnssMu        sync.Mutex
+	gnssStopped   bool // the GNSS of the receiver on the input is stopped to save power
+
+	err      *rtkutils.ErrorHistory
+	recovery *rtkutils.Recovery
+	workers  *rtkutils.Supervisor
+}
+
+func newCorrectionStation(
+	ctx context.Context,
+	deps resource.Dependencies,
+	name resource.Name,
+	newConf *Config,
+	logger golog.Logger,
+) (sensor.Sensor, error) {
+	cancelCtx, cancelFunc := context.WithCancel(rtkutils.ModuleContext())
+
+	r := &correctionStation{
+		Named:      name.AsNamed(),
+		cancelCtx:  cancelCtx,
+		cancelFunc: cancelFunc,
+		logger:     logger,
+		conf:       newConf,
+		err:        rtkutils.NewErrorHistory(),
+		events:     rtkutils.NewEventLog(logger),
+	}
+	r.recovery = rtkutils.NewRecovery(recoveryPolicy(newConf.Input.Transport), r.err, logger)
+	r.workers = rtkutils.NewSupervisor(cancelCtx, &r.activeBackgroundWorkers, logger)
+
+	if err := r.configureReceiver(); rtkutils.IsDeviceAbsent(err) {
+		r.logger.Warnf("receiver isn't there yet, waiting for it: %s", err)
+		r.waitForReceiver = true
+	} else if err != nil {
+		r.logger.Warnf("rtk base station could not be configured: %s", err)
+	}
+
+	var err error
+	r.vrs = rtkutils.NewVirtualBase(newConf.VirtualBaseLat, newConf.VirtualBaseLng, newConf.VirtualBaseAlt)
+	r.monitor = rtkutils.NewBaseMonitor(newConf.BaseMoveThreshold, r.events)
+	r.skyView = rtkutils.NewSkyView()
+	r.ephemerides = rtkutils.NewEphemerisRelay(newConf.RelayEphemerides)
+	r.decoder = rtkutils.NewStreamDecoder(r.skyView.Update, r.monitor.Update, r.ephemerides.Update)
+	r.inputCheck = rtkutils.NewInputCheck(newConf.hasReceiver() && newConf.ephemerisOutput(), func(err error) {
+		if r.configLost(err) {
+			return
+		}
+		r.err.Record(inputClasses(newConf.Input.Transport).category, err)
+		r.logger.Warnf("no rtcm frames from the %s input: %s", newConf.Input.Transport, err)
+	})
+	r.rtcmFiles, err = rtkutils.NewRTCMFileSink(newConf.RecordDir,
+		time.Duration(newConf.RecordRotateMinutes*float64(time.Minute)), newConf.RecordKeepFiles)
+	if err != nil {
+		cancelFunc()
+		return nil, err
+	}
+	r.schedule, err = rtkutils.ParseSchedule(newConf.TransmitSchedule, newConf.ScheduleTimezone)
+	if err != nil {
+		cancelFunc()
+		return nil, err
+	}
+	if newConf.VoltageSensor != "" {
+		if r.voltageSensor, err = sensor.FromDependencies(deps, newConf.VoltageSensor); err != nil {
+			cancelFunc()
+			return nil, err
+		}
+		r.brownout = rtkutils.NewBrownoutGuard(newConf.MinVoltage, newConf.ResumeVoltage)
+	}
+	if newConf.hasReceiver() {
+		r.inputBuffer = rtkutils.NewReadBufferUsage(newConf.Input.ReadBufferSize)
+	}
+	if newConf.Input.Transport == TransportNtrip {
+		r.ntrip = rtkutils.NewNtripReader(cancelCtx, newConf.Input.ntripConfig(), rtkutils.DefaultNtripRetryInterval, logger)
+	}
+	for _, conf := range newConf.Outputs {
+		o := &output{
+			conf:    conf,
+			signer:  rtkutils.NewFrameSigner(conf.AuthKey),
+			framer:  rtkutils.NewRadioFramer(conf.RadioFraming),
+			mavlink: rtkutils.NewMAVLinkRTCM(conf.MAVLink),
+		}
+		if conf.Transport == TransportTCPServer {
+			o.server = rtkutils.NewCorrectionServer(conf.Addr, conf.clientACL(), logger)
+		}
+		if conf.Heartbeat {
+			r.heartbeats = append(r.heartbeats, o)
+			continue
+		}
+		r.outputs = append(r.outputs, o)
+	}
+
+	r.logger.Debugf("Starting the station, reading corrections over %s", newConf.Input.Transport)
+	r.start()
+	rtkutils.TrackResource(r)
+
+	return r, r.err.Get()
+}
+
+// configureReceiver configures a receiver on the input to survey its position and output
+// corrections. Other inputs need no configuration.
+func (r *correctionStation) configureReceiver() error {
+	switch r.conf.Input.Transport {
+	case TransportSerial:
+		r.logger.Debug("configuring the base station")
+		return stationserial.ConfigureBaseRTKStation(r.conf.serialStation())
+	case TransportI2C:
+		r.logger.Debug("configuring the base station")
+		return stationi2c.ConfigureBaseRTKStation(r.conf.i2cStation())
+	default:
+		return nil
+	}
+}
+
+// configLost reports whether the mismatch err of the input is a receiver that sent corrections since
+// it was configured and sends nmea instead, as it does once a brownout wipes its configuration. The
+// input is then closed so that the rtcm reader configures it again. It is called by the rtcm reader.
+func (r *correctionStation) configLost(err error) bool {
+	if !errors.Is(err, rtkutils.ErrInputNMEA) || !r.conf.hasReceiver() || r.rtcmFrames.Get() <= r.configuredFrames {
+		return false
+	}
+	r.configResets.Add(1)
+	r.events.Add(rtkutils.EventReceiverConfigReset,
+		"the receiver on the %s input sends nmea instead of rtcm, configuring it again", r.conf.Input.Transport)
+	r.waitForReceiver = true
+	r.closeInput()
+	return true
+}
+
+// start starts reading corrections from the input and forwarding them to the outputs.
+func (r *correctionStation) start() {
+	r.workers.Go("rtcm reader", func() error {
+		classes := inputClasses(r.conf.Input.Transport)
+		var frames *rtkutils.FrameReader
+
+		for {
+			if r.cancelCtx.Err() != nil {
+				return nil
+			}
+			if r.waitForReceiver {
+				err := r.configureReceiver()
+				if rtkutils.IsDeviceAbsent(err) {
+					if r.recovery.Handle(r.cancelCtx, classes.open, err) == rtkutils.Rebuild {
+						return rtkutils.ErrRebuildRequired
+					}
+					continue
+				}
+				if err != nil {
+					r.logger.Warnf("rtk base station could not be configured: %s", err)
+				}
+				r.waitForReceiver = false
+				r.configuredFrames = r.rtcmFrames.Get()
+			}
+			input, err := r.openInput()
+			if err != nil {
+				if r.cancelCtx.Err() != nil {
+					return nil
+				}
+				if r.recovery.Handle(r.cancelCtx, classes.open, err) == rtkutils.Rebuild {
+					return rtkutils.ErrRebuildRequired
+				}
+				continue
+			}
+			r.recovery.Succeeded(classes.open)
+			if !r.setInput(input) {
+				// the station closed while the input was opening
+				return nil
+			}
+
+			// the decoder and the input check see the whole stream, including the ubx subframes of
+			// the receiver that aren't forwarded
+			stream := io.TeeReader(input, io.MultiWriter(r.decoder, r.inputCheck))
+			if frames == nil {
+				frames = rtkutils.NewFrameReader(stream)
+			} else {
+				frames.Reset(stream)
+			}
+			err = r.forward(frames, classes.read)
+			r.closeInput()
+			if r.cancelCtx.Err() != nil {
+				// the input was closed on shutdown
+				return nil
+			}
+			if r.waitForReceiver {
+				// the input was closed to configure the receiver again
+				continue
+			}
+			if r.recovery.Handle(r.cancelCtx, classes.read, err) == rtkutils.Rebuild {
+				return rtkutils.ErrRebuildRequired
+			}
+		}
+	})
+	if len(r.heartbeats) > 0 {
+		r.workers.Go("heartbeat", r.sendHeartbeats)
+	}
+	if r.schedule != nil {
+		r.workers.Go("schedule", r.followSchedule)
+	}
+	if r.voltageSensor != nil {
+		r.workers.Go("supply", r.watchSupply)
+	}
+}
+
+// transmitting reports whether the station forwards corrections at now.
+func (r *correctionStation) transmitting(now time.Time) bool {
+	return r.schedule.Active(now) && !r.brownout.Low()
+}
+
+// followSchedule raises an event whenever the transmit schedule starts or stops, and with low power
+// starts and stops the GNSS of the receiver with it, until the station closes.
+func (r *correctionStation) followSchedule() error {
+	active := r.schedule.Active(time.Now())
+	if r.conf.ScheduleLowPower {
+		// the receiver may have been stopped before the station was reconfigured
+		r.updateGNSS(time.Now(), true)
+	}
+
+	ticker := time.NewTicker(scheduleCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.cancelCtx.Done():
+			return nil
+		case now := <-ticker.C:
+			if r.schedule.Active(now) == active {
+				continue
+			}
+			active = !active
+			if active {
+				r.events.Add(rtkutils.EventScheduleStarted, "transmitting corrections")
+			} else if next, ok := r.schedule.NextChange(now); ok {
+				r.events.Add(rtkutils.EventScheduleStopped, "stopped transmitting corrections until %s", next.Format(time.RFC3339))
+			} else {
+				r.events.Add(rtkutils.EventScheduleStopped, "stopped transmitting corrections")
+			}
+			r.updateGNSS(now, false)
+		}
+	}
+}
+
+// watchSupply reads the voltage sensor every rtkutils.BrownoutCheckInterval until the
+// station closes. When the supply browns out the station stops transmitting and saves its state.
+func (r *correctionStation) watchSupply() error {
+	ticker := time.NewTicker(rtkutils.BrownoutCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.cancelCtx.Done():
+			return nil
+		case now := <-ticker.C:
+			readings, err := r.voltageSensor.Readings(r.cancelCtx, nil)
+			var volts float64
+			if err == nil {
+				volts, err = rtkutils.SupplyVoltage(readings)
+			}
+			if err != nil {
+				r.logger.Warnf("failed to read the voltage of %s: %s", r.conf.VoltageSensor, err)
+				continue
+			}
+			if !r.brownout.Update(volts, now, r.events) {
+				continue
+			}
+			if r.brownout.Low() {
+				r.saveState()
+			}
+			r.updateGNSS(now, false)
+		}
+	}
+}
+
+// saveState keeps what the station would lose if its supply browns out: the configuration of a
+// u-blox receiver on the input is saved to its non-volatile memory, and the recording to disk.
+func (r *correctionStation) saveState() {
+	if r.conf.hasReceiver() && r.conf.ubxReceiver() {
+		if err := r.writeToReceiver(rtkutils.UBXSaveConfigPacket()); err != nil {
+			r.logger.Warnf("failed to save the configuration of the receiver: %s", err)
+		}
+	}
+	if err := r.rtcmFiles.Close(); err != nil {
+		r.logger.Warnf("failed to close the rtcm recording: %s", err)
+	}
+}
+
+// updateGNSS stops the GNSS of a u-blox receiver on the input to save power while the station doesn't
+// transmit, outside of a low power schedule or while browned out, and starts it again once it does.
+// With force it writes to the receiver even if the GNSS should already be as it needs to be.
+func (r *correctionStation) updateGNSS(now time.Time, force bool) {
+	if !r.conf.hasReceiver() || !r.conf.ubxReceiver() {
+		return
+	}
+	stop := r.brownout.Low() || (r.conf.ScheduleLowPower && !r.schedule.Active(now))
+	r.gnssMu.Lock()
+	defer r.gnssMu.Unlock()
+	if stop == r.gnssStopped && !force {
+		return
+	}
+	packet, what := rtkutils.UBXGNSSStartPacket(), "start"
+	if stop {
+		packet, what = rtkutils.UBXGNSSStopPacket(), "stop"
+	}
+	if err := r.writeToReceiver(packet); err != nil {
+		r.logger.Warnf("failed to %s the gnss of the receiver: %s", what, err)
+		return
+	}
+	r.gnssStopped = stop
+}
+
+// sendHeartbeats sends the status line of the station to the heartbeat outputs every
+// rtkutils.HeartbeatInterval until the station closes.
+func (r *correctionStation) sendHeartbeats() error {
+	ticker := time.NewTicker(rtkutils.HeartbeatInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.cancelCtx.Done():
+			return nil
+		case now := <-ticker.C:
+			sentence, err := rtkutils.HeartbeatSentence(now, r.state(now), r.bytesSent.Get())
+			if err != nil {
+				return err
+			}
+			for _, o := range r.heartbeats {
+				if err := o.send(sentence, now); err != nil {
+					r.err.Record(o.category(), err)
+					r.logger.Debugf("failed to send the heartbeat to %s output: %s", o.conf.Transport, err)
+				}
+			}
+		}
+	}
+}
+
+// forward reads frames from the input and forwards them until a read fails.
+func (r *correctionStation) forward(frames *rtkutils.FrameReader, class rtkutils.ErrorClass) error {
+	for {
+		frame, err := frames.Next()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				err = fmt.Errorf("%s input closed the stream", r.conf.Input.Transport)
+			}
+			return err
+		}
+		r.recovery.Succeeded(class)
+		r.rtcmFrames.Add(1)
+		r.inputCheck.Frame()
+
+		now := time.Now()
+		r.glonass.Frame(frame, now, r.events)
+		r.stations.Frame(frame, now, r.events)
+		if !r.transmitting(now) {
+			continue
+		}
+		r.send(frame, now)
+		// the ephemerides decoded from the frames read so far follow them
+		for _, eph := range r.ephemerides.Due(now) {
+			r.send(eph, now)
+		}
+	}
+}
+
+// send forwards a frame to the outputs, the remote rovers and the recording.
+func (r *correctionStation) send(frame []byte, now time.Time) {
+	frame, ok := r.vrs.TransformFrame(frame)
+	if !ok {
+		return
+	}
+	// the buffer copies the frame, which FrameReader reuses for the next one
+	r.corrections.Add(frame)
+	sent := false
+	for _, o := range r.outputs {
+		if err := o.send(frame, now); err != nil {
+			r.err.Record(o.category(), err)
+			r.logger.Debugf("failed to forward rtcm frame to %s output: %s", o.conf.Transport, err)
+		} else if o.w != nil {
+			sent = true
+		}
+	}
+	if sent {
+		r.bytesSent.Add(uint64(len(frame)))
+	}
+	if _, err := r.rtcmFiles.Write(frame); err != nil {
+		r.logger.Warnf("failed to record rtcm frame: %s", err)
+	}
+}
+
+// setInput makes input the one corrections are read from, unless the station is closing, in
+// which case input is closed and setInput returns false.
+func (r *correctionStation) setInput(input io.ReadCloser) bool {
+	r.inputMu.Lock()
+	defer r.inputMu.Unlock()
+	if r.cancelCtx.Err() != nil {
+		if err := input.Close(); err != nil {
+			r.logger.Errorf("failed to close the %s input: %s", r.conf.Input.Transport, err)
+		}
+		return false
+	}
+	r.input = input
+	return true
+}
+
+// closeInput closes the input, so that a read blocked on it returns.
+func (r *correctionStation) closeInput() error {
+	r.inputMu.Lock()
+	defer r.inputMu.Unlock()
+	if r.input == nil {
+		return nil
+	}
+	err := r.input.Close()
+	r.input = nil
+	if err != nil {
+		r.err.Record(inputClasses(r.conf.Input.Transport).category, err)
+	}
+	return err
+}
+
+// inputOpen reports whether the input is open.
+func (r *correctionStation) inputOpen() bool {
+	r.inputMu.Lock()
+	defer r.inputMu.Unlock()
+	return r.input != nil
+}
+
+// DoCommand runs the commands supported by the station.
+func (r *correctionStation) DoCommand(ctx context.Context, cmd map[string]interface{}) (map[string]interface{}, error) {
+	switch cmd[rtkutils.CommandKey] {
+	case rtkutils.SelfTestCommand:
+		return r.selfTest(ctx, rtkutils.SelfTestTimeout(cmd)), nil
+	case rtkutils.CheckConfigCommand:
+		return r.checkConfig(ctx, cmd)
+	case rtkutils.ProvisionCommand:
+		switch r.conf.Input.Transport {
+		case TransportSerial:
+			return stationserial.Provision(ctx, r.conf.serialStation(), cmd)
+		case TransportI2C:
+			return stationi2c.Provision(ctx, r.conf.i2cStation(), cmd)
+		default:
+			return nil, fmt.Errorf("provision needs a receiver connected over %s or %s, not %s",
+				TransportSerial, TransportI2C, r.conf.Input.Transport)
+		}
+	case rtkutils.ReadRTCMCommand:
+		return r.corrections.ReadRTCM(cmd), nil
+	case rtkutils.DecodeRTCMCommand:
+		return rtkutils.DecodeRTCMResult(&r.corrections, cmd), nil
+	case rtkutils.RTCMStationsCommand:
+		return r.stations.StationsResult(time.Now()), nil
+	case rtkutils.EventsCommand:
+		return r.events.EventsResult(cmd), nil
+	case rtkutils.ClientsCommand:
+		return r.clientsResult(), nil
+	case rtkutils.ErrorsCommand:
+		return r.err.ErrorsResult(cmd), nil
+	case rtkutils.VersionCommand:
+		return rtkutils.VersionResult(), nil
+	case rtkutils.EffectiveConfigCommand:
+		return rtkutils.RedactConfig(r.conf.effective())
+	default:
+		return nil, resource.ErrDoUnimplemented
+	}
+}
+
+// clientsResult answers a ClientsCommand with the clients of every tcp_server output.
+func (r *correctionStation) clientsResult() map[string]interface{} {
+	clients := []interface{}{}
+	for _, o := range r.outputs {
+		if o.server == nil {
+			continue
+		}
+		for _, c := range o.server.Clients() {
+			client := map[string]interface{}{
+				"output":               o.conf.Addr,
+				"ip":                   c.IP,
+				"connections":          c.Connections,
+				"bytes_sent":           c.BytesSent,
+				"rejected_connections": c.Rejected,
+			}
+			if !c.LastConnected.IsZero() {
+				client["last_connected"] = c.LastConnected.Format(time.RFC3339Nano)
+			}
+			if !c.Rover.Received.IsZero() {
+				client["rover"] = c.Rover.Reading(c.IP, time.Now())
+			}
+			clients = append(clients, client)
+		}
+	}
+	return map[string]interface{}{"clients": clients}
+}
+
+// roverReadings returns the last report of every client of a tcp_server output that sent its GGA back.
+func (r *correctionStation) roverReadings(now time.Time) []interface{} {
+	var rovers []interface{}
+	for _, o := range r.outputs {
+		if o.server == nil {
+			continue
+		}
+		for _, c := range o.server.Clients() {
+			if !c.Rover.Received.IsZero() {
+				rovers = append(rovers, c.Rover.Reading(c.IP, now))
+			}
+		}
+	}
+	return rovers
+}
+
+// selfTest checks that the input is open and that rtcm frames are read from it.
+func (r *correctionStation) selfTest(ctx context.Context, timeout time.Duration) map[string]interface{} {
+	report := rtkutils.NewReport()
+
+	if r.ntrip != nil {
+		report.Check("ntrip_stream", rtkutils.WaitFor(ctx, timeout, "ntrip stream", func() bool { return r.ntrip.Err() == nil }))
+	} else {
+		report.Check("input_open", rtkutils.WaitFor(ctx, timeout, r.conf.Input.Transport+" input", r.inputOpen))
+	}
+	err := rtkutils.WaitForIncrease(ctx, timeout, "rtcm frames", r.rtcmFrames.Get)
+	if inputErr := r.inputCheck.Err(); err != nil && inputErr != nil {
+		// say why there are none
+		err = inputErr
+	}
+	report.Check("rtcm_frames", err)
+
+	return report.Result()
+}
+
+// checkConfig checks a proposed config against the hardware without applying it: it is validated
+// with its device checks, and a receiver input has to acknowledge on i2c or send something on its
+// serial port. The input the station has open is checked by the frames it reads instead.
+func (r *correctionStation) checkConfig(ctx context.Context, cmd map[string]interface{}) (map[string]interface{}, error) {
+	attrs, err := rtkutils.ConfigArg(cmd)
+	if err != nil {
+		return nil, err
+	}
+	timeout := rtkutils.SelfTestTimeout(cmd)
+
+	report := rtkutils.NewReport()
+	conf, err := resource.TransformAttributeMap[*Config](attrs)
+	report.Check("decode_config", err)
+	if err != nil {
+		return report.Result(), nil
+	}
+	conf.SkipDeviceCheck = false
+	_, err = conf.Validate("config")
+	report.Check("validate_config", err)
+	if err != nil {
+		return report.Result(), nil
+	}
+
+	ports := map[string]interface{}{}
+	input := conf.Input
+	switch {
+	case input.Transport == TransportI2C:
+		report.Check("input_i2c_addr_ack", rtkutils.CheckI2CAck(byte(input.I2CAddr), input.I2CBus))
+	case input.Transport != TransportSerial:
+		// the network inputs have no hardware to check
+	case r.conf.Input.Transport == TransportSerial && rtkutils.SameDevice(input.SerialPath, r.conf.Input.SerialPath):
+		report.Check("rtcm_frames", rtkutils.WaitForIncrease(ctx, timeout, "rtcm frames", r.rtcmFrames.Get))
+	default:
+		port, err := openSerial(input.SerialPath, input.SerialBaudRate)
+		report.Check("input_port_open", err)
+		if err != nil {
+			break
+		}
+		output, err := rtkutils.ListenToPort(ctx, port, timeout)
+		if err == nil && output.Sentences+output.UBX+output.RTCM == 0 {
+			// a receiver sends nmea until the station configures it to send rtcm
+			err = fmt.Errorf("nothing from %s within %s, check its baud rate", input.SerialPath, timeout)
+		}
+		report.Check("receiver_output", err)
+		ports[input.SerialPath] = output.Result()
+		report.Check("receiver_model", output.MatchReceiver(input.Receiver))
+	}
+
+	result := report.Result()
+	result["ports"] = ports
+	return result, nil
+}
+
+// Close shuts down the station.
+func (r *correctionStation) Close(ctx context.Context) error {
+	return r.closed.Close(func() error { return r.close(ctx) })
+}
+
+func (r *correctionStation) close(ctx context.Context) error {
+	rtkutils.UntrackResource(r)
+	r.cancelFunc()
+
+	// close the input first so a read blocked on it can return
+	if err := r.closeInput(); err != nil {
+		r.logger.Errorf("failed to close the %s input: %s", r.conf.Input.Transport, err)
+	}
+	if !rtkutils.WaitWithTimeout(ctx, &r.activeBackgroundWorkers, rtkutils.DefaultShutdownTimeout) {
+		r.logger.Warn("timed out waiting for background workers to stop")
+	}
+	for _, o := range append(r.outputs, r.heartbeats...) {
+		if err := o.close(); err != nil {
+			r.logger.Errorf("failed to close the %s output: %s", o.conf.Transport, err)
+		}
+	}
+
+	if err := r.rtcmFiles.Close(); err != nil {
+		r.logger.Errorf("failed to close the rtcm recording: %s", err)
+	}
+
+	if err := r.err.Get(); err != nil && !errors.Is(err, context.Canceled) {
+		return err
+	}
+	return nil
+}
+
+// state returns the state of the station.
+func (r *correctionStation) state(now time.Time) string {
+	state := rtkutils.DeviceState(rtkutils.StationState(r.err, &r.rtcmFrames, r.conf.hasReceiver(), now), r.recovery)
+	return r.brownout.State(r.schedule.State(state, now))
+}
+
+// Readings returns the state of the station, how many corrections it read and how many bytes of them
+// it sent, the sky view of its base, whether the base moved, whether its GLONASS observations come
+// with code-phase biases, the reference stations they come from, how many ephemerides it relayed,
+// how full the reads of its receiver get, where the rovers served over tcp last reported they are and
+// whether it transmits on its schedule and the voltage of its supply.
+func (r *correctionStation) Readings(ctx context.Context, extra map[string]interface{}) (map[string]interface{}, error) {
+	now := time.Now()
+	readings := r.skyView.Readings(now)
+	readings[rtkutils.StateKey] = r.state(now)
+	readings["rtcm_frames"] = r.rtcmFrames.Get()
+	readings["rtcm_bytes_sent"] = r.bytesSent.Get()
+	readings[rtkutils.RestartsKey] = r.workers.Restarts()
+	if r.conf.hasReceiver() {
+		readings["receiver_config_resets"] = r.configResets.Get()
+	}
+	if r.monitor != nil {
+		readings["base_moved"] = r.monitor.Moved()
+		if offset, ok := r.monitor.Offset(); ok {
+			readings["base_offset_m"] = offset
+		}
+	}
+	for name, value := range r.glonass.Readings(now) {
+		readings[name] = value
+	}
+	for name, value := range r.stations.Readings(now) {
+		readings[name] = value
+	}
+	for _, o := range r.outputs {
+		if o.mavlink != nil {
+			readings["mavlink_rtcm_frames_too_long"] = o.mavlink.TooLong()
+		}
+	}
+	if r.ephemerides != nil {
+		readings["ephemerides_relayed"] = r.ephemerides.Relayed()
+	}
+	if r.inputBuffer != nil {
+		for name, value := range r.inputBuffer.Readings("input") {
+			readings[name] = value
+		}
+	}
+	if rovers := r.roverReadings(now); len(rovers) > 0 {
+		readings["rovers"] = rovers
+	}
+	for name, value := range r.schedule.Readings(now) {
+		readings[name] = value
+	}
+	for name, value := range r.brownout.Readings() {
+		readings[name] = value
+	}
+	if r.schedule != nil || r.brownout != nil {
+		readings["transmitting"] = r.transmitting(now)
+	}
+	return readings, nil
+}
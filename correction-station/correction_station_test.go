@@ -0,0 +1,717 @@
+package correctionstation
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/edaniels/golog"
+	"github.com/go-gnss/rtcm/rtcm3"
+	"go.viam.com/rdk/components/sensor"
+	"go.viam.com/rdk/resource"
+	"go.viam.com/test"
+	"go.viam.com/utils"
+
+	rtkutils "rtksystem/rtk-utils"
+)
+
+const (
+	testPath        = "test-path"
+	path            = "path"
+	testStationName = "station"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name        string
+		config      *Config
+		expectedErr error
+	}{
+		{
+			name: "A serial receiver with a radio output should result in no errors",
+			config: &Config{
+				RequiredAccuracy: 4,
+				RequiredTime:     200,
+				Input:            InputConfig{Transport: TransportSerial, SerialPath: testPath},
+				Outputs:          []OutputConfig{{Transport: TransportSerial, SerialPath: "radio-path"}},
+				SkipDeviceCheck:  true,
+			},
+		},
+		{
+			name: "An i2c receiver needs no outputs",
+			config: &Config{
+				RequiredAccuracy: 4,
+				RequiredTime:     200,
+				Input:            InputConfig{Transport: TransportI2C, I2CBus: 1, I2CAddr: 0x42},
+				SkipDeviceCheck:  true,
+			},
+		},
+		{
+			name: "A legacy m8p receiver can be a station",
+			config: &Config{
+				RequiredAccuracy: 4,
+				RequiredTime:     200,
+				Input:            InputConfig{Transport: TransportI2C, I2CBus: 1, I2CAddr: 0x42, Receiver: rtkutils.ReceiverNEOM8P},
+				SkipDeviceCheck:  true,
+			},
+		},
+		{
+			name: "A SkyTraq receiver can be a station",
+			config: &Config{
+				RequiredAccuracy: 4,
+				RequiredTime:     200,
+				Input:            InputConfig{Transport: TransportSerial, SerialPath: testPath, Receiver: rtkutils.ReceiverPX1122R},
+				SkipDeviceCheck:  true,
+			},
+		},
+		{
+			name: "A timing receiver can't be a station",
+			config: &Config{
+				RequiredAccuracy: 4,
+				RequiredTime:     200,
+				Input:            InputConfig{Transport: TransportI2C, I2CBus: 1, I2CAddr: 0x42, Receiver: rtkutils.ReceiverNEOM8T},
+				SkipDeviceCheck:  true,
+			},
+			expectedErr: errors.New("path: input.receiver \"neo-m8t\" can't output rtcm corrections for a station"),
+		},
+		{
+			name: "A u-blox F9 receiver can check that the base doesn't move",
+			config: &Config{
+				RequiredAccuracy:  4,
+				RequiredTime:      200,
+				Input:             InputConfig{Transport: TransportSerial, SerialPath: testPath},
+				BaseMoveThreshold: 5,
+				SkipDeviceCheck:   true,
+			},
+		},
+		{
+			name: "A receiver that doesn't output ephemerides can't check that the base doesn't move",
+			config: &Config{
+				RequiredAccuracy:  4,
+				RequiredTime:      200,
+				Input:             InputConfig{Transport: TransportSerial, SerialPath: testPath, Receiver: rtkutils.ReceiverPX1122R},
+				BaseMoveThreshold: 5,
+				SkipDeviceCheck:   true,
+			},
+			expectedErr: errors.New("path: input.receiver \"px1122r\" doesn't output the ephemerides base_move_threshold_m needs"),
+		},
+		{
+			name: "A negative base move threshold should error",
+			config: &Config{
+				Input:             InputConfig{Transport: TransportTCP, Addr: "localhost:2101"},
+				Outputs:           []OutputConfig{{Transport: TransportUDP, Addr: "localhost:2102"}},
+				BaseMoveThreshold: -1,
+			},
+			expectedErr: errors.New("path: base_move_threshold_m must not be negative"),
+		},
+		{
+			name: "A u-blox F9 receiver can relay its ephemerides",
+			config: &Config{
+				RequiredAccuracy: 4,
+				RequiredTime:     200,
+				Input:            InputConfig{Transport: TransportI2C, I2CBus: 1, I2CAddr: 0x42},
+				RelayEphemerides: true,
+				SkipDeviceCheck:  true,
+			},
+		},
+		{
+			name: "A tcp input can't relay ephemerides",
+			config: &Config{
+				Input:            InputConfig{Transport: TransportTCP, Addr: "localhost:2101"},
+				Outputs:          []OutputConfig{{Transport: TransportUDP, Addr: "localhost:2102"}},
+				RelayEphemerides: true,
+			},
+			expectedErr: errors.New("path: relay_ephemerides needs a u-blox receiver that outputs raw measurements on the input, " +
+				"other inputs forward the ephemerides of their stream as they are"),
+		},
+		{
+			name: "A schedule needs five fields",
+			config: &Config{
+				Input:            InputConfig{Transport: TransportTCP, Addr: "localhost:2101"},
+				Outputs:          []OutputConfig{{Transport: TransportUDP, Addr: "localhost:2102"}},
+				TransmitSchedule: "7-18 * *",
+			},
+			expectedErr: errors.New("path: transmit_schedule \"7-18 * *\" must have 5 fields: " +
+				"minute, hour, day of month, month and day of week"),
+		},
+		{
+			name: "A tcp input can't stop its gnss outside of the schedule",
+			config: &Config{
+				Input:            InputConfig{Transport: TransportTCP, Addr: "localhost:2101"},
+				Outputs:          []OutputConfig{{Transport: TransportUDP, Addr: "localhost:2102"}},
+				TransmitSchedule: "* 7-18 * * *",
+				ScheduleLowPower: true,
+			},
+			expectedErr: errors.New("path: schedule_low_power needs a u-blox receiver on a serial or i2c input"),
+		},
+		{
+			name: "A voltage sensor needs a min voltage",
+			config: &Config{
+				Input:         InputConfig{Transport: TransportTCP, Addr: "localhost:2101"},
+				Outputs:       []OutputConfig{{Transport: TransportUDP, Addr: "localhost:2102"}},
+				VoltageSensor: "battery",
+			},
+			expectedErr: errors.New("path: voltage_sensor needs a min_voltage"),
+		},
+		{
+			name: "A tcp input has no read buffer size",
+			config: &Config{
+				Input:   InputConfig{Transport: TransportTCP, Addr: "localhost:2101", ReadBufferSize: 4096},
+				Outputs: []OutputConfig{{Transport: TransportUDP, Addr: "localhost:2102"}},
+			},
+			expectedErr: errors.New("path: input.read_buffer_size is only supported over serial and i2c"),
+		},
+		{
+			name: "A receiver on a carrier board can output rtcm on uart1",
+			config: &Config{
+				RequiredAccuracy: 4,
+				RequiredTime:     200,
+				Input:            InputConfig{Transport: TransportSerial, SerialPath: testPath, RTCMOutputPort: "uart1"},
+				SkipDeviceCheck:  true,
+			},
+		},
+		{
+			name: "An unknown rtcm output port should error",
+			config: &Config{
+				RequiredAccuracy: 4,
+				RequiredTime:     200,
+				Input:            InputConfig{Transport: TransportSerial, SerialPath: testPath, RTCMOutputPort: "spi"},
+				SkipDeviceCheck:  true,
+			},
+			expectedErr: errors.New("path: input.rtcm_output_port: unknown receiver port \"spi\", must be one of i2c, uart1, uart2 or usb"),
+		},
+		{
+			name: "A tcp input has no rtcm output port",
+			config: &Config{
+				Input:   InputConfig{Transport: TransportTCP, Addr: "localhost:2101", RTCMOutputPort: "uart2"},
+				Outputs: []OutputConfig{{Transport: TransportUDP, Addr: "localhost:2102"}},
+			},
+			expectedErr: errors.New("path: input.rtcm_output_port is only supported over serial and i2c"),
+		},
+		{
+			name: "An ntrip relay needs no receiver settings",
+			config: &Config{
+				Input: InputConfig{Transport: TransportNtrip, NtripURL: "http://caster.example.com:2101", NtripMountpoint: "MP"},
+				Outputs: []OutputConfig{
+					{Transport: TransportUDP, Addr: "192.168.1.255:2101"},
+					{Transport: TransportTCP, Addr: "localhost:2102"},
+				},
+			},
+		},
+		{
+			name:        "No input transport should error",
+			config:      &Config{},
+			expectedErr: utils.NewConfigValidationFieldRequiredError(path, "input.transport"),
+		},
+		{
+			name:        "An unknown input transport should error",
+			config:      &Config{Input: InputConfig{Transport: "bluetooth"}},
+			expectedErr: errors.New("path: input.transport \"bluetooth\" isn't supported, use serial, i2c, tcp or ntrip"),
+		},
+		{
+			name: "A receiver with no RequiredTime should error",
+			config: &Config{
+				RequiredAccuracy: 4,
+				Input:            InputConfig{Transport: TransportSerial, SerialPath: testPath},
+				SkipDeviceCheck:  true,
+			},
+			expectedErr: utils.NewConfigValidationFieldRequiredError(path, "required_time_sec"),
+		},
+		{
+			name: "A serial input that doesn't exist should error",
+			config: &Config{
+				RequiredAccuracy: 4,
+				RequiredTime:     200,
+				Input:            InputConfig{Transport: TransportSerial, SerialPath: testPath},
+			},
+			expectedErr: errors.New("path: input.serial_path \"test-path\" doesn't exist, check that the device is connected, or set skip_device_check if it is only connected later"),
+		},
+		{
+			name: "An i2c input with no address should error",
+			config: &Config{
+				RequiredAccuracy: 4,
+				RequiredTime:     200,
+				Input:            InputConfig{Transport: TransportI2C, I2CBus: 1},
+			},
+			expectedErr: utils.NewConfigValidationFieldRequiredError(path, "input.i2c_addr"),
+		},
+		{
+			name:        "A tcp input with no outputs should error",
+			config:      &Config{Input: InputConfig{Transport: TransportTCP, Addr: "localhost:2101"}},
+			expectedErr: utils.NewConfigValidationFieldRequiredError(path, "outputs"),
+		},
+		{
+			name: "A tcp input with only a heartbeat output should error",
+			config: &Config{
+				Input:   InputConfig{Transport: TransportTCP, Addr: "localhost:2101"},
+				Outputs: []OutputConfig{{Transport: TransportTCPServer, Addr: ":2103", Heartbeat: true}},
+			},
+			expectedErr: utils.NewConfigValidationFieldRequiredError(path, "outputs"),
+		},
+		{
+			name: "A heartbeat output can't sign the frames it doesn't send",
+			config: &Config{
+				Input: InputConfig{Transport: TransportTCP, Addr: "localhost:2101"},
+				Outputs: []OutputConfig{
+					{Transport: TransportUDP, Addr: "localhost:2102"},
+					{Transport: TransportUDP, Addr: "localhost:2103", Heartbeat: true, AuthKey: "0123456789abcdef"},
+				},
+			},
+			expectedErr: errors.New("path: outputs.1.auth_key isn't used with heartbeat, which sends no corrections"),
+		},
+		{
+			name: "Radio framing is only supported on a serial output",
+			config: &Config{
+				Input:   InputConfig{Transport: TransportTCP, Addr: "localhost:2101"},
+				Outputs: []OutputConfig{{Transport: TransportUDP, Addr: "localhost:2102", RadioFraming: true}},
+			},
+			expectedErr: errors.New("path: outputs.0.radio_framing is only supported over serial"),
+		},
+		{
+			name: "A MAVLink output only sends MAVLink",
+			config: &Config{
+				Input:   InputConfig{Transport: TransportTCP, Addr: "localhost:2101"},
+				Outputs: []OutputConfig{{Transport: TransportUDP, Addr: "localhost:2102", MAVLink: true, AuthKey: "0123456789abcdef"}},
+			},
+			expectedErr: errors.New("path: outputs.0.mavlink can't be used with heartbeat, auth_key or radio_framing, autopilots only read MAVLink"),
+		},
+		{
+			name: "An output that isn't a host:port should error",
+			config: &Config{
+				Input:   InputConfig{Transport: TransportTCP, Addr: "localhost:2101"},
+				Outputs: []OutputConfig{{Transport: TransportUDP, Addr: "localhost"}},
+			},
+			expectedErr: errors.New("path: outputs.0.addr \"localhost\" must be a host:port: address localhost: missing port in address"),
+		},
+		{
+			name: "An output to the input serial port should error",
+			config: &Config{
+				RequiredAccuracy: 4,
+				RequiredTime:     200,
+				Input:            InputConfig{Transport: TransportSerial, SerialPath: testPath},
+				Outputs:          []OutputConfig{{Transport: TransportSerial, SerialPath: testPath}},
+				SkipDeviceCheck:  true,
+			},
+			expectedErr: errors.New("path: outputs.0.serial_path is the input serial port, corrections can't be forwarded to where they are read from"),
+		},
+		{
+			name: "A udp output can't use tls",
+			config: &Config{
+				Input:   InputConfig{Transport: TransportTCP, Addr: "localhost:2101"},
+				Outputs: []OutputConfig{{Transport: TransportUDP, Addr: "localhost:2102", TLS: true}},
+			},
+			expectedErr: errors.New("path: outputs.0.tls is only supported over tcp"),
+		},
+		{
+			name: "A short auth key should error",
+			config: &Config{
+				Input:   InputConfig{Transport: TransportTCP, Addr: "localhost:2101"},
+				Outputs: []OutputConfig{{Transport: TransportUDP, Addr: "localhost:2102", AuthKey: "secret"}},
+			},
+			expectedErr: errors.New("path: outputs.0.auth_key must be at least 16 characters"),
+		},
+		{
+			name: "A tls client certificate needs its key",
+			config: &Config{
+				Input:   InputConfig{Transport: TransportTCP, Addr: "localhost:2101", TLS: true, TLSCertFile: "client.pem"},
+				Outputs: []OutputConfig{{Transport: TransportUDP, Addr: "localhost:2102"}},
+			},
+			expectedErr: errors.New("path: input.tls_cert_file and input.tls_key_file must be set together"),
+		},
+		{
+			name: "A tcp server output with a client that isn't an IP address should error",
+			config: &Config{
+				Input:   InputConfig{Transport: TransportTCP, Addr: "localhost:2101"},
+				Outputs: []OutputConfig{{Transport: TransportTCPServer, Addr: ":2102", AllowedClients: []string{"rover1"}}},
+			},
+			expectedErr: errors.New("path: outputs.0.allowed_clients.0 \"rover1\" must be an IP address or a CIDR range"),
+		},
+		{
+			name: "Allowed clients are only used by tcp server outputs",
+			config: &Config{
+				Input:   InputConfig{Transport: TransportTCP, Addr: "localhost:2101"},
+				Outputs: []OutputConfig{{Transport: TransportTCP, Addr: "localhost:2102", MaxClientConnections: 2}},
+			},
+			expectedErr: errors.New("path: outputs.0.allowed_clients and outputs.0.max_client_connections are only used with tcp_server"),
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			deps, err := tc.config.Validate(path)
+
+			if tc.expectedErr != nil {
+				test.That(t, err, test.ShouldBeError, tc.expectedErr)
+			} else {
+				test.That(t, err, test.ShouldBeNil)
+			}
+			test.That(t, len(deps), test.ShouldEqual, 0)
+		})
+	}
+}
+
+func TestForwardTCP(t *testing.T) {
+	logger := golog.NewTestLogger(t)
+	ctx := context.Background()
+	frame := rtcm3.EncapsulateByteArray([]byte{0x3e, 0xd0, 0x01, 0x02, 0x03}).Serialize()
+
+	// a base serving its corrections over tcp, and a peer the station forwards them to
+	base, err := net.Listen("tcp", "127.0.0.1:0")
+	test.That(t, err, test.ShouldBeNil)
+	defer base.Close()
+	peer, err := net.Listen("tcp", "127.0.0.1:0")
+	test.That(t, err, test.ShouldBeNil)
+	defer peer.Close()
+
+	go func() {
+		conn, err := base.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.Write(append([]byte{0xff, 0xff}, frame...))
+		// keep the stream open until the station closes it
+		io.Copy(io.Discard, conn)
+	}()
+
+	conf := &Config{
+		Input:   InputConfig{Transport: TransportTCP, Addr: base.Addr().String()},
+		Outputs: []OutputConfig{{Transport: TransportTCP, Addr: peer.Addr().String()}},
+	}
+	name := resource.NewName(sensor.API, testStationName)
+	g, err := newCorrectionStation(ctx, make(resource.Dependencies), name, conf, logger)
+	test.That(t, err, test.ShouldBeNil)
+
+	conn, err := peer.Accept()
+	test.That(t, err, test.ShouldBeNil)
+	defer conn.Close()
+	forwarded := make([]byte, len(frame))
+	_, err = io.ReadFull(conn, forwarded)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, forwarded, test.ShouldResemble, frame)
+
+	readings, err := g.Readings(ctx, nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, readings["rtcm_frames"], test.ShouldEqual, uint64(1))
+	test.That(t, readings["rtcm_bytes_sent"], test.ShouldEqual, uint64(len(frame)))
+	test.That(t, readings[rtkutils.StateKey], test.ShouldEqual, rtkutils.StateHealthy)
+	// the forwarded frame has no observations
+	test.That(t, readings["satellites"], test.ShouldEqual, 0)
+
+	test.That(t, g.Close(ctx), test.ShouldBeNil)
+}
+
+func TestOffSchedule(t *testing.T) {
+	logger := golog.NewTestLogger(t)
+	ctx := context.Background()
+	frame := rtcm3.EncapsulateByteArray([]byte{0x3e, 0xd0, 0x01, 0x02, 0x03}).Serialize()
+
+	base, err := net.Listen("tcp", "127.0.0.1:0")
+	test.That(t, err, test.ShouldBeNil)
+	defer base.Close()
+	go func() {
+		conn, err := base.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.Write(frame)
+		io.Copy(io.Discard, conn)
+	}()
+
+	// a schedule of a month that isn't this one
+	month := int(time.Now().Month())%12 + 1
+	conf := &Config{
+		Input:            InputConfig{Transport: TransportTCP, Addr: base.Addr().String()},
+		Outputs:          []OutputConfig{{Transport: TransportUDP, Addr: "127.0.0.1:1"}},
+		TransmitSchedule: fmt.Sprintf("* * * %d *", month),
+		ScheduleTimezone: "UTC",
+	}
+	name := resource.NewName(sensor.API, testStationName)
+	g, err := newCorrectionStation(ctx, make(resource.Dependencies), name, conf, logger)
+	test.That(t, err, test.ShouldBeNil)
+	defer g.Close(ctx)
+
+	station := g.(*correctionStation)
+	test.That(t, rtkutils.WaitFor(ctx, time.Second, "rtcm frames", func() bool { return station.rtcmFrames.Get() > 0 }), test.ShouldBeNil)
+	readings, err := g.Readings(ctx, nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, readings["rtcm_bytes_sent"], test.ShouldEqual, uint64(0))
+	test.That(t, readings[rtkutils.StateKey], test.ShouldEqual, rtkutils.StateOffSchedule)
+	test.That(t, readings["transmitting"], test.ShouldBeFalse)
+	test.That(t, readings["schedule_next_change"], test.ShouldNotBeEmpty)
+	decoded, err := g.DoCommand(ctx, map[string]interface{}{rtkutils.CommandKey: rtkutils.DecodeRTCMCommand})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, decoded["frames"], test.ShouldBeEmpty)
+}
+
+func TestHeartbeat(t *testing.T) {
+	logger := golog.NewTestLogger(t)
+	ctx := context.Background()
+
+	// a base that sends nothing, the monitor gets the heartbeat regardless
+	base, err := net.Listen("tcp", "127.0.0.1:0")
+	test.That(t, err, test.ShouldBeNil)
+	defer base.Close()
+	monitor, err := net.Listen("tcp", "127.0.0.1:0")
+	test.That(t, err, test.ShouldBeNil)
+	defer monitor.Close()
+
+	conf := &Config{
+		Input: InputConfig{Transport: TransportTCP, Addr: base.Addr().String()},
+		Outputs: []OutputConfig{
+			{Transport: TransportUDP, Addr: "127.0.0.1:1"},
+			{Transport: TransportTCP, Addr: monitor.Addr().String(), Heartbeat: true},
+		},
+	}
+	g, err := newCorrectionStation(ctx, make(resource.Dependencies), resource.NewName(sensor.API, testStationName), conf, logger)
+	test.That(t, err, test.ShouldBeNil)
+
+	conn, err := monitor.Accept()
+	test.That(t, err, test.ShouldBeNil)
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, line, test.ShouldStartWith, "$PRTKHB,")
+	test.That(t, line, test.ShouldContainSubstring, ","+rtkutils.StateInitializing+",0*")
+
+	test.That(t, g.Close(ctx), test.ShouldBeNil)
+}
+
+func TestMAVLinkOutput(t *testing.T) {
+	autopilot, err := net.ListenPacket("udp", "127.0.0.1:0")
+	test.That(t, err, test.ShouldBeNil)
+	defer autopilot.Close()
+	o := &output{
+		conf:    OutputConfig{Transport: TransportUDP, Addr: autopilot.LocalAddr().String(), MAVLink: true},
+		mavlink: rtkutils.NewMAVLinkRTCM(true),
+	}
+	defer o.close()
+
+	// a frame too long for MAVLink is skipped, the next one is sent in GPS_RTCM_DATA messages
+	test.That(t, o.send(make([]byte, 1000), time.Now()), test.ShouldBeNil)
+	frame := rtcm3.EncapsulateByteArray([]byte{0x43, 0x50, 0x00}).Serialize()
+	test.That(t, o.send(frame, time.Now()), test.ShouldBeNil)
+	buf := make([]byte, 1024)
+	test.That(t, autopilot.SetReadDeadline(time.Now().Add(time.Second)), test.ShouldBeNil)
+	n, _, err := autopilot.ReadFrom(buf)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, buf[0], test.ShouldEqual, byte(0xFD))
+	test.That(t, buf[7], test.ShouldEqual, byte(233))
+	test.That(t, buf[11], test.ShouldEqual, byte(len(frame)))
+	test.That(t, buf[12:12+len(frame)], test.ShouldResemble, frame)
+	test.That(t, n, test.ShouldEqual, 10+2+len(frame)+2)
+	test.That(t, o.mavlink.TooLong(), test.ShouldEqual, uint64(1))
+}
+
+func TestInputSendingNMEA(t *testing.T) {
+	logger := golog.NewTestLogger(t)
+	ctx := context.Background()
+
+	// a radio wired to the nmea port of the base receiver instead of its rtcm port
+	base, err := net.Listen("tcp", "127.0.0.1:0")
+	test.That(t, err, test.ShouldBeNil)
+	defer base.Close()
+	go func() {
+		conn, err := base.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		gga := []byte("$GPGGA,172814.00,3723.46587704,N,12202.26957864,W,1,6,1.2,18.893,M,-25.669,M,,*62\r\n")
+		for {
+			if _, err := conn.Write(gga); err != nil {
+				return
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}()
+
+	conf := &Config{
+		Input:   InputConfig{Transport: TransportTCP, Addr: base.Addr().String()},
+		Outputs: []OutputConfig{{Transport: TransportUDP, Addr: "127.0.0.1:2102"}},
+	}
+	name := resource.NewName(sensor.API, testStationName)
+	g, err := newCorrectionStation(ctx, make(resource.Dependencies), name, conf, logger)
+	test.That(t, err, test.ShouldBeNil)
+	defer g.Close(ctx)
+
+	result, err := g.DoCommand(ctx, map[string]interface{}{"command": rtkutils.SelfTestCommand, "timeout_sec": 0.5})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, result["passed"], test.ShouldBeFalse)
+	checks := result["checks"].([]interface{})
+	test.That(t, checks[len(checks)-1].(map[string]interface{})["error"], test.ShouldEqual, "input appears to be NMEA — check which UART is connected")
+}
+
+func TestCheckConfigCommand(t *testing.T) {
+	logger := golog.NewTestLogger(t)
+	ctx := context.Background()
+	conf := &Config{
+		Input:   InputConfig{Transport: TransportTCP, Addr: "127.0.0.1:1"},
+		Outputs: []OutputConfig{{Transport: TransportUDP, Addr: "127.0.0.1:2102"}},
+	}
+	g, err := newCorrectionStation(ctx, make(resource.Dependencies), resource.NewName(sensor.API, testStationName), conf, logger)
+	test.That(t, err, test.ShouldBeNil)
+	defer g.Close(ctx)
+
+	check := func(attrs map[string]interface{}) map[string]interface{} {
+		result, err := g.DoCommand(ctx, map[string]interface{}{
+			"command": rtkutils.CheckConfigCommand, "config": attrs, "timeout_sec": 0.2,
+		})
+		test.That(t, err, test.ShouldBeNil)
+		return result
+	}
+
+	// a network input has no hardware to check
+	result := check(map[string]interface{}{
+		"input":   map[string]interface{}{"transport": "ntrip", "ntrip_url": "http://caster.example.com:2101", "ntrip_mountpoint": "MP"},
+		"outputs": []interface{}{map[string]interface{}{"transport": "udp", "addr": "127.0.0.1:2102"}},
+	})
+	test.That(t, result["passed"], test.ShouldBeTrue)
+	test.That(t, result["ports"], test.ShouldResemble, map[string]interface{}{})
+
+	// a receiver that isn't connected fails the device checks the proposed config skips
+	result = check(map[string]interface{}{
+		"input":             map[string]interface{}{"transport": "serial", "serial_path": testPath},
+		"required_accuracy": 4,
+		"required_time_sec": 200,
+		"skip_device_check": true,
+	})
+	test.That(t, result["passed"], test.ShouldBeFalse)
+	checks := result["checks"].([]interface{})
+	test.That(t, checks[len(checks)-1].(map[string]interface{})["name"], test.ShouldEqual, "validate_config")
+}
+
+func TestServeTCP(t *testing.T) {
+	logger := golog.NewTestLogger(t)
+	ctx := context.Background()
+	frame := rtcm3.EncapsulateByteArray([]byte{0x3e, 0xd0, 0x01, 0x02, 0x03}).Serialize()
+
+	// a base serving a frame every 10ms, until the station closes the stream
+	base, err := net.Listen("tcp", "127.0.0.1:0")
+	test.That(t, err, test.ShouldBeNil)
+	defer base.Close()
+	go func() {
+		conn, err := base.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			if _, err := conn.Write(frame); err != nil {
+				return
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}()
+
+	conf := &Config{
+		Input: InputConfig{Transport: TransportTCP, Addr: base.Addr().String()},
+		Outputs: []OutputConfig{{
+			Transport:            TransportTCPServer,
+			Addr:                 "127.0.0.1:0",
+			AllowedClients:       []string{"127.0.0.1"},
+			MaxClientConnections: 1,
+		}},
+	}
+	name := resource.NewName(sensor.API, testStationName)
+	g, err := newCorrectionStation(ctx, make(resource.Dependencies), name, conf, logger)
+	test.That(t, err, test.ShouldBeNil)
+	defer g.Close(ctx)
+
+	// the server listens once the first frame is forwarded
+	server := g.(*correctionStation).outputs[0].server
+	test.That(t, rtkutils.WaitFor(ctx, time.Second, "tcp server", func() bool { return server.Addr() != nil }), test.ShouldBeNil)
+	conn, err := net.Dial("tcp", server.Addr().String())
+	test.That(t, err, test.ShouldBeNil)
+	defer conn.Close()
+	served := make([]byte, len(frame))
+	_, err = io.ReadFull(conn, served)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, served, test.ShouldResemble, frame)
+
+	result, err := g.DoCommand(ctx, map[string]interface{}{rtkutils.CommandKey: rtkutils.ClientsCommand})
+	test.That(t, err, test.ShouldBeNil)
+	clients := result["clients"].([]interface{})
+	test.That(t, clients, test.ShouldHaveLength, 1)
+	client := clients[0].(map[string]interface{})
+	test.That(t, client["ip"], test.ShouldEqual, "127.0.0.1")
+	test.That(t, client["connections"], test.ShouldEqual, 1)
+	test.That(t, client["bytes_sent"], test.ShouldBeGreaterThanOrEqualTo, uint64(len(frame)))
+
+	// a rover that sends its GGA back shows up in the readings
+	_, err = conn.Write([]byte("$GPGGA,172814.00,3723.46587704,N,12202.26957864,W,4,6,1.2,18.893,M,-25.669,M,,*57\r\n"))
+	test.That(t, err, test.ShouldBeNil)
+	var rovers []interface{}
+	reported := func() bool {
+		readings, err := g.Readings(ctx, nil)
+		test.That(t, err, test.ShouldBeNil)
+		rovers, _ = readings["rovers"].([]interface{})
+		return len(rovers) == 1
+	}
+	test.That(t, rtkutils.WaitFor(ctx, time.Second, "rover report", reported), test.ShouldBeNil)
+	rover := rovers[0].(map[string]interface{})
+	test.That(t, rover["ip"], test.ShouldEqual, "127.0.0.1")
+	test.That(t, rover["carr_soln"], test.ShouldEqual, "fixed")
+}
+
+func TestEffectiveConfigCommand(t *testing.T) {
+	logger := golog.NewTestLogger(t)
+	ctx := context.Background()
+	conf := &Config{
+		Input: InputConfig{
+			Transport:     TransportNtrip,
+			NtripURL:      "127.0.0.1:1/MP?token=abc",
+			NtripUsername: "base",
+			NtripPassword: "hunter2",
+		},
+		Outputs: []OutputConfig{{Transport: TransportSerial, SerialPath: testPath, AuthKey: "0123456789abcdef"}},
+	}
+	g, err := newCorrectionStation(ctx, make(resource.Dependencies), resource.NewName(sensor.API, testStationName), conf, logger)
+	test.That(t, err, test.ShouldBeNil)
+	defer g.Close(ctx)
+
+	result, err := g.DoCommand(ctx, map[string]interface{}{"command": rtkutils.EffectiveConfigCommand})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, result["input"], test.ShouldResemble, map[string]interface{}{
+		"transport":      TransportNtrip,
+		"ntrip_url":      "127.0.0.1:1/MP?token=REDACTED",
+		"ntrip_username": "base",
+		"ntrip_password": rtkutils.Redacted,
+	})
+	test.That(t, result["outputs"], test.ShouldResemble, []interface{}{map[string]interface{}{
+		"transport":        TransportSerial,
+		"serial_path":      testPath,
+		"serial_baud_rate": 38400.,
+		"auth_key":         rtkutils.Redacted,
+	}})
+}
+
+func TestConfigLost(t *testing.T) {
+	r := &correctionStation{
+		conf:   &Config{Input: InputConfig{Transport: TransportSerial}},
+		events: rtkutils.NewEventLog(golog.NewTestLogger(t)),
+	}
+	// nmea from a receiver that never sent corrections is a miswired input
+	test.That(t, r.configLost(rtkutils.ErrInputNMEA), test.ShouldBeFalse)
+
+	r.rtcmFrames.Add(10)
+	test.That(t, r.configLost(rtkutils.ErrInputUBX), test.ShouldBeFalse)
+	test.That(t, r.configLost(rtkutils.ErrInputNMEA), test.ShouldBeTrue)
+	test.That(t, r.waitForReceiver, test.ShouldBeTrue)
+	test.That(t, r.configResets.Get(), test.ShouldEqual, 1)
+	test.That(t, len(r.events.Events(rtkutils.EventReceiverConfigReset)), test.ShouldEqual, 1)
+
+	// one that doesn't send corrections once configured again is miswired after all
+	r.waitForReceiver, r.configuredFrames = false, r.rtcmFrames.Get()
+	test.That(t, r.configLost(rtkutils.ErrInputNMEA), test.ShouldBeFalse)
+
+	r.conf.Input.Transport = TransportTCP
+	r.rtcmFrames.Add(10)
+	test.That(t, r.configLost(rtkutils.ErrInputNMEA), test.ShouldBeFalse)
+}
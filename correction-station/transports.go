@@ -0,0 +1,205 @@
+package correctionstation
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"time"
+
+	"github.com/d2r2/go-i2c"
+	"github.com/jacobsa/go-serial/serial"
+	"go.uber.org/multierr"
+
+	rtkutils "rtksystem/rtk-utils"
+)
+
+const (
+	// a tcp input sending nothing for this long has dropped, bases send corrections every second
+	tcpReadTimeout = 30 * time.Second
+	// how long a write to a tcp or udp output may block the forwarding of corrections
+	netWriteTimeout = time.Second
+	dialTimeout     = 10 * time.Second
+	// how long to wait before reopening an output that failed
+	outputRetryInterval = 5 * time.Second
+	// how often the station checks whether its transmit schedule started or stopped
+	scheduleCheckInterval = 10 * time.Second
+)
+
+// inputErrorClasses are the classes of the errors of opening and reading an input.
+type inputErrorClasses struct {
+	category   string
+	open, read rtkutils.ErrorClass
+}
+
+// inputClasses returns the error classes of an input over transport.
+func inputClasses(transport string) inputErrorClasses {
+	category := categoryOf(transport)
+	return inputErrorClasses{
+		category: category,
+		open:     rtkutils.ErrorClass{Category: category, Op: rtkutils.OpOpen},
+		read:     rtkutils.ErrorClass{Category: category, Op: rtkutils.OpRead},
+	}
+}
+
+// categoryOf returns the error category of transport.
+func categoryOf(transport string) string {
+	switch transport {
+	case TransportSerial:
+		return rtkutils.ErrorSerial
+	case TransportI2C:
+		return rtkutils.ErrorI2C
+	default:
+		return rtkutils.ErrorNetwork
+	}
+}
+
+// recoveryPolicy is how the station recovers from the errors of an input over transport.
+func recoveryPolicy(transport string) rtkutils.RecoveryPolicy {
+	classes := inputClasses(transport)
+	return rtkutils.RecoveryPolicy{
+		Actions: map[rtkutils.ErrorClass]rtkutils.RecoveryAction{
+			// an input that fails while open was most likely unplugged or dropped, reopen it until it is back
+			classes.read: rtkutils.Reopen,
+			classes.open: rtkutils.Retry,
+		},
+		MaxFailures: 30,
+	}
+}
+
+// openInput opens the input corrections are read from.
+func (r *correctionStation) openInput() (io.ReadCloser, error) {
+	input := r.conf.Input
+	switch input.Transport {
+	case TransportSerial:
+		port, err := openSerial(input.SerialPath, input.SerialBaudRate)
+		if err != nil {
+			return nil, err
+		}
+		return rtkutils.NewSizedReader(port, r.inputBuffer), nil
+	case TransportI2C:
+		return rtkutils.NewI2CReader(r.cancelCtx, input.I2CBus, byte(input.I2CAddr),
+			rtkutils.PollInterval(r.conf.PollIntervalMs, rtkutils.DefaultPollInterval), r.inputBuffer), nil
+	case TransportTCP:
+		conn, err := rtkutils.DialTCP(r.cancelCtx, input.Addr, dialTimeout, input.tlsConfig())
+		if err != nil {
+			return nil, err
+		}
+		return &rtkutils.TimeoutConn{Conn: conn, ReadTimeout: tcpReadTimeout}, nil
+	default:
+		// the ntrip reader reconnects to the caster by itself
+		return r.ntrip, nil
+	}
+}
+
+// writeToReceiver writes a message to the receiver on the input, over a handle of its own.
+func (r *correctionStation) writeToReceiver(msg []byte) error {
+	input := r.conf.Input
+	switch input.Transport {
+	case TransportSerial:
+		port, err := openSerial(input.SerialPath, input.SerialBaudRate)
+		if err != nil {
+			return err
+		}
+		_, err = port.Write(msg)
+		return multierr.Combine(err, port.Close())
+	case TransportI2C:
+		handle, err := i2c.NewI2C(byte(input.I2CAddr), input.I2CBus)
+		if err != nil {
+			return err
+		}
+		_, err = handle.WriteBytes(msg)
+		return multierr.Combine(err, handle.Close())
+	default:
+		return fmt.Errorf("the %s input has no receiver", input.Transport)
+	}
+}
+
+func openSerial(path string, baud int) (io.ReadWriteCloser, error) {
+	return serial.Open(serial.OpenOptions{
+		PortName:        path,
+		BaudRate:        uint(baudRate(baud)),
+		DataBits:        8,
+		StopBits:        1,
+		MinimumReadSize: 4,
+	})
+}
+
+// output is a transport corrections are forwarded to. It is opened with the first frame, and
+// reopened with a later frame after it fails, so that a radio or a peer that isn't there yet
+// doesn't stop the others.
+type output struct {
+	conf    OutputConfig
+	signer  *rtkutils.FrameSigner
+	framer  *rtkutils.RadioFramer
+	mavlink *rtkutils.MAVLinkRTCM
+	server  *rtkutils.CorrectionServer // the clients of a tcp_server output
+	w       io.WriteCloser
+	retryAt time.Time
+}
+
+// send writes frame to the output, opening it if needed.
+func (o *output) send(frame []byte, now time.Time) error {
+	if o.w == nil {
+		if now.Before(o.retryAt) {
+			return nil
+		}
+		w, err := o.open()
+		if err != nil {
+			o.retryAt = now.Add(outputRetryInterval)
+			return err
+		}
+		o.w = w
+	}
+	// the frame and its tag are written at once, so a udp peer gets both in one datagram and a radio
+	// in one radio frame, as are the MAVLink messages of a fragmented frame
+	msg := o.mavlink.Wrap(o.framer.Wrap(o.signer.Sign(frame)))
+	if len(msg) == 0 {
+		// too long for MAVLink
+		return nil
+	}
+	if _, err := o.w.Write(msg); err != nil {
+		err = multierr.Combine(err, o.close())
+		o.retryAt = now.Add(outputRetryInterval)
+		return err
+	}
+	return nil
+}
+
+func (o *output) open() (io.WriteCloser, error) {
+	switch o.conf.Transport {
+	case TransportSerial:
+		return openSerial(o.conf.SerialPath, o.conf.SerialBaudRate)
+	case TransportTCPServer:
+		if err := o.server.Listen(); err != nil {
+			return nil, err
+		}
+		return o.server, nil
+	case TransportTCP:
+		conn, err := rtkutils.DialTCP(context.Background(), o.conf.Addr, dialTimeout, o.conf.tlsConfig())
+		if err != nil {
+			return nil, err
+		}
+		return &rtkutils.TimeoutConn{Conn: conn, WriteTimeout: netWriteTimeout}, nil
+	default:
+		conn, err := net.DialTimeout(o.conf.Transport, o.conf.Addr, dialTimeout)
+		if err != nil {
+			return nil, err
+		}
+		return &rtkutils.TimeoutConn{Conn: conn, WriteTimeout: netWriteTimeout}, nil
+	}
+}
+
+// category returns the error category of the output.
+func (o *output) category() string {
+	return categoryOf(o.conf.Transport)
+}
+
+func (o *output) close() error {
+	if o.w == nil {
+		return nil
+	}
+	err := o.w.Close()
+	o.w = nil
+	return err
+}
@@ -11,6 +11,7 @@ require (
 	github.com/jacobsa/go-serial v0.0.0-20180131005756-15cf729a72d4
 	github.com/kellydunn/golang-geo v0.7.0
 	github.com/pkg/errors v0.9.1
+	go.uber.org/multierr v1.11.0
 	go.viam.com/rdk v0.4.1-0.20230713192127-ce8a72c8070d
 	go.viam.com/utils v0.1.37
 )
@@ -116,7 +117,6 @@ require (
 	go.opencensus.io v0.24.0 // indirect
 	go.uber.org/atomic v1.10.0 // indirect
 	go.uber.org/goleak v1.2.1 // indirect
-	go.uber.org/multierr v1.11.0 // indirect
 	go.uber.org/zap v1.24.0 // indirect
 	go.viam.com/api v0.1.151 // indirect
 	go.viam.com/test v1.1.1-0.20220913152726-5da9916c08a2 // indirect
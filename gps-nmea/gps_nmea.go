@@ -0,0 +1,183 @@
+// Package gpsnmea registers the rdk's plain nmea gps, without rtk corrections, over serial and i2c.
+package gpsnmea
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/edaniels/golog"
+	"go.viam.com/rdk/components/movementsensor"
+	nmea "go.viam.com/rdk/components/movementsensor/gpsnmea"
+	"go.viam.com/rdk/resource"
+	"go.viam.com/utils"
+
+	rtkutils "rtksystem/rtk-utils"
+)
+
+var (
+	SerialModel = resource.NewModel("viam-labs", "movement-sensor", "gps-nmea-serial")
+	I2CModel    = resource.NewModel("viam-labs", "movement-sensor", "gps-nmea-i2c")
+)
+
+// connection types of the rdk's nmea gps config.
+const (
+	connectionSerial = "serial"
+	connectionI2C    = "i2c"
+)
+
+func init() {
+	resource.RegisterComponent(
+		movementsensor.API,
+		SerialModel,
+		resource.Registration[movementsensor.MovementSensor, *SerialConfig]{
+			Constructor: func(
+				ctx context.Context,
+				deps resource.Dependencies,
+				conf resource.Config,
+				logger golog.Logger,
+			) (movementsensor.MovementSensor, error) {
+				newConf, err := resource.NativeConfig[*SerialConfig](conf)
+				if err != nil {
+					return nil, err
+				}
+				gps, err := nmea.NewSerialGPSNMEA(ctx, conf.ResourceName(), newConf.nmeaConfig(), logger)
+				if err != nil {
+					return nil, err
+				}
+				return newVersionedGPS(gps), nil
+			},
+		})
+
+	resource.RegisterComponent(
+		movementsensor.API,
+		I2CModel,
+		resource.Registration[movementsensor.MovementSensor, *I2CConfig]{
+			Constructor: func(
+				ctx context.Context,
+				deps resource.Dependencies,
+				conf resource.Config,
+				logger golog.Logger,
+			) (movementsensor.MovementSensor, error) {
+				newConf, err := resource.NativeConfig[*I2CConfig](conf)
+				if err != nil {
+					return nil, err
+				}
+				gps, err := nmea.NewPmtkI2CGPSNMEA(ctx, deps, conf.ResourceName(), newConf.nmeaConfig(), logger)
+				if err != nil {
+					return nil, err
+				}
+				return newVersionedGPS(gps), nil
+			},
+		})
+}
+
+// versionedGPS is an rdk nmea gps that also answers the version command of the module's other models.
+type versionedGPS struct {
+	movementsensor.MovementSensor
+	closed rtkutils.CloseOnce
+}
+
+// newVersionedGPS wraps gps, registering it to be closed when the module shuts down like the
+// module's other models.
+func newVersionedGPS(gps movementsensor.MovementSensor) *versionedGPS {
+	g := &versionedGPS{MovementSensor: gps}
+	rtkutils.TrackResource(g)
+	return g
+}
+
+// DoCommand returns the version of the module, and passes other commands to the gps.
+func (g *versionedGPS) DoCommand(ctx context.Context, cmd map[string]interface{}) (map[string]interface{}, error) {
+	if cmd[rtkutils.CommandKey] == rtkutils.VersionCommand {
+		return rtkutils.VersionResult(), nil
+	}
+	return g.MovementSensor.DoCommand(ctx, cmd)
+}
+
+// Close closes the gps, once however often it is called.
+func (g *versionedGPS) Close(ctx context.Context) error {
+	return g.closed.Close(func() error { return g.close(ctx) })
+}
+
+func (g *versionedGPS) close(ctx context.Context) error {
+	rtkutils.UntrackResource(g)
+	return g.MovementSensor.Close(ctx)
+}
+
+// Accuracy returns the dilutions of precision of the gps, with both their keys, see rtkutils.TypedAccuracy.
+func (g *versionedGPS) Accuracy(ctx context.Context, extra map[string]interface{}) (map[string]float32, error) {
+	accuracy, err := g.MovementSensor.Accuracy(ctx, extra)
+	if accuracy == nil {
+		return accuracy, err
+	}
+	return rtkutils.TypedAccuracy(accuracy), err
+}
+
+// SerialConfig is used for converting the attributes of a serial nmea gps.
+type SerialConfig struct {
+	SerialPath     string `json:"serial_path"`
+	SerialBaudRate int    `json:"serial_baud_rate,omitempty"`
+
+	// Validate checks that the serial port exists unless this is set, for receivers connected later
+	SkipDeviceCheck bool `json:"skip_device_check,omitempty"`
+}
+
+// Validate ensures all parts of the config are valid.
+func (cfg *SerialConfig) Validate(path string) ([]string, error) {
+	if cfg.SerialPath == "" {
+		return nil, utils.NewConfigValidationFieldRequiredError(path, "serial_path")
+	}
+	if !cfg.SkipDeviceCheck {
+		if err := rtkutils.ValidateDevicePath("serial_path", cfg.SerialPath); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	return nil, nil
+}
+
+func (cfg *SerialConfig) nmeaConfig() *nmea.Config {
+	return &nmea.Config{
+		ConnectionType: connectionSerial,
+		SerialConfig: &nmea.SerialConfig{
+			SerialPath:     cfg.SerialPath,
+			SerialBaudRate: cfg.SerialBaudRate,
+		},
+	}
+}
+
+// I2CConfig is used for converting the attributes of an i2c nmea gps. The bus is read through
+// the board it is configured on.
+type I2CConfig struct {
+	Board       string `json:"board"`
+	I2CBus      string `json:"i2c_bus"`
+	I2CAddr     int    `json:"i2c_addr"`
+	I2CBaudRate int    `json:"i2c_baud_rate,omitempty"`
+}
+
+// Validate ensures all parts of the config are valid.
+func (cfg *I2CConfig) Validate(path string) ([]string, error) {
+	if cfg.Board == "" {
+		return nil, utils.NewConfigValidationFieldRequiredError(path, "board")
+	}
+	if cfg.I2CBus == "" {
+		return nil, utils.NewConfigValidationFieldRequiredError(path, "i2c_bus")
+	}
+	if cfg.I2CAddr == 0 {
+		return nil, utils.NewConfigValidationFieldRequiredError(path, "i2c_addr")
+	}
+	if err := rtkutils.ValidateI2CAddr("i2c_addr", cfg.I2CAddr); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return []string{cfg.Board}, nil
+}
+
+func (cfg *I2CConfig) nmeaConfig() *nmea.Config {
+	return &nmea.Config{
+		ConnectionType: connectionI2C,
+		I2CConfig: &nmea.I2CConfig{
+			Board:       cfg.Board,
+			I2CBus:      cfg.I2CBus,
+			I2CAddr:     cfg.I2CAddr,
+			I2CBaudRate: cfg.I2CBaudRate,
+		},
+	}
+}
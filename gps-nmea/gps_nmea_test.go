@@ -0,0 +1,127 @@
+package gpsnmea
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.viam.com/rdk/components/movementsensor"
+	"go.viam.com/test"
+	"go.viam.com/utils"
+
+	rtkutils "rtksystem/rtk-utils"
+)
+
+const path = "path"
+
+func TestValidateSerial(t *testing.T) {
+	tests := []struct {
+		name        string
+		config      *SerialConfig
+		expectedErr error
+	}{
+		{
+			name:   "A config with a serial path should result in no errors",
+			config: &SerialConfig{SerialPath: "some-path", SkipDeviceCheck: true},
+		},
+		{
+			name:        "A config without a serial path should error",
+			config:      &SerialConfig{},
+			expectedErr: utils.NewConfigValidationFieldRequiredError(path, "serial_path"),
+		},
+		{
+			name:   "A serial port that doesn't exist should error",
+			config: &SerialConfig{SerialPath: "some-path"},
+			expectedErr: errors.New("path: serial_path \"some-path\" doesn't exist, check that the device is connected, " +
+				"or set skip_device_check if it is only connected later"),
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			deps, err := tc.config.Validate(path)
+			test.That(t, deps, test.ShouldBeNil)
+			if tc.expectedErr != nil {
+				test.That(t, err, test.ShouldBeError, tc.expectedErr)
+			} else {
+				test.That(t, err, test.ShouldBeNil)
+			}
+		})
+	}
+}
+
+func TestValidateI2C(t *testing.T) {
+	tests := []struct {
+		name         string
+		config       *I2CConfig
+		expectedDeps []string
+		expectedErr  error
+	}{
+		{
+			name:         "A config with a board, bus and address depends on the board",
+			config:       &I2CConfig{Board: "pi", I2CBus: "1", I2CAddr: 0x42},
+			expectedDeps: []string{"pi"},
+		},
+		{
+			name:        "A config without a board should error",
+			config:      &I2CConfig{I2CBus: "1", I2CAddr: 0x42},
+			expectedErr: utils.NewConfigValidationFieldRequiredError(path, "board"),
+		},
+		{
+			name:        "A config without a bus should error",
+			config:      &I2CConfig{Board: "pi", I2CAddr: 0x42},
+			expectedErr: utils.NewConfigValidationFieldRequiredError(path, "i2c_bus"),
+		},
+		{
+			name:        "A config without an address should error",
+			config:      &I2CConfig{Board: "pi", I2CBus: "1"},
+			expectedErr: utils.NewConfigValidationFieldRequiredError(path, "i2c_addr"),
+		},
+		{
+			name:        "A reserved address should error",
+			config:      &I2CConfig{Board: "pi", I2CBus: "1", I2CAddr: 0x78},
+			expectedErr: errors.New("path: i2c_addr 0x78 is not a valid i2c address, devices use 0x8 to 0x77 (u-blox receivers default to 0x42)"),
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			deps, err := tc.config.Validate(path)
+			if tc.expectedErr != nil {
+				test.That(t, err, test.ShouldBeError, tc.expectedErr)
+				test.That(t, deps, test.ShouldBeNil)
+			} else {
+				test.That(t, err, test.ShouldBeNil)
+				test.That(t, deps, test.ShouldResemble, tc.expectedDeps)
+			}
+		})
+	}
+}
+
+func TestVersionCommand(t *testing.T) {
+	gps := &versionedGPS{}
+	result, err := gps.DoCommand(context.Background(), map[string]interface{}{rtkutils.CommandKey: rtkutils.VersionCommand})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, result["version"], test.ShouldEqual, "dev")
+}
+
+// fakeGPS is a gps that only counts how often it is closed.
+type fakeGPS struct {
+	movementsensor.MovementSensor
+	closes int
+}
+
+func (g *fakeGPS) Close(ctx context.Context) error {
+	g.closes++
+	return errors.New("port already closed")
+}
+
+func TestClose(t *testing.T) {
+	fake := &fakeGPS{}
+	gps := newVersionedGPS(fake)
+
+	// module shutdown and the robot both close the gps
+	test.That(t, gps.Close(context.Background()), test.ShouldBeError, errors.New("port already closed"))
+	test.That(t, gps.Close(context.Background()), test.ShouldBeError, errors.New("port already closed"))
+	test.That(t, fake.closes, test.ShouldEqual, 1)
+}
@@ -1,11 +1,16 @@
 package gpsrtki2c
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"math"
+	"os"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/d2r2/go-i2c"
 	"github.com/d2r2/go-logger"
@@ -13,22 +18,104 @@ import (
 	"github.com/edaniels/golog"
 	"github.com/golang/geo/r3"
 	geo "github.com/kellydunn/golang-geo"
+	"go.uber.org/multierr"
 	"go.viam.com/utils"
 
 	"go.viam.com/rdk/components/movementsensor"
-	"go.viam.com/rdk/components/movementsensor/gpsnmea"
+	"go.viam.com/rdk/components/sensor"
 	"go.viam.com/rdk/resource"
 	"go.viam.com/rdk/spatialmath"
+	rutils "go.viam.com/rdk/utils"
+
+	rtkutils "rtksystem/rtk-utils"
 )
 
 var errNilLocation = errors.New("nil gps location, check nmea message parsing")
+
+// Classes of the errors of the rover's workers.
+var (
+	classOpenI2C   = rtkutils.ErrorClass{Category: rtkutils.ErrorI2C, Op: rtkutils.OpOpen}
+	classReadI2C   = rtkutils.ErrorClass{Category: rtkutils.ErrorI2C, Op: rtkutils.OpRead}
+	classWriteI2C  = rtkutils.ErrorClass{Category: rtkutils.ErrorI2C, Op: rtkutils.OpWrite}
+	classCloseI2C  = rtkutils.ErrorClass{Category: rtkutils.ErrorI2C, Op: rtkutils.OpClose}
+	classConfigure = rtkutils.ErrorClass{Category: rtkutils.ErrorI2C, Op: rtkutils.OpConfigure}
+	// reading the correction buffer of the station, which fails while no station is attached
+	classReadCorrections = rtkutils.ErrorClass{Category: rtkutils.ErrorRTCM, Op: rtkutils.OpRead}
+)
+
+// recoveryPolicy is how the rover recovers from the errors of its workers.
+var recoveryPolicy = rtkutils.RecoveryPolicy{
+	Actions: map[rtkutils.ErrorClass]rtkutils.RecoveryAction{
+		// the bus is opened for every transfer, so retrying also reopens it
+		classOpenI2C:         rtkutils.Retry,
+		classReadI2C:         rtkutils.Retry,
+		classWriteI2C:        rtkutils.Retry,
+		classReadCorrections: rtkutils.Retry,
+		// a handle left open may keep other devices off the bus
+		classCloseI2C: rtkutils.Surface,
+		// an unconfigured receiver still sends its default sentences
+		classConfigure: rtkutils.Surface,
+	},
+	MaxFailures: 30,
+}
+
 var Model = resource.NewModel("viam-labs", "movement-sensor", "gps-rtk-i2c-no-network")
 
+// default time to wait for an rtk fix after provisioning the receiver.
+const defaultProvisionFixTimeout = 2 * time.Minute
+
 type Config struct {
 	I2CBus      int `json:"i2c_bus"`
 	NMEAAddr    int `json:"nmea_i2c_addr"` // address of the rover
 	RTCMAddr    int `json:"rtcm_i2c_addr"` // address of the station
 	I2CBaudRate int `json:"i2c_baud_rate,omitempty"`
+
+	// Validate checks that the i2c bus exists unless this is set
+	SkipDeviceCheck bool `json:"skip_device_check,omitempty"`
+
+	// Serve standalone gps positions while the correction source can't be read, e.g. a station
+	// that isn't attached yet, instead of failing once it kept failing
+	AllowGPSOnly bool `json:"allow_gps_only,omitempty"`
+
+	// How long loops polling for data wait when there is none, 50ms for i2c reads and 200ms for
+	// remote_correction_station by default
+	PollIntervalMs int `json:"poll_interval_ms,omitempty"`
+
+	// Name of a correction station on another robot to read corrections from instead of rtcm_i2c_addr
+	RemoteCorrectionStation string `json:"remote_correction_station,omitempty"`
+
+	// A recorded rtcm file replayed as the correction source instead of rtcm_i2c_addr, for offline debugging
+	ReplayCorrectionFile string `json:"replay_correction_file,omitempty"`
+	ReplayLoop           bool   `json:"replay_loop,omitempty"`
+
+	// Added to every reported heading to correct for antennas mounted off the vehicle's forward axis
+	HeadingOffsetDegrees float64 `json:"heading_offset_degrees,omitempty"`
+
+	// Thresholds used to decide whether the rover is moving, see rtkutils.MotionThresholds
+	MovingSpeed      float64 `json:"moving_speed_mps,omitempty"`
+	StationarySpeed  float64 `json:"stationary_speed_mps,omitempty"`
+	MovingDistance   float64 `json:"moving_distance_m,omitempty"`
+	StationaryEpochs int     `json:"stationary_epochs,omitempty"`
+
+	// An overspeed event is raised above speed_limit_mps and cleared below speed_limit_clear_mps
+	SpeedLimit      float64 `json:"speed_limit_mps,omitempty"`
+	SpeedLimitClear float64 `json:"speed_limit_clear_mps,omitempty"`
+
+	// Fixes of the last track_minutes are kept for export_track, and appended to track_path if it is set
+	TrackMinutes float64 `json:"track_minutes,omitempty"`
+	TrackPath    string  `json:"track_path,omitempty"`
+
+	// Raw rover observations and base corrections are recorded here for ppk_solve, which runs the
+	// RTKLIB tools in rtklib_path, or those on the PATH without it
+	PPKRecordDir string `json:"ppk_record_dir,omitempty"`
+	RTKLIBPath   string `json:"rtklib_path,omitempty"`
+
+	// Raw sentences are captured to this file once parse failures exceed the rate per minute
+	ParseFailureLogPath string `json:"parse_failure_log_path,omitempty"`
+	ParseFailureRate    int    `json:"parse_failure_rate_per_min,omitempty"`
+
+	// warnings about deprecated keys found by convertAttributes
+	warnings []string
 }
 
 // Validate ensures all parts of the config are valid.
@@ -39,10 +126,81 @@ func (cfg *Config) Validate(path string) ([]string, error) {
 	if cfg.NMEAAddr == 0 {
 		return nil, utils.NewConfigValidationFieldRequiredError(path, "nmea_i2c_addr")
 	}
-	if cfg.RTCMAddr == 0 {
+	deps := []string{}
+	if cfg.RemoteCorrectionStation != "" && cfg.ReplayCorrectionFile != "" {
+		return nil, fmt.Errorf("%s: only one of remote_correction_station and replay_correction_file can be set", path)
+	}
+	if cfg.RemoteCorrectionStation != "" {
+		deps = append(deps, cfg.RemoteCorrectionStation)
+	} else if cfg.ReplayCorrectionFile == "" && cfg.RTCMAddr == 0 {
 		return nil, utils.NewConfigValidationFieldRequiredError(path, "rtcm_i2c_addr")
 	}
-	return []string{}, nil
+	if err := rtkutils.ValidateI2CAddr("nmea_i2c_addr", cfg.NMEAAddr); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if cfg.RemoteCorrectionStation == "" && cfg.ReplayCorrectionFile == "" {
+		if err := rtkutils.ValidateI2CAddr("rtcm_i2c_addr", cfg.RTCMAddr); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		if cfg.RTCMAddr == cfg.NMEAAddr {
+			return nil, fmt.Errorf("%s: nmea_i2c_addr and rtcm_i2c_addr are both %#x, "+
+				"the rover and station receivers need different addresses", path, cfg.NMEAAddr)
+		}
+	}
+	if !cfg.SkipDeviceCheck {
+		if err := rtkutils.ValidateDevicePath("i2c_bus", rtkutils.I2CBusPath(cfg.I2CBus)); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	if cfg.TrackPath != "" && cfg.TrackMinutes <= 0 {
+		return nil, utils.NewConfigValidationFieldRequiredError(path, "track_minutes")
+	}
+	if err := rtkutils.ValidatePPK(cfg.PPKRecordDir, cfg.RTKLIBPath); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if err := rtkutils.ValidateSpeedAlarm(cfg.SpeedLimit, cfg.SpeedLimitClear); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if err := cfg.motionThresholds().Validate(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if err := rtkutils.ValidatePollInterval(cfg.PollIntervalMs); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return deps, nil
+}
+
+func (cfg *Config) motionThresholds() rtkutils.MotionThresholds {
+	return rtkutils.MotionThresholds{
+		MovingSpeed:      cfg.MovingSpeed,
+		StationarySpeed:  cfg.StationarySpeed,
+		MovingDistance:   cfg.MovingDistance,
+		StationaryEpochs: cfg.StationaryEpochs,
+	}
+}
+
+// configMigration maps the keys of old configs to the current ones.
+var configMigration = rtkutils.ConfigMigration{
+	Protocol: "i2c",
+	Nested:   []string{"i2c_attributes"},
+	Aliases: map[string]string{
+		"i2c_addr":      "nmea_i2c_addr",
+		"rctm_i2c_addr": "rtcm_i2c_addr",
+	},
+}
+
+// convertAttributes converts the attributes of a config, migrating the keys of old configs.
+func convertAttributes(attributes rutils.AttributeMap) (*Config, error) {
+	migrated, warnings, err := configMigration.Migrate(attributes)
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := resource.TransformAttributeMap[*Config](migrated)
+	if err != nil {
+		return nil, err
+	}
+	cfg.warnings = warnings
+	return cfg, nil
 }
 
 func init() {
@@ -60,8 +218,13 @@ func init() {
 				if err != nil {
 					return nil, err
 				}
+				for _, warning := range newConf.warnings {
+					logger.Warn(warning)
+				}
+				logger.Warnf("%s is deprecated, use viam-labs:movement-sensor:gps-rtk with a i2c nmea_source instead", Model.Name)
 				return newRTKI2CNoNetwork(ctx, deps, conf.ResourceName(), newConf, logger)
 			},
+			AttributeMapConverter: convertAttributes,
 		})
 }
 
@@ -72,22 +235,50 @@ type rtkI2CNoNetwork struct {
 	logger     golog.Logger
 	cancelCtx  context.Context
 	cancelFunc func()
+	closed     rtkutils.CloseOnce
 
 	activeBackgroundWorkers sync.WaitGroup
 
-	err          movementsensor.LastError
+	err          *rtkutils.ErrorHistory
+	recovery     *rtkutils.Recovery
+	workers      *rtkutils.Supervisor
 	lastposition movementsensor.LastPosition
+	// when the last position was read from the receiver
+	lastPositionAt time.Time
+	lastPositionMu sync.Mutex
 
-	data gpsnmea.GPSData
-	mu   sync.RWMutex
+	parseFailures *rtkutils.ParseFailureRecorder
+	sentences     rtkutils.SentenceStats // sentences read from the receiver by type
+	ppk           *rtkutils.PPKRecorder
+	track         *rtkutils.Track
+	events        *rtkutils.EventLog
+	speedAlarm    *rtkutils.SpeedAlarm
+
+	latest rtkutils.Snapshot // the latest complete nmea epoch
+	epochs rtkutils.EpochTracker
+	mu     sync.RWMutex
+
+	headingOffset float64 // degrees added to every reported heading
 
 	bus       int
 	wbaud     int
 	readAddr  byte
 	writeAddr byte
 
+	pollInterval       time.Duration // wait after an i2c read with no data
+	remotePollInterval time.Duration
+
 	readI2c  *i2c.I2C
 	writeI2c *i2c.I2C
+
+	rtcmFrames rtkutils.Counter // frames forwarded to the gps
+	published  rtkutils.Counter // nmea epochs published
+
+	remoteStation resource.Resource // correction station on another robot, read instead of readAddr
+	replayPath    string            // recorded rtcm file replayed instead of readAddr
+	replayLoop    bool
+
+	allowGPSOnly bool // keep serving positions while corrections can't be read
 }
 
 func newRTKI2CNoNetwork(
@@ -98,15 +289,28 @@ func newRTKI2CNoNetwork(
 	logger golog.Logger,
 ) (movementsensor.MovementSensor, error) {
 
-	cancelCtx, cancelFunc := context.WithCancel(context.Background())
+	cancelCtx, cancelFunc := context.WithCancel(rtkutils.ModuleContext())
 	g := &rtkI2CNoNetwork{
 		Named:        name.AsNamed(),
 		cancelCtx:    cancelCtx,
 		cancelFunc:   cancelFunc,
 		logger:       logger,
-		err:          movementsensor.NewLastError(1, 1),
+		err:          rtkutils.NewErrorHistory(),
 		lastposition: movementsensor.NewLastPosition(),
+		parseFailures: rtkutils.NewParseFailureRecorder(
+			newConf.ParseFailureLogPath, newConf.ParseFailureRate, rtkutils.DefaultParseFailureLogSize),
+		headingOffset: newConf.HeadingOffsetDegrees,
+		events:        rtkutils.NewEventLog(logger),
+		speedAlarm:    rtkutils.NewSpeedAlarm(newConf.SpeedLimit, newConf.SpeedLimitClear),
+		allowGPSOnly:  newConf.AllowGPSOnly,
 	}
+	policy := recoveryPolicy
+	if g.allowGPSOnly {
+		policy = policy.WithOptional(classReadCorrections)
+	}
+	g.recovery = rtkutils.NewRecovery(policy, g.err, logger)
+	g.workers = rtkutils.NewSupervisor(cancelCtx, &g.activeBackgroundWorkers, logger)
+	g.epochs.SetMotionThresholds(newConf.motionThresholds())
 
 	if newConf.I2CBaudRate == 0 {
 		newConf.I2CBaudRate = 38400
@@ -117,10 +321,41 @@ func newRTKI2CNoNetwork(
 	g.readAddr = byte(newConf.RTCMAddr)
 	g.writeAddr = byte(newConf.NMEAAddr)
 	g.bus = newConf.I2CBus
+	g.pollInterval = rtkutils.PollInterval(newConf.PollIntervalMs, rtkutils.DefaultPollInterval)
+	g.remotePollInterval = rtkutils.PollInterval(newConf.PollIntervalMs, rtkutils.DefaultRemotePollInterval)
+
+	if newConf.RemoteCorrectionStation != "" {
+		station, err := sensor.FromDependencies(deps, newConf.RemoteCorrectionStation)
+		if err != nil {
+			cancelFunc()
+			return nil, err
+		}
+		g.remoteStation = station
+	}
+	g.replayPath, g.replayLoop = newConf.ReplayCorrectionFile, newConf.ReplayLoop
+
+	ppk, err := rtkutils.NewPPKRecorder(newConf.PPKRecordDir, newConf.RTKLIBPath)
+	if err != nil {
+		cancelFunc()
+		return nil, fmt.Errorf("failed to start ppk recording: %w", err)
+	}
+	g.ppk = ppk
+
+	track, err := rtkutils.NewTrack(time.Duration(newConf.TrackMinutes*float64(time.Minute)), newConf.TrackPath)
+	if err != nil {
+		cancelFunc()
+		g.ppk.Close()
+		return nil, fmt.Errorf("failed to open track file: %w", err)
+	}
+	g.track = track
 
 	if err := g.start(); err != nil {
+		cancelFunc()
+		g.ppk.Close()
+		g.track.Close()
 		return nil, err
 	}
+	rtkutils.TrackResource(g)
 	return g, g.err.Get()
 }
 
@@ -131,95 +366,169 @@ func (g *rtkI2CNoNetwork) start() error {
 		return err
 	}
 
-	g.activeBackgroundWorkers.Add(1)
-	utils.PanicCapturingGo(func() { g.receiveAndWriteI2C(g.cancelCtx) })
+	switch {
+	case g.remoteStation != nil:
+		g.workers.Go("remote correction reader", func() error {
+			reader := rtkutils.NewRemoteCorrectionReader(g.cancelCtx, g.remoteStation, g.remotePollInterval, g.logger)
+			return g.receiveAndWriteReader(reader)
+		})
+	case g.replayPath != "":
+		replay, err := rtkutils.NewRTCMReplayReader(g.cancelCtx, g.replayPath, g.replayLoop)
+		if err != nil {
+			if !g.allowGPSOnly {
+				return err
+			}
+			// the worker keeps trying to open it
+			g.logger.Warnf("can't replay corrections, serving gps positions without them: %s", err)
+			replay = nil
+		}
+		g.workers.Go("correction replay", func() error {
+			// a restarted replay starts over from the beginning of the file
+			if replay == nil {
+				var err error
+				if replay, err = rtkutils.NewRTCMReplayReader(g.cancelCtx, g.replayPath, g.replayLoop); err != nil {
+					return err
+				}
+			}
+			defer func() {
+				replay.Close()
+				replay = nil
+			}()
+			return g.receiveAndWriteReader(replay)
+		})
+	default:
+		g.workers.Go("correction writer", func() error { return g.receiveAndWriteI2C(g.cancelCtx) })
+	}
 
 	return g.err.Get()
 }
 
 // start begins reading nmea messages from module and updates gps data.
 func (g *rtkI2CNoNetwork) startGPSNMEA(ctx context.Context) error {
-	err := g.initializeI2C(ctx)
-	if err != nil {
-		g.logger.Errorf("error initializing i2c %v", err)
-		g.err.Set(err)
-	}
-
-	g.activeBackgroundWorkers.Add(1)
-	utils.PanicCapturingGo(func() {
-		g.readNMEAMessages(ctx)
+	g.workers.Go("nmea reader", func() error {
+		return g.readNMEAMessages(ctx)
 	})
 
 	return g.err.Get()
 }
 
-func (g *rtkI2CNoNetwork) readNMEAMessages(ctx context.Context) {
-	defer g.activeBackgroundWorkers.Done()
-	strBuf := ""
+// setUpReceiver configures the receiver and enables raw measurement output for ppk. It only
+// returns the error of a receiver that isn't there, the others are handled here.
+func (g *rtkI2CNoNetwork) setUpReceiver(ctx context.Context) error {
+	if err := g.initializeI2C(ctx); err != nil {
+		if rtkutils.IsDeviceAbsent(err) {
+			return err
+		}
+		g.recovery.Handle(ctx, classConfigure, err)
+	}
+	if g.ppk != nil {
+		if err := rtkutils.EnableRawOutput(g.writeToReceiver); err != nil {
+			if rtkutils.IsDeviceAbsent(err) {
+				return err
+			}
+			g.logger.Warnf("failed to enable raw measurement output for ppk: %s", err)
+		}
+	}
+	return nil
+}
+
+func (g *rtkI2CNoNetwork) readNMEAMessages(ctx context.Context) error {
+	var sentences rtkutils.NMEASplitter
+	buffer := make([]byte, 1024)
+	setUp := false
 	for {
 		select {
 		case <-g.cancelCtx.Done():
-			return
+			return nil
 		default:
 		}
-		// open/close each loop so other things also have a chance to use i2c
-		// create i2c connection
-		i2cBus, err := i2c.NewI2C(g.writeAddr, g.bus)
-		if err != nil {
-			g.logger.Errorf("error opening the i2c bus: %v", err)
-			g.err.Set(err)
-		}
-
-		// change so you don't see a million logs
-		gologger.ChangePackageLogLevel("i2c", gologger.InfoLevel)
-
-		// Record the error value no matter what. If it's nil, this will help suppress
-		// ephemeral errors later.
-		g.err.Set(err)
-		if err != nil {
-			g.logger.Errorf("can't open gps i2c handle: %s", err)
-			return
+		if !setUp {
+			// the receiver may only be powered on after the module, set it up once it is there
+			if err := g.setUpReceiver(ctx); err != nil {
+				if g.cancelCtx.Err() != nil || g.recovery.Handle(g.cancelCtx, classOpenI2C, err) == rtkutils.Rebuild {
+					return rtkutils.ErrRebuildRequired
+				}
+				continue
+			}
+			setUp = true
 		}
-		buffer := make([]byte, 1024)
-		_, err = i2cBus.ReadBytes(buffer)
-		g.err.Set(err)
-		err = i2cBus.Close()
-		g.err.Set(err)
+		class, err := g.readNMEABuffer(buffer)
 		if err != nil {
-			g.logger.Errorf("failed to close the i2c bus: %s", err)
-			return
+			if g.cancelCtx.Err() != nil || g.recovery.Handle(g.cancelCtx, class, err) == rtkutils.Rebuild {
+				return rtkutils.ErrRebuildRequired
+			}
+			// the buffer was read before the bus failed to close
+			if class != classCloseI2C {
+				// the sentence being read lost the bytes of the failed read
+				sentences.Reset()
+				continue
+			}
 		}
-		if err != nil {
-			g.logger.Error(err)
+		if rtkutils.IsIdle(buffer) {
+			// the receiver has nothing to send yet
+			utils.SelectContextOrWait(g.cancelCtx, g.pollInterval)
 			continue
 		}
-		for _, b := range buffer {
-			// PMTK uses CRLF line endings to terminate sentences, but just LF to blank data.
-			// Since CR should never appear except at the end of our sentence, we use that to determine sentence end.
-			// LF is merely ignored.
-			if b == 0x0D {
-				if strBuf != "" {
-					g.mu.Lock()
-					err = g.data.ParseAndUpdate(strBuf)
-					g.mu.Unlock()
-					if err != nil {
-						g.logger.Debugf("can't parse nmea : %s, %v", strBuf, err)
-					}
-				}
-				strBuf = ""
-			} else if b != 0x0A && b != 0xFF { // adds only valid bytes
-				strBuf += string(b)
-			}
-		}
+		// with ppk recording the receiver also sends binary raw measurements, 0xFF is idle padding
+		g.ppk.Rover().Write(bytes.TrimRight(buffer, "\xff"))
+		sentences.Split(buffer, time.Now(), g.parseNMEA)
+	}
+}
+
+// parseNMEA parses a sentence read from the receiver into the current epoch.
+func (g *rtkI2CNoNetwork) parseNMEA(sentence string) {
+	if g.ppk != nil && !strings.Contains(sentence, "$G") {
+		return
+	}
+	g.mu.Lock()
+	snap, published, err := g.epochs.ParseAndUpdate(sentence)
+	if published {
+		g.latest = snap
+	}
+	g.mu.Unlock()
+	if published {
+		g.onEpoch(snap)
+	}
+	g.sentences.Record(sentence, err)
+	switch {
+	case errors.Is(err, rtkutils.ErrUnsupportedSentence):
+		g.logger.Debugf("ignoring nmea sentence: %v", err)
+	case err != nil:
+		g.err.Record(rtkutils.ErrorParse, err)
+		g.logger.Debugf("can't parse nmea : %s, %v", sentence, g.parseFailures.Record(sentence, err))
+	}
+}
+
+// readNMEABuffer reads the nmea buffer of the receiver. The bus is opened and closed for each
+// read so other things also have a chance to use i2c. It returns the class of a failure.
+func (g *rtkI2CNoNetwork) readNMEABuffer(buffer []byte) (rtkutils.ErrorClass, error) {
+	i2cBus, err := i2c.NewI2C(g.writeAddr, g.bus)
+	if err != nil {
+		return classOpenI2C, err
+	}
+	g.recovery.Succeeded(classOpenI2C)
+
+	// change so you don't see a million logs
+	gologger.ChangePackageLogLevel("i2c", gologger.InfoLevel)
+
+	_, err = i2cBus.ReadBytes(buffer)
+	closeErr := i2cBus.Close()
+	if err != nil {
+		return classReadI2C, err
+	}
+	g.recovery.Succeeded(classReadI2C)
+	if closeErr != nil {
+		return classCloseI2C, closeErr
 	}
+	g.recovery.Succeeded(classCloseI2C)
+	return rtkutils.ErrorClass{}, nil
 }
 
 func (g *rtkI2CNoNetwork) initializeI2C(ctx context.Context) error {
 	// create i2c connection
 	i2cBus, err := i2c.NewI2C(g.writeAddr, g.bus)
 	if err != nil {
-		g.logger.Errorf("error opening the i2c bus: %v", err)
-		g.err.Set(err)
+		return err
 	}
 
 	// change so you don't see a million logs
@@ -231,114 +540,181 @@ func (g *rtkI2CNoNetwork) initializeI2C(ctx context.Context) error {
 	cmd314 := movementsensor.PMTKAddChk([]byte("PMTK314,1,1,1,1,1,1,0,0,0,0,0,0,0,0,0,0,0,0,0"))
 	cmd220 := movementsensor.PMTKAddChk([]byte("PMTK220,1000"))
 
+	// the handle is closed on failures too, since a receiver that isn't there is retried
 	_, err = i2cBus.WriteBytes(cmd251)
-	if err != nil {
+	if err != nil && !rtkutils.IsDeviceAbsent(err) {
 		g.logger.Errorf("Failed to set baud rate")
+		err = nil
 	}
-	_, err = i2cBus.WriteBytes(cmd314)
-	if err != nil {
-		g.logger.Errorf("i2c write failed %s", err)
-		return err
+	if err == nil {
+		_, err = i2cBus.WriteBytes(cmd314)
 	}
-	_, err = i2cBus.WriteBytes(cmd220)
-	if err != nil {
-		g.logger.Errorf("i2c write failed %s", err)
-		return err
+	if err == nil {
+		_, err = i2cBus.WriteBytes(cmd220)
 	}
-	err = i2cBus.Close()
-	if err != nil {
-		g.logger.Errorf("failed to close handle: %s", err)
-		return err
+	if closeErr := i2cBus.Close(); err == nil {
+		err = closeErr
 	}
-	return nil
+	return err
 }
 
 // receiveAndWriteI2C reads tbe rctm correction messages from the read addr and writes the write addr
-func (g *rtkI2CNoNetwork) receiveAndWriteI2C(ctx context.Context) {
-
-	defer g.activeBackgroundWorkers.Done()
-	if err := g.cancelCtx.Err(); err != nil {
-		return
+func (g *rtkI2CNoNetwork) receiveAndWriteI2C(ctx context.Context) error {
+	for g.cancelCtx.Err() == nil {
+		n, class, err := g.transferCorrections()
+		if err != nil && g.cancelCtx.Err() == nil && g.recovery.Handle(g.cancelCtx, class, err) == rtkutils.Rebuild {
+			return rtkutils.ErrRebuildRequired
+		}
+		if err == nil && n == 0 {
+			// the station has no new corrections yet
+			utils.SelectContextOrWait(g.cancelCtx, g.pollInterval)
+		}
 	}
+	return nil
+}
 
-	var err error
-	for err == nil {
-		select {
-		case <-g.cancelCtx.Done():
-			return
-		default:
+// transferCorrections copies the correction buffer of the read addr to the write addr. The i2c
+// handles are opened and closed for each transfer so other processes can use them. It returns
+// the number of bytes forwarded and the class of a failure.
+func (g *rtkI2CNoNetwork) transferCorrections() (n int, class rtkutils.ErrorClass, err error) {
+	defer func() {
+		closeErr := g.closeCorrectionHandles()
+		if closeErr == nil {
+			g.recovery.Succeeded(classCloseI2C)
+		} else if err == nil {
+			n, class, err = 0, classCloseI2C, closeErr
 		}
-		var rctmData []byte
+	}()
 
-		// create i2c connections
-		var err error
-		g.readI2c, err = i2c.NewI2C(g.readAddr, g.bus)
-		g.err.Set(err)
+	// create i2c connections
+	if g.readI2c, err = i2c.NewI2C(g.readAddr, g.bus); err != nil {
+		return 0, classOpenI2C, err
+	}
+	if g.writeI2c, err = i2c.NewI2C(g.writeAddr, g.bus); err != nil {
+		return 0, classOpenI2C, err
+	}
+	g.recovery.Succeeded(classOpenI2C)
 
-		g.writeI2c, err = i2c.NewI2C(g.writeAddr, g.bus)
-		g.err.Set(err)
+	// change so you don't see a million logs
+	logger.ChangePackageLogLevel("i2c", logger.InfoLevel)
 
-		// change so you don't see a million logs
-		logger.ChangePackageLogLevel("i2c", logger.InfoLevel)
+	// read from the correction buffer
+	buf := make([]byte, 1024)
+	if _, err := g.readI2c.ReadBytes(buf); err != nil {
+		return 0, classReadCorrections, err
+	}
+	g.recovery.Succeeded(classReadCorrections)
 
-		// read from the correction buffer
-		buf := make([]byte, 1024)
-		_, err = g.readI2c.ReadBytes(buf)
-		g.err.Set(err)
-		if err != nil {
-			g.logger.Debug("Could not read from the i2c address")
+	// write only the rctm data
+	var rctmData []byte
+	for _, b := range buf {
+		if b != 255 {
+			rctmData = append(rctmData, b)
 		}
+	}
+	if len(rctmData) == 0 {
+		return 0, rtkutils.ErrorClass{}, nil
+	}
+	if _, err := g.writeI2c.WriteBytes(rctmData); err != nil {
+		return 0, classWriteI2C, err
+	}
+	g.recovery.Succeeded(classWriteI2C)
+	g.rtcmFrames.Add(uint64(rtkutils.CountRTCMFrames(rctmData)))
+	g.ppk.Base().Write(rctmData)
+	return len(rctmData), rtkutils.ErrorClass{}, nil
+}
 
-		// write only the rctm data
-		for _, b := range buf {
-			if b != 255 {
-				rctmData = append(rctmData, b)
-			}
-		}
+// closeCorrectionHandles closes the i2c handles opened by transferCorrections.
+func (g *rtkI2CNoNetwork) closeCorrectionHandles() error {
+	var err error
+	if g.readI2c != nil {
+		err = multierr.Combine(err, g.readI2c.Close())
+		g.readI2c = nil
+	}
+	if g.writeI2c != nil {
+		err = multierr.Combine(err, g.writeI2c.Close())
+		g.writeI2c = nil
+	}
+	return err
+}
 
-		if len(rctmData) != 0 {
-			_, err = g.writeI2c.WriteBytes(rctmData)
-			g.err.Set(err)
-			if err != nil {
-				g.logger.Debug("Could not write to i2c address")
-			}
+// receiveAndWriteReader reads the rtcm correction messages from a remote station or a replayed file and writes the write addr
+func (g *rtkI2CNoNetwork) receiveAndWriteReader(reader io.Reader) error {
+	buf := make([]byte, 1024)
+	for {
+		n, err := reader.Read(buf)
+		if errors.Is(err, io.EOF) {
+			g.logger.Infof("finished replaying corrections from %s", g.replayPath)
+			return nil
 		}
-
-		// close I2C handles each time so other processes can use them
-		err = g.readI2c.Close()
-		g.err.Set(err)
-		g.readI2c = nil
 		if err != nil {
-			g.logger.Debug("failed to close i2c handle: %s", err)
-			return
+			// otherwise the readers only fail once the rover is closed
+			return err
 		}
-		err = g.writeI2c.Close()
-		g.err.Set(err)
-		g.writeI2c = nil
-		if err != nil {
-			g.logger.Debug("failed to close i2c handle: %s", err)
-			return
+		if err := g.writeToReceiver(buf[:n]); err != nil {
+			if g.recovery.Handle(g.cancelCtx, classWriteI2C, err) == rtkutils.Rebuild {
+				return rtkutils.ErrRebuildRequired
+			}
+			continue
 		}
+		g.recovery.Succeeded(classWriteI2C)
+		g.rtcmFrames.Add(uint64(rtkutils.CountRTCMFrames(buf[:n])))
+		g.ppk.Base().Write(buf[:n])
+	}
+}
+
+// onEpoch handles every complete nmea epoch once it is published.
+func (g *rtkI2CNoNetwork) onEpoch(snap rtkutils.Snapshot) {
+	g.published.Add(1)
+	if err := g.track.Add(snap.Data, time.Now()); err != nil {
+		g.logger.Warnf("failed to write track file: %s", err)
 	}
+	g.speedAlarm.Update(snap.Data.Speed, g.events)
 }
 
-// Position returns the current geographic location of the MOVEMENTSENSOR.
+// Position returns the current geographic location of the MOVEMENTSENSOR. When it fails, it returns
+// the last known location, if any, along with an rtkutils.LastPositionError telling how old it is.
 func (g *rtkI2CNoNetwork) Position(ctx context.Context, extra map[string]interface{}) (*geo.Point, float64, error) {
-	lastError := g.err.Get()
-	if lastError != nil {
-		lastPosition := g.lastposition.GetLastPosition()
-		if lastPosition != nil {
-			return lastPosition, 0, nil
-		}
-		return geo.NewPoint(math.NaN(), math.NaN()), math.NaN(), lastError
+	pos, alt, err := g.currentPosition(extra)
+	if err != nil {
+		lastErr := g.lastPositionError(err)
+		return lastErr.Position, 0, lastErr
+	}
+	return pos, alt, nil
+}
+
+// lastPositionError returns err with the last position the rover knew.
+func (g *rtkI2CNoNetwork) lastPositionError(err error) *rtkutils.LastPositionError {
+	category := g.err.Category(err)
+	if errors.Is(err, errNilLocation) {
+		category = rtkutils.ErrorParse
 	}
+	g.lastPositionMu.Lock()
+	readAt := g.lastPositionAt
+	g.lastPositionMu.Unlock()
+	lastErr := &rtkutils.LastPositionError{Err: err, Category: category}
+	if last := g.lastposition.GetLastPosition(); last != nil && !readAt.IsZero() {
+		lastErr.Position, lastErr.Age = last, time.Since(readAt)
+	}
+	return lastErr
+}
 
+// currentPosition returns the position of the latest epoch.
+func (g *rtkI2CNoNetwork) currentPosition(extra map[string]interface{}) (*geo.Point, float64, error) {
 	lastPosition := g.lastposition.GetLastPosition()
+	if lastError := g.err.Get(); lastError != nil {
+		return lastPosition, 0, lastError
+	}
 
 	g.mu.RLock()
 	defer g.mu.RUnlock()
 
-	currentPosition := g.data.Location
+	snap, err := g.snapshot(extra)
+	if err != nil {
+		return lastPosition, 0, err
+	}
+
+	currentPosition := snap.Data.Location
 
 	if currentPosition == nil {
 		return lastPosition, 0, errNilLocation
@@ -346,7 +722,7 @@ func (g *rtkI2CNoNetwork) Position(ctx context.Context, extra map[string]interfa
 
 	// if current position is (0,0) we will return the last non zero position
 	if g.lastposition.IsZeroPosition(currentPosition) && !g.lastposition.IsZeroPosition(lastPosition) {
-		return lastPosition, g.data.Alt, g.err.Get()
+		return lastPosition, snap.Data.Alt, g.err.Get()
 	}
 
 	// updating lastposition if it is different from the current position
@@ -357,9 +733,12 @@ func (g *rtkI2CNoNetwork) Position(ctx context.Context, extra map[string]interfa
 	// updating the last known valid position if the current position is non-zero
 	if !g.lastposition.IsZeroPosition(currentPosition) && !g.lastposition.IsPositionNaN(currentPosition) {
 		g.lastposition.SetLastPosition(currentPosition)
+		g.lastPositionMu.Lock()
+		g.lastPositionAt = time.Now()
+		g.lastPositionMu.Unlock()
 	}
 
-	return currentPosition, g.data.Alt, g.err.Get()
+	return currentPosition, snap.Data.Alt, g.err.Get()
 }
 
 // LinearVelocity passthrough.
@@ -371,7 +750,11 @@ func (g *rtkI2CNoNetwork) LinearVelocity(ctx context.Context, extra map[string]i
 
 	g.mu.RLock()
 	defer g.mu.RUnlock()
-	return r3.Vector{X: 0, Y: g.data.Speed, Z: 0}, g.err.Get()
+	snap, err := g.snapshot(extra)
+	if err != nil {
+		return r3.Vector{}, err
+	}
+	return r3.Vector{X: 0, Y: snap.Data.Speed, Z: 0}, g.err.Get()
 }
 
 // LinearAcceleration not supported.
@@ -389,11 +772,25 @@ func (g *rtkI2CNoNetwork) AngularVelocity(ctx context.Context, extra map[string]
 	return spatialmath.AngularVelocity{}, movementsensor.ErrMethodUnimplementedAngularVelocity
 }
 
-// CompassHeading not supported.
+// CompassHeading returns the dual antenna heading, or the course over ground when there is none,
+// corrected by the configured heading offset. It is NaN if no heading was reported in the epoch.
 func (g *rtkI2CNoNetwork) CompassHeading(ctx context.Context, extra map[string]interface{}) (float64, error) {
+	lastError := g.err.Get()
+	if lastError != nil {
+		return math.NaN(), lastError
+	}
+
 	g.mu.RLock()
 	defer g.mu.RUnlock()
-	return 0, movementsensor.ErrMethodUnimplementedCompassHeading
+	snap, err := g.snapshot(extra)
+	if err != nil {
+		return math.NaN(), err
+	}
+	heading := snap.Heading
+	if !heading.Valid {
+		return math.NaN(), g.err.Get()
+	}
+	return rtkutils.NormalizeHeading(heading.Degrees + g.headingOffset), g.err.Get()
 }
 
 // Orientation not supported.
@@ -412,7 +809,7 @@ func (g *rtkI2CNoNetwork) readFix(ctx context.Context) (int, error) {
 
 	g.mu.RLock()
 	defer g.mu.RUnlock()
-	return g.data.FixQuality, g.err.Get()
+	return g.latest.Data.FixQuality, g.err.Get()
 }
 
 // Properties passthrough.
@@ -420,10 +817,11 @@ func (g *rtkI2CNoNetwork) Properties(ctx context.Context, extra map[string]inter
 	return &movementsensor.Properties{
 		LinearVelocitySupported: true,
 		PositionSupported:       true,
+		CompassHeadingSupported: true,
 	}, nil
 }
 
-// Accuracy passthrough.
+// Accuracy returns the accuracy of the epoch, see rtkutils.EpochAccuracy.
 func (g *rtkI2CNoNetwork) Accuracy(ctx context.Context, extra map[string]interface{}) (map[string]float32, error) {
 	lastError := g.err.Get()
 	if lastError != nil {
@@ -432,28 +830,180 @@ func (g *rtkI2CNoNetwork) Accuracy(ctx context.Context, extra map[string]interfa
 
 	g.mu.RLock()
 	defer g.mu.RUnlock()
-	return map[string]float32{"hDOP": float32(g.data.HDOP), "vDOP": float32(g.data.VDOP)}, g.err.Get()
+	snap, err := g.snapshot(extra)
+	if err != nil {
+		return map[string]float32{}, err
+	}
+	return rtkutils.EpochAccuracy(snap), g.err.Get()
 }
 
-// Readings uses the movementSensor readings function.
+// snapshot returns the epoch pinned in extra, or the latest epoch if none is pinned.
+// The caller must hold mu.
+func (g *rtkI2CNoNetwork) snapshot(extra map[string]interface{}) (rtkutils.Snapshot, error) {
+	epoch, pinned, err := rtkutils.RequestedEpoch(extra)
+	if err != nil || !pinned || epoch == g.latest.Epoch {
+		return g.latest, err
+	}
+	return g.epochs.Get(epoch)
+}
+
+// Readings uses the movementSensor readings function, with every value taken from the same nmea epoch.
 func (g *rtkI2CNoNetwork) Readings(ctx context.Context, extra map[string]interface{}) (map[string]interface{}, error) {
+	// without an epoch or a device, or after a fatal error, there is nothing to read but the state
+	state := g.state()
+	if state == rtkutils.StateInitializing || state == rtkutils.StateWaitingForDevice || state == rtkutils.StateError {
+		return map[string]interface{}{rtkutils.StateKey: state}, nil
+	}
+
+	g.mu.RLock()
+	extra = rtkutils.PinEpoch(extra, g.latest.Epoch)
+	g.mu.RUnlock()
+
 	readings, err := movementsensor.Readings(ctx, g, extra)
 
 	if err != nil {
 		return nil, err
 	}
+	readings[rtkutils.EpochKey] = extra[rtkutils.EpochKey]
+
+	g.mu.RLock()
+	snap, err := g.snapshot(extra)
+	g.mu.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+	readings["moving"] = snap.Moving
+	readings[rtkutils.StateKey] = state
+	readings[rtkutils.RestartsKey] = g.workers.Restarts()
+	if g.speedAlarm != nil {
+		readings["overspeed"] = g.speedAlarm.Overspeed()
+	}
 
 	return readings, nil
 }
 
+// state returns the state of the rover for its readings.
+func (g *rtkI2CNoNetwork) state() string {
+	g.mu.RLock()
+	fixQuality := g.latest.Data.FixQuality
+	g.mu.RUnlock()
+	now := time.Now()
+	state := rtkutils.RoverState(g.err, &g.published, fixQuality, now)
+	if g.allowGPSOnly {
+		state = rtkutils.GPSOnlyState(state, &g.rtcmFrames, now)
+	}
+	return rtkutils.DeviceState(state, g.recovery)
+}
+
+// DoCommand runs the commands supported by the rover.
+func (g *rtkI2CNoNetwork) DoCommand(ctx context.Context, cmd map[string]interface{}) (map[string]interface{}, error) {
+	switch cmd[rtkutils.CommandKey] {
+	case rtkutils.SelfTestCommand:
+		return g.selfTest(ctx, rtkutils.SelfTestTimeout(cmd)), nil
+	case rtkutils.ProvisionCommand:
+		return g.provision(ctx, cmd)
+	case rtkutils.SendSentenceCommand:
+		return rtkutils.SendSentence(cmd, g.writeToReceiver)
+	case rtkutils.PPKSolveCommand:
+		return rtkutils.PPKSolveResult(ctx, g.ppk)
+	case rtkutils.ExportTrackCommand:
+		return rtkutils.ExportTrackResult(g.track, cmd)
+	case rtkutils.EventsCommand:
+		return g.events.EventsResult(cmd), nil
+	case rtkutils.ErrorsCommand:
+		return g.err.ErrorsResult(cmd), nil
+	case rtkutils.SentenceStatsCommand:
+		return rtkutils.SentenceStatsResult(g.sentences.Get()), nil
+	case rtkutils.VersionCommand:
+		return rtkutils.VersionResult(), nil
+	default:
+		return nil, resource.ErrDoUnimplemented
+	}
+}
+
+// selfTest checks that both i2c addresses respond, that its configuration can be written and that
+// nmea sentences and rtcm corrections are flowing.
+func (g *rtkI2CNoNetwork) selfTest(ctx context.Context, timeout time.Duration) map[string]interface{} {
+	report := rtkutils.NewReport()
+
+	report.Check("nmea_i2c_addr_ack", rtkutils.CheckI2CAck(g.writeAddr, g.bus))
+	switch {
+	case g.remoteStation != nil:
+		report.Check("remote_correction_station", rtkutils.CheckRemoteStation(ctx, g.remoteStation))
+	case g.replayPath != "":
+		_, err := os.Stat(g.replayPath)
+		report.Check("correction_replay_file", err)
+	default:
+		report.Check("rtcm_i2c_addr_ack", rtkutils.CheckI2CAck(g.readAddr, g.bus))
+	}
+	report.Check("receiver_config_write", g.initializeI2C(ctx))
+	report.Check("nmea_sentences", rtkutils.WaitForIncrease(ctx, timeout, "complete nmea epoch", g.currentEpoch))
+	report.Check("rtcm_frames", rtkutils.WaitForIncrease(ctx, timeout, "rtcm frames forwarded", g.rtcmFrames.Get))
+
+	return report.Result()
+}
+
+// provision enables rtcm input on the receiver port the radio is wired to (uart2 by default),
+// saves the configuration and waits for an rtk fix.
+func (g *rtkI2CNoNetwork) provision(ctx context.Context, cmd map[string]interface{}) (map[string]interface{}, error) {
+	port, err := rtkutils.UBXPortArg(cmd, "rtcm_input_port", rtkutils.UBXPortUART2)
+	if err != nil {
+		return nil, err
+	}
+	baudRate := rtkutils.IntArg(cmd, "baud_rate", g.wbaud)
+
+	report := rtkutils.NewReport()
+	report.Check("enable_rtcm_input", g.writeToReceiver(rtkutils.UBXPortConfigPacket(port, baudRate,
+		rtkutils.UBXProtoUBX|rtkutils.UBXProtoNMEA|rtkutils.UBXProtoRTCM3, rtkutils.UBXProtoUBX|rtkutils.UBXProtoNMEA)))
+	report.Check("save_config", g.writeToReceiver(rtkutils.UBXSaveConfigPacket()))
+	if report.Passed() {
+		timeout := rtkutils.DurationArg(cmd, "fix_timeout_sec", defaultProvisionFixTimeout)
+		report.Check("rtk_fix", rtkutils.WaitFor(ctx, timeout, "rtk fix", g.hasRTKFix))
+	}
+
+	return report.Result(), nil
+}
+
+// writeToReceiver writes a raw message to the receiver over its nmea i2c address.
+func (g *rtkI2CNoNetwork) writeToReceiver(msg []byte) error {
+	handle, err := i2c.NewI2C(g.writeAddr, g.bus)
+	if err != nil {
+		return err
+	}
+	_, err = handle.WriteBytes(msg)
+	if closeErr := handle.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+func (g *rtkI2CNoNetwork) hasRTKFix() bool {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return rtkutils.IsRTKFix(g.latest.Data.FixQuality)
+}
+
+func (g *rtkI2CNoNetwork) currentEpoch() uint64 {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return g.latest.Epoch
+}
+
 // Close shuts down the RTKI2CNoNetwork.
 func (g *rtkI2CNoNetwork) Close(ctx context.Context) error {
+	return g.closed.Close(func() error { return g.close(ctx) })
+}
+
+func (g *rtkI2CNoNetwork) close(ctx context.Context) error {
+	rtkutils.UntrackResource(g)
 	g.cancelFunc()
-	g.activeBackgroundWorkers.Wait()
+	if !rtkutils.WaitWithTimeout(ctx, &g.activeBackgroundWorkers, rtkutils.DefaultShutdownTimeout) {
+		g.logger.Warn("timed out waiting for background workers to stop")
+	}
 
 	if g.readI2c != nil {
 		err := g.readI2c.Close()
-		g.err.Set(err)
+		g.err.Set(rtkutils.ErrorI2C, err)
 		if err != nil {
 			g.logger.Errorf("failed to close i2c read bus: %s", err)
 		}
@@ -462,13 +1012,25 @@ func (g *rtkI2CNoNetwork) Close(ctx context.Context) error {
 
 	if g.writeI2c != nil {
 		err := g.writeI2c.Close()
-		g.err.Set(err)
+		g.err.Set(rtkutils.ErrorI2C, err)
 		if err != nil {
 			g.logger.Errorf("failed to close i2c write bus: %s", err)
 		}
 		g.writeI2c = nil
 	}
 
+	if err := g.parseFailures.Close(); err != nil {
+		g.logger.Errorf("failed to close parse failure log: %s", err)
+	}
+
+	if err := g.track.Close(); err != nil {
+		g.logger.Errorf("failed to close track file: %s", err)
+	}
+
+	if err := g.ppk.Close(); err != nil {
+		g.logger.Errorf("failed to close ppk recording: %s", err)
+	}
+
 	if err := g.err.Get(); err != nil && !errors.Is(err, context.Canceled) {
 		return err
 	}
@@ -2,6 +2,7 @@ package gpsrtki2c
 
 import (
 	"context"
+	"errors"
 	"testing"
 
 	"github.com/edaniels/golog"
@@ -10,8 +11,11 @@ import (
 	"go.viam.com/rdk/components/movementsensor"
 	"go.viam.com/rdk/components/movementsensor/gpsnmea"
 	"go.viam.com/rdk/resource"
+	rutils "go.viam.com/rdk/utils"
 	"go.viam.com/test"
 	"go.viam.com/utils"
+
+	rtkutils "rtksystem/rtk-utils"
 )
 
 const (
@@ -42,10 +46,40 @@ func TestValidate(t *testing.T) {
 		{
 			name: "A valid config should result in no errors",
 			config: &Config{
-				I2CBus:   testi2cBus,
+				I2CBus:          testi2cBus,
+				NMEAAddr:        testNmeaAddr,
+				RTCMAddr:        testRTCMAddr,
+				SkipDeviceCheck: true,
+			},
+		},
+		{
+			name: "a config with the same nmea and rtcm addresses should result in error",
+			config: &Config{
+				I2CBus:          testi2cBus,
+				NMEAAddr:        testNmeaAddr,
+				RTCMAddr:        testNmeaAddr,
+				SkipDeviceCheck: true,
+			},
+			expectedErr: errors.New("path: nmea_i2c_addr and rtcm_i2c_addr are both 0x42, the rover and station receivers need different addresses"),
+		},
+		{
+			name: "a config with an rtcm address out of range should result in error",
+			config: &Config{
+				I2CBus:          testi2cBus,
+				NMEAAddr:        testNmeaAddr,
+				RTCMAddr:        0x80,
+				SkipDeviceCheck: true,
+			},
+			expectedErr: errors.New("path: rtcm_i2c_addr 0x80 is not a valid i2c address, devices use 0x8 to 0x77 (u-blox receivers default to 0x42)"),
+		},
+		{
+			name: "a config with an i2c bus that doesn't exist should result in error",
+			config: &Config{
+				I2CBus:   99,
 				NMEAAddr: testNmeaAddr,
 				RTCMAddr: testRTCMAddr,
 			},
+			expectedErr: errors.New("path: i2c_bus \"/dev/i2c-99\" doesn't exist, check that the device is connected, or set skip_device_check if it is only connected later"),
 		},
 		{
 			name: "a config with no i2c_bus should result in error",
@@ -86,6 +120,23 @@ func TestValidate(t *testing.T) {
 	}
 }
 
+func TestConvertAttributes(t *testing.T) {
+	// an old rover config with the misspelled rtcm address
+	cfg, err := convertAttributes(rutils.AttributeMap{
+		"connection_type": "I2C",
+		"i2c_attributes":  map[string]interface{}{"i2c_bus": 1.0, "i2c_addr": 66.0},
+		"rctm_i2c_addr":   67.0,
+	})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, cfg.I2CBus, test.ShouldEqual, 1)
+	test.That(t, cfg.NMEAAddr, test.ShouldEqual, testNmeaAddr)
+	test.That(t, cfg.RTCMAddr, test.ShouldEqual, testRTCMAddr)
+	test.That(t, cfg.warnings, test.ShouldHaveLength, 4)
+
+	_, err = convertAttributes(rutils.AttributeMap{"connection_type": "serial"})
+	test.That(t, err, test.ShouldNotBeNil)
+}
+
 func TestNewrtki2cNoNetwork(t *testing.T) {
 	logger := golog.NewTestLogger(t)
 	ctx := context.Background()
@@ -133,7 +184,8 @@ func TestPosition(t *testing.T) {
 	var testRTK = &rtkI2CNoNetwork{
 		logger:    logger,
 		cancelCtx: ctx,
-		data:      mockGPSData,
+		latest:    rtkutils.Snapshot{Data: mockGPSData},
+		err:       rtkutils.NewErrorHistory(),
 	}
 
 	lastPostion := movementsensor.LastPosition{}
@@ -169,13 +221,17 @@ func TestPosition(t *testing.T) {
 
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
-			testRTK.data.Location = tc.location
+			testRTK.latest.Data.Location = tc.location
 			location, alt, err := testRTK.Position(ctx, nil)
 			if tc.expectedErr == nil {
 				test.That(t, err, test.ShouldBeNil)
 				test.That(t, alt, test.ShouldEqual, mockGPSData.Alt)
 			} else {
-				test.That(t, err, test.ShouldBeError, tc.expectedErr)
+				var lastErr *rtkutils.LastPositionError
+				test.That(t, errors.As(err, &lastErr), test.ShouldBeTrue)
+				test.That(t, lastErr.Err, test.ShouldEqual, tc.expectedErr)
+				test.That(t, lastErr.Category, test.ShouldEqual, rtkutils.ErrorParse)
+				test.That(t, lastErr.Position, test.ShouldEqual, location)
 				test.That(t, alt, test.ShouldEqual, 0)
 			}
 			if tc.validLocation {
@@ -186,6 +242,18 @@ func TestPosition(t *testing.T) {
 
 		})
 	}
+
+	// a failed read returns the last known position along with the error
+	readErr := errors.New("read failed")
+	testRTK.err.Set(rtkutils.ErrorSerial, readErr)
+	location, alt, err := testRTK.Position(ctx, nil)
+	var lastErr *rtkutils.LastPositionError
+	test.That(t, errors.As(err, &lastErr), test.ShouldBeTrue)
+	test.That(t, errors.Is(err, readErr), test.ShouldBeTrue)
+	test.That(t, lastErr.Category, test.ShouldEqual, rtkutils.ErrorSerial)
+	test.That(t, location, test.ShouldResemble, geo.NewPoint(0.01, 0.001))
+	test.That(t, lastErr.Position, test.ShouldEqual, location)
+	test.That(t, alt, test.ShouldEqual, 0)
 }
 
 func TestLinearVelocity(t *testing.T) {
@@ -195,7 +263,8 @@ func TestLinearVelocity(t *testing.T) {
 	testRTK := &rtkI2CNoNetwork{
 		logger:    logger,
 		cancelCtx: ctx,
-		data:      mockGPSData,
+		latest:    rtkutils.Snapshot{Data: mockGPSData},
+		err:       rtkutils.NewErrorHistory(),
 	}
 
 	linearVel, err := testRTK.LinearVelocity(ctx, nil)
@@ -214,7 +283,8 @@ func TestLinearAcceleration(t *testing.T) {
 	testRTK := &rtkI2CNoNetwork{
 		logger:    logger,
 		cancelCtx: ctx,
-		data:      mockGPSData,
+		latest:    rtkutils.Snapshot{Data: mockGPSData},
+		err:       rtkutils.NewErrorHistory(),
 	}
 
 	linearAcc, err := testRTK.LinearAcceleration(ctx, nil)
@@ -229,7 +299,8 @@ func TestReadFix(t *testing.T) {
 	testRTK := &rtkI2CNoNetwork{
 		logger:    logger,
 		cancelCtx: ctx,
-		data:      mockGPSData,
+		latest:    rtkutils.Snapshot{Data: mockGPSData},
+		err:       rtkutils.NewErrorHistory(),
 	}
 
 	fix, err := testRTK.readFix(ctx)
@@ -245,10 +316,13 @@ func TestClose(t *testing.T) {
 		logger:     logger,
 		cancelCtx:  cancelCtx,
 		cancelFunc: cancelFunc,
-		data:       mockGPSData,
-		err:        movementsensor.NewLastError(1, 1),
+		latest:     rtkutils.Snapshot{Data: mockGPSData},
+		err:        rtkutils.NewErrorHistory(),
 	}
 
 	err := testRTK.Close(cancelCtx)
 	test.That(t, err, test.ShouldBeNil)
+
+	// the module shuts it down and the robot closes it too
+	test.That(t, testRTK.Close(cancelCtx), test.ShouldBeNil)
 }
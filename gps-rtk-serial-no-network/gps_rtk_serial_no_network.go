@@ -4,33 +4,112 @@ import (
 	"bufio"
 	"context"
 	"errors"
+	"fmt"
 	"io"
 	"math"
+	"os"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/edaniels/golog"
-	"github.com/go-gnss/rtcm/rtcm3"
 	"github.com/golang/geo/r3"
 	slib "github.com/jacobsa/go-serial/serial"
 	geo "github.com/kellydunn/golang-geo"
 	"go.viam.com/rdk/components/movementsensor"
-	"go.viam.com/rdk/components/movementsensor/gpsnmea"
+	"go.viam.com/rdk/components/sensor"
 	"go.viam.com/rdk/resource"
 	"go.viam.com/rdk/spatialmath"
+	rutils "go.viam.com/rdk/utils"
 	"go.viam.com/utils"
+
+	rtkutils "rtksystem/rtk-utils"
 )
 
 var Model = resource.NewModel("viam-labs", "movement-sensor", "gps-rtk-serial-no-network")
+
+// default time to wait for an rtk fix after provisioning the receiver.
+const defaultProvisionFixTimeout = 2 * time.Minute
+
 var errNilLocation = errors.New("nil gps location, check nmea message parsing")
 
+// Classes of the errors of the rover's workers.
+var (
+	classOpenNMEA         = rtkutils.ErrorClass{Category: rtkutils.ErrorSerial, Op: rtkutils.OpOpen}
+	classReadNMEA         = rtkutils.ErrorClass{Category: rtkutils.ErrorSerial, Op: rtkutils.OpRead}
+	classWriteCorrections = rtkutils.ErrorClass{Category: rtkutils.ErrorSerial, Op: rtkutils.OpWrite}
+	classOpenCorrections  = rtkutils.ErrorClass{Category: rtkutils.ErrorRTCM, Op: rtkutils.OpOpen}
+	classReadCorrections  = rtkutils.ErrorClass{Category: rtkutils.ErrorRTCM, Op: rtkutils.OpRead}
+)
+
+// recoveryPolicy is how the rover recovers from the errors of its workers.
+var recoveryPolicy = rtkutils.RecoveryPolicy{
+	Actions: map[rtkutils.ErrorClass]rtkutils.RecoveryAction{
+		// a port that can't be opened yet may still appear, e.g. a receiver plugged in late
+		classOpenNMEA:        rtkutils.Retry,
+		classOpenCorrections: rtkutils.Retry,
+		// a port that fails once open was most likely unplugged, reopen it until it is back
+		classReadNMEA:         rtkutils.Reopen,
+		classWriteCorrections: rtkutils.Reopen,
+		classReadCorrections:  rtkutils.Reopen,
+	},
+	MaxFailures: 30,
+}
+
 type Config struct {
 	SerialNMEAPath           string `json:"serial_nmea_path"` // The path that NMEA data is being written to
 	SerialNMEABaudRate       int    `json:"serial_nmea_baud_rate,omitempty"`
 	SerialCorrectionPath     string `json:"serial_correction_path"` // The path that rtcm data will be read from
 	SerialCorrectionBaudRate int    `json:"serial_correction_baud_rate"`
 
+	// Validate checks that the serial ports exist unless this is set, for receivers connected later
+	SkipDeviceCheck bool `json:"skip_device_check,omitempty"`
+
+	// Serve standalone gps positions while the correction source can't be read, e.g. a radio that
+	// isn't attached yet, instead of failing once it kept failing
+	AllowGPSOnly bool `json:"allow_gps_only,omitempty"`
+
+	// Name of a correction station on another robot to read corrections from instead of serial_correction_path
+	RemoteCorrectionStation string `json:"remote_correction_station,omitempty"`
+	// How often the remote station is polled for corrections, 200ms by default. Serial ports are
+	// read with blocking reads and don't poll.
+	PollIntervalMs int `json:"poll_interval_ms,omitempty"`
+
+	// A recorded rtcm file replayed as the correction source instead of serial_correction_path, for offline debugging
+	ReplayCorrectionFile string `json:"replay_correction_file,omitempty"`
+	ReplayLoop           bool   `json:"replay_loop,omitempty"`
+
+	// Added to every reported heading to correct for antennas mounted off the vehicle's forward axis
+	HeadingOffsetDegrees float64 `json:"heading_offset_degrees,omitempty"`
+
+	// Thresholds used to decide whether the rover is moving, see rtkutils.MotionThresholds
+	MovingSpeed      float64 `json:"moving_speed_mps,omitempty"`
+	StationarySpeed  float64 `json:"stationary_speed_mps,omitempty"`
+	MovingDistance   float64 `json:"moving_distance_m,omitempty"`
+	StationaryEpochs int     `json:"stationary_epochs,omitempty"`
+
+	// An overspeed event is raised above speed_limit_mps and cleared below speed_limit_clear_mps
+	SpeedLimit      float64 `json:"speed_limit_mps,omitempty"`
+	SpeedLimitClear float64 `json:"speed_limit_clear_mps,omitempty"`
+
+	// Fixes of the last track_minutes are kept for export_track, and appended to track_path if it is set
+	TrackMinutes float64 `json:"track_minutes,omitempty"`
+	TrackPath    string  `json:"track_path,omitempty"`
+
+	// Raw rover observations and base corrections are recorded here for ppk_solve, which runs the
+	// RTKLIB tools in rtklib_path, or those on the PATH without it
+	PPKRecordDir string `json:"ppk_record_dir,omitempty"`
+	RTKLIBPath   string `json:"rtklib_path,omitempty"`
+
+	// Raw sentences are captured to this file once parse failures exceed the rate per minute
+	ParseFailureLogPath string `json:"parse_failure_log_path,omitempty"`
+	ParseFailureRate    int    `json:"parse_failure_rate_per_min,omitempty"`
+
 	// TestChan is a fake "serial" path for test use only
 	TestChan chan []uint8 `json:"-"`
+
+	// warnings about deprecated keys found by convertAttributes
+	warnings []string
 }
 
 // ValidateSerial ensures all parts of the config are valid.
@@ -39,12 +118,80 @@ func (cfg *Config) Validate(path string) ([]string, error) {
 	if cfg.SerialNMEAPath == "" {
 		return nil, utils.NewConfigValidationFieldRequiredError(path, "serial_nmea_path")
 	}
-	if cfg.SerialCorrectionPath == "" {
+	if cfg.RemoteCorrectionStation != "" && cfg.ReplayCorrectionFile != "" {
+		return nil, fmt.Errorf("%s: only one of remote_correction_station and replay_correction_file can be set", path)
+	}
+	if cfg.RemoteCorrectionStation != "" {
+		deps = append(deps, cfg.RemoteCorrectionStation)
+	} else if cfg.ReplayCorrectionFile == "" && cfg.SerialCorrectionPath == "" {
 		return nil, utils.NewConfigValidationFieldRequiredError(path, "serial_correction_path")
 	}
+	readsPort := cfg.RemoteCorrectionStation == "" && cfg.ReplayCorrectionFile == ""
+	if readsPort && rtkutils.SameDevice(cfg.SerialNMEAPath, cfg.SerialCorrectionPath) {
+		return nil, fmt.Errorf("%s: serial_nmea_path and serial_correction_path are both %q, "+
+			"corrections are read from the port of the radio or station receiver", path, cfg.SerialNMEAPath)
+	}
+	if !cfg.SkipDeviceCheck {
+		if err := rtkutils.ValidateDevicePath("serial_nmea_path", cfg.SerialNMEAPath); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		if readsPort && !cfg.AllowGPSOnly {
+			if err := rtkutils.ValidateDevicePath("serial_correction_path", cfg.SerialCorrectionPath); err != nil {
+				return nil, fmt.Errorf("%s: %w", path, err)
+			}
+		}
+	}
+	if cfg.TrackPath != "" && cfg.TrackMinutes <= 0 {
+		return nil, utils.NewConfigValidationFieldRequiredError(path, "track_minutes")
+	}
+	if err := rtkutils.ValidatePPK(cfg.PPKRecordDir, cfg.RTKLIBPath); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if err := rtkutils.ValidateSpeedAlarm(cfg.SpeedLimit, cfg.SpeedLimitClear); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if err := cfg.motionThresholds().Validate(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if err := rtkutils.ValidatePollInterval(cfg.PollIntervalMs); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
 	return deps, nil
 }
 
+func (cfg *Config) motionThresholds() rtkutils.MotionThresholds {
+	return rtkutils.MotionThresholds{
+		MovingSpeed:      cfg.MovingSpeed,
+		StationarySpeed:  cfg.StationarySpeed,
+		MovingDistance:   cfg.MovingDistance,
+		StationaryEpochs: cfg.StationaryEpochs,
+	}
+}
+
+// configMigration maps the keys of old configs to the current ones.
+var configMigration = rtkutils.ConfigMigration{
+	Protocol: "serial",
+	Nested:   []string{"serial_attributes"},
+	Aliases: map[string]string{
+		"serial_path":      "serial_nmea_path",
+		"serial_baud_rate": "serial_nmea_baud_rate",
+	},
+}
+
+// convertAttributes converts the attributes of a config, migrating the keys of old configs.
+func convertAttributes(attributes rutils.AttributeMap) (*Config, error) {
+	migrated, warnings, err := configMigration.Migrate(attributes)
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := resource.TransformAttributeMap[*Config](migrated)
+	if err != nil {
+		return nil, err
+	}
+	cfg.warnings = warnings
+	return cfg, nil
+}
+
 func init() {
 	resource.RegisterComponent(
 		movementsensor.API,
@@ -60,8 +207,13 @@ func init() {
 				if err != nil {
 					return nil, err
 				}
+				for _, warning := range newConf.warnings {
+					logger.Warn(warning)
+				}
+				logger.Warnf("%s is deprecated, use viam-labs:movement-sensor:gps-rtk with a serial nmea_source instead", Model.Name)
 				return newrtkSerialNoNetwork(ctx, deps, conf.ResourceName(), newConf, logger)
 			},
+			AttributeMapConverter: convertAttributes,
 		})
 }
 
@@ -72,24 +224,50 @@ type rtkSerialNoNetwork struct {
 	logger     golog.Logger
 	cancelCtx  context.Context
 	cancelFunc func()
+	closed     rtkutils.CloseOnce
 
 	activeBackgroundWorkers sync.WaitGroup
 
-	err          movementsensor.LastError
+	err          *rtkutils.ErrorHistory
+	recovery     *rtkutils.Recovery
+	workers      *rtkutils.Supervisor
 	lastposition movementsensor.LastPosition
-
-	data   gpsnmea.GPSData
+	// when the last position was read from the receiver
+	lastPositionAt time.Time
+	lastPositionMu sync.Mutex
+
+	parseFailures *rtkutils.ParseFailureRecorder
+	sentences     rtkutils.SentenceStats // sentences read from the receiver by type
+	ppk           *rtkutils.PPKRecorder
+	track         *rtkutils.Track
+	events        *rtkutils.EventLog
+	speedAlarm    *rtkutils.SpeedAlarm
+
+	latest rtkutils.Snapshot // the latest complete nmea epoch
+	epochs rtkutils.EpochTracker
 	dataMu sync.RWMutex
 
+	headingOffset float64 // degrees added to every reported heading
+
 	correctionWriter   io.ReadWriteCloser
 	correctionReader   io.ReadCloser
 	correctionReaderMu sync.Mutex
+	rtcmFrames         rtkutils.Counter // frames forwarded to the gps
+	published          rtkutils.Counter // nmea epochs published
 
 	writePath     string
 	writeBaudRate int
 
 	readPath     string
 	readBaudRate int
+
+	remoteStation resource.Resource // correction station on another robot, read instead of readPath
+	replayPath    string            // recorded rtcm file replayed instead of readPath
+	replayLoop    bool
+
+	remotePollInterval time.Duration
+
+	allowGPSOnly bool // keep serving positions while corrections can't be read
 }
 
 func newrtkSerialNoNetwork(
@@ -100,15 +278,28 @@ func newrtkSerialNoNetwork(
 	logger golog.Logger,
 ) (movementsensor.MovementSensor, error) {
 
-	cancelCtx, cancelFunc := context.WithCancel(context.Background())
+	cancelCtx, cancelFunc := context.WithCancel(rtkutils.ModuleContext())
 	g := &rtkSerialNoNetwork{
 		Named:        name.AsNamed(),
 		cancelCtx:    cancelCtx,
 		cancelFunc:   cancelFunc,
 		logger:       logger,
-		err:          movementsensor.NewLastError(1, 1),
+		err:          rtkutils.NewErrorHistory(),
 		lastposition: movementsensor.NewLastPosition(),
+		parseFailures: rtkutils.NewParseFailureRecorder(
+			newConf.ParseFailureLogPath, newConf.ParseFailureRate, rtkutils.DefaultParseFailureLogSize),
+		headingOffset: newConf.HeadingOffsetDegrees,
+		events:        rtkutils.NewEventLog(logger),
+		speedAlarm:    rtkutils.NewSpeedAlarm(newConf.SpeedLimit, newConf.SpeedLimitClear),
+		allowGPSOnly:  newConf.AllowGPSOnly,
+	}
+	policy := recoveryPolicy
+	if g.allowGPSOnly {
+		policy = policy.WithOptional(classOpenCorrections, classReadCorrections)
 	}
+	g.recovery = rtkutils.NewRecovery(policy, g.err, logger)
+	g.workers = rtkutils.NewSupervisor(cancelCtx, &g.activeBackgroundWorkers, logger)
+	g.epochs.SetMotionThresholds(newConf.motionThresholds())
 
 	g.writePath = newConf.SerialNMEAPath
 	g.writeBaudRate = newConf.SerialNMEABaudRate
@@ -124,11 +315,41 @@ func newrtkSerialNoNetwork(
 		g.readBaudRate = 38400
 	}
 
+	if newConf.RemoteCorrectionStation != "" {
+		station, err := sensor.FromDependencies(deps, newConf.RemoteCorrectionStation)
+		if err != nil {
+			cancelFunc()
+			return nil, err
+		}
+		g.remoteStation = station
+		g.remotePollInterval = rtkutils.PollInterval(newConf.PollIntervalMs, rtkutils.DefaultRemotePollInterval)
+	}
+	g.replayPath, g.replayLoop = newConf.ReplayCorrectionFile, newConf.ReplayLoop
+
+	ppk, err := rtkutils.NewPPKRecorder(newConf.PPKRecordDir, newConf.RTKLIBPath)
+	if err != nil {
+		cancelFunc()
+		return nil, fmt.Errorf("failed to start ppk recording: %w", err)
+	}
+	g.ppk = ppk
+
+	track, err := rtkutils.NewTrack(time.Duration(newConf.TrackMinutes*float64(time.Minute)), newConf.TrackPath)
+	if err != nil {
+		cancelFunc()
+		g.ppk.Close()
+		return nil, fmt.Errorf("failed to open track file: %w", err)
+	}
+	g.track = track
+
 	if newConf.TestChan == nil {
 		if err := g.start(); err != nil {
+			cancelFunc()
+			g.ppk.Close()
+			g.track.Close()
 			return nil, err
 		}
 	}
+	rtkutils.TrackResource(g)
 	return g, g.err.Get()
 
 }
@@ -139,56 +360,105 @@ func (g *rtkSerialNoNetwork) start() error {
 		g.lastposition.GetLastPosition()
 		return err
 	}
-	g.activeBackgroundWorkers.Add(1)
-	utils.PanicCapturingGo(g.receiveAndWriteSerial)
+	if g.ppk != nil {
+		if err := rtkutils.EnableRawOutput(g.writeToReceiver); err != nil {
+			g.logger.Warnf("failed to enable raw measurement output for ppk: %s", err)
+		}
+	}
+
+	g.workers.Go("correction writer", g.receiveAndWriteSerial)
 
 	return g.err.Get()
 }
 
 // Start begins reading nmea messages from module and updates gps data.
 func (g *rtkSerialNoNetwork) startGPSNMEA(ctx context.Context) error {
-	g.activeBackgroundWorkers.Add(1)
-	utils.PanicCapturingGo(func() {
-		g.readNMEAMessages(ctx)
+	g.workers.Go("nmea reader", func() error {
+		return g.readNMEAMessages(ctx)
 	})
 
 	return g.err.Get()
 }
 
-func (g *rtkSerialNoNetwork) readNMEAMessages(ctx context.Context) {
-	defer g.activeBackgroundWorkers.Done()
-	r := bufio.NewReader(g.openNMEAPath())
+func (g *rtkSerialNoNetwork) readNMEAMessages(ctx context.Context) error {
+	for g.cancelCtx.Err() == nil {
+		port, err := g.openNMEAPath()
+		if err != nil {
+			if g.cancelCtx.Err() != nil || g.recovery.Handle(g.cancelCtx, classOpenNMEA, err) == rtkutils.Rebuild {
+				return rtkutils.ErrRebuildRequired
+			}
+			continue
+		}
+		g.recovery.Succeeded(classOpenNMEA)
+
+		err = g.readNMEAFrom(port)
+		if g.cancelCtx.Err() != nil {
+			// the port was closed on shutdown
+			return nil
+		}
+		switch g.recovery.Handle(g.cancelCtx, classReadNMEA, err) {
+		case rtkutils.Rebuild:
+			return rtkutils.ErrRebuildRequired
+		case rtkutils.Reopen:
+			g.closeNMEAPath(port)
+		}
+	}
+	return nil
+}
+
+// readNMEAFrom parses the nmea sentences read from port until reading fails.
+func (g *rtkSerialNoNetwork) readNMEAFrom(port io.Reader) error {
+	// with ppk recording the port also carries binary raw measurements, which are recorded as read
+	r := bufio.NewReader(io.TeeReader(port, g.ppk.Rover()))
 	for {
 		select {
 		case <-g.cancelCtx.Done():
-			return
+			return g.cancelCtx.Err()
 		default:
 		}
 
 		line, err := r.ReadString('\n')
 		if err != nil {
-			g.logger.Errorf("can't read gps serial %s", err)
-			g.err.Set(err)
-			return
+			return err
 		}
-		// Update our struct's gps data in-place
+		g.recovery.Succeeded(classReadNMEA)
+		if g.ppk != nil && !strings.Contains(line, "$G") {
+			continue
+		}
+		// Update the pending epoch and publish the previous one once it is complete
 		g.dataMu.Lock()
-		err = g.data.ParseAndUpdate(line)
+		snap, published, err := g.epochs.ParseAndUpdate(line)
+		if published {
+			g.latest = snap
+		}
 		g.dataMu.Unlock()
-		if err != nil {
-			g.logger.Warnf("can't parse nmea sentence: %#v", err)
+		if published {
+			g.onEpoch(snap)
+		}
+		g.sentences.Record(line, err)
+		switch {
+		case errors.Is(err, rtkutils.ErrUnsupportedSentence):
+			g.logger.Debugf("ignoring nmea sentence: %v", err)
+		case err != nil:
+			g.err.Record(rtkutils.ErrorParse, err)
+			g.logger.Warnf("can't parse nmea sentence: %v", g.parseFailures.Record(line, err))
 		}
 	}
 }
 
-func (g *rtkSerialNoNetwork) openNMEAPath() io.ReadWriteCloser {
+// openNMEAPath returns the handle of the nmea port, opening it if needed.
+func (g *rtkSerialNoNetwork) openNMEAPath() (io.ReadWriteCloser, error) {
+	g.correctionReaderMu.Lock()
+	defer g.correctionReaderMu.Unlock()
 
 	if err := g.cancelCtx.Err(); err != nil {
-		return nil
+		return nil, err
 	}
 
-	g.correctionReaderMu.Lock()
-	defer g.correctionReaderMu.Unlock()
+	// the nmea path is both read from and written to with corrections, so share a single handle
+	if g.correctionWriter != nil {
+		return g.correctionWriter, nil
+	}
 
 	options := slib.OpenOptions{
 		PortName:        g.writePath,
@@ -198,27 +468,45 @@ func (g *rtkSerialNoNetwork) openNMEAPath() io.ReadWriteCloser {
 		MinimumReadSize: 1,
 	}
 
-	var err error
-	g.correctionWriter, err = slib.Open(options)
+	port, err := slib.Open(options)
 	if err != nil {
-		g.logger.Errorf("serial.Open: %v", err)
-		g.err.Set(err)
-		return nil
+		return nil, err
 	}
-
-	return g.correctionWriter
-
+	g.correctionWriter = port
+	return port, nil
 }
 
-func (g *rtkSerialNoNetwork) openCorrectionReader() io.ReadCloser {
+// closeNMEAPath closes port if it is still the shared handle of the nmea port, so that the next
+// openNMEAPath reopens it.
+func (g *rtkSerialNoNetwork) closeNMEAPath(port io.ReadWriteCloser) {
+	g.correctionReaderMu.Lock()
+	defer g.correctionReaderMu.Unlock()
+	if g.correctionWriter != port {
+		return
+	}
+	if err := port.Close(); err != nil {
+		g.err.Record(rtkutils.ErrorSerial, err)
+	}
+	g.correctionWriter = nil
+}
 
-	if err := g.cancelCtx.Err(); err != nil {
-		return nil
+// openCorrectionSource opens the remote station, replay file or serial port corrections are read from.
+func (g *rtkSerialNoNetwork) openCorrectionSource() (io.ReadCloser, error) {
+	switch {
+	case g.remoteStation != nil:
+		return io.NopCloser(rtkutils.NewRemoteCorrectionReader(
+			g.cancelCtx, g.remoteStation, g.remotePollInterval, g.logger)), nil
+	case g.replayPath != "":
+		return rtkutils.NewRTCMReplayReader(g.cancelCtx, g.replayPath, g.replayLoop)
 	}
 
 	g.correctionReaderMu.Lock()
 	defer g.correctionReaderMu.Unlock()
 
+	if err := g.cancelCtx.Err(); err != nil {
+		return nil, err
+	}
+
 	options := slib.OpenOptions{
 		PortName:        g.readPath,
 		BaudRate:        uint(g.readBaudRate),
@@ -227,80 +515,169 @@ func (g *rtkSerialNoNetwork) openCorrectionReader() io.ReadCloser {
 		MinimumReadSize: 1,
 	}
 
-	var err error
-	g.correctionReader, err = slib.Open(options)
+	port, err := slib.Open(options)
 	if err != nil {
-		g.logger.Errorf("serial.Open: %v", err)
-		g.err.Set(err)
-		return nil
+		return nil, err
 	}
+	g.correctionReader = port
+	return port, nil
+}
 
-	return g.correctionReader
-
+// closeCorrectionSource closes a source returned by openCorrectionSource, unless Close already did.
+func (g *rtkSerialNoNetwork) closeCorrectionSource(reader io.ReadCloser) {
+	g.correctionReaderMu.Lock()
+	defer g.correctionReaderMu.Unlock()
+	if g.replayPath == "" && g.remoteStation == nil {
+		if g.correctionReader != reader {
+			return
+		}
+		g.correctionReader = nil
+	}
+	if err := reader.Close(); err != nil {
+		g.err.Record(rtkutils.ErrorSerial, err)
+	}
 }
 
 // Recieves correction data from the base station serial port and writes to the gpsrtk
-func (g *rtkSerialNoNetwork) receiveAndWriteSerial() {
-	defer g.activeBackgroundWorkers.Done()
-	if err := g.cancelCtx.Err(); err != nil {
-		return
-	}
+func (g *rtkSerialNoNetwork) receiveAndWriteSerial() error {
+	var reader io.ReadCloser
+	var frames *rtkutils.FrameReader
+	defer func() {
+		if reader != nil {
+			g.closeCorrectionSource(reader)
+		}
+	}()
 
-	reader := g.openCorrectionReader()
+	for g.cancelCtx.Err() == nil {
+		if reader == nil {
+			var err error
+			reader, err = g.openCorrectionSource()
+			if err != nil {
+				reader = nil
+				if g.cancelCtx.Err() != nil || g.recovery.Handle(g.cancelCtx, classOpenCorrections, err) == rtkutils.Rebuild {
+					return rtkutils.ErrRebuildRequired
+				}
+				continue
+			}
+			g.recovery.Succeeded(classOpenCorrections)
+			if frames == nil {
+				frames = rtkutils.NewFrameReader(reader)
+			} else {
+				frames.Reset(reader)
+			}
+		}
 
-	g.correctionWriter = g.openNMEAPath()
+		nmeaPort, err := g.openNMEAPath()
+		if err != nil {
+			if g.cancelCtx.Err() != nil || g.recovery.Handle(g.cancelCtx, classOpenNMEA, err) == rtkutils.Rebuild {
+				return rtkutils.ErrRebuildRequired
+			}
+			continue
+		}
+		g.recovery.Succeeded(classOpenNMEA)
 
-	writer := bufio.NewWriter(g.correctionWriter)
-	scanner := rtcm3.NewScanner(reader)
+		class, err := g.writeCorrections(frames, nmeaPort)
+		if g.cancelCtx.Err() != nil {
+			// the ports were closed on shutdown
+			return nil
+		}
+		if errors.Is(err, io.EOF) && g.replayPath != "" {
+			g.logger.Infof("finished replaying corrections from %s", g.replayPath)
+			return nil
+		}
+		switch g.recovery.Handle(g.cancelCtx, class, err) {
+		case rtkutils.Rebuild:
+			return rtkutils.ErrRebuildRequired
+		case rtkutils.Reopen:
+			if class == classReadCorrections {
+				g.closeCorrectionSource(reader)
+				reader = nil
+			} else {
+				g.closeNMEAPath(nmeaPort)
+			}
+		}
+	}
+	return nil
+}
 
+// writeCorrections forwards the rtcm frames of frames to the nmea port as they were read until
+// reading or writing fails, returning the class of the failure.
+func (g *rtkSerialNoNetwork) writeCorrections(frames *rtkutils.FrameReader, nmeaPort io.Writer) (rtkutils.ErrorClass, error) {
+	base := g.ppk.Base()
 	for {
 		select {
 		case <-g.cancelCtx.Done():
-			return
+			return classReadCorrections, g.cancelCtx.Err()
 		default:
 		}
 
-		msg, err := scanner.NextMessage()
-
-		switch msg.(type) {
-		case rtcm3.MessageUnknown:
-			continue
-		default:
-			frame := rtcm3.EncapsulateMessage(msg)
-			byteMsg := frame.Serialize()
-			writer.Write(byteMsg)
-			if err != nil {
-				g.logger.Errorf("Error writing RTCM message: %s", err)
-				g.err.Set(err)
-				return
-			}
-		}
+		frame, err := frames.Next()
 		if err != nil {
-			if msg == nil {
-				g.logger.Debug("No message... reconnecting to stream...")
-				scanner = rtcm3.NewScanner(reader)
-				continue
-			}
+			return classReadCorrections, err
+		}
+		g.recovery.Succeeded(classReadCorrections)
+
+		if _, err := nmeaPort.Write(frame); err != nil {
+			return classWriteCorrections, err
 		}
+		g.recovery.Succeeded(classWriteCorrections)
+		base.Write(frame)
+		g.rtcmFrames.Add(1)
 	}
+}
 
+// onEpoch handles every complete nmea epoch once it is published.
+func (g *rtkSerialNoNetwork) onEpoch(snap rtkutils.Snapshot) {
+	g.published.Add(1)
+	if err := g.track.Add(snap.Data, time.Now()); err != nil {
+		g.logger.Warnf("failed to write track file: %s", err)
+	}
+	g.speedAlarm.Update(snap.Data.Speed, g.events)
 }
 
-// Position returns the current geographic location of the MOVEMENTSENSOR.
+// Position returns the current geographic location of the MOVEMENTSENSOR. When it fails, it returns
+// the last known location, if any, along with an rtkutils.LastPositionError telling how old it is.
 func (g *rtkSerialNoNetwork) Position(ctx context.Context, extra map[string]interface{}) (*geo.Point, float64, error) {
-	lastError := g.err.Get()
+	pos, alt, err := g.currentPosition(extra)
+	if err != nil {
+		lastErr := g.lastPositionError(err)
+		return lastErr.Position, 0, lastErr
+	}
+	return pos, alt, nil
+}
+
+// lastPositionError returns err with the last position the rover knew.
+func (g *rtkSerialNoNetwork) lastPositionError(err error) *rtkutils.LastPositionError {
+	category := g.err.Category(err)
+	if errors.Is(err, errNilLocation) {
+		category = rtkutils.ErrorParse
+	}
+	g.lastPositionMu.Lock()
+	readAt := g.lastPositionAt
+	g.lastPositionMu.Unlock()
+	lastErr := &rtkutils.LastPositionError{Err: err, Category: category}
+	if last := g.lastposition.GetLastPosition(); last != nil && !readAt.IsZero() {
+		lastErr.Position, lastErr.Age = last, time.Since(readAt)
+	}
+	return lastErr
+}
+
+// currentPosition returns the position of the latest epoch.
+func (g *rtkSerialNoNetwork) currentPosition(extra map[string]interface{}) (*geo.Point, float64, error) {
 	lastPosition := g.lastposition.GetLastPosition()
-	if lastError != nil {
-		if lastPosition != nil {
-			return lastPosition, 0, nil
-		}
-		return geo.NewPoint(math.NaN(), math.NaN()), math.NaN(), lastError
+	if lastError := g.err.Get(); lastError != nil {
+		return lastPosition, 0, lastError
 	}
 
 	g.dataMu.RLock()
 	defer g.dataMu.RUnlock()
 
-	currentPosition := g.data.Location
+	snap, err := g.snapshot(extra)
+	if err != nil {
+		return lastPosition, 0, err
+	}
+
+	currentPosition := snap.Data.Location
 
 	if currentPosition == nil {
 		return lastPosition, 0, errNilLocation
@@ -308,7 +685,7 @@ func (g *rtkSerialNoNetwork) Position(ctx context.Context, extra map[string]inte
 
 	// if current position is (0,0) we will return the last non zero position
 	if g.lastposition.IsZeroPosition(currentPosition) && !g.lastposition.IsZeroPosition(lastPosition) {
-		return lastPosition, g.data.Alt, g.err.Get()
+		return lastPosition, snap.Data.Alt, g.err.Get()
 	}
 
 	// updating lastposition if it is different from the current position
@@ -319,9 +696,12 @@ func (g *rtkSerialNoNetwork) Position(ctx context.Context, extra map[string]inte
 	// updating the last known valid position if the current position is non-zero
 	if !g.lastposition.IsZeroPosition(currentPosition) && !g.lastposition.IsPositionNaN(currentPosition) {
 		g.lastposition.SetLastPosition(currentPosition)
+		g.lastPositionMu.Lock()
+		g.lastPositionAt = time.Now()
+		g.lastPositionMu.Unlock()
 	}
 
-	return currentPosition, g.data.Alt, g.err.Get()
+	return currentPosition, snap.Data.Alt, g.err.Get()
 }
 
 // LinearVelocity passthrough.
@@ -333,7 +713,11 @@ func (g *rtkSerialNoNetwork) LinearVelocity(ctx context.Context, extra map[strin
 
 	g.dataMu.RLock()
 	defer g.dataMu.RUnlock()
-	return r3.Vector{X: 0, Y: g.data.Speed, Z: 0}, g.err.Get()
+	snap, err := g.snapshot(extra)
+	if err != nil {
+		return r3.Vector{}, err
+	}
+	return r3.Vector{X: 0, Y: snap.Data.Speed, Z: 0}, g.err.Get()
 }
 
 // LinearAcceleration not supported.
@@ -351,11 +735,25 @@ func (g *rtkSerialNoNetwork) AngularVelocity(ctx context.Context, extra map[stri
 	return spatialmath.AngularVelocity{}, movementsensor.ErrMethodUnimplementedAngularVelocity
 }
 
-// CompassHeading not supported.
+// CompassHeading returns the dual antenna heading, or the course over ground when there is none,
+// corrected by the configured heading offset. It is NaN if no heading was reported in the epoch.
 func (g *rtkSerialNoNetwork) CompassHeading(ctx context.Context, extra map[string]interface{}) (float64, error) {
+	lastError := g.err.Get()
+	if lastError != nil {
+		return math.NaN(), lastError
+	}
+
 	g.dataMu.RLock()
 	defer g.dataMu.RUnlock()
-	return 0, movementsensor.ErrMethodUnimplementedCompassHeading
+	snap, err := g.snapshot(extra)
+	if err != nil {
+		return math.NaN(), err
+	}
+	heading := snap.Heading
+	if !heading.Valid {
+		return math.NaN(), g.err.Get()
+	}
+	return rtkutils.NormalizeHeading(heading.Degrees + g.headingOffset), g.err.Get()
 }
 
 // Orientation not supported.
@@ -373,7 +771,7 @@ func (g *rtkSerialNoNetwork) readFix(ctx context.Context) (int, error) {
 	}
 	g.dataMu.RLock()
 	defer g.dataMu.RUnlock()
-	return g.data.FixQuality, g.err.Get()
+	return g.latest.Data.FixQuality, g.err.Get()
 }
 
 // Properties passthrough.
@@ -381,10 +779,11 @@ func (g *rtkSerialNoNetwork) Properties(ctx context.Context, extra map[string]in
 	return &movementsensor.Properties{
 		LinearVelocitySupported: true,
 		PositionSupported:       true,
+		CompassHeadingSupported: true,
 	}, nil
 }
 
-// Accuracy passthrough.
+// Accuracy returns the accuracy of the epoch, see rtkutils.EpochAccuracy.
 func (g *rtkSerialNoNetwork) Accuracy(ctx context.Context, extra map[string]interface{}) (map[string]float32, error) {
 	lastError := g.err.Get()
 	if lastError != nil {
@@ -393,43 +792,221 @@ func (g *rtkSerialNoNetwork) Accuracy(ctx context.Context, extra map[string]inte
 
 	g.dataMu.RLock()
 	defer g.dataMu.RUnlock()
-	return map[string]float32{"hDOP": float32(g.data.HDOP), "vDOP": float32(g.data.VDOP)}, g.err.Get()
+	snap, err := g.snapshot(extra)
+	if err != nil {
+		return map[string]float32{}, err
+	}
+	return rtkutils.EpochAccuracy(snap), g.err.Get()
+}
+
+// snapshot returns the epoch pinned in extra, or the latest epoch if none is pinned.
+// The caller must hold dataMu.
+func (g *rtkSerialNoNetwork) snapshot(extra map[string]interface{}) (rtkutils.Snapshot, error) {
+	epoch, pinned, err := rtkutils.RequestedEpoch(extra)
+	if err != nil || !pinned || epoch == g.latest.Epoch {
+		return g.latest, err
+	}
+	return g.epochs.Get(epoch)
 }
 
-// Readings will use the MovementSensor Readings
+// Readings will use the MovementSensor Readings, with every value taken from the same nmea epoch.
 func (g *rtkSerialNoNetwork) Readings(ctx context.Context, extra map[string]interface{}) (map[string]interface{}, error) {
-	readings := make(map[string]interface{})
+	// without an epoch or a device, or after a fatal error, there is nothing to read but the state
+	state := g.state()
+	if state == rtkutils.StateInitializing || state == rtkutils.StateWaitingForDevice || state == rtkutils.StateError {
+		return map[string]interface{}{rtkutils.StateKey: state}, nil
+	}
+
+	g.dataMu.RLock()
+	extra = rtkutils.PinEpoch(extra, g.latest.Epoch)
+	g.dataMu.RUnlock()
+
+	readings, err := movementsensor.Readings(ctx, g, extra)
+	if err != nil {
+		return nil, err
+	}
+	readings[rtkutils.EpochKey] = extra[rtkutils.EpochKey]
+
+	g.dataMu.RLock()
+	snap, err := g.snapshot(extra)
+	g.dataMu.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+	readings["moving"] = snap.Moving
+	readings[rtkutils.StateKey] = state
+	readings[rtkutils.RestartsKey] = g.workers.Restarts()
+	if g.speedAlarm != nil {
+		readings["overspeed"] = g.speedAlarm.Overspeed()
+	}
+
 	return readings, nil
 }
 
+// state returns the state of the rover for its readings.
+func (g *rtkSerialNoNetwork) state() string {
+	g.dataMu.RLock()
+	fixQuality := g.latest.Data.FixQuality
+	g.dataMu.RUnlock()
+	now := time.Now()
+	state := rtkutils.RoverState(g.err, &g.published, fixQuality, now)
+	if g.allowGPSOnly {
+		state = rtkutils.GPSOnlyState(state, &g.rtcmFrames, now)
+	}
+	return rtkutils.DeviceState(state, g.recovery)
+}
+
+// DoCommand runs the commands supported by the rover.
+func (g *rtkSerialNoNetwork) DoCommand(ctx context.Context, cmd map[string]interface{}) (map[string]interface{}, error) {
+	switch cmd[rtkutils.CommandKey] {
+	case rtkutils.SelfTestCommand:
+		return g.selfTest(ctx, rtkutils.SelfTestTimeout(cmd)), nil
+	case rtkutils.ProvisionCommand:
+		return g.provision(ctx, cmd)
+	case rtkutils.SendSentenceCommand:
+		return rtkutils.SendSentence(cmd, g.writeToReceiver)
+	case rtkutils.PPKSolveCommand:
+		return rtkutils.PPKSolveResult(ctx, g.ppk)
+	case rtkutils.ExportTrackCommand:
+		return rtkutils.ExportTrackResult(g.track, cmd)
+	case rtkutils.EventsCommand:
+		return g.events.EventsResult(cmd), nil
+	case rtkutils.ErrorsCommand:
+		return g.err.ErrorsResult(cmd), nil
+	case rtkutils.SentenceStatsCommand:
+		return rtkutils.SentenceStatsResult(g.sentences.Get()), nil
+	case rtkutils.VersionCommand:
+		return rtkutils.VersionResult(), nil
+	default:
+		return nil, resource.ErrDoUnimplemented
+	}
+}
+
+// selfTest checks that both ports are open and that nmea sentences and rtcm corrections are flowing.
+func (g *rtkSerialNoNetwork) selfTest(ctx context.Context, timeout time.Duration) map[string]interface{} {
+	report := rtkutils.NewReport()
+
+	g.correctionReaderMu.Lock()
+	nmeaOpen, correctionOpen := g.correctionWriter != nil, g.correctionReader != nil
+	g.correctionReaderMu.Unlock()
+
+	var err error
+	if !nmeaOpen {
+		err = fmt.Errorf("nmea port %s is not open", g.writePath)
+	}
+	report.Check("nmea_port_open", err)
+
+	switch {
+	case g.remoteStation != nil:
+		report.Check("remote_correction_station", rtkutils.CheckRemoteStation(ctx, g.remoteStation))
+	case g.replayPath != "":
+		_, err = os.Stat(g.replayPath)
+		report.Check("correction_replay_file", err)
+	default:
+		err = nil
+		if !correctionOpen {
+			err = fmt.Errorf("correction port %s is not open", g.readPath)
+		}
+		report.Check("correction_port_open", err)
+	}
+
+	report.Check("nmea_sentences", rtkutils.WaitForIncrease(ctx, timeout, "complete nmea epoch", g.currentEpoch))
+	report.Check("rtcm_frames", rtkutils.WaitForIncrease(ctx, timeout, "rtcm frames forwarded", g.rtcmFrames.Get))
+
+	return report.Result()
+}
+
+// provision enables rtcm input on the receiver port the radio is wired to (uart2 by default),
+// saves the configuration and waits for an rtk fix.
+func (g *rtkSerialNoNetwork) provision(ctx context.Context, cmd map[string]interface{}) (map[string]interface{}, error) {
+	port, err := rtkutils.UBXPortArg(cmd, "rtcm_input_port", rtkutils.UBXPortUART2)
+	if err != nil {
+		return nil, err
+	}
+	baudRate := rtkutils.IntArg(cmd, "baud_rate", 38400)
+
+	report := rtkutils.NewReport()
+	report.Check("enable_rtcm_input", g.writeToReceiver(rtkutils.UBXPortConfigPacket(port, baudRate,
+		rtkutils.UBXProtoUBX|rtkutils.UBXProtoNMEA|rtkutils.UBXProtoRTCM3, rtkutils.UBXProtoUBX|rtkutils.UBXProtoNMEA)))
+	report.Check("save_config", g.writeToReceiver(rtkutils.UBXSaveConfigPacket()))
+	if report.Passed() {
+		timeout := rtkutils.DurationArg(cmd, "fix_timeout_sec", defaultProvisionFixTimeout)
+		report.Check("rtk_fix", rtkutils.WaitFor(ctx, timeout, "rtk fix", g.hasRTKFix))
+	}
+
+	return report.Result(), nil
+}
+
+// writeToReceiver writes a raw message to the receiver over the nmea port.
+func (g *rtkSerialNoNetwork) writeToReceiver(msg []byte) error {
+	port, err := g.openNMEAPath()
+	if err != nil {
+		return fmt.Errorf("nmea port %s is not open: %w", g.writePath, err)
+	}
+	_, err = port.Write(msg)
+	return err
+}
+
+func (g *rtkSerialNoNetwork) hasRTKFix() bool {
+	g.dataMu.RLock()
+	defer g.dataMu.RUnlock()
+	return rtkutils.IsRTKFix(g.latest.Data.FixQuality)
+}
+
+func (g *rtkSerialNoNetwork) currentEpoch() uint64 {
+	g.dataMu.RLock()
+	defer g.dataMu.RUnlock()
+	return g.latest.Epoch
+}
+
 // Close shuts down the RTKSerialNoNetwork.
 func (g *rtkSerialNoNetwork) Close(ctx context.Context) error {
+	return g.closed.Close(func() error { return g.close(ctx) })
+}
+
+func (g *rtkSerialNoNetwork) close(ctx context.Context) error {
+	rtkutils.UntrackResource(g)
 	g.cancelFunc()
-	g.activeBackgroundWorkers.Wait()
 
+	// close the ports before waiting on the workers so reads blocked on them can return.
 	g.correctionReaderMu.Lock()
 
 	// close the reader.
 	if g.correctionReader != nil {
 		if err := g.correctionReader.Close(); err != nil {
-			g.correctionReaderMu.Unlock()
-			g.err.Set(err)
+			g.err.Set(rtkutils.ErrorSerial, err)
 			g.logger.Errorf("failed to close correction reader %s", err)
 		}
 		g.correctionReader = nil
 	}
 
-	g.correctionReaderMu.Unlock()
-
 	// close the writer.
 	if g.correctionWriter != nil {
 		if err := g.correctionWriter.Close(); err != nil {
-			g.err.Set(err)
+			g.err.Set(rtkutils.ErrorSerial, err)
 			g.logger.Errorf("failed to close correction writer %s", err)
 		}
 		g.correctionWriter = nil
 	}
 
+	g.correctionReaderMu.Unlock()
+
+	if !rtkutils.WaitWithTimeout(ctx, &g.activeBackgroundWorkers, rtkutils.DefaultShutdownTimeout) {
+		g.logger.Warn("timed out waiting for background workers to stop")
+	}
+
+	if err := g.parseFailures.Close(); err != nil {
+		g.logger.Errorf("failed to close parse failure log %s", err)
+	}
+
+	if err := g.track.Close(); err != nil {
+		g.logger.Errorf("failed to close track file: %s", err)
+	}
+
+	if err := g.ppk.Close(); err != nil {
+		g.logger.Errorf("failed to close ppk recording %s", err)
+	}
+
 	if err := g.err.Get(); err != nil && !errors.Is(err, context.Canceled) {
 		return err
 	}
@@ -2,7 +2,9 @@ package gpsrtkserialnonetwork
 
 import (
 	"context"
+	"errors"
 	"io"
+	"os"
 	"strings"
 	"testing"
 
@@ -14,6 +16,8 @@ import (
 	"go.viam.com/rdk/resource"
 	"go.viam.com/test"
 	"go.viam.com/utils"
+
+	rtkutils "rtksystem/rtk-utils"
 )
 
 const nmeaPath = "nmea-path"
@@ -42,7 +46,43 @@ func TestValidate(t *testing.T) {
 			name: "A valid config should result in no errors",
 			config: &Config{
 				SerialNMEAPath:       nmeaPath,
-				SerialCorrectionPath: correctionPath},
+				SerialCorrectionPath: correctionPath,
+				SkipDeviceCheck:      true,
+			},
+		},
+		{
+			name: "a config with the same nmea and correction paths should result in error",
+			config: &Config{
+				SerialNMEAPath:       nmeaPath,
+				SerialCorrectionPath: "./" + nmeaPath,
+				SkipDeviceCheck:      true,
+			},
+			expectedErr: errors.New("path: serial_nmea_path and serial_correction_path are both \"nmea-path\", " +
+				"corrections are read from the port of the radio or station receiver"),
+		},
+		{
+			name: "a config with a serial path that doesn't exist should result in error",
+			config: &Config{
+				SerialNMEAPath:       nmeaPath,
+				SerialCorrectionPath: correctionPath,
+			},
+			expectedErr: errors.New("path: serial_nmea_path \"nmea-path\" doesn't exist, check that the device is connected, or set skip_device_check if it is only connected later"),
+		},
+		{
+			name: "a config with a correction path that doesn't exist should result in error",
+			config: &Config{
+				SerialNMEAPath:       os.DevNull,
+				SerialCorrectionPath: correctionPath,
+			},
+			expectedErr: errors.New("path: serial_correction_path \"corr-path\" doesn't exist, check that the device is connected, or set skip_device_check if it is only connected later"),
+		},
+		{
+			name: "a gps only config doesn't need the correction path to exist",
+			config: &Config{
+				SerialNMEAPath:       os.DevNull,
+				SerialCorrectionPath: correctionPath,
+				AllowGPSOnly:         true,
+			},
 		},
 		{
 			name: "a config with no serial_nmea_path should result in error",
@@ -120,7 +160,8 @@ func TestPosition(t *testing.T) {
 	var testRTK = &rtkSerialNoNetwork{
 		logger:    logger,
 		cancelCtx: ctx,
-		data:      mockGPSData,
+		latest:    rtkutils.Snapshot{Data: mockGPSData},
+		err:       rtkutils.NewErrorHistory(),
 	}
 
 	lastPostion := movementsensor.LastPosition{}
@@ -156,13 +197,17 @@ func TestPosition(t *testing.T) {
 
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
-			testRTK.data.Location = tc.location
+			testRTK.latest.Data.Location = tc.location
 			location, alt, err := testRTK.Position(ctx, nil)
 			if tc.expectedErr == nil {
 				test.That(t, err, test.ShouldBeNil)
 				test.That(t, alt, test.ShouldEqual, mockGPSData.Alt)
 			} else {
-				test.That(t, err, test.ShouldBeError, tc.expectedErr)
+				var lastErr *rtkutils.LastPositionError
+				test.That(t, errors.As(err, &lastErr), test.ShouldBeTrue)
+				test.That(t, lastErr.Err, test.ShouldEqual, tc.expectedErr)
+				test.That(t, lastErr.Category, test.ShouldEqual, rtkutils.ErrorParse)
+				test.That(t, lastErr.Position, test.ShouldEqual, location)
 				test.That(t, alt, test.ShouldEqual, 0)
 			}
 			if tc.validLocation {
@@ -173,6 +218,18 @@ func TestPosition(t *testing.T) {
 
 		})
 	}
+
+	// a failed read returns the last known position along with the error
+	readErr := errors.New("read failed")
+	testRTK.err.Set(rtkutils.ErrorSerial, readErr)
+	location, alt, err := testRTK.Position(ctx, nil)
+	var lastErr *rtkutils.LastPositionError
+	test.That(t, errors.As(err, &lastErr), test.ShouldBeTrue)
+	test.That(t, errors.Is(err, readErr), test.ShouldBeTrue)
+	test.That(t, lastErr.Category, test.ShouldEqual, rtkutils.ErrorSerial)
+	test.That(t, location, test.ShouldResemble, geo.NewPoint(0.01, 0.001))
+	test.That(t, lastErr.Position, test.ShouldEqual, location)
+	test.That(t, alt, test.ShouldEqual, 0)
 }
 
 func TestLinearVelocity(t *testing.T) {
@@ -182,7 +239,8 @@ func TestLinearVelocity(t *testing.T) {
 	testRTK := &rtkSerialNoNetwork{
 		logger:    logger,
 		cancelCtx: ctx,
-		data:      mockGPSData,
+		latest:    rtkutils.Snapshot{Data: mockGPSData},
+		err:       rtkutils.NewErrorHistory(),
 	}
 
 	linearVel, err := testRTK.LinearVelocity(ctx, nil)
@@ -201,7 +259,8 @@ func TestLinearAcceleration(t *testing.T) {
 	testRTK := &rtkSerialNoNetwork{
 		logger:    logger,
 		cancelCtx: ctx,
-		data:      mockGPSData,
+		latest:    rtkutils.Snapshot{Data: mockGPSData},
+		err:       rtkutils.NewErrorHistory(),
 	}
 
 	linearAcc, err := testRTK.LinearAcceleration(ctx, nil)
@@ -216,7 +275,8 @@ func TestReadFix(t *testing.T) {
 	testRTK := &rtkSerialNoNetwork{
 		logger:    logger,
 		cancelCtx: ctx,
-		data:      mockGPSData,
+		latest:    rtkutils.Snapshot{Data: mockGPSData},
+		err:       rtkutils.NewErrorHistory(),
 	}
 
 	fix, err := testRTK.readFix(ctx)
@@ -235,8 +295,8 @@ func TestClose(t *testing.T) {
 		logger:           logger,
 		cancelCtx:        cancelCtx,
 		cancelFunc:       cancelFunc,
-		data:             mockGPSData,
-		err:              movementsensor.NewLastError(1, 1),
+		latest:           rtkutils.Snapshot{Data: mockGPSData},
+		err:              rtkutils.NewErrorHistory(),
 		correctionReader: r,
 		correctionWriter: w,
 	}
@@ -244,4 +304,7 @@ func TestClose(t *testing.T) {
 	err := testRTK.Close(cancelCtx)
 	test.That(t, err, test.ShouldBeNil)
 	test.That(t, testRTK.correctionReader, test.ShouldBeNil)
+
+	// the module shuts it down and the robot closes it too
+	test.That(t, testRTK.Close(cancelCtx), test.ShouldBeNil)
 }
@@ -0,0 +1,420 @@
+package gpsrtk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	geo "github.com/kellydunn/golang-geo"
+	"go.viam.com/rdk/resource"
+
+	rtkutils "rtksystem/rtk-utils"
+)
+
+// DoCommand runs the commands supported by the rover.
+func (g *gpsRTK) DoCommand(ctx context.Context, cmd map[string]interface{}) (map[string]interface{}, error) {
+	switch cmd[rtkutils.CommandKey] {
+	case rtkutils.SelfTestCommand:
+		return g.selfTest(ctx, rtkutils.SelfTestTimeout(cmd)), nil
+	case rtkutils.ProvisionCommand:
+		return g.provision(ctx, cmd)
+	case rtkutils.CheckConfigCommand:
+		return g.checkConfig(ctx, cmd)
+	case rtkutils.SendSentenceCommand:
+		return rtkutils.SendSentence(cmd, g.writeToReceiver)
+	case rtkutils.PPKSolveCommand:
+		return rtkutils.PPKSolveResult(ctx, g.ppk)
+	case rtkutils.ExportTrackCommand:
+		if err := g.privacy.Authorized(cmd); err != nil {
+			return nil, err
+		}
+		return rtkutils.ExportTrackResult(g.track, cmd)
+	case rtkutils.ExportCoverageCommand:
+		if err := g.privacy.Authorized(cmd); err != nil {
+			return nil, err
+		}
+		return rtkutils.ExportCoverageResult(g.coverage)
+	case rtkutils.PrecisePositionCommand:
+		return g.precisePositionResult(cmd)
+	case rtkutils.CalibrateOffsetCommand:
+		return g.calibrateOffset(ctx, cmd)
+	case rtkutils.PropertiesCommand:
+		return g.propertiesResult(ctx)
+	case rtkutils.PowerCycleCommand:
+		return g.powerCycle(ctx)
+	case rtkutils.PauseCorrectionsCommand:
+		g.paused.Pause(rtkutils.DurationArg(cmd, "duration_sec", 0), time.Now(), g.events)
+		return g.paused.Result(time.Now()), nil
+	case rtkutils.ResumeCorrectionsCommand:
+		g.paused.Resume(time.Now(), g.events)
+		return g.paused.Result(time.Now()), nil
+	case rtkutils.SaveReceiverConfigCommand:
+		return g.saveReceiverConfig(), nil
+	case rtkutils.RawObservablesCommand:
+		return g.raw.RawObservablesResult(cmd, g.writeToReceiver, g.ppk != nil)
+	case rtkutils.CurrentPositionCommand:
+		return g.hold.CurrentPositionResult(g.privacy)
+	case rtkutils.NavSatFixCommand:
+		return g.navSatFixResult(cmd), nil
+	case rtkutils.ClockCommand:
+		return g.clock.ClockResult(cmd)
+	case rtkutils.TimingCommand:
+		return rtkutils.TimingResult(g.nmeaLatency.Summary(), g.correctionQueue.Latency()), nil
+	case rtkutils.InterlockCommand:
+		return rtkutils.InterlockResult(g.interlock()), nil
+	case rtkutils.EventsCommand:
+		return g.events.EventsResult(cmd), nil
+	case rtkutils.ErrorsCommand:
+		return g.err.ErrorsResult(cmd), nil
+	case rtkutils.SentenceStatsCommand:
+		return rtkutils.SentenceStatsResult(g.sentences.Get()), nil
+	case rtkutils.DecodeRTCMCommand:
+		return rtkutils.DecodeRTCMResult(&g.recentFrames, cmd), nil
+	case rtkutils.VerifyPairCommand:
+		return g.verifyPair(ctx, cmd)
+	case rtkutils.OutagesCommand:
+		return g.outages.OutagesResult(g.privacy, time.Now()), nil
+	case rtkutils.RTCMStationsCommand:
+		return g.rtcmStations.StationsResult(time.Now()), nil
+	case rtkutils.VersionCommand:
+		return rtkutils.VersionResult(), nil
+	case rtkutils.EffectiveConfigCommand:
+		return rtkutils.RedactConfig(g.conf.effective(g.profile))
+	default:
+		return nil, resource.ErrDoUnimplemented
+	}
+}
+
+// verifyPair answers a VerifyPairCommand. What the station sends is read from a remote correction
+// station, or from the "station" argument, the result of the station's decode_rtcm, for others.
+func (g *gpsRTK) verifyPair(ctx context.Context, cmd map[string]interface{}) (map[string]interface{}, error) {
+	station, _ := g.stations.Station()
+	check := rtkutils.PairCheck{
+		Received:         g.recentFrames.Content(),
+		Tracked:          rtkutils.TrackedConstellations(g.sentences.Get()),
+		ReferenceStation: station,
+	}
+	decoded, ok := cmd["station"].(map[string]interface{})
+	if !ok && g.remoteStation != nil {
+		var err error
+		decoded, err = g.remoteStation.DoCommand(ctx, map[string]interface{}{
+			rtkutils.CommandKey: rtkutils.DecodeRTCMCommand,
+			"count":             float64(rtkutils.RTCMBufferSize),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("can't read the rtcm of correction station %s: %w", g.remoteStation.Name(), err)
+		}
+	}
+	if decoded != nil {
+		content := rtkutils.RTCMContentOf(decoded)
+		check.Station = &content
+	}
+	return rtkutils.VerifyPair(check), nil
+}
+
+// powerCycle answers a PowerCycleCommand.
+func (g *gpsRTK) powerCycle(ctx context.Context) (map[string]interface{}, error) {
+	if err := g.power.Cycle(ctx); err != nil {
+		return nil, err
+	}
+	g.events.Add(rtkutils.EventReceiverPowerCycled, "power cycled on request")
+	return map[string]interface{}{"power_cycles": g.power.Cycles()}, nil
+}
+
+// propertiesResult answers a PropertiesCommand with the Properties of the rover, where its compass
+// heading can come from and where the current one does, and what its receiver supports.
+func (g *gpsRTK) propertiesResult(ctx context.Context) (map[string]interface{}, error) {
+	props, err := g.Properties(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	g.dataMu.RLock()
+	heading := g.latest.Heading
+	g.dataMu.RUnlock()
+	_, source := g.receiverHeading(heading)
+	if source == "" {
+		_, source = g.heading.Heading(ctx)
+	}
+	sources := []interface{}{}
+	for _, s := range g.heading.Sources() {
+		sources = append(sources, s)
+	}
+	return map[string]interface{}{
+		"position_supported":            props.PositionSupported,
+		"linear_velocity_supported":     props.LinearVelocitySupported,
+		"compass_heading_supported":     props.CompassHeadingSupported,
+		"orientation_supported":         props.OrientationSupported,
+		"angular_velocity_supported":    props.AngularVelocitySupported,
+		"linear_acceleration_supported": props.LinearAccelerationSupported,
+		"heading_sources":               sources,
+		"heading_source":                source,
+		"receiver":                      g.profile.Name,
+		"rtk":                           g.profile.RTK,
+		"reduced_position":              g.privacy != nil,
+	}, nil
+}
+
+// precisePositionResult answers a PrecisePositionCommand.
+func (g *gpsRTK) precisePositionResult(cmd map[string]interface{}) (map[string]interface{}, error) {
+	if err := g.privacy.Authorized(cmd); err != nil {
+		return nil, err
+	}
+	pos, alt, err := g.precisePosition(cmd)
+	if err != nil {
+		return nil, err
+	}
+	pos, alt = g.robotFrame.ToRobot(pos, alt, time.Now())
+	pos = g.offset.Apply(g.datum.Transform(pos, alt, time.Now()))
+	return map[string]interface{}{"lat": pos.Lat(), "lng": pos.Lng(), "alt": alt}, nil
+}
+
+// navSatFixResult answers a NavSatFixCommand with the latest epoch as the rover reports it, in the
+// frame_id of the command or named after the rover.
+func (g *gpsRTK) navSatFixResult(cmd map[string]interface{}) map[string]interface{} {
+	frameID, ok := cmd["frame_id"].(string)
+	if !ok || frameID == "" {
+		frameID = g.Name().Name
+	}
+	g.dataMu.RLock()
+	snap := g.latest
+	g.dataMu.RUnlock()
+	accuracy := rtkutils.EpochAccuracy(snap)
+	if nav, ok := g.nav.Accuracy(time.Now()); ok {
+		nav.Add(accuracy)
+	}
+	var pos *geo.Point
+	var alt float64
+	if snap.Data.FixQuality != 0 && snap.Data.Location != nil {
+		pos, alt = g.reported(snap.Data.Location, snap.Data.Alt)
+	}
+	return rtkutils.NavSatFix(snap, pos, alt, accuracy, frameID, time.Now())
+}
+
+// calibrateOffset answers a CalibrateOffsetCommand, averaging the positions of the next "epochs"
+// rtk fixed epochs, in the output datum, to measure the offset to the known point.
+func (g *gpsRTK) calibrateOffset(ctx context.Context, cmd map[string]interface{}) (map[string]interface{}, error) {
+	// the known and measured points reveal where the rover is
+	if err := g.privacy.Authorized(cmd); err != nil {
+		return nil, err
+	}
+	if clear, _ := cmd["clear"].(bool); clear {
+		g.offset.Clear()
+		g.events.Add(rtkutils.EventOffsetCalibrated, "position offset cleared")
+		return g.offset.Result(), nil
+	}
+	known, err := rtkutils.CalibrationPoint(cmd)
+	if err != nil {
+		return nil, err
+	}
+	epochs := rtkutils.IntArg(cmd, "epochs", rtkutils.DefaultCalibrationEpochs)
+	timeout := rtkutils.DurationArg(cmd, "epoch_timeout_sec", rtkutils.DefaultCalibrationEpochTimeout)
+	var measured []*geo.Point
+	for len(measured) < epochs {
+		if err := rtkutils.WaitForIncrease(ctx, timeout, "nmea epoch", g.published.Get); err != nil {
+			return nil, err
+		}
+		g.dataMu.RLock()
+		data := g.latest.Data
+		g.dataMu.RUnlock()
+		if !rtkutils.IsRTKFix(data.FixQuality) || data.Location == nil {
+			return nil, errors.New("calibrate_offset needs an rtk fixed position, wait for a fix and try again")
+		}
+		pos, alt := g.robotFrame.ToRobot(data.Location, data.Alt, time.Now())
+		measured = append(measured, g.datum.Transform(pos, alt, time.Now()))
+	}
+	if err := g.offset.Calibrate(known, measured, time.Now()); err != nil {
+		return nil, err
+	}
+	result := g.offset.Result()
+	g.events.Add(rtkutils.EventOffsetCalibrated, "position offset calibrated to %.3f m north and %.3f m east",
+		result["offset_north_m"], result["offset_east_m"])
+	return result, nil
+}
+
+// selfTest checks that the receiver and the correction source are reachable, and that nmea
+// sentences and rtcm corrections are flowing.
+func (g *gpsRTK) selfTest(ctx context.Context, timeout time.Duration) map[string]interface{} {
+	report := rtkutils.NewReport()
+
+	g.portsMu.Lock()
+	receiverOpen, correctionsOpen := g.receiver != nil, g.corrections != nil
+	correctionPortOpen := g.correctionPort != nil
+	g.portsMu.Unlock()
+
+	nmea := g.conf.NMEASource
+	if nmea.Transport == TransportI2C {
+		report.Check("nmea_i2c_addr_ack", rtkutils.CheckI2CAck(byte(nmea.I2CAddr), nmea.I2CBus))
+	} else {
+		var err error
+		if !receiverOpen {
+			err = fmt.Errorf("nmea port %s is not open", nmea.SerialPath)
+		}
+		report.Check("nmea_port_open", err)
+	}
+	switch nmea.CorrectionTransport {
+	case TransportI2C:
+		report.Check("receiver_correction_i2c_addr_ack", rtkutils.CheckI2CAck(byte(nmea.CorrectionI2CAddr), g.conf.correctionPortBus()))
+	case TransportSerial:
+		var err error
+		if !correctionPortOpen {
+			err = fmt.Errorf("receiver correction port %s is not open", nmea.CorrectionSerialPath)
+		}
+		report.Check("receiver_correction_port_open", err)
+	}
+
+	source := g.conf.CorrectionSource
+	switch source.Transport {
+	case "":
+		// no correction source with a ppp service
+	case TransportRemote:
+		report.Check("remote_correction_station", rtkutils.CheckRemoteStation(ctx, g.remoteStation))
+	case TransportReplay:
+		_, err := os.Stat(source.ReplayFile)
+		report.Check("correction_replay_file", err)
+	case TransportI2C:
+		report.Check("rtcm_i2c_addr_ack", rtkutils.CheckI2CAck(byte(source.I2CAddr), g.conf.correctionBus()))
+	default:
+		var err error
+		if !correctionsOpen {
+			err = fmt.Errorf("%s correction source is not open", source.Transport)
+		}
+		report.Check("correction_source_open", err)
+	}
+
+	if len(g.profile.InitPackets)+len(g.profile.InitSentences) > 0 {
+		report.Check("receiver_config_write", g.sendInit())
+	} else if nmea.Transport == TransportI2C {
+		report.Check("receiver_config_write", g.configureReceiver())
+	}
+	report.Check("nmea_sentences", rtkutils.WaitForIncrease(ctx, timeout, "complete nmea epoch", g.currentEpoch))
+	if source.Transport != "" {
+		report.Check("rtcm_frames", rtkutils.WaitForIncrease(ctx, timeout, "rtcm frames forwarded", g.rtcmFrames.Get))
+	}
+
+	return report.Result()
+}
+
+// checkConfig checks a proposed config against the hardware without applying it: it is validated
+// with its device checks, its i2c addresses have to acknowledge and its serial ports are listened to.
+// Ports the rover has open are checked by what it reads from them instead.
+func (g *gpsRTK) checkConfig(ctx context.Context, cmd map[string]interface{}) (map[string]interface{}, error) {
+	attrs, err := rtkutils.ConfigArg(cmd)
+	if err != nil {
+		return nil, err
+	}
+	timeout := rtkutils.SelfTestTimeout(cmd)
+
+	report := rtkutils.NewReport()
+	conf, err := resource.TransformAttributeMap[*Config](attrs)
+	report.Check("decode_config", err)
+	if err != nil {
+		return report.Result(), nil
+	}
+	conf.SkipDeviceCheck = false
+	_, err = conf.Validate("config")
+	report.Check("validate_config", err)
+	if err != nil {
+		return report.Result(), nil
+	}
+
+	ports := map[string]interface{}{}
+	nmea := conf.NMEASource
+	if nmea.Transport == TransportI2C {
+		report.Check("nmea_i2c_addr_ack", rtkutils.CheckI2CAck(byte(nmea.I2CAddr), nmea.I2CBus))
+	} else if g.conf.NMEASource.Transport != TransportI2C && rtkutils.SameDevice(nmea.SerialPath, g.conf.NMEASource.SerialPath) {
+		report.Check("nmea_sentences", rtkutils.WaitForIncrease(ctx, timeout, "complete nmea epoch", g.currentEpoch))
+	} else {
+		baud := nmea.SerialBaudRate
+		if profile, err := rtkutils.Receiver(nmea.Receiver); err == nil && baud == 0 {
+			baud = profile.BaudRate
+		}
+		output, err := g.listenForCheck(ctx, nmea.SerialPath, baud, timeout)
+		report.Check("nmea_port_open", err)
+		if err == nil {
+			ports[nmea.SerialPath] = output.Result()
+			if output.Sentences == 0 && output.UBX == 0 {
+				err = fmt.Errorf("no nmea sentences from %s within %s, check its baud rate", nmea.SerialPath, timeout)
+			}
+			report.Check("nmea_sentences", err)
+			report.Check("receiver_model", output.MatchReceiver(nmea.Receiver))
+		}
+	}
+	if nmea.CorrectionTransport == TransportI2C {
+		report.Check("receiver_correction_i2c_addr_ack", rtkutils.CheckI2CAck(byte(nmea.CorrectionI2CAddr), conf.correctionPortBus()))
+	}
+
+	source := conf.CorrectionSource
+	switch source.Transport {
+	case TransportI2C:
+		report.Check("rtcm_i2c_addr_ack", rtkutils.CheckI2CAck(byte(source.I2CAddr), conf.correctionBus()))
+	case TransportSerial:
+		if g.conf.CorrectionSource.Transport == TransportSerial && rtkutils.SameDevice(source.SerialPath, g.conf.CorrectionSource.SerialPath) {
+			report.Check("rtcm_frames", rtkutils.WaitForIncrease(ctx, timeout, "rtcm frames forwarded", g.rtcmFrames.Get))
+			break
+		}
+		output, err := g.listenForCheck(ctx, source.SerialPath, source.SerialBaudRate, timeout)
+		report.Check("correction_port_open", err)
+		if err == nil {
+			ports[source.SerialPath] = output.Result()
+			if output.RTCM == 0 {
+				err = fmt.Errorf("no rtcm frames from %s within %s", source.SerialPath, timeout)
+			}
+			report.Check("rtcm_frames", err)
+		}
+	}
+
+	result := report.Result()
+	result["ports"] = ports
+	return result, nil
+}
+
+// listenForCheck opens a serial port of a proposed config and listens to it. A port the rover has
+// open for another role can't be listened to without taking its data.
+func (g *gpsRTK) listenForCheck(ctx context.Context, path string, baud int, timeout time.Duration) (rtkutils.PortOutput, error) {
+	current := map[string]string{"nmea_source": g.conf.NMEASource.SerialPath}
+	if g.conf.CorrectionSource.Transport == TransportSerial {
+		current["correction_source"] = g.conf.CorrectionSource.SerialPath
+	}
+	for role, open := range current {
+		if open != "" && rtkutils.SameDevice(path, open) {
+			return rtkutils.PortOutput{}, fmt.Errorf("%s is the %s of the rover, it can only be checked once the config is applied", path, role)
+		}
+	}
+	port, err := openSerial(path, baud)
+	if err != nil {
+		return rtkutils.PortOutput{}, err
+	}
+	return rtkutils.ListenToPort(ctx, port, timeout)
+}
+
+// provision enables rtcm input on the receiver port the radio is wired to (uart2 by default),
+// saves the configuration and waits for an rtk fix.
+func (g *gpsRTK) provision(ctx context.Context, cmd map[string]interface{}) (map[string]interface{}, error) {
+	port, err := rtkutils.UBXPortArg(cmd, "rtcm_input_port", rtkutils.UBXPortUART2)
+	if err != nil {
+		return nil, err
+	}
+	baudRate := rtkutils.IntArg(cmd, "baud_rate", defaultBaudRate)
+
+	report := rtkutils.NewReport()
+	report.Check("enable_rtcm_input", g.writeToReceiver(rtkutils.UBXPortConfigPacket(port, baudRate,
+		rtkutils.UBXProtoUBX|rtkutils.UBXProtoNMEA|rtkutils.UBXProtoRTCM3, rtkutils.UBXProtoUBX|rtkutils.UBXProtoNMEA)))
+	report.Check("save_config", g.writeToReceiver(rtkutils.UBXSaveConfigPacket()))
+	if report.Passed() {
+		timeout := rtkutils.DurationArg(cmd, "fix_timeout_sec", defaultProvisionFixTimeout)
+		report.Check("rtk_fix", rtkutils.WaitFor(ctx, timeout, "rtk fix", g.hasRTKFix))
+	}
+
+	return report.Result(), nil
+}
+
+// saveReceiverConfig answers a SaveReceiverConfigCommand.
+func (g *gpsRTK) saveReceiverConfig() map[string]interface{} {
+	report := rtkutils.NewReport()
+	report.Check("save_config", g.profile.SaveConfig(g.writeToReceiver))
+	result := report.Result()
+	result["receiver"] = g.profile.Name
+	return result
+}
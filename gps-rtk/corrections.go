@@ -0,0 +1,174 @@
+package gpsrtk
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+
+	rtkutils "rtksystem/rtk-utils"
+)
+
+// receiveAndWriteCorrections reads corrections from the correction source and writes them to the receiver.
+func (g *gpsRTK) receiveAndWriteCorrections() error {
+	var reader io.ReadCloser
+	var frames *rtkutils.FrameReader
+	defer func() {
+		if reader != nil {
+			g.closeCorrectionSource(reader)
+		}
+	}()
+
+	for g.cancelCtx.Err() == nil {
+		if reader == nil {
+			var err error
+			reader, err = g.openCorrectionSource()
+			if err != nil {
+				reader = nil
+				if g.cancelCtx.Err() != nil || g.recovery.Handle(g.cancelCtx, g.classes.openCorrections, err) == rtkutils.Rebuild {
+					return rtkutils.ErrRebuildRequired
+				}
+				continue
+			}
+			g.recovery.Succeeded(g.classes.openCorrections)
+			if frames == nil {
+				frames = rtkutils.NewFrameReader(reader)
+			} else {
+				frames.Reset(reader)
+			}
+		}
+
+		rx, err := g.openCorrectionPort()
+		if err != nil {
+			if g.cancelCtx.Err() != nil || g.recovery.Handle(g.cancelCtx, g.classes.openCorrectionPort, err) == rtkutils.Rebuild {
+				return rtkutils.ErrRebuildRequired
+			}
+			continue
+		}
+		g.recovery.Succeeded(g.classes.openCorrectionPort)
+
+		class, err := g.writeCorrections(frames, rx)
+		if g.cancelCtx.Err() != nil {
+			// the sources were closed on shutdown
+			return nil
+		}
+		if errors.Is(err, io.EOF) && g.conf.CorrectionSource.Transport == TransportReplay {
+			g.logger.Infof("finished replaying corrections from %s", g.conf.CorrectionSource.ReplayFile)
+			return nil
+		}
+		switch g.recovery.Handle(g.cancelCtx, class, err) {
+		case rtkutils.Rebuild:
+			return rtkutils.ErrRebuildRequired
+		case rtkutils.Reopen:
+			if class == g.classes.readCorrections {
+				g.closeCorrectionSource(reader)
+				reader = nil
+			} else {
+				g.closeCorrectionPort(rx)
+			}
+		}
+	}
+	return nil
+}
+
+// startWritingCorrections registers the writing of corrections to the receiver for Close to wait on,
+// unless the rover is closing.
+func (g *gpsRTK) startWritingCorrections() bool {
+	g.portsMu.Lock()
+	defer g.portsMu.Unlock()
+	if g.cancelCtx.Err() != nil {
+		return false
+	}
+	g.rtcmWriters.Add(1)
+	return true
+}
+
+// writeCorrections forwards the rtcm frames of frames to the receiver as they were read until
+// reading or writing fails, returning the class of the failure. Frames are written through the
+// correction queue, so that a receiver that stops taking them doesn't hold up reading: the oldest
+// frames are dropped, and a write that stays blocked fails so the receiver is reopened. On
+// shutdown the frames left in the queue are flushed to the receiver before it is closed.
+func (g *gpsRTK) writeCorrections(frames *rtkutils.FrameReader, rx io.Writer) (rtkutils.ErrorClass, error) {
+	if !g.startWritingCorrections() {
+		return g.classes.writeCorrections, g.cancelCtx.Err()
+	}
+	defer g.rtcmWriters.Done()
+	base := g.ppk.Base()
+	written := func(frame []byte) {
+		g.recovery.Succeeded(g.classes.writeCorrections)
+		base.Write(frame)
+		g.rtcmFrames.Add(1)
+		g.mavlink.RTCM(frame)
+	}
+	// flush writes the frames left on shutdown
+	flush := func() {
+		if g.cancelCtx.Err() == nil {
+			return
+		}
+		if err := g.correctionQueue.Flush(rx, rtkutils.DefaultFlushTimeout, written); err != nil {
+			g.logger.Warnf("failed to flush the corrections to the receiver: %s", err)
+		}
+	}
+	ctx, cancel := context.WithCancel(g.cancelCtx)
+	defer cancel()
+	writing := make(chan error, 1)
+	go func() {
+		writing <- g.correctionQueue.Run(ctx, rx, written)
+	}()
+
+	// stop waits for the write in progress, unless it stalls, and returns the failure of writing
+	// over that of reading
+	stop := func(class rtkutils.ErrorClass, err error) (rtkutils.ErrorClass, error) {
+		cancel()
+		ticker := time.NewTicker(100 * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			select {
+			case writeErr := <-writing:
+				if writeErr != nil {
+					return g.classes.writeCorrections, writeErr
+				}
+				flush()
+				return class, err
+			case <-ticker.C:
+				if stallErr := g.correctionQueue.CheckStall(time.Now()); stallErr != nil {
+					return g.classes.writeCorrections, stallErr
+				}
+			}
+		}
+	}
+
+	for {
+		frame, err := frames.Next()
+		if err != nil {
+			return stop(g.classes.readCorrections, err)
+		}
+		g.recovery.Succeeded(g.classes.readCorrections)
+		frame, ok := g.verifier.Verify(frame)
+		if !ok || !g.frameFilter.Accept(frame, time.Now()) {
+			continue
+		}
+		g.recentFrames.Add(frame)
+		g.glonass.Frame(frame, time.Now(), g.events)
+		g.rtcmStations.Frame(frame, time.Now(), g.events)
+		if !g.paused.Forward(time.Now(), g.events) {
+			// a ppk recording keeps the corrections the receiver doesn't get
+			base.Write(frame)
+			continue
+		}
+		g.jumps.Frame(frame)
+
+		select {
+		case err := <-writing:
+			if err == nil {
+				flush()
+			}
+			return g.classes.writeCorrections, err
+		default:
+		}
+		if err := g.correctionQueue.CheckStall(time.Now()); err != nil {
+			return g.classes.writeCorrections, err
+		}
+		g.degrader.Degrade(frame, g.correctionQueue.Push)
+	}
+}
@@ -0,0 +1,1330 @@
+// Package gpsrtk implements an rtk rover that reads nmea from its receiver over one transport and
+// forwards corrections to it from another.
+package gpsrtk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/edaniels/golog"
+	"github.com/golang/geo/r3"
+	geo "github.com/kellydunn/golang-geo"
+	"go.viam.com/rdk/components/board"
+	"go.viam.com/rdk/components/movementsensor"
+	"go.viam.com/rdk/components/sensor"
+	"go.viam.com/rdk/referenceframe"
+	"go.viam.com/rdk/resource"
+	"go.viam.com/rdk/spatialmath"
+	"go.viam.com/utils"
+
+	rtkutils "rtksystem/rtk-utils"
+)
+
+var Model = resource.NewModel("viam-labs", "movement-sensor", "gps-rtk")
+
+// default time to wait for an rtk fix after provisioning the receiver.
+const defaultProvisionFixTimeout = 2 * time.Minute
+
+var errNilLocation = errors.New("nil gps location, check nmea message parsing")
+
+// receiverPaths are the error paths of what the receiver reports, which the errors of the
+// corrections don't fail: a rover without them still knows where it is.
+var receiverPaths = []string{rtkutils.PathNMEARead, rtkutils.PathI2CBus}
+
+func init() {
+	resource.RegisterComponent(
+		movementsensor.API,
+		Model,
+		resource.Registration[movementsensor.MovementSensor, *Config]{
+			Constructor: func(
+				ctx context.Context,
+				deps resource.Dependencies,
+				conf resource.Config,
+				logger golog.Logger,
+			) (movementsensor.MovementSensor, error) {
+				newConf, err := resource.NativeConfig[*Config](conf)
+				if err != nil {
+					return nil, err
+				}
+				return newGPSRTK(ctx, deps, conf.ResourceName(), conf.Frame, newConf, logger)
+			},
+		})
+}
+
+// Config is used for the gps-rtk attributes.
+type Config struct {
+	NMEASource       NMEASourceConfig       `json:"nmea_source"`
+	CorrectionSource CorrectionSourceConfig `json:"correction_source"`
+
+	// Validate checks that serial ports and i2c buses exist unless this is set, for receivers connected later
+	SkipDeviceCheck bool `json:"skip_device_check,omitempty"`
+
+	// Serve standalone gps positions while the correction source can't be read, e.g. a radio that
+	// isn't attached yet, instead of failing once it kept failing
+	AllowGPSOnly bool `json:"allow_gps_only,omitempty"`
+
+	// A rover that read no valid nmea within startup_fix_timeout_sec of starting reports the no_data
+	// state and returns an error once, see rtkutils.StartupWatchdog
+	StartupFixTimeout float64 `json:"startup_fix_timeout_sec,omitempty"`
+
+	// How long loops polling for data wait when there is none, 50ms for i2c reads and 200ms for
+	// remote correction stations by default
+	PollIntervalMs int `json:"poll_interval_ms,omitempty"`
+
+	// Added to every reported heading to correct for antennas mounted off the vehicle's forward axis
+	HeadingOffsetDegrees float64 `json:"heading_offset_degrees,omitempty"`
+
+	// While the receiver has no heading, e.g. a single antenna standing still, CompassHeading returns
+	// the heading of heading_sensor, a movement sensor such as an rtk-heading pair or a compass, then
+	// the last heading of the receiver if hold_heading is set, see rtkutils.HeadingFallback
+	HeadingSensor string `json:"heading_sensor,omitempty"`
+	HoldHeading   bool   `json:"hold_heading,omitempty"`
+
+	// The course over ground isn't reported as the compass heading, for rovers whose course can
+	// differ from the way they face, such as a robot that drives backwards
+	DisableCourseHeading bool `json:"disable_course_heading,omitempty"`
+
+	// Thresholds used to decide whether the rover is moving, see rtkutils.MotionThresholds
+	MovingSpeed      float64 `json:"moving_speed_mps,omitempty"`
+	StationarySpeed  float64 `json:"stationary_speed_mps,omitempty"`
+	MovingDistance   float64 `json:"moving_distance_m,omitempty"`
+	StationaryEpochs int     `json:"stationary_epochs,omitempty"`
+
+	// The reported speed and heading are smoothed over velocity_filter_window epochs (5 by default)
+	// with a moving_average or exponential velocity_filter, see rtkutils.VelocityFilter
+	VelocityFilter       string `json:"velocity_filter,omitempty"`
+	VelocityFilterWindow int    `json:"velocity_filter_window,omitempty"`
+
+	// The speed is reported as 0 while Readings report zupt, the rover having stayed below the
+	// stationary speed for the stationary epochs, see rtkutils.MotionDetector.ZeroVelocity
+	ZUPTClampVelocity bool `json:"zupt_clamp_velocity,omitempty"`
+
+	// An overspeed event is raised above speed_limit_mps and cleared below speed_limit_clear_mps
+	SpeedLimit      float64 `json:"speed_limit_mps,omitempty"`
+	SpeedLimitClear float64 `json:"speed_limit_clear_mps,omitempty"`
+
+	// Fixes of the last track_minutes are kept for export_track, and appended to track_path if it is set
+	TrackMinutes float64 `json:"track_minutes,omitempty"`
+	TrackPath    string  `json:"track_path,omitempty"`
+
+	// The fix qualities of the epochs are counted on a grid of coverage_cell_m cells for
+	// export_coverage, see rtkutils.CoverageMap
+	CoverageCell float64 `json:"coverage_cell_m,omitempty"`
+
+	// Raw rover observations and base corrections are recorded here for ppk_solve, which runs the
+	// RTKLIB tools in rtklib_path, or those on the PATH without it
+	PPKRecordDir string `json:"ppk_record_dir,omitempty"`
+	RTKLIBPath   string `json:"rtklib_path,omitempty"`
+
+	// Raw sentences are captured to this file once parse failures exceed the rate per minute
+	ParseFailureLogPath string `json:"parse_failure_log_path,omitempty"`
+	ParseFailureRate    int    `json:"parse_failure_rate_per_min,omitempty"`
+
+	// Positions are shifted by privacy_offset_m and snapped to a privacy_grid_m grid, and only
+	// precise_position and export_track given privacy_key return them at full precision
+	PrivacyGrid   float64 `json:"privacy_grid_m,omitempty"`
+	PrivacyOffset float64 `json:"privacy_offset_m,omitempty"`
+	PrivacyKey    string  `json:"privacy_key,omitempty"`
+
+	// Positions are reported in output_datum, for ground control not in WGS84: wgs84 (the default),
+	// nad83, etrs89, or custom with the parameters of datum_transform, see rtkutils.Datum
+	OutputDatum    string                `json:"output_datum,omitempty"`
+	DatumTransform *DatumTransformConfig `json:"datum_transform,omitempty"`
+
+	// Positions are the antenna's, or with position_frame robot the robot's origin, from the frame of
+	// the component in the frame system, see rtkutils.RobotFrame
+	PositionFrame string `json:"position_frame,omitempty"`
+
+	// The configuration the receiver is set up with is saved to its flash or battery backed memory,
+	// so that it survives a brownout without being set up again, see rtkutils.ReceiverProfile.SaveConfig
+	SaveReceiverConfig bool `json:"save_receiver_config,omitempty"`
+
+	// A GPIO pin of power_board that powers the receiver or holds it in reset, low to turn it off
+	// unless power_off_high is set. The receiver is power cycled by power_cycle, and by a watchdog
+	// once it sends no epoch for power_cycle_after_sec, 30 by default, see rtkutils.PowerControl
+	PowerBoard      string  `json:"power_board,omitempty"`
+	PowerPin        string  `json:"power_pin,omitempty"`
+	PowerOffHigh    bool    `json:"power_off_high,omitempty"`
+	PowerOffMs      int     `json:"power_off_ms,omitempty"`
+	PowerCycleAfter float64 `json:"power_cycle_after_sec,omitempty"`
+
+	// A GPIO pin of antenna_switch_board that selects the secondary antenna when high, through an RF
+	// switch or the antenna input of the receiver. The rover fails over to the other antenna once the
+	// C/N0 of its strongest satellites stays below antenna_failover_cnr_db_hz for antenna_failover_sec,
+	// see rtkutils.AntennaSwitch
+	AntennaSwitchBoard string  `json:"antenna_switch_board,omitempty"`
+	AntennaSwitchPin   string  `json:"antenna_switch_pin,omitempty"`
+	AntennaFailoverCNR float64 `json:"antenna_failover_cnr_db_hz,omitempty"`
+	AntennaFailover    float64 `json:"antenna_failover_sec,omitempty"`
+
+	// A digital interrupt of pps_board wired to the pulse per second output of the receiver, which
+	// times the clock estimate of the clock command more precisely than the fixes, see
+	// rtkutils.ClockEstimator
+	PPSBoard     string `json:"pps_board,omitempty"`
+	PPSInterrupt string `json:"pps_interrupt,omitempty"`
+	// The socket of a chrony SOCK refclock the clock samples are sent to, so that chrony disciplines
+	// the system clock with the receiver, see rtkutils.ChronySocket
+	ChronySocket string `json:"chrony_socket,omitempty"`
+
+	// The position of every epoch with a fix is sent to these udp host:port endpoints, as json or, with
+	// telemetry_format nmea, as a GGA sentence, see rtkutils.Telemetry
+	TelemetryEndpoints []string `json:"telemetry_endpoints,omitempty"`
+	TelemetryFormat    string   `json:"telemetry_format,omitempty"`
+
+	// An ArduPilot or PX4 autopilot at the udp host:port mavlink_addr gets the position of every epoch
+	// as GPS_INPUT if mavlink_gps_input is set, and the corrections as GPS_RTCM_DATA if
+	// mavlink_rtcm_data is, see rtkutils.MAVLink
+	MAVLinkAddr     string `json:"mavlink_addr,omitempty"`
+	MAVLinkGPSInput bool   `json:"mavlink_gps_input,omitempty"`
+	MAVLinkRTCMData bool   `json:"mavlink_rtcm_data,omitempty"`
+
+	// Position returns a position sampled republish_rate_hz times a second instead of the latest
+	// epoch, extrapolated by up to max_extrapolation_sec (1 by default) while moving if
+	// extrapolate_position is set, see rtkutils.PositionHold
+	RepublishRate       float64 `json:"republish_rate_hz,omitempty"`
+	ExtrapolatePosition bool    `json:"extrapolate_position,omitempty"`
+	MaxExtrapolation    float64 `json:"max_extrapolation_sec,omitempty"`
+
+	// The receiver applies the corrections of this ppp service broadcast by the satellites, has or
+	// b2b, which makes correction_source optional
+	PPPService string `json:"ppp_service,omitempty"`
+
+	// The rover is rtk ok, as reported by Readings and the interlock command, with at least an
+	// interlock_fix (fixed by default) fix, a correction at most interlock_max_correction_age_sec (10
+	// by default) old and an hdop of at most interlock_max_hdop if it is set, see rtkutils.InterlockConditions
+	InterlockFix              string  `json:"interlock_fix,omitempty"`
+	InterlockMaxCorrectionAge float64 `json:"interlock_max_correction_age_sec,omitempty"`
+	InterlockMaxHDOP          float64 `json:"interlock_max_hdop,omitempty"`
+
+	// What Position reports once corrections are lost, none having arrived for
+	// interlock_max_correction_age_sec: continue (the default), hold or error, see rtkutils.CorrectionLoss
+	CorrectionLossPolicy string `json:"correction_loss_policy,omitempty"`
+
+	// For testing only: corrections are degraded on their way to the receiver, degrade_loss_percent
+	// of them dropped, degrade_corrupt_percent corrupted and every one delayed by degrade_latency_ms
+	// and up to degrade_jitter_ms more, see rtkutils.CorrectionDegradation
+	DegradeLossPercent    float64 `json:"degrade_loss_percent,omitempty"`
+	DegradeCorruptPercent float64 `json:"degrade_corrupt_percent,omitempty"`
+	DegradeLatencyMs      int     `json:"degrade_latency_ms,omitempty"`
+	DegradeJitterMs       int     `json:"degrade_jitter_ms,omitempty"`
+	DegradeSeed           int64   `json:"degrade_seed,omitempty"`
+}
+
+// Validate ensures all parts of the config are valid.
+func (cfg *Config) Validate(path string) ([]string, error) {
+	if err := cfg.validateNMEASource(path); err != nil {
+		return nil, err
+	}
+	deps, err := cfg.validateCorrectionSource(path)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.TrackPath != "" && cfg.TrackMinutes <= 0 {
+		return nil, utils.NewConfigValidationFieldRequiredError(path, "track_minutes")
+	}
+	if err := rtkutils.ValidateSpeedAlarm(cfg.SpeedLimit, cfg.SpeedLimitClear); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if err := rtkutils.ValidateCoverageCell(cfg.CoverageCell); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if err := cfg.motionThresholds().Validate(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if err := cfg.interlockConditions().Validate(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if err := rtkutils.ValidateCorrectionLossPolicy(cfg.CorrectionLossPolicy, cfg.CorrectionSource.Transport != ""); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if err := rtkutils.ValidateVelocityFilter(cfg.VelocityFilter, cfg.VelocityFilterWindow); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if err := rtkutils.ValidatePollInterval(cfg.PollIntervalMs); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if err := rtkutils.ValidateStartupTimeout(cfg.StartupFixTimeout); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if err := rtkutils.ValidatePositionPrivacy(cfg.PrivacyGrid, cfg.PrivacyOffset, cfg.PrivacyKey); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if err := rtkutils.ValidateDatum(cfg.OutputDatum, cfg.DatumTransform.helmert()); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if err := rtkutils.ValidatePositionFrame(cfg.PositionFrame); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if err := rtkutils.ValidateTelemetry(cfg.TelemetryEndpoints, cfg.TelemetryFormat); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if err := rtkutils.ValidatePPK(cfg.PPKRecordDir, cfg.RTKLIBPath); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if err := rtkutils.ValidateMAVLink(cfg.MAVLinkAddr, cfg.MAVLinkGPSInput, cfg.MAVLinkRTCMData); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if err := rtkutils.ValidatePowerControl(cfg.PowerBoard, cfg.PowerPin, cfg.PowerOffMs, cfg.PowerCycleAfter); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if err := rtkutils.ValidateAntennaSwitch(
+		cfg.AntennaSwitchBoard, cfg.AntennaSwitchPin, cfg.AntennaFailoverCNR, cfg.AntennaFailover); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if err := rtkutils.ValidatePPS(cfg.PPSBoard, cfg.PPSInterrupt); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if err := rtkutils.ValidateChronySocket(cfg.ChronySocket); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if err := rtkutils.ValidatePositionHold(cfg.RepublishRate, cfg.ExtrapolatePosition, cfg.MaxExtrapolation); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if err := rtkutils.ValidatePPPService("ppp_service", cfg.PPPService, cfg.NMEASource.Receiver); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if err := cfg.correctionDegradation().Validate(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if cfg.HeadingSensor != "" {
+		deps = append(deps, cfg.HeadingSensor)
+	}
+	if cfg.PowerBoard != "" {
+		deps = append(deps, cfg.PowerBoard)
+	}
+	if cfg.AntennaSwitchBoard != "" && cfg.AntennaSwitchBoard != cfg.PowerBoard {
+		deps = append(deps, cfg.AntennaSwitchBoard)
+	}
+	if cfg.PPSBoard != "" && cfg.PPSBoard != cfg.PowerBoard && cfg.PPSBoard != cfg.AntennaSwitchBoard {
+		deps = append(deps, cfg.PPSBoard)
+	}
+	return deps, nil
+}
+
+// DatumTransformConfig is a Helmert transformation from WGS84 with proj's parameters: a translation
+// in m, rotations in arcseconds, a scale in ppm and their rates per year from t_epoch. Rotations are
+// in the position_vector convention unless convention is coordinate_frame. a and rf are the ellipsoid
+// of the datum, WGS84's if they are not set.
+type DatumTransformConfig struct {
+	X          float64 `json:"x,omitempty"`
+	Y          float64 `json:"y,omitempty"`
+	Z          float64 `json:"z,omitempty"`
+	RX         float64 `json:"rx,omitempty"`
+	RY         float64 `json:"ry,omitempty"`
+	RZ         float64 `json:"rz,omitempty"`
+	S          float64 `json:"s,omitempty"`
+	DX         float64 `json:"dx,omitempty"`
+	DY         float64 `json:"dy,omitempty"`
+	DZ         float64 `json:"dz,omitempty"`
+	DRX        float64 `json:"drx,omitempty"`
+	DRY        float64 `json:"dry,omitempty"`
+	DRZ        float64 `json:"drz,omitempty"`
+	DS         float64 `json:"ds,omitempty"`
+	Epoch      float64 `json:"t_epoch,omitempty"`
+	Convention string  `json:"convention,omitempty"`
+	A          float64 `json:"a,omitempty"`
+	RF         float64 `json:"rf,omitempty"`
+}
+
+// helmert returns the transformation of the config, nil without one.
+func (t *DatumTransformConfig) helmert() *rtkutils.Helmert {
+	if t == nil {
+		return nil
+	}
+	return &rtkutils.Helmert{
+		X: t.X, Y: t.Y, Z: t.Z,
+		RX: t.RX, RY: t.RY, RZ: t.RZ,
+		S:  t.S,
+		DX: t.DX, DY: t.DY, DZ: t.DZ,
+		DRX: t.DRX, DRY: t.DRY, DRZ: t.DRZ,
+		DS:              t.DS,
+		Epoch:           t.Epoch,
+		CoordinateFrame: t.Convention == "coordinate_frame",
+		A:               t.A, RF: t.RF,
+	}
+}
+
+func (cfg *Config) motionThresholds() rtkutils.MotionThresholds {
+	return rtkutils.MotionThresholds{
+		MovingSpeed:      cfg.MovingSpeed,
+		StationarySpeed:  cfg.StationarySpeed,
+		MovingDistance:   cfg.MovingDistance,
+		StationaryEpochs: cfg.StationaryEpochs,
+	}
+}
+
+func (cfg *Config) correctionDegradation() rtkutils.CorrectionDegradation {
+	return rtkutils.CorrectionDegradation{
+		LossPercent:    cfg.DegradeLossPercent,
+		CorruptPercent: cfg.DegradeCorruptPercent,
+		Latency:        time.Duration(cfg.DegradeLatencyMs) * time.Millisecond,
+		Jitter:         time.Duration(cfg.DegradeJitterMs) * time.Millisecond,
+		Seed:           cfg.DegradeSeed,
+	}
+}
+
+// effective returns a copy of the config with the defaults the rover runs with, given the profile of
+// its receiver, filled in.
+func (cfg *Config) effective(profile rtkutils.ReceiverProfile) Config {
+	conf := *cfg
+	nmea, corrections := &conf.NMEASource, &conf.CorrectionSource
+	nmea.Receiver = profile.Name
+	switch nmea.Transport {
+	case TransportSerial:
+		if nmea.SerialBaudRate == 0 {
+			nmea.SerialBaudRate = profile.BaudRate
+		}
+		if nmea.SerialBaudRate == 0 {
+			nmea.SerialBaudRate = defaultBaudRate
+		}
+	case TransportI2C:
+		if nmea.I2CBaudRate == 0 {
+			nmea.I2CBaudRate = defaultBaudRate
+		}
+	}
+	if nmea.ReadBufferSize == 0 {
+		nmea.ReadBufferSize = rtkutils.DefaultReadBufferSize
+	}
+	switch nmea.CorrectionTransport {
+	case TransportSerial:
+		if nmea.CorrectionSerialBaudRate == 0 {
+			nmea.CorrectionSerialBaudRate = defaultBaudRate
+		}
+	case TransportI2C:
+		nmea.CorrectionI2CBus = cfg.correctionPortBus()
+	}
+	switch corrections.Transport {
+	case TransportSerial:
+		if corrections.SerialBaudRate == 0 {
+			corrections.SerialBaudRate = defaultBaudRate
+		}
+	case TransportI2C:
+		corrections.I2CBus = cfg.correctionBus()
+	}
+	if corrections.ReadBufferSize == 0 && (corrections.Transport == TransportSerial || corrections.Transport == TransportI2C) {
+		corrections.ReadBufferSize = rtkutils.DefaultReadBufferSize
+	}
+
+	motion := cfg.motionThresholds().WithDefaults()
+	conf.MovingSpeed, conf.StationarySpeed = motion.MovingSpeed, motion.StationarySpeed
+	conf.MovingDistance, conf.StationaryEpochs = motion.MovingDistance, motion.StationaryEpochs
+	if conf.VelocityFilter != "" && conf.VelocityFilterWindow == 0 {
+		conf.VelocityFilterWindow = rtkutils.DefaultVelocityFilterWindow
+	}
+	interlock := cfg.interlockConditions().WithDefaults()
+	conf.InterlockFix, conf.InterlockMaxCorrectionAge = interlock.MinFix, interlock.MaxCorrectionAge.Seconds()
+	if corrections.Transport != "" && conf.CorrectionLossPolicy == "" {
+		conf.CorrectionLossPolicy = rtkutils.CorrectionLossContinue
+	}
+	if conf.OutputDatum == "" {
+		conf.OutputDatum = rtkutils.DatumWGS84
+	}
+
+	if conf.ParseFailureLogPath != "" && conf.ParseFailureRate == 0 {
+		conf.ParseFailureRate = rtkutils.DefaultParseFailureRate
+	}
+	if conf.PowerPin != "" {
+		if conf.PowerOffMs == 0 {
+			conf.PowerOffMs = int(rtkutils.DefaultPowerOff.Milliseconds())
+		}
+		if conf.PowerCycleAfter == 0 {
+			conf.PowerCycleAfter = rtkutils.DefaultPowerCycleAfter.Seconds()
+		}
+	}
+	if conf.AntennaSwitchPin != "" {
+		if conf.AntennaFailoverCNR == 0 {
+			conf.AntennaFailoverCNR = rtkutils.DefaultFailoverCNR
+		}
+		if conf.AntennaFailover == 0 {
+			conf.AntennaFailover = rtkutils.DefaultFailoverAfter.Seconds()
+		}
+	}
+	if conf.ExtrapolatePosition && conf.MaxExtrapolation == 0 {
+		conf.MaxExtrapolation = rtkutils.DefaultMaxExtrapolation.Seconds()
+	}
+	return conf
+}
+
+func (cfg *Config) interlockConditions() rtkutils.InterlockConditions {
+	return rtkutils.InterlockConditions{
+		MinFix:           cfg.InterlockFix,
+		MaxCorrectionAge: time.Duration(cfg.InterlockMaxCorrectionAge * float64(time.Second)),
+		MaxHDOP:          cfg.InterlockMaxHDOP,
+	}
+}
+
+// A gpsRTK is a MovementSensor model that reads nmea from its receiver and forwards it corrections
+// from a configurable source.
+type gpsRTK struct {
+	resource.Named
+	resource.AlwaysRebuild
+	logger     golog.Logger
+	cancelCtx  context.Context
+	cancelFunc func()
+	closed     rtkutils.CloseOnce
+	conf       *Config
+	profile    rtkutils.ReceiverProfile // what the receiver supports
+
+	activeBackgroundWorkers sync.WaitGroup
+
+	err          *rtkutils.ErrorHistory
+	classes      errorClasses
+	recovery     *rtkutils.Recovery
+	workers      *rtkutils.Supervisor
+	lastposition movementsensor.LastPosition
+	// when lastposition was last read from an epoch
+	lastPositionAt time.Time
+	lastPositionMu sync.Mutex
+
+	parseFailures *rtkutils.ParseFailureRecorder
+	sentences     rtkutils.SentenceStats // sentences read from the receiver by type
+	ppk           *rtkutils.PPKRecorder
+	track         *rtkutils.Track
+	coverage      *rtkutils.CoverageMap
+	events        *rtkutils.EventLog
+	speedAlarm    *rtkutils.SpeedAlarm
+	privacy       *rtkutils.PositionPrivacy
+	stations      rtkutils.ReferenceStationTracker
+	outages       rtkutils.OutageTracker
+	jumps         rtkutils.JumpDetector
+	glonass       rtkutils.GLONASSBiasCheck
+	rtcmStations  rtkutils.RTCMStationHistory
+	heading       *rtkutils.HeadingFallback
+	nav           *rtkutils.NavTracker        // attitude and solution status of a u-blox receiver
+	rtcmStats     *rtkutils.ReceiverRTCMStats // what a u-blox receiver did with the corrections
+	raw           *rtkutils.RawObservables
+	power         *rtkutils.PowerControl
+	configWatch   rtkutils.ConfigWatch // recognizes a receiver that lost the configuration it was set up with
+	rates         rtkutils.RateCheck   // checks the receiver sends what it was set up to
+	antenna       *rtkutils.AntennaSwitch
+	clock         *rtkutils.ClockEstimator
+	chrony        *rtkutils.ChronySocket
+	telemetry     *rtkutils.Telemetry
+	mavlink       *rtkutils.MAVLink
+	hold          *rtkutils.PositionHold
+	ppsBoard      board.Board // nil without a pulse per second
+
+	startup *rtkutils.StartupWatchdog
+
+	latest rtkutils.Snapshot // the latest complete nmea epoch
+	epochs rtkutils.EpochTracker
+	dataMu sync.RWMutex
+
+	receiver        io.ReadWriteCloser // the rover's receiver, nil until it is opened
+	correctionPort  io.WriteCloser     // the receiver's port corrections are written to, if not the receiver's
+	corrections     io.ReadCloser      // the correction source, nil until it is opened
+	portsMu         sync.Mutex
+	rtcmWriters     sync.WaitGroup   // writing corrections to the receiver, until they are flushed on shutdown
+	rtcmFrames      rtkutils.Counter // frames forwarded to the gps
+	receiverFrames  rtkutils.Counter // frames the gps sent on its nmea port
+	verifier        *rtkutils.FrameVerifier
+	radioLink       *rtkutils.RadioLink // nil unless the corrections come in radio frames
+	frameFilter     *rtkutils.FrameFilter
+	correctionQueue *rtkutils.CorrectionQueue // frames waiting to be written to the gps
+	published       rtkutils.Counter          // nmea epochs published
+	nmeaLatency     rtkutils.LatencyStats     // from reading a sentence to having parsed it
+
+	degrader *rtkutils.CorrectionDegrader // nil unless corrections are degraded for testing
+	paused   rtkutils.CorrectionPause     // stops forwarding corrections on request
+
+	correctionLoss *rtkutils.CorrectionLoss // nil without a correction source
+
+	robotFrame *rtkutils.RobotFrame // nil to report the antenna's positions
+	datum      *rtkutils.Datum      // nil for WGS84
+	offset     rtkutils.PositionOffset
+
+	recentFrames rtkutils.RTCMBuffer // last frames forwarded to the gps, for decode_rtcm
+
+	// how full the reads of the receiver, and of a serial or i2c correction source, get
+	nmeaBuffer       *rtkutils.ReadBufferUsage
+	correctionBuffer *rtkutils.ReadBufferUsage // nil for other sources
+
+	remoteStation      resource.Resource // correction station on another robot, for a remote source
+	pollInterval       time.Duration     // wait after an i2c read with no data
+	remotePollInterval time.Duration
+}
+
+func newGPSRTK(
+	ctx context.Context,
+	deps resource.Dependencies,
+	name resource.Name,
+	frame *referenceframe.LinkConfig,
+	newConf *Config,
+	logger golog.Logger,
+) (movementsensor.MovementSensor, error) {
+	profile, err := rtkutils.Receiver(newConf.NMEASource.Receiver)
+	if err != nil {
+		return nil, err
+	}
+	robotFrame, err := rtkutils.NewRobotFrame(newConf.PositionFrame, frame)
+	if err != nil {
+		return nil, err
+	}
+	cancelCtx, cancelFunc := context.WithCancel(rtkutils.ModuleContext())
+	g := &gpsRTK{
+		Named:        name.AsNamed(),
+		cancelCtx:    cancelCtx,
+		cancelFunc:   cancelFunc,
+		conf:         newConf,
+		profile:      profile,
+		robotFrame:   robotFrame,
+		logger:       logger,
+		err:          rtkutils.NewErrorHistory(),
+		classes:      classesOf(newConf.NMEASource.Transport, newConf.NMEASource.CorrectionTransport),
+		lastposition: movementsensor.NewLastPosition(),
+		parseFailures: rtkutils.NewParseFailureRecorder(
+			newConf.ParseFailureLogPath, newConf.ParseFailureRate, rtkutils.DefaultParseFailureLogSize),
+		events:             rtkutils.NewEventLog(logger),
+		speedAlarm:         rtkutils.NewSpeedAlarm(newConf.SpeedLimit, newConf.SpeedLimitClear),
+		verifier:           rtkutils.NewFrameVerifier(newConf.CorrectionSource.AuthKey),
+		radioLink:          rtkutils.NewRadioLink(newConf.CorrectionSource.RadioFraming),
+		frameFilter:        rtkutils.NewFrameFilter(),
+		correctionQueue:    rtkutils.NewCorrectionQueue(rtkutils.DefaultCorrectionQueueSize, rtkutils.DefaultWriteStall),
+		degrader:           rtkutils.NewCorrectionDegrader(newConf.correctionDegradation()),
+		nmeaBuffer:         rtkutils.NewReadBufferUsage(newConf.NMEASource.ReadBufferSize),
+		privacy:            rtkutils.NewPositionPrivacy(newConf.PrivacyGrid, newConf.PrivacyOffset, newConf.PrivacyKey),
+		nav:                rtkutils.NewNavTracker(profile),
+		rtcmStats:          rtkutils.NewReceiverRTCMStats(profile),
+		raw:                rtkutils.NewRawObservables(profile),
+		chrony:             rtkutils.NewChronySocket(newConf.ChronySocket, logger),
+		telemetry:          rtkutils.NewTelemetry(newConf.TelemetryEndpoints, newConf.TelemetryFormat),
+		mavlink:            rtkutils.NewMAVLink(newConf.MAVLinkAddr, newConf.MAVLinkGPSInput, newConf.MAVLinkRTCMData),
+		pollInterval:       rtkutils.PollInterval(newConf.PollIntervalMs, rtkutils.DefaultPollInterval),
+		remotePollInterval: rtkutils.PollInterval(newConf.PollIntervalMs, rtkutils.DefaultRemotePollInterval),
+	}
+	policy := recoveryPolicy(g.classes)
+	if newConf.AllowGPSOnly {
+		policy = policy.WithOptional(g.classes.openCorrections, g.classes.readCorrections)
+	}
+	g.recovery = rtkutils.NewRecovery(policy, g.err, logger)
+	g.hold = rtkutils.NewPositionHold(newConf.RepublishRate, newConf.ExtrapolatePosition,
+		time.Duration(newConf.MaxExtrapolation*float64(time.Second)))
+	g.clock = rtkutils.NewClockEstimator(func(source string, host, gps time.Time) {
+		g.chrony.Send(host, gps, source == rtkutils.ClockSourcePPS)
+	})
+	g.workers = rtkutils.NewSupervisor(cancelCtx, &g.activeBackgroundWorkers, logger)
+	g.startup = rtkutils.NewStartupWatchdog(time.Duration(newConf.StartupFixTimeout*float64(time.Second)), time.Now())
+	g.epochs.SetMotionThresholds(newConf.motionThresholds())
+	g.epochs.SetVelocityFilter(rtkutils.NewVelocityFilter(newConf.VelocityFilter, newConf.VelocityFilterWindow))
+	g.epochs.SetZeroVelocityClamp(newConf.ZUPTClampVelocity)
+	if transport := newConf.CorrectionSource.Transport; transport == TransportSerial || transport == TransportI2C {
+		g.correctionBuffer = rtkutils.NewReadBufferUsage(newConf.CorrectionSource.ReadBufferSize)
+	}
+
+	if g.degrader != nil {
+		logger.Warnf("corrections are degraded for testing: %.f%% dropped, %.f%% corrupted, %dms latency with %dms jitter",
+			newConf.DegradeLossPercent, newConf.DegradeCorruptPercent, newConf.DegradeLatencyMs, newConf.DegradeJitterMs)
+	}
+	if !profile.RTK {
+		logger.Warnf("the %s doesn't apply corrections, positions stay standalone; set ppk_record_dir to post process them", profile.Name)
+	}
+
+	if newConf.CorrectionSource.Transport == TransportRemote {
+		station, err := sensor.FromDependencies(deps, newConf.CorrectionSource.RemoteCorrectionStation)
+		if err != nil {
+			cancelFunc()
+			return nil, err
+		}
+		g.remoteStation = station
+	}
+
+	var headingSensor movementsensor.MovementSensor
+	if newConf.HeadingSensor != "" {
+		headingSensor, err = movementsensor.FromDependencies(deps, newConf.HeadingSensor)
+		if err != nil {
+			cancelFunc()
+			return nil, err
+		}
+	}
+	g.heading = rtkutils.NewHeadingFallback(headingSensor, newConf.HoldHeading, rtkutils.ReceiverHeadings{
+		CourseOverGround: !newConf.DisableCourseHeading,
+		Attitude:         profile.Attitude,
+	})
+
+	if newConf.PowerBoard != "" {
+		b, err := board.FromDependencies(deps, newConf.PowerBoard)
+		if err != nil {
+			cancelFunc()
+			return nil, err
+		}
+		g.power = rtkutils.NewPowerControl(
+			b, newConf.PowerPin, newConf.PowerOffHigh, time.Duration(newConf.PowerOffMs)*time.Millisecond, g.reopenReceiver)
+	}
+
+	if newConf.AntennaSwitchBoard != "" {
+		b, err := board.FromDependencies(deps, newConf.AntennaSwitchBoard)
+		if err != nil {
+			cancelFunc()
+			return nil, err
+		}
+		g.antenna = rtkutils.NewAntennaSwitch(b, newConf.AntennaSwitchPin, newConf.AntennaFailoverCNR,
+			time.Duration(newConf.AntennaFailover*float64(time.Second)))
+	}
+
+	if newConf.PPSBoard != "" {
+		b, err := board.FromDependencies(deps, newConf.PPSBoard)
+		if err != nil {
+			cancelFunc()
+			return nil, err
+		}
+		if _, ok := b.DigitalInterruptByName(newConf.PPSInterrupt); !ok {
+			cancelFunc()
+			return nil, fmt.Errorf("board %q has no digital interrupt %q for the pps", newConf.PPSBoard, newConf.PPSInterrupt)
+		}
+		g.ppsBoard = b
+	}
+
+	ppk, err := rtkutils.NewPPKRecorder(newConf.PPKRecordDir, newConf.RTKLIBPath)
+	if err != nil {
+		cancelFunc()
+		return nil, fmt.Errorf("failed to start ppk recording: %w", err)
+	}
+	g.ppk = ppk
+
+	track, err := rtkutils.NewTrack(time.Duration(newConf.TrackMinutes*float64(time.Minute)), newConf.TrackPath)
+	if err != nil {
+		cancelFunc()
+		g.ppk.Close()
+		return nil, fmt.Errorf("failed to open track file: %w", err)
+	}
+	g.track = track
+	g.coverage = rtkutils.NewCoverageMap(newConf.CoverageCell)
+	g.datum = rtkutils.NewDatum(newConf.OutputDatum, newConf.DatumTransform.helmert())
+	if newConf.CorrectionSource.Transport != "" {
+		g.correctionLoss = rtkutils.NewCorrectionLoss(newConf.CorrectionLossPolicy,
+			newConf.interlockConditions().WithDefaults().MaxCorrectionAge)
+	}
+
+	g.start()
+	rtkutils.TrackResource(g)
+	return g, g.err.Get()
+}
+
+// start configures the receiver and starts reading nmea from it and forwarding corrections to it.
+func (g *gpsRTK) start() {
+	g.workers.Go("nmea reader", g.readNMEAMessages)
+	// a rover using a ppp service may have no correction source
+	if g.conf.CorrectionSource.Transport != "" {
+		g.workers.Go("correction writer", g.receiveAndWriteCorrections)
+	}
+	if g.power != nil {
+		g.workers.Go("receiver watchdog", func() error {
+			after := time.Duration(g.conf.PowerCycleAfter * float64(time.Second))
+			return g.power.Watch(g.cancelCtx, &g.published, after, g.events)
+		})
+	}
+	if g.startup != nil {
+		g.workers.Go("startup watchdog", func() error {
+			if err := g.startup.Wait(g.cancelCtx, &g.published); err != nil {
+				g.logger.Error(err)
+				g.err.SetPath(g.classes.readNMEA.Path, g.classes.readNMEA.Category, err)
+			}
+			return nil
+		})
+	}
+	if g.hold.Republishing() {
+		g.workers.Go("position republisher", func() error {
+			return g.hold.Run(g.cancelCtx)
+		})
+	}
+	if g.ppsBoard != nil {
+		g.workers.Go("pps reader", func() error {
+			return rtkutils.WatchPPS(g.cancelCtx, g.ppsBoard, g.conf.PPSInterrupt, g.clock)
+		})
+	}
+}
+
+// onEpoch handles every complete nmea epoch once it is published.
+func (g *gpsRTK) onEpoch(snap rtkutils.Snapshot) {
+	g.published.Add(1)
+	if g.configWatch.Epoch(time.Now(), g.events) || g.rates.Epoch(time.Now(), g.events) {
+		g.reopenReceiver()
+	}
+	if _, ok := g.nav.Accuracy(time.Now()); ok {
+		g.rates.Message(rtkutils.UBXNavPVTMessage, time.Now())
+	}
+	if _, ok := g.nav.Attitude(time.Now()); ok {
+		g.rates.Message(rtkutils.UBXNavAttMessage, time.Now())
+	}
+	g.hold.Update(snap, time.Now())
+	if err := g.track.Add(snap.Data, time.Now()); err != nil {
+		g.logger.Warnf("failed to write track file: %s", err)
+	}
+	g.coverage.Update(snap.Data.FixQuality, snap.Data.Location)
+	g.correctionLoss.Update(snap.Data.Location, snap.Data.Alt, &g.rtcmFrames, time.Now())
+	g.speedAlarm.Update(snap.Data.Speed, g.events)
+	g.stations.Update(snap.ReferenceStation, g.events)
+	g.outages.Update(snap.Data.FixQuality, snap.Data.Location, time.Now(), g.events)
+	accuracy := rtkutils.EpochAccuracy(snap)
+	if nav, ok := g.nav.Accuracy(time.Now()); ok {
+		nav.Add(accuracy)
+	}
+	g.jumps.Update(snap, accuracy, time.Now(), g.events)
+	g.heading.Update(snap.Heading, g.compassHeading(snap.Heading))
+	if g.robotFrame != nil {
+		// the fallback may ask another sensor, which only the robot frame needs every epoch
+		degrees, source := g.receiverHeading(snap.Heading)
+		if source == "" {
+			degrees, _ = g.heading.Heading(g.cancelCtx)
+		}
+		g.robotFrame.Heading(degrees, time.Now())
+	}
+	if g.telemetry != nil && snap.Data.FixQuality != 0 {
+		g.sendTelemetry(snap, accuracy)
+	}
+	if g.mavlink != nil {
+		g.sendGPSInput(snap, accuracy)
+	}
+	if err := g.antenna.Check(g.cancelCtx, time.Now(), g.events); err != nil {
+		g.logger.Warnf("antenna failover: %s", err)
+	}
+}
+
+// sendTelemetry sends the position of an epoch with a fix, with its accuracy, as the rover reports it.
+func (g *gpsRTK) sendTelemetry(snap rtkutils.Snapshot, accuracy map[string]float32) {
+	pos, alt := g.reported(snap.Data.Location, snap.Data.Alt)
+	heading, _ := g.receiverHeading(snap.Heading)
+	g.telemetry.Send(rtkutils.TelemetryPosition{
+		Name:       g.Name().Name,
+		Position:   pos,
+		Alt:        alt,
+		FixQuality: snap.Data.FixQuality,
+		Satellites: snap.Data.SatsInUse,
+		HDOP:       snap.Data.HDOP,
+		Speed:      snap.Data.Speed,
+		Heading:    heading,
+		Accuracy:   float64(accuracy[rtkutils.AccuracyHorizontal]),
+	}, time.Now())
+}
+
+// sendGPSInput sends the autopilot the position of an epoch as the rover reports it, with no position
+// without a fix. Its yaw is the heading of a dual antenna or dead reckoning receiver, not the course.
+func (g *gpsRTK) sendGPSInput(snap rtkutils.Snapshot, accuracy map[string]float32) {
+	pos := rtkutils.MAVLinkPosition{
+		Time:               time.Now(),
+		FixQuality:         snap.Data.FixQuality,
+		Satellites:         snap.Data.SatsInUse,
+		HDOP:               snap.Data.HDOP,
+		VDOP:               snap.Data.VDOP,
+		Speed:              snap.Data.Speed,
+		Course:             math.NaN(),
+		ClimbRate:          math.NaN(),
+		Yaw:                math.NaN(),
+		HorizontalAccuracy: float64(accuracy[rtkutils.AccuracyHorizontal]),
+		VerticalAccuracy:   float64(accuracy[rtkutils.AccuracyVertical]),
+		SpeedAccuracy:      float64(accuracy[rtkutils.AccuracySpeed]),
+	}
+	if snap.Data.FixQuality != 0 && snap.Data.Location != nil {
+		pos.Position, pos.Alt = g.reported(snap.Data.Location, snap.Data.Alt)
+	}
+	if snap.Heading.Valid && !snap.Heading.DualAntenna {
+		pos.Course = snap.Heading.Degrees
+	}
+	if snap.ClimbRateValid {
+		pos.ClimbRate = snap.ClimbRate
+	}
+	if degrees, source := g.receiverHeading(snap.Heading); source != "" && source != rtkutils.HeadingSourceCourseOverGround {
+		pos.Yaw = degrees
+	}
+	g.mavlink.GPSInput(pos)
+}
+
+// Position returns the current geographic location of the MOVEMENTSENSOR, at the precision the
+// privacy settings allow. When it fails, it returns the last known location, if any, along with an
+// rtkutils.LastPositionError telling how old it is. Once corrections are lost, the correction loss
+// policy may hold the position or fail instead.
+func (g *gpsRTK) Position(ctx context.Context, extra map[string]interface{}) (*geo.Point, float64, error) {
+	lostPos, lostAlt, holding, err := g.correctionLoss.Position(&g.rtcmFrames, time.Now())
+	if err != nil {
+		lastErr := g.lastPositionError(err)
+		return lastErr.Position, 0, lastErr
+	}
+	if holding {
+		pos, alt := g.reported(lostPos, lostAlt)
+		return pos, alt, nil
+	}
+	// calls pinned to an epoch, such as from Readings, get that epoch
+	if _, pinned, _ := rtkutils.RequestedEpoch(extra); !pinned && g.hold.Republishing() && g.err.Get(receiverPaths...) == nil {
+		if held, ok := g.hold.Current(time.Now()); ok {
+			pos, alt := g.reported(held.Point, held.Alt)
+			return pos, alt, nil
+		}
+	}
+	pos, alt, err := g.precisePosition(extra)
+	if err != nil {
+		lastErr := g.lastPositionError(err)
+		return lastErr.Position, 0, lastErr
+	}
+	pos, alt = g.reported(pos, alt)
+	return pos, alt, nil
+}
+
+// reported returns pos and alt of the antenna as the rover reports them: in its position frame and
+// output datum with its calibrated offset, at the precision the privacy settings allow.
+func (g *gpsRTK) reported(pos *geo.Point, alt float64) (*geo.Point, float64) {
+	pos, alt = g.robotFrame.ToRobot(pos, alt, time.Now())
+	return g.privacy.Reduce(g.offset.Apply(g.datum.Transform(pos, alt, time.Now()))), alt
+}
+
+// lastPositionError returns err with the last position the rover knew, at the precision it reports.
+func (g *gpsRTK) lastPositionError(err error) *rtkutils.LastPositionError {
+	category := g.err.Category(err)
+	if errors.Is(err, errNilLocation) {
+		category = rtkutils.ErrorParse
+	}
+	g.lastPositionMu.Lock()
+	readAt := g.lastPositionAt
+	g.lastPositionMu.Unlock()
+	lastErr := &rtkutils.LastPositionError{Err: err, Category: category}
+	if last := g.lastposition.GetLastPosition(); last != nil && !readAt.IsZero() {
+		lastErr.Position, _ = g.reported(last, 0)
+		lastErr.Age = time.Since(readAt)
+	}
+	return lastErr
+}
+
+// precisePosition returns the current geographic location at full precision.
+func (g *gpsRTK) precisePosition(extra map[string]interface{}) (*geo.Point, float64, error) {
+	lastPosition := g.lastposition.GetLastPosition()
+	if lastError := g.err.Get(receiverPaths...); lastError != nil {
+		return lastPosition, 0, lastError
+	}
+
+	g.dataMu.RLock()
+	defer g.dataMu.RUnlock()
+
+	snap, err := g.snapshot(extra)
+	if err != nil {
+		return lastPosition, 0, err
+	}
+
+	currentPosition := snap.Data.Location
+
+	if currentPosition == nil {
+		return lastPosition, 0, errNilLocation
+	}
+
+	// if current position is (0,0) we will return the last non zero position
+	if g.lastposition.IsZeroPosition(currentPosition) && !g.lastposition.IsZeroPosition(lastPosition) {
+		return lastPosition, snap.Data.Alt, g.err.Get(receiverPaths...)
+	}
+
+	// updating lastposition if it is different from the current position
+	if !g.lastposition.ArePointsEqual(currentPosition, lastPosition) {
+		g.lastposition.SetLastPosition(currentPosition)
+	}
+
+	// updating the last known valid position if the current position is non-zero
+	if !g.lastposition.IsZeroPosition(currentPosition) && !g.lastposition.IsPositionNaN(currentPosition) {
+		g.lastposition.SetLastPosition(currentPosition)
+		g.lastPositionMu.Lock()
+		g.lastPositionAt = time.Now()
+		g.lastPositionMu.Unlock()
+	}
+
+	return currentPosition, snap.Data.Alt, g.err.Get(receiverPaths...)
+}
+
+// LinearVelocity returns the speed as Y and the vertical velocity, positive up, as Z. The vertical
+// velocity is 0 while it is unknown.
+func (g *gpsRTK) LinearVelocity(ctx context.Context, extra map[string]interface{}) (r3.Vector, error) {
+	lastError := g.err.Get(receiverPaths...)
+	if lastError != nil {
+		return r3.Vector{}, lastError
+	}
+
+	g.dataMu.RLock()
+	defer g.dataMu.RUnlock()
+	snap, err := g.snapshot(extra)
+	if err != nil {
+		return r3.Vector{}, err
+	}
+	vertical, _ := g.nav.EpochVerticalVelocity(snap, time.Now())
+	return r3.Vector{X: 0, Y: snap.Data.Speed, Z: vertical}, g.err.Get(receiverPaths...)
+}
+
+// LinearAcceleration not supported.
+func (g *gpsRTK) LinearAcceleration(ctx context.Context, extra map[string]interface{}) (r3.Vector, error) {
+	return r3.Vector{}, movementsensor.ErrMethodUnimplementedLinearAcceleration
+}
+
+// AngularVelocity not supported.
+func (g *gpsRTK) AngularVelocity(ctx context.Context, extra map[string]interface{}) (spatialmath.AngularVelocity, error) {
+	return spatialmath.AngularVelocity{}, movementsensor.ErrMethodUnimplementedAngularVelocity
+}
+
+// CompassHeading returns the dual antenna heading, or the attitude heading of a dead reckoning
+// receiver, or the course over ground, corrected by the configured heading offset. Without any it
+// is the heading of the fallback, NaN if there is none.
+func (g *gpsRTK) CompassHeading(ctx context.Context, extra map[string]interface{}) (float64, error) {
+	lastError := g.err.Get(receiverPaths...)
+	if lastError != nil {
+		return math.NaN(), lastError
+	}
+
+	g.dataMu.RLock()
+	snap, err := g.snapshot(extra)
+	g.dataMu.RUnlock()
+	if err != nil {
+		return math.NaN(), err
+	}
+	if degrees, source := g.receiverHeading(snap.Heading); source != "" {
+		return degrees, g.err.Get(receiverPaths...)
+	}
+	// the fallback may ask another sensor, which isn't done holding the data lock
+	degrees, _ := g.heading.Heading(ctx)
+	return degrees, g.err.Get(receiverPaths...)
+}
+
+// receiverHeading returns the compass heading the receiver reports with the heading of an epoch and
+// its source, an empty source if there is none. The attitude is the latest, whatever the epoch.
+func (g *gpsRTK) receiverHeading(heading rtkutils.Heading) (float64, string) {
+	if heading.Valid && heading.DualAntenna {
+		return g.compassHeading(heading), rtkutils.HeadingSourceDualAntenna
+	}
+	if att, ok := g.nav.Attitude(time.Now()); ok {
+		return rtkutils.NormalizeHeading(att.Heading + g.conf.HeadingOffsetDegrees), rtkutils.HeadingSourceAttitude
+	}
+	if heading.Valid && !g.conf.DisableCourseHeading {
+		return g.compassHeading(heading), rtkutils.HeadingSourceCourseOverGround
+	}
+	return math.NaN(), ""
+}
+
+// compassHeading returns the compass heading of a heading of the receiver.
+func (g *gpsRTK) compassHeading(heading rtkutils.Heading) float64 {
+	return rtkutils.NormalizeHeading(heading.Degrees + g.conf.HeadingOffsetDegrees)
+}
+
+// Orientation returns the attitude of a dead reckoning receiver, with the compass heading as a yaw
+// counterclockwise from north. Other receivers have none.
+func (g *gpsRTK) Orientation(ctx context.Context, extra map[string]interface{}) (spatialmath.Orientation, error) {
+	if !g.profile.Attitude {
+		return spatialmath.NewZeroOrientation(), movementsensor.ErrMethodUnimplementedOrientation
+	}
+	if lastError := g.err.Get(receiverPaths...); lastError != nil {
+		return spatialmath.NewZeroOrientation(), lastError
+	}
+	att, ok := g.nav.Attitude(time.Now())
+	if !ok {
+		return spatialmath.NewZeroOrientation(), nil
+	}
+	return &spatialmath.EulerAngles{
+		Roll:  att.Roll * math.Pi / 180,
+		Pitch: att.Pitch * math.Pi / 180,
+		Yaw:   -rtkutils.NormalizeHeading(att.Heading+g.conf.HeadingOffsetDegrees) * math.Pi / 180,
+	}, nil
+}
+
+// Properties reports what the configuration and the receiver support: a compass heading needs a
+// course over ground, a dual antenna heading, an attitude or a heading sensor, and an orientation a
+// dead reckoning receiver.
+func (g *gpsRTK) Properties(ctx context.Context, extra map[string]interface{}) (*movementsensor.Properties, error) {
+	return &movementsensor.Properties{
+		LinearVelocitySupported: true,
+		PositionSupported:       true,
+		CompassHeadingSupported: g.heading.Supported(),
+		OrientationSupported:    g.profile.Attitude,
+	}, nil
+}
+
+// Accuracy returns the accuracy of the epoch, see rtkutils.EpochAccuracy, with the accuracy a u-blox
+// receiver estimates for its solution and attitude.
+func (g *gpsRTK) Accuracy(ctx context.Context, extra map[string]interface{}) (map[string]float32, error) {
+	lastError := g.err.Get(receiverPaths...)
+	if lastError != nil {
+		return map[string]float32{}, lastError
+	}
+
+	g.dataMu.RLock()
+	defer g.dataMu.RUnlock()
+	snap, err := g.snapshot(extra)
+	if err != nil {
+		return map[string]float32{}, err
+	}
+	accuracy := rtkutils.EpochAccuracy(snap)
+	if nav, ok := g.nav.Accuracy(time.Now()); ok {
+		nav.Add(accuracy)
+	}
+	if att, ok := g.nav.Attitude(time.Now()); ok {
+		// the heading of a dead reckoning receiver is that of its attitude rather than of its motion
+		accuracy[rtkutils.AccuracyHeading] = float32(att.HeadingAccuracy)
+	}
+	return accuracy, g.err.Get(receiverPaths...)
+}
+
+// snapshot returns the epoch pinned in extra, or the latest epoch if none is pinned.
+// The caller must hold dataMu.
+func (g *gpsRTK) snapshot(extra map[string]interface{}) (rtkutils.Snapshot, error) {
+	epoch, pinned, err := rtkutils.RequestedEpoch(extra)
+	if err != nil || !pinned || epoch == g.latest.Epoch {
+		return g.latest, err
+	}
+	return g.epochs.Get(epoch)
+}
+
+// Readings will use the MovementSensor Readings, with every value taken from the same nmea epoch.
+func (g *gpsRTK) Readings(ctx context.Context, extra map[string]interface{}) (map[string]interface{}, error) {
+	// without an epoch or a device, or after a fatal error, there is nothing to read but the state,
+	// and the rover isn't rtk ok
+	state := g.state()
+	if state == rtkutils.StateInitializing || state == rtkutils.StateWaitingForDevice || state == rtkutils.StateNoData ||
+		state == rtkutils.StateError {
+		return map[string]interface{}{rtkutils.StateKey: state, "rtk_ok": false}, nil
+	}
+
+	g.dataMu.RLock()
+	extra = rtkutils.PinEpoch(extra, g.latest.Epoch)
+	g.dataMu.RUnlock()
+
+	readings, err := movementsensor.Readings(ctx, g, extra)
+	if err != nil {
+		return nil, err
+	}
+	readings[rtkutils.EpochKey] = extra[rtkutils.EpochKey]
+	if compass, ok := readings["compass"].(float64); ok {
+		rtkutils.AddHeadingReadings(readings, compass)
+	}
+
+	g.dataMu.RLock()
+	snap, err := g.snapshot(extra)
+	g.dataMu.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+	readings["moving"] = snap.Moving
+	readings["zupt"] = snap.ZeroVelocity
+	readings["vertical_velocity_mps"] = math.NaN()
+	vertical, source := g.nav.EpochVerticalVelocity(snap, time.Now())
+	if source != "" {
+		readings["vertical_velocity_mps"] = vertical
+	}
+	readings["vertical_velocity_source"] = source
+	readings[rtkutils.StateKey] = state
+	interlock, _ := g.interlock()
+	readings["rtk_ok"] = interlock.OK
+	readings[rtkutils.RestartsKey] = g.workers.Restarts()
+	if g.speedAlarm != nil {
+		readings["overspeed"] = g.speedAlarm.Overspeed()
+	}
+	if g.verifier != nil {
+		readings["rtcm_frames_rejected"] = g.verifier.Rejected()
+	}
+	for name, value := range g.radioLink.Readings() {
+		readings[name] = value
+	}
+	readings["rtcm_frames_duplicate"] = g.frameFilter.Duplicates()
+	readings["rtcm_frames_out_of_order"] = g.frameFilter.OutOfOrder()
+	readings["rtcm_frames_dropped"] = g.correctionQueue.Dropped()
+	readings["rtcm_write_stalls"] = g.correctionQueue.Stalls()
+	readings["receiver_rtcm_frames"] = g.receiverFrames.Get()
+	for name, value := range g.rtcmStats.Readings(time.Now()) {
+		readings[name] = value
+	}
+	for name, value := range g.correctionLoss.Readings(&g.rtcmFrames, time.Now()) {
+		readings[name] = value
+	}
+	for name, value := range g.robotFrame.Readings() {
+		readings[name] = value
+	}
+	for name, value := range g.offset.Readings() {
+		readings[name] = value
+	}
+	for name, value := range g.paused.Readings(time.Now()) {
+		readings[name] = value
+	}
+	for name, value := range g.degrader.Readings() {
+		readings[name] = value
+	}
+	for name, value := range g.nmeaBuffer.Readings("nmea") {
+		readings[name] = value
+	}
+	if g.correctionBuffer != nil {
+		for name, value := range g.correctionBuffer.Readings("correction") {
+			readings[name] = value
+		}
+	}
+	station, changes := g.stations.Station()
+	if station != "" {
+		readings["reference_station_id"] = station
+	}
+	readings["reference_station_changes"] = changes
+	for name, value := range g.jumps.Readings() {
+		readings[name] = value
+	}
+	for name, value := range g.glonass.Readings(time.Now()) {
+		readings[name] = value
+	}
+	for name, value := range g.rtcmStations.Readings(time.Now()) {
+		readings[name] = value
+	}
+	if g.power != nil {
+		readings["receiver_power_cycles"] = g.power.Cycles()
+	}
+	if g.configuresReceiver() {
+		for name, value := range g.configWatch.Readings() {
+			readings[name] = value
+		}
+		for name, value := range g.rates.Readings() {
+			readings[name] = value
+		}
+	}
+	if g.antenna != nil {
+		readings["active_antenna"] = g.antenna.Active()
+		readings["antenna_cnr_db_hz"] = g.antenna.CNR(time.Now())
+	}
+	if g.chrony != nil {
+		readings["chrony_samples_sent"] = g.chrony.Sent()
+	}
+	for name, value := range g.telemetry.Readings() {
+		readings[name] = value
+	}
+	for name, value := range g.mavlink.Readings() {
+		readings[name] = value
+	}
+	if held, ok := g.hold.Current(time.Now()); ok {
+		readings["position_age_sec"] = held.Age.Seconds()
+	}
+	for key, value := range g.solutionStatus(snap).Readings() {
+		readings[key] = value
+	}
+	if g.profile.PPP {
+		for key, value := range snap.PPP.Readings() {
+			readings[key] = value
+		}
+	}
+
+	return readings, nil
+}
+
+// solutionStatus returns the latest solution status of a u-blox receiver, or the status inferred
+// from the fix quality of the epoch for other receivers, or a u-blox receiver that doesn't send it.
+func (g *gpsRTK) solutionStatus(snap rtkutils.Snapshot) rtkutils.SolutionStatus {
+	if status, ok := g.nav.SolutionStatus(time.Now()); ok {
+		return status
+	}
+	return rtkutils.GGASolutionStatus(snap.Data.FixQuality)
+}
+
+// interlock returns whether the rover is rtk ok, and what it was judged on.
+func (g *gpsRTK) interlock() (rtkutils.InterlockStatus, rtkutils.InterlockInput) {
+	g.dataMu.RLock()
+	snap := g.latest
+	g.dataMu.RUnlock()
+	now := time.Now()
+	input := rtkutils.InterlockInput{
+		Snapshot:      snap,
+		EpochAge:      now.Sub(g.published.Last()),
+		CorrectionAge: now.Sub(g.rtcmFrames.Last()),
+		Corrections:   g.rtcmFrames.Get() > 0,
+		HasSource:     g.conf.CorrectionSource.Transport != "",
+	}
+	return g.conf.interlockConditions().Check(input), input
+}
+
+// state returns the state of the rover for its readings.
+func (g *gpsRTK) state() string {
+	g.dataMu.RLock()
+	fixQuality, ppp := g.latest.Data.FixQuality, g.latest.PPP
+	g.dataMu.RUnlock()
+	now := time.Now()
+	state := rtkutils.PPPState(rtkutils.RoverState(g.err, &g.published, fixQuality, now), ppp)
+	if g.conf.AllowGPSOnly {
+		state = rtkutils.GPSOnlyState(state, &g.rtcmFrames, now)
+	}
+	return g.startup.State(rtkutils.DeviceState(state, g.recovery), now)
+}
+
+// writeToReceiver writes a raw message to the receiver.
+func (g *gpsRTK) writeToReceiver(msg []byte) error {
+	rx, err := g.openReceiver()
+	if err != nil {
+		return fmt.Errorf("the receiver is not open: %w", err)
+	}
+	_, err = rx.Write(msg)
+	return err
+}
+
+func (g *gpsRTK) hasRTKFix() bool {
+	g.dataMu.RLock()
+	defer g.dataMu.RUnlock()
+	return rtkutils.IsRTKFix(g.latest.Data.FixQuality)
+}
+
+func (g *gpsRTK) currentEpoch() uint64 {
+	g.dataMu.RLock()
+	defer g.dataMu.RUnlock()
+	return g.latest.Epoch
+}
+
+// Close shuts down the rover.
+func (g *gpsRTK) Close(ctx context.Context) error {
+	return g.closed.Close(func() error { return g.close(ctx) })
+}
+
+func (g *gpsRTK) close(ctx context.Context) error {
+	rtkutils.UntrackResource(g)
+	g.cancelFunc()
+
+	// close the sources before waiting on the workers so reads blocked on them can return, the
+	// correction source first so that the frames read from it are written to the receiver before
+	// its ports are closed rather than cut short
+	g.portsMu.Lock()
+	if g.corrections != nil {
+		if err := g.corrections.Close(); err != nil {
+			g.err.SetPath(g.classes.readCorrections.Path, rtkutils.ErrorRTCM, err)
+			g.logger.Errorf("failed to close the correction source: %s", err)
+		}
+		g.corrections = nil
+	}
+	g.portsMu.Unlock()
+	if !rtkutils.WaitWithTimeout(ctx, &g.rtcmWriters, rtkutils.DefaultFlushTimeout+time.Second) {
+		g.logger.Warn("timed out flushing the corrections to the receiver")
+	}
+
+	g.portsMu.Lock()
+	if g.receiver != nil {
+		if err := g.receiver.Close(); err != nil {
+			g.err.SetPath(g.classes.readNMEA.Path, g.classes.readNMEA.Category, err)
+			g.logger.Errorf("failed to close the receiver: %s", err)
+		}
+		g.receiver = nil
+	}
+	if g.correctionPort != nil {
+		if err := g.correctionPort.Close(); err != nil {
+			g.err.SetPath(g.classes.writeCorrections.Path, g.classes.writeCorrections.Category, err)
+			g.logger.Errorf("failed to close the receiver's correction port: %s", err)
+		}
+		g.correctionPort = nil
+	}
+	g.portsMu.Unlock()
+
+	if !rtkutils.WaitWithTimeout(ctx, &g.activeBackgroundWorkers, rtkutils.DefaultShutdownTimeout) {
+		g.logger.Warn("timed out waiting for background workers to stop")
+	}
+
+	if err := g.parseFailures.Close(); err != nil {
+		g.logger.Errorf("failed to close parse failure log %s", err)
+	}
+
+	if err := g.track.Close(); err != nil {
+		g.logger.Errorf("failed to close track file: %s", err)
+	}
+
+	if err := g.ppk.Close(); err != nil {
+		g.logger.Errorf("failed to close ppk recording %s", err)
+	}
+	g.chrony.Close()
+	g.telemetry.Close()
+	g.mavlink.Close()
+
+	if err := g.err.Get(); err != nil && !errors.Is(err, context.Canceled) {
+		return err
+	}
+	return nil
+}
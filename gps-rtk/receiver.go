@@ -0,0 +1,277 @@
+package gpsrtk
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"go.viam.com/rdk/components/movementsensor"
+
+	rtkutils "rtksystem/rtk-utils"
+)
+
+// reopenReceiver closes the receiver's port, so that the nmea reader opens it and sets up the
+// receiver again, e.g. after a power cycle.
+func (g *gpsRTK) reopenReceiver() {
+	g.portsMu.Lock()
+	rx := g.receiver
+	g.portsMu.Unlock()
+	if rx != nil {
+		g.closeReceiver(rx)
+	}
+}
+
+// setUpReceiver sends the init messages of the receiver profile, or configures a receiver on i2c
+// without any, and enables raw measurement output for ppk or the raw observables command, the
+// solution status of u-blox receivers and the ppp service of receivers that have one, then saves
+// that configuration to the receiver if save_receiver_config is set. It only returns the error of a
+// receiver that isn't there, the others are handled here.
+func (g *gpsRTK) setUpReceiver() error {
+	if len(g.profile.InitPackets)+len(g.profile.InitSentences) > 0 {
+		if err := g.sendInit(); err != nil {
+			if rtkutils.IsDeviceAbsent(err) {
+				return err
+			}
+			g.recovery.Handle(g.cancelCtx, g.classes.configure, err)
+		}
+	} else if g.conf.NMEASource.Transport == TransportI2C {
+		if err := g.configureReceiver(); err != nil {
+			if rtkutils.IsDeviceAbsent(err) {
+				return err
+			}
+			g.recovery.Handle(g.cancelCtx, g.classes.configure, err)
+		}
+	}
+	if g.ppk != nil {
+		if err := rtkutils.EnableRawOutput(g.writeToReceiver); err != nil {
+			if rtkutils.IsDeviceAbsent(err) {
+				return err
+			}
+			g.logger.Warnf("failed to enable raw measurement output for ppk: %s", err)
+		}
+	} else if g.raw.Enabled() {
+		// enabled by the raw observables command before the receiver was reopened
+		if err := g.writeToReceiver(rtkutils.UBXMessageRatePacket(rtkutils.UBXClassRxm, rtkutils.UBXRxmRawx, 1)); err != nil {
+			if rtkutils.IsDeviceAbsent(err) {
+				return err
+			}
+			g.logger.Warnf("failed to enable raw measurement output: %s", err)
+		}
+	}
+	if g.profile.UBX {
+		if err := rtkutils.EnableSolutionStatus(g.writeToReceiver); err != nil {
+			if rtkutils.IsDeviceAbsent(err) {
+				return err
+			}
+			g.logger.Warnf("failed to enable the solution status output: %s", err)
+		}
+		if err := rtkutils.EnableReceiverRTCMStats(g.writeToReceiver); err != nil {
+			if rtkutils.IsDeviceAbsent(err) {
+				return err
+			}
+			g.logger.Warnf("failed to enable the rtcm input status output: %s", err)
+		}
+	}
+	if g.profile.PPP {
+		// disabled without a ppp service, in case it was enabled before
+		for _, packet := range rtkutils.UnicorePPPPackets(g.conf.PPPService) {
+			if err := g.writeToReceiver(packet); err != nil {
+				if rtkutils.IsDeviceAbsent(err) {
+					return err
+				}
+				g.logger.Warnf("failed to configure the ppp service: %s", err)
+				break
+			}
+		}
+	}
+	if g.conf.SaveReceiverConfig {
+		if err := g.profile.SaveConfig(g.writeToReceiver); err != nil {
+			if rtkutils.IsDeviceAbsent(err) {
+				return err
+			}
+			g.logger.Warnf("failed to save the configuration to the receiver: %s", err)
+		}
+	}
+	return nil
+}
+
+// configuresReceiver reports whether setUpReceiver configures the receiver, which is then watched
+// for losing that configuration.
+func (g *gpsRTK) configuresReceiver() bool {
+	return len(g.profile.InitPackets)+len(g.profile.InitSentences) > 0 ||
+		g.conf.NMEASource.Transport == TransportI2C || g.ppk != nil || g.profile.UBX || g.profile.PPP
+}
+
+// configuredRates returns the output setUpReceiver sets the receiver up to send.
+func (g *gpsRTK) configuredRates() rtkutils.ReceiverRates {
+	rates := g.profile.ConfiguredRates()
+	if len(g.profile.InitPackets)+len(g.profile.InitSentences) == 0 && g.conf.NMEASource.Transport == TransportI2C {
+		// the sentences and rate of configureReceiver
+		for _, typ := range []string{"GLL", "RMC", "VTG", "GGA", "GSA", "GSV"} {
+			rates.Messages[typ] = 1
+		}
+		rates.EpochRate = 1
+	}
+	return rates
+}
+
+// reconfigurations returns the number of times the receiver was found to need setting up again, for
+// losing its configuration or not sending what it was set up to.
+func (g *gpsRTK) reconfigurations() uint64 {
+	return g.configWatch.Resets() + g.rates.Corrections()
+}
+
+// sendInit sends the init packets and sentences of the receiver profile, stopping at the first that fails.
+func (g *gpsRTK) sendInit() error {
+	for _, packet := range g.profile.InitPackets {
+		if err := g.writeToReceiver(packet); err != nil {
+			return err
+		}
+	}
+	for _, body := range g.profile.InitSentences {
+		sentence, err := rtkutils.NMEASentence(body)
+		if err != nil {
+			return err
+		}
+		if err := g.writeToReceiver(sentence); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// configureReceiver sets the baud rate of a receiver on i2c and has it send GLL, RMC, VTG, GGA,
+// GSA and GSV sentences every 1000ms.
+func (g *gpsRTK) configureReceiver() error {
+	baudRate := g.conf.NMEASource.I2CBaudRate
+	if baudRate == 0 {
+		baudRate = defaultBaudRate
+	}
+	cmd251 := movementsensor.PMTKAddChk([]byte(fmt.Sprintf("PMTK251,%d", baudRate)))
+	cmd314 := movementsensor.PMTKAddChk([]byte("PMTK314,1,1,1,1,1,1,0,0,0,0,0,0,0,0,0,0,0,0,0"))
+	cmd220 := movementsensor.PMTKAddChk([]byte("PMTK220,1000"))
+
+	if err := g.writeToReceiver(cmd251); err != nil {
+		g.logger.Errorf("Failed to set baud rate")
+	}
+	if err := g.writeToReceiver(cmd314); err != nil {
+		return err
+	}
+	return g.writeToReceiver(cmd220)
+}
+
+func (g *gpsRTK) readNMEAMessages() error {
+	var port rtkutils.PortDemux
+	buf := make([]byte, g.nmeaBuffer.Size())
+	setUp := false
+	var setUpCycles uint64 // power cycles before the receiver was last set up
+	var setUpResets uint64 // reconfigurations found needed before the receiver was last set up
+	for g.cancelCtx.Err() == nil {
+		if !setUp || setUpCycles != g.power.Cycles() || setUpResets != g.reconfigurations() {
+			// the receiver may only be powered on after the module, set it up once it is there, and
+			// again after a power cycle, a brownout lost its configuration or it ignored part of it
+			cycles, resets := g.power.Cycles(), g.reconfigurations()
+			if err := g.setUpReceiver(); err != nil {
+				if g.cancelCtx.Err() != nil || g.recovery.Handle(g.cancelCtx, g.classes.openNMEA, err) == rtkutils.Rebuild {
+					return rtkutils.ErrRebuildRequired
+				}
+				continue
+			}
+			setUp, setUpCycles, setUpResets = true, cycles, resets
+			if g.configuresReceiver() {
+				g.configWatch.SetUp(time.Now())
+				g.rates.SetUp(g.configuredRates(), time.Now())
+			}
+		}
+
+		rx, err := g.openReceiver()
+		if err != nil {
+			if g.cancelCtx.Err() != nil || g.recovery.Handle(g.cancelCtx, g.classes.openNMEA, err) == rtkutils.Rebuild {
+				return rtkutils.ErrRebuildRequired
+			}
+			continue
+		}
+		g.recovery.Succeeded(g.classes.openNMEA)
+
+		err = g.readNMEAFrom(rx, buf, &port)
+		if g.cancelCtx.Err() != nil {
+			// the receiver was closed on shutdown
+			return nil
+		}
+		// the sentence or frame being read lost the bytes of the failed read
+		port.Reset()
+		if setUpResets != g.reconfigurations() {
+			// the receiver was closed to set it up again
+			continue
+		}
+		switch g.recovery.Handle(g.cancelCtx, g.classes.readNMEA, err) {
+		case rtkutils.Rebuild:
+			return rtkutils.ErrRebuildRequired
+		case rtkutils.Reopen:
+			g.closeReceiver(rx)
+		}
+	}
+	return nil
+}
+
+// readNMEAFrom parses the nmea sentences read from rx until reading fails. The rtcm frames the
+// receiver sends on the same port, e.g. as a moving base, are counted but not parsed.
+func (g *gpsRTK) readNMEAFrom(rx io.Reader, buf []byte, port *rtkutils.PortDemux) error {
+	for {
+		n, err := rx.Read(buf)
+		if err != nil {
+			return err
+		}
+		read := time.Now()
+		g.recovery.Succeeded(g.classes.readNMEA)
+		if g.conf.NMEASource.Transport == TransportSerial {
+			// the reads of an i2c receiver are recorded by its reader
+			g.nmeaBuffer.Record(n)
+		}
+		// with ppk recording the receiver also sends binary raw measurements, which are recorded as read
+		g.ppk.Rover().Write(buf[:n])
+		g.nav.Write(buf[:n])
+		g.rtcmStats.Write(buf[:n])
+		g.raw.Write(buf[:n])
+		port.Split(buf[:n], read, func(sentence string) {
+			g.parseNMEA(sentence)
+			g.nmeaLatency.Record(time.Since(read))
+		}, func([]byte) { g.receiverFrames.Add(1) })
+	}
+}
+
+// parseNMEA parses a sentence read from the receiver into the current epoch.
+func (g *gpsRTK) parseNMEA(sentence string) {
+	now := time.Now()
+	// checked by its checksum, unlike the sentences parsed below
+	g.rates.Sentence(sentence, now)
+	// raw measurements are binary, and can look like the start of a sentence
+	if (g.ppk != nil || g.raw.Enabled()) && !strings.Contains(sentence, "$G") {
+		return
+	}
+	g.antenna.Update(sentence, now)
+	g.clock.Update(sentence, now)
+	// Update the pending epoch and publish the previous one once it is complete
+	g.dataMu.Lock()
+	snap, published, err := g.epochs.ParseAndUpdate(sentence)
+	if published {
+		g.latest = snap
+	}
+	g.dataMu.Unlock()
+	if published {
+		g.onEpoch(snap)
+	}
+	g.sentences.Record(sentence, err)
+	if g.configWatch.Sentence(sentence, now, g.events) {
+		g.reopenReceiver()
+	}
+	switch {
+	case errors.Is(err, rtkutils.ErrUnsupportedSentence):
+		g.logger.Debugf("ignoring nmea sentence: %v", err)
+	case err != nil:
+		g.err.Record(rtkutils.ErrorParse, err)
+		g.logger.Warnf("can't parse nmea sentence: %v", g.parseFailures.Record(sentence, err))
+	}
+}
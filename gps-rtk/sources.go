@@ -0,0 +1,582 @@
+package gpsrtk
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"time"
+
+	"github.com/d2r2/go-i2c"
+	slib "github.com/jacobsa/go-serial/serial"
+	"go.viam.com/utils"
+
+	rtkutils "rtksystem/rtk-utils"
+)
+
+// Transports of the nmea and correction sources.
+const (
+	TransportSerial = "serial"
+	TransportI2C    = "i2c"
+	TransportTCP    = "tcp"
+	TransportNtrip  = "ntrip"
+	TransportRemote = "remote"
+	TransportReplay = "replay"
+)
+
+const (
+	defaultBaudRate = 38400
+	dialTimeout     = 10 * time.Second
+	// a tcp source sending nothing for this long has dropped, bases send corrections every second
+	tcpReadTimeout = 30 * time.Second
+)
+
+// NMEASourceConfig is the connection to the rover's receiver. NMEA sentences are read from it and
+// corrections are written to it, or to another port of the receiver if correction_transport is set.
+type NMEASourceConfig struct {
+	Transport string `json:"transport"`
+
+	SerialPath     string `json:"serial_path,omitempty"`
+	SerialBaudRate int    `json:"serial_baud_rate,omitempty"`
+
+	I2CBus      int `json:"i2c_bus,omitempty"`
+	I2CAddr     int `json:"i2c_addr,omitempty"`
+	I2CBaudRate int `json:"i2c_baud_rate,omitempty"`
+
+	// Corrections are written to the receiver over this transport, serial or i2c, instead of the one
+	// nmea is read from, e.g. to the i2c port of a receiver whose only wired uart sends nmea, or to a
+	// second uart taking rtcm
+	CorrectionTransport      string `json:"correction_transport,omitempty"`
+	CorrectionSerialPath     string `json:"correction_serial_path,omitempty"`
+	CorrectionSerialBaudRate int    `json:"correction_serial_baud_rate,omitempty"`
+	// the bus of an i2c nmea source by default
+	CorrectionI2CBus  int `json:"correction_i2c_bus,omitempty"`
+	CorrectionI2CAddr int `json:"correction_i2c_addr,omitempty"`
+
+	// How much is read from the receiver at once, rtkutils.DefaultReadBufferSize by default
+	ReadBufferSize int `json:"read_buffer_size,omitempty"`
+
+	// The u-blox receiver, zed-f9p by default, see rtkutils.Receiver
+	Receiver string `json:"receiver,omitempty"`
+}
+
+// CorrectionSourceConfig is where the rover reads corrections from: a radio or station receiver
+// connected over serial or i2c, a tcp stream, an NTRIP caster, a correction station on another
+// robot or a recorded file.
+type CorrectionSourceConfig struct {
+	Transport string `json:"transport"`
+
+	SerialPath     string `json:"serial_path,omitempty"`
+	SerialBaudRate int    `json:"serial_baud_rate,omitempty"`
+
+	// the bus of an i2c nmea source by default
+	I2CBus  int `json:"i2c_bus,omitempty"`
+	I2CAddr int `json:"i2c_addr,omitempty"`
+
+	// How much is read from a serial or i2c source at once, rtkutils.DefaultReadBufferSize by default
+	ReadBufferSize int `json:"read_buffer_size,omitempty"`
+
+	// host:port of a tcp source
+	Addr string `json:"addr,omitempty"`
+
+	NtripURL        string `json:"ntrip_url,omitempty"`
+	NtripMountpoint string `json:"ntrip_mountpoint,omitempty"`
+	NtripUsername   string `json:"ntrip_username,omitempty"`
+	NtripPassword   string `json:"ntrip_password,omitempty"`
+
+	// TLS on a tcp or ntrip source, see rtkutils.TLSConfig
+	TLS         bool   `json:"tls,omitempty"`
+	TLSCAFile   string `json:"tls_ca_file,omitempty"`
+	TLSCertFile string `json:"tls_cert_file,omitempty"`
+	TLSKeyFile  string `json:"tls_key_file,omitempty"`
+
+	// Key shared with the station to drop frames it didn't send, see rtkutils.FrameVerifier
+	AuthKey string `json:"auth_key,omitempty"`
+
+	// A serial radio carries the frames of a station whose output sets radio_framing, see
+	// rtkutils.RadioLink
+	RadioFraming bool `json:"radio_framing,omitempty"`
+
+	// name of the station on another robot, e.g. "base-robot:station1"
+	RemoteCorrectionStation string `json:"remote_correction_station,omitempty"`
+
+	ReplayFile string `json:"replay_file,omitempty"`
+	ReplayLoop bool   `json:"replay_loop,omitempty"`
+}
+
+func (cfg *Config) validateNMEASource(path string) error {
+	source := cfg.NMEASource
+	switch source.Transport {
+	case "":
+		return utils.NewConfigValidationFieldRequiredError(path, "nmea_source.transport")
+	case TransportSerial:
+		if source.SerialPath == "" {
+			return utils.NewConfigValidationFieldRequiredError(path, "nmea_source.serial_path")
+		}
+		if !cfg.SkipDeviceCheck {
+			if err := rtkutils.ValidateDevicePath("nmea_source.serial_path", source.SerialPath); err != nil {
+				return fmt.Errorf("%s: %w", path, err)
+			}
+		}
+	case TransportI2C:
+		if source.I2CBus == 0 {
+			return utils.NewConfigValidationFieldRequiredError(path, "nmea_source.i2c_bus")
+		}
+		if source.I2CAddr == 0 {
+			return utils.NewConfigValidationFieldRequiredError(path, "nmea_source.i2c_addr")
+		}
+		if err := rtkutils.ValidateI2CAddr("nmea_source.i2c_addr", source.I2CAddr); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		if !cfg.SkipDeviceCheck {
+			if err := rtkutils.ValidateDevicePath("nmea_source.i2c_bus", rtkutils.I2CBusPath(source.I2CBus)); err != nil {
+				return fmt.Errorf("%s: %w", path, err)
+			}
+		}
+	default:
+		return fmt.Errorf("%s: nmea_source.transport %q isn't supported, use %s or %s",
+			path, source.Transport, TransportSerial, TransportI2C)
+	}
+	if err := cfg.validateCorrectionPort(path); err != nil {
+		return err
+	}
+	if err := rtkutils.ValidateRoverReceiver("nmea_source.receiver", source.Receiver, cfg.PPKRecordDir != ""); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	if err := rtkutils.ValidateReadBufferSize("nmea_source.read_buffer_size", source.ReadBufferSize); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
+
+// validateCorrectionPort validates the port corrections are written to the receiver through, if it
+// isn't the one nmea is read from.
+func (cfg *Config) validateCorrectionPort(path string) error {
+	source := cfg.NMEASource
+	switch source.CorrectionTransport {
+	case "":
+		if source.CorrectionSerialPath != "" || source.CorrectionI2CBus != 0 || source.CorrectionI2CAddr != 0 {
+			return utils.NewConfigValidationFieldRequiredError(path, "nmea_source.correction_transport")
+		}
+	case TransportSerial:
+		if source.CorrectionSerialPath == "" {
+			return utils.NewConfigValidationFieldRequiredError(path, "nmea_source.correction_serial_path")
+		}
+		if source.Transport == TransportSerial && rtkutils.SameDevice(source.CorrectionSerialPath, source.SerialPath) {
+			return fmt.Errorf("%s: nmea_source.correction_serial_path is the nmea port %q, "+
+				"leave correction_transport unset to write corrections to it", path, source.SerialPath)
+		}
+		if !cfg.SkipDeviceCheck {
+			if err := rtkutils.ValidateDevicePath("nmea_source.correction_serial_path", source.CorrectionSerialPath); err != nil {
+				return fmt.Errorf("%s: %w", path, err)
+			}
+		}
+	case TransportI2C:
+		bus := cfg.correctionPortBus()
+		if bus == 0 {
+			return utils.NewConfigValidationFieldRequiredError(path, "nmea_source.correction_i2c_bus")
+		}
+		if source.CorrectionI2CAddr == 0 {
+			return utils.NewConfigValidationFieldRequiredError(path, "nmea_source.correction_i2c_addr")
+		}
+		if err := rtkutils.ValidateI2CAddr("nmea_source.correction_i2c_addr", source.CorrectionI2CAddr); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		if source.Transport == TransportI2C && bus == source.I2CBus && source.CorrectionI2CAddr == source.I2CAddr {
+			return fmt.Errorf("%s: nmea_source.correction_i2c_addr is the nmea address %#x, "+
+				"leave correction_transport unset to write corrections to it", path, source.I2CAddr)
+		}
+		if !cfg.SkipDeviceCheck {
+			if err := rtkutils.ValidateDevicePath("nmea_source.correction_i2c_bus", rtkutils.I2CBusPath(bus)); err != nil {
+				return fmt.Errorf("%s: %w", path, err)
+			}
+		}
+	default:
+		return fmt.Errorf("%s: nmea_source.correction_transport %q isn't supported, use %s or %s",
+			path, source.CorrectionTransport, TransportSerial, TransportI2C)
+	}
+	return nil
+}
+
+// validateCorrectionSource validates the correction source, returning the remote station it
+// depends on if any.
+func (cfg *Config) validateCorrectionSource(path string) ([]string, error) {
+	source := cfg.CorrectionSource
+	if err := rtkutils.ValidateAuthKey("correction_source.auth_key", source.AuthKey); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if source.RadioFraming && source.Transport != TransportSerial {
+		return nil, fmt.Errorf("%s: correction_source.radio_framing is only supported over %s", path, TransportSerial)
+	}
+	if source.TLS && source.Transport != TransportTCP && source.Transport != TransportNtrip {
+		return nil, fmt.Errorf("%s: correction_source.tls is only supported over %s and %s", path, TransportTCP, TransportNtrip)
+	}
+	if source.ReadBufferSize != 0 && source.Transport != TransportSerial && source.Transport != TransportI2C {
+		return nil, fmt.Errorf("%s: correction_source.read_buffer_size is only supported over %s and %s", path, TransportSerial, TransportI2C)
+	}
+	if err := rtkutils.ValidateReadBufferSize("correction_source.read_buffer_size", source.ReadBufferSize); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	switch source.Transport {
+	case "":
+		if cfg.PPPService != "" {
+			return nil, nil
+		}
+		return nil, utils.NewConfigValidationFieldRequiredError(path, "correction_source.transport")
+	case TransportSerial:
+		if source.SerialPath == "" {
+			return nil, utils.NewConfigValidationFieldRequiredError(path, "correction_source.serial_path")
+		}
+		if cfg.NMEASource.Transport == TransportSerial && rtkutils.SameDevice(source.SerialPath, cfg.NMEASource.SerialPath) {
+			return nil, fmt.Errorf("%s: nmea_source and correction_source are both %q, "+
+				"corrections are read from the port of the radio or station receiver", path, source.SerialPath)
+		}
+		if !cfg.SkipDeviceCheck && !cfg.AllowGPSOnly {
+			if err := rtkutils.ValidateDevicePath("correction_source.serial_path", source.SerialPath); err != nil {
+				return nil, fmt.Errorf("%s: %w", path, err)
+			}
+		}
+	case TransportI2C:
+		bus := cfg.correctionBus()
+		if bus == 0 {
+			return nil, utils.NewConfigValidationFieldRequiredError(path, "correction_source.i2c_bus")
+		}
+		if source.I2CAddr == 0 {
+			return nil, utils.NewConfigValidationFieldRequiredError(path, "correction_source.i2c_addr")
+		}
+		if err := rtkutils.ValidateI2CAddr("correction_source.i2c_addr", source.I2CAddr); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		if cfg.NMEASource.Transport == TransportI2C && bus == cfg.NMEASource.I2CBus && source.I2CAddr == cfg.NMEASource.I2CAddr {
+			return nil, fmt.Errorf("%s: nmea_source and correction_source are both %#x on i2c bus %d, "+
+				"the rover and station receivers need different addresses", path, source.I2CAddr, bus)
+		}
+		if !cfg.SkipDeviceCheck && !cfg.AllowGPSOnly {
+			if err := rtkutils.ValidateDevicePath("correction_source.i2c_bus", rtkutils.I2CBusPath(bus)); err != nil {
+				return nil, fmt.Errorf("%s: %w", path, err)
+			}
+		}
+	case TransportTCP:
+		if source.Addr == "" {
+			return nil, utils.NewConfigValidationFieldRequiredError(path, "correction_source.addr")
+		}
+		if _, _, err := net.SplitHostPort(source.Addr); err != nil {
+			return nil, fmt.Errorf("%s: correction_source.addr %q must be a host:port: %w", path, source.Addr, err)
+		}
+		if err := source.tlsConfig().Validate("correction_source."); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	case TransportNtrip:
+		if err := source.ntripConfig().Validate(); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	case TransportRemote:
+		if source.RemoteCorrectionStation == "" {
+			return nil, utils.NewConfigValidationFieldRequiredError(path, "correction_source.remote_correction_station")
+		}
+		return []string{source.RemoteCorrectionStation}, nil
+	case TransportReplay:
+		if source.ReplayFile == "" {
+			return nil, utils.NewConfigValidationFieldRequiredError(path, "correction_source.replay_file")
+		}
+	default:
+		return nil, fmt.Errorf("%s: correction_source.transport %q isn't supported, use %s, %s, %s, %s, %s or %s", path,
+			source.Transport, TransportSerial, TransportI2C, TransportTCP, TransportNtrip, TransportRemote, TransportReplay)
+	}
+	return nil, nil
+}
+
+// correctionBus returns the i2c bus of the correction source, the bus of the nmea source unless
+// it is set.
+func (cfg *Config) correctionBus() int {
+	if cfg.CorrectionSource.I2CBus == 0 && cfg.NMEASource.Transport == TransportI2C {
+		return cfg.NMEASource.I2CBus
+	}
+	return cfg.CorrectionSource.I2CBus
+}
+
+// correctionPortBus returns the i2c bus corrections are written to the receiver on, the bus of the
+// nmea source unless it is set.
+func (cfg *Config) correctionPortBus() int {
+	if cfg.NMEASource.CorrectionI2CBus == 0 && cfg.NMEASource.Transport == TransportI2C {
+		return cfg.NMEASource.I2CBus
+	}
+	return cfg.NMEASource.CorrectionI2CBus
+}
+
+func (source CorrectionSourceConfig) ntripConfig() rtkutils.NtripConfig {
+	return rtkutils.NtripConfig{
+		URL:        source.NtripURL,
+		Mountpoint: source.NtripMountpoint,
+		Username:   source.NtripUsername,
+		Password:   source.NtripPassword,
+		TLS:        source.tlsConfig(),
+	}
+}
+
+func (source CorrectionSourceConfig) tlsConfig() rtkutils.TLSConfig {
+	return rtkutils.TLSConfig{
+		Enabled:  source.TLS,
+		CAFile:   source.TLSCAFile,
+		CertFile: source.TLSCertFile,
+		KeyFile:  source.TLSKeyFile,
+	}
+}
+
+// errorClasses are the classes of the errors of the rover's workers.
+type errorClasses struct {
+	openNMEA, readNMEA, writeCorrections, configure rtkutils.ErrorClass
+	openCorrections, readCorrections                rtkutils.ErrorClass
+	// opening the port corrections are written through, openNMEA unless it is a port of its own
+	openCorrectionPort rtkutils.ErrorClass
+}
+
+// classesOf returns the error classes of a rover reading nmea over nmeaTransport, and writing
+// corrections over correctionTransport, or nmeaTransport if it is empty.
+func classesOf(nmeaTransport, correctionTransport string) errorClasses {
+	category := rtkutils.ErrorSerial
+	readPath, writePath := rtkutils.PathNMEARead, rtkutils.PathCorrectionWrite
+	if nmeaTransport == TransportI2C {
+		category = rtkutils.ErrorI2C
+		readPath, writePath = rtkutils.PathI2CBus, rtkutils.PathI2CBus
+	}
+	openPort := rtkutils.ErrorClass{Category: category, Op: rtkutils.OpOpen, Path: readPath}
+	writeCategory := category
+	if correctionTransport != "" {
+		// a port of its own doesn't fail the receiver's nmea
+		writeCategory, writePath = rtkutils.ErrorSerial, rtkutils.PathCorrectionWrite
+		if correctionTransport == TransportI2C {
+			writeCategory = rtkutils.ErrorI2C
+		}
+		openPort = rtkutils.ErrorClass{Category: writeCategory, Op: rtkutils.OpOpen, Path: writePath}
+	}
+	return errorClasses{
+		openNMEA:           rtkutils.ErrorClass{Category: category, Op: rtkutils.OpOpen, Path: readPath},
+		openCorrectionPort: openPort,
+		readNMEA:           rtkutils.ErrorClass{Category: category, Op: rtkutils.OpRead, Path: readPath},
+		writeCorrections:   rtkutils.ErrorClass{Category: writeCategory, Op: rtkutils.OpWrite, Path: writePath},
+		configure:          rtkutils.ErrorClass{Category: category, Op: rtkutils.OpConfigure, Path: readPath},
+		openCorrections:    rtkutils.ErrorClass{Category: rtkutils.ErrorRTCM, Op: rtkutils.OpOpen, Path: rtkutils.PathCorrectionRead},
+		readCorrections:    rtkutils.ErrorClass{Category: rtkutils.ErrorRTCM, Op: rtkutils.OpRead, Path: rtkutils.PathCorrectionRead},
+	}
+}
+
+// recoveryPolicy is how a rover with classes recovers from the errors of its workers.
+func recoveryPolicy(classes errorClasses) rtkutils.RecoveryPolicy {
+	return rtkutils.RecoveryPolicy{
+		Actions: map[rtkutils.ErrorClass]rtkutils.RecoveryAction{
+			// a source that can't be opened yet may still appear, e.g. a receiver plugged in late
+			classes.openNMEA:           rtkutils.Retry,
+			classes.openCorrectionPort: rtkutils.Retry,
+			classes.openCorrections:    rtkutils.Retry,
+			// a source that fails once open was most likely unplugged, reopen it until it is back
+			classes.readNMEA:         rtkutils.Reopen,
+			classes.writeCorrections: rtkutils.Reopen,
+			classes.readCorrections:  rtkutils.Reopen,
+			// an unconfigured receiver still sends its default sentences
+			classes.configure: rtkutils.Surface,
+		},
+		MaxFailures: 30,
+	}
+}
+
+func openSerial(path string, baud int) (io.ReadWriteCloser, error) {
+	if baud == 0 {
+		baud = defaultBaudRate
+	}
+	return slib.Open(slib.OpenOptions{
+		PortName:        path,
+		BaudRate:        uint(baud),
+		DataBits:        8,
+		StopBits:        1,
+		MinimumReadSize: 1,
+	})
+}
+
+// i2cReceiver is a receiver on an i2c bus. Its data buffer is read as a stream, and messages are
+// written to it with a handle opened for every write.
+type i2cReceiver struct {
+	*rtkutils.I2CReader
+	bus  int
+	addr byte
+}
+
+func (r *i2cReceiver) Write(p []byte) (int, error) {
+	return writeI2C(r.bus, r.addr, p)
+}
+
+// i2cWriter is the i2c port of a receiver that corrections are written to, while nmea is read from
+// another of its ports.
+type i2cWriter struct {
+	bus  int
+	addr byte
+}
+
+func (w *i2cWriter) Write(p []byte) (int, error) {
+	return writeI2C(w.bus, w.addr, p)
+}
+
+// Close does nothing, the bus is already closed after every write.
+func (w *i2cWriter) Close() error {
+	return nil
+}
+
+// writeI2C writes p to the device at addr on bus, with a handle opened for the write.
+func writeI2C(bus int, addr byte, p []byte) (int, error) {
+	handle, err := i2c.NewI2C(addr, bus)
+	if err != nil {
+		return 0, err
+	}
+	n, err := handle.WriteBytes(p)
+	if closeErr := handle.Close(); err == nil {
+		err = closeErr
+	}
+	return n, err
+}
+
+// openReceiver returns the handle of the receiver, opening it if needed. The nmea reader and the
+// correction writer share a single handle.
+func (g *gpsRTK) openReceiver() (io.ReadWriteCloser, error) {
+	g.portsMu.Lock()
+	defer g.portsMu.Unlock()
+	if err := g.cancelCtx.Err(); err != nil {
+		return nil, err
+	}
+	if g.receiver != nil {
+		return g.receiver, nil
+	}
+
+	source := g.conf.NMEASource
+	switch source.Transport {
+	case TransportI2C:
+		g.receiver = &i2cReceiver{
+			I2CReader: rtkutils.NewI2CReader(g.cancelCtx, source.I2CBus, byte(source.I2CAddr), g.pollInterval, g.nmeaBuffer),
+			bus:       source.I2CBus,
+			addr:      byte(source.I2CAddr),
+		}
+	default:
+		baud := source.SerialBaudRate
+		if baud == 0 {
+			baud = g.profile.BaudRate
+		}
+		port, err := openSerial(source.SerialPath, baud)
+		if err != nil {
+			return nil, err
+		}
+		g.receiver = port
+	}
+	return g.receiver, nil
+}
+
+// closeReceiver closes rx if it is still the shared handle of the receiver, so that the next
+// openReceiver reopens it.
+func (g *gpsRTK) closeReceiver(rx io.ReadWriteCloser) {
+	g.portsMu.Lock()
+	defer g.portsMu.Unlock()
+	if g.receiver != rx {
+		return
+	}
+	if err := rx.Close(); err != nil {
+		g.err.Record(g.classes.readNMEA.Category, err)
+	}
+	g.receiver = nil
+}
+
+// openCorrectionPort returns the handle corrections are written to the receiver through, opening it if
+// needed. It is the handle of the receiver unless the nmea source has a correction_transport.
+func (g *gpsRTK) openCorrectionPort() (io.WriteCloser, error) {
+	source := g.conf.NMEASource
+	if source.CorrectionTransport == "" {
+		return g.openReceiver()
+	}
+	g.portsMu.Lock()
+	defer g.portsMu.Unlock()
+	if err := g.cancelCtx.Err(); err != nil {
+		return nil, err
+	}
+	if g.correctionPort != nil {
+		return g.correctionPort, nil
+	}
+	switch source.CorrectionTransport {
+	case TransportI2C:
+		g.correctionPort = &i2cWriter{bus: g.conf.correctionPortBus(), addr: byte(source.CorrectionI2CAddr)}
+	default:
+		port, err := openSerial(source.CorrectionSerialPath, source.CorrectionSerialBaudRate)
+		if err != nil {
+			return nil, err
+		}
+		g.correctionPort = port
+	}
+	return g.correctionPort, nil
+}
+
+// closeCorrectionPort closes a handle returned by openCorrectionPort, so that the next
+// openCorrectionPort reopens it.
+func (g *gpsRTK) closeCorrectionPort(w io.WriteCloser) {
+	if rx, ok := w.(io.ReadWriteCloser); ok && g.conf.NMEASource.CorrectionTransport == "" {
+		g.closeReceiver(rx)
+		return
+	}
+	g.portsMu.Lock()
+	defer g.portsMu.Unlock()
+	if g.correctionPort != w {
+		return
+	}
+	if err := w.Close(); err != nil {
+		g.err.Record(g.classes.writeCorrections.Category, err)
+	}
+	g.correctionPort = nil
+}
+
+// openCorrectionSource opens the source corrections are read from.
+func (g *gpsRTK) openCorrectionSource() (io.ReadCloser, error) {
+	g.portsMu.Lock()
+	defer g.portsMu.Unlock()
+	if err := g.cancelCtx.Err(); err != nil {
+		return nil, err
+	}
+
+	source := g.conf.CorrectionSource
+	var reader io.ReadCloser
+	switch source.Transport {
+	case TransportSerial:
+		port, err := openSerial(source.SerialPath, source.SerialBaudRate)
+		if err != nil {
+			return nil, err
+		}
+		reader = g.radioLink.Reader(rtkutils.NewSizedReader(port, g.correctionBuffer))
+	case TransportI2C:
+		reader = rtkutils.NewI2CReader(g.cancelCtx, g.conf.correctionBus(), byte(source.I2CAddr), g.pollInterval, g.correctionBuffer)
+	case TransportTCP:
+		conn, err := rtkutils.DialTCP(g.cancelCtx, source.Addr, dialTimeout, source.tlsConfig())
+		if err != nil {
+			return nil, err
+		}
+		reader = &rtkutils.TimeoutConn{Conn: conn, ReadTimeout: tcpReadTimeout}
+	case TransportNtrip:
+		reader = rtkutils.NewNtripReader(g.cancelCtx, source.ntripConfig(), rtkutils.DefaultNtripRetryInterval, g.logger)
+	case TransportRemote:
+		reader = io.NopCloser(rtkutils.NewRemoteCorrectionReader(
+			g.cancelCtx, g.remoteStation, g.remotePollInterval, g.logger))
+	default:
+		replay, err := rtkutils.NewRTCMReplayReader(g.cancelCtx, source.ReplayFile, source.ReplayLoop)
+		if err != nil {
+			return nil, err
+		}
+		reader = replay
+	}
+	g.corrections = reader
+	return reader, nil
+}
+
+// closeCorrectionSource closes a source returned by openCorrectionSource, unless Close already did.
+func (g *gpsRTK) closeCorrectionSource(reader io.ReadCloser) {
+	g.portsMu.Lock()
+	defer g.portsMu.Unlock()
+	if g.corrections != reader {
+		return
+	}
+	g.corrections = nil
+	if err := reader.Close(); err != nil {
+		g.err.Record(rtkutils.ErrorRTCM, err)
+	}
+}
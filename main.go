@@ -3,11 +3,18 @@ package main
 
 import (
 	"context"
+	"time"
+
+	correctionstation "rtksystem/correction-station"
 	stationi2c "rtksystem/correction-station-i2c"
 	serialstation "rtksystem/correction-station-serial"
 
+	gpsnmea "rtksystem/gps-nmea"
+	gpsrtk "rtksystem/gps-rtk"
 	gpsrtki2cnonetwork "rtksystem/gps-rtk-i2c-no-network"
 	gpsrtkserialnonetwork "rtksystem/gps-rtk-serial-no-network"
+	rtkheading "rtksystem/rtk-heading"
+	rtkutils "rtksystem/rtk-utils"
 
 	"github.com/edaniels/golog"
 	"go.viam.com/rdk/components/movementsensor"
@@ -16,23 +23,35 @@ import (
 	"go.viam.com/utils"
 )
 
+// shutdownTimeout bounds the total time spent releasing devices once the module is asked to stop.
+const shutdownTimeout = 10 * time.Second
+
 func main() {
 	utils.ContextualMain(mainWithArgs, golog.NewDevelopmentLogger("rtk-system"))
 }
 
 func mainWithArgs(ctx context.Context, args []string, logger golog.Logger) error {
+	// flush buffered logs last, after everything else has shut down
+	//nolint:errcheck
+	defer logger.Sync()
+
 	rtkSystem, err := module.NewModuleFromArgs(ctx, logger)
 
 	if err != nil {
 		return err
 	}
+	rtkSystem.AddModelFromRegistry(ctx, sensor.API, correctionstation.Model)
 	rtkSystem.AddModelFromRegistry(ctx, sensor.API, serialstation.Model)
 	rtkSystem.AddModelFromRegistry(ctx, sensor.API, stationi2c.Model)
+	rtkSystem.AddModelFromRegistry(ctx, movementsensor.API, gpsrtk.Model)
 	rtkSystem.AddModelFromRegistry(ctx, movementsensor.API, gpsrtkserialnonetwork.Model)
 	rtkSystem.AddModelFromRegistry(ctx, movementsensor.API, gpsrtki2cnonetwork.Model)
+	rtkSystem.AddModelFromRegistry(ctx, movementsensor.API, rtkheading.Model)
+	rtkSystem.AddModelFromRegistry(ctx, movementsensor.API, gpsnmea.SerialModel)
+	rtkSystem.AddModelFromRegistry(ctx, movementsensor.API, gpsnmea.I2CModel)
 
 	err = rtkSystem.Start(ctx)
-	defer rtkSystem.Close(ctx)
+	defer closeModule(rtkSystem, logger)
 	if err != nil {
 		return err
 	}
@@ -40,3 +59,16 @@ func mainWithArgs(ctx context.Context, args []string, logger golog.Logger) error
 	<-ctx.Done()
 	return nil
 }
+
+// closeModule closes every resource still running so their serial and i2c handles are released,
+// then shuts the module down. ctx is already canceled on SIGTERM, so a fresh bounded one is used.
+// Closing a resource the robot closes as well is harmless, each Close only runs once.
+func closeModule(rtkSystem *module.Module, logger golog.Logger) {
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	if err := rtkutils.Shutdown(ctx); err != nil {
+		logger.Errorf("failed to close all resources: %s", err)
+	}
+	rtkSystem.Close(ctx)
+}
@@ -0,0 +1,486 @@
+// Package rtkheading implements a movement sensor that only serves the heading of a u-blox moving
+// base pair, so that it can be composed with a separately configured position sensor.
+package rtkheading
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/d2r2/go-i2c"
+	"github.com/edaniels/golog"
+	"github.com/golang/geo/r3"
+	slib "github.com/jacobsa/go-serial/serial"
+	geo "github.com/kellydunn/golang-geo"
+	"go.viam.com/rdk/components/movementsensor"
+	"go.viam.com/rdk/resource"
+	"go.viam.com/rdk/spatialmath"
+	"go.viam.com/utils"
+
+	rtkutils "rtksystem/rtk-utils"
+)
+
+var Model = resource.NewModel("viam-labs", "movement-sensor", "rtk-heading")
+
+// Transports of the receiver.
+const (
+	TransportSerial = "serial"
+	TransportI2C    = "i2c"
+)
+
+const defaultBaudRate = 38400
+
+func init() {
+	resource.RegisterComponent(
+		movementsensor.API,
+		Model,
+		resource.Registration[movementsensor.MovementSensor, *Config]{
+			Constructor: func(
+				ctx context.Context,
+				deps resource.Dependencies,
+				conf resource.Config,
+				logger golog.Logger,
+			) (movementsensor.MovementSensor, error) {
+				newConf, err := resource.NativeConfig[*Config](conf)
+				if err != nil {
+					return nil, err
+				}
+				return newRTKHeading(conf.ResourceName(), newConf, logger)
+			},
+		})
+}
+
+// Config is used for the rtk-heading attributes. The receiver is the rover of the moving base pair,
+// the one that computes the relative position of the two antennas.
+type Config struct {
+	Transport string `json:"transport"`
+
+	SerialPath     string `json:"serial_path,omitempty"`
+	SerialBaudRate int    `json:"serial_baud_rate,omitempty"`
+
+	I2CBus  int `json:"i2c_bus,omitempty"`
+	I2CAddr int `json:"i2c_addr,omitempty"`
+
+	// Validate checks that serial ports and i2c buses exist unless this is set, for receivers connected later
+	SkipDeviceCheck bool `json:"skip_device_check,omitempty"`
+
+	// How long i2c reads wait when there is no data, 50ms by default
+	PollIntervalMs int `json:"poll_interval_ms,omitempty"`
+
+	// Added to every reported heading to correct for antennas mounted off the vehicle's forward axis
+	HeadingOffsetDegrees float64 `json:"heading_offset_degrees,omitempty"`
+}
+
+// Validate ensures all parts of the config are valid.
+func (cfg *Config) Validate(path string) ([]string, error) {
+	switch cfg.Transport {
+	case "":
+		return nil, utils.NewConfigValidationFieldRequiredError(path, "transport")
+	case TransportSerial:
+		if cfg.SerialPath == "" {
+			return nil, utils.NewConfigValidationFieldRequiredError(path, "serial_path")
+		}
+		if !cfg.SkipDeviceCheck {
+			if err := rtkutils.ValidateDevicePath("serial_path", cfg.SerialPath); err != nil {
+				return nil, fmt.Errorf("%s: %w", path, err)
+			}
+		}
+	case TransportI2C:
+		if cfg.I2CBus == 0 {
+			return nil, utils.NewConfigValidationFieldRequiredError(path, "i2c_bus")
+		}
+		if cfg.I2CAddr == 0 {
+			return nil, utils.NewConfigValidationFieldRequiredError(path, "i2c_addr")
+		}
+		if err := rtkutils.ValidateI2CAddr("i2c_addr", cfg.I2CAddr); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		if !cfg.SkipDeviceCheck {
+			if err := rtkutils.ValidateDevicePath("i2c_bus", rtkutils.I2CBusPath(cfg.I2CBus)); err != nil {
+				return nil, fmt.Errorf("%s: %w", path, err)
+			}
+		}
+	default:
+		return nil, fmt.Errorf("%s: transport %q isn't supported, use %s or %s",
+			path, cfg.Transport, TransportSerial, TransportI2C)
+	}
+	if err := rtkutils.ValidatePollInterval(cfg.PollIntervalMs); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return nil, nil
+}
+
+// An rtkHeading is a MovementSensor model serving the heading a moving base pair reports in its
+// UBX-NAV-RELPOSNED messages, and nothing else.
+type rtkHeading struct {
+	resource.Named
+	resource.AlwaysRebuild
+	logger     golog.Logger
+	cancelCtx  context.Context
+	cancelFunc func()
+	closed     rtkutils.CloseOnce
+	conf       *Config
+
+	activeBackgroundWorkers sync.WaitGroup
+
+	err       *rtkutils.ErrorHistory
+	openClass rtkutils.ErrorClass
+	readClass rtkutils.ErrorClass
+	recovery  *rtkutils.Recovery
+	workers   *rtkutils.Supervisor
+
+	latest   rtkutils.RelPosNED // the latest relative position
+	headings rtkutils.Counter   // relative positions read
+	dataMu   sync.RWMutex
+
+	receiver     io.ReadWriteCloser // nil until it is opened
+	portsMu      sync.Mutex
+	pollInterval time.Duration // wait after an i2c read with no data
+}
+
+func newRTKHeading(name resource.Name, newConf *Config, logger golog.Logger) (movementsensor.MovementSensor, error) {
+	category := rtkutils.ErrorSerial
+	if newConf.Transport == TransportI2C {
+		category = rtkutils.ErrorI2C
+	}
+	cancelCtx, cancelFunc := context.WithCancel(rtkutils.ModuleContext())
+	h := &rtkHeading{
+		Named:        name.AsNamed(),
+		logger:       logger,
+		cancelCtx:    cancelCtx,
+		cancelFunc:   cancelFunc,
+		conf:         newConf,
+		err:          rtkutils.NewErrorHistory(),
+		openClass:    rtkutils.ErrorClass{Category: category, Op: rtkutils.OpOpen},
+		readClass:    rtkutils.ErrorClass{Category: category, Op: rtkutils.OpRead},
+		pollInterval: rtkutils.PollInterval(newConf.PollIntervalMs, rtkutils.DefaultPollInterval),
+	}
+	h.recovery = rtkutils.NewRecovery(rtkutils.RecoveryPolicy{
+		Actions: map[rtkutils.ErrorClass]rtkutils.RecoveryAction{
+			// a receiver that can't be opened yet may still appear, one that fails once open was most
+			// likely unplugged
+			h.openClass: rtkutils.Retry,
+			h.readClass: rtkutils.Reopen,
+		},
+		MaxFailures: 30,
+	}, h.err, logger)
+	h.workers = rtkutils.NewSupervisor(cancelCtx, &h.activeBackgroundWorkers, logger)
+
+	h.workers.Go("relposned reader", h.readRelPosNED)
+	rtkutils.TrackResource(h)
+	return h, h.err.Get()
+}
+
+// i2cReceiver is a receiver on an i2c bus. Its data buffer is read as a stream, and messages are
+// written to it with a handle opened for every write.
+type i2cReceiver struct {
+	*rtkutils.I2CReader
+	bus  int
+	addr byte
+}
+
+func (r *i2cReceiver) Write(p []byte) (int, error) {
+	handle, err := i2c.NewI2C(r.addr, r.bus)
+	if err != nil {
+		return 0, err
+	}
+	n, err := handle.WriteBytes(p)
+	if closeErr := handle.Close(); err == nil {
+		err = closeErr
+	}
+	return n, err
+}
+
+// openReceiver opens the receiver and enables RELPOSNED output on the port it is connected to.
+func (h *rtkHeading) openReceiver() (io.ReadWriteCloser, error) {
+	h.portsMu.Lock()
+	defer h.portsMu.Unlock()
+	if err := h.cancelCtx.Err(); err != nil {
+		return nil, err
+	}
+
+	var rx io.ReadWriteCloser
+	switch h.conf.Transport {
+	case TransportI2C:
+		rx = &i2cReceiver{
+			I2CReader: rtkutils.NewI2CReader(h.cancelCtx, h.conf.I2CBus, byte(h.conf.I2CAddr), h.pollInterval, nil),
+			bus:       h.conf.I2CBus,
+			addr:      byte(h.conf.I2CAddr),
+		}
+	default:
+		baud := h.conf.SerialBaudRate
+		if baud == 0 {
+			baud = defaultBaudRate
+		}
+		port, err := slib.Open(slib.OpenOptions{
+			PortName:        h.conf.SerialPath,
+			BaudRate:        uint(baud),
+			DataBits:        8,
+			StopBits:        1,
+			MinimumReadSize: 1,
+		})
+		if err != nil {
+			return nil, err
+		}
+		rx = port
+	}
+	if _, err := rx.Write(rtkutils.UBXMessageRatePacket(rtkutils.UBXClassNav, rtkutils.UBXNavRelPosNED, 1)); err != nil {
+		if closeErr := rx.Close(); closeErr != nil {
+			h.logger.Warnf("failed to close the receiver: %s", closeErr)
+		}
+		return nil, fmt.Errorf("failed to enable relposned output: %w", err)
+	}
+	h.receiver = rx
+	return rx, nil
+}
+
+// closeReceiver closes rx unless Close already did, so that the next openReceiver reopens it.
+func (h *rtkHeading) closeReceiver(rx io.ReadWriteCloser) {
+	h.portsMu.Lock()
+	defer h.portsMu.Unlock()
+	if h.receiver != rx {
+		return
+	}
+	if err := rx.Close(); err != nil {
+		h.err.Record(h.readClass.Category, err)
+	}
+	h.receiver = nil
+}
+
+func (h *rtkHeading) readRelPosNED() error {
+	buf := make([]byte, 1024)
+	for h.cancelCtx.Err() == nil {
+		rx, err := h.openReceiver()
+		if err != nil {
+			if h.cancelCtx.Err() != nil || h.recovery.Handle(h.cancelCtx, h.openClass, err) == rtkutils.Rebuild {
+				return rtkutils.ErrRebuildRequired
+			}
+			continue
+		}
+		h.recovery.Succeeded(h.openClass)
+
+		err = h.readFrom(rx, buf)
+		if h.cancelCtx.Err() != nil {
+			// the receiver was closed on shutdown
+			return nil
+		}
+		switch h.recovery.Handle(h.cancelCtx, h.readClass, err) {
+		case rtkutils.Rebuild:
+			return rtkutils.ErrRebuildRequired
+		case rtkutils.Reopen:
+			h.closeReceiver(rx)
+		}
+	}
+	return nil
+}
+
+// readFrom parses the RELPOSNED messages read from rx until reading fails. The nmea sentences and
+// other UBX messages the receiver sends are skipped.
+func (h *rtkHeading) readFrom(rx io.Reader, buf []byte) error {
+	var pending []byte
+	for {
+		n, err := rx.Read(buf)
+		if err != nil {
+			return err
+		}
+		h.recovery.Succeeded(h.readClass)
+
+		var frames [][]byte
+		frames, pending = rtkutils.SplitUBXFrames(append(pending, buf[:n]...))
+		pending = append([]byte{}, pending...)
+		for _, frame := range frames {
+			cls, id, payload := rtkutils.UBXFrame(frame)
+			if cls != rtkutils.UBXClassNav || id != rtkutils.UBXNavRelPosNED {
+				continue
+			}
+			rel, err := rtkutils.ParseRelPosNED(payload)
+			if err != nil {
+				h.err.Record(rtkutils.ErrorParse, err)
+				continue
+			}
+			h.dataMu.Lock()
+			h.latest = rel
+			h.dataMu.Unlock()
+			h.headings.Add(1)
+		}
+	}
+}
+
+// relPosNED returns the latest relative position, or the error of a receiver that can't be read.
+func (h *rtkHeading) relPosNED() (rtkutils.RelPosNED, error) {
+	if err := h.err.Get(); err != nil {
+		return rtkutils.RelPosNED{}, err
+	}
+	h.dataMu.RLock()
+	defer h.dataMu.RUnlock()
+	return h.latest, nil
+}
+
+// CompassHeading returns the heading from the moving base to the rover antenna, corrected by the
+// configured heading offset. It is NaN while the receiver has no valid heading.
+func (h *rtkHeading) CompassHeading(ctx context.Context, extra map[string]interface{}) (float64, error) {
+	rel, err := h.relPosNED()
+	if err != nil || !rel.HeadingValid {
+		return math.NaN(), err
+	}
+	return rtkutils.NormalizeHeading(rel.Heading + h.conf.HeadingOffsetDegrees), nil
+}
+
+// Orientation returns the compass heading as a yaw, counterclockwise from north.
+func (h *rtkHeading) Orientation(ctx context.Context, extra map[string]interface{}) (spatialmath.Orientation, error) {
+	heading, err := h.CompassHeading(ctx, extra)
+	if err != nil || math.IsNaN(heading) {
+		return spatialmath.NewZeroOrientation(), err
+	}
+	return &spatialmath.EulerAngles{Yaw: -heading * math.Pi / 180}, nil
+}
+
+// Accuracy returns the accuracy of the heading in degrees, see rtkutils.HeadingAccuracy.
+func (h *rtkHeading) Accuracy(ctx context.Context, extra map[string]interface{}) (map[string]float32, error) {
+	rel, err := h.relPosNED()
+	if err != nil || !rel.HeadingValid {
+		return map[string]float32{}, err
+	}
+	return rtkutils.HeadingAccuracy(rel.HeadingAccuracy), nil
+}
+
+// Properties only supports the heading.
+func (h *rtkHeading) Properties(ctx context.Context, extra map[string]interface{}) (*movementsensor.Properties, error) {
+	return &movementsensor.Properties{
+		CompassHeadingSupported: true,
+		OrientationSupported:    true,
+	}, nil
+}
+
+// Position not supported, compose this sensor with a position sensor.
+func (h *rtkHeading) Position(ctx context.Context, extra map[string]interface{}) (*geo.Point, float64, error) {
+	return geo.NewPoint(math.NaN(), math.NaN()), math.NaN(), movementsensor.ErrMethodUnimplementedPosition
+}
+
+// LinearVelocity not supported.
+func (h *rtkHeading) LinearVelocity(ctx context.Context, extra map[string]interface{}) (r3.Vector, error) {
+	return r3.Vector{}, movementsensor.ErrMethodUnimplementedLinearVelocity
+}
+
+// LinearAcceleration not supported.
+func (h *rtkHeading) LinearAcceleration(ctx context.Context, extra map[string]interface{}) (r3.Vector, error) {
+	return r3.Vector{}, movementsensor.ErrMethodUnimplementedLinearAcceleration
+}
+
+// AngularVelocity not supported.
+func (h *rtkHeading) AngularVelocity(ctx context.Context, extra map[string]interface{}) (spatialmath.AngularVelocity, error) {
+	return spatialmath.AngularVelocity{}, movementsensor.ErrMethodUnimplementedAngularVelocity
+}
+
+// Readings returns the heading with its accuracy, the baseline between the antennas and the
+// carrier solution it was computed with.
+func (h *rtkHeading) Readings(ctx context.Context, extra map[string]interface{}) (map[string]interface{}, error) {
+	// without a heading or a device, or after a fatal error, there is nothing to read but the state
+	state := h.state()
+	if state == rtkutils.StateInitializing || state == rtkutils.StateWaitingForDevice || state == rtkutils.StateError {
+		return map[string]interface{}{rtkutils.StateKey: state}, nil
+	}
+
+	rel, err := h.relPosNED()
+	if err != nil {
+		return nil, err
+	}
+	readings := map[string]interface{}{
+		"compass":               math.NaN(),
+		"compass_degrees_error": math.NaN(),
+		"baseline_m":            rel.Length,
+		"carrier_solution":      carrierSolutions[rel.CarrierSolution],
+		rtkutils.StateKey:       state,
+		rtkutils.RestartsKey:    h.workers.Restarts(),
+	}
+	if rel.HeadingValid {
+		readings["compass"] = rtkutils.NormalizeHeading(rel.Heading + h.conf.HeadingOffsetDegrees)
+		readings["compass_degrees_error"] = rel.HeadingAccuracy
+	}
+	rtkutils.AddHeadingReadings(readings, readings["compass"].(float64))
+	return readings, nil
+}
+
+var carrierSolutions = map[int]string{
+	rtkutils.CarrierSolutionNone:  "none",
+	rtkutils.CarrierSolutionFloat: "float",
+	rtkutils.CarrierSolutionFixed: "fixed",
+}
+
+// state returns the state of the sensor for its readings. It is healthy once the heading is
+// computed from a fixed carrier solution.
+func (h *rtkHeading) state() string {
+	h.dataMu.RLock()
+	fixQuality := h.latest.FixQuality()
+	h.dataMu.RUnlock()
+	return rtkutils.DeviceState(rtkutils.RoverState(h.err, &h.headings, fixQuality, time.Now()), h.recovery)
+}
+
+// DoCommand runs the commands supported by the sensor.
+func (h *rtkHeading) DoCommand(ctx context.Context, cmd map[string]interface{}) (map[string]interface{}, error) {
+	switch cmd[rtkutils.CommandKey] {
+	case rtkutils.SelfTestCommand:
+		return h.selfTest(ctx, rtkutils.SelfTestTimeout(cmd)), nil
+	case rtkutils.ErrorsCommand:
+		return h.err.ErrorsResult(cmd), nil
+	case rtkutils.VersionCommand:
+		return rtkutils.VersionResult(), nil
+	default:
+		return nil, resource.ErrDoUnimplemented
+	}
+}
+
+// selfTest checks that the receiver is reachable and sending relative positions.
+func (h *rtkHeading) selfTest(ctx context.Context, timeout time.Duration) map[string]interface{} {
+	report := rtkutils.NewReport()
+
+	if h.conf.Transport == TransportI2C {
+		report.Check("i2c_addr_ack", rtkutils.CheckI2CAck(byte(h.conf.I2CAddr), h.conf.I2CBus))
+	} else {
+		h.portsMu.Lock()
+		open := h.receiver != nil
+		h.portsMu.Unlock()
+		var err error
+		if !open {
+			err = fmt.Errorf("port %s is not open", h.conf.SerialPath)
+		}
+		report.Check("port_open", err)
+	}
+	report.Check("relposned", rtkutils.WaitForIncrease(ctx, timeout, "relposned message", h.headings.Get))
+
+	return report.Result()
+}
+
+// Close shuts down the sensor.
+func (h *rtkHeading) Close(ctx context.Context) error {
+	return h.closed.Close(func() error { return h.close(ctx) })
+}
+
+func (h *rtkHeading) close(ctx context.Context) error {
+	rtkutils.UntrackResource(h)
+	h.cancelFunc()
+
+	// close the receiver before waiting on the worker so a read blocked on it can return.
+	h.portsMu.Lock()
+	if h.receiver != nil {
+		if err := h.receiver.Close(); err != nil {
+			h.err.Set(h.readClass.Category, err)
+			h.logger.Errorf("failed to close the receiver: %s", err)
+		}
+		h.receiver = nil
+	}
+	h.portsMu.Unlock()
+
+	if !rtkutils.WaitWithTimeout(ctx, &h.activeBackgroundWorkers, rtkutils.DefaultShutdownTimeout) {
+		h.logger.Warn("timed out waiting for background workers to stop")
+	}
+
+	if err := h.err.Get(); err != nil && !errors.Is(err, context.Canceled) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,126 @@
+package rtkheading
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"testing"
+
+	"github.com/edaniels/golog"
+	"go.viam.com/rdk/components/movementsensor"
+	"go.viam.com/rdk/resource"
+	"go.viam.com/test"
+	"go.viam.com/utils"
+
+	rtkutils "rtksystem/rtk-utils"
+)
+
+const path = "path"
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name        string
+		config      *Config
+		expectedErr error
+	}{
+		{
+			name:   "A serial receiver should result in no errors",
+			config: &Config{Transport: TransportSerial, SerialPath: "heading-path", SkipDeviceCheck: true},
+		},
+		{
+			name:   "An i2c receiver should result in no errors",
+			config: &Config{Transport: TransportI2C, I2CBus: 1, I2CAddr: 0x42, SkipDeviceCheck: true},
+		},
+		{
+			name:        "No transport should error",
+			config:      &Config{},
+			expectedErr: utils.NewConfigValidationFieldRequiredError(path, "transport"),
+		},
+		{
+			name:        "An i2c receiver needs an address",
+			config:      &Config{Transport: TransportI2C, I2CBus: 1, SkipDeviceCheck: true},
+			expectedErr: utils.NewConfigValidationFieldRequiredError(path, "i2c_addr"),
+		},
+		{
+			name:        "An unknown transport should error",
+			config:      &Config{Transport: "tcp"},
+			expectedErr: errors.New("path: transport \"tcp\" isn't supported, use serial or i2c"),
+		},
+		{
+			name:   "A serial receiver that doesn't exist should error",
+			config: &Config{Transport: TransportSerial, SerialPath: "heading-path"},
+			expectedErr: errors.New("path: serial_path \"heading-path\" doesn't exist, check that the device is connected, " +
+				"or set skip_device_check if it is only connected later"),
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			deps, err := tc.config.Validate(path)
+			test.That(t, deps, test.ShouldBeNil)
+			if tc.expectedErr != nil {
+				test.That(t, err, test.ShouldBeError, tc.expectedErr)
+			} else {
+				test.That(t, err, test.ShouldBeNil)
+			}
+		})
+	}
+}
+
+// relPosNEDFrame builds a RELPOSNED message with a fixed carrier solution.
+func relPosNEDFrame(heading, accuracy, lengthM float64) []byte {
+	payload := make([]byte, 64)
+	payload[0] = 0x01
+	binary.LittleEndian.PutUint32(payload[20:], uint32(int32(lengthM*100)))
+	binary.LittleEndian.PutUint32(payload[24:], uint32(int32(heading*1e5)))
+	binary.LittleEndian.PutUint32(payload[52:], uint32(accuracy*1e5))
+	binary.LittleEndian.PutUint32(payload[60:], 0x0117)
+	return rtkutils.UBXPacket(rtkutils.UBXClassNav, rtkutils.UBXNavRelPosNED, payload)
+}
+
+func TestRTKHeading(t *testing.T) {
+	logger := golog.NewTestLogger(t)
+	ctx := context.Background()
+	conf := &Config{Transport: TransportSerial, SerialPath: "heading-path", SkipDeviceCheck: true, HeadingOffsetDegrees: 20}
+	name := resource.NewName(movementsensor.API, "heading")
+
+	// the receiver isn't connected yet, so the sensor keeps trying to open it
+	s, err := newRTKHeading(name, conf, logger)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, s.Name(), test.ShouldResemble, name)
+	h := s.(*rtkHeading)
+
+	readings, err := h.Readings(ctx, nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, readings, test.ShouldResemble, map[string]interface{}{rtkutils.StateKey: rtkutils.StateInitializing})
+	heading, err := h.CompassHeading(ctx, nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, math.IsNaN(heading), test.ShouldBeTrue)
+
+	// relative positions are read among nmea sentences, the heading is corrected by the offset
+	stream := append([]byte("$GNGGA,,,,,,0,,,,,,,,*56\r\n"), relPosNEDFrame(350, 0.5, 1.2)...)
+	test.That(t, h.readFrom(bytes.NewReader(stream), make([]byte, 16)), test.ShouldEqual, io.EOF)
+
+	heading, err = h.CompassHeading(ctx, nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, heading, test.ShouldAlmostEqual, 10, 1e-6)
+	accuracy, err := h.Accuracy(ctx, nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, accuracy["compass_degrees_error"], test.ShouldAlmostEqual, 0.5, 1e-6)
+	test.That(t, accuracy[rtkutils.AccuracyHeading], test.ShouldAlmostEqual, 0.5, 1e-6)
+	_, _, err = h.Position(ctx, nil)
+	test.That(t, err, test.ShouldEqual, movementsensor.ErrMethodUnimplementedPosition)
+
+	readings, err = h.Readings(ctx, nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, readings[rtkutils.StateKey], test.ShouldEqual, rtkutils.StateHealthy)
+	test.That(t, readings["carrier_solution"], test.ShouldEqual, "fixed")
+	test.That(t, readings["baseline_m"], test.ShouldAlmostEqual, 1.2, 1e-6)
+	test.That(t, readings[rtkutils.HeadingCompassKey], test.ShouldAlmostEqual, 10, 1e-6)
+	test.That(t, readings[rtkutils.HeadingMathKey], test.ShouldAlmostEqual, 80, 1e-6)
+
+	test.That(t, h.Close(ctx), test.ShouldBeNil)
+}
@@ -0,0 +1,127 @@
+package rtkutils
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Keys of the accuracy the movement sensors report, with their units in their names. The dilutions
+// of precision are unitless, and the fix is the GGA fix quality. Every key but the fix, and the
+// dilutions of precision of a rover, is only reported when the receiver provides it.
+const (
+	AccuracyHDOP          = "hdop"
+	AccuracyVDOP          = "vdop"
+	AccuracyPDOP          = "pdop"
+	AccuracyHorizontal    = "h_acc_m"
+	AccuracyVertical      = "v_acc_m"
+	AccuracySpeed         = "speed_acc_mps"
+	AccuracyHeading       = "heading_acc_deg"
+	AccuracyFix           = "fix"
+	AccuracyCorrectionAge = "correction_age_s"
+)
+
+// EpochAccuracy returns the accuracy of an epoch: its dilutions of precision, fix and correction age,
+// and the horizontal and vertical accuracy a receiver estimates in PUBX,00, PQTMEPE or its ppp
+// solution. The keys of earlier versions are kept alongside, for the clients reading them.
+func EpochAccuracy(snap Snapshot) map[string]float32 {
+	accuracy := map[string]float32{
+		AccuracyHDOP: float32(snap.Data.HDOP),
+		AccuracyVDOP: float32(snap.Data.VDOP),
+		AccuracyFix:  float32(snap.Data.FixQuality),
+		// deprecated
+		"hDOP": float32(snap.Data.HDOP),
+		"vDOP": float32(snap.Data.VDOP),
+	}
+	if snap.PDOP > 0 {
+		accuracy[AccuracyPDOP] = float32(snap.PDOP)
+	}
+	if snap.CorrectionAgeValid {
+		accuracy[AccuracyCorrectionAge] = float32(snap.CorrectionAge)
+	}
+	switch {
+	case snap.PUBX.Valid:
+		accuracy[AccuracyHorizontal] = float32(snap.PUBX.HorizontalAccuracy)
+		accuracy[AccuracyVertical] = float32(snap.PUBX.VerticalAccuracy)
+		// deprecated
+		accuracy["hacc_m"] = float32(snap.PUBX.HorizontalAccuracy)
+		accuracy["vacc_m"] = float32(snap.PUBX.VerticalAccuracy)
+	case snap.PositionError.Valid:
+		accuracy[AccuracyHorizontal] = float32(snap.PositionError.Horizontal)
+		accuracy[AccuracyVertical] = float32(snap.PositionError.Down)
+		// deprecated
+		accuracy["epe_2d_m"] = float32(snap.PositionError.Horizontal)
+		accuracy["epe_3d_m"] = float32(snap.PositionError.Spherical)
+	case snap.PPP.Valid && snap.PPP.Solution != PPPSolutionNone:
+		accuracy[AccuracyHorizontal] = float32(snap.PPP.HorizontalStdev)
+		accuracy[AccuracyVertical] = float32(snap.PPP.VerticalStdev)
+	}
+	return accuracy
+}
+
+// HeadingAccuracy returns the accuracy of a heading of degrees accuracy, with the key of earlier
+// versions kept alongside, for the clients reading it.
+func HeadingAccuracy(degrees float64) map[string]float32 {
+	return map[string]float32{
+		AccuracyHeading: float32(degrees),
+		// deprecated
+		"compass_degrees_error": float32(degrees),
+	}
+}
+
+// TypedAccuracy adds the keys of the dilutions of precision to the accuracy of a gps that only
+// reports them with the keys of earlier versions, hDOP and vDOP.
+func TypedAccuracy(accuracy map[string]float32) map[string]float32 {
+	if hdop, ok := accuracy["hDOP"]; ok {
+		accuracy[AccuracyHDOP] = hdop
+	}
+	if vdop, ok := accuracy["vDOP"]; ok {
+		accuracy[AccuracyVDOP] = vdop
+	}
+	return accuracy
+}
+
+// nmeaFields returns the fields of line, without its checksum, if it is a standard sentence of type typ.
+func nmeaFields(line, typ string) ([]string, bool) {
+	ind := strings.Index(line, "$G")
+	if ind == -1 {
+		return nil, false
+	}
+	line = line[ind:]
+	if star := strings.IndexByte(line, '*'); star != -1 {
+		line = line[:star]
+	}
+	fields := strings.Split(strings.TrimSpace(line), ",")
+	if len(fields[0]) < 6 || fields[0][3:] != typ {
+		return nil, false
+	}
+	return fields, true
+}
+
+// ggaCorrectionAge returns the age of the corrections of a GGA sentence, in seconds, and false if it
+// has none.
+func ggaCorrectionAge(line string) (float64, bool) {
+	// $GPGGA,<time>,<lat>,N,<lng>,E,<quality>,<sats>,<hdop>,<alt>,M,<sep>,M,<age>,<station id>
+	fields, ok := nmeaFields(line, "GGA")
+	if !ok || len(fields) < 14 || fields[13] == "" {
+		return 0, false
+	}
+	age, err := strconv.ParseFloat(fields[13], 64)
+	if err != nil || age < 0 {
+		return 0, false
+	}
+	return age, true
+}
+
+// gsaPDOP returns the position dilution of precision of a GSA sentence, and false if it isn't one.
+func gsaPDOP(line string) (float64, bool) {
+	// $GNGSA,<mode>,<fix>,<12 satellites>,<pdop>,<hdop>,<vdop>[,<system id>]
+	fields, ok := nmeaFields(line, "GSA")
+	if !ok || len(fields) < 16 {
+		return 0, false
+	}
+	pdop, err := strconv.ParseFloat(fields[15], 64)
+	if err != nil {
+		return 0, false
+	}
+	return pdop, true
+}
@@ -0,0 +1,64 @@
+package rtkutils
+
+import (
+	"testing"
+
+	"go.viam.com/rdk/components/movementsensor/gpsnmea"
+	"go.viam.com/test"
+)
+
+func TestEpochAccuracy(t *testing.T) {
+	snap := Snapshot{Data: gpsnmea.GPSData{HDOP: 0.8, VDOP: 1.2, FixQuality: FixQualityRTKFixed}}
+	test.That(t, EpochAccuracy(snap), test.ShouldResemble, map[string]float32{
+		AccuracyHDOP: 0.8, AccuracyVDOP: 1.2, AccuracyFix: 4, "hDOP": 0.8, "vDOP": 1.2,
+	})
+
+	snap.PDOP = 1.5
+	snap.CorrectionAge, snap.CorrectionAgeValid = 1.2, true
+	snap.PUBX = PUBXPosition{HorizontalAccuracy: 0.02, VerticalAccuracy: 0.03, Valid: true}
+	accuracy := EpochAccuracy(snap)
+	test.That(t, accuracy[AccuracyPDOP], test.ShouldAlmostEqual, 1.5, 1e-6)
+	test.That(t, accuracy[AccuracyCorrectionAge], test.ShouldAlmostEqual, 1.2, 1e-6)
+	test.That(t, accuracy[AccuracyHorizontal], test.ShouldAlmostEqual, 0.02, 1e-6)
+	test.That(t, accuracy[AccuracyVertical], test.ShouldAlmostEqual, 0.03, 1e-6)
+	test.That(t, accuracy["hacc_m"], test.ShouldAlmostEqual, 0.02, 1e-6)
+
+	snap.PUBX = PUBXPosition{}
+	snap.PositionError = PositionError{Down: 0.04, Horizontal: 0.05, Spherical: 0.06, Valid: true}
+	accuracy = EpochAccuracy(snap)
+	test.That(t, accuracy[AccuracyHorizontal], test.ShouldAlmostEqual, 0.05, 1e-6)
+	test.That(t, accuracy[AccuracyVertical], test.ShouldAlmostEqual, 0.04, 1e-6)
+	test.That(t, accuracy["epe_3d_m"], test.ShouldAlmostEqual, 0.06, 1e-6)
+
+	test.That(t, HeadingAccuracy(0.5), test.ShouldResemble, map[string]float32{
+		AccuracyHeading: 0.5, "compass_degrees_error": 0.5,
+	})
+	test.That(t, TypedAccuracy(map[string]float32{"hDOP": 0.8, "vDOP": 1.2}), test.ShouldResemble, map[string]float32{
+		AccuracyHDOP: 0.8, AccuracyVDOP: 1.2, "hDOP": 0.8, "vDOP": 1.2,
+	})
+}
+
+func TestAccuracySentences(t *testing.T) {
+	age, ok := ggaCorrectionAge("$GNGGA,120000.00,4000.0,N,10500.0,W,4,12,0.8,1600.0,M,-20.0,M,1.5,0012*7A")
+	test.That(t, ok, test.ShouldBeTrue)
+	test.That(t, age, test.ShouldAlmostEqual, 1.5, 1e-9)
+	_, ok = ggaCorrectionAge("$GNGGA,120000.00,4000.0,N,10500.0,W,1,12,0.8,1600.0,M,-20.0,M,,*7A")
+	test.That(t, ok, test.ShouldBeFalse)
+	_, ok = ggaCorrectionAge("$GNRMC,120000.00,A,4000.0,N,10500.0,W,0.0,,010124,,,D*7A")
+	test.That(t, ok, test.ShouldBeFalse)
+
+	pdop, ok := gsaPDOP("$GNGSA,A,3,05,13,15,18,,,,,,,,,1.52,0.81,1.29,1*0E")
+	test.That(t, ok, test.ShouldBeTrue)
+	test.That(t, pdop, test.ShouldAlmostEqual, 1.52, 1e-9)
+	_, ok = gsaPDOP("$GNGSA,A,1*0E")
+	test.That(t, ok, test.ShouldBeFalse)
+
+	var tracker EpochTracker
+	tracker.ParseAndUpdate("$GNGGA,120000.00,4000.0,N,10500.0,W,4,12,0.8,1600.0,M,-20.0,M,1.5,0012*7A")
+	tracker.ParseAndUpdate("$GNGSA,A,3,05,13,15,18,,,,,,,,,1.52,0.81,1.29,1*0E")
+	snap, published, _ := tracker.ParseAndUpdate("$GNGGA,120001.00,4000.0,N,10500.0,W,4,12,0.8,1600.0,M,-20.0,M,,*7A")
+	test.That(t, published, test.ShouldBeTrue)
+	test.That(t, snap.PDOP, test.ShouldAlmostEqual, 1.52, 1e-9)
+	test.That(t, snap.CorrectionAgeValid, test.ShouldBeTrue)
+	test.That(t, snap.CorrectionAge, test.ShouldAlmostEqual, 1.5, 1e-9)
+}
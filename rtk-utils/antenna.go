@@ -0,0 +1,252 @@
+package rtkutils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.viam.com/rdk/components/board"
+)
+
+// Antennas an AntennaSwitch selects.
+const (
+	AntennaPrimary   = "primary"
+	AntennaSecondary = "secondary"
+)
+
+// EventAntennaSwitched is raised when an AntennaSwitch fails over to the other antenna.
+const EventAntennaSwitched = "antenna_switched"
+
+const (
+	// DefaultFailoverCNR is the C/N0 in dB-Hz of the strongest satellites below which an antenna failed.
+	DefaultFailoverCNR = 25.0
+	// DefaultFailoverAfter is how long the C/N0 stays below the threshold before the antenna is switched.
+	DefaultFailoverAfter = 10 * time.Second
+
+	// number of the strongest satellites whose C/N0 is averaged, so that a few satellites low on the
+	// horizon don't make a good antenna look bad
+	strongestSatellites = 4
+	// satellites not reported for this long are left out, as after a switch
+	antennaCNRMaxAge = 5 * time.Second
+)
+
+// ValidateAntennaSwitch checks the antenna switch settings of a rover.
+func ValidateAntennaSwitch(boardName, pin string, cnr, after float64) error {
+	if (boardName == "") != (pin == "") {
+		return errors.New("antenna_switch_board and antenna_switch_pin must be set together")
+	}
+	if cnr < 0 {
+		return errors.New("antenna_failover_cnr_db_hz must not be negative")
+	}
+	if after < 0 {
+		return errors.New("antenna_failover_sec must not be negative")
+	}
+	if boardName == "" && (cnr > 0 || after > 0) {
+		return errors.New("antenna_failover_cnr_db_hz and antenna_failover_sec need antenna_switch_board and antenna_switch_pin")
+	}
+	return nil
+}
+
+type satelliteCNR struct {
+	cnr  float64 // dB-Hz, 0 if the satellite isn't tracked
+	time time.Time
+}
+
+// AntennaSwitch fails a rover over between two antennas, selected by a board GPIO pin driving an
+// external RF switch or the antenna select input of a receiver, when the C/N0 of the satellites
+// collapses, e.g. on a cut cable or a damaged antenna. The pin is low for the primary antenna and
+// high for the secondary one. The C/N0 is read from the GSV sentences of the receiver. A nil switch
+// has a single antenna.
+type AntennaSwitch struct {
+	board     board.Board
+	pin       string
+	threshold float64
+	after     time.Duration
+
+	mu       sync.Mutex
+	selected bool   // the pin was set
+	active   string // antenna selected
+	cnrs     map[string]satelliteCNR
+	below    time.Time // when the C/N0 fell below the threshold, zero while it is above
+}
+
+// NewAntennaSwitch returns a switch of pin on b, or nil if b is nil. The antenna is switched once
+// the C/N0 of the strongest satellites stays below threshold dB-Hz for after, DefaultFailoverCNR and
+// DefaultFailoverAfter if they are zero.
+func NewAntennaSwitch(b board.Board, pin string, threshold float64, after time.Duration) *AntennaSwitch {
+	if b == nil {
+		return nil
+	}
+	if threshold <= 0 {
+		threshold = DefaultFailoverCNR
+	}
+	if after <= 0 {
+		after = DefaultFailoverAfter
+	}
+	return &AntennaSwitch{
+		board:     b,
+		pin:       pin,
+		threshold: threshold,
+		after:     after,
+		active:    AntennaPrimary,
+		cnrs:      map[string]satelliteCNR{},
+	}
+}
+
+// Update reads the C/N0 of the satellites of a GSV sentence read at now, and ignores other sentences.
+func (s *AntennaSwitch) Update(line string, now time.Time) {
+	if s == nil {
+		return
+	}
+	cnrs, ok := gsvCNRs(line)
+	if !ok {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for sat, cnr := range cnrs {
+		s.cnrs[sat] = satelliteCNR{cnr: cnr, time: now}
+	}
+}
+
+// gsvCNRs returns the C/N0 in dB-Hz of the satellites of a GSV sentence by talker, satellite and
+// signal, and false if line isn't a GSV sentence.
+func gsvCNRs(line string) (map[string]float64, bool) {
+	ind := strings.Index(line, "$G")
+	if ind == -1 {
+		return nil, false
+	}
+	line = line[ind:]
+	if star := strings.IndexByte(line, '*'); star != -1 {
+		line = line[:star]
+	}
+	// $GPGSV,<messages>,<message>,<satellites>{,<id>,<elevation>,<azimuth>,<cn0>}[,<signal id>]
+	fields := strings.Split(strings.TrimSpace(line), ",")
+	if len(fields[0]) < 6 || fields[0][3:] != "GSV" || len(fields) < 4 {
+		return nil, false
+	}
+	talker := fields[0][1:3]
+	sats := fields[4:]
+	signal := ""
+	if len(sats)%4 == 1 {
+		// nmea 4.10 adds the signal id
+		signal = "/" + sats[len(sats)-1]
+		sats = sats[:len(sats)-1]
+	}
+	cnrs := map[string]float64{}
+	for i := 0; i+3 < len(sats); i += 4 {
+		if sats[i] == "" {
+			continue
+		}
+		// an empty C/N0 is a satellite in view that isn't tracked
+		cnr, _ := strconv.ParseFloat(sats[i+3], 64)
+		cnrs[talker+sats[i]+signal] = cnr
+	}
+	return cnrs, true
+}
+
+// CNR returns the mean C/N0 in dB-Hz of the strongest satellites reported recently, 0 if there are none.
+func (s *AntennaSwitch) CNR(now time.Time) float64 {
+	if s == nil {
+		return 0
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.cnr(now)
+}
+
+// cnr returns the mean C/N0 of the strongest satellites. The caller must hold mu.
+func (s *AntennaSwitch) cnr(now time.Time) float64 {
+	var cnrs []float64
+	for sat, c := range s.cnrs {
+		if now.Sub(c.time) > antennaCNRMaxAge {
+			delete(s.cnrs, sat)
+			continue
+		}
+		cnrs = append(cnrs, c.cnr)
+	}
+	if len(cnrs) == 0 {
+		return 0
+	}
+	sort.Sort(sort.Reverse(sort.Float64Slice(cnrs)))
+	if len(cnrs) > strongestSatellites {
+		cnrs = cnrs[:strongestSatellites]
+	}
+	var sum float64
+	for _, c := range cnrs {
+		sum += c
+	}
+	return sum / float64(len(cnrs))
+}
+
+// Active returns the antenna selected.
+func (s *AntennaSwitch) Active() string {
+	if s == nil {
+		return AntennaPrimary
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.active
+}
+
+// Check selects the primary antenna the first time it is called, then switches to the other
+// antenna once the C/N0 stayed below the threshold for long enough, adding an event to events. The
+// antenna switched to gets as long to acquire the satellites before it can be switched back.
+func (s *AntennaSwitch) Check(ctx context.Context, now time.Time, events *EventLog) error {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.selected {
+		if err := s.selectAntenna(ctx, s.active); err != nil {
+			return err
+		}
+		s.selected = true
+		s.below = time.Time{}
+	}
+
+	cnr := s.cnr(now)
+	switch {
+	case cnr >= s.threshold:
+		s.below = time.Time{}
+		return nil
+	case s.below.IsZero():
+		s.below = now
+		return nil
+	case now.Sub(s.below) < s.after:
+		return nil
+	}
+
+	other := AntennaSecondary
+	if s.active == AntennaSecondary {
+		other = AntennaPrimary
+	}
+	if err := s.selectAntenna(ctx, other); err != nil {
+		return err
+	}
+	events.Add(EventAntennaSwitched, "C/N0 of the %s antenna was %.1f dB-Hz for %s, switched to the %s antenna",
+		s.active, cnr, now.Sub(s.below).Round(time.Second), other)
+	s.active = other
+	// the satellites are judged again on the new antenna alone
+	s.cnrs = map[string]satelliteCNR{}
+	s.below = now
+	return nil
+}
+
+// selectAntenna sets the pin for antenna. The caller must hold mu.
+func (s *AntennaSwitch) selectAntenna(ctx context.Context, antenna string) error {
+	pin, err := s.board.GPIOPinByName(s.pin)
+	if err != nil {
+		return fmt.Errorf("antenna switch pin %q: %w", s.pin, err)
+	}
+	if err := pin.Set(ctx, antenna == AntennaSecondary, nil); err != nil {
+		return fmt.Errorf("failed to select the %s antenna: %w", antenna, err)
+	}
+	return nil
+}
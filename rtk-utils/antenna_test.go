@@ -0,0 +1,82 @@
+package rtkutils
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/edaniels/golog"
+	"go.viam.com/rdk/components/board"
+	"go.viam.com/test"
+)
+
+func TestValidateAntennaSwitch(t *testing.T) {
+	test.That(t, ValidateAntennaSwitch("", "", 0, 0), test.ShouldBeNil)
+	test.That(t, ValidateAntennaSwitch("pi", "13", 30, 5), test.ShouldBeNil)
+	test.That(t, ValidateAntennaSwitch("", "13", 0, 0), test.ShouldBeError,
+		errors.New("antenna_switch_board and antenna_switch_pin must be set together"))
+	test.That(t, ValidateAntennaSwitch("pi", "13", -1, 0), test.ShouldBeError,
+		errors.New("antenna_failover_cnr_db_hz must not be negative"))
+	test.That(t, ValidateAntennaSwitch("pi", "13", 0, -1), test.ShouldBeError,
+		errors.New("antenna_failover_sec must not be negative"))
+	test.That(t, ValidateAntennaSwitch("", "", 30, 0), test.ShouldBeError,
+		errors.New("antenna_failover_cnr_db_hz and antenna_failover_sec need antenna_switch_board and antenna_switch_pin"))
+}
+
+func TestGSVCNRs(t *testing.T) {
+	cnrs, ok := gsvCNRs("$GPGSV,3,1,11,10,63,137,17,07,61,098,,05,59,290,20,08,54,157,30*70")
+	test.That(t, ok, test.ShouldBeTrue)
+	test.That(t, cnrs, test.ShouldResemble, map[string]float64{"GP10": 17, "GP07": 0, "GP05": 20, "GP08": 30})
+
+	cnrs, ok = gsvCNRs("$GBGSV,1,1,01,33,56,045,27,1*40")
+	test.That(t, ok, test.ShouldBeTrue)
+	test.That(t, cnrs, test.ShouldResemble, map[string]float64{"GB33/1": 27})
+
+	_, ok = gsvCNRs("$GNGGA,172814.0,3723.46587704,N,12202.26957864,W,2,6,1.2,18.893,M,-25.669,M,2.0,0031*4F")
+	test.That(t, ok, test.ShouldBeFalse)
+}
+
+func TestAntennaSwitch(t *testing.T) {
+	ctx := context.Background()
+	var single *AntennaSwitch
+	single.Update("$GBGSV,1,1,01,33,56,045,27,1*40", time.Now())
+	test.That(t, single.Check(ctx, time.Now(), nil), test.ShouldBeNil)
+	test.That(t, single.Active(), test.ShouldEqual, AntennaPrimary)
+	test.That(t, NewAntennaSwitch(nil, "13", 0, 0), test.ShouldBeNil)
+
+	var levels []bool
+	pin := &fakePin{set: func(high bool) error {
+		levels = append(levels, high)
+		return nil
+	}}
+	b := &fakeBoard{pins: map[string]board.GPIOPin{"13": pin}}
+	events := NewEventLog(golog.NewTestLogger(t))
+	s := NewAntennaSwitch(b, "13", 0, 10*time.Second)
+	start := time.Now()
+	at := func(sec int) time.Time { return start.Add(time.Duration(sec) * time.Second) }
+
+	// the mean of the 4 strongest satellites is good enough
+	s.Update("$GPGSV,2,1,05,10,63,137,45,07,61,098,44,05,59,290,43,08,54,157,42*70", at(0))
+	s.Update("$GPGSV,2,2,05,12,10,040,12*70", at(0))
+	test.That(t, s.CNR(at(0)), test.ShouldEqual, 43.5)
+	test.That(t, s.Check(ctx, at(0), events), test.ShouldBeNil)
+	test.That(t, levels, test.ShouldResemble, []bool{false})
+
+	// the antenna is switched once the C/N0 stays low
+	s.Update("$GPGSV,2,1,05,10,63,137,,07,61,098,,05,59,290,15,08,54,157,*70", at(1))
+	s.Update("$GPGSV,2,2,05,12,10,040,*70", at(1))
+	test.That(t, s.Check(ctx, at(1), events), test.ShouldBeNil)
+	test.That(t, s.Check(ctx, at(10), events), test.ShouldBeNil)
+	test.That(t, s.Active(), test.ShouldEqual, AntennaPrimary)
+	test.That(t, s.Check(ctx, at(11), events), test.ShouldBeNil)
+	test.That(t, s.Active(), test.ShouldEqual, AntennaSecondary)
+	test.That(t, levels, test.ShouldResemble, []bool{false, true})
+	test.That(t, events.Events(EventAntennaSwitched), test.ShouldHaveLength, 1)
+
+	// the secondary antenna is kept once it acquired the satellites
+	test.That(t, s.CNR(at(11)), test.ShouldEqual, 0.0)
+	s.Update("$GPGSV,1,1,02,10,63,137,40,07,61,098,38*70", at(20))
+	test.That(t, s.Check(ctx, at(21), events), test.ShouldBeNil)
+	test.That(t, s.Active(), test.ShouldEqual, AntennaSecondary)
+}
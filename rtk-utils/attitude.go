@@ -0,0 +1,34 @@
+package rtkutils
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// UBX attitude of the vehicle, output by dead reckoning receivers such as the ZED-F9R.
+const UBXNavAtt = 0x05
+
+const navAttPayloadLen = 32
+
+// Attitude is the attitude of the vehicle a dead reckoning receiver is mounted on, from a
+// UBX-NAV-ATT message, in degrees.
+type Attitude struct {
+	Roll, Pitch     float64
+	Heading         float64 // clockwise from true north
+	HeadingAccuracy float64
+}
+
+// ParseNavAtt parses the payload of a UBX-NAV-ATT message.
+func ParseNavAtt(payload []byte) (Attitude, error) {
+	if len(payload) != navAttPayloadLen {
+		return Attitude{}, fmt.Errorf("nav-att payload is %d bytes, expected %d", len(payload), navAttPayloadLen)
+	}
+	// angles and their accuracy are in 1e-5 degrees
+	angle := func(offset int) float64 { return float64(int32(binary.LittleEndian.Uint32(payload[offset:]))) * 1e-5 }
+	return Attitude{
+		Roll:            angle(8),
+		Pitch:           angle(12),
+		Heading:         NormalizeHeading(angle(16)),
+		HeadingAccuracy: float64(binary.LittleEndian.Uint32(payload[28:])) * 1e-5,
+	}, nil
+}
@@ -0,0 +1,30 @@
+package rtkutils
+
+import (
+	"encoding/binary"
+	"errors"
+	"testing"
+
+	"go.viam.com/test"
+)
+
+func navAttPayload(roll, pitch, heading int32, accHeading uint32) []byte {
+	payload := make([]byte, navAttPayloadLen)
+	binary.LittleEndian.PutUint32(payload[8:], uint32(roll))
+	binary.LittleEndian.PutUint32(payload[12:], uint32(pitch))
+	binary.LittleEndian.PutUint32(payload[16:], uint32(heading))
+	binary.LittleEndian.PutUint32(payload[28:], accHeading)
+	return payload
+}
+
+func TestParseNavAtt(t *testing.T) {
+	att, err := ParseNavAtt(navAttPayload(-150000, 250000, -9000000, 50000))
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, att.Roll, test.ShouldAlmostEqual, -1.5, 1e-9)
+	test.That(t, att.Pitch, test.ShouldAlmostEqual, 2.5, 1e-9)
+	test.That(t, att.Heading, test.ShouldAlmostEqual, 270, 1e-9)
+	test.That(t, att.HeadingAccuracy, test.ShouldAlmostEqual, 0.5, 1e-9)
+
+	_, err = ParseNavAtt(make([]byte, 28))
+	test.That(t, err, test.ShouldBeError, errors.New("nav-att payload is 28 bytes, expected 32"))
+}
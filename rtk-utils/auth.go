@@ -0,0 +1,133 @@
+package rtkutils
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"time"
+
+	"github.com/go-gnss/rtcm/rtcm3"
+)
+
+const (
+	// AuthMessageNumber is the rtcm message of the authentication tag following every frame of an
+	// authenticated stream. It is in the range reserved for experimental messages, which receivers
+	// ignore, so rovers without the key still use the corrections.
+	AuthMessageNumber = 100
+
+	minAuthKeyLen = 16
+	authSeqLen    = 8
+	authMACLen    = 16 // HMAC-SHA256 truncated to 128 bits
+	// message number and version, sequence number and mac
+	authPayloadLen = 2 + authSeqLen + authMACLen
+)
+
+// ValidateAuthKey checks the shared key of field, if set.
+func ValidateAuthKey(field, key string) error {
+	if key != "" && len(key) < minAuthKeyLen {
+		return fmt.Errorf("%s must be at least %d characters", field, minAuthKeyLen)
+	}
+	return nil
+}
+
+// FrameSigner authenticates the rtcm frames a station sends over a radio or udp, where anyone in
+// range can inject frames, with a key shared with the rovers. Every frame is followed by a tag frame
+// holding a sequence number and the HMAC-SHA256 of both, which a FrameVerifier checks. Sequence
+// numbers start from the time in milliseconds, so they keep increasing when the station restarts.
+// A nil signer leaves frames as they are.
+type FrameSigner struct {
+	key []byte
+	seq uint64
+}
+
+// NewFrameSigner returns a signer with key, or nil if key is empty.
+func NewFrameSigner(key string) *FrameSigner {
+	if key == "" {
+		return nil
+	}
+	return &FrameSigner{key: []byte(key)}
+}
+
+// Sign returns frame followed by its tag frame, in a new buffer.
+func (s *FrameSigner) Sign(frame []byte) []byte {
+	if s == nil {
+		return frame
+	}
+	s.seq++
+	if now := uint64(time.Now().UnixMilli()); now > s.seq {
+		s.seq = now
+	}
+	tag := rtcm3.EncapsulateByteArray(authPayload(s.key, s.seq, frame)).Serialize()
+	return append(append(make([]byte, 0, len(frame)+len(tag)), frame...), tag...)
+}
+
+// authPayload returns the payload of the tag frame of frame.
+func authPayload(key []byte, seq uint64, frame []byte) []byte {
+	payload := make([]byte, 2+authSeqLen, authPayloadLen)
+	// 12 bit message number, then a 4 bit version
+	binary.BigEndian.PutUint16(payload, AuthMessageNumber<<4)
+	binary.BigEndian.PutUint64(payload[2:], seq)
+	mac := hmac.New(sha256.New, key)
+	mac.Write(payload)
+	mac.Write(frame)
+	return append(payload, mac.Sum(nil)[:authMACLen]...)
+}
+
+// FrameVerifier checks the frames of a stream authenticated by a FrameSigner, holding back each frame
+// until its tag frame shows it was sent by a station with the key. Frames without a valid tag, or
+// with a tag replayed from earlier in the stream, are dropped. A nil verifier accepts every frame.
+type FrameVerifier struct {
+	key      []byte
+	pending  []byte // the last frame read, waiting for its tag
+	lastSeq  uint64
+	rejected Counter
+}
+
+// NewFrameVerifier returns a verifier with key, or nil if key is empty.
+func NewFrameVerifier(key string) *FrameVerifier {
+	if key == "" {
+		return nil
+	}
+	return &FrameVerifier{key: []byte(key)}
+}
+
+// Verify takes the next frame of the stream and returns the frame it authenticates if it is a valid
+// tag. The returned frame is only valid until the next call to Verify.
+func (v *FrameVerifier) Verify(frame []byte) ([]byte, bool) {
+	if v == nil {
+		return frame, true
+	}
+	payload := frame[3 : len(frame)-3]
+	if len(payload) < 2 || binary.BigEndian.Uint16(payload)>>4 != AuthMessageNumber {
+		if v.pending != nil {
+			v.rejected.Add(1)
+		}
+		v.pending = append(v.pending[:0], frame...)
+		return nil, false
+	}
+	if v.pending == nil {
+		return nil, false
+	}
+	signed := v.pending
+	v.pending = nil
+	if len(payload) != authPayloadLen {
+		v.rejected.Add(1)
+		return nil, false
+	}
+	seq := binary.BigEndian.Uint64(payload[2:])
+	if seq <= v.lastSeq || !hmac.Equal(payload, authPayload(v.key, seq, signed)) {
+		v.rejected.Add(1)
+		return nil, false
+	}
+	v.lastSeq = seq
+	return signed, true
+}
+
+// Rejected returns how many frames were dropped because they couldn't be authenticated.
+func (v *FrameVerifier) Rejected() uint64 {
+	if v == nil {
+		return 0
+	}
+	return v.rejected.Get()
+}
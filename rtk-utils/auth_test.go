@@ -0,0 +1,68 @@
+package rtkutils
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/go-gnss/rtcm/rtcm3"
+	"go.viam.com/test"
+)
+
+const testAuthKey = "0123456789abcdef"
+
+func TestFrameAuthentication(t *testing.T) {
+	test.That(t, ValidateAuthKey("auth_key", ""), test.ShouldBeNil)
+	test.That(t, ValidateAuthKey("auth_key", testAuthKey), test.ShouldBeNil)
+	test.That(t, ValidateAuthKey("auth_key", "short"), test.ShouldBeError,
+		errors.New("auth_key must be at least 16 characters"))
+
+	frame := rtcm3.EncapsulateByteArray([]byte{0x3e, 0xd0, 0x01, 0x02, 0x03}).Serialize()
+	var nilSigner *FrameSigner
+	test.That(t, nilSigner.Sign(frame), test.ShouldResemble, frame)
+	var nilVerifier *FrameVerifier
+	verified, ok := nilVerifier.Verify(frame)
+	test.That(t, ok, test.ShouldBeTrue)
+	test.That(t, verified, test.ShouldResemble, frame)
+	test.That(t, NewFrameSigner(""), test.ShouldBeNil)
+	test.That(t, NewFrameVerifier(""), test.ShouldBeNil)
+
+	signer := NewFrameSigner(testAuthKey)
+	signed := signer.Sign(frame)
+	replayed := append([]byte{}, signed...)
+	frames, rest := SplitRTCMFrames(append(signed, signer.Sign(frame)...))
+	test.That(t, rest, test.ShouldBeEmpty)
+	test.That(t, frames, test.ShouldHaveLength, 4)
+
+	// each frame is returned once its tag is read
+	v := NewFrameVerifier(testAuthKey)
+	_, ok = v.Verify(frames[0])
+	test.That(t, ok, test.ShouldBeFalse)
+	verified, ok = v.Verify(frames[1])
+	test.That(t, ok, test.ShouldBeTrue)
+	test.That(t, verified, test.ShouldResemble, frame)
+	v.Verify(frames[2])
+	_, ok = v.Verify(frames[3])
+	test.That(t, ok, test.ShouldBeTrue)
+	test.That(t, v.Rejected(), test.ShouldEqual, 0)
+
+	// a replayed frame, a frame without a tag and a tampered one are dropped
+	replayedFrames, _ := SplitRTCMFrames(replayed)
+	v.Verify(replayedFrames[0])
+	_, ok = v.Verify(replayedFrames[1])
+	test.That(t, ok, test.ShouldBeFalse)
+	frames, _ = SplitRTCMFrames(signer.Sign(frame))
+	v.Verify(frame)
+	tampered := bytes.Replace(frames[0], []byte{0x01, 0x02}, []byte{0x01, 0x07}, 1)
+	v.Verify(rtcm3.EncapsulateByteArray(tampered[3 : len(tampered)-3]).Serialize())
+	_, ok = v.Verify(frames[1])
+	test.That(t, ok, test.ShouldBeFalse)
+	test.That(t, v.Rejected(), test.ShouldEqual, 3)
+
+	// a tag signed with another key is dropped
+	frames, _ = SplitRTCMFrames(NewFrameSigner("another key of 16").Sign(frame))
+	v.Verify(frames[0])
+	_, ok = v.Verify(frames[1])
+	test.That(t, ok, test.ShouldBeFalse)
+	test.That(t, v.Rejected(), test.ShouldEqual, 4)
+}
@@ -0,0 +1,157 @@
+package rtkutils
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/go-gnss/rtcm/rtcm3"
+)
+
+// Base monitor event types.
+const (
+	EventBaseMoved        = "base_moved"
+	EventBaseMovedCleared = "base_moved_cleared"
+)
+
+const (
+	// single point positions whose median is compared to the reference position, a minute of epochs
+	baseMonitorEpochs = 60
+	// share of the threshold the offset has to drop below to clear a moved base
+	baseMonitorClearRatio = 0.8
+)
+
+// BaseMonitor checks that the antenna of a station stays at the reference position it broadcasts in
+// its 1005 or 1006 messages, since a base knocked over or moved after its survey silently corrupts
+// the fix of every rover. It solves single point positions from the GPS MSM4 and MSM7 observations
+// a StreamDecoder passes it, with the GPS ephemerides of the stream, and raises an event once their
+// median is further than a threshold from the reference position horizontally. Single point positions are only accurate to a few meters, so the
+// threshold has to be larger than that. A nil monitor checks nothing.
+type BaseMonitor struct {
+	threshold float64 // m
+	events    *EventLog
+
+	mu           sync.Mutex
+	ephemerides  map[uint8]rtcm3.Message1019
+	reference    [3]float64 // ECEF in m
+	hasReference bool
+	positions    [][3]float64 // latest single point positions, oldest first
+	offset       float64      // horizontal distance of their median from the reference in m
+	hasOffset    bool
+	moved        bool
+}
+
+// NewBaseMonitor returns a monitor raising events on events once the base is further than threshold
+// meters from its reference position, or nil if threshold is not positive.
+func NewBaseMonitor(threshold float64, events *EventLog) *BaseMonitor {
+	if threshold <= 0 {
+		return nil
+	}
+	return &BaseMonitor{threshold: threshold, events: events, ephemerides: map[uint8]rtcm3.Message1019{}}
+}
+
+// ValidateBaseMonitor checks the base monitor threshold of a config.
+func ValidateBaseMonitor(threshold float64) error {
+	if threshold < 0 {
+		return errors.New("base_move_threshold_m must not be negative")
+	}
+	return nil
+}
+
+// Update checks a message of the station's stream.
+func (m *BaseMonitor) Update(msg rtcm3.Message) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	switch msg := msg.(type) {
+	case rtcm3.Message1005:
+		m.setReference(msg.AntennaReferencePoint)
+	case rtcm3.Message1006:
+		m.setReference(msg.AntennaReferencePoint)
+	case rtcm3.Message1019:
+		m.ephemerides[msg.SatelliteID] = msg
+	case rtcm3.Message1074:
+		fine := make([]int64, len(msg.SignalData.Pseudoranges))
+		for i, v := range msg.SignalData.Pseudoranges {
+			fine[i] = int64(v)
+		}
+		m.addEpoch(gpsPseudoranges(msg.MsmHeader, msg.SatelliteData.RangeMilliseconds, msg.SatelliteData.Ranges,
+			fine, 15, 24, m.ephemerides))
+	case rtcm3.Message1077:
+		fine := make([]int64, len(msg.SignalData.Pseudoranges))
+		for i, v := range msg.SignalData.Pseudoranges {
+			fine[i] = int64(v)
+		}
+		m.addEpoch(gpsPseudoranges(msg.MsmHeader, msg.SatelliteData.RangeMilliseconds, msg.SatelliteData.Ranges,
+			fine, 20, 29, m.ephemerides))
+	}
+}
+
+// setReference sets the reference position of the base. A new survey starts the check over.
+// The caller must hold mu.
+func (m *BaseMonitor) setReference(arp rtcm3.AntennaReferencePoint) {
+	reference := [3]float64{
+		float64(arp.ReferencePointX) * 1e-4,
+		float64(arp.ReferencePointY) * 1e-4,
+		float64(arp.ReferencePointZ) * 1e-4,
+	}
+	if m.hasReference && reference == m.reference {
+		return
+	}
+	m.reference, m.hasReference = reference, true
+	m.positions, m.hasOffset = nil, false
+}
+
+// addEpoch solves the position of an epoch and compares the median of the latest ones to the
+// reference position. The caller must hold mu.
+func (m *BaseMonitor) addEpoch(ranges []pseudorange) {
+	if !m.hasReference {
+		return
+	}
+	pos, ok := solvePosition(ranges, m.reference)
+	if !ok {
+		return
+	}
+	m.positions = append(m.positions, pos)
+	if len(m.positions) > baseMonitorEpochs {
+		m.positions = m.positions[1:]
+	}
+	if len(m.positions) < baseMonitorEpochs {
+		return
+	}
+	m.offset, m.hasOffset = horizontalDistance(medianPosition(m.positions), m.reference), true
+
+	clear := m.threshold * baseMonitorClearRatio
+	switch {
+	case !m.moved && m.offset > m.threshold:
+		m.moved = true
+		m.events.Add(EventBaseMoved, "the base is %.1f m from its reference position, over the %.1f m threshold; "+
+			"check the antenna and survey it again", m.offset, m.threshold)
+	case m.moved && m.offset < clear:
+		m.moved = false
+		m.events.Add(EventBaseMovedCleared, "the base is back within %.1f m of its reference position", clear)
+	}
+}
+
+// Offset returns the horizontal distance in m of the base from its reference position, and false
+// until it is known.
+func (m *BaseMonitor) Offset() (float64, bool) {
+	if m == nil {
+		return 0, false
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.offset, m.hasOffset
+}
+
+// Moved reports whether the base is further than the threshold from its reference position.
+func (m *BaseMonitor) Moved() bool {
+	if m == nil {
+		return false
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.moved
+}
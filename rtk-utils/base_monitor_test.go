@@ -0,0 +1,75 @@
+package rtkutils
+
+import (
+	"math"
+	"testing"
+
+	"github.com/edaniels/golog"
+	"github.com/go-gnss/rtcm/rtcm3"
+	"go.viam.com/test"
+)
+
+func referenceMessage(pos [3]float64) rtcm3.Message1005 {
+	return rtcm3.Message1005{
+		AbstractMessage: rtcm3.AbstractMessage{MessageNumber: 1005},
+		AntennaReferencePoint: rtcm3.AntennaReferencePoint{
+			ReferencePointX: int64(math.Round(pos[0] * 1e4)),
+			ReferencePointY: int64(math.Round(pos[1] * 1e4)),
+			ReferencePointZ: int64(math.Round(pos[2] * 1e4)),
+		},
+	}
+}
+
+func TestBaseMonitor(t *testing.T) {
+	var nilMonitor *BaseMonitor
+	nilMonitor.Update(rtcm3.MessageUnknown{})
+	test.That(t, nilMonitor.Moved(), test.ShouldBeFalse)
+	test.That(t, NewBaseMonitor(0, nil), test.ShouldBeNil)
+	test.That(t, ValidateBaseMonitor(5), test.ShouldBeNil)
+	test.That(t, ValidateBaseMonitor(-1), test.ShouldNotBeNil)
+
+	events := NewEventLog(golog.NewTestLogger(t))
+	m := NewBaseMonitor(5, events)
+	ephemerides := testConstellation()
+	for _, eph := range ephemerides {
+		m.Update(eph)
+	}
+	surveyed := geodeticToECEF(37.4, -122.1, 30)
+	m.Update(referenceMessage(surveyed))
+
+	// the offset is only known after a minute of epochs
+	epoch := testMSM4(surveyed, ephemerides)
+	for i := 0; i < baseMonitorEpochs-1; i++ {
+		m.Update(epoch)
+	}
+	_, ok := m.Offset()
+	test.That(t, ok, test.ShouldBeFalse)
+	m.Update(epoch)
+	offset, ok := m.Offset()
+	test.That(t, ok, test.ShouldBeTrue)
+	test.That(t, offset, test.ShouldBeLessThan, 0.1)
+	test.That(t, m.Moved(), test.ShouldBeFalse)
+
+	// the tripod is knocked 20m away, which shows once most of the window has moved
+	moved := testMSM4(geodeticToECEF(37.4, -122.09978, 30), ephemerides)
+	for i := 0; i < baseMonitorEpochs/2-1; i++ {
+		m.Update(moved)
+	}
+	test.That(t, m.Moved(), test.ShouldBeFalse)
+	m.Update(moved)
+	m.Update(moved)
+	test.That(t, m.Moved(), test.ShouldBeTrue)
+	offset, _ = m.Offset()
+	test.That(t, offset, test.ShouldAlmostEqual, 19.5, 0.5)
+	test.That(t, events.Events(""), test.ShouldHaveLength, 1)
+	test.That(t, events.Events("")[0].Type, test.ShouldEqual, EventBaseMoved)
+
+	// surveying the new position again clears it
+	m.Update(referenceMessage(geodeticToECEF(37.4, -122.09978, 30)))
+	for i := 0; i < baseMonitorEpochs; i++ {
+		m.Update(moved)
+	}
+	test.That(t, m.Moved(), test.ShouldBeFalse)
+	test.That(t, events.Events(""), test.ShouldHaveLength, 2)
+	test.That(t, events.Events("")[1].Type, test.ShouldEqual, EventBaseMovedCleared)
+}
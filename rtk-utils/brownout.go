@@ -0,0 +1,135 @@
+package rtkutils
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// Brownout event types.
+const (
+	EventBrownout      = "brownout"
+	EventPowerRestored = "power_restored"
+)
+
+const (
+	// BrownoutCheckInterval is how often a station reads its voltage sensor.
+	BrownoutCheckInterval = 5 * time.Second
+	// how far above the min voltage the voltage has to be back by default to resume
+	defaultBrownoutHysteresis = 0.5 // V
+	// the reading of a voltage sensor with its voltage, in volts
+	voltageReading = "volts"
+)
+
+// ValidateBrownout checks the voltage sensor of a config and the voltages it stops below and resumes at.
+func ValidateBrownout(sensor string, min, resume float64) error {
+	if min < 0 || resume < 0 {
+		return errors.New("min_voltage and resume_voltage must not be negative")
+	}
+	if sensor == "" {
+		if min > 0 || resume > 0 {
+			return errors.New("min_voltage and resume_voltage need a voltage_sensor")
+		}
+		return nil
+	}
+	if min == 0 {
+		return errors.New("voltage_sensor needs a min_voltage")
+	}
+	if resume != 0 && resume <= min {
+		return fmt.Errorf("resume_voltage (%v) must be greater than min_voltage (%v)", resume, min)
+	}
+	return nil
+}
+
+// SupplyVoltage returns the voltage in the readings of the voltage sensor of a station.
+func SupplyVoltage(readings map[string]interface{}) (float64, error) {
+	switch volts := readings[voltageReading].(type) {
+	case float64:
+		return volts, nil
+	case float32:
+		return float64(volts), nil
+	case nil:
+		return 0, fmt.Errorf("no %q in the readings", voltageReading)
+	default:
+		return 0, fmt.Errorf("%q reading %v isn't a number", voltageReading, volts)
+	}
+}
+
+// BrownoutGuard watches the voltage of the supply of a station, such as the battery of a solar
+// powered base, so that the station stops transmitting and saves its state before the supply browns
+// out, and resumes by itself once the voltage is back above the resume voltage, which keeps a voltage
+// hovering around the minimum from toggling it. A nil guard never browns out.
+type BrownoutGuard struct {
+	min, resume float64 // V
+
+	mu      sync.Mutex
+	low     bool
+	voltage float64 // NaN until it is read
+	since   time.Time
+}
+
+// NewBrownoutGuard returns a guard stopping below min V and resuming at resume V, or nil if min is 0.
+// A zero resume voltage defaults to half a volt above min.
+func NewBrownoutGuard(min, resume float64) *BrownoutGuard {
+	if min == 0 {
+		return nil
+	}
+	if resume == 0 {
+		resume = min + defaultBrownoutHysteresis
+	}
+	return &BrownoutGuard{min: min, resume: resume, voltage: math.NaN()}
+}
+
+// Update takes a voltage read at now, adding an event to events and returning true when it browns out
+// or recovers.
+func (b *BrownoutGuard) Update(voltage float64, now time.Time, events *EventLog) bool {
+	if b == nil {
+		return false
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.voltage = voltage
+	switch {
+	case !b.low && voltage < b.min:
+		b.low, b.since = true, now
+		events.Add(EventBrownout, "supply at %.2f V, below %.2f V, stopped transmitting", voltage, b.min)
+		return true
+	case b.low && voltage >= b.resume:
+		events.Add(EventPowerRestored, "supply back at %.2f V after %s, transmitting again",
+			voltage, now.Sub(b.since).Round(time.Second))
+		b.low, b.since = false, time.Time{}
+		return true
+	}
+	return false
+}
+
+// Low returns whether the supply browned out.
+func (b *BrownoutGuard) Low() bool {
+	if b == nil {
+		return false
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.low
+}
+
+// State returns StateBrownout for a station in state while the supply browned out, unless it failed,
+// and state otherwise.
+func (b *BrownoutGuard) State(state string) string {
+	if state != StateError && b.Low() {
+		return StateBrownout
+	}
+	return state
+}
+
+// Readings returns the last voltage read and whether the supply browned out.
+func (b *BrownoutGuard) Readings() map[string]interface{} {
+	if b == nil {
+		return map[string]interface{}{}
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return map[string]interface{}{"supply_voltage": b.voltage, "brownout": b.low}
+}
@@ -0,0 +1,71 @@
+package rtkutils
+
+import (
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/edaniels/golog"
+	"go.viam.com/test"
+)
+
+func TestValidateBrownout(t *testing.T) {
+	test.That(t, ValidateBrownout("", 0, 0), test.ShouldBeNil)
+	test.That(t, ValidateBrownout("battery", 11.5, 0), test.ShouldBeNil)
+	test.That(t, ValidateBrownout("battery", 11.5, 12.2), test.ShouldBeNil)
+	test.That(t, ValidateBrownout("", 11.5, 0), test.ShouldBeError, errors.New("min_voltage and resume_voltage need a voltage_sensor"))
+	test.That(t, ValidateBrownout("battery", 0, 0), test.ShouldBeError, errors.New("voltage_sensor needs a min_voltage"))
+	test.That(t, ValidateBrownout("battery", -1, 0), test.ShouldBeError,
+		errors.New("min_voltage and resume_voltage must not be negative"))
+	test.That(t, ValidateBrownout("battery", 11.5, 11), test.ShouldBeError,
+		errors.New("resume_voltage (11) must be greater than min_voltage (11.5)"))
+}
+
+func TestSupplyVoltage(t *testing.T) {
+	volts, err := SupplyVoltage(map[string]interface{}{"volts": 12.4, "amps": 0.3})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, volts, test.ShouldAlmostEqual, 12.4, 1e-9)
+	volts, err = SupplyVoltage(map[string]interface{}{"volts": float32(11.5)})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, volts, test.ShouldAlmostEqual, 11.5, 1e-6)
+
+	_, err = SupplyVoltage(map[string]interface{}{"amps": 0.3})
+	test.That(t, err, test.ShouldBeError, errors.New(`no "volts" in the readings`))
+	_, err = SupplyVoltage(map[string]interface{}{"volts": "12"})
+	test.That(t, err, test.ShouldBeError, errors.New(`"volts" reading 12 isn't a number`))
+}
+
+func TestBrownoutGuard(t *testing.T) {
+	events := NewEventLog(golog.NewTestLogger(t))
+	now := time.Now()
+
+	var none *BrownoutGuard
+	test.That(t, none.Update(0, now, events), test.ShouldBeFalse)
+	test.That(t, none.Low(), test.ShouldBeFalse)
+	test.That(t, none.State(StateHealthy), test.ShouldEqual, StateHealthy)
+	test.That(t, NewBrownoutGuard(0, 0), test.ShouldBeNil)
+
+	guard := NewBrownoutGuard(11.5, 0)
+	test.That(t, math.IsNaN(guard.Readings()["supply_voltage"].(float64)), test.ShouldBeTrue)
+	test.That(t, guard.Update(12.4, now, events), test.ShouldBeFalse)
+	test.That(t, guard.Update(11.4, now, events), test.ShouldBeTrue)
+	test.That(t, guard.Low(), test.ShouldBeTrue)
+	test.That(t, guard.State(StateHealthy), test.ShouldEqual, StateBrownout)
+	test.That(t, guard.State(StateError), test.ShouldEqual, StateError)
+	test.That(t, guard.Update(11.2, now, events), test.ShouldBeFalse)
+
+	// back above the minimum but below the resume voltage, half a volt above it
+	test.That(t, guard.Update(11.8, now.Add(time.Minute), events), test.ShouldBeFalse)
+	test.That(t, guard.Low(), test.ShouldBeTrue)
+	test.That(t, guard.Update(12.0, now.Add(time.Hour), events), test.ShouldBeTrue)
+	test.That(t, guard.Low(), test.ShouldBeFalse)
+	test.That(t, guard.Readings(), test.ShouldResemble, map[string]interface{}{"supply_voltage": 12.0, "brownout": false})
+
+	recorded := events.Events("")
+	test.That(t, recorded, test.ShouldHaveLength, 2)
+	test.That(t, recorded[0].Type, test.ShouldEqual, EventBrownout)
+	test.That(t, recorded[0].Message, test.ShouldEqual, "supply at 11.40 V, below 11.50 V, stopped transmitting")
+	test.That(t, recorded[1].Type, test.ShouldEqual, EventPowerRestored)
+	test.That(t, recorded[1].Message, test.ShouldEqual, "supply back at 12.00 V after 1h0m0s, transmitting again")
+}
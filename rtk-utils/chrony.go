@@ -0,0 +1,131 @@
+package rtkutils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/edaniels/golog"
+)
+
+const (
+	// chronySOCKMagic identifies the samples of chrony's SOCK refclock, "SOCK"
+	chronySOCKMagic = 0x534f434b
+	// a sample chrony doesn't take at once is dropped, the next one comes within a second
+	chronyWriteTimeout = 100 * time.Millisecond
+)
+
+// ValidateChronySocket checks the chrony_socket of a rover.
+func ValidateChronySocket(path string) error {
+	if path != "" && path[0] != '/' {
+		return errors.New("chrony_socket must be an absolute path")
+	}
+	return nil
+}
+
+// ChronySocket sends the clock samples of a receiver to the SOCK refclock of chrony, configured
+// with e.g. `refclock SOCK /var/run/chrony.rtk.sock refid GPS`, so that the system clock is
+// disciplined by the receiver. chrony creates the socket and must be running before samples can be
+// sent. A nil socket sends nothing.
+type ChronySocket struct {
+	path   string
+	logger golog.Logger
+
+	mu      sync.Mutex
+	conn    net.Conn // nil until connected, and after a send failed
+	failing bool     // the last send failed, so the failure was logged
+	sent    Counter
+}
+
+// NewChronySocket returns a socket sending to chrony's socket at path, or nil if path is empty.
+func NewChronySocket(path string, logger golog.Logger) *ChronySocket {
+	if path == "" {
+		return nil
+	}
+	return &ChronySocket{path: path, logger: logger}
+}
+
+// Send sends chrony a sample of GPS time gps at host time host, as a pulse of a pulse per second
+// if pulse is set. Failures are logged once until a sample is sent again.
+func (s *ChronySocket) Send(host, gps time.Time, pulse bool) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	err := s.send(chronySample(host, gps.Sub(host), pulse))
+	switch {
+	case err != nil && !s.failing:
+		s.logger.Warnf("failed to send a clock sample to chrony at %s: %s", s.path, err)
+	case err == nil && s.failing:
+		s.logger.Infof("sending clock samples to chrony at %s again", s.path)
+	}
+	s.failing = err != nil
+}
+
+// send writes a sample, connecting first if needed. The caller must hold mu.
+func (s *ChronySocket) send(sample []byte) error {
+	if s.conn == nil {
+		conn, err := net.Dial("unixgram", s.path)
+		if err != nil {
+			return err
+		}
+		s.conn = conn
+	}
+	if err := s.conn.SetWriteDeadline(time.Now().Add(chronyWriteTimeout)); err != nil {
+		return err
+	}
+	if _, err := s.conn.Write(sample); err != nil {
+		s.conn.Close()
+		s.conn = nil
+		return err
+	}
+	s.sent.Add(1)
+	return nil
+}
+
+// Sent returns the number of samples sent to chrony.
+func (s *ChronySocket) Sent() uint64 {
+	if s == nil {
+		return 0
+	}
+	return s.sent.Get()
+}
+
+// Close closes the connection to chrony.
+func (s *ChronySocket) Close() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conn != nil {
+		s.conn.Close()
+		s.conn = nil
+	}
+}
+
+// chronySample encodes the struct sock_sample of chrony for 64 bit little endian hosts: the host
+// time as a struct timeval, the offset of true time from it in s, whether it is a pulse, the leap
+// second status, padding, and the magic number.
+func chronySample(host time.Time, offset time.Duration, pulse bool) []byte {
+	var buf bytes.Buffer
+	usec := host.UnixNano() / int64(time.Microsecond)
+	var isPulse int32
+	if pulse {
+		isPulse = 1
+	}
+	for _, v := range []interface{}{
+		usec / 1e6, usec % 1e6,
+		math.Float64bits(offset.Seconds()),
+		isPulse, int32(0), int32(0), int32(chronySOCKMagic),
+	} {
+		// writes to a bytes.Buffer can't fail
+		_ = binary.Write(&buf, binary.LittleEndian, v)
+	}
+	return buf.Bytes()
+}
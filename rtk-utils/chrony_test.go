@@ -0,0 +1,60 @@
+package rtkutils
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/edaniels/golog"
+	"go.viam.com/test"
+)
+
+func TestValidateChronySocket(t *testing.T) {
+	test.That(t, ValidateChronySocket(""), test.ShouldBeNil)
+	test.That(t, ValidateChronySocket("/var/run/chrony.rtk.sock"), test.ShouldBeNil)
+	test.That(t, ValidateChronySocket("chrony.sock"), test.ShouldBeError, errors.New("chrony_socket must be an absolute path"))
+}
+
+func TestChronySample(t *testing.T) {
+	host := time.Unix(1700000000, 250000000)
+	sample := chronySample(host, 1500*time.Millisecond, true)
+	test.That(t, sample, test.ShouldHaveLength, 40)
+	test.That(t, binary.LittleEndian.Uint64(sample[0:]), test.ShouldEqual, uint64(1700000000))
+	test.That(t, binary.LittleEndian.Uint64(sample[8:]), test.ShouldEqual, uint64(250000))
+	test.That(t, math.Float64frombits(binary.LittleEndian.Uint64(sample[16:])), test.ShouldEqual, 1.5)
+	test.That(t, binary.LittleEndian.Uint32(sample[24:]), test.ShouldEqual, uint32(1))
+	test.That(t, binary.LittleEndian.Uint32(sample[36:]), test.ShouldEqual, uint32(chronySOCKMagic))
+}
+
+func TestChronySocket(t *testing.T) {
+	var none *ChronySocket
+	none.Send(time.Now(), time.Now(), false)
+	test.That(t, none.Sent(), test.ShouldEqual, uint64(0))
+	test.That(t, NewChronySocket("", golog.NewTestLogger(t)), test.ShouldBeNil)
+
+	path := filepath.Join(t.TempDir(), "chrony.sock")
+	s := NewChronySocket(path, golog.NewTestLogger(t))
+	defer s.Close()
+	// chrony isn't running yet
+	s.Send(time.Now(), time.Now(), false)
+	test.That(t, s.Sent(), test.ShouldEqual, uint64(0))
+
+	chrony, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	test.That(t, err, test.ShouldBeNil)
+	defer chrony.Close()
+	host := time.Now()
+	s.Send(host, host.Add(-2*time.Second), false)
+	test.That(t, s.Sent(), test.ShouldEqual, uint64(1))
+
+	buf := make([]byte, 64)
+	test.That(t, chrony.SetReadDeadline(time.Now().Add(time.Second)), test.ShouldBeNil)
+	n, err := chrony.Read(buf)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, n, test.ShouldEqual, 40)
+	test.That(t, math.Float64frombits(binary.LittleEndian.Uint64(buf[16:])), test.ShouldEqual, -2.0)
+	test.That(t, binary.LittleEndian.Uint32(buf[24:]), test.ShouldEqual, uint32(0))
+}
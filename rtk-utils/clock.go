@@ -0,0 +1,347 @@
+package rtkutils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.viam.com/rdk/components/board"
+)
+
+// ClockCommand returns the offset and drift of the host clock from GPS time, and converts the
+// "host_time" of the command (RFC 3339) to GPS time if it is set.
+const ClockCommand = "clock"
+
+// Where the GPS time of a clock sample comes from.
+const (
+	// ClockSourceFix pairs the time of a fix with when its first sentence was read, which is late by
+	// the output latency of the receiver, tens to hundreds of ms, but steady.
+	ClockSourceFix = "fix"
+	// ClockSourcePPS pairs the pulse per second of the receiver with the second it marks.
+	ClockSourcePPS = "pps"
+)
+
+// ErrNoClockEstimate is returned before a ClockEstimator has a fix with a date.
+var ErrNoClockEstimate = errors.New("no clock estimate yet, the receiver has no fix with a date")
+
+const (
+	// samples older than this are left out of the estimate, so it follows changes of the drift
+	clockWindow = 10 * time.Minute
+	// samples kept at most, 10 minutes of fixes at 1 Hz
+	maxClockSamples = 600
+	// a sample further than this from the estimate is a step of the host clock, which starts over
+	clockStep = time.Second
+	// fixes are only used while no pulse was read for this long
+	ppsMaxAge = 3 * time.Second
+	// a pulse is only paired with a fix read this recently
+	ppsFixMaxAge = 2 * time.Second
+)
+
+// ValidatePPS checks the pulse per second settings of a rover.
+func ValidatePPS(boardName, interrupt string) error {
+	if (boardName == "") != (interrupt == "") {
+		return errors.New("pps_board and pps_interrupt must be set together")
+	}
+	return nil
+}
+
+type clockSample struct {
+	host   time.Time // when the sample was taken, with its monotonic reading
+	offset float64   // GPS time minus host time, in s
+}
+
+// ClockEstimate is the offset and drift of the host clock from GPS time.
+type ClockEstimate struct {
+	Offset   time.Duration // GPS time minus host time
+	DriftPPM float64       // how much faster GPS time runs than host time, in parts per million
+	RMS      time.Duration // of the samples from the estimate
+	Samples  int
+	Source   string
+}
+
+// ClockEstimator estimates the offset and drift of the host clock from GPS time, so that the
+// timestamps of other sensors of the robot can be converted to GPS time. It fits a line to the
+// offsets of the last 10 minutes of samples, taken from the fixes of the receiver or from its pulse
+// per second when one is read. GPS time is the UTC time reported by the receiver.
+type ClockEstimator struct {
+	onSample func(source string, host, gps time.Time)
+
+	mu       sync.Mutex
+	date     time.Time // UTC date of the latest RMC or ZDA, zero until one is read
+	lastTime string    // time field of the latest fix
+	fixHost  time.Time // when the latest fix was read
+	fixTime  time.Time // GPS time of the latest fix
+	lastPPS  time.Time
+	source   string
+	samples  []clockSample // oldest to newest
+}
+
+// NewClockEstimator returns an estimator with no samples, calling onSample, if it isn't nil, with
+// every sample it takes of the GPS time gps at host time host.
+func NewClockEstimator(onSample func(source string, host, gps time.Time)) *ClockEstimator {
+	return &ClockEstimator{onSample: onSample}
+}
+
+// Update reads the time of the fix of a sentence read at now. The first sentence of a fix is taken
+// as a sample, unless pulses are read.
+func (c *ClockEstimator) Update(sentence string, now time.Time) {
+	fields, ok := timeFields(sentence)
+	if !ok {
+		return
+	}
+	tod, ok := parseTimeOfDay(fields[1])
+	if !ok {
+		return
+	}
+
+	c.mu.Lock()
+	fix, ok := c.updateFix(fields, tod, now)
+	c.mu.Unlock()
+	if ok && c.onSample != nil {
+		c.onSample(ClockSourceFix, now, fix)
+	}
+}
+
+// updateFix reads the fix of the fields of a time sentence, and returns its time if it is taken as
+// a sample. The caller must hold mu.
+func (c *ClockEstimator) updateFix(fields []string, tod time.Duration, now time.Time) (time.Time, bool) {
+	switch fields[0][3:] {
+	case "RMC":
+		// $GPRMC,<time>,<status>,<lat>,N,<lng>,E,<speed>,<course>,<ddmmyy>
+		if len(fields) > 9 {
+			if date, err := time.Parse("020106", fields[9]); err == nil {
+				c.date = date
+			}
+		}
+	case "ZDA":
+		// $GPZDA,<time>,<day>,<month>,<year>
+		if len(fields) > 4 {
+			if date, err := time.Parse("2 1 2006", fields[2]+" "+fields[3]+" "+fields[4]); err == nil {
+				c.date = date
+			}
+		}
+	}
+	if fields[1] == c.lastTime || c.date.IsZero() {
+		return time.Time{}, false
+	}
+	c.lastTime = fields[1]
+	if !fixValid(fields) {
+		// the time of a receiver without a fix comes from its own clock
+		return time.Time{}, false
+	}
+
+	fix := c.date.Add(tod)
+	if !c.fixTime.IsZero() && fix.Before(c.fixTime.Add(-12*time.Hour)) {
+		// midnight passed before the receiver sent the date of the new day
+		fix = fix.Add(24 * time.Hour)
+		c.date = c.date.Add(24 * time.Hour)
+	}
+	c.fixHost = now
+	c.fixTime = fix
+	if !c.lastPPS.IsZero() && now.Sub(c.lastPPS) < ppsMaxAge {
+		return time.Time{}, false
+	}
+	c.add(ClockSourceFix, now, fix)
+	return fix, true
+}
+
+// Pulse takes a sample from a pulse per second read at now. The second it marks is the one after
+// the latest fix, as the receiver sends a fix after the pulse of its second.
+func (c *ClockEstimator) Pulse(now time.Time) {
+	c.mu.Lock()
+	c.lastPPS = now
+	if c.fixHost.IsZero() || now.Sub(c.fixHost) > ppsFixMaxAge {
+		c.mu.Unlock()
+		return
+	}
+	// the fix was read late by less than a second, so the pulse is on the next whole second
+	late := c.fixTime.Add(now.Sub(c.fixHost))
+	second := late.Truncate(time.Second)
+	if second.Before(late) {
+		second = second.Add(time.Second)
+	}
+	c.add(ClockSourcePPS, now, second)
+	c.mu.Unlock()
+	if c.onSample != nil {
+		c.onSample(ClockSourcePPS, now, second)
+	}
+}
+
+// add adds a sample of GPS time gps at host, starting over when the source changes or the host
+// clock stepped. The caller must hold mu.
+func (c *ClockEstimator) add(source string, host, gps time.Time) {
+	sample := clockSample{host: host, offset: gps.Sub(host).Seconds()}
+	if source != c.source {
+		c.source = source
+		c.samples = nil
+	}
+	if est, ok := c.estimate(host); ok && math.Abs(sample.offset-est.Offset.Seconds()) > clockStep.Seconds() {
+		c.samples = nil
+	}
+	c.samples = append(c.samples, sample)
+	for len(c.samples) > maxClockSamples || host.Sub(c.samples[0].host) > clockWindow {
+		c.samples = c.samples[1:]
+	}
+}
+
+// Estimate returns the offset of the host clock at now and its drift, and false if there are no samples.
+func (c *ClockEstimator) Estimate(now time.Time) (ClockEstimate, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.estimate(now)
+}
+
+// estimate fits a line to the offsets of the samples. The caller must hold mu.
+func (c *ClockEstimator) estimate(now time.Time) (ClockEstimate, bool) {
+	n := len(c.samples)
+	if n == 0 {
+		return ClockEstimate{}, false
+	}
+	ref := c.samples[0].host
+	var meanX, meanY float64
+	for _, s := range c.samples {
+		meanX += s.host.Sub(ref).Seconds()
+		meanY += s.offset
+	}
+	meanX /= float64(n)
+	meanY /= float64(n)
+	var sxx, sxy float64
+	for _, s := range c.samples {
+		dx := s.host.Sub(ref).Seconds() - meanX
+		sxx += dx * dx
+		sxy += dx * (s.offset - meanY)
+	}
+	var drift float64
+	if sxx > 0 {
+		drift = sxy / sxx
+	}
+	at := func(host time.Time) float64 {
+		return meanY + drift*(host.Sub(ref).Seconds()-meanX)
+	}
+	var sumSq float64
+	for _, s := range c.samples {
+		r := s.offset - at(s.host)
+		sumSq += r * r
+	}
+	return ClockEstimate{
+		Offset:   time.Duration(at(now) * float64(time.Second)),
+		DriftPPM: drift * 1e6,
+		RMS:      time.Duration(math.Sqrt(sumSq/float64(n)) * float64(time.Second)),
+		Samples:  n,
+		Source:   c.source,
+	}, true
+}
+
+// GPSTime returns the GPS time of host time host, and false if there is no estimate.
+func (c *ClockEstimator) GPSTime(host time.Time) (time.Time, bool) {
+	est, ok := c.Estimate(host)
+	if !ok {
+		return time.Time{}, false
+	}
+	return host.Add(est.Offset).UTC(), true
+}
+
+// ClockResult answers a ClockCommand.
+func (c *ClockEstimator) ClockResult(cmd map[string]interface{}) (map[string]interface{}, error) {
+	host := time.Now()
+	if s, ok := cmd["host_time"].(string); ok {
+		t, err := time.Parse(time.RFC3339Nano, s)
+		if err != nil {
+			return nil, fmt.Errorf("host_time must be an RFC 3339 time: %w", err)
+		}
+		host = t
+	}
+	est, ok := c.Estimate(host)
+	if !ok {
+		return nil, ErrNoClockEstimate
+	}
+	return map[string]interface{}{
+		"host_time":  host.UTC().Format(time.RFC3339Nano),
+		"gps_time":   host.Add(est.Offset).UTC().Format(time.RFC3339Nano),
+		"offset_sec": est.Offset.Seconds(),
+		"drift_ppm":  est.DriftPPM,
+		"rms_sec":    est.RMS.Seconds(),
+		"samples":    est.Samples,
+		"source":     est.Source,
+	}, nil
+}
+
+// WatchPPS passes the rising edges of the pulse per second on interrupt of b to c until ctx is done.
+func WatchPPS(ctx context.Context, b board.Board, interrupt string, c *ClockEstimator) error {
+	di, ok := b.DigitalInterruptByName(interrupt)
+	if !ok {
+		return fmt.Errorf("no digital interrupt %q on the pps board", interrupt)
+	}
+	ticks := make(chan board.Tick, 4)
+	di.AddCallback(ticks)
+	defer di.RemoveCallback(ticks)
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case tick := <-ticks:
+			// the tick timestamps of boards have no common origin with the host clock, so the
+			// pulse is timed as it is read
+			if tick.High {
+				c.Pulse(time.Now())
+			}
+		}
+	}
+}
+
+// timeFields returns the fields of an RMC, GGA, GNS or ZDA sentence, with the time of its fix
+// second, and false for other sentences.
+func timeFields(line string) ([]string, bool) {
+	ind := strings.Index(line, "$G")
+	if ind == -1 {
+		return nil, false
+	}
+	line = line[ind:]
+	if star := strings.IndexByte(line, '*'); star != -1 {
+		line = line[:star]
+	}
+	fields := strings.Split(strings.TrimSpace(line), ",")
+	if len(fields[0]) < 6 || len(fields) < 2 {
+		return nil, false
+	}
+	switch fields[0][3:] {
+	case "RMC", "GGA", "GNS", "ZDA":
+		return fields, true
+	default:
+		return nil, false
+	}
+}
+
+// fixValid reports whether the fields of a time sentence are of a fix.
+func fixValid(fields []string) bool {
+	switch fields[0][3:] {
+	case "RMC":
+		return len(fields) > 2 && fields[2] == "A"
+	case "GGA":
+		return len(fields) > 6 && fields[6] != "" && fields[6] != "0"
+	case "GNS":
+		return len(fields) > 6 && strings.Trim(fields[6], "N") != ""
+	default:
+		return true
+	}
+}
+
+// parseTimeOfDay parses an nmea time, hhmmss.ss.
+func parseTimeOfDay(s string) (time.Duration, bool) {
+	if len(s) < 6 {
+		return 0, false
+	}
+	h, err1 := strconv.Atoi(s[0:2])
+	m, err2 := strconv.Atoi(s[2:4])
+	sec, err3 := strconv.ParseFloat(s[4:], 64)
+	if err1 != nil || err2 != nil || err3 != nil || h > 23 || m > 59 || sec >= 61 {
+		return 0, false
+	}
+	return time.Duration(h)*time.Hour + time.Duration(m)*time.Minute +
+		time.Duration(math.Round(sec*1000))*time.Millisecond, true
+}
@@ -0,0 +1,157 @@
+package rtkutils
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go.viam.com/rdk/components/board"
+	"go.viam.com/test"
+)
+
+func rmcAt(tod, date string) string {
+	return "$GNRMC," + tod + ",A,3723.46587704,N,12202.26957864,W,0.0,0.0," + date + ",,,D*00"
+}
+
+func ggaAt(tod string) string {
+	return "$GNGGA," + tod + ",3723.46587704,N,12202.26957864,W,4,12,0.6,18.893,M,-25.669,M,1.0,0031*00"
+}
+
+func TestValidatePPS(t *testing.T) {
+	test.That(t, ValidatePPS("", ""), test.ShouldBeNil)
+	test.That(t, ValidatePPS("pi", "pps"), test.ShouldBeNil)
+	test.That(t, ValidatePPS("pi", ""), test.ShouldBeError, errors.New("pps_board and pps_interrupt must be set together"))
+}
+
+func TestParseTimeOfDay(t *testing.T) {
+	tod, ok := parseTimeOfDay("123456.78")
+	test.That(t, ok, test.ShouldBeTrue)
+	test.That(t, tod, test.ShouldEqual, 12*time.Hour+34*time.Minute+56780*time.Millisecond)
+	_, ok = parseTimeOfDay("")
+	test.That(t, ok, test.ShouldBeFalse)
+	_, ok = parseTimeOfDay("250000.00")
+	test.That(t, ok, test.ShouldBeFalse)
+}
+
+func TestClockEstimatorFixes(t *testing.T) {
+	c := NewClockEstimator(nil)
+	start := time.Now()
+	_, err := c.ClockResult(map[string]interface{}{})
+	test.That(t, err, test.ShouldEqual, ErrNoClockEstimate)
+
+	// no date yet
+	c.Update(ggaAt("115959.00"), start)
+	_, ok := c.Estimate(start)
+	test.That(t, ok, test.ShouldBeFalse)
+
+	// a host clock 10 ppm slow, reading every fix 100 ms late
+	gpsStart := time.Date(2026, 3, 16, 12, 0, 0, 0, time.UTC)
+	var host time.Time
+	for i := 0; i < 60; i++ {
+		host = start.Add(time.Duration(i)*(time.Second-10*time.Microsecond) + 100*time.Millisecond)
+		tod := gpsStart.Add(time.Duration(i) * time.Second).Format("150405.00")
+		c.Update(rmcAt(tod, "160326"), host)
+		c.Update(ggaAt(tod), host.Add(10*time.Millisecond))
+	}
+	est, ok := c.Estimate(host)
+	test.That(t, ok, test.ShouldBeTrue)
+	test.That(t, est.Samples, test.ShouldEqual, 60)
+	test.That(t, est.Source, test.ShouldEqual, ClockSourceFix)
+	test.That(t, est.DriftPPM, test.ShouldAlmostEqual, 10, 1e-3)
+	test.That(t, est.RMS, test.ShouldBeLessThan, time.Microsecond)
+	last := gpsStart.Add(59 * time.Second)
+	test.That(t, est.Offset, test.ShouldAlmostEqual, last.Sub(host), float64(time.Microsecond))
+
+	gps, ok := c.GPSTime(host)
+	test.That(t, ok, test.ShouldBeTrue)
+	test.That(t, gps.Sub(last), test.ShouldBeBetween, -time.Microsecond, time.Microsecond)
+
+	res, err := c.ClockResult(map[string]interface{}{"host_time": host.Format(time.RFC3339Nano)})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, res["source"], test.ShouldEqual, ClockSourceFix)
+	test.That(t, res["samples"], test.ShouldEqual, 60)
+	_, err = c.ClockResult(map[string]interface{}{"host_time": "noon"})
+	test.That(t, err, test.ShouldNotBeNil)
+
+	// a step of the host clock starts over
+	c.Update(rmcAt("120100.00", "160326"), host.Add(-time.Minute))
+	est, _ = c.Estimate(host)
+	test.That(t, est.Samples, test.ShouldEqual, 1)
+
+	// fixes without a position don't count
+	c.Update("$GNRMC,120101.00,V,,,,,,,160326,,,N*00", host.Add(-time.Minute+time.Second))
+	est, _ = c.Estimate(host)
+	test.That(t, est.Samples, test.ShouldEqual, 1)
+}
+
+func TestClockEstimatorMidnight(t *testing.T) {
+	c := NewClockEstimator(nil)
+	start := time.Now()
+	c.Update(rmcAt("235959.00", "160326"), start)
+	// the GGA of the new day comes before its RMC
+	c.Update(ggaAt("000000.00"), start.Add(time.Second))
+	est, ok := c.Estimate(start.Add(time.Second))
+	test.That(t, ok, test.ShouldBeTrue)
+	test.That(t, est.Samples, test.ShouldEqual, 2)
+	test.That(t, est.Offset, test.ShouldAlmostEqual,
+		time.Date(2026, 3, 17, 0, 0, 0, 0, time.UTC).Sub(start.Add(time.Second)), float64(time.Microsecond))
+}
+
+func TestClockEstimatorPPS(t *testing.T) {
+	c := NewClockEstimator(nil)
+	start := time.Now()
+	gpsStart := time.Date(2026, 3, 16, 12, 0, 0, 0, time.UTC)
+	// pulses before any fix are ignored
+	c.Pulse(start)
+	_, ok := c.Estimate(start)
+	test.That(t, ok, test.ShouldBeFalse)
+
+	// each pulse marks the second after the fix read before it
+	for i := 0; i < 10; i++ {
+		edge := start.Add(time.Duration(i) * time.Second)
+		c.Pulse(edge)
+		c.Update(rmcAt(gpsStart.Add(time.Duration(i)*time.Second).Format("150405.00"), "160326"), edge.Add(300*time.Millisecond))
+	}
+	est, ok := c.Estimate(start)
+	test.That(t, ok, test.ShouldBeTrue)
+	test.That(t, est.Source, test.ShouldEqual, ClockSourcePPS)
+	test.That(t, est.Samples, test.ShouldEqual, 9)
+	test.That(t, est.Offset, test.ShouldAlmostEqual, gpsStart.Sub(start), float64(time.Microsecond))
+}
+
+// fakeInterrupt is a digital interrupt passing the channels of its callbacks to callbacks.
+type fakeInterrupt struct {
+	board.DigitalInterrupt
+	callbacks chan chan board.Tick
+}
+
+func (di *fakeInterrupt) AddCallback(c chan board.Tick) {
+	di.callbacks <- c
+}
+
+func (di *fakeInterrupt) RemoveCallback(c chan board.Tick) {}
+
+func TestWatchPPS(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	ticks := make(chan chan board.Tick, 1)
+	b := &fakeBoard{interrupts: map[string]board.DigitalInterrupt{"pps": &fakeInterrupt{callbacks: ticks}}}
+
+	c := NewClockEstimator(nil)
+	test.That(t, WatchPPS(ctx, b, "gpio", c), test.ShouldBeError, errors.New("no digital interrupt \"gpio\" on the pps board"))
+
+	done := make(chan error)
+	go func() { done <- WatchPPS(ctx, b, "pps", c) }()
+	callback := <-ticks
+	c.Update(rmcAt("120000.00", "160326"), time.Now())
+	callback <- board.Tick{High: false}
+	callback <- board.Tick{High: true}
+	pulsed := func() bool {
+		est, ok := c.Estimate(time.Now())
+		return ok && est.Source == ClockSourcePPS
+	}
+	test.That(t, WaitFor(ctx, time.Second, "pulse", pulsed), test.ShouldBeNil)
+
+	cancel()
+	test.That(t, <-done, test.ShouldBeNil)
+}
@@ -0,0 +1,184 @@
+package rtkutils
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/d2r2/go-i2c"
+	"github.com/go-gnss/rtcm/rtcm3"
+)
+
+const (
+	// CommandKey names the command to run in a DoCommand request.
+	CommandKey = "command"
+	// SelfTestCommand checks the wiring and configuration of a component.
+	SelfTestCommand = "self_test"
+	// ProvisionCommand configures a factory fresh receiver for its role in the rtk system.
+	ProvisionCommand = "provision"
+
+	defaultSelfTestTimeout = 5 * time.Second
+)
+
+// Counter is a count shared between a background worker and its readers.
+type Counter struct {
+	mu    sync.Mutex
+	count uint64
+	last  time.Time
+}
+
+// Add increments the counter by n.
+func (c *Counter) Add(n uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.count += n
+	c.last = time.Now()
+}
+
+// Get returns the current count.
+func (c *Counter) Get() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.count
+}
+
+// Last returns when the counter was last added to, or the zero time if it never was.
+func (c *Counter) Last() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.last
+}
+
+// Report collects the result of every check or step run by a command.
+type Report struct {
+	checks []interface{}
+	passed bool
+}
+
+// NewReport returns an empty report that passes until a check fails.
+func NewReport() *Report {
+	return &Report{passed: true}
+}
+
+// Check records the outcome of the named check; a nil err means it passed.
+func (r *Report) Check(name string, err error) {
+	check := map[string]interface{}{"name": name, "passed": err == nil}
+	if err != nil {
+		check["error"] = err.Error()
+		r.passed = false
+	}
+	r.checks = append(r.checks, check)
+}
+
+// Passed reports whether every check so far passed.
+func (r *Report) Passed() bool {
+	return r.passed
+}
+
+// Result returns the report as a DoCommand response.
+func (r *Report) Result() map[string]interface{} {
+	return map[string]interface{}{"passed": r.passed, "checks": r.checks}
+}
+
+// SelfTestTimeout returns the "timeout_sec" argument of a self test command, or the default.
+func SelfTestTimeout(cmd map[string]interface{}) time.Duration {
+	return DurationArg(cmd, "timeout_sec", defaultSelfTestTimeout)
+}
+
+// DurationArg returns the command argument key, given in seconds, or def if it is not set.
+func DurationArg(cmd map[string]interface{}, key string, def time.Duration) time.Duration {
+	if sec, ok := cmd[key].(float64); ok && sec > 0 {
+		return time.Duration(sec * float64(time.Second))
+	}
+	return def
+}
+
+// IntArg returns the integer command argument key, or def if it is not set.
+func IntArg(cmd map[string]interface{}, key string, def int) int {
+	if v, ok := cmd[key].(float64); ok {
+		return int(v)
+	}
+	return def
+}
+
+// WaitForIncrease waits for get to return more than it did when called, polling until timeout.
+func WaitForIncrease(ctx context.Context, timeout time.Duration, what string, get func() uint64) error {
+	start := get()
+	return WaitFor(ctx, timeout, what, func() bool { return get() > start })
+}
+
+// WaitFor polls cond until it is true, returning an error naming what was expected after timeout.
+func WaitFor(ctx context.Context, timeout time.Duration, what string, cond func() bool) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("no %s within %s", what, timeout)
+		case <-ticker.C:
+			if cond() {
+				return nil
+			}
+		}
+	}
+}
+
+// CountRTCMFrames returns the number of complete RTCM3 frames in buf with a valid CRC.
+func CountRTCMFrames(buf []byte) int {
+	frames, _ := SplitRTCMFrames(buf)
+	return len(frames)
+}
+
+// SplitRTCMFrames returns the complete RTCM3 frames in buf with a valid CRC, skipping everything else.
+// rest is the tail of buf that may hold the start of a frame cut off by the end of the read.
+func SplitRTCMFrames(buf []byte) (frames [][]byte, rest []byte) {
+	partial := len(buf)
+	for i := 0; i < len(buf); {
+		if buf[i] != rtcm3.FramePreamble {
+			i++
+			continue
+		}
+		end := len(buf) + 1
+		if i+3 <= len(buf) {
+			length := (int(buf[i+1])&0x03)<<8 | int(buf[i+2])
+			end = i + 3 + length + 3
+		}
+		if end > len(buf) {
+			if i < partial {
+				partial = i
+			}
+			i++
+			continue
+		}
+		crc := uint32(buf[end-3])<<16 | uint32(buf[end-2])<<8 | uint32(buf[end-1])
+		if rtcm3.Crc24q(buf[i:end-3]) != crc {
+			i++
+			continue
+		}
+		frames = append(frames, buf[i:end])
+		partial = len(buf)
+		i = end
+	}
+	return frames, buf[partial:]
+}
+
+// CheckI2CAck reports whether a device acknowledges reads at addr on bus.
+func CheckI2CAck(addr byte, bus int) error {
+	handle, err := i2c.NewI2C(addr, bus)
+	if err != nil {
+		return fmt.Errorf("failed to open i2c bus %d: %w", bus, err)
+	}
+	buf := make([]byte, 1)
+	_, readErr := handle.ReadBytes(buf)
+	if err := handle.Close(); err != nil {
+		return err
+	}
+	if readErr != nil {
+		return fmt.Errorf("no ack from i2c address %#x on bus %d: %w", addr, bus, readErr)
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package rtkutils
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-gnss/rtcm/rtcm3"
+	"go.viam.com/test"
+)
+
+func TestCountRTCMFrames(t *testing.T) {
+	frame := rtcm3.EncapsulateByteArray([]byte{0x3e, 0xd0, 0x01, 0x02, 0x03}).Serialize()
+
+	// padding, a stray preamble, two whole frames and a partial one
+	buf := append([]byte{0xff, rtcm3.FramePreamble, 0x00}, frame...)
+	buf = append(buf, frame...)
+	buf = append(buf, frame[:4]...)
+	test.That(t, CountRTCMFrames(buf), test.ShouldEqual, 2)
+
+	frames, rest := SplitRTCMFrames(buf)
+	test.That(t, frames, test.ShouldResemble, [][]byte{frame, frame})
+	test.That(t, rest, test.ShouldResemble, frame[:4])
+
+	// the cut off frame is complete once the rest of it is read
+	frames, rest = SplitRTCMFrames(append(rest, frame[4:]...))
+	test.That(t, frames, test.ShouldResemble, [][]byte{frame})
+	test.That(t, rest, test.ShouldBeEmpty)
+
+	corrupted := append([]byte{}, frame...)
+	corrupted[4] ^= 0x01
+	test.That(t, CountRTCMFrames(corrupted), test.ShouldEqual, 0)
+}
+
+func BenchmarkSplitRTCMFrames(b *testing.B) {
+	frame := rtcm3.EncapsulateByteArray(make([]byte, 600)).Serialize()
+	buf := append(bytes.Repeat(frame, 10), frame[:100]...)
+	b.ReportAllocs()
+	b.SetBytes(int64(len(buf)))
+	for i := 0; i < b.N; i++ {
+		SplitRTCMFrames(buf)
+	}
+}
+
+func TestReport(t *testing.T) {
+	report := NewReport()
+	report.Check("ok", nil)
+	test.That(t, report.Result()["passed"], test.ShouldBeTrue)
+
+	report.Check("broken", errors.New("no ack"))
+	result := report.Result()
+	test.That(t, result["passed"], test.ShouldBeFalse)
+	test.That(t, result["checks"], test.ShouldHaveLength, 2)
+	test.That(t, result["checks"].([]interface{})[1], test.ShouldResemble,
+		map[string]interface{}{"name": "broken", "passed": false, "error": "no ack"})
+}
+
+func TestWaitForIncrease(t *testing.T) {
+	var counter Counter
+	ctx := context.Background()
+
+	test.That(t, WaitForIncrease(ctx, 150*time.Millisecond, "frames", counter.Get), test.ShouldNotBeNil)
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		counter.Add(1)
+	}()
+	test.That(t, WaitForIncrease(ctx, time.Second, "frames", counter.Get), test.ShouldBeNil)
+	test.That(t, SelfTestTimeout(map[string]interface{}{"timeout_sec": 2.0}), test.ShouldEqual, 2*time.Second)
+}
+
+func TestUBXPortConfigPacket(t *testing.T) {
+	packet := UBXPortConfigPacket(UBXPortUART2, 38400, UBXProtoUBX|UBXProtoRTCM3, UBXProtoUBX)
+	test.That(t, packet, test.ShouldResemble, []byte{
+		0xB5, 0x62, 0x06, 0x00, 0x14, 0x00,
+		0x02, 0x00, 0x00, 0x00, 0xD0, 0x08, 0x00, 0x00, 0x00, 0x96, 0x00, 0x00,
+		0x21, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0xAC, 0x68,
+	})
+
+	port, err := UBXPortArg(map[string]interface{}{"port": "USB"}, "port", UBXPortUART2)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, port, test.ShouldEqual, UBXPortUSB)
+
+	_, err = UBXPortArg(map[string]interface{}{"port": "uart3"}, "port", UBXPortUART2)
+	test.That(t, err, test.ShouldNotBeNil)
+}
+
+func TestValidateRTCMOutputPort(t *testing.T) {
+	port, err := UBXPort("", UBXPortI2C)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, port, test.ShouldEqual, UBXPortI2C)
+
+	test.That(t, ValidateRTCMOutputPort("input.rtcm_output_port", "", ReceiverUM980), test.ShouldBeNil)
+	test.That(t, ValidateRTCMOutputPort("input.rtcm_output_port", "UART1", ""), test.ShouldBeNil)
+	test.That(t, ValidateRTCMOutputPort("input.rtcm_output_port", "uart3", ""), test.ShouldBeError,
+		errors.New(`input.rtcm_output_port: unknown receiver port "uart3", must be one of i2c, uart1, uart2 or usb`))
+	test.That(t, ValidateRTCMOutputPort("input.rtcm_output_port", "uart1", ReceiverPX1122R), test.ShouldBeError,
+		errors.New(`input.rtcm_output_port is only supported on u-blox receivers, "px1122r" outputs rtcm on the port it is read from`))
+}
@@ -0,0 +1,131 @@
+package rtkutils
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// CheckConfigCommand checks a proposed config against the connected hardware without applying it, so
+// that a config edited remotely can be tried before it restarts the component. The attributes of the
+// proposed config are its "config" argument.
+const CheckConfigCommand = "check_config"
+
+// maxListenBytes is the most read from a port that is listened to.
+const maxListenBytes = 64 << 10
+
+// Vendors of receivers, as recognized from their proprietary messages.
+const (
+	vendorUBlox   = "u-blox"
+	vendorQuectel = "quectel"
+)
+
+var receiverVendors = map[string]string{
+	ReceiverZEDF9P: vendorUBlox,
+	ReceiverZEDF9R: vendorUBlox,
+	ReceiverNEOM8P: vendorUBlox,
+	ReceiverNEOM8T: vendorUBlox,
+	ReceiverLC29H:  vendorQuectel,
+	ReceiverLG69T:  vendorQuectel,
+}
+
+// ConfigArg returns the attributes of the proposed config of a CheckConfigCommand.
+func ConfigArg(cmd map[string]interface{}) (map[string]interface{}, error) {
+	attrs, ok := cmd["config"].(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%s needs the attributes of the proposed config as \"config\"", CheckConfigCommand)
+	}
+	return attrs, nil
+}
+
+// PortOutput is what a port sent while it was listened to.
+type PortOutput struct {
+	Sentences int    // nmea sentences
+	UBX       int    // ubx messages
+	RTCM      int    // rtcm frames with a valid crc
+	Vendor    string // the vendor of the receiver, if it sent proprietary messages
+}
+
+// ListenToPort reads port until timeout or ctx is done and closes it, returning what it sent. The port
+// is closed to stop a blocked read, so it must not be used by anything else.
+func ListenToPort(ctx context.Context, port io.ReadCloser, timeout time.Duration) (PortOutput, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var closeOnce sync.Once
+	var closeErr error
+	closePort := func() { closeOnce.Do(func() { closeErr = port.Close() }) }
+	go func() {
+		<-ctx.Done()
+		closePort()
+	}()
+
+	var buf []byte
+	chunk := make([]byte, DefaultReadBufferSize)
+	var readErr error
+	for len(buf) < maxListenBytes {
+		n, err := port.Read(chunk)
+		buf = append(buf, chunk[:n]...)
+		if err != nil {
+			// a read cut off by the timeout closing the port is how listening ends
+			if ctx.Err() == nil && !errors.Is(err, io.EOF) {
+				readErr = err
+			}
+			break
+		}
+	}
+	cancel()
+	closePort()
+	if readErr != nil {
+		return PortOutput{}, readErr
+	}
+	return ParsePortOutput(buf), closeErr
+}
+
+// ParsePortOutput returns what the bytes read from a port hold.
+func ParsePortOutput(buf []byte) PortOutput {
+	var output PortOutput
+	for i := 1; i < len(buf); i++ {
+		switch {
+		case buf[i-1] == '$' && (buf[i] == 'G' || buf[i] == 'B' || buf[i] == 'P'):
+			output.Sentences++
+		case buf[i-1] == ubxSync1 && buf[i] == ubxSync2:
+			output.UBX++
+		}
+	}
+	output.RTCM = CountRTCMFrames(buf)
+	switch {
+	case output.UBX > 0 || bytes.Contains(buf, []byte("$PUBX")):
+		output.Vendor = vendorUBlox
+	case bytes.Contains(buf, []byte("$PQTM")) || bytes.Contains(buf, []byte("$PAIR")):
+		output.Vendor = vendorQuectel
+	}
+	return output
+}
+
+// MatchReceiver checks that the port was sent from by the named receiver, as far as its messages
+// tell. Receivers that only sent standard nmea or rtcm match any receiver.
+func (o PortOutput) MatchReceiver(receiver string) error {
+	if receiver == "" {
+		receiver = ReceiverZEDF9P
+	}
+	vendor, ok := receiverVendors[receiver]
+	if o.Vendor == "" || !ok || o.Vendor == vendor {
+		return nil
+	}
+	return fmt.Errorf("the receiver sends %s messages, it isn't a %s", o.Vendor, receiver)
+}
+
+// Result returns the output as part of a CheckConfigCommand response.
+func (o PortOutput) Result() map[string]interface{} {
+	return map[string]interface{}{
+		"nmea_sentences": o.Sentences,
+		"ubx_messages":   o.UBX,
+		"rtcm_frames":    o.RTCM,
+		"vendor":         o.Vendor,
+	}
+}
@@ -0,0 +1,60 @@
+package rtkutils
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/go-gnss/rtcm/rtcm3"
+	"go.viam.com/test"
+)
+
+func TestConfigArg(t *testing.T) {
+	attrs, err := ConfigArg(map[string]interface{}{"config": map[string]interface{}{"skip_device_check": true}})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, attrs["skip_device_check"], test.ShouldEqual, true)
+
+	_, err = ConfigArg(map[string]interface{}{})
+	test.That(t, err, test.ShouldBeError, errors.New(`check_config needs the attributes of the proposed config as "config"`))
+}
+
+func TestParsePortOutput(t *testing.T) {
+	frame := rtcm3.EncapsulateByteArray([]byte{0x3e, 0xd0, 0x01, 0x02, 0x03}).Serialize()
+	var buf []byte
+	buf = append(buf, testGGAEpoch1+"\r\n"...)
+	buf = append(buf, frame...)
+	buf = append(buf, testGGAEpoch2+"\r\n"...)
+	output := ParsePortOutput(buf)
+	test.That(t, output, test.ShouldResemble, PortOutput{Sentences: 2, RTCM: 1})
+	// standard messages match any receiver
+	test.That(t, output.MatchReceiver(ReceiverLC29H), test.ShouldBeNil)
+
+	output = ParsePortOutput(append(buf, UBXSaveConfigPacket()...))
+	test.That(t, output.UBX, test.ShouldEqual, 1)
+	test.That(t, output.Vendor, test.ShouldEqual, "u-blox")
+	test.That(t, output.MatchReceiver(""), test.ShouldBeNil)
+	test.That(t, output.MatchReceiver(ReceiverLG69T), test.ShouldBeError,
+		errors.New("the receiver sends u-blox messages, it isn't a lg69t"))
+
+	output = ParsePortOutput([]byte("$PQTMEPE,2,1.000,1.000,2.000,1.414,2.449*5E\r\n"))
+	test.That(t, output.Vendor, test.ShouldEqual, "quectel")
+	test.That(t, output.MatchReceiver(ReceiverZEDF9P), test.ShouldNotBeNil)
+}
+
+func TestListenToPort(t *testing.T) {
+	r, w := io.Pipe()
+	go func() {
+		w.Write([]byte(testGGAEpoch1 + "\r\n"))
+	}()
+	start := time.Now()
+	output, err := ListenToPort(context.Background(), r, 100*time.Millisecond)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, output.Sentences, test.ShouldEqual, 1)
+	test.That(t, time.Since(start), test.ShouldBeGreaterThanOrEqualTo, 100*time.Millisecond)
+
+	// the port is closed once listened to
+	_, err = w.Write([]byte("$"))
+	test.That(t, err, test.ShouldEqual, io.ErrClosedPipe)
+}
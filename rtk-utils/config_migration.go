@@ -0,0 +1,94 @@
+package rtkutils
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// protocolKeys are the keys old configs used to pick how a receiver is connected. The protocol is
+// now implied by the model.
+var protocolKeys = []string{"protocol", "correction_source", "connection_type"}
+
+// ConfigMigration maps the attributes of old or misspelled configs to the current keys of a model.
+type ConfigMigration struct {
+	// Protocol is how the model's receiver is connected, "serial" or "i2c". Old configs naming
+	// another protocol are rejected, since they need another model.
+	Protocol string
+	// Nested are keys of old configs holding objects whose keys are now set at the top level, e.g.
+	// serial_attributes.
+	Nested []string
+	// Aliases maps deprecated or misspelled keys to the current ones.
+	Aliases map[string]string
+	// Dropped are keys of old configs that aren't needed anymore.
+	Dropped []string
+}
+
+// Migrate returns a copy of attributes with the keys of old configs replaced by the current ones,
+// and a warning for each replaced key so that users can update their configs. Keys that are set
+// under both their old and current name keep the current value.
+func (m ConfigMigration) Migrate(attributes map[string]interface{}) (map[string]interface{}, []string, error) {
+	migrated := make(map[string]interface{}, len(attributes))
+	for key, value := range attributes {
+		migrated[key] = value
+	}
+	var warnings []string
+
+	for _, key := range protocolKeys {
+		value, ok := migrated[key]
+		if !ok {
+			continue
+		}
+		delete(migrated, key)
+		if protocol, _ := value.(string); !strings.EqualFold(protocol, m.Protocol) {
+			return nil, nil, fmt.Errorf("%s %q isn't supported by this model, which is for %s receivers", key, value, m.Protocol)
+		}
+		warnings = append(warnings, fmt.Sprintf("%s is deprecated and ignored, the protocol is set by the model", key))
+	}
+
+	for _, key := range m.Nested {
+		value, ok := migrated[key]
+		if !ok {
+			continue
+		}
+		delete(migrated, key)
+		nested, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, nil, fmt.Errorf("%s must be an object", key)
+		}
+		warnings = append(warnings, fmt.Sprintf("%s is deprecated, set its attributes at the top level instead", key))
+		for nestedKey, nestedValue := range nested {
+			if _, ok := migrated[nestedKey]; !ok {
+				migrated[nestedKey] = nestedValue
+			}
+		}
+	}
+
+	old := make([]string, 0, len(m.Aliases))
+	for key := range m.Aliases {
+		old = append(old, key)
+	}
+	sort.Strings(old)
+	for _, key := range old {
+		value, ok := migrated[key]
+		if !ok {
+			continue
+		}
+		delete(migrated, key)
+		current := m.Aliases[key]
+		if _, ok := migrated[current]; ok {
+			warnings = append(warnings, fmt.Sprintf("%s is deprecated and ignored since %s is set", key, current))
+			continue
+		}
+		migrated[current] = value
+		warnings = append(warnings, fmt.Sprintf("%s is deprecated, use %s instead", key, current))
+	}
+
+	for _, key := range m.Dropped {
+		if _, ok := migrated[key]; ok {
+			delete(migrated, key)
+			warnings = append(warnings, fmt.Sprintf("%s is deprecated and ignored", key))
+		}
+	}
+	return migrated, warnings, nil
+}
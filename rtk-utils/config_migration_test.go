@@ -0,0 +1,51 @@
+package rtkutils
+
+import (
+	"testing"
+
+	"go.viam.com/test"
+)
+
+func TestConfigMigration(t *testing.T) {
+	m := ConfigMigration{
+		Protocol: "serial",
+		Nested:   []string{"serial_attributes"},
+		Aliases:  map[string]string{"serial_path": "serial_nmea_path", "required_time": "required_time_sec"},
+		Dropped:  []string{"svin"},
+	}
+
+	// an old config with nested attributes
+	migrated, warnings, err := m.Migrate(map[string]interface{}{
+		"correction_source": "Serial",
+		"serial_attributes": map[string]interface{}{"serial_path": "/dev/ttyACM0", "serial_baud_rate": 38400.0},
+		"required_time":     200.0,
+		"svin":              "time",
+	})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, migrated, test.ShouldResemble, map[string]interface{}{
+		"serial_nmea_path":  "/dev/ttyACM0",
+		"serial_baud_rate":  38400.0,
+		"required_time_sec": 200.0,
+	})
+	test.That(t, warnings, test.ShouldHaveLength, 5)
+
+	// current keys win over old ones
+	attributes := map[string]interface{}{"serial_path": "/dev/ttyACM0", "serial_nmea_path": "/dev/ttyUSB0"}
+	migrated, warnings, err = m.Migrate(attributes)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, migrated, test.ShouldResemble, map[string]interface{}{"serial_nmea_path": "/dev/ttyUSB0"})
+	test.That(t, warnings, test.ShouldResemble, []string{"serial_path is deprecated and ignored since serial_nmea_path is set"})
+	test.That(t, attributes, test.ShouldHaveLength, 2)
+
+	// configs for another model are rejected
+	_, _, err = m.Migrate(map[string]interface{}{"protocol": "i2c"})
+	test.That(t, err, test.ShouldNotBeNil)
+	_, _, err = m.Migrate(map[string]interface{}{"serial_attributes": "/dev/ttyACM0"})
+	test.That(t, err, test.ShouldNotBeNil)
+
+	// current configs are left alone
+	migrated, warnings, err = m.Migrate(map[string]interface{}{"serial_nmea_path": "/dev/ttyUSB0"})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, migrated, test.ShouldResemble, map[string]interface{}{"serial_nmea_path": "/dev/ttyUSB0"})
+	test.That(t, warnings, test.ShouldBeEmpty)
+}
@@ -0,0 +1,169 @@
+package rtkutils
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// EventReceiverConfigReset is raised when a receiver is found to have lost its configuration, and
+// it is configured again.
+const EventReceiverConfigReset = "receiver_config_reset"
+
+const (
+	// how long after a receiver is set up its output is ignored, while it applies the configuration
+	configSettleTime = 2 * time.Second
+	// how long after that the sentences and epoch rate of a receiver are learned
+	configLearnTime = 30 * time.Second
+	// the times a sentence type the receiver didn't send while it was learned has to be read again
+	// to be taken for a reset, so that a stray sentence isn't
+	configResetSentences = 3
+	// the epochs in a row that have to be this much slower than learned to be taken for a reset
+	configResetSlowEpochs = 5
+	configResetSlowdown   = 1.5
+	// the epoch intervals kept while learning
+	maxConfigLearnIntervals = 1000
+)
+
+// configBootSentences are sentence types receivers send by themselves, such as the banner a u-blox
+// receiver sends when it boots, rather than because they were configured to.
+var configBootSentences = map[string]bool{"TXT": true}
+
+// ConfigWatch recognizes a receiver that reverted to its default configuration, as brownouts and
+// resets do to a receiver that only keeps it in volatile memory, so that it is configured again.
+// Once the receiver is set up it learns the sentence types the receiver sends and the rate of its
+// epochs. A reset is taken to have happened when sentence types the receiver didn't send come back,
+// or its epochs slow down, such as to the default 1 Hz. The zero value is ready to use and safe for
+// concurrent use, and watches nothing until the receiver is set up.
+type ConfigWatch struct {
+	mu        sync.Mutex
+	setUpAt   time.Time
+	learned   map[string]bool
+	unlearned map[string]int // sentence types read since learning, by count
+	intervals []time.Duration
+	interval  time.Duration // learned between epochs, 0 while learning
+	lastEpoch time.Time
+	slow      int  // epochs in a row slower than learned
+	reset     bool // a reset was found since the receiver was last set up
+	resets    Counter
+}
+
+// SetUp notes that the receiver was set up at now, which starts learning its output afresh.
+func (w *ConfigWatch) SetUp(now time.Time) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.setUpAt, w.learned, w.unlearned = now, map[string]bool{}, map[string]int{}
+	w.intervals, w.interval, w.lastEpoch, w.slow, w.reset = nil, 0, time.Time{}, 0, false
+}
+
+// learning returns whether the output read at now is learned, and whether it is watched at all.
+// It must be called with mu held.
+func (w *ConfigWatch) learning(now time.Time) (learning, watching bool) {
+	if w.setUpAt.IsZero() || w.reset || now.Sub(w.setUpAt) < configSettleTime {
+		return false, false
+	}
+	return now.Sub(w.setUpAt) < configSettleTime+configLearnTime, true
+}
+
+// Sentence takes a sentence read from the receiver at now, adding an event to events and returning
+// true when it shows the receiver lost its configuration. Sentences with a bad checksum are ignored.
+func (w *ConfigWatch) Sentence(line string, now time.Time, events *EventLog) bool {
+	start := strings.IndexByte(line, '$')
+	if start == -1 {
+		return false
+	}
+	body, checksum, ok := strings.Cut(strings.TrimSpace(line[start+1:]), "*")
+	if !ok || !validNMEAChecksum(body, checksum) {
+		return false
+	}
+	key, _, typ := sentenceKey(line)
+	if configBootSentences[typ] {
+		return false
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	learning, watching := w.learning(now)
+	switch {
+	case !watching:
+		return false
+	case learning:
+		w.learned[key] = true
+		return false
+	case w.learned[key]:
+		return false
+	}
+	w.unlearned[key]++
+	if w.unlearned[key] < configResetSentences {
+		return false
+	}
+	var appeared []string
+	for key, count := range w.unlearned {
+		if count >= configResetSentences {
+			appeared = append(appeared, key)
+		}
+	}
+	sort.Strings(appeared)
+	w.found(events, "the receiver sends %s sentences it was configured not to", strings.Join(appeared, ", "))
+	return true
+}
+
+// Epoch takes an epoch the receiver sent at now, adding an event to events and returning true when
+// the epochs show the receiver lost its configuration.
+func (w *ConfigWatch) Epoch(now time.Time, events *EventLog) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	learning, watching := w.learning(now)
+	if !watching {
+		return false
+	}
+	last := w.lastEpoch
+	w.lastEpoch = now
+	if last.IsZero() {
+		return false
+	}
+	interval := now.Sub(last)
+	if learning {
+		if len(w.intervals) < maxConfigLearnIntervals {
+			w.intervals = append(w.intervals, interval)
+		}
+		return false
+	}
+	if w.interval == 0 {
+		if len(w.intervals) == 0 {
+			return false
+		}
+		// the median, reads can bunch epochs up or hold them back
+		sort.Slice(w.intervals, func(i, j int) bool { return w.intervals[i] < w.intervals[j] })
+		w.interval = w.intervals[len(w.intervals)/2]
+	}
+	if float64(interval) < float64(w.interval)*configResetSlowdown {
+		w.slow = 0
+		return false
+	}
+	w.slow++
+	if w.slow < configResetSlowEpochs {
+		return false
+	}
+	w.found(events, "the receiver sends epochs every %s instead of every %s",
+		interval.Round(time.Millisecond), w.interval.Round(time.Millisecond))
+	return true
+}
+
+// found records a reset until the receiver is set up again. It must be called with mu held.
+func (w *ConfigWatch) found(events *EventLog, format string, args ...interface{}) {
+	w.reset = true
+	w.resets.Add(1)
+	events.Add(EventReceiverConfigReset, format+", configuring it again", args...)
+}
+
+// Resets returns the number of times the receiver was found to have lost its configuration.
+func (w *ConfigWatch) Resets() uint64 {
+	return w.resets.Get()
+}
+
+// Readings returns the number of times the receiver lost its configuration.
+func (w *ConfigWatch) Readings() map[string]interface{} {
+	return map[string]interface{}{"receiver_config_resets": w.Resets()}
+}
@@ -0,0 +1,84 @@
+package rtkutils
+
+import (
+	"testing"
+	"time"
+
+	"github.com/edaniels/golog"
+	"go.viam.com/test"
+)
+
+func configSentence(t *testing.T, body string) string {
+	t.Helper()
+	sentence, err := NMEASentence(body)
+	test.That(t, err, test.ShouldBeNil)
+	return string(sentence)
+}
+
+func TestConfigWatchSentences(t *testing.T) {
+	events := NewEventLog(golog.NewTestLogger(t))
+	gga := configSentence(t, "GNGGA,120000.00,4000.0,N,10500.0,W,4,12,0.8,1600.0,M,-20.0,M,1.0,0000")
+	gsv := configSentence(t, "GPGSV,1,1,01,05,45,120,40")
+	banner := configSentence(t, "GNTXT,01,01,02,u-blox AG - www.u-blox.com")
+
+	var w ConfigWatch
+	setUp := time.Now()
+	// nothing is watched until the receiver is set up
+	for i := 0; i < 5; i++ {
+		test.That(t, w.Sentence(gsv, setUp, events), test.ShouldBeFalse)
+	}
+
+	w.SetUp(setUp)
+	// sentences still coming while the receiver applies its configuration aren't learned
+	test.That(t, w.Sentence(gsv, setUp.Add(time.Second), events), test.ShouldBeFalse)
+	test.That(t, w.Sentence(gga, setUp.Add(5*time.Second), events), test.ShouldBeFalse)
+
+	watched := setUp.Add(configSettleTime + configLearnTime + time.Second)
+	test.That(t, w.Sentence(gga, watched, events), test.ShouldBeFalse)
+	// boot banners and bad checksums aren't configured output
+	for i := 0; i < 5; i++ {
+		test.That(t, w.Sentence(banner, watched, events), test.ShouldBeFalse)
+		test.That(t, w.Sentence("$GPGSV,1,1,01,05,45,120,40*00", watched, events), test.ShouldBeFalse)
+	}
+	test.That(t, w.Sentence(gsv, watched, events), test.ShouldBeFalse)
+	test.That(t, w.Sentence(gsv, watched, events), test.ShouldBeFalse)
+	test.That(t, w.Sentence(gsv, watched, events), test.ShouldBeTrue)
+	test.That(t, w.Resets(), test.ShouldEqual, 1)
+	test.That(t, len(events.Events(EventReceiverConfigReset)), test.ShouldEqual, 1)
+	test.That(t, events.Events(EventReceiverConfigReset)[0].Message, test.ShouldEqual,
+		"the receiver sends GPGSV sentences it was configured not to, configuring it again")
+
+	// a reset is reported once until the receiver is set up again
+	test.That(t, w.Sentence(gsv, watched, events), test.ShouldBeFalse)
+	w.SetUp(watched)
+	test.That(t, w.Sentence(gsv, watched.Add(5*time.Second), events), test.ShouldBeFalse)
+	test.That(t, w.Readings(), test.ShouldResemble, map[string]interface{}{"receiver_config_resets": uint64(1)})
+}
+
+func TestConfigWatchEpochs(t *testing.T) {
+	events := NewEventLog(golog.NewTestLogger(t))
+	var w ConfigWatch
+	setUp := time.Now()
+	w.SetUp(setUp)
+
+	now := setUp.Add(configSettleTime)
+	for now.Before(setUp.Add(configSettleTime + configLearnTime)) {
+		test.That(t, w.Epoch(now, events), test.ShouldBeFalse)
+		now = now.Add(200 * time.Millisecond)
+	}
+	// a single late epoch, such as after a dropped read, isn't a reset
+	now = now.Add(time.Second)
+	test.That(t, w.Epoch(now, events), test.ShouldBeFalse)
+	now = now.Add(200 * time.Millisecond)
+	test.That(t, w.Epoch(now, events), test.ShouldBeFalse)
+
+	for i := 1; i < configResetSlowEpochs; i++ {
+		now = now.Add(time.Second)
+		test.That(t, w.Epoch(now, events), test.ShouldBeFalse)
+	}
+	now = now.Add(time.Second)
+	test.That(t, w.Epoch(now, events), test.ShouldBeTrue)
+	test.That(t, w.Resets(), test.ShouldEqual, 1)
+	test.That(t, events.Events(EventReceiverConfigReset)[0].Message, test.ShouldEqual,
+		"the receiver sends epochs every 1s instead of every 200ms, configuring it again")
+}
@@ -0,0 +1,122 @@
+package rtkutils
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	geo "github.com/kellydunn/golang-geo"
+)
+
+// Policies of what a rover reports once its corrections are lost.
+const (
+	// the position the receiver computes without them, degrading to a float or standalone solution
+	CorrectionLossContinue = "continue"
+	// the last position from while corrections arrived, flagged as stale
+	CorrectionLossHold = "hold"
+	// an error
+	CorrectionLossError = "error"
+)
+
+// ErrCorrectionsLost is returned for the position of a rover whose corrections are lost, with the
+// error correction loss policy.
+var ErrCorrectionsLost = errors.New("corrections lost")
+
+// ValidateCorrectionLossPolicy checks the correction loss policy of a config, which only rovers with
+// a correction source have a use for.
+func ValidateCorrectionLossPolicy(policy string, hasSource bool) error {
+	switch policy {
+	case "":
+		return nil
+	case CorrectionLossContinue, CorrectionLossHold, CorrectionLossError:
+	default:
+		return fmt.Errorf("correction_loss_policy %q isn't supported, use %s, %s or %s",
+			policy, CorrectionLossContinue, CorrectionLossHold, CorrectionLossError)
+	}
+	if !hasSource {
+		return errors.New("correction_loss_policy needs a correction_source")
+	}
+	return nil
+}
+
+// CorrectionLoss applies a policy to the position a rover reports once its corrections are lost:
+// it received some, but none for maxAge. Until then the rover reports its position as usual, so
+// that autonomy stacks needing other failure semantics than a receiver silently degrading to a
+// float or standalone solution get them explicitly. A nil CorrectionLoss never loses corrections.
+type CorrectionLoss struct {
+	policy string
+	maxAge time.Duration
+
+	mu   sync.Mutex
+	held *geo.Point // last position from while corrections arrived
+	alt  float64
+}
+
+// NewCorrectionLoss returns the policy, continue if it is empty, for corrections older than maxAge.
+func NewCorrectionLoss(policy string, maxAge time.Duration) *CorrectionLoss {
+	if policy == "" {
+		policy = CorrectionLossContinue
+	}
+	return &CorrectionLoss{policy: policy, maxAge: maxAge}
+}
+
+// Lost returns whether the corrections, counted by corrections, are lost at now, and since when
+// the last one arrived.
+func (c *CorrectionLoss) Lost(corrections *Counter, now time.Time) (bool, time.Duration) {
+	if c == nil || corrections.Get() == 0 {
+		return false, 0
+	}
+	age := now.Sub(corrections.Last())
+	return age > c.maxAge, age
+}
+
+// Update takes the position of an epoch, which is held if corrections are lost later.
+func (c *CorrectionLoss) Update(pos *geo.Point, alt float64, corrections *Counter, now time.Time) {
+	if c == nil || c.policy != CorrectionLossHold || pos == nil || math.IsNaN(pos.Lat()) || math.IsNaN(pos.Lng()) {
+		return
+	}
+	if lost, _ := c.Lost(corrections, now); lost {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.held, c.alt = pos, alt
+}
+
+// Position returns the position to report instead of the receiver's while corrections are lost:
+// the held position and true with the hold policy, an error wrapping ErrCorrectionsLost with the
+// error policy. It returns false while the rover reports the receiver's position.
+func (c *CorrectionLoss) Position(corrections *Counter, now time.Time) (*geo.Point, float64, bool, error) {
+	lost, age := c.Lost(corrections, now)
+	if !lost {
+		return nil, 0, false, nil
+	}
+	switch c.policy {
+	case CorrectionLossError:
+		return nil, 0, false, fmt.Errorf("%w, the last one arrived %s ago", ErrCorrectionsLost, age.Round(time.Millisecond))
+	case CorrectionLossHold:
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if c.held != nil {
+			return c.held, c.alt, true, nil
+		}
+	}
+	return nil, 0, false, nil
+}
+
+// Readings returns whether the corrections are lost, and with the hold policy whether the position
+// reported is stale.
+func (c *CorrectionLoss) Readings(corrections *Counter, now time.Time) map[string]interface{} {
+	if c == nil {
+		return map[string]interface{}{}
+	}
+	lost, _ := c.Lost(corrections, now)
+	readings := map[string]interface{}{"corrections_lost": lost}
+	if c.policy == CorrectionLossHold {
+		_, _, held, _ := c.Position(corrections, now)
+		readings["position_stale"] = held
+	}
+	return readings
+}
@@ -0,0 +1,71 @@
+package rtkutils
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	geo "github.com/kellydunn/golang-geo"
+	"go.viam.com/test"
+)
+
+func TestValidateCorrectionLossPolicy(t *testing.T) {
+	test.That(t, ValidateCorrectionLossPolicy("", false), test.ShouldBeNil)
+	test.That(t, ValidateCorrectionLossPolicy(CorrectionLossHold, true), test.ShouldBeNil)
+	test.That(t, ValidateCorrectionLossPolicy("freeze", true), test.ShouldBeError,
+		errors.New(`correction_loss_policy "freeze" isn't supported, use continue, hold or error`))
+	test.That(t, ValidateCorrectionLossPolicy(CorrectionLossError, false), test.ShouldBeError,
+		errors.New("correction_loss_policy needs a correction_source"))
+}
+
+func TestCorrectionLoss(t *testing.T) {
+	var none *CorrectionLoss
+	var corrections Counter
+	corrections.Add(1)
+	_, _, held, err := none.Position(&corrections, time.Now().Add(time.Hour))
+	test.That(t, held, test.ShouldBeFalse)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, none.Readings(&corrections, time.Now()), test.ShouldBeEmpty)
+
+	pos := geo.NewPoint(37.4, -122.1)
+	later := time.Now().Add(11 * time.Second)
+
+	// corrections aren't lost before any arrived, nor while they arrive
+	var never Counter
+	for _, policy := range []string{"", CorrectionLossHold, CorrectionLossError} {
+		c := NewCorrectionLoss(policy, 10*time.Second)
+		c.Update(pos, 12, &corrections, time.Now())
+		_, _, held, err := c.Position(&never, later)
+		test.That(t, held, test.ShouldBeFalse)
+		test.That(t, err, test.ShouldBeNil)
+		_, _, held, err = c.Position(&corrections, time.Now())
+		test.That(t, held, test.ShouldBeFalse)
+		test.That(t, err, test.ShouldBeNil)
+	}
+
+	c := NewCorrectionLoss("", 10*time.Second)
+	c.Update(pos, 12, &corrections, time.Now())
+	_, _, held, err = c.Position(&corrections, later)
+	test.That(t, held, test.ShouldBeFalse)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, c.Readings(&corrections, later), test.ShouldResemble, map[string]interface{}{"corrections_lost": true})
+
+	c = NewCorrectionLoss(CorrectionLossError, 10*time.Second)
+	_, _, _, err = c.Position(&corrections, later)
+	test.That(t, errors.Is(err, ErrCorrectionsLost), test.ShouldBeTrue)
+	test.That(t, err.Error(), test.ShouldStartWith, "corrections lost, the last one arrived 11")
+
+	// the hold policy holds the last position from before the loss
+	c = NewCorrectionLoss(CorrectionLossHold, 10*time.Second)
+	test.That(t, c.Readings(&corrections, time.Now()), test.ShouldResemble,
+		map[string]interface{}{"corrections_lost": false, "position_stale": false})
+	c.Update(pos, 12, &corrections, time.Now())
+	c.Update(geo.NewPoint(37.5, -122.1), 13, &corrections, later)
+	got, alt, held, err := c.Position(&corrections, later)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, held, test.ShouldBeTrue)
+	test.That(t, got, test.ShouldResemble, pos)
+	test.That(t, alt, test.ShouldEqual, 12)
+	test.That(t, c.Readings(&corrections, later), test.ShouldResemble,
+		map[string]interface{}{"corrections_lost": true, "position_stale": true})
+}
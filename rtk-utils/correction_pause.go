@@ -0,0 +1,102 @@
+package rtkutils
+
+import (
+	"sync"
+	"time"
+)
+
+// Commands pausing and resuming the corrections a rover forwards to its receiver. PauseCorrectionsCommand
+// pauses them until ResumeCorrectionsCommand, or for "duration_sec" if it is given.
+const (
+	PauseCorrectionsCommand  = "pause_corrections"
+	ResumeCorrectionsCommand = "resume_corrections"
+)
+
+// Correction pause event types.
+const (
+	EventCorrectionsPaused  = "corrections_paused"
+	EventCorrectionsResumed = "corrections_resumed"
+)
+
+// CorrectionPause stops a rover forwarding corrections to its receiver for a while, to compare its
+// solution with and without rtk while troubleshooting, without editing its config. Corrections are
+// still read, so that decode_rtcm shows what arrives, and dropped before the receiver. The zero value
+// forwards every correction and is safe for concurrent use.
+type CorrectionPause struct {
+	mu      sync.Mutex
+	paused  bool
+	since   time.Time
+	until   time.Time // zero until resumed
+	dropped uint64    // frames dropped since paused
+}
+
+// Pause stops forwarding corrections from now, for duration or until resumed if it is 0. Pausing a
+// paused rover restarts its duration.
+func (p *CorrectionPause) Pause(duration time.Duration, now time.Time, events *EventLog) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.paused {
+		p.paused, p.since, p.dropped = true, now, 0
+	}
+	p.until = time.Time{}
+	if duration > 0 {
+		p.until = now.Add(duration)
+		events.Add(EventCorrectionsPaused, "corrections paused for %s", duration)
+		return
+	}
+	events.Add(EventCorrectionsPaused, "corrections paused until resumed")
+}
+
+// Resume forwards corrections again from now.
+func (p *CorrectionPause) Resume(now time.Time, events *EventLog) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.resume(now, events)
+}
+
+// resume must be called with mu held.
+func (p *CorrectionPause) resume(now time.Time, events *EventLog) {
+	if !p.paused {
+		return
+	}
+	events.Add(EventCorrectionsResumed, "corrections resumed after %s, %d frames dropped",
+		now.Sub(p.since).Round(time.Second), p.dropped)
+	p.paused, p.since, p.until = false, time.Time{}, time.Time{}
+}
+
+// Forward returns whether a correction read at now is forwarded to the receiver, resuming once the
+// duration of the pause is over.
+func (p *CorrectionPause) Forward(now time.Time, events *EventLog) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.paused && !p.until.IsZero() && !now.Before(p.until) {
+		p.resume(now, events)
+	}
+	if p.paused {
+		p.dropped++
+		return false
+	}
+	return true
+}
+
+// Result answers a PauseCorrectionsCommand or a ResumeCorrectionsCommand with whether corrections
+// are paused at now and, if they are, since when, until when and how many were dropped.
+func (p *CorrectionPause) Result(now time.Time) map[string]interface{} {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	paused := p.paused && (p.until.IsZero() || now.Before(p.until))
+	result := map[string]interface{}{"paused": paused}
+	if paused {
+		result["paused_at"] = p.since.UTC().Format(time.RFC3339Nano)
+		result["frames_dropped"] = p.dropped
+		if !p.until.IsZero() {
+			result["resumes_at"] = p.until.UTC().Format(time.RFC3339Nano)
+		}
+	}
+	return result
+}
+
+// Readings returns whether corrections are paused at now.
+func (p *CorrectionPause) Readings(now time.Time) map[string]interface{} {
+	return map[string]interface{}{"corrections_paused": p.Result(now)["paused"]}
+}
@@ -0,0 +1,46 @@
+package rtkutils
+
+import (
+	"testing"
+	"time"
+
+	"github.com/edaniels/golog"
+	"go.viam.com/test"
+)
+
+func TestCorrectionPause(t *testing.T) {
+	events := NewEventLog(golog.NewTestLogger(t))
+	now := time.Now()
+
+	var p CorrectionPause
+	test.That(t, p.Forward(now, events), test.ShouldBeTrue)
+	test.That(t, p.Result(now), test.ShouldResemble, map[string]interface{}{"paused": false})
+	// resuming corrections that aren't paused does nothing
+	p.Resume(now, events)
+	test.That(t, events.Events(""), test.ShouldBeEmpty)
+
+	p.Pause(0, now, events)
+	test.That(t, p.Forward(now, events), test.ShouldBeFalse)
+	test.That(t, p.Forward(now.Add(time.Hour), events), test.ShouldBeFalse)
+	result := p.Result(now.Add(time.Hour))
+	test.That(t, result["paused"], test.ShouldBeTrue)
+	test.That(t, result["frames_dropped"], test.ShouldEqual, uint64(2))
+	_, resumes := result["resumes_at"]
+	test.That(t, resumes, test.ShouldBeFalse)
+	test.That(t, p.Readings(now), test.ShouldResemble, map[string]interface{}{"corrections_paused": true})
+
+	p.Resume(now.Add(time.Hour), events)
+	test.That(t, p.Forward(now.Add(time.Hour), events), test.ShouldBeTrue)
+	resumed := events.Events(EventCorrectionsResumed)
+	test.That(t, resumed, test.ShouldHaveLength, 1)
+	test.That(t, resumed[0].Message, test.ShouldEqual, "corrections resumed after 1h0m0s, 2 frames dropped")
+
+	// a pause with a duration resumes by itself
+	p.Pause(time.Minute, now, events)
+	test.That(t, p.Result(now)["resumes_at"], test.ShouldEqual, now.Add(time.Minute).UTC().Format(time.RFC3339Nano))
+	test.That(t, p.Forward(now.Add(30*time.Second), events), test.ShouldBeFalse)
+	test.That(t, p.Result(now.Add(time.Minute))["paused"], test.ShouldBeFalse)
+	test.That(t, p.Forward(now.Add(time.Minute), events), test.ShouldBeTrue)
+	test.That(t, events.Events(EventCorrectionsPaused), test.ShouldHaveLength, 2)
+	test.That(t, events.Events(EventCorrectionsResumed), test.ShouldHaveLength, 2)
+}
@@ -0,0 +1,189 @@
+package rtkutils
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultCorrectionQueueSize is how many rtcm frames wait to be written to a receiver before the
+	// oldest are dropped, about 10 seconds of a base sending 6 messages every second.
+	DefaultCorrectionQueueSize = 64
+	// DefaultWriteStall is how long a write to a receiver may block before it failed.
+	DefaultWriteStall = 5 * time.Second
+	// DefaultFlushTimeout is how long the frames left in the queue may take to be written on shutdown.
+	DefaultFlushTimeout = 2 * time.Second
+)
+
+// CorrectionQueue holds the rtcm frames waiting to be written to a receiver, so that a receiver that
+// stops taking them, such as on a slow uart or with a full i2c buffer, doesn't hold up reading the
+// corrections. Once it is full the oldest frames are dropped, as newer corrections are worth more to
+// the receiver.
+type CorrectionQueue struct {
+	size  int
+	stall time.Duration
+
+	mu            sync.Mutex
+	frames        []queuedFrame
+	pushed        chan struct{}
+	writeStarted  time.Time // when the write in progress started, zero if there is none
+	stallReported bool      // the write in progress was reported as stalled
+	dropped       Counter
+	stalls        Counter
+	latency       LatencyStats // from pushing a frame to having written it
+}
+
+// queuedFrame is a frame waiting to be written, with when it was pushed.
+type queuedFrame struct {
+	frame  []byte
+	pushed time.Time
+}
+
+// NewCorrectionQueue returns an empty queue of size frames whose writes stall after blocking for
+// stall, DefaultCorrectionQueueSize and DefaultWriteStall if they are zero.
+func NewCorrectionQueue(size int, stall time.Duration) *CorrectionQueue {
+	if size <= 0 {
+		size = DefaultCorrectionQueueSize
+	}
+	if stall <= 0 {
+		stall = DefaultWriteStall
+	}
+	return &CorrectionQueue{size: size, stall: stall, pushed: make(chan struct{}, 1)}
+}
+
+// Push queues a copy of frame to be written, dropping the oldest frame if the queue is full. The
+// frames of a FrameReader are only valid until its next one, which is read while they wait.
+func (q *CorrectionQueue) Push(frame []byte) {
+	frame = append([]byte(nil), frame...)
+	q.mu.Lock()
+	if len(q.frames) >= q.size {
+		q.frames[0] = queuedFrame{}
+		q.frames = q.frames[1:]
+		q.dropped.Add(1)
+	}
+	q.frames = append(q.frames, queuedFrame{frame: frame, pushed: time.Now()})
+	q.mu.Unlock()
+
+	select {
+	case q.pushed <- struct{}{}:
+	default:
+	}
+}
+
+// Len returns the number of frames waiting to be written.
+func (q *CorrectionQueue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.frames)
+}
+
+// Run writes the queued frames to w in order, calling written after each one, until ctx is done or
+// a write fails. Frames that weren't written stay queued for the next run.
+func (q *CorrectionQueue) Run(ctx context.Context, w io.Writer, written func(frame []byte)) error {
+	for {
+		if ctx.Err() != nil {
+			return nil
+		}
+		queued, ok := q.next()
+		if !ok {
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-q.pushed:
+				continue
+			}
+		}
+		if err := q.write(w, queued, written); err != nil {
+			return err
+		}
+	}
+}
+
+// Flush writes the frames left in the queue to w in order, calling written after each one, until
+// none is left, a write fails or timeout passed, so that a receiver being closed gets the frames
+// read before rather than a frame cut short. A write in progress when timeout passes is finished.
+func (q *CorrectionQueue) Flush(w io.Writer, timeout time.Duration, written func(frame []byte)) error {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		queued, ok := q.next()
+		if !ok {
+			return nil
+		}
+		if err := q.write(w, queued, written); err != nil {
+			return err
+		}
+	}
+	if n := q.Len(); n > 0 {
+		return fmt.Errorf("timed out after %s with %d frames left", timeout, n)
+	}
+	return nil
+}
+
+// next takes the oldest frame out of the queue as the write in progress, if there is one.
+func (q *CorrectionQueue) next() (queuedFrame, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.frames) == 0 {
+		return queuedFrame{}, false
+	}
+	queued := q.frames[0]
+	q.frames[0] = queuedFrame{}
+	q.frames = q.frames[1:]
+	q.writeStarted = time.Now()
+	q.stallReported = false
+	return queued, true
+}
+
+// write writes a frame taken by next to w, calling written once it is.
+func (q *CorrectionQueue) write(w io.Writer, queued queuedFrame, written func(frame []byte)) error {
+	_, err := w.Write(queued.frame)
+
+	q.mu.Lock()
+	q.writeStarted = time.Time{}
+	q.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	q.latency.Record(time.Since(queued.pushed))
+	if written != nil {
+		written(queued.frame)
+	}
+	return nil
+}
+
+// CheckStall returns an error if the write in progress has blocked for longer than the queue allows
+// at now, counting every stalled write once.
+func (q *CorrectionQueue) CheckStall(now time.Time) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.writeStarted.IsZero() {
+		return nil
+	}
+	blocked := now.Sub(q.writeStarted)
+	if blocked < q.stall {
+		return nil
+	}
+	if !q.stallReported {
+		q.stallReported = true
+		q.stalls.Add(1)
+	}
+	return fmt.Errorf("write to the receiver blocked for %s with %d frames waiting", blocked.Round(time.Millisecond), len(q.frames))
+}
+
+// Dropped returns the number of frames dropped because the queue was full.
+func (q *CorrectionQueue) Dropped() uint64 {
+	return q.dropped.Get()
+}
+
+// Stalls returns the number of writes that blocked for too long.
+func (q *CorrectionQueue) Stalls() uint64 {
+	return q.stalls.Get()
+}
+
+// Latency returns the latencies from pushing a frame, right after it was read, to having written it.
+func (q *CorrectionQueue) Latency() LatencySummary {
+	return q.latency.Summary()
+}
@@ -0,0 +1,154 @@
+package rtkutils
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"go.viam.com/test"
+)
+
+// gatedWriter blocks every write until it is let through.
+type gatedWriter struct {
+	gate    chan struct{}
+	mu      sync.Mutex
+	written [][]byte
+	err     error
+}
+
+func (w *gatedWriter) Write(p []byte) (int, error) {
+	<-w.gate
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.err != nil {
+		return 0, w.err
+	}
+	w.written = append(w.written, p)
+	return len(p), nil
+}
+
+func (w *gatedWriter) frames() [][]byte {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return append([][]byte{}, w.written...)
+}
+
+func TestCorrectionQueue(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	q := NewCorrectionQueue(2, time.Second)
+	w := &gatedWriter{gate: make(chan struct{})}
+	var written Counter
+	done := make(chan error)
+	go func() { done <- q.Run(ctx, w, func([]byte) { written.Add(1) }) }()
+
+	// the first frame is taken by the blocked write, the oldest of the others is dropped
+	q.Push([]byte{1})
+	test.That(t, WaitFor(ctx, time.Second, "write", func() bool { return q.Len() == 0 }), test.ShouldBeNil)
+	q.Push([]byte{2})
+	q.Push([]byte{3})
+	q.Push([]byte{4})
+	test.That(t, q.Len(), test.ShouldEqual, 2)
+	test.That(t, q.Dropped(), test.ShouldEqual, uint64(1))
+
+	// the write stalls once it has blocked for long enough, and is counted once
+	test.That(t, q.CheckStall(time.Now()), test.ShouldBeNil)
+	test.That(t, q.CheckStall(time.Now().Add(2*time.Second)), test.ShouldNotBeNil)
+	test.That(t, q.CheckStall(time.Now().Add(3*time.Second)), test.ShouldNotBeNil)
+	test.That(t, q.Stalls(), test.ShouldEqual, uint64(1))
+
+	close(w.gate)
+	test.That(t, WaitFor(ctx, time.Second, "writes", func() bool { return written.Get() == 3 }), test.ShouldBeNil)
+	test.That(t, w.frames(), test.ShouldResemble, [][]byte{{1}, {3}, {4}})
+	// the written frames waited on the blocked write
+	test.That(t, q.Latency().Count, test.ShouldEqual, uint64(3))
+	test.That(t, q.Latency().Max, test.ShouldBeGreaterThan, 0)
+	test.That(t, q.CheckStall(time.Now().Add(3*time.Second)), test.ShouldBeNil)
+
+	cancel()
+	test.That(t, <-done, test.ShouldBeNil)
+}
+
+func TestCorrectionQueueWriteError(t *testing.T) {
+	q := NewCorrectionQueue(0, 0)
+	w := &gatedWriter{gate: make(chan struct{}), err: errors.New("closed")}
+	close(w.gate)
+	q.Push([]byte{1})
+	q.Push([]byte{2})
+	test.That(t, q.Run(context.Background(), w, nil), test.ShouldBeError, errors.New("closed"))
+	// the frame left is written once the receiver is reopened
+	test.That(t, q.Len(), test.ShouldEqual, 1)
+}
+
+func TestCorrectionQueueCopiesFrames(t *testing.T) {
+	// the second frame is read into the buffer of the first one while it waits
+	first, second := gpsMSMFrame(1000), gpsMSMFrame(2000)
+	r := NewFrameReader(bytes.NewReader(append(append([]byte{}, first...), second...)))
+	q := NewCorrectionQueue(2, time.Second)
+	for i := 0; i < 2; i++ {
+		frame, err := r.Next()
+		test.That(t, err, test.ShouldBeNil)
+		q.Push(frame)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	w := &gatedWriter{gate: make(chan struct{})}
+	close(w.gate)
+	done := make(chan error)
+	go func() { done <- q.Run(ctx, w, nil) }()
+	test.That(t, WaitFor(ctx, time.Second, "writes", func() bool { return len(w.frames()) == 2 }), test.ShouldBeNil)
+	test.That(t, w.frames(), test.ShouldResemble, [][]byte{first, second})
+	cancel()
+	test.That(t, <-done, test.ShouldBeNil)
+}
+
+// slowWriter takes delay to write.
+type slowWriter struct {
+	delay   time.Duration
+	written int
+}
+
+func (w *slowWriter) Write(p []byte) (int, error) {
+	time.Sleep(w.delay)
+	w.written++
+	return len(p), nil
+}
+
+func TestCorrectionQueueFlush(t *testing.T) {
+	q := NewCorrectionQueue(0, 0)
+	// a run stopped on shutdown leaves the frames it didn't write
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	q.Push([]byte{1})
+	q.Push([]byte{2})
+	test.That(t, q.Run(ctx, &slowWriter{}, nil), test.ShouldBeNil)
+	test.That(t, q.Len(), test.ShouldEqual, 2)
+
+	// frames keep what was pushed when the buffer they were read into is reused
+	buf := []byte{3}
+	q.Push(buf)
+	buf[0] = 4
+	q.Push(buf)
+
+	w := &gatedWriter{gate: make(chan struct{})}
+	close(w.gate)
+	var written Counter
+	test.That(t, q.Flush(w, time.Second, func([]byte) { written.Add(1) }), test.ShouldBeNil)
+	test.That(t, w.frames(), test.ShouldResemble, [][]byte{{1}, {2}, {3}, {4}})
+	test.That(t, written.Get(), test.ShouldEqual, uint64(4))
+
+	// a receiver too slow to take them all in time
+	for i := 0; i < 5; i++ {
+		q.Push([]byte{byte(i)})
+	}
+	slow := &slowWriter{delay: 50 * time.Millisecond}
+	err := q.Flush(slow, 120*time.Millisecond, nil)
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldStartWith, "timed out after 120ms with")
+	test.That(t, slow.written, test.ShouldBeBetween, 0, 5)
+	test.That(t, q.Len(), test.ShouldEqual, 5-slow.written)
+}
@@ -0,0 +1,266 @@
+package rtkutils
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/edaniels/golog"
+)
+
+// ClientsCommand returns the clients of a station's tcp_server outputs and the bytes sent to each.
+const ClientsCommand = "clients"
+
+// how long a write to a client may block the others
+const serverWriteTimeout = time.Second
+
+// ClientACL restricts which clients may connect to a CorrectionServer. Allowed are the IP addresses
+// and CIDR ranges of the clients, any client if it is empty, and MaxPerClient is how many connections
+// each client IP may hold at once, unlimited if 0.
+type ClientACL struct {
+	Allowed      []string
+	MaxPerClient int
+}
+
+// Validate checks that every allowed client is an IP address or a CIDR range.
+func (a ClientACL) Validate(prefix string) error {
+	if a.MaxPerClient < 0 {
+		return fmt.Errorf("%smax_client_connections must not be negative", prefix)
+	}
+	for i, entry := range a.Allowed {
+		if _, err := parseClientRange(entry); err != nil {
+			return fmt.Errorf("%sallowed_clients.%d %q must be an IP address or a CIDR range", prefix, i, entry)
+		}
+	}
+	return nil
+}
+
+// parseClientRange returns the range of an IP address or a CIDR range.
+func parseClientRange(entry string) (*net.IPNet, error) {
+	if strings.Contains(entry, "/") {
+		_, ipNet, err := net.ParseCIDR(entry)
+		return ipNet, err
+	}
+	ip := net.ParseIP(entry)
+	if ip == nil {
+		return nil, errors.New("invalid IP address")
+	}
+	bits := 8 * net.IPv6len
+	if ip4 := ip.To4(); ip4 != nil {
+		ip, bits = ip4, 8*net.IPv4len
+	}
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+}
+
+// ClientStats are the connections of a client of a CorrectionServer, by IP address.
+type ClientStats struct {
+	IP            string
+	Connections   int    // open now
+	BytesSent     uint64 // over every connection
+	Rejected      uint64 // connections refused by the ACL
+	LastConnected time.Time
+	Rover         RoverReport // the last GGA the client sent back, if any
+}
+
+// CorrectionServer serves a station's corrections over tcp to every client the ACL allows, for rovers
+// and other stations that connect to the station rather than the other way around. Its stats are kept
+// while it is closed and opened again.
+type CorrectionServer struct {
+	addr   string
+	ranges []*net.IPNet
+	max    int
+	logger golog.Logger
+
+	mu       sync.Mutex
+	listener net.Listener
+	conns    map[net.Conn]string     // open connections and their client IP
+	clients  map[string]*ClientStats // by IP
+	workers  sync.WaitGroup
+}
+
+// NewCorrectionServer returns a server for the host:port addr, which only listens once opened.
+// The acl must be valid.
+func NewCorrectionServer(addr string, acl ClientACL, logger golog.Logger) *CorrectionServer {
+	s := &CorrectionServer{
+		addr:    addr,
+		max:     acl.MaxPerClient,
+		logger:  logger,
+		conns:   map[net.Conn]string{},
+		clients: map[string]*ClientStats{},
+	}
+	for _, entry := range acl.Allowed {
+		if ipNet, err := parseClientRange(entry); err == nil {
+			s.ranges = append(s.ranges, ipNet)
+		}
+	}
+	return s
+}
+
+// Listen starts accepting clients.
+func (s *CorrectionServer) Listen() error {
+	l, err := net.Listen("tcp", s.addr)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	s.listener = l
+	s.mu.Unlock()
+	s.workers.Add(1)
+	go func() {
+		defer s.workers.Done()
+		s.accept(l)
+	}()
+	return nil
+}
+
+// Addr returns the address the server listens on, nil unless it is listening.
+func (s *CorrectionServer) Addr() net.Addr {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.listener == nil {
+		return nil
+	}
+	return s.listener.Addr()
+}
+
+func (s *CorrectionServer) accept(l net.Listener) {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		ip, _, err := net.SplitHostPort(conn.RemoteAddr().String())
+		if err != nil {
+			conn.Close()
+			continue
+		}
+		if reason := s.admit(l, conn, ip); reason != "" {
+			s.logger.Debugf("refused a connection from %s to %s: %s", ip, s.addr, reason)
+			conn.Close()
+		}
+	}
+}
+
+// admit adds the connection of a client accepted by l, returning why it was refused if the ACL
+// doesn't allow it.
+func (s *CorrectionServer) admit(l net.Listener, conn net.Conn, ip string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.listener != l {
+		return "the server is closed"
+	}
+	stats, ok := s.clients[ip]
+	if !ok {
+		stats = &ClientStats{IP: ip}
+		s.clients[ip] = stats
+	}
+	if !s.allowed(net.ParseIP(ip)) {
+		stats.Rejected++
+		return "not in allowed_clients"
+	}
+	if s.max > 0 && stats.Connections >= s.max {
+		stats.Rejected++
+		return fmt.Sprintf("already has %d connections", stats.Connections)
+	}
+	stats.Connections++
+	stats.LastConnected = time.Now().UTC()
+	s.conns[conn] = ip
+	s.workers.Add(1)
+	go func() {
+		defer s.workers.Done()
+		s.readUploads(conn, ip)
+	}()
+	return ""
+}
+
+// readUploads reads what a client sends back, such as the GGA of a rover that reports its position
+// like it would to an NTRIP caster, until the connection is closed.
+func (s *CorrectionServer) readUploads(conn net.Conn, ip string) {
+	lines := bufio.NewScanner(conn)
+	for lines.Scan() {
+		report, ok := ParseRoverGGA(lines.Text(), time.Now().UTC())
+		if !ok {
+			continue
+		}
+		s.mu.Lock()
+		s.clients[ip].Rover = report
+		s.mu.Unlock()
+	}
+	// the client hung up, or sent a line too long to be a sentence
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.conns[conn]; ok {
+		s.drop(conn)
+	}
+}
+
+func (s *CorrectionServer) allowed(ip net.IP) bool {
+	if len(s.ranges) == 0 {
+		return true
+	}
+	for _, ipNet := range s.ranges {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// Write sends p to every client, dropping the clients that fail. It never fails, so that a station
+// keeps forwarding corrections with no clients connected.
+func (s *CorrectionServer) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for conn, ip := range s.conns {
+		if err := conn.SetWriteDeadline(time.Now().Add(serverWriteTimeout)); err == nil {
+			var n int
+			n, err = conn.Write(p)
+			s.clients[ip].BytesSent += uint64(n)
+			if err == nil {
+				continue
+			}
+		}
+		s.drop(conn)
+	}
+	return len(p), nil
+}
+
+// drop closes the connection of a client. The caller must hold mu.
+func (s *CorrectionServer) drop(conn net.Conn) {
+	conn.Close()
+	s.clients[s.conns[conn]].Connections--
+	delete(s.conns, conn)
+}
+
+// Clients returns the stats of every client that connected, or tried to, by IP address.
+func (s *CorrectionServer) Clients() []ClientStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	clients := make([]ClientStats, 0, len(s.clients))
+	for _, stats := range s.clients {
+		clients = append(clients, *stats)
+	}
+	sort.Slice(clients, func(i, j int) bool { return clients[i].IP < clients[j].IP })
+	return clients
+}
+
+// Close stops accepting clients and disconnects them.
+func (s *CorrectionServer) Close() error {
+	s.mu.Lock()
+	var err error
+	if s.listener != nil {
+		err = s.listener.Close()
+		s.listener = nil
+	}
+	for conn := range s.conns {
+		s.drop(conn)
+	}
+	s.mu.Unlock()
+	s.workers.Wait()
+	return err
+}
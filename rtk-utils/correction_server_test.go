@@ -0,0 +1,106 @@
+package rtkutils
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/edaniels/golog"
+	"go.viam.com/test"
+)
+
+func TestValidateClientACL(t *testing.T) {
+	test.That(t, ClientACL{}.Validate("outputs.0."), test.ShouldBeNil)
+	test.That(t, ClientACL{Allowed: []string{"10.0.0.7", "192.168.1.0/24", "fd00::/8"}}.Validate("outputs.0."), test.ShouldBeNil)
+	test.That(t, ClientACL{Allowed: []string{"10.0.0.7", "rover1"}}.Validate("outputs.0."), test.ShouldBeError,
+		errors.New("outputs.0.allowed_clients.1 \"rover1\" must be an IP address or a CIDR range"))
+	test.That(t, ClientACL{MaxPerClient: -1}.Validate("outputs.0."), test.ShouldBeError,
+		errors.New("outputs.0.max_client_connections must not be negative"))
+}
+
+func TestCorrectionServer(t *testing.T) {
+	ctx := context.Background()
+	s := NewCorrectionServer("127.0.0.1:0", ClientACL{Allowed: []string{"127.0.0.0/8"}, MaxPerClient: 1}, golog.NewTestLogger(t))
+	test.That(t, s.Listen(), test.ShouldBeNil)
+	addr := s.Addr().String()
+
+	client, err := net.Dial("tcp", addr)
+	test.That(t, err, test.ShouldBeNil)
+	defer client.Close()
+	connected := func() bool {
+		clients := s.Clients()
+		return len(clients) == 1 && clients[0].Connections == 1
+	}
+	test.That(t, WaitFor(ctx, time.Second, "client", connected), test.ShouldBeNil)
+
+	// a second connection from the same client is over the limit
+	second, err := net.Dial("tcp", addr)
+	test.That(t, err, test.ShouldBeNil)
+	defer second.Close()
+	_, err = second.Read(make([]byte, 1))
+	test.That(t, err, test.ShouldEqual, io.EOF)
+
+	n, err := s.Write([]byte("rtcm"))
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, n, test.ShouldEqual, 4)
+	buf := make([]byte, 4)
+	_, err = io.ReadFull(client, buf)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, string(buf), test.ShouldEqual, "rtcm")
+
+	clients := s.Clients()
+	test.That(t, clients, test.ShouldHaveLength, 1)
+	test.That(t, clients[0].IP, test.ShouldEqual, "127.0.0.1")
+	test.That(t, clients[0].BytesSent, test.ShouldEqual, uint64(4))
+	test.That(t, clients[0].Rejected, test.ShouldEqual, uint64(1))
+
+	// the stats are kept once the clients are disconnected
+	test.That(t, s.Close(), test.ShouldBeNil)
+	_, err = client.Read(buf)
+	test.That(t, err, test.ShouldEqual, io.EOF)
+	clients = s.Clients()
+	test.That(t, clients[0].Connections, test.ShouldEqual, 0)
+	test.That(t, clients[0].BytesSent, test.ShouldEqual, uint64(4))
+}
+
+func TestCorrectionServerRoverUploads(t *testing.T) {
+	ctx := context.Background()
+	s := NewCorrectionServer("127.0.0.1:0", ClientACL{}, golog.NewTestLogger(t))
+	test.That(t, s.Listen(), test.ShouldBeNil)
+	defer s.Close()
+
+	// a rover sends its GGA back like it would to an NTRIP caster
+	client, err := net.Dial("tcp", s.Addr().String())
+	test.That(t, err, test.ShouldBeNil)
+	_, err = client.Write([]byte("garbage\r\n" + testGGAEpoch1 + "\r\n"))
+	test.That(t, err, test.ShouldBeNil)
+	reported := func() bool {
+		clients := s.Clients()
+		return len(clients) == 1 && !clients[0].Rover.Received.IsZero()
+	}
+	test.That(t, WaitFor(ctx, time.Second, "rover report", reported), test.ShouldBeNil)
+	test.That(t, s.Clients()[0].Rover.FixQuality, test.ShouldEqual, 2)
+
+	// a rover that hangs up is disconnected, and its last report kept
+	test.That(t, client.Close(), test.ShouldBeNil)
+	disconnected := func() bool { return s.Clients()[0].Connections == 0 }
+	test.That(t, WaitFor(ctx, time.Second, "disconnect", disconnected), test.ShouldBeNil)
+	test.That(t, s.Clients()[0].Rover.Satellites, test.ShouldEqual, 6)
+}
+
+func TestCorrectionServerNotAllowed(t *testing.T) {
+	s := NewCorrectionServer("127.0.0.1:0", ClientACL{Allowed: []string{"10.0.0.0/8"}}, golog.NewTestLogger(t))
+	test.That(t, s.Listen(), test.ShouldBeNil)
+	defer s.Close()
+
+	client, err := net.Dial("tcp", s.Addr().String())
+	test.That(t, err, test.ShouldBeNil)
+	defer client.Close()
+	_, err = client.Read(make([]byte, 1))
+	test.That(t, err, test.ShouldEqual, io.EOF)
+	test.That(t, s.Clients()[0].Rejected, test.ShouldEqual, uint64(1))
+	test.That(t, s.Clients()[0].Connections, test.ShouldEqual, 0)
+}
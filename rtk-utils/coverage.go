@@ -0,0 +1,166 @@
+package rtkutils
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+
+	geo "github.com/kellydunn/golang-geo"
+)
+
+// ExportCoverageCommand returns the fix quality statistics of a CoverageMap as GeoJSON.
+const ExportCoverageCommand = "export_coverage"
+
+const (
+	minCoverageCell = 1    // m
+	maxCoverageCell = 1000 // m
+	// cells a CoverageMap keeps, about 5 km² of 5 m cells, epochs in new cells past it aren't counted
+	maxCoverageCells = 200000
+)
+
+// coverageFixes are the GGA fix qualities a CoverageMap counts apart, the others count as "other".
+var coverageFixes = map[int]string{
+	0:                  "no_fix",
+	1:                  "gps",
+	2:                  "dgps",
+	FixQualityRTKFixed: "rtk_fixed",
+	FixQualityRTKFloat: "rtk_float",
+}
+
+// ValidateCoverageCell checks the coverage cell size of a config, in meters.
+func ValidateCoverageCell(cell float64) error {
+	if cell != 0 && (cell < minCoverageCell || cell > maxCoverageCell) {
+		return fmt.Errorf("coverage_cell_m must be between %d and %d", minCoverageCell, maxCoverageCell)
+	}
+	return nil
+}
+
+type coverageCell struct {
+	lat, lng int // indexes of the cell, in cells from the equator and the meridian
+}
+
+// CoverageMap counts the fix qualities of a rover's epochs on a grid of square cells, so that a
+// site can be mapped by where RTK degrades. Epochs without a fix count where the rover last had one,
+// the receiver keeps reporting that position. Cells are a fixed size in latitude, and in longitude
+// at the latitude of their row. A nil map counts nothing.
+type CoverageMap struct {
+	cell float64 // m
+
+	mu      sync.Mutex
+	cells   map[coverageCell]map[string]uint64
+	skipped uint64 // epochs in new cells past maxCoverageCells
+}
+
+// NewCoverageMap returns a map of cell meter cells, or nil if cell is 0.
+func NewCoverageMap(cell float64) *CoverageMap {
+	if cell == 0 {
+		return nil
+	}
+	return &CoverageMap{cell: cell, cells: map[coverageCell]map[string]uint64{}}
+}
+
+// Update counts the fix quality of an epoch at pos. Epochs without a valid position are skipped.
+func (m *CoverageMap) Update(fixQuality int, pos *geo.Point) {
+	if m == nil || pos == nil || math.IsNaN(pos.Lat()) || math.IsNaN(pos.Lng()) || (pos.Lat() == 0 && pos.Lng() == 0) {
+		return
+	}
+	fix, ok := coverageFixes[fixQuality]
+	if !ok {
+		fix = "other"
+	}
+	key := m.cellOf(pos)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	counts, ok := m.cells[key]
+	if !ok {
+		if len(m.cells) >= maxCoverageCells {
+			m.skipped++
+			return
+		}
+		counts = map[string]uint64{}
+		m.cells[key] = counts
+	}
+	counts[fix]++
+}
+
+func (m *CoverageMap) cellOf(pos *geo.Point) coverageCell {
+	lat := int(math.Floor(pos.Lat() * metersPerDegree / m.cell))
+	return coverageCell{lat: lat, lng: int(math.Floor(pos.Lng() / m.lngStep(lat)))}
+}
+
+// latStep is the height of a cell in degrees of latitude.
+func (m *CoverageMap) latStep() float64 {
+	return m.cell / metersPerDegree
+}
+
+// lngStep is the width of a cell of the row lat in degrees of longitude.
+func (m *CoverageMap) lngStep(lat int) float64 {
+	center := (float64(lat) + 0.5) * m.latStep()
+	cos := math.Max(math.Cos(center*math.Pi/180), 1e-9)
+	return m.latStep() / cos
+}
+
+// GeoJSON returns the cells as a GeoJSON feature collection of polygons, with the epochs of every
+// fix quality counted in them, their total and the share that were rtk fixed as properties.
+func (m *CoverageMap) GeoJSON() ([]byte, int, error) {
+	m.mu.Lock()
+	keys := make([]coverageCell, 0, len(m.cells))
+	for key := range m.cells {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].lat != keys[j].lat {
+			return keys[i].lat < keys[j].lat
+		}
+		return keys[i].lng < keys[j].lng
+	})
+	features := make([]interface{}, 0, len(keys))
+	for _, key := range keys {
+		properties := map[string]interface{}{}
+		var epochs uint64
+		for fix, n := range m.cells[key] {
+			properties[fix] = n
+			epochs += n
+		}
+		properties["epochs"] = epochs
+		properties["rtk_fixed_ratio"] = float64(m.cells[key]["rtk_fixed"]) / float64(epochs)
+
+		south, west := float64(key.lat)*m.latStep(), float64(key.lng)*m.lngStep(key.lat)
+		north, east := south+m.latStep(), west+m.lngStep(key.lat)
+		features = append(features, map[string]interface{}{
+			"type": "Feature",
+			"geometry": map[string]interface{}{
+				"type":        "Polygon",
+				"coordinates": [][][]float64{{{west, south}, {east, south}, {east, north}, {west, north}, {west, south}}},
+			},
+			"properties": properties,
+		})
+	}
+	skipped := m.skipped
+	m.mu.Unlock()
+
+	doc, err := json.Marshal(map[string]interface{}{
+		"type":     "FeatureCollection",
+		"features": features,
+		"properties": map[string]interface{}{
+			"cell_m":         m.cell,
+			"skipped_epochs": skipped,
+		},
+	})
+	return doc, len(features), err
+}
+
+// ExportCoverageResult answers an ExportCoverageCommand.
+func ExportCoverageResult(m *CoverageMap) (map[string]interface{}, error) {
+	if m == nil {
+		return nil, errors.New("coverage mapping is not enabled, set coverage_cell_m")
+	}
+	doc, cells, err := m.GeoJSON()
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"cells": cells, "coverage": string(doc)}, nil
+}
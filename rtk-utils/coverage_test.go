@@ -0,0 +1,74 @@
+package rtkutils
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"testing"
+
+	geo "github.com/kellydunn/golang-geo"
+	"go.viam.com/test"
+)
+
+func TestValidateCoverageCell(t *testing.T) {
+	test.That(t, ValidateCoverageCell(0), test.ShouldBeNil)
+	test.That(t, ValidateCoverageCell(5), test.ShouldBeNil)
+	test.That(t, ValidateCoverageCell(-5), test.ShouldBeError, errors.New("coverage_cell_m must be between 1 and 1000"))
+	test.That(t, ValidateCoverageCell(5000), test.ShouldBeError, errors.New("coverage_cell_m must be between 1 and 1000"))
+}
+
+func TestCoverageMap(t *testing.T) {
+	var none *CoverageMap
+	none.Update(FixQualityRTKFixed, geo.NewPoint(37.4, -122.1))
+	_, err := ExportCoverageResult(none)
+	test.That(t, err, test.ShouldBeError, errors.New("coverage mapping is not enabled, set coverage_cell_m"))
+
+	m := NewCoverageMap(5)
+	// the center of a cell
+	cell := m.cellOf(geo.NewPoint(37.4, -122.1))
+	pos := geo.NewPoint((float64(cell.lat)+0.5)*m.latStep(), (float64(cell.lng)+0.5)*m.lngStep(cell.lat))
+	near := pos.PointAtDistanceAndBearing(0.001, 90) // 1 m east
+	far := pos.PointAtDistanceAndBearing(0.02, 0)    // 20 m north
+	m.Update(FixQualityRTKFixed, pos)
+	m.Update(FixQualityRTKFixed, pos)
+	m.Update(FixQualityRTKFloat, pos)
+	m.Update(FixQualityRTKFixed, near)
+	m.Update(0, far)
+	m.Update(FixQualityRTKFixed, nil)
+	m.Update(FixQualityRTKFixed, geo.NewPoint(math.NaN(), math.NaN()))
+
+	result, err := ExportCoverageResult(m)
+	test.That(t, err, test.ShouldBeNil)
+	var doc struct {
+		Type     string
+		Features []struct {
+			Geometry struct {
+				Type        string
+				Coordinates [][][2]float64
+			}
+			Properties map[string]float64
+		}
+	}
+	test.That(t, json.Unmarshal([]byte(result["coverage"].(string)), &doc), test.ShouldBeNil)
+	test.That(t, doc.Type, test.ShouldEqual, "FeatureCollection")
+	test.That(t, result["cells"], test.ShouldEqual, 2)
+	test.That(t, len(doc.Features), test.ShouldEqual, 2)
+
+	var epochs float64
+	for _, f := range doc.Features {
+		epochs += f.Properties["epochs"]
+		test.That(t, f.Geometry.Type, test.ShouldEqual, "Polygon")
+		ring := f.Geometry.Coordinates[0]
+		test.That(t, len(ring), test.ShouldEqual, 5)
+		sw := geo.NewPoint(ring[0][1], ring[0][0])
+		test.That(t, sw.GreatCircleDistance(geo.NewPoint(ring[1][1], ring[1][0]))*1000, test.ShouldAlmostEqual, 5, 0.01)
+		test.That(t, sw.GreatCircleDistance(geo.NewPoint(ring[3][1], ring[3][0]))*1000, test.ShouldAlmostEqual, 5, 0.01)
+	}
+	test.That(t, epochs, test.ShouldEqual, 5)
+
+	// the cells are sorted south to north
+	test.That(t, doc.Features[0].Properties, test.ShouldResemble,
+		map[string]float64{"rtk_fixed": 3, "rtk_float": 1, "epochs": 4, "rtk_fixed_ratio": 0.75})
+	test.That(t, doc.Features[1].Properties, test.ShouldResemble,
+		map[string]float64{"no_fix": 1, "epochs": 1, "rtk_fixed_ratio": 0})
+}
@@ -0,0 +1,177 @@
+package rtkutils
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	geo "github.com/kellydunn/golang-geo"
+)
+
+// Datums a rover can report its positions in.
+const (
+	DatumWGS84 = "wgs84"
+	// NAD83(2011), from ITRF2008 which WGS84 matches at the cm level, as NGS publishes it
+	DatumNAD83 = "nad83"
+	// ETRS89 as realized by ETRF2014, from ITRF2014, as EUREF publishes it
+	DatumETRS89 = "etrs89"
+	// the Helmert transformation of the config
+	DatumCustom = "custom"
+)
+
+const (
+	grs80A = 6378137.0
+	grs80F = 1 / 298.257222101
+
+	arcsecToRad = math.Pi / (180 * 3600)
+)
+
+// Helmert is a 7 parameter transformation from WGS84, with the parameters proj takes: a translation
+// in m, rotations in arcseconds in the position vector convention, unless CoordinateFrame is set, and
+// a scale in ppm. Parameters changing over time have rates per year from Epoch, a decimal year. A and
+// RF are the semi major axis in m and the inverse flattening of the ellipsoid of the target datum,
+// WGS84's if 0.
+type Helmert struct {
+	X, Y, Z    float64
+	RX, RY, RZ float64
+	S          float64
+
+	DX, DY, DZ    float64
+	DRX, DRY, DRZ float64
+	DS            float64
+	Epoch         float64
+
+	CoordinateFrame bool
+	A, RF           float64
+}
+
+// builtinDatums are the transformations of the built in datums.
+var builtinDatums = map[string]Helmert{
+	DatumNAD83: {
+		X: 0.99343, Y: -1.90331, Z: -0.52655,
+		RX: 0.02591467, RY: 0.00942645, RZ: 0.01159935,
+		S:  0.00171504,
+		DX: 0.00079, DY: -0.00060, DZ: -0.00134,
+		DRX: 0.00006667, DRY: -0.00075744, DRZ: -0.00005133,
+		DS:              -0.00010201,
+		Epoch:           1997.0,
+		CoordinateFrame: true,
+		A:               grs80A, RF: 1 / grs80F,
+	},
+	DatumETRS89: {
+		DRX: 0.000085, DRY: 0.000531, DRZ: -0.000770,
+		Epoch: 1989.0,
+		A:     grs80A, RF: 1 / grs80F,
+	},
+}
+
+// ValidateDatum checks the output datum of a config, and the transformation it needs if it is custom.
+func ValidateDatum(name string, transform *Helmert) error {
+	switch name {
+	case "", DatumWGS84, DatumNAD83, DatumETRS89:
+		if transform != nil {
+			return errors.New("datum_transform needs output_datum custom")
+		}
+		return nil
+	case DatumCustom:
+	default:
+		return fmt.Errorf("output_datum %q isn't supported, use %s, %s, %s or %s",
+			name, DatumWGS84, DatumNAD83, DatumETRS89, DatumCustom)
+	}
+	if transform == nil {
+		return errors.New("output_datum custom needs a datum_transform")
+	}
+	if (transform.A == 0) != (transform.RF == 0) {
+		return errors.New("datum_transform needs both a and rf, or neither")
+	}
+	if transform.A < 0 || transform.RF < 0 {
+		return errors.New("datum_transform a and rf must not be negative")
+	}
+	return nil
+}
+
+// Datum transforms the WGS84 positions a rover reads into the datum of the ground control of a
+// survey. Only latitude and longitude are transformed, the altitude a receiver reports is above mean
+// sea level rather than the ellipsoid. A nil Datum leaves positions in WGS84.
+type Datum struct {
+	h Helmert
+}
+
+// NewDatum returns the datum of a name, or nil for WGS84. A custom datum uses transform.
+func NewDatum(name string, transform *Helmert) *Datum {
+	switch name {
+	case "", DatumWGS84:
+		return nil
+	case DatumCustom:
+		return &Datum{h: *transform}
+	}
+	h, ok := builtinDatums[name]
+	if !ok {
+		return nil
+	}
+	return &Datum{h: h}
+}
+
+// Transform returns pos, a WGS84 position at alt m above the ellipsoid, in the datum at the time at.
+func (d *Datum) Transform(pos *geo.Point, alt float64, at time.Time) *geo.Point {
+	if d == nil || pos == nil || math.IsNaN(pos.Lat()) || math.IsNaN(pos.Lng()) {
+		return pos
+	}
+	h := d.h
+	dt := decimalYear(at) - h.Epoch
+	if h.Epoch == 0 {
+		dt = 0
+	}
+	t := [3]float64{h.X + h.DX*dt, h.Y + h.DY*dt, h.Z + h.DZ*dt}
+	r := [3]float64{
+		(h.RX + h.DRX*dt) * arcsecToRad,
+		(h.RY + h.DRY*dt) * arcsecToRad,
+		(h.RZ + h.DRZ*dt) * arcsecToRad,
+	}
+	if h.CoordinateFrame {
+		r = [3]float64{-r[0], -r[1], -r[2]}
+	}
+	s := 1 + (h.S+h.DS*dt)*1e-6
+
+	x := geodeticToECEF(pos.Lat(), pos.Lng(), alt)
+	out := [3]float64{
+		t[0] + s*(x[0]-r[2]*x[1]+r[1]*x[2]),
+		t[1] + s*(r[2]*x[0]+x[1]-r[0]*x[2]),
+		t[2] + s*(-r[1]*x[0]+r[0]*x[1]+x[2]),
+	}
+	a, f := wgs84A, wgs84F
+	if h.A != 0 {
+		a, f = h.A, 1/h.RF
+	}
+	lat, lng, _ := ecefToGeodetic(out, a, f)
+	return geo.NewPoint(lat, lng)
+}
+
+// ecefToGeodetic converts ECEF in m to latitude and longitude in degrees and height in m on the
+// ellipsoid of semi major axis a and flattening f.
+func ecefToGeodetic(x [3]float64, a, f float64) (float64, float64, float64) {
+	e2 := f * (2 - f)
+	p := math.Hypot(x[0], x[1])
+	lng := math.Atan2(x[1], x[0])
+	phi := math.Atan2(x[2], p*(1-e2))
+	var height float64
+	for i := 0; i < 10; i++ {
+		n := a / math.Sqrt(1-e2*math.Sin(phi)*math.Sin(phi))
+		if math.Abs(math.Cos(phi)) > 1e-12 {
+			height = p/math.Cos(phi) - n
+		} else {
+			height = math.Abs(x[2]) - n*(1-e2)
+		}
+		phi = math.Atan2(x[2], p*(1-e2*n/(n+height)))
+	}
+	return phi * 180 / math.Pi, lng * 180 / math.Pi, height
+}
+
+// decimalYear returns t as a year and the fraction of it gone by.
+func decimalYear(t time.Time) float64 {
+	t = t.UTC()
+	start := time.Date(t.Year(), time.January, 1, 0, 0, 0, 0, time.UTC)
+	end := start.AddDate(1, 0, 0)
+	return float64(t.Year()) + float64(t.Sub(start))/float64(end.Sub(start))
+}
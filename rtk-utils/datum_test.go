@@ -0,0 +1,72 @@
+package rtkutils
+
+import (
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	geo "github.com/kellydunn/golang-geo"
+	"go.viam.com/test"
+)
+
+func TestValidateDatum(t *testing.T) {
+	test.That(t, ValidateDatum("", nil), test.ShouldBeNil)
+	test.That(t, ValidateDatum(DatumNAD83, nil), test.ShouldBeNil)
+	test.That(t, ValidateDatum(DatumCustom, &Helmert{X: 1}), test.ShouldBeNil)
+	test.That(t, ValidateDatum("osgb36", nil), test.ShouldBeError,
+		errors.New(`output_datum "osgb36" isn't supported, use wgs84, nad83, etrs89 or custom`))
+	test.That(t, ValidateDatum(DatumCustom, nil), test.ShouldBeError,
+		errors.New("output_datum custom needs a datum_transform"))
+	test.That(t, ValidateDatum(DatumETRS89, &Helmert{X: 1}), test.ShouldBeError,
+		errors.New("datum_transform needs output_datum custom"))
+	test.That(t, ValidateDatum(DatumCustom, &Helmert{A: 6378137}), test.ShouldBeError,
+		errors.New("datum_transform needs both a and rf, or neither"))
+}
+
+func TestEcefToGeodetic(t *testing.T) {
+	for _, p := range [][3]float64{{0, 0, 0}, {40.7, -74.0, 30}, {-33.9, 151.2, 1200}, {89.9, 10, 5}} {
+		lat, lng, alt := ecefToGeodetic(geodeticToECEF(p[0], p[1], p[2]), wgs84A, wgs84F)
+		test.That(t, lat, test.ShouldAlmostEqual, p[0], 1e-9)
+		test.That(t, lng, test.ShouldAlmostEqual, p[1], 1e-9)
+		test.That(t, alt, test.ShouldAlmostEqual, p[2], 1e-4)
+	}
+}
+
+func TestDatumTransform(t *testing.T) {
+	at := time.Date(2026, time.July, 2, 0, 0, 0, 0, time.UTC)
+	pos := geo.NewPoint(0, 0)
+
+	t.Run("wgs84 leaves positions as they are", func(t *testing.T) {
+		var datum *Datum
+		test.That(t, NewDatum(DatumWGS84, nil), test.ShouldBeNil)
+		test.That(t, datum.Transform(pos, 0, at), test.ShouldEqual, pos)
+	})
+
+	t.Run("a translation shifts positions", func(t *testing.T) {
+		out := NewDatum(DatumCustom, &Helmert{Y: 10}).Transform(pos, 0, at)
+		test.That(t, out.Lat(), test.ShouldAlmostEqual, 0, 1e-12)
+		test.That(t, out.Lng(), test.ShouldAlmostEqual, 10/wgs84A*180/math.Pi, 1e-12)
+	})
+
+	t.Run("rotations follow their convention", func(t *testing.T) {
+		out := NewDatum(DatumCustom, &Helmert{RZ: 1}).Transform(pos, 0, at)
+		test.That(t, out.Lng(), test.ShouldAlmostEqual, 1.0/3600, 1e-12)
+		out = NewDatum(DatumCustom, &Helmert{RZ: 1, CoordinateFrame: true}).Transform(pos, 0, at)
+		test.That(t, out.Lng(), test.ShouldAlmostEqual, -1.0/3600, 1e-12)
+	})
+
+	t.Run("rates apply from the epoch", func(t *testing.T) {
+		out := NewDatum(DatumCustom, &Helmert{DY: 1, Epoch: decimalYear(at) - 10}).Transform(pos, 0, at)
+		test.That(t, out.Lng(), test.ShouldAlmostEqual, 10/wgs84A*180/math.Pi, 1e-9)
+	})
+
+	t.Run("built in datums shift positions by a meter or two", func(t *testing.T) {
+		denver := geo.NewPoint(39.7392, -104.9903)
+		shift := denver.GreatCircleDistance(NewDatum(DatumNAD83, nil).Transform(denver, 1600, at)) * 1000
+		test.That(t, shift, test.ShouldBeBetween, 0.5, 2.5)
+		paris := geo.NewPoint(48.8566, 2.3522)
+		shift = paris.GreatCircleDistance(NewDatum(DatumETRS89, nil).Transform(paris, 35, at)) * 1000
+		test.That(t, shift, test.ShouldBeBetween, 0.5, 1.5)
+	})
+}
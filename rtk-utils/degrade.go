@@ -0,0 +1,112 @@
+package rtkutils
+
+import (
+	"errors"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// maxDegradeDelay is the most latency or jitter corrections can be degraded by, past the age at which
+// receivers stop using them.
+const maxDegradeDelay = time.Minute
+
+// CorrectionDegradation is how corrections are degraded on their way to a receiver, for testing how a
+// robot behaves with degraded rtk before field trials.
+type CorrectionDegradation struct {
+	LossPercent    float64       // frames dropped
+	CorruptPercent float64       // frames with a byte flipped, which the receiver rejects by their crc
+	Latency        time.Duration // added to every frame
+	Jitter         time.Duration // up to this much more latency, at random
+	Seed           int64         // seeds the random choices for repeatable runs, the time if 0
+}
+
+// Validate checks the degradation.
+func (d CorrectionDegradation) Validate() error {
+	if d.LossPercent < 0 || d.LossPercent > 100 {
+		return errors.New("degrade_loss_percent must be between 0 and 100")
+	}
+	if d.CorruptPercent < 0 || d.CorruptPercent > 100 {
+		return errors.New("degrade_corrupt_percent must be between 0 and 100")
+	}
+	if d.Latency < 0 || d.Latency > maxDegradeDelay {
+		return errors.New("degrade_latency_ms must be between 0 and 60000")
+	}
+	if d.Jitter < 0 || d.Jitter > maxDegradeDelay {
+		return errors.New("degrade_jitter_ms must be between 0 and 60000")
+	}
+	return nil
+}
+
+// CorrectionDegrader drops, corrupts and delays the corrections of a CorrectionDegradation. A nil
+// degrader passes them through.
+type CorrectionDegrader struct {
+	degradation CorrectionDegradation
+
+	mu        sync.Mutex
+	rand      *rand.Rand
+	dropped   Counter
+	corrupted Counter
+}
+
+// NewCorrectionDegrader returns the degrader of d, or nil if d doesn't degrade anything.
+func NewCorrectionDegrader(d CorrectionDegradation) *CorrectionDegrader {
+	if d.LossPercent == 0 && d.CorruptPercent == 0 && d.Latency == 0 && d.Jitter == 0 {
+		return nil
+	}
+	seed := d.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	//nolint:gosec
+	return &CorrectionDegrader{degradation: d, rand: rand.New(rand.NewSource(seed))}
+}
+
+// Degrade hands frame to deliver unless it is dropped, corrupted if it is chosen to be and once its
+// latency passed. Delayed frames are delivered from another goroutine, and jitter can reorder them
+// like a network would.
+func (d *CorrectionDegrader) Degrade(frame []byte, deliver func(frame []byte)) {
+	if d == nil {
+		deliver(frame)
+		return
+	}
+	d.mu.Lock()
+	drop := d.rand.Float64()*100 < d.degradation.LossPercent
+	corrupt := !drop && d.rand.Float64()*100 < d.degradation.CorruptPercent
+	delay := d.degradation.Latency
+	if d.degradation.Jitter > 0 {
+		delay += time.Duration(d.rand.Int63n(int64(d.degradation.Jitter)))
+	}
+	var flip int
+	if corrupt && len(frame) > 1 {
+		// anything but the preamble, so the frame is still found and then rejected
+		flip = 1 + d.rand.Intn(len(frame)-1)
+	}
+	d.mu.Unlock()
+
+	if drop {
+		d.dropped.Add(1)
+		return
+	}
+	if corrupt && flip > 0 {
+		frame = append([]byte(nil), frame...)
+		frame[flip] ^= 0xFF
+		d.corrupted.Add(1)
+	}
+	if delay <= 0 {
+		deliver(frame)
+		return
+	}
+	time.AfterFunc(delay, func() { deliver(frame) })
+}
+
+// Readings returns how many frames were dropped and corrupted.
+func (d *CorrectionDegrader) Readings() map[string]interface{} {
+	if d == nil {
+		return map[string]interface{}{}
+	}
+	return map[string]interface{}{
+		"rtcm_degraded_dropped":   d.dropped.Get(),
+		"rtcm_degraded_corrupted": d.corrupted.Get(),
+	}
+}
@@ -0,0 +1,65 @@
+package rtkutils
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-gnss/rtcm/rtcm3"
+	"go.viam.com/test"
+)
+
+func TestValidateCorrectionDegradation(t *testing.T) {
+	test.That(t, CorrectionDegradation{}.Validate(), test.ShouldBeNil)
+	test.That(t, CorrectionDegradation{LossPercent: 20, CorruptPercent: 5, Latency: time.Second}.Validate(), test.ShouldBeNil)
+	test.That(t, CorrectionDegradation{LossPercent: 101}.Validate(), test.ShouldBeError,
+		errors.New("degrade_loss_percent must be between 0 and 100"))
+	test.That(t, CorrectionDegradation{CorruptPercent: -1}.Validate(), test.ShouldBeError,
+		errors.New("degrade_corrupt_percent must be between 0 and 100"))
+	test.That(t, CorrectionDegradation{Jitter: 2 * time.Minute}.Validate(), test.ShouldBeError,
+		errors.New("degrade_jitter_ms must be between 0 and 60000"))
+}
+
+func TestCorrectionDegrader(t *testing.T) {
+	frame := rtcm3.EncapsulateByteArray([]byte{0x3e, 0xd0, 0x01, 0x02, 0x03}).Serialize()
+	var delivered [][]byte
+	deliver := func(frame []byte) { delivered = append(delivered, frame) }
+
+	// no degradation passes frames through
+	var none *CorrectionDegrader
+	test.That(t, NewCorrectionDegrader(CorrectionDegradation{Seed: 1}), test.ShouldBeNil)
+	none.Degrade(frame, deliver)
+	test.That(t, delivered, test.ShouldResemble, [][]byte{frame})
+	test.That(t, none.Readings(), test.ShouldResemble, map[string]interface{}{})
+
+	delivered = nil
+	lossy := NewCorrectionDegrader(CorrectionDegradation{LossPercent: 100})
+	lossy.Degrade(frame, deliver)
+	test.That(t, delivered, test.ShouldBeEmpty)
+	test.That(t, lossy.Readings()["rtcm_degraded_dropped"], test.ShouldEqual, uint64(1))
+
+	corrupt := NewCorrectionDegrader(CorrectionDegradation{CorruptPercent: 100, Seed: 1})
+	corrupt.Degrade(frame, deliver)
+	test.That(t, len(delivered), test.ShouldEqual, 1)
+	test.That(t, delivered[0][0], test.ShouldEqual, rtcm3.FramePreamble)
+	test.That(t, CountRTCMFrames(delivered[0]), test.ShouldEqual, 0)
+	// the frame that was read isn't changed
+	test.That(t, CountRTCMFrames(frame), test.ShouldEqual, 1)
+	test.That(t, corrupt.Readings()["rtcm_degraded_corrupted"], test.ShouldEqual, uint64(1))
+}
+
+func TestCorrectionDegraderLatency(t *testing.T) {
+	frame := rtcm3.EncapsulateByteArray([]byte{0x3e, 0xd0, 0x01, 0x02, 0x03}).Serialize()
+	var wg sync.WaitGroup
+	wg.Add(1)
+	var delay time.Duration
+	start := time.Now()
+	NewCorrectionDegrader(CorrectionDegradation{Latency: 50 * time.Millisecond, Jitter: 10 * time.Millisecond}).Degrade(frame,
+		func([]byte) {
+			delay = time.Since(start)
+			wg.Done()
+		})
+	wg.Wait()
+	test.That(t, delay, test.ShouldBeGreaterThanOrEqualTo, 50*time.Millisecond)
+}
@@ -0,0 +1,65 @@
+package rtkutils
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// Range of the 7 bit i2c addresses devices can use, the others are reserved.
+const (
+	minI2CAddr = 0x08
+	maxI2CAddr = 0x77
+)
+
+// ValidateI2CAddr checks that addr, the value of field, is an address an i2c device can use.
+func ValidateI2CAddr(field string, addr int) error {
+	if addr < minI2CAddr || addr > maxI2CAddr {
+		return fmt.Errorf("%s %#x is not a valid i2c address, devices use %#x to %#x (u-blox receivers default to 0x42)",
+			field, addr, minI2CAddr, maxI2CAddr)
+	}
+	return nil
+}
+
+// I2CBusPath returns the device path of an i2c bus.
+func I2CBusPath(bus int) string {
+	return fmt.Sprintf("/dev/i2c-%d", bus)
+}
+
+// ValidateDevicePath checks that the device at path, the value of field, exists.
+func ValidateDevicePath(field, path string) error {
+	_, err := os.Stat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("%s %q doesn't exist, check that the device is connected, or set skip_device_check if it is only connected later",
+			field, path)
+	}
+	// a device that exists but can't be read fails with a clearer error when it is opened
+	return nil
+}
+
+// SameDevice reports whether paths a and b name the same device, following symlinks like the ones
+// in /dev/serial/by-id.
+func SameDevice(a, b string) bool {
+	infoA, errA := os.Stat(a)
+	infoB, errB := os.Stat(b)
+	if errA == nil && errB == nil {
+		return os.SameFile(infoA, infoB)
+	}
+	return filepath.Clean(a) == filepath.Clean(b)
+}
+
+// IsDeviceAbsent reports whether err is from a device that isn't there, like a serial port that
+// doesn't exist yet or an i2c receiver that doesn't acknowledge because it is powered off.
+func IsDeviceAbsent(err error) bool {
+	if errors.Is(err, fs.ErrNotExist) {
+		return true
+	}
+	for _, errno := range absentDeviceErrnos {
+		if errors.Is(err, errno) {
+			return true
+		}
+	}
+	return false
+}
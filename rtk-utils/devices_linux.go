@@ -0,0 +1,7 @@
+package rtkutils
+
+import "syscall"
+
+// absentDeviceErrnos are the errors of the drivers of devices that aren't there. A read from an
+// i2c address nothing acknowledges fails with EREMOTEIO.
+var absentDeviceErrnos = []syscall.Errno{syscall.ENODEV, syscall.ENXIO, syscall.EREMOTEIO}
@@ -0,0 +1,8 @@
+//go:build !linux
+
+package rtkutils
+
+import "syscall"
+
+// absentDeviceErrnos are the errors of the drivers of devices that aren't there.
+var absentDeviceErrnos = []syscall.Errno{syscall.ENODEV, syscall.ENXIO}
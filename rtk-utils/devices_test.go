@@ -0,0 +1,46 @@
+package rtkutils
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"go.viam.com/test"
+)
+
+func TestValidateI2CAddr(t *testing.T) {
+	test.That(t, ValidateI2CAddr("nmea_i2c_addr", 0x42), test.ShouldBeNil)
+	test.That(t, ValidateI2CAddr("nmea_i2c_addr", 0x78), test.ShouldNotBeNil)
+	test.That(t, ValidateI2CAddr("nmea_i2c_addr", 0x03), test.ShouldNotBeNil)
+	test.That(t, ValidateI2CAddr("nmea_i2c_addr", 200).Error(), test.ShouldContainSubstring, "nmea_i2c_addr 0xc8")
+}
+
+func TestDevicePaths(t *testing.T) {
+	dir := t.TempDir()
+	device := filepath.Join(dir, "ttyACM0")
+	test.That(t, os.WriteFile(device, nil, 0o600), test.ShouldBeNil)
+	link := filepath.Join(dir, "usb-u-blox")
+	test.That(t, os.Symlink(device, link), test.ShouldBeNil)
+
+	test.That(t, ValidateDevicePath("serial_path", device), test.ShouldBeNil)
+	err := ValidateDevicePath("serial_path", filepath.Join(dir, "ttyACM1"))
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "skip_device_check")
+
+	test.That(t, SameDevice(device, link), test.ShouldBeTrue)
+	test.That(t, SameDevice(device, filepath.Join(dir, ".", "ttyACM0")), test.ShouldBeTrue)
+	test.That(t, SameDevice("/dev/ttyUSB7", "/dev/ttyUSB7"), test.ShouldBeTrue)
+	test.That(t, SameDevice("/dev/ttyUSB7", "/dev/ttyUSB8"), test.ShouldBeFalse)
+}
+
+func TestIsDeviceAbsent(t *testing.T) {
+	_, err := os.Open(filepath.Join(t.TempDir(), "ttyACM0"))
+	test.That(t, IsDeviceAbsent(err), test.ShouldBeTrue)
+	test.That(t, IsDeviceAbsent(fmt.Errorf("read: %w", &fs.PathError{Op: "read", Path: "/dev/i2c-1", Err: syscall.ENXIO})),
+		test.ShouldBeTrue)
+	test.That(t, IsDeviceAbsent(&fs.PathError{Op: "open", Path: "/dev/ttyACM0", Err: fs.ErrPermission}), test.ShouldBeFalse)
+	test.That(t, IsDeviceAbsent(nil), test.ShouldBeFalse)
+}
@@ -0,0 +1,114 @@
+package rtkutils
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/go-gnss/rtcm/rtcm3"
+)
+
+const (
+	// how often the ephemeris of each satellite is relayed, so that a rover started cold has them all
+	// within half a minute of reading corrections
+	ephemerisRelayInterval = 30 * time.Second
+	// an ephemeris not read again for this long is of a satellite that set, and is no longer relayed.
+	// u-blox receivers output the subframes of a tracked satellite every 30 s.
+	ephemerisRelayMaxAge = 10 * time.Minute
+)
+
+// ValidateEphemerisRelay checks that the input of a station relaying ephemerides decodes them from
+// the subframes of its receiver.
+func ValidateEphemerisRelay(relay, ephemerisOutput bool) error {
+	if relay && !ephemerisOutput {
+		return errors.New("relay_ephemerides needs a u-blox receiver that outputs raw measurements on the input, " +
+			"other inputs forward the ephemerides of their stream as they are")
+	}
+	return nil
+}
+
+type relayedEphemeris struct {
+	eph   rtcm3.Message1019
+	frame []byte
+	read  time.Time // when it was last read from the stream
+	sent  time.Time // when it was last relayed, zero if it wasn't yet
+}
+
+// EphemerisRelay relays the GPS ephemerides a station decodes from the UBX-RXM-SFRBX subframes of its
+// u-blox receiver to the rovers as rtcm 1019 messages, since u-blox receivers can't output them, so
+// that rovers started cold in the field don't wait for the broadcast ephemerides to acquire a fix. A
+// new ephemeris is relayed as soon as it is decoded and each one is repeated every 30 s. A nil relay
+// relays nothing.
+type EphemerisRelay struct {
+	mu          sync.Mutex
+	ephemerides map[uint8]*relayedEphemeris
+	relayed     Counter
+}
+
+// NewEphemerisRelay returns a relay if enabled is set, nil otherwise.
+func NewEphemerisRelay(enabled bool) *EphemerisRelay {
+	if !enabled {
+		return nil
+	}
+	return &EphemerisRelay{ephemerides: map[uint8]*relayedEphemeris{}}
+}
+
+// Update reads a message of the station's stream, as a StreamDecoder handler.
+func (e *EphemerisRelay) Update(msg rtcm3.Message) {
+	if e == nil {
+		return
+	}
+	eph, ok := msg.(rtcm3.Message1019)
+	if !ok {
+		return
+	}
+	now := time.Now()
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if r, ok := e.ephemerides[eph.SatelliteID]; ok && r.eph.IODE == eph.IODE && r.eph.Toe == eph.Toe {
+		r.read = now
+		return
+	}
+	e.ephemerides[eph.SatelliteID] = &relayedEphemeris{
+		eph:   eph,
+		frame: rtcm3.EncapsulateMessage(eph).Serialize(),
+		read:  now,
+	}
+}
+
+// Due returns the frames of the ephemerides to relay at now, by satellite, and counts them as relayed.
+func (e *EphemerisRelay) Due(now time.Time) [][]byte {
+	if e == nil {
+		return nil
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	var svs []int
+	for sv, r := range e.ephemerides {
+		if now.Sub(r.read) > ephemerisRelayMaxAge {
+			delete(e.ephemerides, sv)
+			continue
+		}
+		if r.sent.IsZero() || now.Sub(r.sent) >= ephemerisRelayInterval {
+			svs = append(svs, int(sv))
+		}
+	}
+	sort.Ints(svs)
+	var frames [][]byte
+	for _, sv := range svs {
+		r := e.ephemerides[uint8(sv)]
+		r.sent = now
+		frames = append(frames, r.frame)
+	}
+	e.relayed.Add(uint64(len(frames)))
+	return frames
+}
+
+// Relayed returns the number of ephemeris frames relayed.
+func (e *EphemerisRelay) Relayed() uint64 {
+	if e == nil {
+		return 0
+	}
+	return e.relayed.Get()
+}
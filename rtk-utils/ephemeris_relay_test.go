@@ -0,0 +1,53 @@
+package rtkutils
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-gnss/rtcm/rtcm3"
+	"go.viam.com/test"
+)
+
+func TestValidateEphemerisRelay(t *testing.T) {
+	test.That(t, ValidateEphemerisRelay(false, false), test.ShouldBeNil)
+	test.That(t, ValidateEphemerisRelay(true, true), test.ShouldBeNil)
+	test.That(t, ValidateEphemerisRelay(true, false), test.ShouldBeError, errors.New(
+		"relay_ephemerides needs a u-blox receiver that outputs raw measurements on the input, "+
+			"other inputs forward the ephemerides of their stream as they are"))
+}
+
+func TestEphemerisRelay(t *testing.T) {
+	var none *EphemerisRelay
+	none.Update(testEphemeris(3))
+	test.That(t, none.Due(time.Now()), test.ShouldBeEmpty)
+	test.That(t, none.Relayed(), test.ShouldEqual, uint64(0))
+	test.That(t, NewEphemerisRelay(false), test.ShouldBeNil)
+
+	e := NewEphemerisRelay(true)
+	e.Update(rtcm3.Message1005{})
+	test.That(t, e.Due(time.Now()), test.ShouldBeEmpty)
+
+	e.Update(testEphemeris(12))
+	e.Update(testEphemeris(3))
+	now := time.Now()
+	frames := e.Due(now)
+	test.That(t, frames, test.ShouldResemble, [][]byte{
+		rtcm3.EncapsulateMessage(testEphemeris(3)).Serialize(),
+		rtcm3.EncapsulateMessage(testEphemeris(12)).Serialize(),
+	})
+	test.That(t, e.Due(now.Add(time.Second)), test.ShouldBeEmpty)
+
+	// the same ephemeris read again waits for the interval, a new one doesn't
+	e.Update(testEphemeris(3))
+	next := testEphemeris(12)
+	next.IODE = 1
+	e.Update(next)
+	frames = e.Due(now.Add(2 * time.Second))
+	test.That(t, frames, test.ShouldResemble, [][]byte{rtcm3.EncapsulateMessage(next).Serialize()})
+	test.That(t, e.Due(now.Add(ephemerisRelayInterval)), test.ShouldHaveLength, 1)
+	test.That(t, e.Relayed(), test.ShouldEqual, uint64(4))
+
+	// nor are the ephemerides of satellites that set
+	test.That(t, e.Due(now.Add(ephemerisRelayMaxAge+time.Minute)), test.ShouldBeEmpty)
+}
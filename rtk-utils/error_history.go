@@ -0,0 +1,225 @@
+package rtkutils
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrorsCommand returns the recent errors of a component, optionally only those of a "category".
+const ErrorsCommand = "errors"
+
+// Error categories.
+const (
+	ErrorSerial  = "serial"
+	ErrorI2C     = "i2c"
+	ErrorParse   = "parse"
+	ErrorRTCM    = "rtcm"
+	ErrorNetwork = "network"
+)
+
+// number of errors kept by an error history.
+const errorHistorySize = 50
+
+// ErrorRecord is an error of a component. Consecutive occurrences of the same error are counted
+// in a single record.
+type ErrorRecord struct {
+	Time     time.Time // of the last occurrence
+	Category string
+	Err      error
+	Count    int
+}
+
+// Paths errors are returned on. An error of one path isn't returned by the APIs of a component that
+// don't depend on it, e.g. a failing correction writer doesn't fail the position read from the receiver.
+const (
+	PathNMEARead        = "nmea_read"
+	PathCorrectionRead  = "correction_read"
+	PathCorrectionWrite = "correction_write"
+	PathI2CBus          = "i2c_bus" // a receiver both read and written on an i2c bus
+)
+
+// ErrorHistory keeps the last error returned by a component's API, like a movementsensor.LastError
+// of size 1 for each path, along with a history of recent errors by category, so that a transient
+// error doesn't hide a persistent one. Components with a single path use the empty one.
+type ErrorHistory struct {
+	mu      sync.Mutex
+	paths   map[string]*pathErrors
+	sets    uint64        // errors set so far, orders those of different paths
+	records []ErrorRecord // oldest to newest
+}
+
+type pathErrors struct {
+	last     error  // returned once by Get
+	lastSet  uint64 // sets when last was set
+	fatal    error  // returned by every Get once set
+	fatalSet uint64
+}
+
+// NewErrorHistory returns an empty error history.
+func NewErrorHistory() *ErrorHistory {
+	return &ErrorHistory{paths: map[string]*pathErrors{}}
+}
+
+// Set stores the result of an operation of category to be returned by Get, and records it if it failed.
+// A nil error clears the error to return.
+func (h *ErrorHistory) Set(category string, err error) {
+	h.SetPath("", category, err)
+}
+
+// SetPath is Set for an operation on path.
+func (h *ErrorHistory) SetPath(path, category string, err error) {
+	h.mu.Lock()
+	p := h.path(path)
+	h.sets++
+	p.last, p.lastSet = err, h.sets
+	h.mu.Unlock()
+	h.Record(category, err)
+}
+
+// SetFatal records err and returns it from every later Get, for errors a component doesn't recover from.
+func (h *ErrorHistory) SetFatal(category string, err error) {
+	h.SetFatalPath("", category, err)
+}
+
+// SetFatalPath is SetFatal for an operation on path.
+func (h *ErrorHistory) SetFatalPath(path, category string, err error) {
+	if err == nil {
+		return
+	}
+	h.Record(category, err)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	p := h.path(path)
+	h.sets++
+	p.fatal, p.fatalSet = err, h.sets
+}
+
+// Get returns the first fatal error of paths if there is one, otherwise the most recently set error
+// of paths, which is then cleared so that it is returned only once. Without paths, it returns the
+// errors of every path.
+func (h *ErrorHistory) Get(paths ...string) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if fatal := h.fatal(paths); fatal != nil {
+		return fatal.fatal
+	}
+	var latest *pathErrors
+	for name, p := range h.paths {
+		if includesPath(paths, name) && p.last != nil && (latest == nil || p.lastSet > latest.lastSet) {
+			latest = p
+		}
+	}
+	if latest == nil {
+		return nil
+	}
+	err := latest.last
+	latest.last = nil
+	return err
+}
+
+// Fatal returns the first fatal error of paths, or of every path without any, without clearing anything.
+func (h *ErrorHistory) Fatal(paths ...string) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if fatal := h.fatal(paths); fatal != nil {
+		return fatal.fatal
+	}
+	return nil
+}
+
+func (h *ErrorHistory) fatal(paths []string) *pathErrors {
+	var first *pathErrors
+	for name, p := range h.paths {
+		if includesPath(paths, name) && p.fatal != nil && (first == nil || p.fatalSet < first.fatalSet) {
+			first = p
+		}
+	}
+	return first
+}
+
+func (h *ErrorHistory) path(name string) *pathErrors {
+	p, ok := h.paths[name]
+	if !ok {
+		p = &pathErrors{}
+		h.paths[name] = p
+	}
+	return p
+}
+
+func includesPath(paths []string, name string) bool {
+	if len(paths) == 0 {
+		return true
+	}
+	for _, path := range paths {
+		if path == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Record adds err to the history without returning it from Get, for errors a component recovers from.
+// Nil errors and cancellations on shutdown are not recorded.
+func (h *ErrorHistory) Record(category string, err error) {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return
+	}
+	now := time.Now().UTC()
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if n := len(h.records); n > 0 {
+		last := &h.records[n-1]
+		if last.Category == category && last.Err.Error() == err.Error() {
+			last.Time, last.Err = now, err
+			last.Count++
+			return
+		}
+	}
+	h.records = append(h.records, ErrorRecord{Time: now, Category: category, Err: err, Count: 1})
+	if len(h.records) > errorHistorySize {
+		h.records = h.records[1:]
+	}
+}
+
+// Category returns the category err was last recorded with, or "" if it wasn't.
+func (h *ErrorHistory) Category(err error) string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for i := len(h.records) - 1; i >= 0; i-- {
+		if h.records[i].Err == err {
+			return h.records[i].Category
+		}
+	}
+	return ""
+}
+
+// Errors returns the recorded errors of category, or every error if it is empty.
+func (h *ErrorHistory) Errors(category string) []ErrorRecord {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	var records []ErrorRecord
+	for _, r := range h.records {
+		if category == "" || r.Category == category {
+			records = append(records, r)
+		}
+	}
+	return records
+}
+
+// ErrorsResult answers an ErrorsCommand.
+func (h *ErrorHistory) ErrorsResult(cmd map[string]interface{}) map[string]interface{} {
+	category, _ := cmd["category"].(string)
+	errs := []interface{}{}
+	for _, r := range h.Errors(category) {
+		errs = append(errs, map[string]interface{}{
+			"time":     r.Time.Format(time.RFC3339Nano),
+			"category": r.Category,
+			"error":    r.Err.Error(),
+			"count":    r.Count,
+		})
+	}
+	return map[string]interface{}{"errors": errs}
+}
@@ -0,0 +1,93 @@
+package rtkutils
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.viam.com/test"
+)
+
+func TestErrorHistory(t *testing.T) {
+	h := NewErrorHistory()
+	test.That(t, h.Get(), test.ShouldBeNil)
+
+	parseErr := errors.New("bad checksum")
+	h.Record(ErrorParse, parseErr)
+	h.Record(ErrorParse, errors.New("bad checksum"))
+	test.That(t, h.Get(), test.ShouldBeNil)
+
+	i2cErr := errors.New("i2c timeout")
+	h.Set(ErrorI2C, i2cErr)
+	h.Set(ErrorI2C, nil)
+	h.Set(ErrorI2C, context.Canceled)
+	h.Record(ErrorParse, parseErr)
+
+	// the transient i2c error doesn't hide the parse errors around it
+	errs := h.Errors("")
+	test.That(t, len(errs), test.ShouldEqual, 3)
+	test.That(t, errs[0].Category, test.ShouldEqual, ErrorParse)
+	test.That(t, errs[0].Count, test.ShouldEqual, 2)
+	test.That(t, errs[1].Err, test.ShouldEqual, i2cErr)
+	test.That(t, errs[2].Category, test.ShouldEqual, ErrorParse)
+	test.That(t, len(h.Errors(ErrorI2C)), test.ShouldEqual, 1)
+
+	for i := 0; i < errorHistorySize; i++ {
+		h.Set(ErrorSerial, errors.New(string(rune('a'+i%2))))
+	}
+	test.That(t, h.Get(), test.ShouldNotBeNil)
+	test.That(t, len(h.Errors("")), test.ShouldEqual, errorHistorySize)
+	test.That(t, len(h.Errors(ErrorParse)), test.ShouldEqual, 0)
+
+	resp := h.ErrorsResult(map[string]interface{}{"category": ErrorSerial})
+	records := resp["errors"].([]interface{})
+	test.That(t, len(records), test.ShouldEqual, errorHistorySize)
+	test.That(t, records[0].(map[string]interface{})["category"], test.ShouldEqual, ErrorSerial)
+	test.That(t, records[0].(map[string]interface{})["count"], test.ShouldEqual, 1)
+
+	// an error can be set again once Get returned one
+	h.Set(ErrorSerial, errors.New("reopened"))
+	test.That(t, h.Get(), test.ShouldNotBeNil)
+	test.That(t, h.Get(), test.ShouldBeNil)
+
+	reopened := errors.New("reopened again")
+	h.Set(ErrorI2C, reopened)
+	test.That(t, h.Category(reopened), test.ShouldEqual, ErrorI2C)
+	test.That(t, h.Category(errors.New("reopened again")), test.ShouldEqual, "")
+	test.That(t, h.Get(), test.ShouldEqual, reopened)
+
+	h.SetFatal(ErrorSerial, errors.New("no permission"))
+	h.Set(ErrorSerial, nil)
+	test.That(t, h.Get(), test.ShouldNotBeNil)
+	test.That(t, h.Get(), test.ShouldNotBeNil)
+}
+
+func TestErrorHistoryPaths(t *testing.T) {
+	h := NewErrorHistory()
+	writeErr := errors.New("write failed")
+	h.SetPath(PathCorrectionWrite, ErrorSerial, writeErr)
+	// the position read from the receiver isn't failed by the corrections
+	test.That(t, h.Get(PathNMEARead, PathI2CBus), test.ShouldBeNil)
+
+	readErr := errors.New("read failed")
+	h.SetPath(PathNMEARead, ErrorSerial, readErr)
+	test.That(t, h.Get(PathNMEARead, PathI2CBus), test.ShouldEqual, readErr)
+	test.That(t, h.Get(PathNMEARead, PathI2CBus), test.ShouldBeNil)
+	// the error of the other path is still returned once, by the APIs reading every path
+	test.That(t, h.Get(), test.ShouldEqual, writeErr)
+	test.That(t, h.Get(), test.ShouldBeNil)
+
+	h.SetPath(PathNMEARead, ErrorSerial, readErr)
+	h.SetPath(PathCorrectionRead, ErrorRTCM, writeErr)
+	test.That(t, h.Get(), test.ShouldEqual, writeErr)
+	test.That(t, h.Get(), test.ShouldEqual, readErr)
+
+	fatalErr := errors.New("no permission")
+	h.SetFatalPath(PathCorrectionWrite, ErrorSerial, fatalErr)
+	h.SetFatalPath(PathCorrectionRead, ErrorRTCM, errors.New("later"))
+	test.That(t, h.Get(PathNMEARead), test.ShouldBeNil)
+	test.That(t, h.Fatal(PathNMEARead), test.ShouldBeNil)
+	test.That(t, h.Get(), test.ShouldEqual, fatalErr)
+	test.That(t, h.Fatal(), test.ShouldEqual, fatalErr)
+	test.That(t, h.Get(PathCorrectionWrite), test.ShouldEqual, fatalErr)
+}
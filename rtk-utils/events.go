@@ -0,0 +1,75 @@
+package rtkutils
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/edaniels/golog"
+)
+
+// EventsCommand returns the events recorded by a component, optionally only those of a "type".
+const EventsCommand = "events"
+
+// number of events kept by an event log.
+const eventLogSize = 100
+
+// Event is something notable that happened to a component, kept for diagnostics and compliance logs.
+type Event struct {
+	Time    time.Time
+	Type    string
+	Message string
+}
+
+// EventLog keeps the most recent events of a component and logs each one as it is added.
+type EventLog struct {
+	logger golog.Logger
+
+	mu     sync.Mutex
+	events []Event // oldest to newest
+}
+
+// NewEventLog returns an empty event log writing to logger.
+func NewEventLog(logger golog.Logger) *EventLog {
+	return &EventLog{logger: logger}
+}
+
+// Add records an event of the given type.
+func (l *EventLog) Add(eventType, format string, args ...interface{}) {
+	e := Event{Time: time.Now().UTC(), Type: eventType, Message: fmt.Sprintf(format, args...)}
+	l.logger.Infof("%s: %s", e.Type, e.Message)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.events = append(l.events, e)
+	if len(l.events) > eventLogSize {
+		l.events = l.events[1:]
+	}
+}
+
+// Events returns the recorded events of eventType, or every event if it is empty.
+func (l *EventLog) Events(eventType string) []Event {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var events []Event
+	for _, e := range l.events {
+		if eventType == "" || e.Type == eventType {
+			events = append(events, e)
+		}
+	}
+	return events
+}
+
+// EventsResult answers an EventsCommand.
+func (l *EventLog) EventsResult(cmd map[string]interface{}) map[string]interface{} {
+	eventType, _ := cmd["type"].(string)
+	events := []interface{}{}
+	for _, e := range l.Events(eventType) {
+		events = append(events, map[string]interface{}{
+			"time":    e.Time.Format(time.RFC3339Nano),
+			"type":    e.Type,
+			"message": e.Message,
+		})
+	}
+	return map[string]interface{}{"events": events}
+}
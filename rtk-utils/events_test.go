@@ -0,0 +1,27 @@
+package rtkutils
+
+import (
+	"testing"
+
+	"github.com/edaniels/golog"
+	"go.viam.com/test"
+)
+
+func TestEventLog(t *testing.T) {
+	events := NewEventLog(golog.NewTestLogger(t))
+	test.That(t, events.EventsResult(map[string]interface{}{}), test.ShouldResemble,
+		map[string]interface{}{"events": []interface{}{}})
+
+	for i := 0; i < eventLogSize+1; i++ {
+		events.Add("tick", "tick %d", i)
+	}
+	events.Add("tock", "tock")
+
+	ticks := events.Events("tick")
+	test.That(t, ticks, test.ShouldHaveLength, eventLogSize-1)
+	test.That(t, ticks[0].Message, test.ShouldEqual, "tick 2")
+
+	result := events.EventsResult(map[string]interface{}{"type": "tock"})["events"].([]interface{})
+	test.That(t, result, test.ShouldHaveLength, 1)
+	test.That(t, result[0].(map[string]interface{})["message"], test.ShouldEqual, "tock")
+}
@@ -0,0 +1,107 @@
+package rtkutils
+
+import (
+	"hash/fnv"
+	"sync"
+	"time"
+)
+
+const (
+	// MSM messages of every constellation, 1071 to 1127, by tens
+	rtcmMSMFirst = 1071
+	rtcmMSMLast  = 1127
+
+	// a frame identical to one forwarded this recently is a duplicate. MSM frames hold their epoch so
+	// can't recur, the others such as 1005 legitimately repeat every second.
+	msmDuplicateWindow   = 10 * time.Second
+	otherDuplicateWindow = 500 * time.Millisecond
+	// an MSM epoch older than the newest forwarded by more than this is a new stream, e.g. a base
+	// that was restarted with a wrong clock, rather than a late frame
+	maxReorder = 30 * time.Second
+)
+
+type msmStream struct {
+	msgNum  int
+	station int
+}
+
+// FrameFilter drops the duplicated and late rtcm frames of corrections sent over unreliable
+// transports, such as radios with repeaters or udp, before they reach the receiver: frames identical
+// to one forwarded recently, and MSM observations of an epoch older than the newest one forwarded
+// for the same message and station.
+type FrameFilter struct {
+	mu         sync.Mutex
+	seen       map[uint64]time.Time // frames forwarded, by hash, until they can't be duplicated
+	newest     map[msmStream]uint32 // newest MSM epoch forwarded
+	duplicates Counter
+	outOfOrder Counter
+}
+
+// NewFrameFilter returns a filter that has seen no frames.
+func NewFrameFilter() *FrameFilter {
+	return &FrameFilter{seen: map[uint64]time.Time{}, newest: map[msmStream]uint32{}}
+}
+
+// Accept reports whether frame, read at now, is forwarded to the receiver, counting it if it isn't.
+func (f *FrameFilter) Accept(frame []byte, now time.Time) bool {
+	h := fnv.New64a()
+	h.Write(frame)
+	sum := h.Sum64()
+	stream, epoch, isMSM := msmEpoch(frame)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for s, expires := range f.seen {
+		if now.After(expires) {
+			delete(f.seen, s)
+		}
+	}
+	if _, ok := f.seen[sum]; ok {
+		f.duplicates.Add(1)
+		return false
+	}
+	window := otherDuplicateWindow
+	if isMSM {
+		if newest, ok := f.newest[stream]; ok && epochBefore(epoch, newest) {
+			f.outOfOrder.Add(1)
+			return false
+		}
+		f.newest[stream] = epoch
+		window = msmDuplicateWindow
+	}
+	f.seen[sum] = now.Add(window)
+	return true
+}
+
+// epochBefore reports whether the MSM epoch a is before b by less than maxReorder. Epochs further
+// apart, as across the end of the week where epoch times wrap, start a new stream.
+func epochBefore(a, b uint32) bool {
+	return a < b && b-a < uint32(maxReorder/time.Millisecond)
+}
+
+// Duplicates returns the number of duplicated frames dropped.
+func (f *FrameFilter) Duplicates() uint64 {
+	return f.duplicates.Get()
+}
+
+// OutOfOrder returns the number of frames dropped for arriving after a newer epoch.
+func (f *FrameFilter) OutOfOrder() uint64 {
+	return f.outOfOrder.Get()
+}
+
+// msmEpoch returns the message and station of an MSM frame with its raw epoch time, and false for
+// other frames.
+func msmEpoch(frame []byte) (msmStream, uint32, bool) {
+	// 3 header bytes, then 12 bits message number, 12 bits station id and 30 bits epoch time
+	if len(frame) < 3+7 {
+		return msmStream{}, 0, false
+	}
+	payload := frame[3:]
+	msgNum := int(payload[0])<<4 | int(payload[1])>>4
+	if msgNum < rtcmMSMFirst || msgNum > rtcmMSMLast || msgNum%10 == 0 || msgNum%10 > 7 {
+		return msmStream{}, 0, false
+	}
+	station := int(payload[1]&0x0F)<<8 | int(payload[2])
+	bits := uint32(payload[3])<<24 | uint32(payload[4])<<16 | uint32(payload[5])<<8 | uint32(payload[6])
+	return msmStream{msgNum: msgNum, station: station}, bits >> 2, true
+}
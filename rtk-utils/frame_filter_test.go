@@ -0,0 +1,48 @@
+package rtkutils
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-gnss/rtcm/rtcm3"
+	"go.viam.com/test"
+)
+
+func TestFrameFilter(t *testing.T) {
+	f := NewFrameFilter()
+	now := time.Now()
+
+	// a duplicated MSM frame is dropped, however late it arrives
+	test.That(t, f.Accept(gpsMSMFrame(100000), now), test.ShouldBeTrue)
+	test.That(t, f.Accept(gpsMSMFrame(100000), now.Add(2*time.Second)), test.ShouldBeFalse)
+	test.That(t, f.Duplicates(), test.ShouldEqual, uint64(1))
+
+	// as is an epoch older than the newest forwarded
+	test.That(t, f.Accept(gpsMSMFrame(101000), now), test.ShouldBeTrue)
+	test.That(t, f.Accept(gpsMSMFrame(100500), now), test.ShouldBeFalse)
+	test.That(t, f.OutOfOrder(), test.ShouldEqual, uint64(1))
+
+	// unless it is much older, as for a base restarted with another clock
+	test.That(t, f.Accept(gpsMSMFrame(1), now), test.ShouldBeTrue)
+
+	// across the end of the GPS week
+	test.That(t, f.Accept(gpsMSMFrame(gpsWeekMillis-1000), now), test.ShouldBeTrue)
+	test.That(t, f.Accept(gpsMSMFrame(0), now), test.ShouldBeTrue)
+	test.That(t, f.Accept(gpsMSMFrame(10), now), test.ShouldBeTrue)
+
+	// other messages legitimately repeat, only a repeat within a moment is a duplicate
+	position := rtcm3.EncapsulateByteArray([]byte{0x3e, 0xd0, 0x01, 0x02, 0x03}).Serialize()
+	test.That(t, f.Accept(position, now), test.ShouldBeTrue)
+	test.That(t, f.Accept(position, now.Add(100*time.Millisecond)), test.ShouldBeFalse)
+	test.That(t, f.Accept(position, now.Add(time.Second)), test.ShouldBeTrue)
+	test.That(t, f.Duplicates(), test.ShouldEqual, uint64(2))
+	test.That(t, f.OutOfOrder(), test.ShouldEqual, uint64(1))
+}
+
+func TestEpochBefore(t *testing.T) {
+	test.That(t, epochBefore(1000, 2000), test.ShouldBeTrue)
+	test.That(t, epochBefore(2000, 2000), test.ShouldBeFalse)
+	test.That(t, epochBefore(2000, 1000), test.ShouldBeFalse)
+	test.That(t, epochBefore(0, 60000), test.ShouldBeFalse)
+	test.That(t, epochBefore(gpsWeekMillis-1000, 10), test.ShouldBeFalse)
+}
@@ -0,0 +1,64 @@
+package rtkutils
+
+import (
+	"bufio"
+	"io"
+
+	"github.com/go-gnss/rtcm/rtcm3"
+)
+
+// maxRTCMFrame is the length of the longest RTCM3 frame: 3 header bytes, up to 1023 payload bytes
+// and 3 CRC bytes.
+const maxRTCMFrame = 3 + 1023 + 3
+
+// FrameReader reads the RTCM3 frames with a valid CRC from a stream, skipping everything else.
+// Frames are returned as they were read, without decoding and encoding them again, in a buffer
+// that is reused for every frame.
+type FrameReader struct {
+	buf   *bufio.Reader
+	frame []byte
+}
+
+// NewFrameReader returns a reader of the frames in r.
+func NewFrameReader(r io.Reader) *FrameReader {
+	return &FrameReader{buf: bufio.NewReaderSize(r, 2*maxRTCMFrame), frame: make([]byte, 0, maxRTCMFrame)}
+}
+
+// Next returns the next frame. It is only valid until the next call to Next.
+func (r *FrameReader) Next() ([]byte, error) {
+	for {
+		b, err := r.buf.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		if b != rtcm3.FramePreamble {
+			continue
+		}
+		header, err := r.buf.Peek(2)
+		if err != nil {
+			continue
+		}
+		length := (int(header[0])&0x03)<<8 | int(header[1])
+		rest, err := r.buf.Peek(2 + length + 3)
+		if err != nil {
+			// too short to be a frame, keep scanning what is left of the stream
+			continue
+		}
+
+		r.frame = append(append(r.frame[:0], b), rest...)
+		end := len(r.frame)
+		crc := uint32(r.frame[end-3])<<16 | uint32(r.frame[end-2])<<8 | uint32(r.frame[end-1])
+		if rtcm3.Crc24q(r.frame[:end-3]) != crc {
+			continue
+		}
+		if _, err := r.buf.Discard(len(rest)); err != nil {
+			return nil, err
+		}
+		return r.frame, nil
+	}
+}
+
+// Reset discards the buffered bytes and reads from src instead.
+func (r *FrameReader) Reset(src io.Reader) {
+	r.buf.Reset(src)
+}
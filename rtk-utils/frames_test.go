@@ -0,0 +1,55 @@
+package rtkutils
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/go-gnss/rtcm/rtcm3"
+	"go.viam.com/test"
+)
+
+func TestFrameReader(t *testing.T) {
+	frame := rtcm3.EncapsulateByteArray([]byte{0x3e, 0xd0, 0x01, 0x02, 0x03}).Serialize()
+	corrupted := append([]byte{}, frame...)
+	corrupted[4] ^= 0x01
+
+	// padding, a stray preamble, a corrupted frame, two whole frames and a partial one
+	stream := append([]byte{0xff, rtcm3.FramePreamble, 0x00}, corrupted...)
+	stream = append(stream, frame...)
+	stream = append(stream, frame...)
+	stream = append(stream, frame[:4]...)
+
+	r := NewFrameReader(bytes.NewReader(stream))
+	got, err := r.Next()
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, got, test.ShouldResemble, frame)
+	got, err = r.Next()
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, got, test.ShouldResemble, frame)
+	_, err = r.Next()
+	test.That(t, err, test.ShouldEqual, io.EOF)
+
+	// frames are read in place, without allocating
+	r.Reset(bytes.NewReader(bytes.Repeat(frame, 10)))
+	allocs := testing.AllocsPerRun(9, func() {
+		if _, err := r.Next(); err != nil {
+			t.Fatal(err)
+		}
+	})
+	test.That(t, allocs, test.ShouldEqual, 0)
+}
+
+func BenchmarkFrameReader(b *testing.B) {
+	frame := rtcm3.EncapsulateByteArray(make([]byte, 600)).Serialize()
+	stream := bytes.NewReader(bytes.Repeat(frame, 100))
+	r := NewFrameReader(stream)
+	b.ReportAllocs()
+	b.SetBytes(int64(len(frame)))
+	for i := 0; i < b.N; i++ {
+		if _, err := r.Next(); err == io.EOF {
+			stream.Seek(0, io.SeekStart)
+			r.Reset(stream)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package rtkutils
+
+import (
+	"sync"
+	"time"
+)
+
+// GLONASS code-phase bias event types.
+const (
+	EventGLONASSBiasesMissing  = "glonass_biases_missing"
+	EventGLONASSBiasesReceived = "glonass_biases_received"
+)
+
+const (
+	// message number of the GLONASS code-phase biases
+	rtcmGLONASSBiases = 1230
+	// how long GLONASS MSM have to come without code-phase biases for them to be missing. Bases send
+	// them every 5 to 30 seconds.
+	glonassBiasWindow = time.Minute
+)
+
+// GLONASSBiasCheck follows whether a correction stream with GLONASS MSM observations also has the
+// GLONASS code-phase biases of the base (1230). Without them, receivers of another make than the base
+// can't resolve the GLONASS ambiguities, and many only reach a float solution. It raises an event when
+// GLONASS MSM have come for a minute without biases, and another once biases come. The zero value is
+// ready to use and safe for concurrent use.
+type GLONASSBiasCheck struct {
+	mu       sync.Mutex
+	msmSince time.Time // first GLONASS MSM of the current stream of them
+	lastMSM  time.Time
+	lastBias time.Time
+	missing  bool
+}
+
+// Frame takes an rtcm frame of the stream read at now, adding an event to events when the biases go
+// missing or come again.
+func (c *GLONASSBiasCheck) Frame(frame []byte, now time.Time, events *EventLog) {
+	stream, _, msm := msmEpoch(frame)
+	biases := !msm && len(frame) >= 3+2+3 && int(frame[3])<<4|int(frame[4])>>4 == rtcmGLONASSBiases
+	if !biases && (!msm || stream.msgNum/10 != 108) {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if biases {
+		c.lastBias = now
+		if c.missing {
+			c.missing = false
+			events.Add(EventGLONASSBiasesReceived, "GLONASS code-phase biases (1230) received")
+		}
+		return
+	}
+	if c.lastMSM.IsZero() || now.Sub(c.lastMSM) > glonassBiasWindow {
+		c.msmSince = now
+	}
+	c.lastMSM = now
+	if !c.missing && c.biasesMissing(now) {
+		c.missing = true
+		events.Add(EventGLONASSBiasesMissing, "the corrections have had GLONASS MSM without code-phase biases (1230) "+
+			"for %s, receivers may only reach a float solution; enable 1230 on the base or disable GLONASS", glonassBiasWindow)
+	}
+}
+
+// biasesMissing returns whether the GLONASS MSM read up to now come without biases. It must be
+// called with mu held.
+func (c *GLONASSBiasCheck) biasesMissing(now time.Time) bool {
+	return c.glonass(now) && now.Sub(c.msmSince) >= glonassBiasWindow &&
+		(c.lastBias.IsZero() || now.Sub(c.lastBias) > glonassBiasWindow)
+}
+
+// glonass returns whether GLONASS MSM were read lately. It must be called with mu held.
+func (c *GLONASSBiasCheck) glonass(now time.Time) bool {
+	return !c.lastMSM.IsZero() && now.Sub(c.lastMSM) <= glonassBiasWindow
+}
+
+// Readings returns whether the stream had GLONASS MSM and code-phase biases lately, and whether the
+// biases are missing from it at now.
+func (c *GLONASSBiasCheck) Readings(now time.Time) map[string]interface{} {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return map[string]interface{}{
+		"glonass_msm":            c.glonass(now),
+		"glonass_biases":         !c.lastBias.IsZero() && now.Sub(c.lastBias) <= glonassBiasWindow,
+		"glonass_biases_missing": c.biasesMissing(now),
+	}
+}
@@ -0,0 +1,56 @@
+package rtkutils
+
+import (
+	"testing"
+	"time"
+
+	"github.com/edaniels/golog"
+	"github.com/go-gnss/rtcm/rtcm3"
+	"go.viam.com/test"
+)
+
+func TestGLONASSBiasCheck(t *testing.T) {
+	events := NewEventLog(golog.NewTestLogger(t))
+	glonassMSM := rtcm3.EncapsulateByteArray([]byte{0x43, 0xC0, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00}).Serialize()
+	biases := rtcm3.EncapsulateByteArray([]byte{0x4C, 0xE0, 0x01, 0x00}).Serialize()
+	now := time.Now()
+
+	var c GLONASSBiasCheck
+	test.That(t, c.Readings(now), test.ShouldResemble, map[string]interface{}{
+		"glonass_msm": false, "glonass_biases": false, "glonass_biases_missing": false,
+	})
+	// gps alone needs no biases
+	for i := 0; i < 120; i++ {
+		c.Frame(gpsMSMFrame(int64(i)*1000), now.Add(time.Duration(i)*time.Second), events)
+	}
+	test.That(t, c.Readings(now.Add(2 * time.Minute))["glonass_msm"], test.ShouldBeFalse)
+
+	// glonass with biases every 10 seconds
+	now = now.Add(2 * time.Minute)
+	for i := 0; i < 120; i++ {
+		if i%10 == 0 {
+			c.Frame(biases, now, events)
+		}
+		c.Frame(glonassMSM, now, events)
+		now = now.Add(time.Second)
+	}
+	test.That(t, c.Readings(now), test.ShouldResemble, map[string]interface{}{
+		"glonass_msm": true, "glonass_biases": true, "glonass_biases_missing": false,
+	})
+	test.That(t, events.Events(""), test.ShouldBeEmpty)
+
+	// and without them
+	for i := 0; i < 120; i++ {
+		c.Frame(glonassMSM, now, events)
+		now = now.Add(time.Second)
+	}
+	test.That(t, c.Readings(now)["glonass_biases_missing"], test.ShouldBeTrue)
+	missing := events.Events(EventGLONASSBiasesMissing)
+	test.That(t, missing, test.ShouldHaveLength, 1)
+	test.That(t, missing[0].Message, test.ShouldEqual, "the corrections have had GLONASS MSM without code-phase "+
+		"biases (1230) for 1m0s, receivers may only reach a float solution; enable 1230 on the base or disable GLONASS")
+
+	c.Frame(biases, now, events)
+	test.That(t, c.Readings(now)["glonass_biases_missing"], test.ShouldBeFalse)
+	test.That(t, events.Events(EventGLONASSBiasesReceived), test.ShouldHaveLength, 1)
+}
@@ -0,0 +1,109 @@
+package rtkutils
+
+import (
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Heading is the direction of travel reported during a single epoch, in degrees clockwise from true north.
+type Heading struct {
+	Degrees float64
+	// DualAntenna is true when the heading was measured between two antennas (HDT or THS) rather
+	// than derived from the course over ground (RMC or VTG), which is only meaningful while moving.
+	DualAntenna bool
+	Valid       bool
+}
+
+// update keeps the heading reported by line, preferring a dual antenna heading over the course
+// over ground. It reports whether line is a heading-only sentence the nmea parser does not know.
+func (h *Heading) update(line string) (headingOnly bool) {
+	ind := strings.Index(line, "$G")
+	if ind == -1 {
+		return false
+	}
+	line = line[ind:]
+	if star := strings.IndexByte(line, '*'); star != -1 {
+		line = line[:star]
+	}
+	fields := strings.Split(strings.TrimSpace(line), ",")
+	if len(fields[0]) < 6 {
+		return false
+	}
+
+	var (
+		value       string
+		dualAntenna bool
+	)
+	switch fields[0][3:] {
+	case "HDT":
+		headingOnly, dualAntenna = true, true
+		value = field(fields, 1)
+	case "THS":
+		// mode V means the heading is not valid
+		headingOnly, dualAntenna = true, true
+		if field(fields, 2) != "V" {
+			value = field(fields, 1)
+		}
+	case "RMC":
+		value = field(fields, 8)
+	case "VTG":
+		value = field(fields, 1)
+	default:
+		return false
+	}
+
+	if value == "" || (h.Valid && h.DualAntenna && !dualAntenna) {
+		return headingOnly
+	}
+	degrees, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return headingOnly
+	}
+	*h = Heading{Degrees: NormalizeHeading(degrees), DualAntenna: dualAntenna, Valid: true}
+	return headingOnly
+}
+
+func field(fields []string, i int) string {
+	if i >= len(fields) {
+		return ""
+	}
+	return fields[i]
+}
+
+// NormalizeHeading wraps a heading in degrees into [0, 360).
+func NormalizeHeading(degrees float64) float64 {
+	degrees = math.Mod(degrees, 360)
+	if degrees < 0 {
+		degrees += 360
+	}
+	// a tiny negative heading rounds to 360 once wrapped
+	if degrees == 360 {
+		degrees = 0
+	}
+	return degrees
+}
+
+// Keys of the readings that report a heading in both conventions, since consumers expecting the
+// other one is a perennial source of sign bugs.
+const (
+	HeadingCompassKey = "heading_compass_deg" // [0, 360), clockwise from north
+	HeadingMathKey    = "heading_math_deg"    // (-180, 180], counterclockwise from east
+)
+
+// MathHeading converts a compass heading to the mathematical convention, in degrees in (-180, 180]
+// counterclockwise from east.
+func MathHeading(compass float64) float64 {
+	degrees := NormalizeHeading(90 - compass)
+	if degrees > 180 {
+		degrees -= 360
+	}
+	return degrees
+}
+
+// AddHeadingReadings adds the compass heading to readings in both conventions, NaN in both without
+// a heading.
+func AddHeadingReadings(readings map[string]interface{}, compass float64) {
+	readings[HeadingCompassKey] = compass
+	readings[HeadingMathKey] = MathHeading(compass)
+}
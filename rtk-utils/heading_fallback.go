@@ -0,0 +1,110 @@
+package rtkutils
+
+import (
+	"context"
+	"math"
+	"sync"
+
+	"go.viam.com/rdk/components/movementsensor"
+)
+
+// PropertiesCommand returns what a rover can report and where its compass heading comes from,
+// beyond the flags of movementsensor.Properties.
+const PropertiesCommand = "properties"
+
+// Sources of a rover's compass heading.
+const (
+	HeadingSourceDualAntenna      = "dual_antenna"
+	HeadingSourceAttitude         = "attitude"
+	HeadingSourceCourseOverGround = "course_over_ground"
+	HeadingSourceSensor           = "heading_sensor"
+	HeadingSourceHeld             = "held"
+)
+
+// ReceiverHeadings are the headings a receiver reports besides a dual antenna heading, which it
+// reports whenever it has one.
+type ReceiverHeadings struct {
+	CourseOverGround bool // its course over ground, unless it is disabled
+	Attitude         bool // the heading of its dead reckoning attitude
+}
+
+// HeadingFallback supplies a compass heading while the receiver has none, which is whenever a
+// single antenna rover stands still: the navigation service needs one before it sets off. The
+// heading of another movement sensor, such as an rtk-heading pair or a compass, is used first, then
+// the last heading of the receiver if it is held.
+type HeadingFallback struct {
+	sensor   movementsensor.MovementSensor
+	hold     bool
+	receiver ReceiverHeadings
+
+	mu          sync.Mutex
+	last        float64
+	hasLast     bool
+	dualAntenna bool // a dual antenna heading was seen
+}
+
+// NewHeadingFallback returns a fallback to sensor, if it isn't nil, then to the last heading if hold
+// is set. With neither it supplies no heading, but still tracks the sources of the receiver's.
+func NewHeadingFallback(sensor movementsensor.MovementSensor, hold bool, receiver ReceiverHeadings) *HeadingFallback {
+	return &HeadingFallback{sensor: sensor, hold: hold, receiver: receiver}
+}
+
+// Update keeps the heading in degrees the receiver reported for an epoch, if it is valid and not a
+// course over ground that is disabled.
+func (f *HeadingFallback) Update(h Heading, degrees float64) {
+	if !h.Valid || !h.DualAntenna && !f.receiver.CourseOverGround {
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.last, f.hasLast = degrees, true
+	f.dualAntenna = f.dualAntenna || h.DualAntenna
+}
+
+// Heading returns the fallback heading in degrees and its source, NaN and an empty source if there
+// is none.
+func (f *HeadingFallback) Heading(ctx context.Context) (float64, string) {
+	if f.sensor != nil {
+		// extra isn't passed on, an epoch pinned on the rover means nothing to another sensor
+		if degrees, err := f.sensor.CompassHeading(ctx, nil); err == nil && !math.IsNaN(degrees) {
+			return degrees, HeadingSourceSensor
+		}
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.hold && f.hasLast {
+		return f.last, HeadingSourceHeld
+	}
+	return math.NaN(), ""
+}
+
+// Sources returns the sources a rover's compass heading can come from, the receiver's own first.
+func (f *HeadingFallback) Sources() []string {
+	var sources []string
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.dualAntenna {
+		sources = append(sources, HeadingSourceDualAntenna)
+	}
+	if f.receiver.Attitude {
+		sources = append(sources, HeadingSourceAttitude)
+	}
+	if f.receiver.CourseOverGround {
+		sources = append(sources, HeadingSourceCourseOverGround)
+	}
+	if f.sensor != nil {
+		sources = append(sources, HeadingSourceSensor)
+	}
+	if f.hold {
+		sources = append(sources, HeadingSourceHeld)
+	}
+	return sources
+}
+
+// Supported returns whether a rover can report a compass heading at all. A held heading alone
+// isn't one, it only holds the headings of the other sources.
+func (f *HeadingFallback) Supported() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.dualAntenna || f.receiver.CourseOverGround || f.receiver.Attitude || f.sensor != nil
+}
@@ -0,0 +1,78 @@
+package rtkutils
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+
+	"go.viam.com/rdk/components/movementsensor"
+	"go.viam.com/test"
+)
+
+// fakeCompass is a movement sensor with only a compass heading.
+type fakeCompass struct {
+	movementsensor.MovementSensor
+	heading func(ctx context.Context, extra map[string]interface{}) (float64, error)
+}
+
+func (c *fakeCompass) CompassHeading(ctx context.Context, extra map[string]interface{}) (float64, error) {
+	return c.heading(ctx, extra)
+}
+
+func TestHeadingFallback(t *testing.T) {
+	ctx := context.Background()
+
+	none := NewHeadingFallback(nil, false, ReceiverHeadings{CourseOverGround: true})
+	none.Update(Heading{Degrees: 90, Valid: true}, 90)
+	degrees, source := none.Heading(ctx)
+	test.That(t, math.IsNaN(degrees), test.ShouldBeTrue)
+	test.That(t, source, test.ShouldEqual, "")
+	test.That(t, none.Sources(), test.ShouldResemble, []string{HeadingSourceCourseOverGround})
+	test.That(t, none.Supported(), test.ShouldBeTrue)
+
+	// the last heading is held once there is one
+	held := NewHeadingFallback(nil, true, ReceiverHeadings{CourseOverGround: true})
+	degrees, _ = held.Heading(ctx)
+	test.That(t, math.IsNaN(degrees), test.ShouldBeTrue)
+	held.Update(Heading{Degrees: 90, Valid: true}, 95)
+	held.Update(Heading{}, 0)
+	degrees, source = held.Heading(ctx)
+	test.That(t, degrees, test.ShouldEqual, 95)
+	test.That(t, source, test.ShouldEqual, HeadingSourceHeld)
+
+	// the sensor comes first, unless it has no heading
+	compassErr := errors.New("not calibrated")
+	compass := &fakeCompass{heading: func(ctx context.Context, extra map[string]interface{}) (float64, error) {
+		test.That(t, extra, test.ShouldBeNil)
+		return 270, compassErr
+	}}
+	both := NewHeadingFallback(compass, true, ReceiverHeadings{CourseOverGround: true})
+	both.Update(Heading{Degrees: 10, DualAntenna: true, Valid: true}, 10)
+	degrees, source = both.Heading(ctx)
+	test.That(t, degrees, test.ShouldEqual, 10)
+	test.That(t, source, test.ShouldEqual, HeadingSourceHeld)
+	compassErr = nil
+	degrees, source = both.Heading(ctx)
+	test.That(t, degrees, test.ShouldEqual, 270)
+	test.That(t, source, test.ShouldEqual, HeadingSourceSensor)
+	test.That(t, both.Sources(), test.ShouldResemble, []string{
+		HeadingSourceDualAntenna, HeadingSourceCourseOverGround, HeadingSourceSensor, HeadingSourceHeld,
+	})
+
+	// without a course over ground only a dual antenna heading is kept, and reported once it is seen
+	dual := NewHeadingFallback(nil, true, ReceiverHeadings{})
+	test.That(t, dual.Supported(), test.ShouldBeFalse)
+	test.That(t, dual.Sources(), test.ShouldResemble, []string{HeadingSourceHeld})
+	dual.Update(Heading{Degrees: 90, Valid: true}, 90)
+	degrees, _ = dual.Heading(ctx)
+	test.That(t, math.IsNaN(degrees), test.ShouldBeTrue)
+	dual.Update(Heading{Degrees: 45, DualAntenna: true, Valid: true}, 45)
+	degrees, _ = dual.Heading(ctx)
+	test.That(t, degrees, test.ShouldEqual, 45)
+	test.That(t, dual.Supported(), test.ShouldBeTrue)
+
+	attitude := NewHeadingFallback(nil, false, ReceiverHeadings{CourseOverGround: true, Attitude: true})
+	test.That(t, attitude.Supported(), test.ShouldBeTrue)
+	test.That(t, attitude.Sources(), test.ShouldResemble, []string{HeadingSourceAttitude, HeadingSourceCourseOverGround})
+}
@@ -0,0 +1,54 @@
+package rtkutils
+
+import (
+	"math"
+	"testing"
+
+	"go.viam.com/test"
+)
+
+const (
+	testRMC = "$GNRMC,172814.00,A,3723.46587704,N,12202.26957864,W,0.5,54.7,191194,,,A*65"
+	testHDT = "$GNHDT,123.4,T*2F"
+	testTHS = "$GNTHS,,V*10"
+)
+
+func TestHeading(t *testing.T) {
+	var h Heading
+	test.That(t, h.update(testGSAEpoch1), test.ShouldBeFalse)
+	test.That(t, h.Valid, test.ShouldBeFalse)
+
+	test.That(t, h.update(testRMC), test.ShouldBeFalse)
+	test.That(t, h, test.ShouldResemble, Heading{Degrees: 54.7, Valid: true})
+
+	// a dual antenna heading replaces the course over ground and is not replaced by it
+	test.That(t, h.update(testHDT), test.ShouldBeTrue)
+	test.That(t, h, test.ShouldResemble, Heading{Degrees: 123.4, DualAntenna: true, Valid: true})
+	h.update(testRMC)
+	test.That(t, h.Degrees, test.ShouldEqual, 123.4)
+
+	// invalid dual antenna headings are ignored
+	h = Heading{}
+	test.That(t, h.update(testTHS), test.ShouldBeTrue)
+	test.That(t, h.Valid, test.ShouldBeFalse)
+}
+
+func TestNormalizeHeading(t *testing.T) {
+	test.That(t, NormalizeHeading(90), test.ShouldEqual, 90)
+	test.That(t, NormalizeHeading(370), test.ShouldEqual, 10)
+	test.That(t, NormalizeHeading(-10), test.ShouldEqual, 350)
+	test.That(t, NormalizeHeading(360), test.ShouldEqual, 0)
+}
+
+func TestMathHeading(t *testing.T) {
+	test.That(t, MathHeading(0), test.ShouldEqual, 90)
+	test.That(t, MathHeading(90), test.ShouldEqual, 0)
+	test.That(t, MathHeading(180), test.ShouldEqual, -90)
+	test.That(t, MathHeading(270), test.ShouldEqual, 180)
+	test.That(t, MathHeading(350), test.ShouldEqual, 100)
+	test.That(t, math.IsNaN(MathHeading(math.NaN())), test.ShouldBeTrue)
+
+	readings := map[string]interface{}{}
+	AddHeadingReadings(readings, 135)
+	test.That(t, readings, test.ShouldResemble, map[string]interface{}{HeadingCompassKey: 135., HeadingMathKey: -45.})
+}
@@ -0,0 +1,18 @@
+package rtkutils
+
+import (
+	"fmt"
+	"time"
+)
+
+// HeartbeatInterval is how often a station sends its status line to its heartbeat outputs.
+const HeartbeatInterval = time.Second
+
+// HeartbeatSentence returns the status line a station sends to its heartbeat outputs, e.g.
+// $PRTKHB,2026-10-16T12:00:00Z,surveying,0*78: the UTC time it was sent, the state of the station,
+// surveying while its base surveys its position, and the bytes of corrections it sent so far. It is
+// a proprietary NMEA sentence, plain ASCII with a checksum, so that tools such as screen or netcat
+// show that a base is alive without an RTCM decoder.
+func HeartbeatSentence(now time.Time, state string, bytesSent uint64) ([]byte, error) {
+	return NMEASentence(fmt.Sprintf("PRTKHB,%s,%s,%d", now.UTC().Format(time.RFC3339), state, bytesSent))
+}
@@ -0,0 +1,19 @@
+package rtkutils
+
+import (
+	"testing"
+	"time"
+
+	"go.viam.com/test"
+)
+
+func TestHeartbeatSentence(t *testing.T) {
+	now := time.Date(2026, 10, 16, 14, 0, 0, 0, time.FixedZone("CEST", 2*60*60))
+	sentence, err := HeartbeatSentence(now, StateSurveying, 0)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, string(sentence), test.ShouldEqual, "$PRTKHB,2026-10-16T12:00:00Z,surveying,0*78\r\n")
+
+	sentence, err = HeartbeatSentence(now, StateHealthy, 1024)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, string(sentence), test.ShouldStartWith, "$PRTKHB,2026-10-16T12:00:00Z,healthy,1024*")
+}
@@ -0,0 +1,86 @@
+package rtkutils
+
+import (
+	"errors"
+	"sync"
+)
+
+const (
+	// inputCheckBytes is how much input without an RTCM frame is judged, a few seconds of a port
+	// sending NMEA.
+	inputCheckBytes = 4096
+	// the least sentences or messages in that much input for it to be taken as NMEA or UBX
+	inputCheckNMEA = 5
+	inputCheckUBX  = 3
+)
+
+// Mismatches of an input.
+var (
+	ErrInputNMEA = errors.New("input appears to be NMEA — check which UART is connected")
+	ErrInputUBX  = errors.New("input appears to be UBX — check which UART is connected")
+)
+
+// InputCheck recognizes a correction input that sends something other than RTCM, most often the NMEA
+// or UBX port of a receiver wired in place of the port it sends corrections on. The bytes of the
+// input are written to it as they are read, and it is told of every RTCM frame found in them. A
+// mismatch is reported once until the input sends a frame again.
+type InputCheck struct {
+	allowUBX bool // the receiver of the input is set up to send ubx messages along with the rtcm
+	report   func(error)
+
+	mu       sync.Mutex
+	unframed int // bytes since the last frame
+	nmea     int
+	ubx      int
+	prev     byte
+	err      error
+}
+
+// NewInputCheck returns a check calling report with a mismatch. UBX isn't a mismatch if allowUBX is set.
+func NewInputCheck(allowUBX bool, report func(error)) *InputCheck {
+	return &InputCheck{allowUBX: allowUBX, report: report}
+}
+
+// Write counts the NMEA sentences and UBX messages that start in p.
+func (c *InputCheck) Write(p []byte) (int, error) {
+	c.mu.Lock()
+	for _, b := range p {
+		switch {
+		case c.prev == '$' && (b == 'G' || b == 'B' || b == 'P'):
+			c.nmea++
+		case c.prev == ubxSync1 && b == ubxSync2:
+			c.ubx++
+		}
+		c.prev = b
+	}
+	c.unframed += len(p)
+	var mismatch error
+	if c.err == nil && c.unframed >= inputCheckBytes {
+		switch {
+		case c.nmea >= inputCheckNMEA:
+			c.err = ErrInputNMEA
+		case c.ubx >= inputCheckUBX && !c.allowUBX:
+			c.err = ErrInputUBX
+		}
+		mismatch = c.err
+	}
+	c.mu.Unlock()
+	if mismatch != nil && c.report != nil {
+		c.report(mismatch)
+	}
+	return len(p), nil
+}
+
+// Frame notes that an RTCM frame was read, which starts judging the input afresh.
+func (c *InputCheck) Frame() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.unframed, c.nmea, c.ubx, c.err = 0, 0, 0, nil
+}
+
+// Err returns the mismatch of the input since its last frame, if any.
+func (c *InputCheck) Err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
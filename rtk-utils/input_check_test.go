@@ -0,0 +1,48 @@
+package rtkutils
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/go-gnss/rtcm/rtcm3"
+	"go.viam.com/test"
+)
+
+func TestInputCheck(t *testing.T) {
+	var reported []error
+	check := NewInputCheck(false, func(err error) { reported = append(reported, err) })
+	nmea := []byte(testGGAEpoch1 + "\r\n")
+
+	// the input is only judged once enough of it came without a frame
+	check.Write(nmea)
+	test.That(t, check.Err(), test.ShouldBeNil)
+	for i := 0; i < inputCheckBytes/len(nmea)+1; i++ {
+		check.Write(nmea)
+	}
+	test.That(t, check.Err(), test.ShouldBeError, errors.New("input appears to be NMEA — check which UART is connected"))
+	check.Write(nmea)
+	test.That(t, reported, test.ShouldHaveLength, 1)
+
+	// a frame starts over
+	check.Frame()
+	test.That(t, check.Err(), test.ShouldBeNil)
+
+	sfrbx := UBXPacket(UBXClassRxm, UBXRxmSfrbx, make([]byte, 40))
+	check.Write(bytes.Repeat(sfrbx, inputCheckBytes/len(sfrbx)+1))
+	test.That(t, check.Err(), test.ShouldBeError, errors.New("input appears to be UBX — check which UART is connected"))
+	test.That(t, reported, test.ShouldHaveLength, 2)
+
+	// a receiver sending its subframes along with rtcm is fine, as is a stream of frames
+	check = NewInputCheck(true, nil)
+	check.Write(bytes.Repeat(sfrbx, inputCheckBytes/len(sfrbx)+1))
+	test.That(t, check.Err(), test.ShouldBeNil)
+
+	check = NewInputCheck(false, nil)
+	frame := rtcm3.EncapsulateByteArray(make([]byte, 600)).Serialize()
+	for i := 0; i < 20; i++ {
+		check.Write(frame)
+		check.Frame()
+	}
+	test.That(t, check.Err(), test.ShouldBeNil)
+}
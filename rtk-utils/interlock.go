@@ -0,0 +1,146 @@
+package rtkutils
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// InterlockCommand returns whether a rover's position is good enough to navigate on, with the
+// reasons it isn't and the values it was judged on.
+const InterlockCommand = "interlock"
+
+// Minimum fixes of an interlock, from the strictest.
+const (
+	InterlockFixFixed = "fixed" // rtk fixed
+	InterlockFixFloat = "float" // rtk fixed or float
+	InterlockFixDGPS  = "dgps"  // any fix with corrections
+	InterlockFixAny   = "any"   // any fix, standalone included
+)
+
+// DefaultInterlockCorrectionAge is the age of the last correction above which a rover with a
+// correction source isn't rtk ok.
+const DefaultInterlockCorrectionAge = 10 * time.Second
+
+// GGA fix quality of a fix with corrections other than rtk.
+const fixQualityDGPS = 2
+
+// InterlockConditions configure when a rover is rtk ok: its fix is at least MinFix, the last
+// correction was written to its receiver at most MaxCorrectionAge ago, and its HDOP is at most
+// MaxHDOP if it is set. Zero values use the defaults, a fixed solution and a 10 s correction age.
+type InterlockConditions struct {
+	MinFix           string
+	MaxCorrectionAge time.Duration
+	MaxHDOP          float64
+}
+
+// Validate checks the conditions.
+func (c InterlockConditions) Validate() error {
+	switch c.MinFix {
+	case "", InterlockFixFixed, InterlockFixFloat, InterlockFixDGPS, InterlockFixAny:
+	default:
+		return fmt.Errorf("interlock_fix %q isn't supported, use %s, %s, %s or %s",
+			c.MinFix, InterlockFixFixed, InterlockFixFloat, InterlockFixDGPS, InterlockFixAny)
+	}
+	if c.MaxCorrectionAge < 0 {
+		return errors.New("interlock_max_correction_age_sec must not be negative")
+	}
+	if c.MaxHDOP < 0 {
+		return errors.New("interlock_max_hdop must not be negative")
+	}
+	return nil
+}
+
+// WithDefaults returns the conditions with the default of each one that isn't set.
+func (c InterlockConditions) WithDefaults() InterlockConditions {
+	if c.MinFix == "" {
+		c.MinFix = InterlockFixFixed
+	}
+	if c.MaxCorrectionAge == 0 {
+		c.MaxCorrectionAge = DefaultInterlockCorrectionAge
+	}
+	return c
+}
+
+// InterlockInput is what an interlock judges a rover on.
+type InterlockInput struct {
+	Snapshot Snapshot
+	EpochAge time.Duration // since the epoch was read
+	// since the last correction was written to the receiver, only checked for a rover with a
+	// correction source. Corrections is false while none was.
+	CorrectionAge time.Duration
+	Corrections   bool
+	HasSource     bool
+}
+
+// InterlockStatus is whether a rover is rtk ok, with the reasons it isn't.
+type InterlockStatus struct {
+	OK      bool
+	Reasons []string
+}
+
+// Check returns whether input meets the conditions. A rover whose last epoch is stale is never rtk ok.
+func (c InterlockConditions) Check(input InterlockInput) InterlockStatus {
+	c = c.WithDefaults()
+	var reasons []string
+	if input.Snapshot.Epoch == 0 {
+		reasons = append(reasons, "no epoch yet")
+	} else if input.EpochAge > StaleAfter {
+		reasons = append(reasons, fmt.Sprintf("the last epoch is %.1f s old", input.EpochAge.Seconds()))
+	}
+	if quality := input.Snapshot.Data.FixQuality; !interlockFixMeets(quality, c.MinFix) {
+		reasons = append(reasons, fmt.Sprintf("fix quality %d is below %s", quality, c.MinFix))
+	}
+	if input.HasSource {
+		switch {
+		case !input.Corrections:
+			reasons = append(reasons, "no corrections yet")
+		case input.CorrectionAge > c.MaxCorrectionAge:
+			reasons = append(reasons, fmt.Sprintf("the last correction is %.1f s old, more than %.1f s",
+				input.CorrectionAge.Seconds(), c.MaxCorrectionAge.Seconds()))
+		}
+	}
+	if hdop := input.Snapshot.Data.HDOP; c.MaxHDOP > 0 {
+		switch {
+		case hdop <= 0:
+			reasons = append(reasons, "no hdop")
+		case hdop > c.MaxHDOP:
+			reasons = append(reasons, fmt.Sprintf("hdop %v is above %v", hdop, c.MaxHDOP))
+		}
+	}
+	return InterlockStatus{OK: len(reasons) == 0, Reasons: reasons}
+}
+
+// interlockFixMeets reports whether a GGA fix quality is at least minFix.
+func interlockFixMeets(quality int, minFix string) bool {
+	switch minFix {
+	case InterlockFixFixed:
+		return quality == FixQualityRTKFixed
+	case InterlockFixFloat:
+		return IsRTKFix(quality)
+	case InterlockFixDGPS:
+		return quality == fixQualityDGPS || IsRTKFix(quality)
+	default:
+		return quality > 0
+	}
+}
+
+// InterlockResult answers an InterlockCommand.
+func InterlockResult(status InterlockStatus, input InterlockInput) map[string]interface{} {
+	reasons := []interface{}{}
+	for _, reason := range status.Reasons {
+		reasons = append(reasons, reason)
+	}
+	result := map[string]interface{}{
+		"rtk_ok":        status.OK,
+		"reasons":       reasons,
+		"fix_quality":   input.Snapshot.Data.FixQuality,
+		"hdop":          input.Snapshot.Data.HDOP,
+		"epoch":         input.Snapshot.Epoch,
+		"epoch_age_sec": input.EpochAge.Seconds(),
+	}
+	if input.HasSource && input.Corrections {
+		result["correction_age_sec"] = input.CorrectionAge.Seconds()
+	}
+	return result
+}
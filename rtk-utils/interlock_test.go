@@ -0,0 +1,74 @@
+package rtkutils
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"go.viam.com/rdk/components/movementsensor/gpsnmea"
+	"go.viam.com/test"
+)
+
+func interlockInput(fixQuality int, hdop float64) InterlockInput {
+	return InterlockInput{
+		Snapshot:      Snapshot{Data: gpsnmea.GPSData{FixQuality: fixQuality, HDOP: hdop}, Epoch: 7},
+		EpochAge:      200 * time.Millisecond,
+		CorrectionAge: time.Second,
+		Corrections:   true,
+		HasSource:     true,
+	}
+}
+
+func TestInterlockConditionsValidate(t *testing.T) {
+	test.That(t, InterlockConditions{}.Validate(), test.ShouldBeNil)
+	test.That(t, InterlockConditions{MinFix: InterlockFixDGPS, MaxHDOP: 2}.Validate(), test.ShouldBeNil)
+	test.That(t, InterlockConditions{MinFix: "rtk"}.Validate(), test.ShouldBeError,
+		errors.New("interlock_fix \"rtk\" isn't supported, use fixed, float, dgps or any"))
+	test.That(t, InterlockConditions{MaxCorrectionAge: -time.Second}.Validate(), test.ShouldBeError,
+		errors.New("interlock_max_correction_age_sec must not be negative"))
+	test.That(t, InterlockConditions{MaxHDOP: -1}.Validate(), test.ShouldBeError,
+		errors.New("interlock_max_hdop must not be negative"))
+}
+
+func TestInterlock(t *testing.T) {
+	// a fixed solution with fresh corrections by default
+	status := InterlockConditions{}.Check(interlockInput(FixQualityRTKFixed, 0.8))
+	test.That(t, status.OK, test.ShouldBeTrue)
+	test.That(t, status.Reasons, test.ShouldBeEmpty)
+
+	status = InterlockConditions{}.Check(interlockInput(FixQualityRTKFloat, 0.8))
+	test.That(t, status.Reasons, test.ShouldResemble, []string{"fix quality 5 is below fixed"})
+	test.That(t, InterlockConditions{MinFix: InterlockFixFloat}.Check(interlockInput(FixQualityRTKFloat, 0.8)).OK, test.ShouldBeTrue)
+	test.That(t, InterlockConditions{MinFix: InterlockFixDGPS}.Check(interlockInput(1, 0.8)).OK, test.ShouldBeFalse)
+	test.That(t, InterlockConditions{MinFix: InterlockFixAny}.Check(interlockInput(1, 0.8)).OK, test.ShouldBeTrue)
+
+	input := interlockInput(FixQualityRTKFixed, 2.5)
+	input.EpochAge = 6 * time.Second
+	input.CorrectionAge = 12 * time.Second
+	status = InterlockConditions{MaxHDOP: 2}.Check(input)
+	test.That(t, status.OK, test.ShouldBeFalse)
+	test.That(t, status.Reasons, test.ShouldResemble, []string{
+		"the last epoch is 6.0 s old",
+		"the last correction is 12.0 s old, more than 10.0 s",
+		"hdop 2.5 is above 2",
+	})
+
+	// the correction age of a rover without a correction source isn't checked
+	input.EpochAge = 0
+	input.HasSource = false
+	test.That(t, InterlockConditions{MaxCorrectionAge: 5 * time.Second}.Check(input).OK, test.ShouldBeTrue)
+
+	status = InterlockConditions{}.Check(InterlockInput{HasSource: true})
+	test.That(t, status.Reasons, test.ShouldResemble, []string{"no epoch yet", "fix quality 0 is below fixed", "no corrections yet"})
+	result := InterlockResult(status, InterlockInput{HasSource: true})
+	test.That(t, result["rtk_ok"], test.ShouldBeFalse)
+	test.That(t, result["reasons"], test.ShouldHaveLength, 3)
+	_, ok := result["correction_age_sec"]
+	test.That(t, ok, test.ShouldBeFalse)
+
+	input = interlockInput(FixQualityRTKFixed, 0.8)
+	result = InterlockResult(InterlockConditions{}.Check(input), input)
+	test.That(t, result["rtk_ok"], test.ShouldBeTrue)
+	test.That(t, result["correction_age_sec"], test.ShouldEqual, 1.0)
+	test.That(t, result["epoch"], test.ShouldEqual, uint64(7))
+}
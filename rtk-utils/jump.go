@@ -0,0 +1,204 @@
+package rtkutils
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-gnss/rtcm/rtcm3"
+)
+
+// EventPositionJump is raised when the position of a rover steps further than its accuracy and
+// speed allow, with what changed along with it.
+const EventPositionJump = "position_jump"
+
+// Causes a position jump is put down to, the last one is reported in the readings.
+const (
+	JumpCauseReferenceStation = "reference_station"
+	JumpCauseBase             = "base_position"
+	JumpCauseFix              = "fix"
+	JumpCauseMovement         = "movement"
+)
+
+const (
+	// how many times its horizontal accuracy a position has to step to be taken for a jump
+	jumpAccuracyFactor = 3
+	// steps shorter than this aren't jumps, whatever the accuracy, in m
+	jumpMinDistance = 0.1
+	// the share of the distance the speed of a rover accounts for that it may be off by, for
+	// accelerations and the error of the speed itself
+	jumpSpeedSlack = 0.25
+	// epochs with a fix further apart than this aren't compared, the rover may have moved meanwhile
+	jumpMaxGap = 5 * time.Second
+	// the base position has to move this much to be taken for a change, in m
+	jumpMinBaseMove = 0.01
+)
+
+// jumpNominalAccuracy is the horizontal accuracy taken for a fix quality when the receiver doesn't
+// estimate it, in m.
+var jumpNominalAccuracy = map[int]float64{
+	1:                  2.5,
+	2:                  1,
+	FixQualityRTKFixed: 0.02,
+	FixQualityRTKFloat: 0.5,
+}
+
+// JumpDetector recognizes steps of a rover's position larger than its reported accuracy, and than
+// its speed accounts for, and puts them down to what changed along with them: the reference station
+// of the corrections, the position of the base they come from, or the fix. A jump with none of these
+// is taken for a real movement, while the others are a switch of the reference frame the rover is
+// positioned in. The zero value is ready to use and safe for concurrent use.
+type JumpDetector struct {
+	mu          sync.Mutex
+	last        Snapshot // last epoch with a fix
+	lastAt      time.Time
+	lastAcc     float64    // horizontal accuracy of the last epoch, in m
+	base        [3]float64 // ECEF position of the base, in m
+	hasBase     bool
+	baseMoved   float64 // how far the base moved since the last epoch, in m
+	jumps       Counter
+	lastCause   string
+	lastJumpLen float64
+}
+
+// Frame takes an rtcm frame forwarded to the receiver, noting the base position of the stationary
+// antenna reference point messages.
+func (d *JumpDetector) Frame(frame []byte) {
+	if len(frame) < 3+2+3 {
+		return
+	}
+	payload := frame[3 : len(frame)-3]
+	if msgNum := int(payload[0])<<4 | int(payload[1])>>4; msgNum != 1005 && msgNum != 1006 {
+		return
+	}
+	var arp rtcm3.AntennaReferencePoint
+	switch msg := rtcm3.DeserializeMessage(payload).(type) {
+	case rtcm3.Message1005:
+		arp = msg.AntennaReferencePoint
+	case rtcm3.Message1006:
+		arp = msg.AntennaReferencePoint
+	default:
+		return
+	}
+	d.setBase([3]float64{
+		float64(arp.ReferencePointX) * 1e-4,
+		float64(arp.ReferencePointY) * 1e-4,
+		float64(arp.ReferencePointZ) * 1e-4,
+	})
+}
+
+// setBase sets the ECEF position of the base, in m, adding how far it moved to the move since the last
+// epoch.
+func (d *JumpDetector) setBase(base [3]float64) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.hasBase {
+		if moved := math.Sqrt(math.Pow(base[0]-d.base[0], 2) + math.Pow(base[1]-d.base[1], 2) +
+			math.Pow(base[2]-d.base[2], 2)); moved >= jumpMinBaseMove {
+			d.baseMoved += moved
+		}
+	}
+	d.base, d.hasBase = base, true
+}
+
+// Update takes an epoch read at now and its accuracy, adding an event to events and returning true
+// when its position jumped from that of the previous epoch with a fix.
+func (d *JumpDetector) Update(snap Snapshot, accuracy map[string]float32, now time.Time, events *EventLog) bool {
+	pos := snap.Data.Location
+	if snap.Data.FixQuality == 0 || pos == nil || math.IsNaN(pos.Lat()) || math.IsNaN(pos.Lng()) {
+		return false
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	last, lastAt, lastAcc, baseMoved := d.last, d.lastAt, d.lastAcc, d.baseMoved
+	acc := horizontalAccuracy(snap, accuracy)
+	d.last, d.lastAt, d.lastAcc, d.baseMoved = snap, now, acc, 0
+	if lastAt.IsZero() || now.Sub(lastAt) > jumpMaxGap {
+		return false
+	}
+
+	// the larger accuracy of the two epochs, a jump to or from a worse fix is only one past it
+	acc = math.Max(acc, lastAcc)
+	travel := snap.Data.Speed * now.Sub(lastAt).Seconds()
+	allowed := math.Max(jumpMinDistance, jumpAccuracyFactor*acc) + jumpSpeedSlack*travel
+	step := last.Data.Location.GreatCircleDistance(pos) * 1000
+	if math.Abs(step-travel) <= allowed {
+		return false
+	}
+
+	var causes []string
+	d.lastCause = ""
+	if last.ReferenceStation != "" && snap.ReferenceStation != "" && last.ReferenceStation != snap.ReferenceStation {
+		causes = append(causes, fmt.Sprintf("the reference station changed from %s to %s",
+			last.ReferenceStation, snap.ReferenceStation))
+		d.lastCause = JumpCauseReferenceStation
+	}
+	if baseMoved > 0 {
+		causes = append(causes, fmt.Sprintf("the base moved %.2f m", baseMoved))
+		if d.lastCause == "" {
+			d.lastCause = JumpCauseBase
+		}
+	}
+	if last.Data.FixQuality != snap.Data.FixQuality {
+		causes = append(causes, fmt.Sprintf("the fix changed from %s to %s",
+			fixName(last.Data.FixQuality), fixName(snap.Data.FixQuality)))
+		if d.lastCause == "" {
+			d.lastCause = JumpCauseFix
+		}
+	}
+	d.jumps.Add(1)
+	d.lastJumpLen = step
+	if d.lastCause == "" {
+		d.lastCause = JumpCauseMovement
+		events.Add(EventPositionJump, "position jumped %.2f m, over the %.2f m its accuracy allows, with no "+
+			"change of base or fix, a real movement", step, allowed)
+		return true
+	}
+	events.Add(EventPositionJump, "position jumped %.2f m, over the %.2f m its accuracy allows, as %s",
+		step, allowed, strings.Join(causes, " and "))
+	return true
+}
+
+// horizontalAccuracy returns the horizontal accuracy of an epoch, in m: that of accuracy if the
+// receiver estimates it, or else the nominal accuracy of its fix scaled by its HDOP.
+func horizontalAccuracy(snap Snapshot, accuracy map[string]float32) float64 {
+	if acc, ok := accuracy[AccuracyHorizontal]; ok && acc > 0 {
+		return float64(acc)
+	}
+	nominal, ok := jumpNominalAccuracy[snap.Data.FixQuality]
+	if !ok {
+		nominal = jumpNominalAccuracy[1]
+	}
+	if snap.Data.HDOP > 1 {
+		nominal *= snap.Data.HDOP
+	}
+	return nominal
+}
+
+// fixName returns the name of a GGA fix quality.
+func fixName(quality int) string {
+	if name, ok := coverageFixes[quality]; ok {
+		return name
+	}
+	return fmt.Sprintf("quality %d", quality)
+}
+
+// Jumps returns the number of position jumps.
+func (d *JumpDetector) Jumps() uint64 {
+	return d.jumps.Get()
+}
+
+// Readings returns the number of position jumps and, once there was one, the cause of the last one
+// and how far it was, in m.
+func (d *JumpDetector) Readings() map[string]interface{} {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	readings := map[string]interface{}{"position_jumps": d.jumps.Get()}
+	if d.lastCause != "" {
+		readings["last_position_jump_cause"] = d.lastCause
+		readings["last_position_jump_m"] = d.lastJumpLen
+	}
+	return readings
+}
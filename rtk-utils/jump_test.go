@@ -0,0 +1,74 @@
+package rtkutils
+
+import (
+	"testing"
+	"time"
+
+	"github.com/edaniels/golog"
+	geo "github.com/kellydunn/golang-geo"
+	"go.viam.com/rdk/components/movementsensor/gpsnmea"
+	"go.viam.com/test"
+)
+
+func jumpEpoch(pos *geo.Point, fix int, station string, speed float64) Snapshot {
+	return Snapshot{
+		Data:             gpsnmea.GPSData{Location: pos, FixQuality: fix, HDOP: 0.8, Speed: speed},
+		ReferenceStation: station,
+	}
+}
+
+func TestJumpDetector(t *testing.T) {
+	events := NewEventLog(golog.NewTestLogger(t))
+	start := geo.NewPoint(40, -105)
+	now := time.Now()
+
+	var d JumpDetector
+	test.That(t, d.Readings(), test.ShouldResemble, map[string]interface{}{"position_jumps": uint64(0)})
+	test.That(t, d.Update(jumpEpoch(start, FixQualityRTKFixed, "12", 0), nil, now, events), test.ShouldBeFalse)
+	// a few centimeters is within the accuracy of a fixed solution
+	pos := start.PointAtDistanceAndBearing(0.00005, 0)
+	now = now.Add(time.Second)
+	test.That(t, d.Update(jumpEpoch(pos, FixQualityRTKFixed, "12", 0), nil, now, events), test.ShouldBeFalse)
+	// and a meter is within how far a rover moving at 1 m/s goes in a second
+	pos = pos.PointAtDistanceAndBearing(0.001, 90)
+	now = now.Add(time.Second)
+	test.That(t, d.Update(jumpEpoch(pos, FixQualityRTKFixed, "12", 1), nil, now, events), test.ShouldBeFalse)
+
+	// a stationary rover stepping half a meter moved, unless something else changed
+	pos = pos.PointAtDistanceAndBearing(0.0005, 90)
+	now = now.Add(time.Second)
+	test.That(t, d.Update(jumpEpoch(pos, FixQualityRTKFixed, "12", 0), nil, now, events), test.ShouldBeTrue)
+	jumps := events.Events(EventPositionJump)
+	test.That(t, jumps, test.ShouldHaveLength, 1)
+	test.That(t, jumps[0].Message, test.ShouldEqual,
+		"position jumped 0.50 m, over the 0.10 m its accuracy allows, with no change of base or fix, a real movement")
+	test.That(t, d.Readings()["last_position_jump_cause"], test.ShouldEqual, JumpCauseMovement)
+
+	// a reported accuracy takes over that of the fix
+	pos = pos.PointAtDistanceAndBearing(0.0005, 90)
+	now = now.Add(time.Second)
+	accuracy := map[string]float32{AccuracyHorizontal: 0.2}
+	test.That(t, d.Update(jumpEpoch(pos, FixQualityRTKFixed, "12", 0), accuracy, now, events), test.ShouldBeFalse)
+
+	// switching reference stations and bases shifts the reference frame
+	d.setBase([3]float64{-1288000, -4720000, 4080000})
+	d.setBase([3]float64{-1288000, -4720003, 4080000})
+	pos = pos.PointAtDistanceAndBearing(0.002, 0)
+	now = now.Add(time.Second)
+	test.That(t, d.Update(jumpEpoch(pos, FixQualityRTKFloat, "34", 0), nil, now, events), test.ShouldBeTrue)
+	jumps = events.Events(EventPositionJump)
+	test.That(t, jumps, test.ShouldHaveLength, 2)
+	test.That(t, jumps[1].Message, test.ShouldEqual, "position jumped 2.00 m, over the 1.50 m its accuracy allows, "+
+		"as the reference station changed from 12 to 34 and the base moved 3.00 m and the fix changed from rtk_fixed to rtk_float")
+	readings := d.Readings()
+	test.That(t, readings["position_jumps"], test.ShouldEqual, uint64(2))
+	test.That(t, readings["last_position_jump_cause"], test.ShouldEqual, JumpCauseReferenceStation)
+	test.That(t, readings["last_position_jump_m"], test.ShouldAlmostEqual, 2, 1e-3)
+
+	// epochs without a fix aren't compared, nor those long after the last fix
+	test.That(t, d.Update(jumpEpoch(nil, 0, "", 0), nil, now, events), test.ShouldBeFalse)
+	now = now.Add(time.Minute)
+	pos = pos.PointAtDistanceAndBearing(0.1, 0)
+	test.That(t, d.Update(jumpEpoch(pos, FixQualityRTKFixed, "34", 0), nil, now, events), test.ShouldBeFalse)
+	test.That(t, d.Jumps(), test.ShouldEqual, 2)
+}
@@ -0,0 +1,29 @@
+package rtkutils
+
+import (
+	"fmt"
+	"time"
+
+	geo "github.com/kellydunn/golang-geo"
+)
+
+// LastPositionError is returned by a rover that can't report its current position. It carries the
+// last position the rover knew, so that clients can fall back on it knowing how old it is, rather
+// than a NaN point that breaks the math of clients using it without checking the error.
+type LastPositionError struct {
+	Err      error
+	Category string        // of the failure, e.g. ErrorSerial, empty if unknown
+	Position *geo.Point    // nil if the rover never knew its position
+	Age      time.Duration // since Position was read from the receiver
+}
+
+func (e *LastPositionError) Error() string {
+	if e.Position == nil {
+		return fmt.Sprintf("%v, no position known yet", e.Err)
+	}
+	return fmt.Sprintf("%v, last position %s old", e.Err, e.Age.Round(time.Millisecond))
+}
+
+func (e *LastPositionError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,25 @@
+package rtkutils
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	geo "github.com/kellydunn/golang-geo"
+	"go.viam.com/test"
+)
+
+func TestLastPositionError(t *testing.T) {
+	readErr := errors.New("read failed")
+	err := &LastPositionError{Err: readErr, Category: ErrorSerial}
+	test.That(t, err, test.ShouldBeError, errors.New("read failed, no position known yet"))
+	test.That(t, errors.Is(err, readErr), test.ShouldBeTrue)
+
+	err.Position = geo.NewPoint(40, -74)
+	err.Age = 1500 * time.Millisecond
+	test.That(t, err, test.ShouldBeError, errors.New("read failed, last position 1.5s old"))
+
+	var lastErr *LastPositionError
+	test.That(t, errors.As(error(err), &lastErr), test.ShouldBeTrue)
+	test.That(t, lastErr.Position.Lat(), test.ShouldEqual, 40)
+}
@@ -0,0 +1,106 @@
+package rtkutils
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// TimingCommand returns the latencies of a rover's loops: from reading nmea to parsing it, and from
+// reading a correction to writing it to the receiver.
+const TimingCommand = "timing"
+
+// latencyWindow is the number of recent latencies percentiles are taken over.
+const latencyWindow = 256
+
+// LatencyStats summarizes the latencies of a loop, so that a slow loop on a loaded board can be
+// measured rather than guessed. The zero value is ready to use and safe for concurrent use.
+type LatencyStats struct {
+	mu      sync.Mutex
+	recent  []time.Duration // the last latencyWindow latencies, a ring from next
+	next    int
+	count   uint64
+	total   time.Duration
+	max     time.Duration
+	last    time.Duration
+	jitter  float64 // ns
+	started bool
+}
+
+// Record adds a latency.
+func (s *LatencyStats) Record(latency time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.recent) < latencyWindow {
+		s.recent = append(s.recent, latency)
+	} else {
+		s.recent[s.next] = latency
+		s.next = (s.next + 1) % latencyWindow
+	}
+	s.count++
+	s.total += latency
+	if latency > s.max {
+		s.max = latency
+	}
+	// the interarrival jitter of RFC 3550: a running mean of the difference between consecutive
+	// latencies, which smooths out a single outlier
+	if s.started {
+		diff := float64(latency - s.last)
+		if diff < 0 {
+			diff = -diff
+		}
+		s.jitter += (diff - s.jitter) / 16
+	}
+	s.last, s.started = latency, true
+}
+
+// LatencySummary is the summary of the latencies recorded so far. The percentiles are of the last
+// 256 latencies.
+type LatencySummary struct {
+	Count  uint64
+	Mean   time.Duration
+	P50    time.Duration
+	P95    time.Duration
+	Max    time.Duration
+	Jitter time.Duration
+}
+
+// Summary returns the summary of the latencies recorded so far.
+func (s *LatencyStats) Summary() LatencySummary {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.count == 0 {
+		return LatencySummary{}
+	}
+	sorted := append([]time.Duration(nil), s.recent...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	return LatencySummary{
+		Count:  s.count,
+		Mean:   s.total / time.Duration(s.count),
+		P50:    sorted[len(sorted)*50/100],
+		P95:    sorted[len(sorted)*95/100],
+		Max:    s.max,
+		Jitter: time.Duration(s.jitter),
+	}
+}
+
+// Result returns the summary for a TimingCommand, in milliseconds.
+func (s LatencySummary) Result() map[string]interface{} {
+	ms := func(d time.Duration) float64 { return float64(d) / float64(time.Millisecond) }
+	return map[string]interface{}{
+		"count":     s.Count,
+		"mean_ms":   ms(s.Mean),
+		"p50_ms":    ms(s.P50),
+		"p95_ms":    ms(s.P95),
+		"max_ms":    ms(s.Max),
+		"jitter_ms": ms(s.Jitter),
+	}
+}
+
+// TimingResult answers a TimingCommand.
+func TimingResult(nmeaReadToParse, rtcmReadToWrite LatencySummary) map[string]interface{} {
+	return map[string]interface{}{
+		"nmea_read_to_parse": nmeaReadToParse.Result(),
+		"rtcm_read_to_write": rtcmReadToWrite.Result(),
+	}
+}
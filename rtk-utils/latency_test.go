@@ -0,0 +1,38 @@
+package rtkutils
+
+import (
+	"testing"
+	"time"
+
+	"go.viam.com/test"
+)
+
+func TestLatencyStats(t *testing.T) {
+	var stats LatencyStats
+	test.That(t, stats.Summary(), test.ShouldResemble, LatencySummary{})
+
+	for i := 1; i <= 100; i++ {
+		stats.Record(time.Duration(i) * time.Millisecond)
+	}
+	summary := stats.Summary()
+	test.That(t, summary.Count, test.ShouldEqual, uint64(100))
+	test.That(t, summary.Mean, test.ShouldEqual, 50500*time.Microsecond)
+	test.That(t, summary.P50, test.ShouldEqual, 51*time.Millisecond)
+	test.That(t, summary.P95, test.ShouldEqual, 96*time.Millisecond)
+	test.That(t, summary.Max, test.ShouldEqual, 100*time.Millisecond)
+	// consecutive latencies 1 ms apart converge to a 1 ms jitter
+	test.That(t, summary.Jitter, test.ShouldBeBetween, 990*time.Microsecond, time.Millisecond)
+
+	// the percentiles are of the recent latencies, the max of all of them
+	for i := 0; i < latencyWindow; i++ {
+		stats.Record(time.Millisecond)
+	}
+	summary = stats.Summary()
+	test.That(t, summary.P95, test.ShouldEqual, time.Millisecond)
+	test.That(t, summary.Max, test.ShouldEqual, 100*time.Millisecond)
+	test.That(t, summary.Jitter, test.ShouldBeLessThan, 100*time.Microsecond)
+
+	result := TimingResult(summary, LatencySummary{})
+	test.That(t, result["nmea_read_to_parse"].(map[string]interface{})["max_ms"], test.ShouldEqual, 100.0)
+	test.That(t, result["rtcm_read_to_write"].(map[string]interface{})["count"], test.ShouldEqual, uint64(0))
+}
@@ -0,0 +1,383 @@
+package rtkutils
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	geo "github.com/kellydunn/golang-geo"
+)
+
+const (
+	mavlinkMagic     = 0xFD // MAVLink 2
+	mavlinkHeaderLen = 10
+	mavlinkCRCLen    = 2
+
+	mavlinkGPSInputID       = 232
+	mavlinkGPSInputCRCExtra = 151
+	mavlinkGPSInputLen      = 65
+
+	mavlinkGPSRTCMDataID       = 233
+	mavlinkGPSRTCMDataCRCExtra = 35
+	mavlinkGPSRTCMDataLen      = 182
+	// rtcm bytes per GPS_RTCM_DATA message, and messages an rtcm frame may be fragmented into
+	mavlinkRTCMFragmentLen = 180
+	mavlinkRTCMFragments   = 4
+
+	// a rover sends as the onboard computer of the vehicle, a station as a ground station like MAVProxy
+	mavlinkRoverSystemID      = 1
+	mavlinkRoverComponentID   = 191
+	mavlinkStationSystemID    = 255
+	mavlinkStationComponentID = 190
+
+	// GPS_INPUT ignore_flags
+	mavlinkIgnoreVDOP             = 4
+	mavlinkIgnoreVelocityHoriz    = 8
+	mavlinkIgnoreVelocityVert     = 16
+	mavlinkIgnoreSpeedAccuracy    = 32
+	mavlinkIgnoreHorizAccuracy    = 64
+	mavlinkIgnoreVerticalAccuracy = 128
+
+	// GPS time is ahead of UTC by the leap seconds since 1980, 18 since 2017
+	gpsLeapSeconds = 18 * time.Second
+)
+
+var gpsTimeEpoch = time.Date(1980, 1, 6, 0, 0, 0, 0, time.UTC)
+
+// mavlinkCRC returns the CRC-16/MCRF4XX of data, accumulated from crc, MAVLink's checksum.
+func mavlinkCRC(crc uint16, data ...byte) uint16 {
+	for _, b := range data {
+		tmp := b ^ byte(crc)
+		tmp ^= tmp << 4
+		crc = crc>>8 ^ uint16(tmp)<<8 ^ uint16(tmp)<<3 ^ uint16(tmp>>4)
+	}
+	return crc
+}
+
+// mavlinkEncoder encodes the MAVLink 2 messages of a system and component, numbering them in
+// sequence.
+type mavlinkEncoder struct {
+	systemID, componentID byte
+
+	mu  sync.Mutex
+	seq byte
+}
+
+// encode returns the message msgID with payload, which MAVLink 2 sends without its trailing zeros.
+func (e *mavlinkEncoder) encode(msgID uint32, crcExtra byte, payload []byte) []byte {
+	for len(payload) > 1 && payload[len(payload)-1] == 0 {
+		payload = payload[:len(payload)-1]
+	}
+	e.mu.Lock()
+	seq := e.seq
+	e.seq++
+	e.mu.Unlock()
+	msg := make([]byte, 0, mavlinkHeaderLen+len(payload)+mavlinkCRCLen)
+	msg = append(msg, mavlinkMagic, byte(len(payload)), 0, 0, seq, e.systemID, e.componentID,
+		byte(msgID), byte(msgID>>8), byte(msgID>>16))
+	msg = append(msg, payload...)
+	crc := mavlinkCRC(0xFFFF, msg[1:]...)
+	crc = mavlinkCRC(crc, crcExtra)
+	return append(msg, byte(crc), byte(crc>>8))
+}
+
+// MAVLinkRTCM wraps rtcm frames in GPS_RTCM_DATA messages for an ArduPilot or PX4 autopilot, which
+// forwards them to its GPS. A frame longer than a message is fragmented into up to 4, larger frames
+// can't be sent. A nil MAVLinkRTCM leaves frames as they are.
+type MAVLinkRTCM struct {
+	encoder *mavlinkEncoder
+	seq     byte // of the fragmented frames, 5 bits
+	tooLong Counter
+}
+
+// NewMAVLinkRTCM returns a MAVLinkRTCM sending as a station if enabled is set, nil otherwise.
+func NewMAVLinkRTCM(enabled bool) *MAVLinkRTCM {
+	if !enabled {
+		return nil
+	}
+	return newMAVLinkRTCM(&mavlinkEncoder{systemID: mavlinkStationSystemID, componentID: mavlinkStationComponentID})
+}
+
+func newMAVLinkRTCM(encoder *mavlinkEncoder) *MAVLinkRTCM {
+	return &MAVLinkRTCM{encoder: encoder}
+}
+
+// Wrap returns the GPS_RTCM_DATA messages of frame, one after the other, or nothing for a frame too
+// long to be sent.
+func (m *MAVLinkRTCM) Wrap(frame []byte) []byte {
+	if m == nil {
+		return frame
+	}
+	if len(frame) > mavlinkRTCMFragments*mavlinkRTCMFragmentLen {
+		m.tooLong.Add(1)
+		return nil
+	}
+	if len(frame) <= mavlinkRTCMFragmentLen {
+		return m.encoder.encode(mavlinkGPSRTCMDataID, mavlinkGPSRTCMDataCRCExtra, rtcmDataPayload(0, frame))
+	}
+	var msgs []byte
+	// flags: fragmented, the fragment and the sequence number of the frame
+	for fragment := 0; len(frame) > 0; fragment++ {
+		n := mavlinkRTCMFragmentLen
+		if len(frame) < n {
+			n = len(frame)
+		}
+		flags := 1 | byte(fragment)<<1 | m.seq<<3
+		msgs = append(msgs, m.encoder.encode(mavlinkGPSRTCMDataID, mavlinkGPSRTCMDataCRCExtra, rtcmDataPayload(flags, frame[:n]))...)
+		frame = frame[n:]
+	}
+	m.seq = (m.seq + 1) & 0x1F
+	return msgs
+}
+
+// rtcmDataPayload returns the payload of a GPS_RTCM_DATA message with flags and data.
+func rtcmDataPayload(flags byte, data []byte) []byte {
+	payload := make([]byte, mavlinkGPSRTCMDataLen)
+	payload[0], payload[1] = flags, byte(len(data))
+	copy(payload[2:], data)
+	return payload
+}
+
+// TooLong returns how many frames were too long to be sent.
+func (m *MAVLinkRTCM) TooLong() uint64 {
+	if m == nil {
+		return 0
+	}
+	return m.tooLong.Get()
+}
+
+// MAVLinkPosition is the position of an epoch a rover sends an autopilot as GPS_INPUT.
+type MAVLinkPosition struct {
+	Time       time.Time
+	Position   *geo.Point // nil without a fix
+	Alt        float64    // m above mean sea level
+	FixQuality int
+	Satellites int
+	HDOP, VDOP float64
+	Speed      float64 // m/s
+	Course     float64 // of the speed, in degrees from north, NaN without one
+	ClimbRate  float64 // m/s, NaN without one
+	// the heading of a dual antenna receiver, in degrees from north, NaN without one
+	Yaw float64
+	// in m and m/s, 0 if the receiver doesn't estimate them
+	HorizontalAccuracy, VerticalAccuracy, SpeedAccuracy float64
+}
+
+// mavlinkFixType returns the GPS_FIX_TYPE of an nmea fix quality.
+func mavlinkFixType(quality int) byte {
+	switch quality {
+	case 0, 6: // invalid, dead reckoning
+		return 1 // no fix
+	case 2: // dgps
+		return 4
+	case FixQualityRTKFloat:
+		return 5
+	case FixQualityRTKFixed:
+		return 6
+	default:
+		return 3 // 3d
+	}
+}
+
+// gpsInputPayload returns the payload of the GPS_INPUT message of pos.
+func gpsInputPayload(pos MAVLinkPosition) []byte {
+	payload := make([]byte, mavlinkGPSInputLen)
+	le := binary.LittleEndian
+	putFloat := func(offset int, f float64) {
+		le.PutUint32(payload[offset:], math.Float32bits(float32(f)))
+	}
+	gps := pos.Time.Add(gpsLeapSeconds).Sub(gpsTimeEpoch)
+	week := gps / (7 * 24 * time.Hour)
+	le.PutUint64(payload[0:], uint64(pos.Time.UnixMicro()))
+	le.PutUint32(payload[8:], uint32((gps-week*7*24*time.Hour)/time.Millisecond))
+	le.PutUint16(payload[58:], uint16(week))
+	payload[61] = mavlinkFixType(pos.FixQuality)
+	if pos.Position == nil {
+		payload[61] = 1
+	}
+	payload[62] = byte(pos.Satellites)
+
+	var ignore uint16
+	if pos.Position != nil {
+		le.PutUint32(payload[12:], uint32(int32(math.Round(pos.Position.Lat()*1e7))))
+		le.PutUint32(payload[16:], uint32(int32(math.Round(pos.Position.Lng()*1e7))))
+	}
+	putFloat(20, pos.Alt)
+	putFloat(24, pos.HDOP)
+	if pos.VDOP > 0 {
+		putFloat(28, pos.VDOP)
+	} else {
+		ignore |= mavlinkIgnoreVDOP
+	}
+	if math.IsNaN(pos.Course) {
+		ignore |= mavlinkIgnoreVelocityHoriz
+	} else {
+		course := pos.Course * math.Pi / 180
+		putFloat(32, pos.Speed*math.Cos(course))
+		putFloat(36, pos.Speed*math.Sin(course))
+	}
+	if math.IsNaN(pos.ClimbRate) {
+		ignore |= mavlinkIgnoreVelocityVert
+	} else {
+		putFloat(40, -pos.ClimbRate)
+	}
+	accuracies := []struct {
+		offset int
+		value  float64
+		flag   uint16
+	}{
+		{44, pos.SpeedAccuracy, mavlinkIgnoreSpeedAccuracy},
+		{48, pos.HorizontalAccuracy, mavlinkIgnoreHorizAccuracy},
+		{52, pos.VerticalAccuracy, mavlinkIgnoreVerticalAccuracy},
+	}
+	for _, accuracy := range accuracies {
+		if accuracy.value > 0 {
+			putFloat(accuracy.offset, accuracy.value)
+		} else {
+			ignore |= accuracy.flag
+		}
+	}
+	le.PutUint16(payload[56:], ignore)
+	// yaw in cdeg, 0 for none, so north is 360
+	if !math.IsNaN(pos.Yaw) {
+		yaw := uint16(math.Round(NormalizeHeading(pos.Yaw) * 100))
+		if yaw == 0 {
+			yaw = 36000
+		}
+		le.PutUint16(payload[63:], yaw)
+	}
+	return payload
+}
+
+// ValidateMAVLink checks the udp host:port of a rover's MAVLink connection, and that it sends
+// something over it.
+func ValidateMAVLink(addr string, gpsInput, rtcmData bool) error {
+	if addr == "" {
+		if gpsInput || rtcmData {
+			return errors.New("mavlink_gps_input and mavlink_rtcm_data need mavlink_addr")
+		}
+		return nil
+	}
+	_, port, err := net.SplitHostPort(addr)
+	if n, portErr := strconv.Atoi(port); err != nil || portErr != nil || n < 1 || n > 65535 {
+		return fmt.Errorf("mavlink_addr %q isn't a host:port", addr)
+	}
+	if !gpsInput && !rtcmData {
+		return errors.New("mavlink_addr needs mavlink_gps_input or mavlink_rtcm_data")
+	}
+	return nil
+}
+
+// MAVLink is a rover's connection to an ArduPilot or PX4 autopilot over udp, directly or through a
+// router such as mavlink-router for one on a serial port. The rover sends the position of every
+// epoch as a GPS_INPUT message, for an autopilot to use the rover's receiver as its GPS, and the
+// corrections it forwards to its receiver as GPS_RTCM_DATA messages, for an autopilot with an rtk
+// GPS of its own. The connection is dialed again with the next message after it fails. A nil
+// MAVLink sends nothing.
+type MAVLink struct {
+	addr     string
+	gpsInput bool
+	encoder  *mavlinkEncoder
+	rtcm     *MAVLinkRTCM // nil unless corrections are forwarded
+
+	mu        sync.Mutex
+	conn      net.Conn // nil until dialed
+	positions Counter
+	frames    Counter
+	failed    Counter
+}
+
+// NewMAVLink returns a MAVLink sending to addr GPS_INPUT messages if gpsInput is set and
+// GPS_RTCM_DATA messages if rtcmData is, or nil if addr is empty.
+func NewMAVLink(addr string, gpsInput, rtcmData bool) *MAVLink {
+	if addr == "" {
+		return nil
+	}
+	encoder := &mavlinkEncoder{systemID: mavlinkRoverSystemID, componentID: mavlinkRoverComponentID}
+	m := &MAVLink{addr: addr, gpsInput: gpsInput, encoder: encoder}
+	if rtcmData {
+		m.rtcm = newMAVLinkRTCM(encoder)
+	}
+	return m
+}
+
+// GPSInput sends pos as a GPS_INPUT message.
+func (m *MAVLink) GPSInput(pos MAVLinkPosition) {
+	if m == nil || !m.gpsInput {
+		return
+	}
+	if m.send(m.encoder.encode(mavlinkGPSInputID, mavlinkGPSInputCRCExtra, gpsInputPayload(pos))) {
+		m.positions.Add(1)
+	}
+}
+
+// RTCM sends an rtcm frame as GPS_RTCM_DATA messages.
+func (m *MAVLink) RTCM(frame []byte) {
+	if m == nil || m.rtcm == nil {
+		return
+	}
+	m.mu.Lock()
+	msgs := m.rtcm.Wrap(frame)
+	m.mu.Unlock()
+	if len(msgs) > 0 && m.send(msgs) {
+		m.frames.Add(1)
+	}
+}
+
+// send writes msgs to the autopilot, dialing it if needed, and reports whether it did.
+func (m *MAVLink) send(msgs []byte) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.conn == nil {
+		conn, err := net.Dial("udp", m.addr)
+		if err != nil {
+			m.failed.Add(1)
+			return false
+		}
+		m.conn = conn
+	}
+	if _, err := m.conn.Write(msgs); err != nil {
+		m.failed.Add(1)
+		m.conn.Close()
+		m.conn = nil
+		return false
+	}
+	return true
+}
+
+// Readings returns how many positions and rtcm frames were sent, failed to be, and were too long to
+// be.
+func (m *MAVLink) Readings() map[string]interface{} {
+	if m == nil {
+		return map[string]interface{}{}
+	}
+	readings := map[string]interface{}{
+		"mavlink_send_errors": m.failed.Get(),
+	}
+	if m.gpsInput {
+		readings["mavlink_positions_sent"] = m.positions.Get()
+	}
+	if m.rtcm != nil {
+		readings["mavlink_rtcm_frames_sent"] = m.frames.Get()
+		readings["mavlink_rtcm_frames_too_long"] = m.rtcm.TooLong()
+	}
+	return readings
+}
+
+// Close closes the connection to the autopilot.
+func (m *MAVLink) Close() {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.conn != nil {
+		m.conn.Close()
+		m.conn = nil
+	}
+}
@@ -0,0 +1,187 @@
+package rtkutils
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"net"
+	"testing"
+	"time"
+
+	geo "github.com/kellydunn/golang-geo"
+	"go.viam.com/test"
+)
+
+// mavlinkMessage is a MAVLink 2 message decoded by parseMAVLink.
+type mavlinkMessage struct {
+	seq, systemID, componentID byte
+	msgID                      uint32
+	payload                    []byte // with the trailing zeros put back up to the length of the message
+}
+
+// parseMAVLink decodes the MAVLink 2 messages of data, checking their CRCs with the CRC extra of
+// their message.
+func parseMAVLink(t *testing.T, data []byte) []mavlinkMessage {
+	t.Helper()
+	var msgs []mavlinkMessage
+	for len(data) > 0 {
+		test.That(t, data[0], test.ShouldEqual, byte(mavlinkMagic))
+		n := mavlinkHeaderLen + int(data[1]) + mavlinkCRCLen
+		test.That(t, len(data), test.ShouldBeGreaterThanOrEqualTo, n)
+		msg := mavlinkMessage{
+			seq: data[4], systemID: data[5], componentID: data[6],
+			msgID: uint32(data[7]) | uint32(data[8])<<8 | uint32(data[9])<<16,
+		}
+		crcExtra, length := byte(mavlinkGPSInputCRCExtra), mavlinkGPSInputLen
+		if msg.msgID == mavlinkGPSRTCMDataID {
+			crcExtra, length = mavlinkGPSRTCMDataCRCExtra, mavlinkGPSRTCMDataLen
+		}
+		crc := mavlinkCRC(mavlinkCRC(0xFFFF, data[1:n-mavlinkCRCLen]...), crcExtra)
+		test.That(t, binary.LittleEndian.Uint16(data[n-mavlinkCRCLen:]), test.ShouldEqual, crc)
+		msg.payload = make([]byte, length)
+		copy(msg.payload, data[mavlinkHeaderLen:n-mavlinkCRCLen])
+		msgs = append(msgs, msg)
+		data = data[n:]
+	}
+	return msgs
+}
+
+func TestMAVLinkCRC(t *testing.T) {
+	// the check value of CRC-16/MCRF4XX
+	test.That(t, mavlinkCRC(0xFFFF, []byte("123456789")...), test.ShouldEqual, uint16(0x6F91))
+}
+
+func TestMAVLinkRTCM(t *testing.T) {
+	test.That(t, NewMAVLinkRTCM(false).Wrap([]byte{1, 2}), test.ShouldResemble, []byte{1, 2})
+	test.That(t, NewMAVLinkRTCM(false).TooLong(), test.ShouldEqual, uint64(0))
+
+	m := NewMAVLinkRTCM(true)
+	frame := gpsMSMFrame(1000)
+	msgs := parseMAVLink(t, m.Wrap(frame))
+	test.That(t, msgs, test.ShouldHaveLength, 1)
+	test.That(t, msgs[0].msgID, test.ShouldEqual, uint32(mavlinkGPSRTCMDataID))
+	test.That(t, msgs[0].systemID, test.ShouldEqual, byte(255))
+	test.That(t, msgs[0].componentID, test.ShouldEqual, byte(190))
+	test.That(t, msgs[0].payload[0], test.ShouldEqual, byte(0))
+	test.That(t, msgs[0].payload[2:2+msgs[0].payload[1]], test.ShouldResemble, frame)
+
+	// a frame longer than a message is fragmented
+	long := make([]byte, 400)
+	for i := range long {
+		long[i] = byte(i)
+	}
+	for seq := byte(0); seq < 2; seq++ {
+		msgs = parseMAVLink(t, m.Wrap(long))
+		test.That(t, msgs, test.ShouldHaveLength, 3)
+		var data []byte
+		for i, msg := range msgs {
+			test.That(t, msg.payload[0], test.ShouldEqual, 1|byte(i)<<1|seq<<3)
+			data = append(data, msg.payload[2:2+msg.payload[1]]...)
+		}
+		test.That(t, data, test.ShouldResemble, long)
+		test.That(t, msgs[2].payload[1], test.ShouldEqual, byte(40))
+	}
+	// messages are numbered across frames
+	test.That(t, msgs[0].seq, test.ShouldEqual, byte(4))
+
+	test.That(t, m.Wrap(make([]byte, 721)), test.ShouldBeNil)
+	test.That(t, m.TooLong(), test.ShouldEqual, uint64(1))
+}
+
+func TestGPSInputPayload(t *testing.T) {
+	pos := MAVLinkPosition{
+		// 2026-10-16 12:00:00 UTC is 12:00:18 GPS time on Friday of gps week 2440
+		Time:     time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC),
+		Position: geo.NewPoint(40.5, -105.25), Alt: 1600.5, FixQuality: FixQualityRTKFixed,
+		Satellites: 12, HDOP: 0.8, Speed: 2, Course: 90, ClimbRate: 0.5, Yaw: 0,
+		HorizontalAccuracy: 0.014,
+	}
+	payload := gpsInputPayload(pos)
+	le := binary.LittleEndian
+	float := func(offset int) float64 {
+		return float64(math.Float32frombits(le.Uint32(payload[offset:])))
+	}
+	test.That(t, le.Uint64(payload[0:]), test.ShouldEqual, uint64(pos.Time.UnixMicro()))
+	test.That(t, le.Uint32(payload[8:]), test.ShouldEqual, uint32((5*24*3600+12*3600+18)*1000))
+	test.That(t, le.Uint16(payload[58:]), test.ShouldEqual, uint16(2440))
+	test.That(t, int32(le.Uint32(payload[12:])), test.ShouldEqual, int32(405000000))
+	test.That(t, int32(le.Uint32(payload[16:])), test.ShouldEqual, int32(-1052500000))
+	test.That(t, float(20), test.ShouldAlmostEqual, 1600.5, 1e-3)
+	test.That(t, float(24), test.ShouldAlmostEqual, 0.8, 1e-6)
+	// moving east and climbing
+	test.That(t, float(32), test.ShouldAlmostEqual, 0, 1e-6)
+	test.That(t, float(36), test.ShouldAlmostEqual, 2, 1e-6)
+	test.That(t, float(40), test.ShouldAlmostEqual, -0.5, 1e-6)
+	test.That(t, float(48), test.ShouldAlmostEqual, 0.014, 1e-6)
+	test.That(t, le.Uint16(payload[56:]), test.ShouldEqual,
+		uint16(mavlinkIgnoreVDOP|mavlinkIgnoreSpeedAccuracy|mavlinkIgnoreVerticalAccuracy))
+	test.That(t, payload[61], test.ShouldEqual, byte(6))
+	test.That(t, payload[62], test.ShouldEqual, byte(12))
+	// north is 360 degrees, 0 is no yaw
+	test.That(t, le.Uint16(payload[63:]), test.ShouldEqual, uint16(36000))
+
+	payload = gpsInputPayload(MAVLinkPosition{Time: pos.Time, Course: math.NaN(), ClimbRate: math.NaN(), Yaw: math.NaN()})
+	test.That(t, payload[61], test.ShouldEqual, byte(1))
+	test.That(t, le.Uint16(payload[56:])&(mavlinkIgnoreVelocityHoriz|mavlinkIgnoreVelocityVert), test.ShouldEqual,
+		uint16(mavlinkIgnoreVelocityHoriz|mavlinkIgnoreVelocityVert))
+	test.That(t, le.Uint16(payload[63:]), test.ShouldEqual, uint16(0))
+}
+
+func TestValidateMAVLink(t *testing.T) {
+	test.That(t, ValidateMAVLink("", false, false), test.ShouldBeNil)
+	test.That(t, ValidateMAVLink("127.0.0.1:14550", true, false), test.ShouldBeNil)
+	test.That(t, ValidateMAVLink("", false, true), test.ShouldBeError,
+		errors.New("mavlink_gps_input and mavlink_rtcm_data need mavlink_addr"))
+	test.That(t, ValidateMAVLink("127.0.0.1", true, true), test.ShouldBeError,
+		errors.New(`mavlink_addr "127.0.0.1" isn't a host:port`))
+	test.That(t, ValidateMAVLink("127.0.0.1:14550", false, false), test.ShouldBeError,
+		errors.New("mavlink_addr needs mavlink_gps_input or mavlink_rtcm_data"))
+}
+
+func TestMAVLink(t *testing.T) {
+	test.That(t, NewMAVLink("", true, true), test.ShouldBeNil)
+
+	autopilot, err := net.ListenPacket("udp", "127.0.0.1:0")
+	test.That(t, err, test.ShouldBeNil)
+	defer autopilot.Close()
+	read := func() []mavlinkMessage {
+		t.Helper()
+		buf := make([]byte, 2048)
+		test.That(t, autopilot.SetReadDeadline(time.Now().Add(time.Second)), test.ShouldBeNil)
+		n, _, err := autopilot.ReadFrom(buf)
+		test.That(t, err, test.ShouldBeNil)
+		return parseMAVLink(t, buf[:n])
+	}
+
+	m := NewMAVLink(autopilot.LocalAddr().String(), true, true)
+	defer m.Close()
+	m.GPSInput(MAVLinkPosition{
+		Time: time.Now(), Position: geo.NewPoint(40.5, -105.25), FixQuality: FixQualityRTKFloat,
+		Course: math.NaN(), ClimbRate: math.NaN(), Yaw: math.NaN(),
+	})
+	msgs := read()
+	test.That(t, msgs, test.ShouldHaveLength, 1)
+	test.That(t, msgs[0].msgID, test.ShouldEqual, uint32(mavlinkGPSInputID))
+	test.That(t, msgs[0].systemID, test.ShouldEqual, byte(1))
+	test.That(t, msgs[0].componentID, test.ShouldEqual, byte(191))
+	test.That(t, msgs[0].payload[61], test.ShouldEqual, byte(5))
+
+	// a fragmented frame is sent at once
+	m.RTCM(make([]byte, 500))
+	msgs = read()
+	test.That(t, msgs, test.ShouldHaveLength, 3)
+	test.That(t, msgs[0].seq, test.ShouldEqual, byte(1))
+	m.RTCM(make([]byte, 800))
+	test.That(t, m.Readings(), test.ShouldResemble, map[string]interface{}{
+		"mavlink_positions_sent": uint64(1), "mavlink_rtcm_frames_sent": uint64(1),
+		"mavlink_rtcm_frames_too_long": uint64(1), "mavlink_send_errors": uint64(0),
+	})
+
+	// corrections only
+	rtcm := NewMAVLink(autopilot.LocalAddr().String(), false, true)
+	defer rtcm.Close()
+	rtcm.GPSInput(MAVLinkPosition{Time: time.Now()})
+	test.That(t, rtcm.Readings(), test.ShouldResemble, map[string]interface{}{
+		"mavlink_rtcm_frames_sent": uint64(0), "mavlink_rtcm_frames_too_long": uint64(0), "mavlink_send_errors": uint64(0),
+	})
+}
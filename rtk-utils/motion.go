@@ -0,0 +1,123 @@
+package rtkutils
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	geo "github.com/kellydunn/golang-geo"
+	"go.viam.com/rdk/components/movementsensor/gpsnmea"
+)
+
+// Default motion detection thresholds.
+const (
+	DefaultMovingSpeed      = 0.5 // m/s
+	DefaultStationarySpeed  = 0.2 // m/s
+	DefaultMovingDistance   = 0.5 // m
+	DefaultStationaryEpochs = 3
+)
+
+// MotionThresholds configure when a receiver is considered to be moving. A receiver starts moving
+// once its speed exceeds MovingSpeed or it drifts more than MovingDistance from where it stopped,
+// and stops once its speed stays below StationarySpeed for StationaryEpochs epochs in a row.
+// Zero values use the defaults.
+type MotionThresholds struct {
+	MovingSpeed      float64 // m/s
+	StationarySpeed  float64 // m/s
+	MovingDistance   float64 // m
+	StationaryEpochs int
+}
+
+// Validate checks that the thresholds leave a hysteresis band between moving and stationary.
+func (m MotionThresholds) Validate() error {
+	m = m.WithDefaults()
+	if m.MovingSpeed < 0 || m.StationarySpeed < 0 || m.MovingDistance < 0 || m.StationaryEpochs < 0 {
+		return errors.New("motion thresholds must not be negative")
+	}
+	if m.StationarySpeed > m.MovingSpeed {
+		return fmt.Errorf("stationary speed (%v m/s) must not be greater than moving speed (%v m/s)",
+			m.StationarySpeed, m.MovingSpeed)
+	}
+	return nil
+}
+
+// WithDefaults returns the thresholds with the default of each one that isn't set.
+func (m MotionThresholds) WithDefaults() MotionThresholds {
+	if m.MovingSpeed == 0 {
+		m.MovingSpeed = DefaultMovingSpeed
+	}
+	if m.StationarySpeed == 0 {
+		m.StationarySpeed = math.Min(DefaultStationarySpeed, m.MovingSpeed)
+	}
+	if m.MovingDistance == 0 {
+		m.MovingDistance = DefaultMovingDistance
+	}
+	if m.StationaryEpochs == 0 {
+		m.StationaryEpochs = DefaultStationaryEpochs
+	}
+	return m
+}
+
+// MotionDetector derives whether a receiver is moving from its speed and position jitter,
+// with hysteresis so that noise around a threshold does not flip the state every epoch.
+type MotionDetector struct {
+	thresholds MotionThresholds
+
+	moving     bool
+	slowEpochs int        // consecutive epochs below the stationary speed, since the last start while moving
+	anchor     *geo.Point // position where the receiver last stopped
+}
+
+// NewMotionDetector returns a detector that starts out stationary.
+func NewMotionDetector(thresholds MotionThresholds) *MotionDetector {
+	return &MotionDetector{thresholds: thresholds.WithDefaults()}
+}
+
+// Update feeds the data of a complete epoch to the detector and returns whether the receiver is moving.
+func (d *MotionDetector) Update(data gpsnmea.GPSData) bool {
+	pos := data.Location
+	hasPos := pos != nil && !math.IsNaN(pos.Lat()) && !math.IsNaN(pos.Lng())
+
+	if !d.moving {
+		if data.Speed < d.thresholds.StationarySpeed {
+			d.slowEpochs++
+		} else {
+			d.slowEpochs = 0
+		}
+		if d.anchor == nil && hasPos {
+			d.anchor = pos
+		}
+		drifted := hasPos && d.anchor != nil && d.distance(pos) > d.thresholds.MovingDistance
+		if data.Speed > d.thresholds.MovingSpeed || drifted {
+			d.moving = true
+			d.slowEpochs = 0
+		}
+		return d.moving
+	}
+
+	if data.Speed >= d.thresholds.StationarySpeed {
+		d.slowEpochs = 0
+		return d.moving
+	}
+	d.slowEpochs++
+	if d.slowEpochs >= d.thresholds.StationaryEpochs {
+		d.moving = false
+		d.anchor = nil
+		if hasPos {
+			d.anchor = pos
+		}
+	}
+	return d.moving
+}
+
+// ZeroVelocity reports whether the receiver is stationary and its speed stayed below the stationary
+// speed for the stationary epochs, so that a zero velocity update can be applied. Unlike being
+// stationary, it isn't assumed before the receiver reported a speed.
+func (d *MotionDetector) ZeroVelocity() bool {
+	return !d.moving && d.slowEpochs >= d.thresholds.StationaryEpochs
+}
+
+// distance returns the distance in meters from the anchor to pos.
+func (d *MotionDetector) distance(pos *geo.Point) float64 {
+	return d.anchor.GreatCircleDistance(pos) * 1000
+}
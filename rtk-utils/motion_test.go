@@ -0,0 +1,58 @@
+package rtkutils
+
+import (
+	"testing"
+
+	geo "github.com/kellydunn/golang-geo"
+	"go.viam.com/rdk/components/movementsensor/gpsnmea"
+	"go.viam.com/test"
+)
+
+func TestMotionThresholdsValidate(t *testing.T) {
+	test.That(t, MotionThresholds{}.Validate(), test.ShouldBeNil)
+	test.That(t, MotionThresholds{MovingSpeed: 1, StationarySpeed: 0.5}.Validate(), test.ShouldBeNil)
+	test.That(t, MotionThresholds{MovingSpeed: 0.5, StationarySpeed: 1}.Validate(), test.ShouldNotBeNil)
+	test.That(t, MotionThresholds{MovingDistance: -1}.Validate(), test.ShouldNotBeNil)
+}
+
+func TestMotionDetector(t *testing.T) {
+	start := geo.NewPoint(37.3911, -122.0378)
+	d := NewMotionDetector(MotionThresholds{StationaryEpochs: 2})
+
+	test.That(t, d.Update(gpsnmea.GPSData{Location: start, Speed: 0.1}), test.ShouldBeFalse)
+	// speed between the thresholds does not start moving
+	test.That(t, d.Update(gpsnmea.GPSData{Location: start, Speed: 0.4}), test.ShouldBeFalse)
+	test.That(t, d.Update(gpsnmea.GPSData{Location: start, Speed: 1}), test.ShouldBeTrue)
+
+	// nor does it stop moving
+	test.That(t, d.Update(gpsnmea.GPSData{Location: start, Speed: 0.4}), test.ShouldBeTrue)
+	test.That(t, d.Update(gpsnmea.GPSData{Location: start, Speed: 0.1}), test.ShouldBeTrue)
+	test.That(t, d.Update(gpsnmea.GPSData{Location: start, Speed: 0.1}), test.ShouldBeFalse)
+
+	// drifting away from where it stopped counts as moving even at low speed
+	test.That(t, d.Update(gpsnmea.GPSData{Location: start.PointAtDistanceAndBearing(0.0002, 90)}), test.ShouldBeFalse)
+	test.That(t, d.Update(gpsnmea.GPSData{Location: start.PointAtDistanceAndBearing(0.001, 90)}), test.ShouldBeTrue)
+}
+
+func TestZeroVelocity(t *testing.T) {
+	start := geo.NewPoint(37.3911, -122.0378)
+	d := NewMotionDetector(MotionThresholds{StationaryEpochs: 2})
+	// stationary from the start, but not for long enough
+	test.That(t, d.ZeroVelocity(), test.ShouldBeFalse)
+	d.Update(gpsnmea.GPSData{Location: start, Speed: 0.1})
+	test.That(t, d.ZeroVelocity(), test.ShouldBeFalse)
+	d.Update(gpsnmea.GPSData{Location: start, Speed: 0.1})
+	test.That(t, d.ZeroVelocity(), test.ShouldBeTrue)
+
+	// a speed between the thresholds is still stationary, but not still enough
+	test.That(t, d.Update(gpsnmea.GPSData{Location: start, Speed: 0.4}), test.ShouldBeFalse)
+	test.That(t, d.ZeroVelocity(), test.ShouldBeFalse)
+
+	d.Update(gpsnmea.GPSData{Location: start, Speed: 1})
+	test.That(t, d.ZeroVelocity(), test.ShouldBeFalse)
+	d.Update(gpsnmea.GPSData{Location: start, Speed: 0.1})
+	test.That(t, d.ZeroVelocity(), test.ShouldBeFalse)
+	// stopping takes the stationary epochs, which are also enough for a zero velocity update
+	test.That(t, d.Update(gpsnmea.GPSData{Location: start, Speed: 0.1}), test.ShouldBeFalse)
+	test.That(t, d.ZeroVelocity(), test.ShouldBeTrue)
+}
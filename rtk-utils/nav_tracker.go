@@ -0,0 +1,144 @@
+package rtkutils
+
+import (
+	"sync"
+	"time"
+)
+
+// a navigation message older than this is no longer reported, receivers output one every solution
+const navMaxAge = 2 * time.Second
+
+// NavTracker keeps the latest attitude, solution status, accuracy and vertical velocity of the UBX-NAV
+// messages a u-blox receiver outputs among its nmea sentences. A nil tracker keeps none.
+type NavTracker struct {
+	ubx []byte // start of a frame cut off by the end of the last write
+
+	mu               sync.Mutex
+	attitude         Attitude
+	attitudeReceived time.Time
+	status           SolutionStatus
+	statusReceived   time.Time
+	vertical         float64 // m/s, positive up
+	verticalReceived time.Time
+	accuracy         NavAccuracy
+	accuracyReceived time.Time
+}
+
+// NewNavTracker returns a tracker if the receiver outputs UBX, nil otherwise.
+func NewNavTracker(profile ReceiverProfile) *NavTracker {
+	if !profile.UBX {
+		return nil
+	}
+	return &NavTracker{}
+}
+
+// Write reads the next bytes output by the receiver. It never fails.
+func (t *NavTracker) Write(p []byte) (int, error) {
+	if t == nil {
+		return len(p), nil
+	}
+	var frames [][]byte
+	frames, t.ubx = SplitUBXFrames(append(t.ubx, p...))
+	now := time.Now()
+	for _, frame := range frames {
+		cls, id, payload := UBXFrame(frame)
+		if cls != UBXClassNav {
+			continue
+		}
+		switch id {
+		case UBXNavAtt:
+			if att, err := ParseNavAtt(payload); err == nil {
+				t.mu.Lock()
+				t.attitude, t.attitudeReceived = att, now
+				t.mu.Unlock()
+			}
+		case UBXNavPVT, UBXNavStatus:
+			parse := ParseNavPVT
+			if id == UBXNavStatus {
+				parse = ParseNavStatus
+			}
+			if status, err := parse(payload); err == nil {
+				t.mu.Lock()
+				t.status, t.statusReceived = status, now
+				t.mu.Unlock()
+			}
+			if vertical, ok := NavPVTVerticalVelocity(payload); ok && id == UBXNavPVT {
+				t.mu.Lock()
+				t.vertical, t.verticalReceived = vertical, now
+				t.mu.Unlock()
+			}
+			if accuracy, ok := ParseNavPVTAccuracy(payload); ok && id == UBXNavPVT {
+				t.mu.Lock()
+				t.accuracy, t.accuracyReceived = accuracy, now
+				t.mu.Unlock()
+			}
+		}
+	}
+	return len(p), nil
+}
+
+// Attitude returns the last attitude, and false if none was received in the last few seconds.
+func (t *NavTracker) Attitude(now time.Time) (Attitude, bool) {
+	if t == nil {
+		return Attitude{}, false
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.attitude, fresh(t.attitudeReceived, now)
+}
+
+// SolutionStatus returns the last solution status, and false if none was received in the last few
+// seconds.
+func (t *NavTracker) SolutionStatus(now time.Time) (SolutionStatus, bool) {
+	if t == nil {
+		return SolutionStatus{}, false
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.status, fresh(t.statusReceived, now)
+}
+
+// VerticalVelocity returns the last vertical velocity of a valid fix in m/s, positive up, and false if
+// none was received in the last few seconds.
+func (t *NavTracker) VerticalVelocity(now time.Time) (float64, bool) {
+	if t == nil {
+		return 0, false
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.vertical, fresh(t.verticalReceived, now)
+}
+
+// Accuracy returns the accuracy of the last valid fix, and false if none was received in the last few
+// seconds.
+func (t *NavTracker) Accuracy(now time.Time) (NavAccuracy, bool) {
+	if t == nil {
+		return NavAccuracy{}, false
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.accuracy, fresh(t.accuracyReceived, now)
+}
+
+// Sources of a vertical velocity.
+const (
+	VerticalVelocitySourceUBX      = "ubx"      // the NAV-PVT velocity of a u-blox receiver
+	VerticalVelocitySourceAltitude = "altitude" // the climb rate between the altitudes of epochs
+)
+
+// EpochVerticalVelocity returns the vertical velocity of snap in m/s, positive up, and its source: the
+// velocity the receiver last reported in a NAV-PVT message, else the climb rate of the epoch. The
+// source is empty without either.
+func (t *NavTracker) EpochVerticalVelocity(snap Snapshot, now time.Time) (float64, string) {
+	if vertical, ok := t.VerticalVelocity(now); ok {
+		return vertical, VerticalVelocitySourceUBX
+	}
+	if snap.ClimbRateValid {
+		return snap.ClimbRate, VerticalVelocitySourceAltitude
+	}
+	return 0, ""
+}
+
+func fresh(received, now time.Time) bool {
+	return !received.IsZero() && now.Sub(received) <= navMaxAge
+}
@@ -0,0 +1,74 @@
+package rtkutils
+
+import (
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"go.viam.com/test"
+)
+
+func TestNavTracker(t *testing.T) {
+	var none *NavTracker
+	n, err := none.Write([]byte("$GNGGA"))
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, n, test.ShouldEqual, 6)
+	_, ok := none.Attitude(time.Now())
+	test.That(t, ok, test.ShouldBeFalse)
+	_, ok = none.SolutionStatus(time.Now())
+	test.That(t, ok, test.ShouldBeFalse)
+
+	profile, err := Receiver(ReceiverLC29H)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, NewNavTracker(profile), test.ShouldBeNil)
+	profile, err = Receiver(ReceiverZEDF9R)
+	test.That(t, err, test.ShouldBeNil)
+	tracker := NewNavTracker(profile)
+	test.That(t, tracker, test.ShouldNotBeNil)
+
+	// the frames are split across writes, among nmea sentences and other UBX messages
+	pvt := make([]byte, navPVTPayloadLen)
+	pvt[21] = navFlagGNSSFixOK | CarrierSolutionFloat<<navPVTCarrSolnShift
+	stream := append([]byte("$GNGGA,1*00\r\n"), UBXPacket(UBXClassNav, UBXNavRelPosNED, make([]byte, 64))...)
+	stream = append(stream, UBXPacket(UBXClassNav, UBXNavPVT, pvt)...)
+	stream = append(stream, UBXPacket(UBXClassNav, UBXNavAtt, navAttPayload(0, 0, 4500000, 0))...)
+	tracker.Write(stream[:len(stream)-10])
+	_, ok = tracker.Attitude(time.Now())
+	test.That(t, ok, test.ShouldBeFalse)
+	status, ok := tracker.SolutionStatus(time.Now())
+	test.That(t, ok, test.ShouldBeTrue)
+	test.That(t, status.CarrierSolution, test.ShouldEqual, CarrierSolutionFloat)
+	tracker.Write(stream[len(stream)-10:])
+	att, ok := tracker.Attitude(time.Now())
+	test.That(t, ok, test.ShouldBeTrue)
+	test.That(t, att.Heading, test.ShouldAlmostEqual, 45, 1e-9)
+
+	_, ok = tracker.Attitude(time.Now().Add(3 * time.Second))
+	test.That(t, ok, test.ShouldBeFalse)
+	_, ok = tracker.SolutionStatus(time.Now().Add(3 * time.Second))
+	test.That(t, ok, test.ShouldBeFalse)
+}
+
+func TestVerticalVelocity(t *testing.T) {
+	climbing := Snapshot{ClimbRate: 0.4, ClimbRateValid: true}
+	var none *NavTracker
+	vertical, source := none.EpochVerticalVelocity(climbing, time.Now())
+	test.That(t, vertical, test.ShouldEqual, 0.4)
+	test.That(t, source, test.ShouldEqual, VerticalVelocitySourceAltitude)
+	_, source = none.EpochVerticalVelocity(Snapshot{}, time.Now())
+	test.That(t, source, test.ShouldEqual, "")
+
+	// the receiver's own velocity is preferred while it is fresh
+	tracker := &NavTracker{}
+	pvt := make([]byte, navPVTPayloadLen)
+	pvt[21] = navFlagGNSSFixOK
+	velD := int32(-1200)
+	binary.LittleEndian.PutUint32(pvt[navPVTVelDOffset:], uint32(velD))
+	tracker.Write(UBXPacket(UBXClassNav, UBXNavPVT, pvt))
+	vertical, source = tracker.EpochVerticalVelocity(climbing, time.Now())
+	test.That(t, vertical, test.ShouldAlmostEqual, 1.2, 1e-9)
+	test.That(t, source, test.ShouldEqual, VerticalVelocitySourceUBX)
+	vertical, source = tracker.EpochVerticalVelocity(climbing, time.Now().Add(3*time.Second))
+	test.That(t, vertical, test.ShouldEqual, 0.4)
+	test.That(t, source, test.ShouldEqual, VerticalVelocitySourceAltitude)
+}
@@ -0,0 +1,104 @@
+package rtkutils
+
+import (
+	"time"
+
+	geo "github.com/kellydunn/golang-geo"
+)
+
+// NavSatFixCommand returns the latest epoch of a rover as a ROS sensor_msgs/NavSatFix message, for
+// bridges to ROS 2 to publish as it is.
+const NavSatFixCommand = "navsatfix"
+
+// NavSatStatus statuses and services, and NavSatFix covariance types.
+const (
+	navSatStatusNoFix   = -1
+	navSatStatusFix     = 0
+	navSatStatusSBASFix = 1
+	navSatStatusGBASFix = 2
+
+	navSatServiceGPS = 1
+
+	navSatCovarianceUnknown       = 0
+	navSatCovarianceApproximated  = 1
+	navSatCovarianceDiagonalKnown = 2
+)
+
+// navSatUERE are the errors in m the hdop of a fix quality is scaled by to approximate the
+// covariance of a position, those of ROS's nmea_navsat_driver.
+var navSatUERE = map[int]float64{
+	1:                  4,
+	2:                  0.1,
+	FixQualityRTKFixed: 0.02,
+	FixQualityRTKFloat: 4,
+	9:                  3,
+}
+
+// navSatStatus returns the NavSatStatus status of a GGA fix quality: dgps fixes count as sbas ones,
+// and rtk fixes as augmented by a ground based station.
+func navSatStatus(quality int) int {
+	switch quality {
+	case 0:
+		return navSatStatusNoFix
+	case 2, 9:
+		return navSatStatusSBASFix
+	case FixQualityRTKFixed, FixQualityRTKFloat:
+		return navSatStatusGBASFix
+	default:
+		return navSatStatusFix
+	}
+}
+
+// NavSatFix returns the epoch of snap with accuracy, at pos and alt as the rover reports them, as a
+// NavSatFix stamped with now in frameID. The covariance is diagonal from the horizontal and vertical
+// accuracy when the receiver estimates them, and approximated from the hdop otherwise, the vertical
+// error twice the horizontal one. Without a fix the status is no fix and the covariance unknown. The
+// altitude is above mean sea level as the receiver reports it rather than above the ellipsoid, and
+// the service is gps, nmea doesn't tell which constellations a fix used.
+func NavSatFix(snap Snapshot, pos *geo.Point, alt float64, accuracy map[string]float32, frameID string,
+	now time.Time,
+) map[string]interface{} {
+	status := navSatStatus(snap.Data.FixQuality)
+	lat, lng := 0.0, 0.0
+	if pos == nil {
+		status = navSatStatusNoFix
+	} else {
+		lat, lng = pos.Lat(), pos.Lng()
+	}
+
+	covarianceType := navSatCovarianceUnknown
+	var horizontal, vertical float64
+	hAcc, vAcc := accuracy[AccuracyHorizontal], accuracy[AccuracyVertical]
+	switch {
+	case status == navSatStatusNoFix:
+		alt = 0
+	case hAcc > 0 && vAcc > 0:
+		covarianceType = navSatCovarianceDiagonalKnown
+		horizontal, vertical = float64(hAcc)*float64(hAcc), float64(vAcc)*float64(vAcc)
+	case snap.Data.HDOP > 0:
+		covarianceType = navSatCovarianceApproximated
+		uere, ok := navSatUERE[snap.Data.FixQuality]
+		if !ok {
+			uere = navSatUERE[1]
+		}
+		err := snap.Data.HDOP * uere
+		horizontal, vertical = err*err, 4*err*err
+	}
+	return map[string]interface{}{
+		"header": map[string]interface{}{
+			"stamp":    map[string]interface{}{"sec": now.Unix(), "nanosec": now.Nanosecond()},
+			"frame_id": frameID,
+		},
+		"status":    map[string]interface{}{"status": status, "service": navSatServiceGPS},
+		"latitude":  lat,
+		"longitude": lng,
+		"altitude":  alt,
+		// row major, east, north and up
+		"position_covariance": []interface{}{
+			horizontal, 0.0, 0.0,
+			0.0, horizontal, 0.0,
+			0.0, 0.0, vertical,
+		},
+		"position_covariance_type": covarianceType,
+	}
+}
@@ -0,0 +1,53 @@
+package rtkutils
+
+import (
+	"testing"
+	"time"
+
+	geo "github.com/kellydunn/golang-geo"
+	"go.viam.com/rdk/components/movementsensor/gpsnmea"
+	"go.viam.com/test"
+)
+
+func TestNavSatFix(t *testing.T) {
+	now := time.Unix(1792152000, 250000000)
+	pos := geo.NewPoint(40.5, -105.25)
+	snap := Snapshot{Data: gpsnmea.GPSData{Location: pos, Alt: 1600.5, FixQuality: FixQualityRTKFixed, HDOP: 0.5}}
+
+	// the receiver estimates its accuracy
+	fix := NavSatFix(snap, pos, 1600.5, map[string]float32{AccuracyHorizontal: 0.5, AccuracyVertical: 2}, "gps", now)
+	test.That(t, fix, test.ShouldResemble, map[string]interface{}{
+		"header": map[string]interface{}{
+			"stamp":    map[string]interface{}{"sec": int64(1792152000), "nanosec": 250000000},
+			"frame_id": "gps",
+		},
+		"status":    map[string]interface{}{"status": navSatStatusGBASFix, "service": navSatServiceGPS},
+		"latitude":  40.5,
+		"longitude": -105.25,
+		"altitude":  1600.5,
+		"position_covariance": []interface{}{
+			0.25, 0.0, 0.0,
+			0.0, 0.25, 0.0,
+			0.0, 0.0, 4.0,
+		},
+		"position_covariance_type": navSatCovarianceDiagonalKnown,
+	})
+
+	// approximated from the hdop of a fixed solution
+	fix = NavSatFix(snap, pos, 1600.5, map[string]float32{}, "gps", now)
+	test.That(t, fix["position_covariance_type"], test.ShouldEqual, navSatCovarianceApproximated)
+	covariance := fix["position_covariance"].([]interface{})
+	test.That(t, covariance[0], test.ShouldAlmostEqual, 0.0001, 1e-12)
+	test.That(t, covariance[8], test.ShouldAlmostEqual, 0.0004, 1e-12)
+
+	// a dgps fix
+	snap.Data.FixQuality = 2
+	test.That(t, NavSatFix(snap, pos, 1600.5, nil, "gps", now)["status"], test.ShouldResemble,
+		map[string]interface{}{"status": navSatStatusSBASFix, "service": navSatServiceGPS})
+
+	// no fix
+	fix = NavSatFix(Snapshot{}, nil, 0, nil, "gps", now)
+	test.That(t, fix["status"], test.ShouldResemble, map[string]interface{}{"status": navSatStatusNoFix, "service": navSatServiceGPS})
+	test.That(t, fix["position_covariance_type"], test.ShouldEqual, navSatCovarianceUnknown)
+	test.That(t, fix["latitude"], test.ShouldEqual, 0.0)
+}
@@ -0,0 +1,267 @@
+package rtkutils
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/edaniels/golog"
+	"go.uber.org/multierr"
+)
+
+const (
+	// DefaultNtripRetryInterval is how long an NtripReader waits before reconnecting to the caster.
+	DefaultNtripRetryInterval = 5 * time.Second
+
+	ntripDialTimeout = 10 * time.Second
+	// casters send corrections every second, a stream silent for longer than this has dropped
+	ntripReadTimeout = 30 * time.Second
+	ntripDefaultPort = "2101"
+	ntripTLSPort     = "443"
+)
+
+var errNtripNotConnected = errors.New("not connected to the ntrip caster")
+
+// NtripConfig describes the mountpoint of an NTRIP caster to read corrections from.
+type NtripConfig struct {
+	URL        string // caster address, e.g. "http://caster.example.com:2101", optionally ending in the mountpoint
+	Mountpoint string
+	Username   string
+	Password   string
+	TLS        TLSConfig // also enabled by an https URL
+}
+
+// Validate checks that the config names a caster and a mountpoint.
+func (c NtripConfig) Validate() error {
+	_, mountpoint, err := c.address()
+	if err != nil {
+		return err
+	}
+	if mountpoint == "" {
+		return errors.New("ntrip_mountpoint is required with ntrip_url")
+	}
+	return c.tlsConfig().Validate("")
+}
+
+// tlsConfig returns the TLS config of the connection to the caster.
+func (c NtripConfig) tlsConfig() TLSConfig {
+	conf := c.TLS
+	if strings.HasPrefix(strings.ToLower(c.URL), "https://") {
+		conf.Enabled = true
+	}
+	return conf
+}
+
+// address returns the host:port of the caster and the mountpoint to request.
+func (c NtripConfig) address() (string, string, error) {
+	raw := c.URL
+	if !strings.Contains(raw, "://") {
+		raw = "http://" + raw
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		// the error of url.Parse quotes the whole url, credentials and all
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return "", "", fmt.Errorf("invalid ntrip_url %q: %w", RedactURL(c.URL), err)
+	}
+	if u.Hostname() == "" {
+		return "", "", fmt.Errorf("invalid ntrip_url %q: no host", RedactURL(c.URL))
+	}
+	port := u.Port()
+	if port == "" && u.Scheme == "https" {
+		port = ntripTLSPort
+	} else if port == "" {
+		port = ntripDefaultPort
+	}
+	mountpoint := c.Mountpoint
+	if mountpoint == "" {
+		mountpoint = strings.Trim(u.Path, "/")
+	}
+	return net.JoinHostPort(u.Hostname(), port), mountpoint, nil
+}
+
+// NtripReader reads the rtcm stream of an NTRIP caster mountpoint. The connection is made on the
+// first read and remade whenever the stream drops, so reads only fail once ctx is done or the
+// reader is closed.
+type NtripReader struct {
+	ctx      context.Context
+	conf     NtripConfig
+	interval time.Duration
+	logger   golog.Logger
+
+	mu      sync.Mutex
+	conn    net.Conn
+	body    *bufio.Reader
+	err     error // why the reader is not connected
+	closed  bool
+	failing bool
+}
+
+// NewNtripReader returns a reader of the mountpoint in conf, reconnecting every interval after a failure.
+func NewNtripReader(ctx context.Context, conf NtripConfig, interval time.Duration, logger golog.Logger) *NtripReader {
+	if interval <= 0 {
+		interval = DefaultNtripRetryInterval
+	}
+	return &NtripReader{ctx: ctx, conf: conf, interval: interval, logger: logger, err: errNtripNotConnected}
+}
+
+// Read copies the next corrections from the caster into p.
+func (r *NtripReader) Read(p []byte) (int, error) {
+	for {
+		conn, body, err := r.stream()
+		if err != nil {
+			return 0, err
+		}
+		if body == nil {
+			conn, body, err = r.connect()
+		}
+		if err == nil {
+			if err = conn.SetReadDeadline(time.Now().Add(ntripReadTimeout)); err == nil {
+				var n int
+				n, err = body.Read(p)
+				if n > 0 {
+					return n, nil
+				}
+			}
+		}
+
+		if _, _, closedErr := r.stream(); closedErr != nil {
+			return 0, closedErr
+		}
+		r.disconnect(err)
+		select {
+		case <-r.ctx.Done():
+			return 0, r.ctx.Err()
+		case <-time.After(r.interval):
+		}
+	}
+}
+
+// stream returns the current connection, or an error once the reader is closed.
+func (r *NtripReader) stream() (net.Conn, *bufio.Reader, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return nil, nil, errors.New("ntrip reader is closed")
+	}
+	if err := r.ctx.Err(); err != nil {
+		return nil, nil, err
+	}
+	return r.conn, r.body, nil
+}
+
+// connect requests the mountpoint from the caster, accepting both NTRIP 1 ("ICY 200 OK") and
+// NTRIP 2 (HTTP) responses.
+func (r *NtripReader) connect() (net.Conn, *bufio.Reader, error) {
+	addr, mountpoint, err := r.conf.address()
+	if err != nil {
+		return nil, nil, err
+	}
+	conn, err := DialTCP(r.ctx, addr, ntripDialTimeout, r.conf.tlsConfig())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	req := fmt.Sprintf("GET /%s HTTP/1.0\r\nHost: %s\r\nUser-Agent: NTRIP rtk-system\r\n", mountpoint, addr)
+	if r.conf.Username != "" || r.conf.Password != "" {
+		auth := base64.StdEncoding.EncodeToString([]byte(r.conf.Username + ":" + r.conf.Password))
+		req += "Authorization: Basic " + auth + "\r\n"
+	}
+	req += "\r\n"
+
+	body := bufio.NewReader(conn)
+	if err := conn.SetDeadline(time.Now().Add(ntripDialTimeout)); err != nil {
+		return nil, nil, multierr.Combine(err, conn.Close())
+	}
+	if err := readNtripResponse(conn, body, req, mountpoint); err != nil {
+		return nil, nil, multierr.Combine(err, conn.Close())
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return nil, nil, multierr.Combine(errors.New("ntrip reader is closed"), conn.Close())
+	}
+	r.conn, r.body, r.err = conn, body, nil
+	if r.failing {
+		r.logger.Infof("reading corrections from ntrip mountpoint %s again", mountpoint)
+		r.failing = false
+	} else {
+		r.logger.Infof("connected to ntrip mountpoint %s", mountpoint)
+	}
+	return conn, body, nil
+}
+
+func readNtripResponse(conn net.Conn, body *bufio.Reader, req, mountpoint string) error {
+	if _, err := conn.Write([]byte(req)); err != nil {
+		return err
+	}
+	status, err := body.ReadString('\n')
+	if err != nil {
+		return fmt.Errorf("no response from the ntrip caster: %w", err)
+	}
+	status = strings.TrimSpace(status)
+	switch {
+	case status == "ICY 200 OK":
+		return nil
+	case strings.HasPrefix(status, "HTTP/") && strings.Contains(status, " 200"):
+		// skip the headers of an NTRIP 2 response
+		for {
+			line, err := body.ReadString('\n')
+			if err != nil {
+				return err
+			}
+			if strings.TrimSpace(line) == "" {
+				return nil
+			}
+		}
+	case strings.HasPrefix(status, "SOURCETABLE"):
+		return fmt.Errorf("the ntrip caster has no mountpoint %q", mountpoint)
+	default:
+		return fmt.Errorf("the ntrip caster refused the connection: %s", status)
+	}
+}
+
+// disconnect drops the current connection after err, logging the first of consecutive failures.
+func (r *NtripReader) disconnect(err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.conn != nil {
+		err = multierr.Combine(err, r.conn.Close())
+	}
+	r.conn, r.body, r.err = nil, nil, err
+	if !r.failing {
+		r.logger.Warnf("can't read corrections from ntrip caster %s, retrying: %v", RedactURL(r.conf.URL), err)
+		r.failing = true
+	}
+}
+
+// Err returns why the reader is not connected to the caster, or nil if it is.
+func (r *NtripReader) Err() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.err
+}
+
+// Close closes the connection to the caster, unblocking a pending read.
+func (r *NtripReader) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.closed = true
+	if r.conn == nil {
+		return nil
+	}
+	err := r.conn.Close()
+	r.conn, r.body, r.err = nil, nil, errNtripNotConnected
+	return err
+}
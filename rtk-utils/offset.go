@@ -0,0 +1,138 @@
+package rtkutils
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	geo "github.com/kellydunn/golang-geo"
+)
+
+// CalibrateOffsetCommand measures the offset between a known point the antenna is placed on, given
+// as "lat" and "lng", and the position of the rover there, which is then added to every position
+// it reports. Given "clear": true it removes the offset.
+const CalibrateOffsetCommand = "calibrate_offset"
+
+// EventOffsetCalibrated is raised when an offset is calibrated or cleared.
+const EventOffsetCalibrated = "offset_calibrated"
+
+const (
+	// DefaultCalibrationEpochs is the number of rtk fixed epochs averaged to calibrate an offset.
+	DefaultCalibrationEpochs = 10
+	// DefaultCalibrationEpochTimeout is how long calibrating an offset waits for every epoch.
+	DefaultCalibrationEpochTimeout = 5 * time.Second
+	// offsets larger than this are taken for a wrong known point rather than calibrated
+	maxCalibrationOffset = 100 // m
+)
+
+// CalibrationPoint returns the known point of a CalibrateOffsetCommand.
+func CalibrationPoint(cmd map[string]interface{}) (*geo.Point, error) {
+	lat, latOK := cmd["lat"].(float64)
+	lng, lngOK := cmd["lng"].(float64)
+	if !latOK || !lngOK {
+		return nil, errors.New(`calibrate_offset needs the "lat" and "lng" of the known point`)
+	}
+	if lat < -90 || lat > 90 || lng < -180 || lng > 180 {
+		return nil, fmt.Errorf("the known point %f, %f isn't a valid position", lat, lng)
+	}
+	return geo.NewPoint(lat, lng), nil
+}
+
+// PositionOffset is a constant offset, in m north and east, added to the positions a rover reports
+// to align them with a known point of a site, such as a survey marker, for a quick check of the
+// alignment of a site. It is measured in the datum the rover reports and lasts until the rover is
+// reconfigured. The zero value has no offset and is safe for concurrent use.
+type PositionOffset struct {
+	mu           sync.Mutex
+	calibrated   bool
+	north, east  float64 // m
+	known        *geo.Point
+	measured     *geo.Point // average of the epochs measured
+	epochs       int
+	calibratedAt time.Time
+}
+
+// Calibrate sets the offset from the average of the positions measured on the known point.
+func (o *PositionOffset) Calibrate(known *geo.Point, measured []*geo.Point, now time.Time) error {
+	if len(measured) == 0 {
+		return errors.New("no position was measured on the known point")
+	}
+	var lat, lng float64
+	for _, pos := range measured {
+		lat += pos.Lat()
+		lng += pos.Lng()
+	}
+	average := geo.NewPoint(lat/float64(len(measured)), lng/float64(len(measured)))
+	north := (known.Lat() - average.Lat()) * metersPerDegree
+	east := (known.Lng() - average.Lng()) * metersPerDegree * math.Cos(average.Lat()*math.Pi/180)
+	if offset := math.Hypot(north, east); offset > maxCalibrationOffset {
+		return fmt.Errorf("the known point is %.1f m from the measured position, more than %d m, check its coordinates",
+			offset, maxCalibrationOffset)
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.calibrated, o.north, o.east = true, north, east
+	o.known, o.measured, o.epochs, o.calibratedAt = known, average, len(measured), now
+	return nil
+}
+
+// Clear removes the offset.
+func (o *PositionOffset) Clear() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.calibrated, o.north, o.east = false, 0, 0
+	o.known, o.measured, o.epochs, o.calibratedAt = nil, nil, 0, time.Time{}
+}
+
+// Apply returns pos with the offset added.
+func (o *PositionOffset) Apply(pos *geo.Point) *geo.Point {
+	o.mu.Lock()
+	north, east, calibrated := o.north, o.east, o.calibrated
+	o.mu.Unlock()
+	if !calibrated || pos == nil || math.IsNaN(pos.Lat()) || math.IsNaN(pos.Lng()) {
+		return pos
+	}
+	return shiftPoint(pos, north, east)
+}
+
+// shiftPoint returns pos moved north and east by the given meters.
+func shiftPoint(pos *geo.Point, north, east float64) *geo.Point {
+	lat := pos.Lat() + north/metersPerDegree
+	lng := pos.Lng()
+	if cos := math.Cos(pos.Lat() * math.Pi / 180); cos > 1e-9 {
+		lng += east / (metersPerDegree * cos)
+	}
+	return geo.NewPoint(lat, lng)
+}
+
+// Result answers a CalibrateOffsetCommand with the offset, and what it was calibrated from.
+func (o *PositionOffset) Result() map[string]interface{} {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	result := map[string]interface{}{
+		"calibrated":     o.calibrated,
+		"offset_north_m": o.north,
+		"offset_east_m":  o.east,
+		"offset_m":       math.Hypot(o.north, o.east),
+	}
+	if o.calibrated {
+		result["known"] = pointResult(o.known)
+		result["measured"] = pointResult(o.measured)
+		result["epochs"] = o.epochs
+		result["calibrated_at"] = o.calibratedAt.UTC().Format(time.RFC3339Nano)
+	}
+	return result
+}
+
+// Readings returns whether an offset is added to the positions reported, and its length.
+func (o *PositionOffset) Readings() map[string]interface{} {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return map[string]interface{}{
+		"offset_calibrated": o.calibrated,
+		"offset_m":          math.Hypot(o.north, o.east),
+	}
+}
@@ -0,0 +1,56 @@
+package rtkutils
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	geo "github.com/kellydunn/golang-geo"
+	"go.viam.com/test"
+)
+
+func TestCalibrationPoint(t *testing.T) {
+	_, err := CalibrationPoint(map[string]interface{}{"lat": 40.0})
+	test.That(t, err, test.ShouldBeError, errors.New(`calibrate_offset needs the "lat" and "lng" of the known point`))
+	_, err = CalibrationPoint(map[string]interface{}{"lat": 91.0, "lng": 0.0})
+	test.That(t, err, test.ShouldBeError, errors.New("the known point 91.000000, 0.000000 isn't a valid position"))
+	known, err := CalibrationPoint(map[string]interface{}{"lat": 40.0, "lng": -74.0})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, known.Lat(), test.ShouldEqual, 40.0)
+	test.That(t, known.Lng(), test.ShouldEqual, -74.0)
+}
+
+func TestPositionOffset(t *testing.T) {
+	var offset PositionOffset
+	pos := geo.NewPoint(40, -74)
+	test.That(t, offset.Apply(pos), test.ShouldEqual, pos)
+	test.That(t, offset.Readings(), test.ShouldResemble, map[string]interface{}{"offset_calibrated": false, "offset_m": 0.0})
+
+	now := time.Now()
+	test.That(t, offset.Calibrate(pos, nil, now), test.ShouldBeError, errors.New("no position was measured on the known point"))
+
+	// measured 1 m south and 2 m west of the known point, on average
+	measured := []*geo.Point{
+		pos.PointAtDistanceAndBearing(0.001, 180).PointAtDistanceAndBearing(0.0015, 270),
+		pos.PointAtDistanceAndBearing(0.001, 180).PointAtDistanceAndBearing(0.0025, 270),
+	}
+	test.That(t, offset.Calibrate(pos, measured, now), test.ShouldBeNil)
+	result := offset.Result()
+	test.That(t, result["calibrated"], test.ShouldBeTrue)
+	test.That(t, result["offset_north_m"], test.ShouldAlmostEqual, 1, 0.01)
+	test.That(t, result["offset_east_m"], test.ShouldAlmostEqual, 2, 0.01)
+	test.That(t, result["epochs"], test.ShouldEqual, 2)
+
+	corrected := offset.Apply(measured[0])
+	test.That(t, corrected.GreatCircleDistance(pos.PointAtDistanceAndBearing(0.0005, 90))*1000, test.ShouldBeLessThan, 0.01)
+	test.That(t, offset.Readings()["offset_calibrated"], test.ShouldBeTrue)
+
+	far := []*geo.Point{pos.PointAtDistanceAndBearing(0.2, 0)}
+	test.That(t, offset.Calibrate(pos, far, now), test.ShouldBeError,
+		errors.New("the known point is 200.0 m from the measured position, more than 100 m, check its coordinates"))
+	test.That(t, offset.Result()["offset_east_m"], test.ShouldAlmostEqual, 2, 0.01)
+
+	offset.Clear()
+	test.That(t, offset.Apply(pos), test.ShouldEqual, pos)
+	test.That(t, offset.Result()["calibrated"], test.ShouldBeFalse)
+}
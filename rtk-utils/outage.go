@@ -0,0 +1,116 @@
+package rtkutils
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	geo "github.com/kellydunn/golang-geo"
+)
+
+// Fix outage event types.
+const (
+	EventFixLost     = "fix_lost"
+	EventFixRegained = "fix_regained"
+)
+
+// OutagesCommand returns the recent outages of a rover's fix, for mapping the coverage of a site.
+const OutagesCommand = "outages"
+
+// number of outages kept by an OutageTracker.
+const outageLogSize = 100
+
+// Outage is a time a rover had no fix. Before and After are its last position with a fix before the
+// outage and its first after it, which bound where it lost and regained the fix.
+type Outage struct {
+	Start  time.Time
+	End    time.Time // zero while the outage goes on
+	Before *geo.Point
+	After  *geo.Point // nil while the outage goes on
+}
+
+// OutageTracker records the outages of a rover's fix and raises an event when the fix is lost and
+// another, with how long it lasted and how far the rover went meanwhile, when it is regained. Epochs
+// only count once the rover had a fix, so a rover starting without one reports no outage. The zero
+// value is ready to use and safe for concurrent use.
+type OutageTracker struct {
+	mu      sync.Mutex
+	lastFix *geo.Point
+	current *Outage
+	outages []Outage // ended, oldest to newest
+}
+
+// Update takes the fix quality and position of an epoch read at now, adding an event to events when
+// the fix is lost or regained.
+func (t *OutageTracker) Update(fixQuality int, pos *geo.Point, now time.Time, events *EventLog) {
+	fixed := fixQuality != 0 && pos != nil && !math.IsNaN(pos.Lat()) && !math.IsNaN(pos.Lng())
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	switch {
+	case fixed && t.current != nil:
+		outage := *t.current
+		outage.End, outage.After = now, pos
+		t.current = nil
+		t.outages = append(t.outages, outage)
+		if len(t.outages) > outageLogSize {
+			t.outages = t.outages[1:]
+		}
+		events.Add(EventFixRegained, "fix regained after %s, %.1f m from where it was lost",
+			outage.End.Sub(outage.Start).Round(time.Millisecond), outage.Before.GreatCircleDistance(pos)*1000)
+	case !fixed && t.current == nil && t.lastFix != nil:
+		t.current = &Outage{Start: now, Before: t.lastFix}
+		events.Add(EventFixLost, "fix lost")
+	}
+	if fixed {
+		t.lastFix = pos
+	}
+}
+
+// Outages returns the recorded outages, oldest first, ending with the current one if the fix is lost.
+func (t *OutageTracker) Outages() []Outage {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	outages := append([]Outage(nil), t.outages...)
+	if t.current != nil {
+		outages = append(outages, *t.current)
+	}
+	return outages
+}
+
+// OutagesResult answers an OutagesCommand with the outages of t, their positions reduced by privacy.
+// The bounds of an ended outage are the box of the positions it is between.
+func (t *OutageTracker) OutagesResult(privacy *PositionPrivacy, now time.Time) map[string]interface{} {
+	outages := []interface{}{}
+	var total time.Duration
+	for _, o := range t.Outages() {
+		end := o.End
+		if end.IsZero() {
+			end = now
+		}
+		total += end.Sub(o.Start)
+		outage := map[string]interface{}{
+			"start":        o.Start.UTC().Format(time.RFC3339Nano),
+			"duration_sec": end.Sub(o.Start).Seconds(),
+			"ongoing":      o.End.IsZero(),
+		}
+		if !o.End.IsZero() {
+			outage["end"] = o.End.UTC().Format(time.RFC3339Nano)
+		}
+		before := privacy.Reduce(o.Before)
+		outage["before"] = pointResult(before)
+		if o.After != nil {
+			after := privacy.Reduce(o.After)
+			outage["after"] = pointResult(after)
+			outage["bounds"] = map[string]interface{}{
+				"min_lat": math.Min(before.Lat(), after.Lat()), "max_lat": math.Max(before.Lat(), after.Lat()),
+				"min_lng": math.Min(before.Lng(), after.Lng()), "max_lng": math.Max(before.Lng(), after.Lng()),
+			}
+		}
+		outages = append(outages, outage)
+	}
+	return map[string]interface{}{"outages": outages, "total_outage_sec": total.Seconds()}
+}
+
+func pointResult(pos *geo.Point) map[string]interface{} {
+	return map[string]interface{}{"lat": pos.Lat(), "lng": pos.Lng()}
+}
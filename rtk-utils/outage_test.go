@@ -0,0 +1,61 @@
+package rtkutils
+
+import (
+	"testing"
+	"time"
+
+	"github.com/edaniels/golog"
+	geo "github.com/kellydunn/golang-geo"
+	"go.viam.com/test"
+)
+
+func TestOutageTracker(t *testing.T) {
+	events := NewEventLog(golog.NewTestLogger(t))
+	var tracker OutageTracker
+	start := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	// a rover starting without a fix has no outage
+	tracker.Update(0, nil, start, events)
+	test.That(t, tracker.Outages(), test.ShouldBeEmpty)
+
+	before := geo.NewPoint(37.0, -122.0)
+	after := geo.NewPoint(37.001, -122.002)
+	tracker.Update(4, before, start.Add(time.Second), events)
+	tracker.Update(0, before, start.Add(2*time.Second), events)
+	tracker.Update(0, before, start.Add(3*time.Second), events)
+	test.That(t, tracker.Outages(), test.ShouldResemble, []Outage{{Start: start.Add(2 * time.Second), Before: before}})
+
+	result := tracker.OutagesResult(nil, start.Add(5*time.Second))
+	test.That(t, result["total_outage_sec"], test.ShouldEqual, 3.0)
+	test.That(t, result["outages"], test.ShouldResemble, []interface{}{map[string]interface{}{
+		"start":        "2026-05-01T12:00:02Z",
+		"duration_sec": 3.0,
+		"ongoing":      true,
+		"before":       map[string]interface{}{"lat": 37.0, "lng": -122.0},
+	}})
+
+	tracker.Update(1, after, start.Add(12*time.Second), events)
+	tracker.Update(1, after, start.Add(13*time.Second), events)
+	result = tracker.OutagesResult(nil, start.Add(20*time.Second))
+	test.That(t, result["total_outage_sec"], test.ShouldEqual, 10.0)
+	test.That(t, result["outages"], test.ShouldResemble, []interface{}{map[string]interface{}{
+		"start":        "2026-05-01T12:00:02Z",
+		"end":          "2026-05-01T12:00:12Z",
+		"duration_sec": 10.0,
+		"ongoing":      false,
+		"before":       map[string]interface{}{"lat": 37.0, "lng": -122.0},
+		"after":        map[string]interface{}{"lat": 37.001, "lng": -122.002},
+		"bounds":       map[string]interface{}{"min_lat": 37.0, "max_lat": 37.001, "min_lng": -122.002, "max_lng": -122.0},
+	}})
+
+	all := events.Events("")
+	test.That(t, len(all), test.ShouldEqual, 2)
+	test.That(t, all[0].Type, test.ShouldEqual, EventFixLost)
+	test.That(t, all[1].Type, test.ShouldEqual, EventFixRegained)
+	test.That(t, all[1].Message, test.ShouldStartWith, "fix regained after 10s, ")
+
+	// positions are reported at the precision privacy allows
+	grid := NewPositionPrivacy(1000, 0, "")
+	reduced := tracker.OutagesResult(grid, start)["outages"].([]interface{})[0].(map[string]interface{})
+	test.That(t, reduced["before"], test.ShouldResemble, pointResult(grid.Reduce(before)))
+}
@@ -0,0 +1,194 @@
+package rtkutils
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// VerifyPairCommand compares the rtcm a rover receives with what its correction station sends and
+// what its receiver tracks, and reports the mismatches, such as a base sending GLONASS MSM to a rover
+// whose receiver has GLONASS disabled.
+const VerifyPairCommand = "verify_pair"
+
+// gsvConstellations are the constellations of the GSV sentences of each talker.
+var gsvConstellations = map[string]string{
+	"GP": "gps", "GL": "glonass", "GA": "galileo", "GB": "beidou", "BD": "beidou", "GQ": "qzss",
+}
+
+// RTCMContent is the reference stations and MSM constellations of an rtcm stream, sorted.
+type RTCMContent struct {
+	Stations       []int
+	Constellations []string
+}
+
+// Content returns the content of the frames of b.
+func (b *RTCMBuffer) Content() RTCMContent {
+	frames := []interface{}{}
+	for _, frame := range b.Last(RTCMBufferSize) {
+		frames = append(frames, DecodeRTCMFrame(frame))
+	}
+	return RTCMContentOf(map[string]interface{}{"frames": frames})
+}
+
+// RTCMContentOf returns the content of the frames of a DecodeRTCMCommand result, whose numbers are
+// floats once it came from another robot.
+func RTCMContentOf(decoded map[string]interface{}) RTCMContent {
+	stations := map[int]bool{}
+	constellations := map[string]bool{}
+	frames, _ := decoded["frames"].([]interface{})
+	for _, frame := range frames {
+		summary, ok := frame.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		switch id := summary["station_id"].(type) {
+		case int:
+			stations[id] = true
+		case float64:
+			stations[int(id)] = true
+		}
+		if constellation, ok := summary["constellation"].(string); ok {
+			constellations[constellation] = true
+		}
+	}
+	var content RTCMContent
+	for id := range stations {
+		content.Stations = append(content.Stations, id)
+	}
+	sort.Ints(content.Stations)
+	for constellation := range constellations {
+		content.Constellations = append(content.Constellations, constellation)
+	}
+	sort.Strings(content.Constellations)
+	return content
+}
+
+// TrackedConstellations returns the constellations a receiver reported satellites of in the GSV
+// sentences it output, sorted, none if it outputs no GSV.
+func TrackedConstellations(counts []SentenceCounts) []string {
+	tracked := []string{}
+	seen := map[string]bool{}
+	for _, c := range counts {
+		constellation, ok := gsvConstellations[c.Talker]
+		if c.Type != "GSV" || c.Parsed == 0 || !ok || seen[constellation] {
+			continue
+		}
+		seen[constellation] = true
+		tracked = append(tracked, constellation)
+	}
+	sort.Strings(tracked)
+	return tracked
+}
+
+// PairCheck is what VerifyPair compares.
+type PairCheck struct {
+	Station  *RTCMContent // what the station sends, nil if unknown
+	Received RTCMContent  // what the rover received
+	// constellations the receiver tracks, none if it outputs no GSV
+	Tracked []string
+	// reference station ID of the receiver's fixes, empty without corrections
+	ReferenceStation string
+}
+
+// VerifyPair answers a VerifyPairCommand with the mismatches of c, which is "ok" without any.
+func VerifyPair(c PairCheck) map[string]interface{} {
+	mismatches := []interface{}{}
+	mismatch := func(format string, args ...interface{}) {
+		mismatches = append(mismatches, fmt.Sprintf(format, args...))
+	}
+
+	received := c.Received
+	switch len(received.Stations) {
+	case 0:
+		mismatch("the rover received no rtcm with a station id")
+	case 1:
+	default:
+		mismatch("the rover receives the rtcm of several stations: %s", joinInts(received.Stations))
+	}
+	if c.Station != nil {
+		if len(c.Station.Stations) > 0 && len(received.Stations) > 0 &&
+			joinInts(c.Station.Stations) != joinInts(received.Stations) {
+			mismatch("the station sends station id %s but the rover receives %s",
+				joinInts(c.Station.Stations), joinInts(received.Stations))
+		}
+		for _, constellation := range missing(c.Station.Constellations, received.Constellations) {
+			mismatch("the station sends %s msm but the rover doesn't receive it", constellation)
+		}
+		for _, constellation := range missing(received.Constellations, c.Station.Constellations) {
+			mismatch("the rover receives %s msm the station doesn't send", constellation)
+		}
+	}
+	if len(c.Tracked) > 0 {
+		for _, constellation := range missing(received.Constellations, c.Tracked) {
+			mismatch("the rover receives %s msm but its receiver doesn't track %s, enable it on the receiver "+
+				"or disable it on the base", constellation, constellation)
+		}
+	}
+	if id, err := strconv.Atoi(c.ReferenceStation); err == nil && len(received.Stations) > 0 &&
+		!containsInt(received.Stations, id) {
+		mismatch("the receiver's fixes use station %d but the rover receives %s", id, joinInts(received.Stations))
+	}
+
+	result := map[string]interface{}{
+		"ok":                      len(mismatches) == 0,
+		"mismatches":              mismatches,
+		"received_station_ids":    intsResult(received.Stations),
+		"received_constellations": stringsResult(received.Constellations),
+		"tracked_constellations":  stringsResult(c.Tracked),
+	}
+	if c.Station != nil {
+		result["station_ids"] = intsResult(c.Station.Stations)
+		result["station_constellations"] = stringsResult(c.Station.Constellations)
+	}
+	return result
+}
+
+// missing returns the items of want that aren't in have.
+func missing(want, have []string) []string {
+	var out []string
+	for _, w := range want {
+		found := false
+		for _, h := range have {
+			found = found || h == w
+		}
+		if !found {
+			out = append(out, w)
+		}
+	}
+	return out
+}
+
+func containsInt(list []int, v int) bool {
+	for _, item := range list {
+		if item == v {
+			return true
+		}
+	}
+	return false
+}
+
+func joinInts(list []int) string {
+	parts := make([]string, 0, len(list))
+	for _, v := range list {
+		parts = append(parts, strconv.Itoa(v))
+	}
+	return strings.Join(parts, ", ")
+}
+
+func intsResult(list []int) []interface{} {
+	out := []interface{}{}
+	for _, v := range list {
+		out = append(out, v)
+	}
+	return out
+}
+
+func stringsResult(list []string) []interface{} {
+	out := []interface{}{}
+	for _, v := range list {
+		out = append(out, v)
+	}
+	return out
+}
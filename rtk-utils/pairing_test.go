@@ -0,0 +1,82 @@
+package rtkutils
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/go-gnss/rtcm/rtcm3"
+	"go.viam.com/test"
+)
+
+func TestRTCMContent(t *testing.T) {
+	var b RTCMBuffer
+	test.That(t, b.Content(), test.ShouldResemble, RTCMContent{})
+
+	b.Add(rtcm3.EncapsulateByteArray([]byte{0x3e, 0xd0, 0x01, 0x02, 0x03}).Serialize(), gpsMSMFrame(1000), gpsMSMFrame(2000))
+	test.That(t, b.Content(), test.ShouldResemble, RTCMContent{Stations: []int{1}, Constellations: []string{"gps"}})
+
+	// as decoded by a station on another robot
+	content := RTCMContentOf(map[string]interface{}{"frames": []interface{}{
+		map[string]interface{}{"message_type": 1087.0, "station_id": 7.0, "constellation": "glonass"},
+		map[string]interface{}{"message_type": 1077.0, "station_id": 7.0, "constellation": "gps"},
+		map[string]interface{}{"message_type": 1019.0},
+	}})
+	test.That(t, content, test.ShouldResemble, RTCMContent{Stations: []int{7}, Constellations: []string{"glonass", "gps"}})
+}
+
+func TestTrackedConstellations(t *testing.T) {
+	var stats SentenceStats
+	test.That(t, TrackedConstellations(stats.Get()), test.ShouldBeEmpty)
+
+	stats.Record("$GPGSV,1,1,00*79", nil)
+	stats.Record("$GAGSV,1,1,00*68", nil)
+	stats.Record("$GLGSV,1,1,00*65", errors.New("bad"))
+	stats.Record("$GNGGA,,,,,,0,,,,,,,,*78", nil)
+	test.That(t, TrackedConstellations(stats.Get()), test.ShouldResemble, []string{"galileo", "gps"})
+}
+
+func TestVerifyPair(t *testing.T) {
+	station := RTCMContent{Stations: []int{7}, Constellations: []string{"glonass", "gps"}}
+	result := VerifyPair(PairCheck{
+		Station:          &station,
+		Received:         station,
+		Tracked:          []string{"galileo", "glonass", "gps"},
+		ReferenceStation: "0007",
+	})
+	test.That(t, result["ok"], test.ShouldBeTrue)
+	test.That(t, result["mismatches"], test.ShouldBeEmpty)
+	test.That(t, result["station_ids"], test.ShouldResemble, []interface{}{7})
+	test.That(t, result["received_constellations"], test.ShouldResemble, []interface{}{"glonass", "gps"})
+
+	// a base sending glonass the rover has disabled, and a second base on the same radio channel
+	result = VerifyPair(PairCheck{
+		Station:          &station,
+		Received:         RTCMContent{Stations: []int{3, 7}, Constellations: []string{"glonass", "gps"}},
+		Tracked:          []string{"gps"},
+		ReferenceStation: "3",
+	})
+	test.That(t, result["ok"], test.ShouldBeFalse)
+	test.That(t, result["mismatches"], test.ShouldResemble, []interface{}{
+		"the rover receives the rtcm of several stations: 3, 7",
+		"the station sends station id 7 but the rover receives 3, 7",
+		"the rover receives glonass msm but its receiver doesn't track glonass, enable it on the receiver or disable it on the base",
+	})
+
+	// without the station, a rover missing constellations and fixing on another station
+	result = VerifyPair(PairCheck{
+		Received:         RTCMContent{Stations: []int{7}, Constellations: []string{"gps"}},
+		ReferenceStation: "12",
+	})
+	test.That(t, result["mismatches"], test.ShouldResemble, []interface{}{
+		"the receiver's fixes use station 12 but the rover receives 7",
+	})
+	_, ok := result["station_ids"]
+	test.That(t, ok, test.ShouldBeFalse)
+
+	result = VerifyPair(PairCheck{Station: &station})
+	test.That(t, result["mismatches"], test.ShouldResemble, []interface{}{
+		"the rover received no rtcm with a station id",
+		"the station sends glonass msm but the rover doesn't receive it",
+		"the station sends gps msm but the rover doesn't receive it",
+	})
+}
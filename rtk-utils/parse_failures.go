@@ -0,0 +1,108 @@
+package rtkutils
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultParseFailureRate is the number of parse failures per minute above which raw sentences are captured.
+	DefaultParseFailureRate = 10
+	// DefaultParseFailureLogSize caps the size of the capture file in bytes.
+	DefaultParseFailureLogSize = 1 << 20
+
+	parseFailureWindow = time.Minute
+)
+
+// ParseFailureRecorder captures raw sentences that fail to parse to a size capped file once
+// failures happen more often than the configured rate, so bug reports can include the data.
+// A nil recorder records nothing.
+type ParseFailureRecorder struct {
+	path      string
+	threshold int
+	maxBytes  int64
+
+	mu       sync.Mutex
+	failures []time.Time // failures within the last window, oldest first
+	file     *os.File
+	written  int64
+}
+
+// NewParseFailureRecorder returns a recorder writing to path, or nil if path is empty.
+// Zero values of ratePerMin and maxBytes use the defaults.
+func NewParseFailureRecorder(path string, ratePerMin int, maxBytes int64) *ParseFailureRecorder {
+	if path == "" {
+		return nil
+	}
+	if ratePerMin <= 0 {
+		ratePerMin = DefaultParseFailureRate
+	}
+	if maxBytes <= 0 {
+		maxBytes = DefaultParseFailureLogSize
+	}
+	return &ParseFailureRecorder{path: path, threshold: ratePerMin, maxBytes: maxBytes}
+}
+
+// Record notes that line failed to parse with err. When the failure rate is over the threshold
+// the line is appended to the capture file and the returned error references the file.
+func (r *ParseFailureRecorder) Record(line string, err error) error {
+	if r == nil || err == nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	r.failures = append(r.failures, now)
+	for len(r.failures) > 0 && now.Sub(r.failures[0]) > parseFailureWindow {
+		r.failures = r.failures[1:]
+	}
+	if len(r.failures) <= r.threshold {
+		return err
+	}
+
+	if r.file == nil {
+		f, openErr := os.OpenFile(r.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if openErr != nil {
+			return fmt.Errorf("%w (failed to open capture file: %v)", err, openErr)
+		}
+		r.file = f
+		// the cap also covers whatever earlier runs left in the file
+		if info, statErr := f.Stat(); statErr == nil {
+			r.written = info.Size()
+		}
+	}
+
+	if r.written >= r.maxBytes {
+		return fmt.Errorf("%w (capture file %s is full)", err, r.path)
+	}
+
+	entry := fmt.Sprintf("%s %q %s\n", now.UTC().Format(time.RFC3339Nano), strings.TrimRight(line, "\r\n"), err)
+	if int64(len(entry)) > r.maxBytes-r.written {
+		entry = entry[:r.maxBytes-r.written]
+	}
+	n, writeErr := r.file.WriteString(entry)
+	r.written += int64(n)
+	if writeErr != nil {
+		return fmt.Errorf("%w (failed to write capture file: %v)", err, writeErr)
+	}
+	return fmt.Errorf("%w (raw sentences captured to %s)", err, r.path)
+}
+
+// Close closes the capture file.
+func (r *ParseFailureRecorder) Close() error {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.file == nil {
+		return nil
+	}
+	err := r.file.Close()
+	r.file = nil
+	return err
+}
@@ -0,0 +1,43 @@
+package rtkutils
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.viam.com/test"
+)
+
+func TestParseFailureRecorder(t *testing.T) {
+	errParse := errors.New("can't parse")
+
+	var disabled *ParseFailureRecorder
+	test.That(t, disabled.Record("$GPXXX", errParse), test.ShouldEqual, errParse)
+	test.That(t, NewParseFailureRecorder("", 0, 0), test.ShouldBeNil)
+
+	path := filepath.Join(t.TempDir(), "failures.log")
+	recorder := NewParseFailureRecorder(path, 2, 100)
+
+	// failures under the rate are only passed through
+	test.That(t, recorder.Record("$GPXXX,1", errParse), test.ShouldEqual, errParse)
+	test.That(t, recorder.Record("$GPXXX,2", errParse), test.ShouldEqual, errParse)
+	_, err := os.Stat(path)
+	test.That(t, os.IsNotExist(err), test.ShouldBeTrue)
+
+	err = recorder.Record("$GPXXX,3\r\n", errParse)
+	test.That(t, errors.Is(err, errParse), test.ShouldBeTrue)
+	test.That(t, err.Error(), test.ShouldContainSubstring, path)
+
+	for i := 0; i < 5; i++ {
+		err = recorder.Record("$GPXXX,4", errParse)
+	}
+	test.That(t, err.Error(), test.ShouldContainSubstring, "full")
+	test.That(t, recorder.Close(), test.ShouldBeNil)
+
+	captured, err := os.ReadFile(path)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, len(captured), test.ShouldEqual, 100)
+	test.That(t, strings.Contains(string(captured), `"$GPXXX,3"`), test.ShouldBeTrue)
+}
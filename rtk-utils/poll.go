@@ -0,0 +1,32 @@
+package rtkutils
+
+import (
+	"bytes"
+	"errors"
+	"time"
+)
+
+// DefaultPollInterval is how long i2c read loops wait after a read that returned no data.
+const DefaultPollInterval = 50 * time.Millisecond
+
+// PollInterval returns the poll_interval_ms of a config, or def if it isn't set.
+func PollInterval(ms int, def time.Duration) time.Duration {
+	if ms <= 0 {
+		return def
+	}
+	return time.Duration(ms) * time.Millisecond
+}
+
+// ValidatePollInterval checks the poll_interval_ms of a config.
+func ValidatePollInterval(ms int) error {
+	if ms < 0 {
+		return errors.New("poll_interval_ms can't be negative")
+	}
+	return nil
+}
+
+// IsIdle reports whether an i2c read returned only the 0xFF padding a u-blox receiver sends when
+// it has no data.
+func IsIdle(buf []byte) bool {
+	return len(bytes.Trim(buf, "\xff")) == 0
+}
@@ -0,0 +1,19 @@
+package rtkutils
+
+import (
+	"testing"
+	"time"
+
+	"go.viam.com/test"
+)
+
+func TestPollInterval(t *testing.T) {
+	test.That(t, PollInterval(0, DefaultPollInterval), test.ShouldEqual, DefaultPollInterval)
+	test.That(t, PollInterval(20, DefaultPollInterval), test.ShouldEqual, 20*time.Millisecond)
+	test.That(t, ValidatePollInterval(0), test.ShouldBeNil)
+	test.That(t, ValidatePollInterval(-1), test.ShouldNotBeNil)
+
+	test.That(t, IsIdle(nil), test.ShouldBeTrue)
+	test.That(t, IsIdle([]byte{0xff, 0xff}), test.ShouldBeTrue)
+	test.That(t, IsIdle([]byte{0xff, '$', 0xff}), test.ShouldBeFalse)
+}
@@ -0,0 +1,63 @@
+package rtkutils
+
+import (
+	"time"
+
+	"github.com/go-gnss/rtcm/rtcm3"
+)
+
+// PortDemux splits what is read from a receiver port that carries RTCM3 as well as NMEA, such as
+// the uart a rover reads its sentences from and writes its corrections to, when the receiver also
+// sends rtcm there. The rtcm frames are taken out of the stream, so their bytes are never split
+// into sentences, and the rest is split into sentences by an NMEASplitter.
+type PortDemux struct {
+	sentences NMEASplitter
+	pending   []byte // the start of a frame cut off by the end of the previous read
+}
+
+// Split splits data read at now, calling sentence with every sentence and frame with every frame
+// it completes. A frame is only valid until frame returns. NMEA is ascii, so the frame preamble
+// can only be part of binary data: the bytes after the start of a frame cut off by the end of a
+// read are held until the rest of it is read, or it turns out not to be a frame.
+func (d *PortDemux) Split(data []byte, now time.Time, sentence func(string), frame func([]byte)) {
+	buf := data
+	if len(d.pending) > 0 {
+		buf = append(d.pending, data...)
+		d.pending = d.pending[:0]
+	}
+	start := 0 // the first byte that isn't part of a frame and wasn't split yet
+	for i := 0; i < len(buf); i++ {
+		if buf[i] != rtcm3.FramePreamble {
+			continue
+		}
+		if i+1 < len(buf) && buf[i+1]&0xFC != 0 {
+			// the reserved bits of a frame header are zero
+			continue
+		}
+		end := len(buf) + 1
+		if i+3 <= len(buf) {
+			length := (int(buf[i+1])&0x03)<<8 | int(buf[i+2])
+			end = i + 3 + length + 3
+		}
+		if end > len(buf) {
+			d.sentences.Split(buf[start:i], now, sentence)
+			d.pending = append(d.pending, buf[i:]...)
+			return
+		}
+		crc := uint32(buf[end-3])<<16 | uint32(buf[end-2])<<8 | uint32(buf[end-1])
+		if rtcm3.Crc24q(buf[i:end-3]) != crc {
+			continue
+		}
+		d.sentences.Split(buf[start:i], now, sentence)
+		frame(buf[i:end])
+		start = end
+		i = end - 1
+	}
+	d.sentences.Split(buf[start:], now, sentence)
+}
+
+// Reset drops the sentence and frame being read, e.g. after a failed read left a gap in the data.
+func (d *PortDemux) Reset() {
+	d.sentences.Reset()
+	d.pending = d.pending[:0]
+}
@@ -0,0 +1,48 @@
+package rtkutils
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-gnss/rtcm/rtcm3"
+	"go.viam.com/test"
+)
+
+func TestPortDemux(t *testing.T) {
+	// a frame holding the bytes that start and end a sentence
+	frame := rtcm3.EncapsulateByteArray([]byte{0x3e, 0xd0, '$', 'G', 'P', '\r', '\n'}).Serialize()
+	stream := []byte(testGGAEpoch1 + "\r\n")
+	stream = append(stream, frame...)
+	stream = append(stream, testGSAEpoch1+"\r\n"...)
+	// ubx raw measurements, with a byte that looks like a preamble
+	stream = append(stream, 0xb5, 0x62, rtcm3.FramePreamble, 0x42, 0x01)
+	stream = append(stream, frame...)
+	stream = append(stream, testGGAEpoch2+"\r\n"...)
+
+	// however the reads cut the stream, the frames and sentences are the same
+	for _, size := range []int{1, 2, 7, 64, len(stream)} {
+		var d PortDemux
+		var sentences []string
+		var frames [][]byte
+		for i := 0; i < len(stream); i += size {
+			end := i + size
+			if end > len(stream) {
+				end = len(stream)
+			}
+			d.Split(stream[i:end], time.Now(),
+				func(sentence string) { sentences = append(sentences, sentence) },
+				func(f []byte) { frames = append(frames, append([]byte{}, f...)) })
+		}
+		test.That(t, sentences, test.ShouldResemble, []string{testGGAEpoch1, testGSAEpoch1, testGGAEpoch2})
+		test.That(t, frames, test.ShouldResemble, [][]byte{frame, frame})
+	}
+
+	// the start of a frame that lost its end isn't held on to after a reset
+	var d PortDemux
+	var sentences []string
+	collect := func(sentence string) { sentences = append(sentences, sentence) }
+	d.Split(frame[:5], time.Now(), collect, func([]byte) { t.Fatal("the frame was cut off") })
+	d.Reset()
+	d.Split([]byte(testGGAEpoch1+"\r\n"), time.Now(), collect, func([]byte) { t.Fatal("there is no frame") })
+	test.That(t, sentences, test.ShouldResemble, []string{testGGAEpoch1})
+}
@@ -0,0 +1,179 @@
+package rtkutils
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sync"
+	"time"
+
+	geo "github.com/kellydunn/golang-geo"
+)
+
+// CurrentPositionCommand returns the position a rover reports now, with how old its epoch is and
+// whether it was extrapolated.
+const CurrentPositionCommand = "current_position"
+
+// DefaultMaxExtrapolation is how far past its epoch a position is extrapolated at most.
+const DefaultMaxExtrapolation = time.Second
+
+// two epochs further apart than this give no velocity to extrapolate with
+const maxExtrapolationBase = 2 * time.Second
+
+// ErrNoHeldPosition is returned before a PositionHold has a position.
+var ErrNoHeldPosition = errors.New("no position yet")
+
+// ValidatePositionHold checks the republishing settings of a rover.
+func ValidatePositionHold(rateHz float64, extrapolate bool, maxExtrapolation float64) error {
+	if rateHz < 0 {
+		return errors.New("republish_rate_hz must not be negative")
+	}
+	if maxExtrapolation < 0 {
+		return errors.New("max_extrapolation_sec must not be negative")
+	}
+	if maxExtrapolation > 0 && !extrapolate {
+		return errors.New("max_extrapolation_sec needs extrapolate_position")
+	}
+	return nil
+}
+
+// HeldPosition is the position a rover reports between the epochs of its receiver.
+type HeldPosition struct {
+	Point        *geo.Point
+	Alt          float64
+	Epoch        uint64
+	Age          time.Duration // since the epoch was read
+	Extrapolated bool
+}
+
+type heldFix struct {
+	point  *geo.Point
+	alt    float64
+	epoch  uint64
+	moving bool
+	at     time.Time // when the epoch was read
+}
+
+// PositionHold republishes the position of a rover at a fixed rate, independently of the rate of
+// its receiver, so that consumers polling faster than the receiver get the best current estimate:
+// the position of the latest epoch, held until the next one, or extrapolated from the velocity
+// between the last two epochs while the rover moves.
+type PositionHold struct {
+	interval         time.Duration // between samples, 0 to sample on every read
+	extrapolate      bool
+	maxExtrapolation time.Duration
+
+	mu      sync.Mutex
+	prev    heldFix
+	last    heldFix
+	sampled HeldPosition // the latest sample, when sampling at a rate
+	at      time.Time    // when it was sampled
+}
+
+// NewPositionHold returns a hold sampling rateHz times a second, or on every read if it is 0, that
+// extrapolates positions by up to maxExtrapolation, DefaultMaxExtrapolation if it is 0, if extrapolate is set.
+func NewPositionHold(rateHz float64, extrapolate bool, maxExtrapolation time.Duration) *PositionHold {
+	var interval time.Duration
+	if rateHz > 0 {
+		interval = time.Duration(float64(time.Second) / rateHz)
+	}
+	if maxExtrapolation <= 0 {
+		maxExtrapolation = DefaultMaxExtrapolation
+	}
+	return &PositionHold{interval: interval, extrapolate: extrapolate, maxExtrapolation: maxExtrapolation}
+}
+
+// Republishing reports whether positions are sampled at a fixed rate.
+func (h *PositionHold) Republishing() bool {
+	return h.interval > 0
+}
+
+// Update holds the position of an epoch read at now, unless it has none.
+func (h *PositionHold) Update(snap Snapshot, now time.Time) {
+	pos := snap.Data.Location
+	if pos == nil || math.IsNaN(pos.Lat()) || math.IsNaN(pos.Lng()) || (pos.Lat() == 0 && pos.Lng() == 0) {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.prev = h.last
+	h.last = heldFix{point: pos, alt: snap.Data.Alt, epoch: snap.Epoch, moving: snap.Moving, at: now}
+}
+
+// Run samples the position at the rate of the hold until ctx is done.
+func (h *PositionHold) Run(ctx context.Context) error {
+	if h.interval == 0 {
+		return nil
+	}
+	ticker := time.NewTicker(h.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case now := <-ticker.C:
+			h.mu.Lock()
+			if pos, ok := h.estimate(now); ok {
+				h.sampled = pos
+				h.at = now
+			}
+			h.mu.Unlock()
+		}
+	}
+}
+
+// Current returns the position to report at now: the latest sample when sampling at a rate, aged
+// to now, or else an estimate made now. It returns false before there is a position.
+func (h *PositionHold) Current(now time.Time) (HeldPosition, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.interval == 0 || h.at.IsZero() {
+		return h.estimate(now)
+	}
+	pos := h.sampled
+	pos.Age += now.Sub(h.at)
+	return pos, true
+}
+
+// estimate returns the position of the latest epoch at now, extrapolated if it can be. The caller
+// must hold mu.
+func (h *PositionHold) estimate(now time.Time) (HeldPosition, bool) {
+	last := h.last
+	if last.point == nil {
+		return HeldPosition{}, false
+	}
+	pos := HeldPosition{Point: last.point, Alt: last.alt, Epoch: last.epoch, Age: now.Sub(last.at)}
+	base := last.at.Sub(h.prev.at)
+	if !h.extrapolate || !last.moving || h.prev.point == nil || base <= 0 || base > maxExtrapolationBase || pos.Age <= 0 {
+		return pos, true
+	}
+	ahead := pos.Age
+	if ahead > h.maxExtrapolation {
+		ahead = h.maxExtrapolation
+	}
+	scale := ahead.Seconds() / base.Seconds()
+	dist := h.prev.point.GreatCircleDistance(last.point) // km
+	if dist > 0 {
+		pos.Point = last.point.PointAtDistanceAndBearing(dist*scale, h.prev.point.BearingTo(last.point))
+	}
+	pos.Alt += (last.alt - h.prev.alt) * scale
+	pos.Extrapolated = true
+	return pos, true
+}
+
+// CurrentPositionResult answers a CurrentPositionCommand, reporting the position through privacy.
+func (h *PositionHold) CurrentPositionResult(privacy *PositionPrivacy) (map[string]interface{}, error) {
+	pos, ok := h.Current(time.Now())
+	if !ok {
+		return nil, ErrNoHeldPosition
+	}
+	point := privacy.Reduce(pos.Point)
+	return map[string]interface{}{
+		"lat":          point.Lat(),
+		"lng":          point.Lng(),
+		"alt":          pos.Alt,
+		"epoch":        pos.Epoch,
+		"age_sec":      pos.Age.Seconds(),
+		"extrapolated": pos.Extrapolated,
+	}, nil
+}
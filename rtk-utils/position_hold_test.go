@@ -0,0 +1,95 @@
+package rtkutils
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	geo "github.com/kellydunn/golang-geo"
+	"go.viam.com/rdk/components/movementsensor/gpsnmea"
+	"go.viam.com/test"
+)
+
+func holdSnapshot(epoch uint64, lat, lng, alt float64, moving bool) Snapshot {
+	return Snapshot{Data: gpsnmea.GPSData{Location: geo.NewPoint(lat, lng), Alt: alt}, Epoch: epoch, Moving: moving}
+}
+
+func TestValidatePositionHold(t *testing.T) {
+	test.That(t, ValidatePositionHold(0, false, 0), test.ShouldBeNil)
+	test.That(t, ValidatePositionHold(20, true, 0.5), test.ShouldBeNil)
+	test.That(t, ValidatePositionHold(-1, false, 0), test.ShouldBeError, errors.New("republish_rate_hz must not be negative"))
+	test.That(t, ValidatePositionHold(20, true, -1), test.ShouldBeError, errors.New("max_extrapolation_sec must not be negative"))
+	test.That(t, ValidatePositionHold(20, false, 1), test.ShouldBeError, errors.New("max_extrapolation_sec needs extrapolate_position"))
+}
+
+func TestPositionHold(t *testing.T) {
+	h := NewPositionHold(0, false, 0)
+	test.That(t, h.Republishing(), test.ShouldBeFalse)
+	now := time.Now()
+	_, ok := h.Current(now)
+	test.That(t, ok, test.ShouldBeFalse)
+	_, err := h.CurrentPositionResult(nil)
+	test.That(t, err, test.ShouldEqual, ErrNoHeldPosition)
+
+	// epochs without a position are skipped
+	h.Update(holdSnapshot(1, 40, -74, 10, true), now)
+	h.Update(Snapshot{Epoch: 2}, now.Add(time.Second))
+	h.Update(holdSnapshot(3, 0, 0, 0, true), now.Add(time.Second))
+	pos, ok := h.Current(now.Add(1500 * time.Millisecond))
+	test.That(t, ok, test.ShouldBeTrue)
+	test.That(t, pos.Epoch, test.ShouldEqual, uint64(1))
+	test.That(t, pos.Age, test.ShouldEqual, 1500*time.Millisecond)
+	test.That(t, pos.Extrapolated, test.ShouldBeFalse)
+	test.That(t, pos.Point.Lat(), test.ShouldEqual, 40.0)
+}
+
+func TestPositionHoldExtrapolation(t *testing.T) {
+	h := NewPositionHold(0, true, 500*time.Millisecond)
+	now := time.Now()
+	// about 11 m north a second
+	h.Update(holdSnapshot(1, 40, -74, 10, true), now)
+	h.Update(holdSnapshot(2, 40.0001, -74, 11, true), now.Add(time.Second))
+
+	pos, _ := h.Current(now.Add(1250 * time.Millisecond))
+	test.That(t, pos.Extrapolated, test.ShouldBeTrue)
+	test.That(t, pos.Epoch, test.ShouldEqual, uint64(2))
+	test.That(t, pos.Point.Lat(), test.ShouldAlmostEqual, 40.000125, 1e-7)
+	test.That(t, pos.Point.Lng(), test.ShouldAlmostEqual, -74, 1e-7)
+	test.That(t, pos.Alt, test.ShouldAlmostEqual, 11.25, 1e-9)
+
+	// no further than the maximum extrapolation
+	pos, _ = h.Current(now.Add(3 * time.Second))
+	test.That(t, pos.Point.Lat(), test.ShouldAlmostEqual, 40.00015, 1e-7)
+	test.That(t, pos.Age, test.ShouldEqual, 2*time.Second)
+
+	// nor once the rover stopped
+	h.Update(holdSnapshot(3, 40.0001, -74, 11, false), now.Add(2*time.Second))
+	pos, _ = h.Current(now.Add(2500 * time.Millisecond))
+	test.That(t, pos.Extrapolated, test.ShouldBeFalse)
+	test.That(t, pos.Point.Lat(), test.ShouldEqual, 40.0001)
+}
+
+func TestPositionHoldRepublish(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	h := NewPositionHold(100, false, 0)
+	test.That(t, h.Republishing(), test.ShouldBeTrue)
+	done := make(chan error)
+	go func() { done <- h.Run(ctx) }()
+
+	h.Update(holdSnapshot(1, 40, -74, 10, false), time.Now())
+	sampled := func() bool {
+		pos, ok := h.Current(time.Now())
+		return ok && pos.Epoch == 1
+	}
+	test.That(t, WaitFor(ctx, time.Second, "sample", sampled), test.ShouldBeNil)
+
+	res, err := h.CurrentPositionResult(NewPositionPrivacy(0, 0, ""))
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, res["lat"], test.ShouldEqual, 40.0)
+	test.That(t, res["epoch"], test.ShouldEqual, uint64(1))
+	test.That(t, res["age_sec"], test.ShouldBeGreaterThan, 0)
+
+	cancel()
+	test.That(t, <-done, test.ShouldBeNil)
+}
@@ -0,0 +1,150 @@
+package rtkutils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.viam.com/rdk/components/board"
+)
+
+// PowerCycleCommand cuts the power of a rover's receiver, or holds it in reset, then restores it.
+const PowerCycleCommand = "power_cycle"
+
+// EventReceiverPowerCycled is raised every time a receiver is power cycled.
+const EventReceiverPowerCycled = "receiver_power_cycled"
+
+const (
+	// DefaultPowerOff is how long a receiver is kept off when it is power cycled.
+	DefaultPowerOff = time.Second
+	// DefaultPowerCycleAfter is how long a receiver can send no data before the watchdog power cycles it.
+	DefaultPowerCycleAfter = 30 * time.Second
+	// how often the watchdog checks on the receiver
+	watchdogInterval = time.Second
+)
+
+// ErrNoPowerControl is returned by a PowerCycleCommand to a rover without a power pin.
+var ErrNoPowerControl = errors.New("power_cycle needs power_board and power_pin")
+
+// ValidatePowerControl checks the power pin settings of a rover.
+func ValidatePowerControl(boardName, pin string, offMs int, after float64) error {
+	if (boardName == "") != (pin == "") {
+		return errors.New("power_board and power_pin must be set together")
+	}
+	if offMs < 0 {
+		return errors.New("power_off_ms must not be negative")
+	}
+	if after < 0 {
+		return errors.New("power_cycle_after_sec must not be negative")
+	}
+	if boardName == "" && (offMs > 0 || after > 0) {
+		return errors.New("power_off_ms and power_cycle_after_sec need power_board and power_pin")
+	}
+	return nil
+}
+
+// PowerControl drives a board GPIO pin that powers a receiver, or holds it in reset, to hard reset
+// receivers that wedge. The pin is low while the receiver is off unless offHigh is set, matching the
+// enable pin of a regulator or the active low reset line of most receivers. A nil control has no pin.
+type PowerControl struct {
+	board   board.Board
+	pin     string
+	offHigh bool
+	off     time.Duration
+	onCycle func()
+
+	mu     sync.Mutex // held while the receiver is off, so that cycles don't overlap
+	cycles Counter
+}
+
+// NewPowerControl returns a control of pin on b, or nil if b is nil. The receiver is kept off for
+// off, DefaultPowerOff if it is zero. onCycle, if not nil, is called once the receiver is back on,
+// e.g. to reopen its port and set it up again.
+func NewPowerControl(b board.Board, pin string, offHigh bool, off time.Duration, onCycle func()) *PowerControl {
+	if b == nil {
+		return nil
+	}
+	if off <= 0 {
+		off = DefaultPowerOff
+	}
+	return &PowerControl{board: b, pin: pin, offHigh: offHigh, off: off, onCycle: onCycle}
+}
+
+// Cycle turns the receiver off, waits, and turns it back on. It is turned back on even if ctx is
+// done while it is off, a receiver left off would need a restart of the robot.
+func (p *PowerControl) Cycle(ctx context.Context) error {
+	if p == nil {
+		return ErrNoPowerControl
+	}
+	pin, err := p.board.GPIOPinByName(p.pin)
+	if err != nil {
+		return fmt.Errorf("power pin %q: %w", p.pin, err)
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := pin.Set(ctx, p.offHigh, nil); err != nil {
+		return fmt.Errorf("failed to turn the receiver off: %w", err)
+	}
+	select {
+	case <-ctx.Done():
+	case <-time.After(p.off):
+	}
+	if err := pin.Set(context.Background(), !p.offHigh, nil); err != nil {
+		return fmt.Errorf("failed to turn the receiver back on: %w", err)
+	}
+	p.cycles.Add(1)
+	if p.onCycle != nil {
+		p.onCycle()
+	}
+	return nil
+}
+
+// Cycles returns the number of times the receiver was power cycled.
+func (p *PowerControl) Cycles() uint64 {
+	if p == nil {
+		return 0
+	}
+	return p.cycles.Get()
+}
+
+// Watch power cycles the receiver whenever activity, e.g. the epochs a rover published, didn't
+// change for after, then gives it after again to come back, until ctx is done. It raises an event
+// with every cycle, and returns at once for a nil control.
+func (p *PowerControl) Watch(ctx context.Context, activity *Counter, after time.Duration, events *EventLog) error {
+	if p == nil {
+		return nil
+	}
+	if after <= 0 {
+		after = DefaultPowerCycleAfter
+	}
+	interval := watchdogInterval
+	if after < 2*interval {
+		interval = after / 2
+	}
+	since := time.Now()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case now := <-ticker.C:
+			if last := activity.Last(); last.After(since) {
+				since = last
+			}
+			if now.Sub(since) < after {
+				continue
+			}
+			if err := p.Cycle(ctx); err != nil {
+				if ctx.Err() != nil {
+					return nil
+				}
+				return err
+			}
+			events.Add(EventReceiverPowerCycled, "no data from the receiver for %s, power cycled it", now.Sub(since).Round(time.Second))
+			since = time.Now()
+		}
+	}
+}
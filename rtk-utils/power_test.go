@@ -0,0 +1,125 @@
+package rtkutils
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/edaniels/golog"
+	"go.viam.com/rdk/components/board"
+	"go.viam.com/test"
+)
+
+func TestValidatePowerControl(t *testing.T) {
+	test.That(t, ValidatePowerControl("", "", 0, 0), test.ShouldBeNil)
+	test.That(t, ValidatePowerControl("pi", "11", 500, 60), test.ShouldBeNil)
+	test.That(t, ValidatePowerControl("pi", "", 0, 0), test.ShouldBeError,
+		errors.New("power_board and power_pin must be set together"))
+	test.That(t, ValidatePowerControl("pi", "11", -1, 0), test.ShouldBeError,
+		errors.New("power_off_ms must not be negative"))
+	test.That(t, ValidatePowerControl("pi", "11", 0, -1), test.ShouldBeError,
+		errors.New("power_cycle_after_sec must not be negative"))
+	test.That(t, ValidatePowerControl("", "", 0, 60), test.ShouldBeError,
+		errors.New("power_off_ms and power_cycle_after_sec need power_board and power_pin"))
+}
+
+// fakeBoard is a board with only its pins and digital interrupts.
+type fakeBoard struct {
+	board.Board
+	pins       map[string]board.GPIOPin
+	interrupts map[string]board.DigitalInterrupt
+}
+
+func (b *fakeBoard) GPIOPinByName(name string) (board.GPIOPin, error) {
+	pin, ok := b.pins[name]
+	if !ok {
+		return nil, errors.New("no pin")
+	}
+	return pin, nil
+}
+
+func (b *fakeBoard) DigitalInterruptByName(name string) (board.DigitalInterrupt, bool) {
+	di, ok := b.interrupts[name]
+	return di, ok
+}
+
+// fakePin is a gpio pin passing the levels it is set to to set.
+type fakePin struct {
+	board.GPIOPin
+	set func(high bool) error
+}
+
+func (p *fakePin) Set(ctx context.Context, high bool, extra map[string]interface{}) error {
+	return p.set(high)
+}
+
+// powerBoard returns a board whose pin "11" records the levels it is set to.
+func powerBoard() (*fakeBoard, func() []bool) {
+	var mu sync.Mutex
+	var levels []bool
+	pin := &fakePin{set: func(high bool) error {
+		mu.Lock()
+		defer mu.Unlock()
+		levels = append(levels, high)
+		return nil
+	}}
+	return &fakeBoard{pins: map[string]board.GPIOPin{"11": pin}}, func() []bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]bool{}, levels...)
+	}
+}
+
+func TestPowerControl(t *testing.T) {
+	ctx := context.Background()
+	var none *PowerControl
+	test.That(t, none.Cycle(ctx), test.ShouldEqual, ErrNoPowerControl)
+	test.That(t, none.Cycles(), test.ShouldEqual, uint64(0))
+	test.That(t, none.Watch(ctx, &Counter{}, time.Second, nil), test.ShouldBeNil)
+	test.That(t, NewPowerControl(nil, "11", false, 0, nil), test.ShouldBeNil)
+
+	b, levels := powerBoard()
+	onCycle := 0
+	p := NewPowerControl(b, "11", false, time.Millisecond, func() { onCycle++ })
+	test.That(t, p.Cycle(ctx), test.ShouldBeNil)
+	test.That(t, levels(), test.ShouldResemble, []bool{false, true})
+	test.That(t, p.Cycles(), test.ShouldEqual, uint64(1))
+	test.That(t, onCycle, test.ShouldEqual, 1)
+
+	// a reset line that is active high
+	b, levels = powerBoard()
+	p = NewPowerControl(b, "11", true, time.Millisecond, nil)
+	test.That(t, p.Cycle(ctx), test.ShouldBeNil)
+	test.That(t, levels(), test.ShouldResemble, []bool{true, false})
+
+	p = NewPowerControl(b, "12", false, time.Millisecond, nil)
+	test.That(t, p.Cycle(ctx), test.ShouldBeError, errors.New("power pin \"12\": no pin"))
+	test.That(t, p.Cycles(), test.ShouldEqual, uint64(0))
+}
+
+func TestPowerWatchdog(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	b, _ := powerBoard()
+	p := NewPowerControl(b, "11", false, time.Millisecond, nil)
+	events := NewEventLog(golog.NewTestLogger(t))
+	var epochs Counter
+
+	done := make(chan error)
+	go func() { done <- p.Watch(ctx, &epochs, 100*time.Millisecond, events) }()
+
+	// a receiver sending data is left alone
+	for i := 0; i < 6; i++ {
+		epochs.Add(1)
+		time.Sleep(25 * time.Millisecond)
+	}
+	test.That(t, p.Cycles(), test.ShouldEqual, uint64(0))
+
+	// then power cycled once it stops
+	cycled := func() bool { return len(events.Events(EventReceiverPowerCycled)) > 0 }
+	test.That(t, WaitFor(ctx, time.Second, "power cycle", cycled), test.ShouldBeNil)
+
+	cancel()
+	test.That(t, <-done, test.ShouldBeNil)
+}
@@ -0,0 +1,194 @@
+package rtkutils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"go.uber.org/multierr"
+)
+
+// PPKSolveCommand runs a post processed kinematic solve over everything recorded so far.
+const PPKSolveCommand = "ppk_solve"
+
+// PPKRecorder records the raw rover stream and the base corrections of a session to files in a
+// directory, so that a track can be post processed with RTKLIB later. A nil recorder records nothing.
+type PPKRecorder struct {
+	RoverPath string // raw ubx and nmea bytes read from the rover receiver
+	BasePath  string // rtcm3 frames received from the base
+	rtklibDir string // of convbin and rnx2rtkp, the PATH if empty
+
+	mu    sync.Mutex
+	rover *os.File
+	base  *os.File
+	err   error // first failure to write a session file
+}
+
+// ValidatePPK checks the recording directory of a config and the RTKLIB directory it is solved with.
+// The RTKLIB tools are run from rtklib_path, so it is only taken from the config.
+func ValidatePPK(recordDir, rtklibDir string) error {
+	if rtklibDir == "" {
+		return nil
+	}
+	if recordDir == "" {
+		return errors.New("rtklib_path needs ppk_record_dir")
+	}
+	if !filepath.IsAbs(rtklibDir) {
+		return fmt.Errorf("rtklib_path %q must be an absolute path", rtklibDir)
+	}
+	return nil
+}
+
+// NewPPKRecorder creates the files of a new session in dir, solved with the RTKLIB tools in
+// rtklibDir, or returns nil if dir is empty.
+func NewPPKRecorder(dir, rtklibDir string) (*PPKRecorder, error) {
+	if dir == "" {
+		return nil, nil
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	session := time.Now().UTC().Format("20060102T150405Z")
+	r := &PPKRecorder{
+		RoverPath: filepath.Join(dir, "rover-"+session+".ubx"),
+		BasePath:  filepath.Join(dir, "base-"+session+".rtcm3"),
+		rtklibDir: rtklibDir,
+	}
+
+	var err error
+	if r.rover, err = os.Create(r.RoverPath); err != nil {
+		return nil, err
+	}
+	if r.base, err = os.Create(r.BasePath); err != nil {
+		return nil, multierr.Combine(err, r.rover.Close())
+	}
+	return r, nil
+}
+
+// Rover returns a writer recording bytes read from the rover receiver.
+func (r *PPKRecorder) Rover() io.Writer {
+	return ppkStream{r, func() *os.File { return r.rover }}
+}
+
+// Base returns a writer recording correction bytes received from the base.
+func (r *PPKRecorder) Base() io.Writer {
+	return ppkStream{r, func() *os.File { return r.base }}
+}
+
+// ppkStream writes to one of the session files. Failures never interrupt the live stream the bytes
+// are copied from; the first one is returned by Solve instead.
+type ppkStream struct {
+	r    *PPKRecorder
+	file func() *os.File
+}
+
+func (s ppkStream) Write(p []byte) (int, error) {
+	if s.r == nil {
+		return len(p), nil
+	}
+	s.r.mu.Lock()
+	defer s.r.mu.Unlock()
+	if f := s.file(); f != nil {
+		if _, err := f.Write(p); err != nil && s.r.err == nil {
+			s.r.err = err
+		}
+	}
+	return len(p), nil
+}
+
+// Solve runs RTKLIB over the session recorded so far and returns the path of the corrected track.
+// convbin and rnx2rtkp are looked up in the RTKLIB directory of the recorder, or on the PATH.
+func (r *PPKRecorder) Solve(ctx context.Context) (string, error) {
+	if r == nil {
+		return "", errors.New("ppk recording is not enabled, set ppk_record_dir")
+	}
+	r.mu.Lock()
+	err := r.err
+	if err == nil {
+		err = multierr.Combine(r.rover.Sync(), r.base.Sync())
+	}
+	r.mu.Unlock()
+	if err != nil {
+		return "", err
+	}
+
+	convbin, err := rtklibTool(r.rtklibDir, "convbin")
+	if err != nil {
+		return "", err
+	}
+	rnx2rtkp, err := rtklibTool(r.rtklibDir, "rnx2rtkp")
+	if err != nil {
+		return "", err
+	}
+
+	roverObs, roverNav := withExt(r.RoverPath, ".obs"), withExt(r.RoverPath, ".nav")
+	baseObs := withExt(r.BasePath, ".obs")
+	track := withExt(r.RoverPath, ".pos")
+
+	steps := [][]string{
+		{convbin, "-r", "ubx", "-o", roverObs, "-n", roverNav, r.RoverPath},
+		{convbin, "-r", "rtcm3", "-o", baseObs, r.BasePath},
+		// kinematic solve with the base position taken from its rinex header
+		{rnx2rtkp, "-p", "2", "-o", track, roverObs, baseObs, roverNav},
+	}
+	for _, step := range steps {
+		out, err := exec.CommandContext(ctx, step[0], step[1:]...).CombinedOutput()
+		if err != nil {
+			return "", fmt.Errorf("%s failed: %w: %s", filepath.Base(step[0]), err, out)
+		}
+	}
+	return track, nil
+}
+
+// Close closes the session files.
+func (r *PPKRecorder) Close() error {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.rover == nil {
+		return nil
+	}
+	err := multierr.Combine(r.rover.Close(), r.base.Close())
+	r.rover, r.base = nil, nil
+	return err
+}
+
+// PPKSolveResult runs a PPKSolveCommand and returns its DoCommand response.
+func PPKSolveResult(ctx context.Context, r *PPKRecorder) (map[string]interface{}, error) {
+	track, err := r.Solve(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"track": track, "rover_file": r.RoverPath, "base_file": r.BasePath}, nil
+}
+
+func rtklibTool(dir, name string) (string, error) {
+	if dir != "" {
+		name = filepath.Join(dir, name)
+	}
+	path, err := exec.LookPath(name)
+	if err != nil {
+		return "", fmt.Errorf("RTKLIB %s is required for ppk, install it or set rtklib_path: %w", filepath.Base(name), err)
+	}
+	return path, nil
+}
+
+func withExt(path, ext string) string {
+	return path[:len(path)-len(filepath.Ext(path))] + ext
+}
+
+// EnableRawOutput asks a u-blox receiver to output the raw measurements RTKLIB needs on the port write sends to.
+func EnableRawOutput(write func([]byte) error) error {
+	return multierr.Combine(
+		write(UBXMessageRatePacket(UBXClassRxm, UBXRxmRawx, 1)),
+		write(UBXMessageRatePacket(UBXClassRxm, UBXRxmSfrbx, 1)),
+	)
+}
@@ -0,0 +1,56 @@
+package rtkutils
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"go.viam.com/test"
+)
+
+func TestValidatePPK(t *testing.T) {
+	test.That(t, ValidatePPK("", ""), test.ShouldBeNil)
+	test.That(t, ValidatePPK("/data/ppk", "/opt/rtklib/bin"), test.ShouldBeNil)
+	test.That(t, ValidatePPK("", "/opt/rtklib/bin"), test.ShouldBeError, errors.New("rtklib_path needs ppk_record_dir"))
+	test.That(t, ValidatePPK("/data/ppk", "rtklib/bin"), test.ShouldBeError,
+		errors.New(`rtklib_path "rtklib/bin" must be an absolute path`))
+}
+
+func TestPPKRecorder(t *testing.T) {
+	r, err := NewPPKRecorder("", "")
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, r, test.ShouldBeNil)
+	_, err = r.Rover().Write([]byte{1})
+	test.That(t, err, test.ShouldBeNil)
+	_, err = r.Solve(context.Background())
+	test.That(t, err, test.ShouldNotBeNil)
+
+	dir := filepath.Join(t.TempDir(), "ppk")
+	r, err = NewPPKRecorder(dir, filepath.Join(dir, "no-rtklib"))
+	test.That(t, err, test.ShouldBeNil)
+	r.Rover().Write([]byte{0xB5, 0x62})
+	r.Base().Write([]byte{0xD3})
+	r.Rover().Write([]byte{0x02})
+
+	// solving without RTKLIB installed explains what is missing
+	_, err = r.Solve(context.Background())
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "RTKLIB convbin")
+
+	test.That(t, r.Close(), test.ShouldBeNil)
+	test.That(t, r.Close(), test.ShouldBeNil)
+
+	rover, err := os.ReadFile(r.RoverPath)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, rover, test.ShouldResemble, []byte{0xB5, 0x62, 0x02})
+	base, err := os.ReadFile(r.BasePath)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, base, test.ShouldResemble, []byte{0xD3})
+}
+
+func TestUBXMessageRatePacket(t *testing.T) {
+	test.That(t, UBXMessageRatePacket(UBXClassRxm, UBXRxmRawx, 1), test.ShouldResemble,
+		[]byte{0xB5, 0x62, 0x06, 0x01, 0x03, 0x00, 0x02, 0x15, 0x01, 0x22, 0x70})
+}
@@ -0,0 +1,97 @@
+package rtkutils
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+
+	geo "github.com/kellydunn/golang-geo"
+)
+
+// PrecisePositionCommand returns the full precision position of a rover that reports reduced
+// positions, given its "key".
+const PrecisePositionCommand = "precise_position"
+
+// length of a degree of latitude, and of longitude at the equator, in m
+const metersPerDegree = geo.EARTH_RADIUS * 1000 * math.Pi / 180
+
+// ErrPrivacyKey is returned by commands that need the privacy key of a rover but weren't given it.
+var ErrPrivacyKey = errors.New("the command needs the privacy_key of the rover as \"key\"")
+
+// PositionPrivacy reduces the precision of the positions a rover reports, for deployments that
+// mustn't record where a robot is exactly. Positions are shifted by a fixed offset, in a direction
+// derived from the key so that it stays the same across restarts, then snapped to a grid, and only
+// commands given the key get them at full precision. A nil privacy leaves positions as they are.
+type PositionPrivacy struct {
+	grid    float64 // m
+	offset  float64 // m
+	bearing float64 // degrees from north
+	key     []byte
+}
+
+// ValidatePositionPrivacy checks the grid and offset in m, which need a key to get precise positions.
+func ValidatePositionPrivacy(grid, offset float64, key string) error {
+	if grid < 0 {
+		return errors.New("privacy_grid_m must not be negative")
+	}
+	if offset < 0 {
+		return errors.New("privacy_offset_m must not be negative")
+	}
+	if (grid > 0 || offset > 0) && key == "" {
+		return errors.New("privacy_key is required with privacy_grid_m or privacy_offset_m")
+	}
+	if key != "" && len(key) < minAuthKeyLen {
+		return fmt.Errorf("privacy_key must be at least %d characters", minAuthKeyLen)
+	}
+	return nil
+}
+
+// NewPositionPrivacy returns the privacy of a grid and offset in m, or nil if both are 0.
+func NewPositionPrivacy(grid, offset float64, key string) *PositionPrivacy {
+	if grid == 0 && offset == 0 {
+		return nil
+	}
+	sum := sha256.Sum256([]byte(key))
+	bearing := float64(binary.BigEndian.Uint64(sum[:])) / math.Exp2(64) * 360
+	return &PositionPrivacy{grid: grid, offset: offset, bearing: bearing, key: []byte(key)}
+}
+
+// Reduce returns the position to report for pos.
+func (p *PositionPrivacy) Reduce(pos *geo.Point) *geo.Point {
+	if p == nil || pos == nil || math.IsNaN(pos.Lat()) || math.IsNaN(pos.Lng()) {
+		return pos
+	}
+	if p.offset > 0 {
+		pos = pos.PointAtDistanceAndBearing(p.offset/1000, p.bearing)
+	}
+	if p.grid == 0 {
+		return pos
+	}
+	// latitude is snapped first, so every cell of a band of latitude has the same width
+	lat := snap(pos.Lat(), p.grid/metersPerDegree)
+	lng := pos.Lng()
+	if cos := math.Cos(lat * math.Pi / 180); cos > 1e-9 {
+		lng = snap(lng, p.grid/(metersPerDegree*cos))
+	}
+	return geo.NewPoint(lat, lng)
+}
+
+// snap rounds v to the nearest multiple of step.
+func snap(v, step float64) float64 {
+	return math.Round(v/step) * step
+}
+
+// Authorized returns ErrPrivacyKey unless cmd has the key of the privacy.
+func (p *PositionPrivacy) Authorized(cmd map[string]interface{}) error {
+	if p == nil {
+		return nil
+	}
+	key, _ := cmd["key"].(string)
+	if subtle.ConstantTimeCompare([]byte(key), p.key) != 1 {
+		return ErrPrivacyKey
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+package rtkutils
+
+import (
+	"errors"
+	"math"
+	"testing"
+
+	geo "github.com/kellydunn/golang-geo"
+	"go.viam.com/test"
+)
+
+const testPrivacyKey = "0123456789abcdef"
+
+func TestValidatePositionPrivacy(t *testing.T) {
+	test.That(t, ValidatePositionPrivacy(0, 0, ""), test.ShouldBeNil)
+	test.That(t, ValidatePositionPrivacy(100, 500, testPrivacyKey), test.ShouldBeNil)
+	test.That(t, ValidatePositionPrivacy(-1, 0, testPrivacyKey), test.ShouldBeError,
+		errors.New("privacy_grid_m must not be negative"))
+	test.That(t, ValidatePositionPrivacy(100, 0, ""), test.ShouldBeError,
+		errors.New("privacy_key is required with privacy_grid_m or privacy_offset_m"))
+	test.That(t, ValidatePositionPrivacy(100, 0, "short"), test.ShouldBeError,
+		errors.New("privacy_key must be at least 16 characters"))
+}
+
+func TestPositionPrivacy(t *testing.T) {
+	var none *PositionPrivacy
+	pos := geo.NewPoint(40.7484405, -73.9856644)
+	test.That(t, none.Reduce(pos), test.ShouldEqual, pos)
+	test.That(t, none.Authorized(nil), test.ShouldBeNil)
+	test.That(t, NewPositionPrivacy(0, 0, testPrivacyKey), test.ShouldBeNil)
+
+	// positions within the same 100m cell are reported the same
+	grid := NewPositionPrivacy(100, 0, testPrivacyKey)
+	reduced := grid.Reduce(pos)
+	test.That(t, pos.GreatCircleDistance(reduced)*1000, test.ShouldBeLessThan, 71)
+	test.That(t, grid.Reduce(geo.NewPoint(reduced.Lat()+0.0001, reduced.Lng()-0.0001)), test.ShouldResemble, reduced)
+	test.That(t, grid.Reduce(geo.NewPoint(reduced.Lat()+0.001, reduced.Lng())).Lat(), test.ShouldNotEqual, reduced.Lat())
+	nan := geo.NewPoint(math.NaN(), math.NaN())
+	test.That(t, grid.Reduce(nan), test.ShouldEqual, nan)
+
+	// the offset is the same for the same key, and keeps the distance between positions
+	offset := NewPositionPrivacy(0, 500, testPrivacyKey)
+	shifted := offset.Reduce(pos)
+	test.That(t, pos.GreatCircleDistance(shifted)*1000, test.ShouldAlmostEqual, 500, 1e-6)
+	test.That(t, NewPositionPrivacy(0, 500, testPrivacyKey).Reduce(pos), test.ShouldResemble, shifted)
+	test.That(t, NewPositionPrivacy(0, 500, "fedcba9876543210").Reduce(pos).Lat(), test.ShouldNotEqual, shifted.Lat())
+	other := geo.NewPoint(40.7494405, -73.9856644)
+	test.That(t, shifted.GreatCircleDistance(offset.Reduce(other)), test.ShouldAlmostEqual, pos.GreatCircleDistance(other), 1e-6)
+
+	test.That(t, grid.Authorized(map[string]interface{}{"key": testPrivacyKey}), test.ShouldBeNil)
+	test.That(t, grid.Authorized(map[string]interface{}{"key": "0123456789abcdeF"}), test.ShouldEqual, ErrPrivacyKey)
+	test.That(t, grid.Authorized(map[string]interface{}{}), test.ShouldEqual, ErrPrivacyKey)
+}
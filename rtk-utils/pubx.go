@@ -0,0 +1,44 @@
+package rtkutils
+
+import (
+	"strconv"
+	"strings"
+)
+
+// PUBXPosition is the accuracy of the position a u-blox receiver reports in PUBX,00 sentences.
+type PUBXPosition struct {
+	NavStatus          string  // NF, DR, G2, G3, D2, D3, RK or TT
+	HorizontalAccuracy float64 // m
+	VerticalAccuracy   float64 // m
+	Valid              bool
+}
+
+// update keeps the position accuracy reported by line. It reports whether line is a u-blox PUBX
+// sentence, the position, satellite status and time sentences of some default configurations, that
+// the nmea parser doesn't know.
+func (p *PUBXPosition) update(line string) (pubx bool) {
+	ind := strings.Index(line, "$PUBX,")
+	if ind == -1 {
+		return false
+	}
+	line = line[ind:]
+	if star := strings.IndexByte(line, '*'); star != -1 {
+		line = line[:star]
+	}
+	// $PUBX,00,<time>,<lat>,<N/S>,<lng>,<E/W>,<alt>,<nav status>,<hacc>,<vacc>,...
+	fields := strings.Split(strings.TrimSpace(line), ",")
+	if fields[1] != "00" || len(fields) < 11 {
+		// PUBX,03 satellite status and PUBX,04 time are skipped, GSV and RMC report the same
+		return true
+	}
+	hacc, err := strconv.ParseFloat(fields[9], 64)
+	if err != nil {
+		return true
+	}
+	vacc, err := strconv.ParseFloat(fields[10], 64)
+	if err != nil {
+		return true
+	}
+	*p = PUBXPosition{NavStatus: fields[8], HorizontalAccuracy: hacc, VerticalAccuracy: vacc, Valid: true}
+	return true
+}
@@ -0,0 +1,42 @@
+package rtkutils
+
+import (
+	"testing"
+
+	"go.viam.com/test"
+)
+
+const (
+	testPUBX00 = "$PUBX,00,081350.00,4717.113210,N,00833.915187,E,546.589,G3,2.1,2.0,0.007,77.52,0.007,,0.92,1.19,0.77,9,0,0*5F"
+	testPUBX03 = "$PUBX,03,11,23,-,,,45,010,29,-,,,46,013,07,-,,,42,015,08,U,067,31,42,025*3E"
+	testPUBX04 = "$PUBX,04,073731.00,091202,113851.00,1196,15D,1930035,-2660.664,43,*3C"
+)
+
+func TestPUBXPosition(t *testing.T) {
+	var p PUBXPosition
+	test.That(t, p.update(testGGAEpoch1), test.ShouldBeFalse)
+	test.That(t, p.update(testEPE), test.ShouldBeFalse)
+	test.That(t, p.Valid, test.ShouldBeFalse)
+
+	test.That(t, p.update(testPUBX00), test.ShouldBeTrue)
+	test.That(t, p, test.ShouldResemble, PUBXPosition{NavStatus: "G3", HorizontalAccuracy: 2.1, VerticalAccuracy: 2.0, Valid: true})
+
+	// the satellite status and time are known, but hold no position
+	p = PUBXPosition{}
+	test.That(t, p.update(testPUBX03), test.ShouldBeTrue)
+	test.That(t, p.update(testPUBX04), test.ShouldBeTrue)
+	test.That(t, p.update("$PUBX,00,081350.00,,,,,,NF,,,*00"), test.ShouldBeTrue)
+	test.That(t, p.Valid, test.ShouldBeFalse)
+}
+
+func TestEpochTrackerPUBX(t *testing.T) {
+	var tracker EpochTracker
+	for _, line := range []string{testGGAEpoch1, testPUBX00, testPUBX03, testPUBX04} {
+		_, _, err := tracker.ParseAndUpdate(line)
+		test.That(t, err, test.ShouldBeNil)
+	}
+	snap, published, err := tracker.ParseAndUpdate(testGGAEpoch2)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, published, test.ShouldBeTrue)
+	test.That(t, snap.PUBX.HorizontalAccuracy, test.ShouldEqual, 2.1)
+}
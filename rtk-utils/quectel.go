@@ -0,0 +1,55 @@
+package rtkutils
+
+import (
+	"strconv"
+	"strings"
+)
+
+// PositionError is the estimated position error a Quectel receiver reports in PQTMEPE sentences,
+// in meters.
+type PositionError struct {
+	North      float64
+	East       float64
+	Down       float64
+	Horizontal float64
+	Spherical  float64
+	Valid      bool
+}
+
+// update keeps the position error reported by line. It reports whether line is a Quectel
+// proprietary sentence, PQTM outputs and replies or PAIR replies, that the nmea parser doesn't know.
+func (e *PositionError) update(line string) (proprietary bool) {
+	ind := strings.Index(line, "$P")
+	if ind == -1 {
+		return false
+	}
+	line = line[ind:]
+	if !strings.HasPrefix(line, "$PQTM") && !strings.HasPrefix(line, "$PAIR") {
+		return false
+	}
+	if star := strings.IndexByte(line, '*'); star != -1 {
+		line = line[:star]
+	}
+	// $PQTMEPE,<version>,<north>,<east>,<down>,<2d>,<3d>
+	fields := strings.Split(strings.TrimSpace(line), ",")
+	if fields[0] != "$PQTMEPE" || len(fields) < 7 {
+		return true
+	}
+	var values [5]float64
+	for i := range values {
+		v, err := strconv.ParseFloat(fields[i+2], 64)
+		if err != nil {
+			return true
+		}
+		values[i] = v
+	}
+	*e = PositionError{
+		North:      values[0],
+		East:       values[1],
+		Down:       values[2],
+		Horizontal: values[3],
+		Spherical:  values[4],
+		Valid:      true,
+	}
+	return true
+}
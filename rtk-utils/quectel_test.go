@@ -0,0 +1,32 @@
+package rtkutils
+
+import (
+	"testing"
+
+	"go.viam.com/test"
+)
+
+const (
+	testEPE   = "$PQTMEPE,2,0.012,0.010,0.025,0.016,0.030*55"
+	testVERNO = "$PQTMVERNO,LC29HDANR11A03S_RSA,2023/05/10,11:24:45*2F"
+	testPAIR  = "$PAIR001,062,0*3F"
+)
+
+func TestPositionError(t *testing.T) {
+	var e PositionError
+	test.That(t, e.update(testGGAEpoch1), test.ShouldBeFalse)
+	test.That(t, e.update(testRMC), test.ShouldBeFalse)
+	test.That(t, e.Valid, test.ShouldBeFalse)
+
+	test.That(t, e.update(testEPE), test.ShouldBeTrue)
+	test.That(t, e, test.ShouldResemble, PositionError{
+		North: 0.012, East: 0.010, Down: 0.025, Horizontal: 0.016, Spherical: 0.030, Valid: true,
+	})
+
+	// replies to commands are known, but hold no position error
+	e = PositionError{}
+	test.That(t, e.update(testVERNO), test.ShouldBeTrue)
+	test.That(t, e.update(testPAIR), test.ShouldBeTrue)
+	test.That(t, e.update("$PQTMEPE,2,,,,,*7A"), test.ShouldBeTrue)
+	test.That(t, e.Valid, test.ShouldBeFalse)
+}
@@ -0,0 +1,180 @@
+package rtkutils
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+)
+
+const (
+	// radio frames start with these two bytes, which rtcm frames never start with
+	radioPreamble0 = 0xAA
+	radioPreamble1 = 0x55
+	// preamble, 2 bytes length and 2 bytes sequence number
+	radioHeaderLen = 2 + 2 + 2
+	radioCRCLen    = 2
+	// a signed rtcm frame and its tag fit with room to spare
+	maxRadioPayload = 2 * maxRTCMFrame
+	// sequence gaps larger than this are taken for the station restarting rather than frames lost
+	maxRadioSeqGap = 256
+)
+
+// radioCRC returns the CRC-16/CCITT-FALSE of data.
+func radioCRC(data []byte) uint16 {
+	crc := uint16(0xFFFF)
+	for _, b := range data {
+		crc ^= uint16(b) << 8
+		for i := 0; i < 8; i++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
+
+// RadioFramer wraps what a station writes to a transparent radio in radio frames: the preamble
+// 0xAA 0x55, the length of the payload and a sequence number, both 16 bit big endian, the payload
+// and the CRC-16/CCITT-FALSE of the length, sequence number and payload. A RadioLink on the rover
+// unwraps them, dropping the frames the radio corrupted and counting those it lost, which the CRC
+// of rtcm frames alone can't tell apart from noise between them. A nil framer leaves writes as they
+// are.
+type RadioFramer struct {
+	seq uint16
+}
+
+// NewRadioFramer returns a framer if enabled is set, nil otherwise.
+func NewRadioFramer(enabled bool) *RadioFramer {
+	if !enabled {
+		return nil
+	}
+	return &RadioFramer{}
+}
+
+// Wrap returns payload in a radio frame, in a new buffer.
+func (f *RadioFramer) Wrap(payload []byte) []byte {
+	if f == nil {
+		return payload
+	}
+	frame := make([]byte, radioHeaderLen, radioHeaderLen+len(payload)+radioCRCLen)
+	frame[0], frame[1] = radioPreamble0, radioPreamble1
+	binary.BigEndian.PutUint16(frame[2:], uint16(len(payload)))
+	binary.BigEndian.PutUint16(frame[4:], f.seq)
+	f.seq++
+	frame = append(frame, payload...)
+	crc := radioCRC(frame[2:])
+	return append(frame, byte(crc>>8), byte(crc))
+}
+
+// RadioLink unwraps the radio frames of a RadioFramer read by a rover, and counts the frames it
+// read, dropped because their CRC didn't match, and lost, from the gaps in their sequence numbers.
+// A nil RadioLink reads streams as they are.
+type RadioLink struct {
+	frames    Counter
+	corrupted Counter
+	lost      Counter
+
+	// sequence number of the last frame, across the readers of the link
+	lastSeq uint16
+	hasSeq  bool
+}
+
+// NewRadioLink returns a link if enabled is set, nil otherwise.
+func NewRadioLink(enabled bool) *RadioLink {
+	if !enabled {
+		return nil
+	}
+	return &RadioLink{}
+}
+
+// Reader returns a reader of the payloads of the radio frames in r, which closes r.
+func (l *RadioLink) Reader(r io.ReadCloser) io.ReadCloser {
+	if l == nil {
+		return r
+	}
+	return &radioLinkReader{
+		ReadCloser: r,
+		link:       l,
+		buf:        bufio.NewReaderSize(r, 2*(radioHeaderLen+maxRadioPayload+radioCRCLen)),
+	}
+}
+
+// Readings returns how many radio frames were read, corrupted and lost.
+func (l *RadioLink) Readings() map[string]interface{} {
+	if l == nil {
+		return map[string]interface{}{}
+	}
+	return map[string]interface{}{
+		"radio_frames":           l.frames.Get(),
+		"radio_frames_corrupted": l.corrupted.Get(),
+		"radio_frames_lost":      l.lost.Get(),
+	}
+}
+
+// sequence takes the sequence number of a frame, counting the frames lost since the last one.
+func (l *RadioLink) sequence(seq uint16) {
+	if gap := seq - l.lastSeq - 1; l.hasSeq && gap > 0 && gap < maxRadioSeqGap {
+		l.lost.Add(uint64(gap))
+	}
+	l.lastSeq, l.hasSeq = seq, true
+}
+
+type radioLinkReader struct {
+	io.ReadCloser
+	link    *RadioLink
+	buf     *bufio.Reader
+	payload []byte
+	pending []byte // what is left of payload to read
+}
+
+func (r *radioLinkReader) Read(p []byte) (int, error) {
+	for len(r.pending) == 0 {
+		if err := r.next(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, r.pending)
+	r.pending = r.pending[n:]
+	return n, nil
+}
+
+// next reads the next radio frame with a valid CRC into pending, skipping everything else.
+func (r *radioLinkReader) next() error {
+	for {
+		b, err := r.buf.ReadByte()
+		if err != nil {
+			return err
+		}
+		if b != radioPreamble0 {
+			continue
+		}
+		header, err := r.buf.Peek(radioHeaderLen - 1)
+		if err != nil || header[0] != radioPreamble1 {
+			continue
+		}
+		length := int(binary.BigEndian.Uint16(header[1:]))
+		if length > maxRadioPayload {
+			continue
+		}
+		rest, err := r.buf.Peek(radioHeaderLen - 1 + length + radioCRCLen)
+		if err != nil {
+			// too short to be a frame, keep scanning what is left of the stream
+			continue
+		}
+		end := len(rest) - radioCRCLen
+		if radioCRC(rest[1:end]) != binary.BigEndian.Uint16(rest[end:]) {
+			r.link.corrupted.Add(1)
+			continue
+		}
+		r.payload = append(r.payload[:0], rest[radioHeaderLen-1:end]...)
+		r.link.sequence(binary.BigEndian.Uint16(rest[3:]))
+		r.link.frames.Add(1)
+		if _, err := r.buf.Discard(len(rest)); err != nil {
+			return err
+		}
+		r.pending = r.payload
+		return nil
+	}
+}
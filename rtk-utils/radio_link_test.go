@@ -0,0 +1,55 @@
+package rtkutils
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"go.viam.com/test"
+)
+
+func TestRadioCRC(t *testing.T) {
+	// the check value of CRC-16/CCITT-FALSE
+	test.That(t, radioCRC([]byte("123456789")), test.ShouldEqual, uint16(0x29B1))
+}
+
+func TestRadioLink(t *testing.T) {
+	test.That(t, NewRadioFramer(false).Wrap([]byte{1, 2}), test.ShouldResemble, []byte{1, 2})
+	src := io.NopCloser(bytes.NewReader([]byte{1, 2}))
+	test.That(t, NewRadioLink(false).Reader(src), test.ShouldResemble, src)
+	test.That(t, NewRadioLink(false).Readings(), test.ShouldBeEmpty)
+
+	framer := NewRadioFramer(true)
+	first := gpsMSMFrame(1000)
+	frame := framer.Wrap(first)
+	test.That(t, frame[:6], test.ShouldResemble, []byte{0xAA, 0x55, 0x00, byte(len(first)), 0x00, 0x00})
+	test.That(t, frame[6:len(frame)-2], test.ShouldResemble, first)
+
+	var stream []byte
+	// noise before the first frame
+	stream = append(stream, 0xAA, 0x00, 0xD3, 0x55)
+	stream = append(stream, frame...)
+	// a frame corrupted by the radio
+	corrupted := framer.Wrap(gpsMSMFrame(2000))
+	corrupted[8] ^= 0xFF
+	stream = append(stream, corrupted...)
+	// two frames lost
+	framer.Wrap(gpsMSMFrame(3000))
+	framer.Wrap(gpsMSMFrame(4000))
+	last := gpsMSMFrame(5000)
+	stream = append(stream, framer.Wrap(last)...)
+
+	link := NewRadioLink(true)
+	payloads, err := io.ReadAll(link.Reader(io.NopCloser(bytes.NewReader(stream))))
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, payloads, test.ShouldResemble, append(append([]byte{}, first...), last...))
+	test.That(t, link.Readings(), test.ShouldResemble, map[string]interface{}{
+		"radio_frames": uint64(2), "radio_frames_corrupted": uint64(1), "radio_frames_lost": uint64(3),
+	})
+
+	// the rtcm frames read through a link are those the station wrapped
+	frames := NewFrameReader(NewRadioLink(true).Reader(io.NopCloser(bytes.NewReader(stream))))
+	got, err := frames.Next()
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, got, test.ShouldResemble, first)
+}
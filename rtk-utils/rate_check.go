@@ -0,0 +1,178 @@
+package rtkutils
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// EventReceiverRateMismatch is raised when a receiver doesn't send what it was configured to, at the
+// rate it was configured to.
+const EventReceiverRateMismatch = "receiver_rate_mismatch"
+
+// Types of the UBX messages in ReceiverRates.
+const (
+	UBXNavPVTMessage = "NAV-PVT"
+	UBXNavAttMessage = "NAV-ATT"
+)
+
+const (
+	// how long the output of a receiver is measured once it applied its configuration
+	rateCheckTime = 10 * time.Second
+	// a message type coming less than this share of the times per epoch it was configured to is
+	// taken to be missing, more is fine, such as the several GSV of an epoch
+	rateCheckMinShare = 0.5
+	// how far off, as a share, the epoch rate can be from the rate it was configured to
+	rateCheckEpochTolerance = 0.25
+	// times in a row a receiver is configured again because of its rates before it is left as is
+	maxRateCorrections = 2
+)
+
+// ReceiverRates are the output a receiver is configured to send: the messages of each type it sends
+// per epoch, keyed by sentence type without the talker such as "GGA", by address for proprietary
+// sentences such as "PQTMEPE", or by UBX message such as "NAV-PVT", and its epochs per second, 0 if
+// its configuration leaves the receiver's own rate.
+type ReceiverRates struct {
+	Messages  map[string]float64
+	EpochRate float64
+}
+
+// RateCheck measures the output of a receiver for a few seconds once it was set up, and compares it
+// with the rates its configuration set, so that a configuration message the receiver silently
+// ignored, such as one its firmware doesn't support, doesn't go unnoticed. A receiver that doesn't
+// send what it was configured to is configured again, twice at most in a row. The zero value checks
+// nothing until the receiver is set up, and is safe for concurrent use.
+type RateCheck struct {
+	mu          sync.Mutex
+	rates       ReceiverRates
+	setUpAt     time.Time
+	counts      map[string]int
+	epochs      int
+	firstEpoch  time.Time
+	checked     bool
+	mismatches  []string
+	inARow      int // times in a row the receiver was configured again
+	corrections Counter
+}
+
+// SetUp notes that the receiver was set up at now with a configuration that sets rates, which
+// starts measuring its output afresh.
+func (c *RateCheck) SetUp(rates ReceiverRates, now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.rates, c.setUpAt, c.counts = rates, now, map[string]int{}
+	c.epochs, c.firstEpoch, c.checked, c.mismatches = 0, time.Time{}, false, nil
+}
+
+// measuring returns whether the output read at now is measured. It must be called with mu held.
+func (c *RateCheck) measuring(now time.Time) bool {
+	if c.setUpAt.IsZero() || c.checked || (len(c.rates.Messages) == 0 && c.rates.EpochRate == 0) {
+		return false
+	}
+	return now.Sub(c.setUpAt) >= configSettleTime
+}
+
+// Sentence takes a sentence read from the receiver at now. Sentences with a bad checksum are ignored.
+func (c *RateCheck) Sentence(line string, now time.Time) {
+	start := strings.IndexByte(line, '$')
+	if start == -1 {
+		return
+	}
+	body, checksum, ok := strings.Cut(strings.TrimSpace(line[start+1:]), "*")
+	if !ok || !validNMEAChecksum(body, checksum) {
+		return
+	}
+	key, talker, typ := sentenceKey(line)
+	if talker == "P" {
+		typ = key
+	}
+	c.Message(typ, now)
+}
+
+// Message takes a message of type typ, such as a UBX message, read from the receiver at now.
+func (c *RateCheck) Message(typ string, now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.measuring(now) && c.epochs > 0 {
+		c.counts[typ]++
+	}
+}
+
+// Epoch takes an epoch the receiver sent at now. Once the output was measured, it adds an event to
+// events if it doesn't match the rates of the configuration, and returns true when the receiver has
+// to be configured again.
+func (c *RateCheck) Epoch(now time.Time, events *EventLog) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.measuring(now) {
+		return false
+	}
+	if c.epochs == 0 {
+		c.firstEpoch = now
+	}
+	if now.Sub(c.firstEpoch) < rateCheckTime {
+		// messages are counted from the start of the first epoch, those before are only part of one
+		c.epochs++
+		return false
+	}
+	c.checked = true
+	c.mismatches = c.compare(now)
+	if len(c.mismatches) == 0 {
+		c.inARow = 0
+		return false
+	}
+	if c.inARow >= maxRateCorrections {
+		events.Add(EventReceiverRateMismatch, "the receiver sends %s, it keeps ignoring its configuration, "+
+			"check that its firmware supports it", strings.Join(c.mismatches, ", "))
+		return false
+	}
+	c.inARow++
+	c.corrections.Add(1)
+	events.Add(EventReceiverRateMismatch, "the receiver sends %s, configuring it again", strings.Join(c.mismatches, ", "))
+	return true
+}
+
+// compare returns how the output measured until the epoch starting at now differs from the rates.
+// It must be called with mu held.
+func (c *RateCheck) compare(now time.Time) []string {
+	var mismatches []string
+	types := make([]string, 0, len(c.rates.Messages))
+	for typ := range c.rates.Messages {
+		types = append(types, typ)
+	}
+	sort.Strings(types)
+	for _, typ := range types {
+		want := c.rates.Messages[typ]
+		if got := float64(c.counts[typ]) / float64(c.epochs); got < want*rateCheckMinShare {
+			mismatches = append(mismatches, fmt.Sprintf("%s %.2g times per epoch instead of %.2g", typ, got, want))
+		}
+	}
+	if want := c.rates.EpochRate; want > 0 {
+		got := float64(c.epochs) / now.Sub(c.firstEpoch).Seconds()
+		if math.Abs(got-want) > want*rateCheckEpochTolerance {
+			mismatches = append(mismatches, fmt.Sprintf("epochs at %.2g Hz instead of %.2g Hz", got, want))
+		}
+	}
+	return mismatches
+}
+
+// Corrections returns the number of times the receiver was configured again because of its rates.
+func (c *RateCheck) Corrections() uint64 {
+	return c.corrections.Get()
+}
+
+// Readings returns, once the output of the receiver was measured, whether it sends what it was
+// configured to and how it doesn't, and how many times it was configured again because of it.
+func (c *RateCheck) Readings() map[string]interface{} {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	readings := map[string]interface{}{"receiver_rate_corrections": c.corrections.Get()}
+	if c.checked {
+		readings["receiver_rates_ok"] = len(c.mismatches) == 0
+		readings["receiver_rate_mismatches"] = stringsResult(c.mismatches)
+	}
+	return readings
+}
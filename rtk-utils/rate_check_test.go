@@ -0,0 +1,79 @@
+package rtkutils
+
+import (
+	"testing"
+	"time"
+
+	"github.com/edaniels/golog"
+	"go.viam.com/test"
+)
+
+// sendRates has the receiver of c send an epoch of a GGA, and a PQTMEPE every other epoch if epe is
+// set, every interval from now until the check ends, returning when it did and whether it has to be
+// configured again.
+func sendRates(t *testing.T, c *RateCheck, now time.Time, interval time.Duration, epe bool, events *EventLog) (time.Time, bool) {
+	t.Helper()
+	gga := configSentence(t, "GNGGA,120000.00,4000.0,N,10500.0,W,4,12,0.8,1600.0,M,-20.0,M,1.0,0000")
+	pqtm := configSentence(t, "PQTMEPE,2,0.010,0.010,0.020,0.014,0.024")
+	for i := 0; i < 1000; i++ {
+		configure := c.Epoch(now, events)
+		if _, checked := c.Readings()["receiver_rates_ok"]; checked {
+			return now, configure
+		}
+		c.Sentence(gga, now)
+		if epe && i%2 == 0 {
+			c.Sentence(pqtm, now)
+		}
+		now = now.Add(interval)
+	}
+	t.Fatal("the rates were never checked")
+	return now, false
+}
+
+func TestRateCheck(t *testing.T) {
+	events := NewEventLog(golog.NewTestLogger(t))
+	rates := ReceiverRates{Messages: map[string]float64{"GGA": 1, "PQTMEPE": 0.5}, EpochRate: 1}
+	now := time.Now()
+
+	var c RateCheck
+	// nothing is checked until the receiver is set up
+	test.That(t, c.Epoch(now, events), test.ShouldBeFalse)
+	test.That(t, c.Readings(), test.ShouldResemble, map[string]interface{}{"receiver_rate_corrections": uint64(0)})
+
+	c.SetUp(rates, now)
+	now, configure := sendRates(t, &c, now.Add(configSettleTime), time.Second, true, events)
+	test.That(t, configure, test.ShouldBeFalse)
+	test.That(t, c.Readings(), test.ShouldResemble, map[string]interface{}{
+		"receiver_rate_corrections": uint64(0), "receiver_rates_ok": true, "receiver_rate_mismatches": []interface{}{},
+	})
+
+	// a receiver that ignored the rate of a sentence and of its epochs is configured again
+	c.SetUp(rates, now)
+	now, configure = sendRates(t, &c, now.Add(configSettleTime), 200*time.Millisecond, false, events)
+	test.That(t, configure, test.ShouldBeTrue)
+	test.That(t, c.Corrections(), test.ShouldEqual, 1)
+	mismatches := events.Events(EventReceiverRateMismatch)
+	test.That(t, mismatches, test.ShouldHaveLength, 1)
+	test.That(t, mismatches[0].Message, test.ShouldEqual, "the receiver sends PQTMEPE 0 times per epoch instead of 0.5, "+
+		"epochs at 5 Hz instead of 1 Hz, configuring it again")
+	test.That(t, c.Readings()["receiver_rates_ok"], test.ShouldBeFalse)
+
+	// twice in a row at most
+	c.SetUp(rates, now)
+	now, configure = sendRates(t, &c, now.Add(configSettleTime), 200*time.Millisecond, false, events)
+	test.That(t, configure, test.ShouldBeTrue)
+	c.SetUp(rates, now)
+	now, configure = sendRates(t, &c, now.Add(configSettleTime), 200*time.Millisecond, false, events)
+	test.That(t, configure, test.ShouldBeFalse)
+	test.That(t, c.Corrections(), test.ShouldEqual, 2)
+	mismatches = events.Events(EventReceiverRateMismatch)
+	test.That(t, mismatches, test.ShouldHaveLength, 3)
+	test.That(t, mismatches[2].Message, test.ShouldEqual, "the receiver sends PQTMEPE 0 times per epoch instead of 0.5, "+
+		"epochs at 5 Hz instead of 1 Hz, it keeps ignoring its configuration, check that its firmware supports it")
+
+	// a receiver set up without rates isn't checked
+	c.SetUp(ReceiverRates{}, now)
+	test.That(t, c.Epoch(now.Add(time.Minute), events), test.ShouldBeFalse)
+	_, checked := c.Readings()["receiver_rates_ok"]
+	test.That(t, checked, test.ShouldBeFalse)
+}
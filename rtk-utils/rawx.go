@@ -0,0 +1,276 @@
+package rtkutils
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// RawObservablesCommand returns the raw observables a rover buffered, those after "after_seq" if it
+// is set, and enables or disables their output with "enable".
+const RawObservablesCommand = "raw_observables"
+
+// ErrNoRawOutput is returned by the raw observables command of a rover whose receiver doesn't
+// output raw measurements.
+var ErrNoRawOutput = errors.New("the receiver doesn't output raw measurements")
+
+const (
+	rawxHeaderLen      = 16
+	rawxMeasurementLen = 32
+	// epochs of raw observables a rover keeps for the raw observables command, a minute at 1 Hz
+	rawEpochsKept = 60
+)
+
+// GNSS names of the gnssId of UBX messages.
+var ubxGNSS = map[byte]string{
+	0: "GPS",
+	1: "SBAS",
+	2: "Galileo",
+	3: "BeiDou",
+	5: "QZSS",
+	6: "GLONASS",
+	7: "NavIC",
+}
+
+// RawMeasurement is the measurement of a signal of a satellite in a UBX-RXM-RAWX message.
+type RawMeasurement struct {
+	GNSS   string
+	SVID   int
+	SigID  int
+	FreqID int // GLONASS frequency slot + 7
+
+	Pseudorange  float64 // m
+	CarrierPhase float64 // cycles
+	Doppler      float64 // Hz
+	CNR          int     // dB-Hz
+	LockTime     int     // ms the carrier phase has been tracked continuously, up to 64500
+
+	PseudorangeStdev  float64 // m
+	CarrierPhaseStdev float64 // cycles
+	DopplerStdev      float64 // Hz
+
+	PseudorangeValid  bool
+	CarrierPhaseValid bool
+	HalfCycleResolved bool // the half cycle ambiguity of the carrier phase is resolved
+}
+
+// RawEpoch is the raw measurements of an epoch, from a UBX-RXM-RAWX message.
+type RawEpoch struct {
+	TOW          float64 // receiver time of week, in s
+	Week         int     // GPS week
+	LeapSeconds  int     // GPS - UTC, in s
+	LeapKnown    bool
+	ClockReset   bool // the receiver clock was reset, so the carrier phases start over
+	Measurements []RawMeasurement
+}
+
+// ParseRxmRawx parses the payload of a UBX-RXM-RAWX message.
+func ParseRxmRawx(payload []byte) (RawEpoch, error) {
+	if len(payload) < rawxHeaderLen {
+		return RawEpoch{}, fmt.Errorf("rxm-rawx payload is %d bytes, expected at least %d", len(payload), rawxHeaderLen)
+	}
+	numMeas := int(payload[11])
+	if want := rawxHeaderLen + numMeas*rawxMeasurementLen; len(payload) != want {
+		return RawEpoch{}, fmt.Errorf("rxm-rawx payload of %d measurements is %d bytes, expected %d", numMeas, len(payload), want)
+	}
+	recStat := payload[12]
+	epoch := RawEpoch{
+		TOW:         math.Float64frombits(binary.LittleEndian.Uint64(payload[0:])),
+		Week:        int(binary.LittleEndian.Uint16(payload[8:])),
+		LeapSeconds: int(int8(payload[10])),
+		LeapKnown:   recStat&0x01 != 0,
+		ClockReset:  recStat&0x02 != 0,
+	}
+	for i := 0; i < numMeas; i++ {
+		m := payload[rawxHeaderLen+i*rawxMeasurementLen:]
+		gnss, ok := ubxGNSS[m[20]]
+		if !ok {
+			gnss = fmt.Sprintf("gnss %d", m[20])
+		}
+		trkStat := m[30]
+		epoch.Measurements = append(epoch.Measurements, RawMeasurement{
+			GNSS:         gnss,
+			SVID:         int(m[21]),
+			SigID:        int(m[22]),
+			FreqID:       int(m[23]),
+			Pseudorange:  math.Float64frombits(binary.LittleEndian.Uint64(m[0:])),
+			CarrierPhase: math.Float64frombits(binary.LittleEndian.Uint64(m[8:])),
+			Doppler:      float64(math.Float32frombits(binary.LittleEndian.Uint32(m[16:]))),
+			LockTime:     int(binary.LittleEndian.Uint16(m[24:])),
+			CNR:          int(m[26]),
+			// the standard deviations are scaled by a power of 2, or a multiple for the carrier phase
+			PseudorangeStdev:  0.01 * math.Exp2(float64(m[27]&0x0F)),
+			CarrierPhaseStdev: 0.004 * float64(m[28]&0x0F),
+			DopplerStdev:      0.002 * math.Exp2(float64(m[29]&0x0F)),
+			PseudorangeValid:  trkStat&0x01 != 0,
+			CarrierPhaseValid: trkStat&0x02 != 0,
+			HalfCycleResolved: trkStat&0x04 != 0,
+		})
+	}
+	return epoch, nil
+}
+
+type bufferedRawEpoch struct {
+	seq      uint64
+	received time.Time
+	epoch    RawEpoch
+}
+
+// RawObservables keeps the latest epochs of raw measurements a u-blox receiver outputs among its
+// nmea sentences, for users doing their own rtk or ppp processing. The output of the measurements
+// is enabled on demand. A nil buffer keeps none.
+type RawObservables struct {
+	ubx []byte // start of a frame cut off by the end of the last write
+
+	mu      sync.Mutex
+	enabled bool // the output was enabled by a command
+	seq     uint64
+	epochs  []bufferedRawEpoch // oldest to newest
+}
+
+// NewRawObservables returns a buffer if the receiver outputs raw measurements, nil otherwise.
+func NewRawObservables(profile ReceiverProfile) *RawObservables {
+	if !profile.RawOutput {
+		return nil
+	}
+	return &RawObservables{}
+}
+
+// Write reads the next bytes output by the receiver. It never fails.
+func (r *RawObservables) Write(p []byte) (int, error) {
+	if r == nil {
+		return len(p), nil
+	}
+	var frames [][]byte
+	frames, r.ubx = SplitUBXFrames(append(r.ubx, p...))
+	now := time.Now()
+	for _, frame := range frames {
+		cls, id, payload := UBXFrame(frame)
+		if cls != UBXClassRxm || id != UBXRxmRawx {
+			continue
+		}
+		epoch, err := ParseRxmRawx(payload)
+		if err != nil {
+			continue
+		}
+		r.mu.Lock()
+		r.seq++
+		r.epochs = append(r.epochs, bufferedRawEpoch{seq: r.seq, received: now, epoch: epoch})
+		if len(r.epochs) > rawEpochsKept {
+			r.epochs = r.epochs[1:]
+		}
+		r.mu.Unlock()
+	}
+	return len(p), nil
+}
+
+// Enabled reports whether the output of raw measurements was enabled by a command, so that it is
+// enabled again when the receiver is set up.
+func (r *RawObservables) Enabled() bool {
+	if r == nil {
+		return false
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.enabled
+}
+
+// Epochs returns the buffered epochs after seq, with their sequence numbers.
+func (r *RawObservables) Epochs(after uint64) ([]uint64, []RawEpoch) {
+	if r == nil {
+		return nil, nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var seqs []uint64
+	var epochs []RawEpoch
+	for _, e := range r.epochs {
+		if e.seq > after {
+			seqs = append(seqs, e.seq)
+			epochs = append(epochs, e.epoch)
+		}
+	}
+	return seqs, epochs
+}
+
+// RawObservablesResult answers a RawObservablesCommand, writing the rate of RXM-RAWX with write when
+// the command enables or disables it. The output stays enabled while keep is set, as for ppk.
+func (r *RawObservables) RawObservablesResult(cmd map[string]interface{}, write func([]byte) error, keep bool) (map[string]interface{}, error) {
+	if r == nil {
+		return nil, ErrNoRawOutput
+	}
+	if v, ok := cmd["enable"]; ok {
+		enable, ok := v.(bool)
+		if !ok {
+			return nil, errors.New("\"enable\" must be a boolean")
+		}
+		var rate byte
+		if enable || keep {
+			rate = 1
+		}
+		if err := write(UBXMessageRatePacket(UBXClassRxm, UBXRxmRawx, rate)); err != nil {
+			return nil, fmt.Errorf("failed to set the raw measurement output: %w", err)
+		}
+		r.mu.Lock()
+		r.enabled = enable
+		r.mu.Unlock()
+	}
+
+	after := IntArg(cmd, "after_seq", 0)
+	if after < 0 {
+		return nil, errors.New("\"after_seq\" must not be negative")
+	}
+	r.mu.Lock()
+	enabled, last, received := r.enabled || keep, r.seq, r.receivedAt()
+	r.mu.Unlock()
+
+	seqs, epochs := r.Epochs(uint64(after))
+	results := []interface{}{}
+	for i, e := range epochs {
+		measurements := []interface{}{}
+		for _, m := range e.Measurements {
+			measurements = append(measurements, map[string]interface{}{
+				"gnss":                       m.GNSS,
+				"sv_id":                      m.SVID,
+				"sig_id":                     m.SigID,
+				"freq_id":                    m.FreqID,
+				"pseudorange_m":              m.Pseudorange,
+				"carrier_phase_cycles":       m.CarrierPhase,
+				"doppler_hz":                 m.Doppler,
+				"cno_db_hz":                  m.CNR,
+				"lock_time_ms":               m.LockTime,
+				"pseudorange_stdev_m":        m.PseudorangeStdev,
+				"carrier_phase_stdev_cycles": m.CarrierPhaseStdev,
+				"doppler_stdev_hz":           m.DopplerStdev,
+				"pseudorange_valid":          m.PseudorangeValid,
+				"carrier_phase_valid":        m.CarrierPhaseValid,
+				"half_cycle_resolved":        m.HalfCycleResolved,
+			})
+		}
+		results = append(results, map[string]interface{}{
+			"seq":          seqs[i],
+			"tow_sec":      e.TOW,
+			"week":         e.Week,
+			"leap_seconds": e.LeapSeconds,
+			"leap_known":   e.LeapKnown,
+			"clock_reset":  e.ClockReset,
+			"measurements": measurements,
+		})
+	}
+	result := map[string]interface{}{"enabled": enabled, "last_seq": last, "epochs": results}
+	if !received.IsZero() {
+		result["last_received"] = received.UTC().Format(time.RFC3339Nano)
+	}
+	return result, nil
+}
+
+// receivedAt returns when the newest epoch was read, zero if there is none. The caller must hold mu.
+func (r *RawObservables) receivedAt() time.Time {
+	if len(r.epochs) == 0 {
+		return time.Time{}
+	}
+	return r.epochs[len(r.epochs)-1].received
+}
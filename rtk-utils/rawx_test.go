@@ -0,0 +1,125 @@
+package rtkutils
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"testing"
+
+	"go.viam.com/test"
+)
+
+// rawxPayload returns the payload of an RXM-RAWX message of week 2300 with a GPS L1 measurement of
+// satellite 12 and a GLONASS one of satellite 3.
+func rawxPayload(tow float64) []byte {
+	payload := make([]byte, rawxHeaderLen+2*rawxMeasurementLen)
+	binary.LittleEndian.PutUint64(payload[0:], math.Float64bits(tow))
+	binary.LittleEndian.PutUint16(payload[8:], 2300)
+	payload[10] = 18
+	payload[11] = 2
+	payload[12] = 0x01
+
+	m := payload[rawxHeaderLen:]
+	binary.LittleEndian.PutUint64(m[0:], math.Float64bits(21234567.25))
+	binary.LittleEndian.PutUint64(m[8:], math.Float64bits(111589264.5))
+	binary.LittleEndian.PutUint32(m[16:], math.Float32bits(-1234.5))
+	m[21] = 12
+	binary.LittleEndian.PutUint16(m[24:], 64500)
+	m[26] = 45
+	m[27] = 3 // 0.08 m
+	m[28] = 2 // 0.008 cycles
+	m[29] = 1 // 0.004 Hz
+	m[30] = 0x07
+
+	m = payload[rawxHeaderLen+rawxMeasurementLen:]
+	m[20] = 6
+	m[21] = 3
+	m[23] = 5
+	m[30] = 0x01
+	return payload
+}
+
+func TestParseRxmRawx(t *testing.T) {
+	epoch, err := ParseRxmRawx(rawxPayload(345600.5))
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, epoch.TOW, test.ShouldEqual, 345600.5)
+	test.That(t, epoch.Week, test.ShouldEqual, 2300)
+	test.That(t, epoch.LeapSeconds, test.ShouldEqual, 18)
+	test.That(t, epoch.LeapKnown, test.ShouldBeTrue)
+	test.That(t, epoch.ClockReset, test.ShouldBeFalse)
+	test.That(t, epoch.Measurements, test.ShouldHaveLength, 2)
+	test.That(t, epoch.Measurements[0], test.ShouldResemble, RawMeasurement{
+		GNSS:              "GPS",
+		SVID:              12,
+		Pseudorange:       21234567.25,
+		CarrierPhase:      111589264.5,
+		Doppler:           -1234.5,
+		CNR:               45,
+		LockTime:          64500,
+		PseudorangeStdev:  0.08,
+		CarrierPhaseStdev: 0.008,
+		DopplerStdev:      0.004,
+		PseudorangeValid:  true,
+		CarrierPhaseValid: true,
+		HalfCycleResolved: true,
+	})
+	test.That(t, epoch.Measurements[1].GNSS, test.ShouldEqual, "GLONASS")
+	test.That(t, epoch.Measurements[1].FreqID, test.ShouldEqual, 5)
+	test.That(t, epoch.Measurements[1].CarrierPhaseValid, test.ShouldBeFalse)
+
+	_, err = ParseRxmRawx(make([]byte, 8))
+	test.That(t, err, test.ShouldBeError, errors.New("rxm-rawx payload is 8 bytes, expected at least 16"))
+	_, err = ParseRxmRawx(rawxPayload(0)[:40])
+	test.That(t, err, test.ShouldBeError, errors.New("rxm-rawx payload of 2 measurements is 40 bytes, expected 80"))
+}
+
+func TestRawObservables(t *testing.T) {
+	var none *RawObservables
+	_, err := none.RawObservablesResult(map[string]interface{}{}, nil, false)
+	test.That(t, err, test.ShouldEqual, ErrNoRawOutput)
+	test.That(t, NewRawObservables(ReceiverProfile{}), test.ShouldBeNil)
+
+	r := NewRawObservables(ReceiverProfile{RawOutput: true})
+	var written [][]byte
+	write := func(p []byte) error {
+		written = append(written, p)
+		return nil
+	}
+	res, err := r.RawObservablesResult(map[string]interface{}{"enable": true}, write, false)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, written, test.ShouldResemble, [][]byte{UBXMessageRatePacket(UBXClassRxm, UBXRxmRawx, 1)})
+	test.That(t, res["enabled"], test.ShouldBeTrue)
+	test.That(t, r.Enabled(), test.ShouldBeTrue)
+
+	// frames are split across writes and mixed with nmea
+	first := UBXPacket(UBXClassRxm, UBXRxmRawx, rawxPayload(1))
+	second := UBXPacket(UBXClassRxm, UBXRxmRawx, rawxPayload(2))
+	stream := append(append(append([]byte("$GNGGA,1*00\r\n"), first...), UBXPacket(UBXClassNav, UBXNavPVT, nil)...), second...)
+	r.Write(stream[:30])
+	r.Write(stream[30:])
+
+	res, err = r.RawObservablesResult(map[string]interface{}{}, write, false)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, res["last_seq"], test.ShouldEqual, uint64(2))
+	test.That(t, res["epochs"], test.ShouldHaveLength, 2)
+	res, err = r.RawObservablesResult(map[string]interface{}{"after_seq": 1.0}, write, false)
+	test.That(t, err, test.ShouldBeNil)
+	epochs := res["epochs"].([]interface{})
+	test.That(t, epochs, test.ShouldHaveLength, 1)
+	epoch := epochs[0].(map[string]interface{})
+	test.That(t, epoch["seq"], test.ShouldEqual, uint64(2))
+	test.That(t, epoch["tow_sec"], test.ShouldEqual, 2.0)
+	test.That(t, epoch["measurements"], test.ShouldHaveLength, 2)
+
+	// ppk keeps the output on
+	res, err = r.RawObservablesResult(map[string]interface{}{"enable": false}, write, true)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, written[1], test.ShouldResemble, UBXMessageRatePacket(UBXClassRxm, UBXRxmRawx, 1))
+	test.That(t, res["enabled"], test.ShouldBeTrue)
+	test.That(t, r.Enabled(), test.ShouldBeFalse)
+
+	_, err = r.RawObservablesResult(map[string]interface{}{"enable": "yes"}, write, false)
+	test.That(t, err, test.ShouldBeError, errors.New("\"enable\" must be a boolean"))
+	_, err = r.RawObservablesResult(map[string]interface{}{"enable": true}, func([]byte) error { return errors.New("closed") }, false)
+	test.That(t, err, test.ShouldBeError, errors.New("failed to set the raw measurement output: closed"))
+}
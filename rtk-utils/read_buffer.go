@@ -0,0 +1,121 @@
+package rtkutils
+
+import (
+	"fmt"
+	"io"
+	"sync"
+)
+
+const (
+	// DefaultReadBufferSize is how much is read from a serial port or an i2c receiver at once.
+	DefaultReadBufferSize = 1024
+	minReadBufferSize     = 64
+	maxReadBufferSize     = 64 << 10
+)
+
+// ValidateReadBufferSize checks the read buffer size of a serial or i2c source, zero for the default.
+func ValidateReadBufferSize(field string, size int) error {
+	if size != 0 && (size < minReadBufferSize || size > maxReadBufferSize) {
+		return fmt.Errorf("%s must be between %d and %d bytes", field, minReadBufferSize, maxReadBufferSize)
+	}
+	return nil
+}
+
+// ReadBufferUsage tracks how full the reads into a read buffer get. A read that fills the buffer
+// means the port had more waiting, such as the MSM7 burst of a base tracking four constellations,
+// and that a larger buffer would take it at once. A nil usage has the default size and records nothing.
+type ReadBufferUsage struct {
+	size int
+
+	mu        sync.Mutex
+	highWater int
+	fullReads uint64
+}
+
+// NewReadBufferUsage returns the usage of a buffer of size bytes, DefaultReadBufferSize if it is zero.
+func NewReadBufferUsage(size int) *ReadBufferUsage {
+	if size <= 0 {
+		size = DefaultReadBufferSize
+	}
+	return &ReadBufferUsage{size: size}
+}
+
+// Size returns the size of the buffer.
+func (u *ReadBufferUsage) Size() int {
+	if u == nil {
+		return DefaultReadBufferSize
+	}
+	return u.size
+}
+
+// Record notes a read of n bytes into the buffer.
+func (u *ReadBufferUsage) Record(n int) {
+	if u == nil {
+		return
+	}
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if n > u.highWater {
+		u.highWater = n
+	}
+	if n >= u.size {
+		u.fullReads++
+	}
+}
+
+// HighWater returns the most bytes read at once.
+func (u *ReadBufferUsage) HighWater() int {
+	if u == nil {
+		return 0
+	}
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.highWater
+}
+
+// FullReads returns the number of reads that filled the buffer.
+func (u *ReadBufferUsage) FullReads() uint64 {
+	if u == nil {
+		return 0
+	}
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.fullReads
+}
+
+// Readings returns the usage as readings whose names start with prefix.
+func (u *ReadBufferUsage) Readings(prefix string) map[string]interface{} {
+	return map[string]interface{}{
+		prefix + "_read_buffer_size":      u.Size(),
+		prefix + "_read_high_water_bytes": u.HighWater(),
+		prefix + "_full_reads":            u.FullReads(),
+	}
+}
+
+// SizedReader reads a source in reads of the size of a buffer, recording their usage, whatever the
+// size of the reads of its callers.
+type SizedReader struct {
+	io.ReadCloser
+	usage   *ReadBufferUsage
+	buf     []byte
+	pending []byte // read from the source but not returned yet
+}
+
+// NewSizedReader returns a reader of src recording to usage.
+func NewSizedReader(src io.ReadCloser, usage *ReadBufferUsage) *SizedReader {
+	return &SizedReader{ReadCloser: src, usage: usage, buf: make([]byte, usage.Size())}
+}
+
+func (r *SizedReader) Read(p []byte) (int, error) {
+	if len(r.pending) == 0 {
+		n, err := r.ReadCloser.Read(r.buf)
+		r.usage.Record(n)
+		if n == 0 {
+			return 0, err
+		}
+		r.pending = r.buf[:n]
+	}
+	n := copy(p, r.pending)
+	r.pending = r.pending[n:]
+	return n, nil
+}
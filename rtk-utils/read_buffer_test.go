@@ -0,0 +1,53 @@
+package rtkutils
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+
+	"go.viam.com/test"
+)
+
+func TestValidateReadBufferSize(t *testing.T) {
+	test.That(t, ValidateReadBufferSize("read_buffer_size", 0), test.ShouldBeNil)
+	test.That(t, ValidateReadBufferSize("read_buffer_size", 4096), test.ShouldBeNil)
+	test.That(t, ValidateReadBufferSize("read_buffer_size", 16), test.ShouldBeError,
+		errors.New("read_buffer_size must be between 64 and 65536 bytes"))
+	test.That(t, ValidateReadBufferSize("read_buffer_size", 1<<20), test.ShouldNotBeNil)
+}
+
+func TestReadBufferUsage(t *testing.T) {
+	var none *ReadBufferUsage
+	none.Record(10)
+	test.That(t, none.Size(), test.ShouldEqual, DefaultReadBufferSize)
+	test.That(t, none.HighWater(), test.ShouldEqual, 0)
+
+	usage := NewReadBufferUsage(0)
+	test.That(t, usage.Size(), test.ShouldEqual, DefaultReadBufferSize)
+	usage = NewReadBufferUsage(100)
+	usage.Record(40)
+	usage.Record(100)
+	usage.Record(60)
+	test.That(t, usage.HighWater(), test.ShouldEqual, 100)
+	test.That(t, usage.FullReads(), test.ShouldEqual, uint64(1))
+	test.That(t, usage.Readings("nmea"), test.ShouldResemble, map[string]interface{}{
+		"nmea_read_buffer_size":      100,
+		"nmea_read_high_water_bytes": 100,
+		"nmea_full_reads":            uint64(1),
+	})
+}
+
+func TestSizedReader(t *testing.T) {
+	usage := NewReadBufferUsage(64)
+	stream := bytes.Repeat([]byte{0xd3}, 150)
+	r := NewSizedReader(io.NopCloser(bytes.NewReader(stream)), usage)
+
+	// the source is read 64 bytes at a time, whatever the callers read
+	got, err := io.ReadAll(r)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, got, test.ShouldResemble, stream)
+	test.That(t, usage.HighWater(), test.ShouldEqual, 64)
+	test.That(t, usage.FullReads(), test.ShouldEqual, uint64(2))
+	test.That(t, r.Close(), test.ShouldBeNil)
+}
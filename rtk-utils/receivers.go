@@ -0,0 +1,268 @@
+package rtkutils
+
+import "fmt"
+
+// SaveReceiverConfigCommand saves the configuration a rover set its receiver up with to the receiver's
+// non-volatile memory, so that it survives a brownout.
+const SaveReceiverConfigCommand = "save_config_to_receiver"
+
+// Receivers set by the "receiver" attribute of stations and rovers.
+const (
+	ReceiverZEDF9P = "zed-f9p"
+	// ZED-F9R, the ZED-F9P with an IMU for dead reckoning
+	ReceiverZEDF9R = "zed-f9r"
+	ReceiverNEOM8P = "neo-m8p"
+	ReceiverNEOM8T = "neo-m8t"
+	ReceiverLC29H  = "lc29h"
+	ReceiverLG69T  = "lg69t"
+	// SkyTraq PX1122R, as found on NavSpark boards
+	ReceiverPX1122R = "px1122r"
+	// Unicore UM980, which also applies the ppp corrections broadcast by Galileo and BeiDou
+	ReceiverUM980 = "um980"
+)
+
+// UBX ids of the RTCM 3 messages a receiver outputs, in the 0xF5 class of CFG-MSG.
+const (
+	UBXRTCM1005 = 0x05 // stationary rtk reference ARP
+	UBXRTCM1074 = 0x4A // GPS MSM4
+	UBXRTCM1077 = 0x4D // GPS MSM7
+	UBXRTCM1084 = 0x54 // GLONASS MSM4
+	UBXRTCM1087 = 0x57 // GLONASS MSM7
+	UBXRTCM1094 = 0x5E // Galileo MSM4
+	UBXRTCM1124 = 0x7C // BeiDou MSM4
+	UBXRTCM1230 = 0xE6 // GLONASS code-phase biases
+)
+
+// ReceiverProfile is what a receiver supports. u-blox receivers are configured with the legacy CFG
+// messages, since M8 receivers have no CFG-VALSET. Quectel receivers are configured with their
+// proprietary PAIR and PQTM sentences, SkyTraq receivers with their own binary messages, and Unicore
+// receivers with their text commands.
+type ReceiverProfile struct {
+	Name      string
+	Base      bool // can be configured as a station
+	RTK       bool // computes rtk solutions from the corrections written to it
+	RawOutput bool // outputs the RXM-RAWX and RXM-SFRBX measurements ppk needs
+	Attitude  bool // outputs the NAV-ATT attitude of its dead reckoning
+	UBX       bool // outputs UBX, such as the NAV-PVT solution status, among its nmea sentences
+	PPP       bool // applies the Galileo HAS or BeiDou PPP-B2b corrections broadcast by the satellites
+
+	// RTCM 3 messages output by a u-blox base, by UBX id, with their rate in navigation solutions
+	RTCMOutput map[int]int
+	// Messages that configure a base surveying for at least surveyTime seconds until it is within
+	// accuracy meters, for receivers that aren't configured with UBX
+	BasePackets func(accuracy float64, surveyTime int) [][]byte
+	// A message the receiver answers without changing its configuration, for self tests of
+	// receivers that aren't configured with UBX
+	ProbePacket []byte
+	// The replies of the receiver accepting and rejecting the probe
+	ProbeAck []byte
+	ProbeNak []byte
+
+	// Binary messages and bodies of sentences, see NMEASentence, a rover sends when it sets up the
+	// receiver. If set, they replace the PMTK configuration of receivers on i2c.
+	InitPackets   [][]byte
+	InitSentences []string
+	// The output the init messages set, checked once the receiver is set up, see RateCheck
+	InitRates ReceiverRates
+	// Binary messages and bodies of sentences that save the configuration of the receiver to its
+	// non-volatile memory: flash or battery backed RAM
+	SavePackets   [][]byte
+	SaveSentences []string
+	// Baud rate of the receiver uarts when none is configured, 0 for the model default
+	BaudRate int
+}
+
+var receiverProfiles = map[string]ReceiverProfile{
+	ReceiverZEDF9P: {
+		Name: ReceiverZEDF9P,
+		UBX:  true,
+		Base: true,
+		RTCMOutput: map[int]int{
+			UBXRTCM1005: 1,
+			UBXRTCM1074: 1,
+			UBXRTCM1084: 1,
+			UBXRTCM1094: 1,
+			UBXRTCM1124: 1,
+			UBXRTCM1230: 5,
+		},
+		RTK:         true,
+		RawOutput:   true,
+		SavePackets: [][]byte{UBXSaveConfigPacket()},
+	},
+	// the F9R is a rover only: its sensor fusion needs it to move. NAV-ATT is enabled on every
+	// navigation solution for its orientation.
+	ReceiverZEDF9R: {
+		Name:        ReceiverZEDF9R,
+		UBX:         true,
+		RTK:         true,
+		RawOutput:   true,
+		Attitude:    true,
+		InitPackets: [][]byte{UBXMessageRatePacket(UBXClassNav, UBXNavAtt, 1)},
+		SavePackets: [][]byte{UBXSaveConfigPacket()},
+	},
+	// the M8P only sends MSM7 for GPS and GLONASS on every firmware
+	ReceiverNEOM8P: {
+		Name: ReceiverNEOM8P,
+		UBX:  true,
+		Base: true,
+		RTCMOutput: map[int]int{
+			UBXRTCM1005: 1,
+			UBXRTCM1077: 1,
+			UBXRTCM1087: 1,
+			UBXRTCM1230: 5,
+		},
+		RTK:         true,
+		SavePackets: [][]byte{UBXSaveConfigPacket()},
+	},
+	// the M8T is a timing receiver: no rtk, but raw measurements for post processing
+	ReceiverNEOM8T: {
+		Name:        ReceiverNEOM8T,
+		UBX:         true,
+		RawOutput:   true,
+		SavePackets: [][]byte{UBXSaveConfigPacket()},
+	},
+	// Quectel modules read rtcm MSM corrections on the uart they send nmea on, at 460800 baud out of
+	// the box. They only fix with a base position (1005 or 1006) in the stream, which every station
+	// profile sends. They have no UBX, so they can't be configured as a station or record for ppk.
+	ReceiverLC29H: {
+		Name: ReceiverLC29H,
+		RTK:  true,
+		InitSentences: []string{
+			"PAIR062,0,1", // GGA
+			"PAIR062,2,1", // GSA
+			"PAIR062,3,1", // GSV
+			"PAIR062,4,1", // RMC
+			"PAIR062,5,1", // VTG
+			"PQTMCFGMSGRATE,W,PQTMEPE,1,2",
+			"PQTMSAVEPAR",
+		},
+		InitRates: ReceiverRates{Messages: map[string]float64{
+			"GGA": 1, "GSA": 1, "GSV": 1, "RMC": 1, "VTG": 1, "PQTMEPE": 0.5,
+		}},
+		SaveSentences: []string{"PQTMSAVEPAR"},
+		BaudRate:      460800,
+	},
+	ReceiverLG69T: {
+		Name: ReceiverLG69T,
+		RTK:  true,
+		InitSentences: []string{
+			"PQTMCFGRCVRMODE,W,1", // rover
+			"PQTMCFGMSGRATE,W,PQTMEPE,1,2",
+			"PQTMSAVEPAR",
+		},
+		InitRates:     ReceiverRates{Messages: map[string]float64{"PQTMEPE": 0.5}},
+		SaveSentences: []string{"PQTMSAVEPAR"},
+		BaudRate:      460800,
+	},
+	// the PX1122R outputs rtcm MSM and the 1005 base position on its nmea uart in base mode, and
+	// reads corrections on the same uart as a rover
+	ReceiverPX1122R: {
+		Name:        ReceiverPX1122R,
+		Base:        true,
+		RTK:         true,
+		BasePackets: SkyTraqBasePackets,
+		ProbePacket: SkyTraqQueryVersionPacket(),
+		ProbeAck:    SkyTraqPacket(skyTraqAck, skyTraqQuerySoftwareVersion),
+		ProbeNak:    SkyTraqPacket(skyTraqNack, skyTraqQuerySoftwareVersion),
+		InitPackets: SkyTraqRoverPackets(),
+		// its configuration messages are saved to flash as they are applied, sending them again saves it
+		SavePackets: SkyTraqRoverPackets(),
+		BaudRate:    115200,
+	},
+	// the UM980 reads rtcm corrections on the uart it sends nmea on, and can apply the ppp corrections
+	// of the satellites instead, without a base. It has no UBX, and isn't set up as a station.
+	ReceiverUM980: {
+		Name:        ReceiverUM980,
+		RTK:         true,
+		PPP:         true,
+		InitPackets: UnicoreRoverPackets(),
+		InitRates: ReceiverRates{
+			Messages:  map[string]float64{"GGA": 1, "GSA": 1, "GSV": 1, "RMC": 1, "VTG": 1},
+			EpochRate: 1,
+		},
+		SavePackets: [][]byte{UnicoreCommand("SAVECONFIG")},
+		BaudRate:    115200,
+	},
+}
+
+// Receiver returns the profile of the named receiver, the ZED-F9P if name is empty.
+func Receiver(name string) (ReceiverProfile, error) {
+	if name == "" {
+		name = ReceiverZEDF9P
+	}
+	profile, ok := receiverProfiles[name]
+	if !ok {
+		return ReceiverProfile{}, unsupportedReceiver("receiver", name)
+	}
+	return profile, nil
+}
+
+// ConfiguredRates returns the output a rover sets the receiver up to send: that of its init
+// messages, and the solution status and attitude of u-blox receivers on every navigation solution.
+func (p ReceiverProfile) ConfiguredRates() ReceiverRates {
+	rates := ReceiverRates{Messages: map[string]float64{}, EpochRate: p.InitRates.EpochRate}
+	for typ, rate := range p.InitRates.Messages {
+		rates.Messages[typ] = rate
+	}
+	if p.UBX {
+		rates.Messages[UBXNavPVTMessage] = 1
+	}
+	if p.Attitude {
+		rates.Messages[UBXNavAttMessage] = 1
+	}
+	return rates
+}
+
+// SaveConfig writes the messages saving the configuration of the receiver to its non-volatile memory
+// with write, stopping at the first that fails.
+func (p ReceiverProfile) SaveConfig(write func([]byte) error) error {
+	for _, packet := range p.SavePackets {
+		if err := write(packet); err != nil {
+			return err
+		}
+	}
+	for _, body := range p.SaveSentences {
+		sentence, err := NMEASentence(body)
+		if err != nil {
+			return err
+		}
+		if err := write(sentence); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ValidateBaseReceiver checks that the named receiver is supported and can output corrections and,
+// if ephemerides is set, the GPS subframes a BaseMonitor solves positions with.
+func ValidateBaseReceiver(field, name string, ephemerides bool) error {
+	profile, err := Receiver(name)
+	if err != nil {
+		return unsupportedReceiver(field, name)
+	}
+	if !profile.Base {
+		return fmt.Errorf("%s %q can't output rtcm corrections for a station", field, name)
+	}
+	if ephemerides && !profile.RawOutput {
+		return fmt.Errorf("%s %q doesn't output the ephemerides base_move_threshold_m needs", field, name)
+	}
+	return nil
+}
+
+// ValidateRoverReceiver checks that the named receiver is supported and, if ppk is set, outputs the
+// raw measurements it records.
+func ValidateRoverReceiver(field, name string, ppk bool) error {
+	profile, err := Receiver(name)
+	if err != nil {
+		return unsupportedReceiver(field, name)
+	}
+	if ppk && !profile.RawOutput {
+		return fmt.Errorf("%s %q doesn't output the raw measurements ppk_record_dir needs", field, name)
+	}
+	return nil
+}
+
+func unsupportedReceiver(field, name string) error {
+	return fmt.Errorf("%s %q isn't supported, use %s, %s, %s, %s, %s, %s, %s or %s", field, name,
+		ReceiverZEDF9P, ReceiverZEDF9R, ReceiverNEOM8P, ReceiverNEOM8T, ReceiverLC29H, ReceiverLG69T, ReceiverPX1122R, ReceiverUM980)
+}
@@ -0,0 +1,99 @@
+package rtkutils
+
+import (
+	"errors"
+	"testing"
+
+	"go.viam.com/test"
+)
+
+func TestReceiver(t *testing.T) {
+	profile, err := Receiver("")
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, profile.Name, test.ShouldEqual, ReceiverZEDF9P)
+
+	profile, err = Receiver(ReceiverNEOM8P)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, profile.RTK, test.ShouldBeTrue)
+	test.That(t, profile.RTCMOutput[UBXRTCM1077], test.ShouldEqual, 1)
+	_, msm4 := profile.RTCMOutput[UBXRTCM1074]
+	test.That(t, msm4, test.ShouldBeFalse)
+
+	// quectel receivers are set up with their own sentences, which all frame
+	profile, err = Receiver(ReceiverLC29H)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, profile.InitSentences, test.ShouldNotBeEmpty)
+	for _, body := range profile.InitSentences {
+		_, err := NMEASentence(body)
+		test.That(t, err, test.ShouldBeNil)
+	}
+
+	_, err = Receiver("neo-6m")
+	test.That(t, err, test.ShouldBeError, errors.New("receiver \"neo-6m\" isn't supported, use zed-f9p, zed-f9r, neo-m8p, neo-m8t, lc29h, lg69t, px1122r or um980"))
+}
+
+func TestValidateReceiver(t *testing.T) {
+	test.That(t, ValidateBaseReceiver("receiver", "", false), test.ShouldBeNil)
+	test.That(t, ValidateBaseReceiver("receiver", ReceiverNEOM8P, false), test.ShouldBeNil)
+	test.That(t, ValidateBaseReceiver("receiver", ReceiverPX1122R, false), test.ShouldBeNil)
+	test.That(t, ValidateBaseReceiver("receiver", ReceiverNEOM8T, false), test.ShouldBeError,
+		errors.New("receiver \"neo-m8t\" can't output rtcm corrections for a station"))
+	test.That(t, ValidateBaseReceiver("receiver", "neo-6m", false), test.ShouldBeError,
+		errors.New("receiver \"neo-6m\" isn't supported, use zed-f9p, zed-f9r, neo-m8p, neo-m8t, lc29h, lg69t, px1122r or um980"))
+
+	test.That(t, ValidateRoverReceiver("receiver", ReceiverNEOM8T, true), test.ShouldBeNil)
+	test.That(t, ValidateRoverReceiver("receiver", ReceiverNEOM8P, false), test.ShouldBeNil)
+	test.That(t, ValidateRoverReceiver("receiver", ReceiverLG69T, false), test.ShouldBeNil)
+	test.That(t, ValidateBaseReceiver("receiver", ReceiverLC29H, false), test.ShouldBeError,
+		errors.New("receiver \"lc29h\" can't output rtcm corrections for a station"))
+	test.That(t, ValidateBaseReceiver("receiver", ReceiverZEDF9P, true), test.ShouldBeNil)
+	test.That(t, ValidateBaseReceiver("receiver", ReceiverPX1122R, true), test.ShouldBeError,
+		errors.New("receiver \"px1122r\" doesn't output the ephemerides base_move_threshold_m needs"))
+	test.That(t, ValidateRoverReceiver("receiver", ReceiverNEOM8P, true), test.ShouldBeError,
+		errors.New("receiver \"neo-m8p\" doesn't output the raw measurements ppk_record_dir needs"))
+}
+
+func TestSaveConfig(t *testing.T) {
+	// every receiver can save its configuration
+	for name := range receiverProfiles {
+		profile, err := Receiver(name)
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, len(profile.SavePackets)+len(profile.SaveSentences), test.ShouldBeGreaterThan, 0)
+	}
+
+	var written [][]byte
+	write := func(msg []byte) error {
+		written = append(written, msg)
+		return nil
+	}
+	profile, err := Receiver(ReceiverZEDF9P)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, profile.SaveConfig(write), test.ShouldBeNil)
+	test.That(t, written, test.ShouldResemble, [][]byte{UBXSaveConfigPacket()})
+
+	written = nil
+	profile, err = Receiver(ReceiverLC29H)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, profile.SaveConfig(write), test.ShouldBeNil)
+	test.That(t, written, test.ShouldResemble, [][]byte{[]byte("$PQTMSAVEPAR*5A\r\n")})
+
+	failed := errors.New("write failed")
+	test.That(t, profile.SaveConfig(func([]byte) error { return failed }), test.ShouldEqual, failed)
+}
+
+func TestConfiguredRates(t *testing.T) {
+	profile, err := Receiver(ReceiverZEDF9R)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, profile.ConfiguredRates(), test.ShouldResemble, ReceiverRates{
+		Messages: map[string]float64{UBXNavPVTMessage: 1, UBXNavAttMessage: 1},
+	})
+
+	profile, err = Receiver(ReceiverUM980)
+	test.That(t, err, test.ShouldBeNil)
+	rates := profile.ConfiguredRates()
+	test.That(t, rates.EpochRate, test.ShouldEqual, 1)
+	test.That(t, rates.Messages["GGA"], test.ShouldEqual, 1)
+	// the profile's own rates are copied
+	rates.Messages["GGA"] = 2
+	test.That(t, profile.InitRates.Messages["GGA"], test.ShouldEqual, 1)
+}
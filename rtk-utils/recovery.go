@@ -0,0 +1,205 @@
+package rtkutils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"sync"
+	"time"
+
+	"github.com/edaniels/golog"
+)
+
+// Operations an error can happen in. Together with its category they classify an error.
+const (
+	OpOpen      = "open"
+	OpRead      = "read"
+	OpWrite     = "write"
+	OpClose     = "close"
+	OpConfigure = "configure"
+)
+
+// ErrorClass is where an error happened, e.g. {ErrorSerial, OpRead}. Its errors are returned on
+// Path, the empty one if unset.
+type ErrorClass struct {
+	Category string
+	Op       string
+	Path     string
+}
+
+func (c ErrorClass) String() string {
+	return c.Category + " " + c.Op
+}
+
+// RecoveryAction is what a component does about an error.
+type RecoveryAction int
+
+const (
+	// Retry the operation after a backoff.
+	Retry RecoveryAction = iota
+	// Reopen closes the port or bus and opens it again after a backoff.
+	Reopen
+	// Surface returns the error from the component's API and carries on.
+	Surface
+	// Rebuild stops the failing worker. The error is returned from every API call until the
+	// component is rebuilt by reconfiguring it.
+	Rebuild
+)
+
+func (a RecoveryAction) String() string {
+	switch a {
+	case Retry:
+		return "retry"
+	case Reopen:
+		return "reopen"
+	case Surface:
+		return "surface"
+	case Rebuild:
+		return "rebuild"
+	default:
+		return fmt.Sprintf("RecoveryAction(%d)", int(a))
+	}
+}
+
+const (
+	defaultMaxBackoff = 5 * time.Second
+	minBackoff        = 100 * time.Millisecond
+)
+
+// ErrRebuildRequired is returned by a component that stopped after a fatal error.
+var ErrRebuildRequired = errors.New("stopped after a fatal error, reconfigure the component to restart it")
+
+// RecoveryPolicy is the table of actions a model takes for each class of error. Transient errors
+// are handled by the action of their class, or retried if the class is missing from Actions.
+// Fatal errors always lead to a rebuild.
+type RecoveryPolicy struct {
+	Actions map[ErrorClass]RecoveryAction
+	// consecutive failures of a class after which its errors are fatal, or 0 to never give up
+	MaxFailures int
+	// longest wait before a retry or a reopen, 5s if zero
+	MaxBackoff time.Duration
+	// classes of the sources a component keeps running without, whose errors are never fatal
+	Optional map[ErrorClass]bool
+}
+
+// WithOptional returns a copy of the policy that keeps handling the errors of classes with their
+// action for as long as they last, e.g. for the corrections of a rover that may run without them.
+func (p RecoveryPolicy) WithOptional(classes ...ErrorClass) RecoveryPolicy {
+	optional := map[ErrorClass]bool{}
+	for class := range p.Optional {
+		optional[class] = true
+	}
+	for _, class := range classes {
+		optional[class] = true
+	}
+	p.Optional = optional
+	return p
+}
+
+// Action returns the action for the n-th consecutive failure of class with err. A device that
+// isn't there is waited for as long as it takes, e.g. a receiver powered on after the module.
+func (p RecoveryPolicy) Action(class ErrorClass, err error, n int) RecoveryAction {
+	giveUp := IsFatal(err) || (p.MaxFailures > 0 && n >= p.MaxFailures && !IsDeviceAbsent(err))
+	if giveUp && !p.Optional[class] {
+		return Rebuild
+	}
+	if action, ok := p.Actions[class]; ok {
+		return action
+	}
+	return Retry
+}
+
+// IsFatal reports whether err can't go away without changing the config or the machine, like a
+// port the module isn't allowed to open.
+func IsFatal(err error) bool {
+	return errors.Is(err, fs.ErrPermission) || errors.Is(err, ErrRebuildRequired)
+}
+
+// Recovery applies the policy of a component to the errors of its background workers, recording
+// them in the component's error history.
+type Recovery struct {
+	policy RecoveryPolicy
+	errs   *ErrorHistory
+	logger golog.Logger
+
+	mu       sync.Mutex
+	failures map[ErrorClass]int  // consecutive failures by class
+	absent   map[ErrorClass]bool // classes whose last failure was a device that isn't there
+}
+
+// NewRecovery returns a Recovery applying policy.
+func NewRecovery(policy RecoveryPolicy, errs *ErrorHistory, logger golog.Logger) *Recovery {
+	if policy.MaxBackoff <= 0 {
+		policy.MaxBackoff = defaultMaxBackoff
+	}
+	return &Recovery{policy: policy, errs: errs, logger: logger, failures: map[ErrorClass]int{}, absent: map[ErrorClass]bool{}}
+}
+
+// Handle records a failure of class and returns the action the worker must take. Retry and
+// Reopen first wait a backoff that doubles with each consecutive failure of the class, or until
+// ctx is done.
+func (r *Recovery) Handle(ctx context.Context, class ErrorClass, err error) RecoveryAction {
+	r.mu.Lock()
+	r.failures[class]++
+	n := r.failures[class]
+	r.absent[class] = IsDeviceAbsent(err)
+	r.mu.Unlock()
+
+	action := r.policy.Action(class, err, n)
+	switch action {
+	case Rebuild:
+		if !errors.Is(err, ErrRebuildRequired) {
+			err = fmt.Errorf("%s: %w: %v", class, ErrRebuildRequired, err)
+		}
+		r.logger.Errorf("%s failed %d times: %s", class, n, err)
+		r.errs.SetFatalPath(class.Path, class.Category, err)
+		return action
+	case Surface:
+		r.errs.SetPath(class.Path, class.Category, err)
+	case Retry, Reopen:
+		r.errs.Record(class.Category, err)
+	}
+	// only the first of consecutive failures is logged as a warning
+	if n == 1 {
+		r.logger.Warnf("%s failed, will %s: %s", class, action, err)
+	} else {
+		r.logger.Debugf("%s failed %d times, will %s: %s", class, n, action, err)
+	}
+
+	if action == Retry || action == Reopen {
+		backoff := r.policy.MaxBackoff
+		if n < 16 && minBackoff<<(n-1) < backoff {
+			backoff = minBackoff << (n - 1)
+		}
+		select {
+		case <-ctx.Done():
+		case <-time.After(backoff):
+		}
+	}
+	return action
+}
+
+// Succeeded resets the consecutive failures of class after the operation worked.
+func (r *Recovery) Succeeded(class ErrorClass) {
+	r.mu.Lock()
+	n := r.failures[class]
+	delete(r.failures, class)
+	delete(r.absent, class)
+	r.mu.Unlock()
+	if n > 0 {
+		r.logger.Infof("%s recovered after %d failures", class, n)
+	}
+}
+
+// WaitingForDevice reports whether an operation keeps failing because its device isn't there.
+func (r *Recovery) WaitingForDevice() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, absent := range r.absent {
+		if absent {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,90 @@
+package rtkutils
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"testing"
+	"time"
+
+	"github.com/edaniels/golog"
+	"go.viam.com/test"
+)
+
+func TestRecoveryPolicy(t *testing.T) {
+	read := ErrorClass{Category: ErrorSerial, Op: OpRead}
+	open := ErrorClass{Category: ErrorSerial, Op: OpOpen}
+	policy := RecoveryPolicy{Actions: map[ErrorClass]RecoveryAction{read: Reopen}, MaxFailures: 3}
+	timeout := errors.New("timeout")
+
+	test.That(t, policy.Action(read, timeout, 1), test.ShouldEqual, Reopen)
+	test.That(t, policy.Action(open, timeout, 1), test.ShouldEqual, Retry)
+	test.That(t, policy.Action(read, timeout, 3), test.ShouldEqual, Rebuild)
+	test.That(t, policy.Action(open, &fs.PathError{Op: "open", Err: fs.ErrPermission}, 1), test.ShouldEqual, Rebuild)
+
+	policy.MaxFailures = 0
+	test.That(t, policy.Action(read, timeout, 1000), test.ShouldEqual, Reopen)
+
+	// a device that isn't there is waited for
+	policy.MaxFailures = 3
+	test.That(t, policy.Action(open, &fs.PathError{Op: "open", Err: fs.ErrNotExist}, 1000), test.ShouldEqual, Retry)
+
+	// optional sources are never given up on
+	policy.MaxFailures = 3
+	optional := policy.WithOptional(open)
+	test.That(t, optional.Action(open, timeout, 1000), test.ShouldEqual, Retry)
+	test.That(t, optional.Action(open, &fs.PathError{Op: "open", Err: fs.ErrPermission}, 1), test.ShouldEqual, Retry)
+	test.That(t, optional.Action(read, timeout, 3), test.ShouldEqual, Rebuild)
+	test.That(t, policy.Optional, test.ShouldBeNil)
+}
+
+func TestRecovery(t *testing.T) {
+	read := ErrorClass{Category: ErrorI2C, Op: OpRead}
+	closing := ErrorClass{Category: ErrorI2C, Op: OpClose}
+	errs := NewErrorHistory()
+	r := NewRecovery(RecoveryPolicy{
+		Actions:     map[ErrorClass]RecoveryAction{closing: Surface},
+		MaxFailures: 3,
+		MaxBackoff:  time.Millisecond,
+	}, errs, golog.NewTestLogger(t))
+	ctx := context.Background()
+	busy := errors.New("bus busy")
+
+	// retried errors are only recorded
+	test.That(t, r.Handle(ctx, read, busy), test.ShouldEqual, Retry)
+	test.That(t, errs.Get(), test.ShouldBeNil)
+	test.That(t, r.Handle(ctx, read, busy), test.ShouldEqual, Retry)
+	r.Succeeded(read)
+	test.That(t, r.Handle(ctx, read, busy), test.ShouldEqual, Retry)
+	test.That(t, errs.Errors(ErrorI2C)[0].Count, test.ShouldEqual, 3)
+
+	test.That(t, r.Handle(ctx, closing, busy), test.ShouldEqual, Surface)
+	test.That(t, errs.Get(), test.ShouldEqual, busy)
+	test.That(t, errs.Get(), test.ShouldBeNil)
+
+	// the third consecutive failure since the last success is fatal
+	test.That(t, r.Handle(ctx, read, busy), test.ShouldEqual, Retry)
+	test.That(t, r.Handle(ctx, read, busy), test.ShouldEqual, Rebuild)
+	err := errs.Get()
+	test.That(t, errors.Is(err, ErrRebuildRequired), test.ShouldBeTrue)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "bus busy")
+	test.That(t, errs.Get(), test.ShouldEqual, err)
+
+	// the errors of a class are returned on its path only
+	write := ErrorClass{Category: ErrorSerial, Op: OpWrite, Path: PathCorrectionWrite}
+	errs = NewErrorHistory()
+	r = NewRecovery(RecoveryPolicy{Actions: map[ErrorClass]RecoveryAction{write: Surface}, MaxFailures: 2}, errs, golog.NewTestLogger(t))
+	test.That(t, r.Handle(ctx, write, busy), test.ShouldEqual, Surface)
+	test.That(t, errs.Get(PathNMEARead), test.ShouldBeNil)
+	test.That(t, r.Handle(ctx, write, busy), test.ShouldEqual, Rebuild)
+	test.That(t, errs.Get(PathNMEARead), test.ShouldBeNil)
+	test.That(t, errors.Is(errs.Get(PathCorrectionWrite), ErrRebuildRequired), test.ShouldBeTrue)
+
+	// the backoff ends early once ctx is done
+	cancelCtx, cancel := context.WithCancel(ctx)
+	cancel()
+	r = NewRecovery(RecoveryPolicy{MaxBackoff: time.Hour}, NewErrorHistory(), golog.NewTestLogger(t))
+	for i := 0; i < 20; i++ {
+		test.That(t, r.Handle(cancelCtx, read, busy), test.ShouldEqual, Retry)
+	}
+}
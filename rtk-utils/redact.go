@@ -0,0 +1,114 @@
+package rtkutils
+
+import (
+	"encoding/json"
+	"net/url"
+	"strings"
+)
+
+// EffectiveConfigCommand returns the config a component runs with, with the defaults it fills in and
+// its credentials redacted, for debugging a config without exposing the secrets in it.
+const EffectiveConfigCommand = "get_config_effective"
+
+// Redacted replaces secrets in logs, errors and configs.
+const Redacted = "REDACTED"
+
+// secretQueryKeys are the parts of URL query parameter names that hold credentials, e.g. ?token=.
+var secretQueryKeys = []string{"token", "password", "passwd", "secret", "key", "auth"}
+
+// IsSecretKey reports whether the config attribute key holds a credential, such as ntrip_password or
+// auth_key. Paths to key files aren't secret.
+func IsSecretKey(key string) bool {
+	key = strings.ToLower(key)
+	return strings.HasSuffix(key, "password") || strings.HasSuffix(key, "_key") || key == "key" ||
+		strings.HasSuffix(key, "token") || strings.HasSuffix(key, "secret")
+}
+
+// RedactURL returns raw with the password of its user info and the values of query parameters that
+// look like credentials redacted, so that it can be logged. The username is kept to tell accounts
+// apart, unless raw can't be parsed as a URL.
+func RedactURL(raw string) string {
+	// a URL without a scheme, e.g. user:pass@caster:2101, only parses with its host as one
+	noScheme := !strings.Contains(raw, "://")
+	toParse := raw
+	if noScheme {
+		toParse = "//" + raw
+	}
+	u, err := url.Parse(toParse)
+	if err != nil || u.Host == "" {
+		// whatever precedes the host may be credentials
+		if at := strings.LastIndex(raw, "@"); at != -1 {
+			return Redacted + raw[at:]
+		}
+		return raw
+	}
+	redacted := false
+	if _, ok := u.User.Password(); ok {
+		u.User = url.UserPassword(u.User.Username(), Redacted)
+		redacted = true
+	}
+	query := u.Query()
+	for name := range query {
+		if secretQueryKey(name) {
+			query.Set(name, Redacted)
+			redacted = true
+		}
+	}
+	if !redacted {
+		return raw
+	}
+	u.RawQuery = query.Encode()
+	if noScheme {
+		return strings.TrimPrefix(u.String(), "//")
+	}
+	return u.String()
+}
+
+func secretQueryKey(name string) bool {
+	name = strings.ToLower(name)
+	for _, part := range secretQueryKeys {
+		if strings.Contains(name, part) {
+			return true
+		}
+	}
+	return false
+}
+
+// RedactConfig returns the attributes of conf, a config struct or map, as a map with the values of
+// secret keys replaced by Redacted and the credentials in URLs redacted. Unset secrets stay unset so
+// that it is clear which are.
+func RedactConfig(conf interface{}) (map[string]interface{}, error) {
+	encoded, err := json.Marshal(conf)
+	if err != nil {
+		return nil, err
+	}
+	var attrs map[string]interface{}
+	if err := json.Unmarshal(encoded, &attrs); err != nil {
+		return nil, err
+	}
+	redactAttributes(attrs)
+	return attrs, nil
+}
+
+func redactAttributes(attrs map[string]interface{}) {
+	for key, value := range attrs {
+		switch v := value.(type) {
+		case string:
+			switch {
+			case v == "":
+			case IsSecretKey(key):
+				attrs[key] = Redacted
+			case strings.HasSuffix(strings.ToLower(key), "url"):
+				attrs[key] = RedactURL(v)
+			}
+		case map[string]interface{}:
+			redactAttributes(v)
+		case []interface{}:
+			for _, item := range v {
+				if nested, ok := item.(map[string]interface{}); ok {
+					redactAttributes(nested)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package rtkutils
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// EventReferenceStationChanged is raised when the reference station of the corrections changes.
+const EventReferenceStationChanged = "reference_station_changed"
+
+// ggaStationID returns the DGPS reference station ID of a GGA sentence, empty if the fix uses no
+// corrections, and false if line isn't a GGA sentence.
+func ggaStationID(line string) (string, bool) {
+	ind := strings.Index(line, "$G")
+	if ind == -1 {
+		return "", false
+	}
+	line = line[ind:]
+	if star := strings.IndexByte(line, '*'); star != -1 {
+		line = line[:star]
+	}
+	// $GPGGA,<time>,<lat>,N,<lng>,E,<quality>,<sats>,<hdop>,<alt>,M,<sep>,M,<age>,<station id>
+	fields := strings.Split(strings.TrimSpace(line), ",")
+	if len(fields[0]) < 6 || fields[0][3:] != "GGA" {
+		return "", false
+	}
+	if len(fields) < 15 {
+		return "", true
+	}
+	id := strings.TrimSpace(fields[14])
+	// receivers pad the ID with zeros, or not
+	if n, err := strconv.Atoi(id); err == nil {
+		id = strconv.Itoa(n)
+	}
+	return id, true
+}
+
+// ReferenceStationTracker follows the reference station ID of a rover's fixes and raises an event when
+// it changes, which shows the rover switched bases or a VRS network handed it over to another
+// station, a common cause of a sudden change of accuracy. Epochs without corrections don't count as a
+// change, so a rover that loses its corrections and gets them back from the same station raises none.
+type ReferenceStationTracker struct {
+	mu      sync.Mutex
+	current string // last station, empty until the first fix with corrections
+	changes uint64
+}
+
+// Update takes the station ID of an epoch, adding an event to events if it changed.
+func (t *ReferenceStationTracker) Update(id string, events *EventLog) {
+	if id == "" {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if id == t.current {
+		return
+	}
+	if t.current != "" {
+		t.changes++
+		events.Add(EventReferenceStationChanged, "reference station changed from %s to %s", t.current, id)
+	}
+	t.current = id
+}
+
+// Station returns the last reference station, empty if none was seen, and how many times it changed.
+func (t *ReferenceStationTracker) Station() (string, uint64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.current, t.changes
+}
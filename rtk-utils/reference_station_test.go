@@ -0,0 +1,45 @@
+package rtkutils
+
+import (
+	"testing"
+
+	"github.com/edaniels/golog"
+	"go.viam.com/test"
+)
+
+func TestGGAStationID(t *testing.T) {
+	id, ok := ggaStationID(testGGAEpoch1)
+	test.That(t, ok, test.ShouldBeTrue)
+	test.That(t, id, test.ShouldEqual, "31")
+
+	// a fix without corrections has no station
+	id, ok = ggaStationID("$GNGGA,172814.00,3723.46587704,N,12202.26957864,W,1,12,0.8,18.893,M,-25.669,M,,*5B")
+	test.That(t, ok, test.ShouldBeTrue)
+	test.That(t, id, test.ShouldEqual, "")
+
+	_, ok = ggaStationID(testGSAEpoch1)
+	test.That(t, ok, test.ShouldBeFalse)
+}
+
+func TestReferenceStationTracker(t *testing.T) {
+	events := NewEventLog(golog.NewTestLogger(t))
+	var tracker ReferenceStationTracker
+	tracker.Update("", events)
+	tracker.Update("31", events)
+	tracker.Update("31", events)
+	id, changes := tracker.Station()
+	test.That(t, id, test.ShouldEqual, "31")
+	test.That(t, changes, test.ShouldEqual, uint64(0))
+	test.That(t, events.Events(""), test.ShouldBeEmpty)
+
+	// losing the corrections isn't a change, getting them from another station is
+	tracker.Update("", events)
+	tracker.Update("31", events)
+	tracker.Update("", events)
+	tracker.Update("1012", events)
+	id, changes = tracker.Station()
+	test.That(t, id, test.ShouldEqual, "1012")
+	test.That(t, changes, test.ShouldEqual, uint64(1))
+	test.That(t, events.Events(EventReferenceStationChanged), test.ShouldHaveLength, 1)
+	test.That(t, events.Events("")[0].Message, test.ShouldEqual, "reference station changed from 31 to 1012")
+}
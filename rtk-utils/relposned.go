@@ -0,0 +1,107 @@
+package rtkutils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+// UBX relative position of a moving base, output by the rover receiver of a heading pair.
+const (
+	UBXClassNav     = 0x01
+	UBXNavRelPosNED = 0x3C
+)
+
+const (
+	ubxMaxPayload       = 1024
+	relPosNEDPayloadLen = 64 // version 1, sent by F9 receivers
+
+	relPosFlagCarrSolnShift    = 3
+	relPosFlagCarrSolnMask     = 0x03 << relPosFlagCarrSolnShift
+	relPosFlagRelPosHeadingVld = 1 << 8
+)
+
+// Carrier phase solutions of a relative position.
+const (
+	CarrierSolutionNone  = 0
+	CarrierSolutionFloat = 1
+	CarrierSolutionFixed = 2
+)
+
+// RelPosNED is the position of the rover antenna of a heading pair relative to its moving base
+// antenna, from a UBX-NAV-RELPOSNED message.
+type RelPosNED struct {
+	Heading         float64 // degrees clockwise from true north, from the base to the rover antenna
+	HeadingAccuracy float64 // degrees
+	HeadingValid    bool
+	Length          float64 // meters between the antennas
+	CarrierSolution int
+}
+
+// FixQuality returns the GGA fix quality matching the carrier solution.
+func (r RelPosNED) FixQuality() int {
+	switch r.CarrierSolution {
+	case CarrierSolutionFixed:
+		return FixQualityRTKFixed
+	case CarrierSolutionFloat:
+		return FixQualityRTKFloat
+	default:
+		return 0
+	}
+}
+
+// ParseRelPosNED parses the payload of a UBX-NAV-RELPOSNED message.
+func ParseRelPosNED(payload []byte) (RelPosNED, error) {
+	if len(payload) != relPosNEDPayloadLen {
+		return RelPosNED{}, fmt.Errorf("relposned payload is %d bytes, only the %d byte version of F9 receivers is supported",
+			len(payload), relPosNEDPayloadLen)
+	}
+	// lengths are in cm with a 0.1mm high precision part, headings and their accuracy in 1e-5 degrees
+	length := float64(int32(binary.LittleEndian.Uint32(payload[20:])))/100 + float64(int8(payload[35]))/10000
+	flags := binary.LittleEndian.Uint32(payload[60:])
+	return RelPosNED{
+		Heading:         NormalizeHeading(float64(int32(binary.LittleEndian.Uint32(payload[24:]))) * 1e-5),
+		HeadingAccuracy: float64(binary.LittleEndian.Uint32(payload[52:])) * 1e-5,
+		HeadingValid:    flags&relPosFlagRelPosHeadingVld != 0,
+		Length:          length,
+		CarrierSolution: int(flags&relPosFlagCarrSolnMask) >> relPosFlagCarrSolnShift,
+	}, nil
+}
+
+// SplitUBXFrames returns the complete UBX frames in buf with a valid checksum, skipping everything
+// else such as nmea sentences. rest is the tail of buf that may hold the start of a frame cut off
+// by the end of the read.
+func SplitUBXFrames(buf []byte) (frames [][]byte, rest []byte) {
+	partial := len(buf)
+	for i := 0; i < len(buf); {
+		if buf[i] != ubxSync1 || (i+1 < len(buf) && buf[i+1] != ubxSync2) {
+			i++
+			continue
+		}
+		end := len(buf) + 1
+		if i+ubxHeaderLen <= len(buf) {
+			length := int(binary.LittleEndian.Uint16(buf[i+4:]))
+			if length > ubxMaxPayload {
+				i++
+				continue
+			}
+			end = i + ubxHeaderLen + length + 2
+		}
+		if end > len(buf) {
+			if i < partial {
+				partial = i
+			}
+			i++
+			continue
+		}
+		frame := buf[i:end]
+		if !bytes.Equal(UBXPacket(UBXFrame(frame)), frame) {
+			i++
+			continue
+		}
+		frames = append(frames, frame)
+		partial = len(buf)
+		i = end
+	}
+	return frames, buf[partial:]
+}
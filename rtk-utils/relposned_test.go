@@ -0,0 +1,68 @@
+package rtkutils
+
+import (
+	"encoding/binary"
+	"errors"
+	"testing"
+
+	"go.viam.com/test"
+)
+
+func relPosNEDPayload(lengthCm int32, lengthHP int8, heading int32, accHeading uint32, flags uint32) []byte {
+	payload := make([]byte, relPosNEDPayloadLen)
+	payload[0] = 0x01 // version
+	binary.LittleEndian.PutUint32(payload[20:], uint32(lengthCm))
+	binary.LittleEndian.PutUint32(payload[24:], uint32(heading))
+	payload[35] = byte(lengthHP)
+	binary.LittleEndian.PutUint32(payload[52:], accHeading)
+	binary.LittleEndian.PutUint32(payload[60:], flags)
+	return payload
+}
+
+func TestParseRelPosNED(t *testing.T) {
+	// fixed carrier solution with a valid heading
+	rel, err := ParseRelPosNED(relPosNEDPayload(123, 45, 9012345, 25000, 0x0117))
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, rel.Heading, test.ShouldAlmostEqual, 90.12345, 1e-9)
+	test.That(t, rel.HeadingAccuracy, test.ShouldAlmostEqual, 0.25, 1e-9)
+	test.That(t, rel.HeadingValid, test.ShouldBeTrue)
+	test.That(t, rel.Length, test.ShouldAlmostEqual, 1.2345, 1e-9)
+	test.That(t, rel.CarrierSolution, test.ShouldEqual, CarrierSolutionFixed)
+	test.That(t, rel.FixQuality(), test.ShouldEqual, FixQualityRTKFixed)
+
+	// negative headings wrap, float solutions without a valid heading are reported as such
+	rel, err = ParseRelPosNED(relPosNEDPayload(100, 0, -1000000, 100000, 0x000F))
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, rel.Heading, test.ShouldAlmostEqual, 350, 1e-9)
+	test.That(t, rel.HeadingValid, test.ShouldBeFalse)
+	test.That(t, rel.CarrierSolution, test.ShouldEqual, CarrierSolutionFloat)
+	test.That(t, rel.FixQuality(), test.ShouldEqual, FixQualityRTKFloat)
+
+	_, err = ParseRelPosNED(make([]byte, 40))
+	test.That(t, err, test.ShouldBeError,
+		errors.New("relposned payload is 40 bytes, only the 64 byte version of F9 receivers is supported"))
+}
+
+func TestSplitUBXFrames(t *testing.T) {
+	frame := UBXPacket(UBXClassNav, UBXNavRelPosNED, relPosNEDPayload(100, 0, 0, 0, 0x0117))
+	corrupted := append([]byte{}, frame...)
+	corrupted[10] ^= 0x01
+
+	// an nmea sentence, a corrupted frame, a whole frame and a partial one
+	stream := append([]byte("$GNGGA,,,,,,0,,,,,,,,*56\r\n"), corrupted...)
+	stream = append(stream, frame...)
+	stream = append(stream, frame[:20]...)
+
+	frames, rest := SplitUBXFrames(stream)
+	test.That(t, frames, test.ShouldResemble, [][]byte{frame})
+	test.That(t, rest, test.ShouldResemble, frame[:20])
+
+	// the partial frame completes on the next read
+	frames, rest = SplitUBXFrames(append(append([]byte{}, rest...), frame[20:]...))
+	test.That(t, frames, test.ShouldResemble, [][]byte{frame})
+	test.That(t, rest, test.ShouldBeEmpty)
+
+	// a lone sync character at the end may start a frame
+	_, rest = SplitUBXFrames([]byte{0x00, ubxSync1})
+	test.That(t, rest, test.ShouldResemble, []byte{ubxSync1})
+}
@@ -0,0 +1,167 @@
+package rtkutils
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/edaniels/golog"
+	"go.viam.com/rdk/resource"
+)
+
+const (
+	// ReadRTCMCommand returns the rtcm frames a correction station received after the "since" cursor,
+	// so that rovers on other robots can read corrections over a remote connection.
+	ReadRTCMCommand = "read_rtcm"
+
+	// DefaultRemotePollInterval is how often rovers ask a remote station for new corrections.
+	DefaultRemotePollInterval = 200 * time.Millisecond
+
+	rtcmSinceKey = "since"
+	rtcmDataKey  = "rtcm"
+	rtcmNextKey  = "next"
+
+	// RTCMBufferSize is how many frames an RTCMBuffer keeps, enough for a few seconds of a typical msm7 stream.
+	RTCMBufferSize = 256
+)
+
+// RTCMBuffer keeps the most recent rtcm frames read by a station, numbered in the order they arrived.
+type RTCMBuffer struct {
+	mu     sync.Mutex
+	frames [][]byte // oldest to newest
+	next   uint64   // number of the next frame added
+}
+
+// Add appends complete rtcm frames to the buffer, dropping the oldest ones once it is full.
+func (b *RTCMBuffer) Add(frames ...[]byte) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, frame := range frames {
+		b.frames = append(b.frames, append([]byte(nil), frame...))
+		b.next++
+	}
+	if extra := len(b.frames) - RTCMBufferSize; extra > 0 {
+		b.frames = b.frames[extra:]
+	}
+}
+
+// Since returns the frames numbered since or later concatenated, and the cursor to read from next.
+// Frames that were already dropped are skipped, and a cursor from the future starts over from the oldest frame.
+func (b *RTCMBuffer) Since(since uint64) ([]byte, uint64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	first := b.next - uint64(len(b.frames))
+	if since < first || since > b.next {
+		since = first
+	}
+	var data []byte
+	for _, frame := range b.frames[since-first:] {
+		data = append(data, frame...)
+	}
+	return data, b.next
+}
+
+// ReadRTCM answers a ReadRTCMCommand. A request without a cursor only returns the cursor, so that
+// a rover starts with the next correction instead of a backlog of stale ones.
+func (b *RTCMBuffer) ReadRTCM(cmd map[string]interface{}) map[string]interface{} {
+	since, ok := cmd[rtcmSinceKey].(float64)
+	if !ok {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		return map[string]interface{}{rtcmDataKey: "", rtcmNextKey: float64(b.next)}
+	}
+	data, next := b.Since(uint64(since))
+	return map[string]interface{}{rtcmDataKey: base64.StdEncoding.EncodeToString(data), rtcmNextKey: float64(next)}
+}
+
+// RemoteCorrectionReader reads the rtcm stream of a correction station on another robot by polling
+// its ReadRTCMCommand. Reads block until corrections arrive or ctx is done.
+type RemoteCorrectionReader struct {
+	ctx      context.Context
+	station  resource.Resource
+	interval time.Duration
+	logger   golog.Logger
+
+	cursor    uint64
+	hasCursor bool
+	pending   []byte
+	failing   bool
+}
+
+// NewRemoteCorrectionReader returns a reader of the corrections of station, polled every interval.
+func NewRemoteCorrectionReader(
+	ctx context.Context,
+	station resource.Resource,
+	interval time.Duration,
+	logger golog.Logger,
+) *RemoteCorrectionReader {
+	if interval <= 0 {
+		interval = DefaultRemotePollInterval
+	}
+	return &RemoteCorrectionReader{ctx: ctx, station: station, interval: interval, logger: logger}
+}
+
+// Read copies the next corrections into p. Failures to reach the station are logged and retried,
+// so a flaky connection only delays corrections.
+func (r *RemoteCorrectionReader) Read(p []byte) (int, error) {
+	for len(r.pending) == 0 {
+		if err := r.poll(); err != nil {
+			if !r.failing {
+				r.logger.Warnf("can't read corrections from %s, retrying: %v", r.station.Name(), err)
+				r.failing = true
+			}
+		} else if r.failing {
+			r.logger.Infof("reading corrections from %s again", r.station.Name())
+			r.failing = false
+		}
+		if len(r.pending) > 0 {
+			break
+		}
+
+		select {
+		case <-r.ctx.Done():
+			return 0, r.ctx.Err()
+		case <-time.After(r.interval):
+		}
+	}
+	n := copy(p, r.pending)
+	r.pending = r.pending[n:]
+	return n, nil
+}
+
+func (r *RemoteCorrectionReader) poll() error {
+	cmd := map[string]interface{}{CommandKey: ReadRTCMCommand}
+	if r.hasCursor {
+		cmd[rtcmSinceKey] = float64(r.cursor)
+	}
+	resp, err := r.station.DoCommand(r.ctx, cmd)
+	if err != nil {
+		return err
+	}
+	next, ok := resp[rtcmNextKey].(float64)
+	if !ok {
+		return fmt.Errorf("%s is not a correction station, %q response has no cursor", r.station.Name(), ReadRTCMCommand)
+	}
+	encoded, _ := resp[rtcmDataKey].(string)
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return fmt.Errorf("invalid corrections from %s: %w", r.station.Name(), err)
+	}
+	r.cursor, r.hasCursor = uint64(next), true
+	r.pending = append(r.pending, data...)
+	return nil
+}
+
+// CheckRemoteStation reports whether station answers a ReadRTCMCommand.
+func CheckRemoteStation(ctx context.Context, station resource.Resource) error {
+	resp, err := station.DoCommand(ctx, map[string]interface{}{CommandKey: ReadRTCMCommand})
+	if err != nil {
+		return fmt.Errorf("can't reach correction station %s: %w", station.Name(), err)
+	}
+	if _, ok := resp[rtcmNextKey].(float64); !ok {
+		return fmt.Errorf("%s is not a correction station", station.Name())
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package rtkutils
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/edaniels/golog"
+	"go.viam.com/rdk/components/sensor"
+	"go.viam.com/rdk/resource"
+	"go.viam.com/test"
+)
+
+// fakeStation is a remote station answering its DoCommands with do.
+type fakeStation struct {
+	resource.Named
+	resource.AlwaysRebuild
+	resource.TriviallyCloseable
+	do func(ctx context.Context, cmd map[string]interface{}) (map[string]interface{}, error)
+}
+
+func (s *fakeStation) DoCommand(ctx context.Context, cmd map[string]interface{}) (map[string]interface{}, error) {
+	return s.do(ctx, cmd)
+}
+
+func TestRTCMBuffer(t *testing.T) {
+	var b RTCMBuffer
+
+	// the first request only returns the cursor
+	resp := b.ReadRTCM(map[string]interface{}{})
+	test.That(t, resp, test.ShouldResemble, map[string]interface{}{"rtcm": "", "next": 0.0})
+
+	b.Add([]byte{1, 2}, []byte{3})
+	data, next := b.Since(0)
+	test.That(t, data, test.ShouldResemble, []byte{1, 2, 3})
+	test.That(t, next, test.ShouldEqual, 2)
+
+	data, next = b.Since(1)
+	test.That(t, data, test.ShouldResemble, []byte{3})
+	test.That(t, next, test.ShouldEqual, 2)
+
+	resp = b.ReadRTCM(map[string]interface{}{"since": 2.0})
+	test.That(t, resp, test.ShouldResemble, map[string]interface{}{"rtcm": "", "next": 2.0})
+
+	// frames dropped from a full buffer are skipped
+	for i := 0; i < RTCMBufferSize; i++ {
+		b.Add([]byte{4})
+	}
+	data, next = b.Since(0)
+	test.That(t, data, test.ShouldHaveLength, RTCMBufferSize)
+	test.That(t, next, test.ShouldEqual, RTCMBufferSize+2)
+}
+
+func TestRemoteCorrectionReader(t *testing.T) {
+	var b RTCMBuffer
+	calls := 0
+	station := &fakeStation{Named: sensor.Named("station").AsNamed()}
+	station.do = func(ctx context.Context, cmd map[string]interface{}) (map[string]interface{}, error) {
+		calls++
+		switch calls {
+		case 1:
+			resp := b.ReadRTCM(cmd)
+			b.Add([]byte{1, 2, 3})
+			return resp, nil
+		case 2:
+			return nil, errors.New("connection lost")
+		default:
+			return b.ReadRTCM(cmd), nil
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r := NewRemoteCorrectionReader(ctx, station, time.Millisecond, golog.NewTestLogger(t))
+
+	buf := make([]byte, 2)
+	n, err := r.Read(buf)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, buf[:n], test.ShouldResemble, []byte{1, 2})
+	n, err = r.Read(buf)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, buf[:n], test.ShouldResemble, []byte{3})
+
+	cancel()
+	_, err = r.Read(buf)
+	test.That(t, err, test.ShouldBeError, context.Canceled)
+
+	test.That(t, CheckRemoteStation(context.Background(), station), test.ShouldBeNil)
+
+	encoded := base64.StdEncoding.EncodeToString([]byte{1})
+	station.do = func(ctx context.Context, cmd map[string]interface{}) (map[string]interface{}, error) {
+		return map[string]interface{}{"rtcm": encoded}, nil
+	}
+	test.That(t, CheckRemoteStation(context.Background(), station), test.ShouldNotBeNil)
+}
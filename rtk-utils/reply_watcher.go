@@ -0,0 +1,98 @@
+package rtkutils
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// ReplyWatcher looks for the reply of a receiver to a configuration message among the bytes a worker
+// reads from it, so that a check can tell whether the receiver accepted the message without reading
+// from a port the worker owns and taking bytes away from it.
+type ReplyWatcher struct {
+	mu      sync.Mutex
+	accept  []byte
+	reject  []byte
+	replies chan bool
+	tail    []byte // end of the bytes seen last, in case a reply is split between reads
+}
+
+// Reader returns a reader of r that shows the watcher every byte read.
+func (w *ReplyWatcher) Reader(r io.Reader) io.Reader {
+	return &watchedReader{r: r, w: w}
+}
+
+type watchedReader struct {
+	r io.Reader
+	w *ReplyWatcher
+}
+
+func (r *watchedReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.w.Observe(p[:n])
+	return n, err
+}
+
+// Observe looks for the expected reply in bytes read from the receiver.
+func (w *ReplyWatcher) Observe(p []byte) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.replies == nil || len(p) == 0 {
+		return
+	}
+
+	seen := append(w.tail, p...)
+	switch {
+	case bytes.Contains(seen, w.accept):
+		w.replies <- true
+		w.replies = nil
+	case w.reject != nil && bytes.Contains(seen, w.reject):
+		w.replies <- false
+		w.replies = nil
+	}
+
+	keep := len(w.accept)
+	if len(w.reject) > keep {
+		keep = len(w.reject)
+	}
+	if keep--; len(seen) > keep {
+		seen = seen[len(seen)-keep:]
+	}
+	w.tail = append(w.tail[:0], seen...)
+}
+
+// Await writes a message to the receiver with write and waits for its reply: accept if the receiver
+// accepted it, reject, which may be nil, if it didn't. The reply has to be read through Reader or
+// Observe within timeout.
+func (w *ReplyWatcher) Await(ctx context.Context, timeout time.Duration, accept, reject []byte, write func() error) error {
+	replies := make(chan bool, 1)
+	w.mu.Lock()
+	w.accept, w.reject, w.replies, w.tail = accept, reject, replies, nil
+	w.mu.Unlock()
+	defer func() {
+		w.mu.Lock()
+		w.replies = nil
+		w.mu.Unlock()
+	}()
+
+	if err := write(); err != nil {
+		return err
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case accepted := <-replies:
+		if !accepted {
+			return errors.New("receiver rejected the configuration")
+		}
+		return nil
+	case <-timer.C:
+		return fmt.Errorf("receiver did not acknowledge the configuration within %s", timeout)
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,45 @@
+package rtkutils
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"go.viam.com/test"
+)
+
+func TestReplyWatcher(t *testing.T) {
+	ack, nak := UBXAckPackets(ubxClassCfg, ubxCfgMsg)
+	test.That(t, ack, test.ShouldResemble, []byte{0xB5, 0x62, 0x05, 0x01, 0x02, 0x00, 0x06, 0x01, 0x0F, 0x38})
+	test.That(t, nak, test.ShouldResemble, []byte{0xB5, 0x62, 0x05, 0x00, 0x02, 0x00, 0x06, 0x01, 0x0E, 0x33})
+
+	var w ReplyWatcher
+	reply := func(p ...[]byte) func() error {
+		return func() error {
+			// the reply is read by someone else, in as many reads as it is split in
+			go func() {
+				for _, b := range p {
+					_, _ = io.ReadAll(w.Reader(bytes.NewReader(b)))
+				}
+			}()
+			return nil
+		}
+	}
+
+	// a reply split between two reads, after rtcm
+	test.That(t, w.Await(context.Background(), time.Second, ack, nak, reply([]byte{0xD3, 0x00, 0xB5, 0x62, 0x05}, ack[3:])), test.ShouldBeNil)
+	test.That(t, w.Await(context.Background(), time.Second, ack, nak, reply(nak)), test.ShouldBeError,
+		errors.New("receiver rejected the configuration"))
+	// the ack of another message
+	other, _ := UBXAckPackets(ubxClassCfg, ubxCfgPrt)
+	test.That(t, w.Await(context.Background(), 10*time.Millisecond, ack, nak, reply(other)), test.ShouldBeError,
+		errors.New("receiver did not acknowledge the configuration within 10ms"))
+	test.That(t, w.Await(context.Background(), time.Second, ack, nak, func() error { return errors.New("write failed") }),
+		test.ShouldBeError, errors.New("write failed"))
+
+	// bytes read while nothing is expected are ignored
+	w.Observe(ack)
+}
@@ -0,0 +1,117 @@
+package rtkutils
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	geo "github.com/kellydunn/golang-geo"
+	"go.viam.com/rdk/referenceframe"
+)
+
+// Frames a rover can report its positions in.
+const (
+	// the position of the antenna, as the receiver reports it
+	PositionFrameAntenna = "antenna"
+	// the position of the origin of the robot, from where the frame system places the antenna on it
+	PositionFrameRobot = "robot"
+)
+
+// how old the heading of the robot can be to place its origin from its antenna
+const robotFrameHeadingAge = 5 * time.Second
+
+// ValidatePositionFrame checks the position frame of a config.
+func ValidatePositionFrame(name string) error {
+	switch name {
+	case "", PositionFrameAntenna, PositionFrameRobot:
+		return nil
+	default:
+		return fmt.Errorf("position_frame %q isn't supported, use %s or %s", name, PositionFrameAntenna, PositionFrameRobot)
+	}
+}
+
+// RobotFrame moves the positions of a rover's antenna to the origin of the robot it is on, with the
+// frame of the rover in the frame system: its translation from its parent, the robot's base, in mm
+// with x to the right of the robot, y forward and z up as for bases, is where the antenna is, and
+// the robot is taken to be level. Placing it horizontally needs the robot's heading, without a
+// recent one positions are the antenna's horizontally. A nil RobotFrame reports the antenna's
+// positions.
+type RobotFrame struct {
+	parent             string
+	right, forward, up float64 // m from the origin of the robot to the antenna
+
+	mu          sync.Mutex
+	heading     float64
+	headingAt   time.Time
+	withHeading bool // the last position was placed with the heading
+}
+
+// NewRobotFrame returns a RobotFrame for position frame name with the frame of the rover in the
+// frame system, nil unless name is PositionFrameRobot.
+func NewRobotFrame(name string, frame *referenceframe.LinkConfig) (*RobotFrame, error) {
+	if name != PositionFrameRobot {
+		return nil, nil
+	}
+	if frame == nil {
+		return nil, errors.New("position_frame robot needs the frame of the component, with the robot's base as its parent")
+	}
+	if frame.Parent == "" || frame.Parent == referenceframe.World {
+		return nil, errors.New("position_frame robot needs the frame of the component to have the robot's base as its " +
+			"parent, the world doesn't move with the robot")
+	}
+	return &RobotFrame{
+		parent:  frame.Parent,
+		right:   frame.Translation.X / 1000,
+		forward: frame.Translation.Y / 1000,
+		up:      frame.Translation.Z / 1000,
+	}, nil
+}
+
+// Heading takes the compass heading of the robot in degrees at now. NaN, no heading, is ignored.
+func (f *RobotFrame) Heading(degrees float64, now time.Time) {
+	if f == nil || math.IsNaN(degrees) {
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.heading, f.headingAt = degrees, now
+}
+
+// ToRobot returns the position of the origin of the robot, and its altitude, with its antenna at pos
+// and alt at now.
+func (f *RobotFrame) ToRobot(pos *geo.Point, alt float64, now time.Time) (*geo.Point, float64) {
+	if f == nil || pos == nil || math.IsNaN(pos.Lat()) || math.IsNaN(pos.Lng()) {
+		return pos, alt
+	}
+	alt -= f.up
+	if f.right == 0 && f.forward == 0 {
+		return pos, alt
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.withHeading = !f.headingAt.IsZero() && now.Sub(f.headingAt) <= robotFrameHeadingAge
+	if !f.withHeading {
+		return pos, alt
+	}
+	sin, cos := math.Sincos(f.heading * math.Pi / 180)
+	north := f.forward*cos - f.right*sin
+	east := f.forward*sin + f.right*cos
+	return shiftPoint(pos, -north, -east), alt
+}
+
+// Readings returns the frame positions are reported in and, in the robot's, its parent frame and
+// whether the last position was placed with the robot's heading.
+func (f *RobotFrame) Readings() map[string]interface{} {
+	if f == nil {
+		return map[string]interface{}{"position_frame": PositionFrameAntenna}
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return map[string]interface{}{
+		"position_frame":      PositionFrameRobot,
+		"robot_frame_parent":  f.parent,
+		"robot_frame_heading": f.withHeading || f.right == 0 && f.forward == 0,
+	}
+}
@@ -0,0 +1,72 @@
+package rtkutils
+
+import (
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/golang/geo/r3"
+	geo "github.com/kellydunn/golang-geo"
+	"go.viam.com/rdk/referenceframe"
+	"go.viam.com/test"
+)
+
+func TestValidatePositionFrame(t *testing.T) {
+	test.That(t, ValidatePositionFrame(""), test.ShouldBeNil)
+	test.That(t, ValidatePositionFrame(PositionFrameRobot), test.ShouldBeNil)
+	test.That(t, ValidatePositionFrame("base_link"), test.ShouldBeError,
+		errors.New(`position_frame "base_link" isn't supported, use antenna or robot`))
+}
+
+func TestRobotFrame(t *testing.T) {
+	frame := &referenceframe.LinkConfig{ID: "rover", Parent: "base", Translation: r3.Vector{X: 0, Y: 1000, Z: 500}}
+	f, err := NewRobotFrame(PositionFrameAntenna, frame)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, f, test.ShouldBeNil)
+	pos := geo.NewPoint(40, -105)
+	got, alt := f.ToRobot(pos, 1600, time.Now())
+	test.That(t, got, test.ShouldEqual, pos)
+	test.That(t, alt, test.ShouldEqual, 1600.0)
+	test.That(t, f.Readings(), test.ShouldResemble, map[string]interface{}{"position_frame": "antenna"})
+
+	_, err = NewRobotFrame(PositionFrameRobot, nil)
+	test.That(t, err, test.ShouldBeError,
+		errors.New("position_frame robot needs the frame of the component, with the robot's base as its parent"))
+	_, err = NewRobotFrame(PositionFrameRobot, &referenceframe.LinkConfig{Parent: referenceframe.World})
+	test.That(t, err, test.ShouldBeError, errors.New("position_frame robot needs the frame of the component to have "+
+		"the robot's base as its parent, the world doesn't move with the robot"))
+
+	// the antenna is 1 m ahead of the origin of the robot and 0.5 m above it
+	f, err = NewRobotFrame(PositionFrameRobot, frame)
+	test.That(t, err, test.ShouldBeNil)
+	now := time.Now()
+	// without a heading, only the altitude is the robot's
+	got, alt = f.ToRobot(pos, 1600, now)
+	test.That(t, got, test.ShouldEqual, pos)
+	test.That(t, alt, test.ShouldAlmostEqual, 1599.5, 1e-9)
+	test.That(t, f.Readings()["robot_frame_heading"], test.ShouldBeFalse)
+
+	// heading east, the robot is 1 m west of its antenna
+	f.Heading(90, now)
+	got, _ = f.ToRobot(pos, 1600, now)
+	test.That(t, pos.GreatCircleDistance(got)*1000, test.ShouldAlmostEqual, 1, 0.001)
+	test.That(t, pos.BearingTo(got), test.ShouldAlmostEqual, -90, 0.01)
+	test.That(t, f.Readings(), test.ShouldResemble, map[string]interface{}{
+		"position_frame": "robot", "robot_frame_parent": "base", "robot_frame_heading": true,
+	})
+
+	// a heading gone stale isn't used, nor is none
+	f.Heading(math.NaN(), now.Add(time.Minute))
+	got, _ = f.ToRobot(pos, 1600, now.Add(time.Minute))
+	test.That(t, got, test.ShouldEqual, pos)
+	test.That(t, f.Readings()["robot_frame_heading"], test.ShouldBeFalse)
+
+	// an antenna to the right of the robot, heading north, is east of it
+	f, err = NewRobotFrame(PositionFrameRobot, &referenceframe.LinkConfig{Parent: "base", Translation: r3.Vector{X: 2000}})
+	test.That(t, err, test.ShouldBeNil)
+	f.Heading(0, now)
+	got, _ = f.ToRobot(pos, 1600, now)
+	test.That(t, pos.GreatCircleDistance(got)*1000, test.ShouldAlmostEqual, 2, 0.001)
+	test.That(t, pos.BearingTo(got), test.ShouldAlmostEqual, -90, 0.01)
+}
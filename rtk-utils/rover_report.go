@@ -0,0 +1,86 @@
+package rtkutils
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RoverReport is the position and fix a rover last reported to a station in a GGA sentence.
+type RoverReport struct {
+	Lat        float64
+	Lng        float64
+	Alt        float64 // m above mean sea level
+	FixQuality int
+	Satellites int
+	HDOP       float64
+	Received   time.Time // zero if the rover never reported
+}
+
+// ParseRoverGGA returns the report of a GGA sentence received at now, false if line isn't a GGA
+// sentence with a valid checksum and a position.
+func ParseRoverGGA(line string, now time.Time) (RoverReport, bool) {
+	ind := strings.Index(line, "$G")
+	if ind == -1 {
+		return RoverReport{}, false
+	}
+	body, checksum, ok := strings.Cut(strings.TrimSpace(line[ind+1:]), "*")
+	if !ok || !validNMEAChecksum(body, checksum) {
+		return RoverReport{}, false
+	}
+	// GPGGA,<time>,<lat>,N,<lng>,E,<quality>,<sats>,<hdop>,<alt>,M,...
+	fields := strings.Split(body, ",")
+	if len(fields) < 10 || len(fields[0]) != 5 || fields[0][2:] != "GGA" {
+		return RoverReport{}, false
+	}
+	lat, okLat := nmeaDegrees(fields[2], fields[3], "S")
+	lng, okLng := nmeaDegrees(fields[4], fields[5], "W")
+	if !okLat || !okLng {
+		return RoverReport{}, false
+	}
+	report := RoverReport{Lat: lat, Lng: lng, Received: now}
+	report.FixQuality, _ = strconv.Atoi(fields[6])
+	report.Satellites, _ = strconv.Atoi(fields[7])
+	report.HDOP, _ = strconv.ParseFloat(fields[8], 64)
+	report.Alt, _ = strconv.ParseFloat(fields[9], 64)
+	return report, true
+}
+
+// nmeaDegrees converts a (d)ddmm.mmmm nmea coordinate to degrees, negative in the hemisphere neg.
+func nmeaDegrees(value, hemisphere, neg string) (float64, bool) {
+	dot := strings.IndexByte(value, '.')
+	if dot == -1 {
+		dot = len(value)
+	}
+	if dot < 3 {
+		return 0, false
+	}
+	deg, err := strconv.Atoi(value[:dot-2])
+	if err != nil {
+		return 0, false
+	}
+	minutes, err := strconv.ParseFloat(value[dot-2:], 64)
+	if err != nil {
+		return 0, false
+	}
+	degrees := float64(deg) + minutes/60
+	if hemisphere == neg {
+		degrees = -degrees
+	}
+	return degrees, true
+}
+
+// Reading returns the report as a reading of the rover with the IP address ip, with its age at now.
+func (r RoverReport) Reading(ip string, now time.Time) map[string]interface{} {
+	return map[string]interface{}{
+		"ip":          ip,
+		"lat":         r.Lat,
+		"lng":         r.Lng,
+		"alt_m":       r.Alt,
+		"fix_quality": r.FixQuality,
+		"carr_soln":   carrierSolutionNames[GGASolutionStatus(r.FixQuality).CarrierSolution],
+		"satellites":  r.Satellites,
+		"hdop":        r.HDOP,
+		"age_sec":     now.Sub(r.Received).Seconds(),
+	}
+}
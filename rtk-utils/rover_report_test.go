@@ -0,0 +1,37 @@
+package rtkutils
+
+import (
+	"testing"
+	"time"
+
+	"go.viam.com/test"
+)
+
+func TestParseRoverGGA(t *testing.T) {
+	now := time.Date(2023, 7, 1, 10, 10, 10, 0, time.UTC)
+	report, ok := ParseRoverGGA("$GNGGA,101010.00,3351.12345,S,15112.54321,E,4,14,0.7,45.2,M,20.1,M,1.0,0001*75\r\n", now)
+	test.That(t, ok, test.ShouldBeTrue)
+	test.That(t, report.Lat, test.ShouldAlmostEqual, -(33 + 51.12345/60), 1e-9)
+	test.That(t, report.Lng, test.ShouldAlmostEqual, 151+12.54321/60, 1e-9)
+	test.That(t, report.Alt, test.ShouldEqual, 45.2)
+	test.That(t, report.FixQuality, test.ShouldEqual, FixQualityRTKFixed)
+	test.That(t, report.Satellites, test.ShouldEqual, 14)
+	test.That(t, report.HDOP, test.ShouldEqual, 0.7)
+
+	reading := report.Reading("10.0.0.7", now.Add(2*time.Second))
+	test.That(t, reading["carr_soln"], test.ShouldEqual, "fixed")
+	test.That(t, reading["age_sec"], test.ShouldEqual, 2.0)
+
+	report, ok = ParseRoverGGA(testGGAEpoch1, now)
+	test.That(t, ok, test.ShouldBeTrue)
+	test.That(t, report.Lat, test.ShouldAlmostEqual, 37+23.46587704/60, 1e-9)
+	test.That(t, report.Lng, test.ShouldAlmostEqual, -(122 + 2.26957864/60), 1e-9)
+
+	// a bad checksum, another sentence and a fix with no position aren't reports
+	_, ok = ParseRoverGGA("$GNGGA,101010.00,3351.12345,S,15112.54321,E,4,14,0.7,45.2,M,20.1,M,1.0,0001*76", now)
+	test.That(t, ok, test.ShouldBeFalse)
+	_, ok = ParseRoverGGA(testGSAEpoch1, now)
+	test.That(t, ok, test.ShouldBeFalse)
+	_, ok = ParseRoverGGA("$GPGGA,101010.00,,,,,0,00,99.99,,,,,,*67", now)
+	test.That(t, ok, test.ShouldBeFalse)
+}
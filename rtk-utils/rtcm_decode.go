@@ -0,0 +1,72 @@
+package rtkutils
+
+import (
+	"time"
+
+	"github.com/go-gnss/rtcm/rtcm3"
+)
+
+// DecodeRTCMCommand returns a summary of the last "count" rtcm frames a component saw, 10 by
+// default, for debugging a correction stream in the field without an RTCM decoder.
+const DecodeRTCMCommand = "decode_rtcm"
+
+const defaultDecodeRTCMCount = 10
+
+// Last returns up to the last n frames of the buffer, oldest first.
+func (b *RTCMBuffer) Last(n int) [][]byte {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if n > len(b.frames) {
+		n = len(b.frames)
+	}
+	if n <= 0 {
+		return nil
+	}
+	return append([][]byte(nil), b.frames[len(b.frames)-n:]...)
+}
+
+// DecodeRTCMResult answers a DecodeRTCMCommand with the frames of b.
+func DecodeRTCMResult(b *RTCMBuffer, cmd map[string]interface{}) map[string]interface{} {
+	frames := []interface{}{}
+	for _, frame := range b.Last(IntArg(cmd, "count", defaultDecodeRTCMCount)) {
+		frames = append(frames, DecodeRTCMFrame(frame))
+	}
+	return map[string]interface{}{"frames": frames}
+}
+
+// DecodeRTCMFrame summarizes a complete rtcm frame: its message_type and length in bytes, and, for
+// the messages that have them, the station_id of the base, and the number of satellites and epoch of
+// observations, resolved to the current GPS week or GLONASS day. MSM observations also have the
+// constellation they are of.
+func DecodeRTCMFrame(frame []byte) map[string]interface{} {
+	summary := map[string]interface{}{"length": len(frame)}
+	if len(frame) < 3+2+3 {
+		return summary
+	}
+	payload := frame[3 : len(frame)-3]
+	msgNum := int(payload[0])<<4 | int(payload[1])>>4
+	summary["message_type"] = msgNum
+	msg := rtcm3.DeserializeMessage(payload)
+	if stream, _, ok := msmEpoch(frame); ok {
+		summary["station_id"] = stream.station
+		if constellation := (stream.msgNum - rtcmMSMFirst) / 10; constellation < len(msmConstellations) {
+			summary["constellation"] = msmConstellations[constellation]
+		}
+	} else if station, ok := rtcmStationID(msgNum, payload); ok {
+		summary["station_id"] = station
+	}
+	if obs, ok := msg.(rtcm3.Observation); ok {
+		summary["satellites"] = obs.SatelliteCount()
+		summary["epoch"] = obs.Time().UTC().Format(time.RFC3339Nano)
+	}
+	return summary
+}
+
+// rtcmStationID returns the reference station ID of the legacy observation and antenna messages,
+// which start with it after the message number like MSM messages.
+func rtcmStationID(msgNum int, payload []byte) (int, bool) {
+	if (msgNum < 1001 || msgNum > 1013) && msgNum != 1033 && msgNum != 1230 {
+		return 0, false
+	}
+	return int(payload[1]&0x0F)<<8 | int(payload[2]), true
+}
@@ -0,0 +1,40 @@
+package rtkutils
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-gnss/rtcm/rtcm3"
+	"go.viam.com/test"
+)
+
+func TestDecodeRTCM(t *testing.T) {
+	var b RTCMBuffer
+	test.That(t, DecodeRTCMResult(&b, map[string]interface{}{}), test.ShouldResemble,
+		map[string]interface{}{"frames": []interface{}{}})
+
+	position := rtcm3.EncapsulateByteArray([]byte{0x3e, 0xd0, 0x01, 0x02, 0x03}).Serialize()
+	msm := testMSM4(geodeticToECEF(37.4, -122.1, 30), testConstellation())
+	msm.MsmHeader.ReferenceStationId = 7
+	gps := rtcm3.EncapsulateMessage(msm).Serialize()
+	b.Add(position, gps)
+	test.That(t, b.Last(1), test.ShouldResemble, [][]byte{gps})
+	test.That(t, b.Last(5), test.ShouldResemble, [][]byte{position, gps})
+
+	result := DecodeRTCMResult(&b, map[string]interface{}{"count": 1.0})
+	frames := result["frames"].([]interface{})
+	test.That(t, len(frames), test.ShouldEqual, 1)
+	summary := frames[0].(map[string]interface{})
+	test.That(t, summary["message_type"], test.ShouldEqual, 1074)
+	test.That(t, summary["station_id"], test.ShouldEqual, 7)
+	test.That(t, summary["constellation"], test.ShouldEqual, "gps")
+	test.That(t, summary["satellites"], test.ShouldEqual, 24)
+	test.That(t, summary["length"], test.ShouldEqual, len(gps))
+	test.That(t, summary["epoch"], test.ShouldEqual, msm.Time().UTC().Format(time.RFC3339Nano))
+
+	frames = DecodeRTCMResult(&b, map[string]interface{}{})["frames"].([]interface{})
+	test.That(t, len(frames), test.ShouldEqual, 2)
+	test.That(t, frames[0], test.ShouldResemble, map[string]interface{}{
+		"length": len(position), "message_type": 1005, "station_id": 1,
+	})
+}
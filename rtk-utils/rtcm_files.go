@@ -0,0 +1,223 @@
+package rtkutils
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"go.uber.org/multierr"
+)
+
+// Defaults of the rtcm file sink.
+const (
+	DefaultRTCMRotateInterval = time.Hour
+	DefaultRTCMKeepFiles      = 24
+)
+
+const (
+	rtcmFilePrefix = "rtcm-"
+	rtcmFileExt    = ".rtcm3"
+
+	// GPS MSM messages, whose epoch time paces a replay
+	rtcmGPSMSMFirst = 1071
+	rtcmGPSMSMLast  = 1077
+	// longest pause between two replayed epochs, so gaps in a recording don't stall the replay
+	maxReplayGap = 5 * time.Second
+	// milliseconds in a GPS week, where MSM epoch times wrap
+	gpsWeekMillis = 7 * 24 * 60 * 60 * 1000
+)
+
+// RTCMFileSink writes an rtcm stream to timestamped files in a directory, starting a new file every
+// rotate interval and deleting the oldest files beyond the number to keep. A nil sink writes nothing.
+type RTCMFileSink struct {
+	dir    string
+	rotate time.Duration
+	keep   int
+
+	mu     sync.Mutex
+	file   *os.File
+	opened time.Time
+}
+
+// NewRTCMFileSink returns a sink writing to dir, or nil if dir is empty. Zero values of rotate
+// and keep use the defaults.
+func NewRTCMFileSink(dir string, rotate time.Duration, keep int) (*RTCMFileSink, error) {
+	if dir == "" {
+		return nil, nil
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	if rotate <= 0 {
+		rotate = DefaultRTCMRotateInterval
+	}
+	if keep <= 0 {
+		keep = DefaultRTCMKeepFiles
+	}
+	return &RTCMFileSink{dir: dir, rotate: rotate, keep: keep}, nil
+}
+
+// Write appends rtcm bytes to the current file, rotating it first if it is due.
+func (s *RTCMFileSink) Write(p []byte) (int, error) {
+	if s == nil {
+		return len(p), nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now().UTC()
+	if s.file == nil || now.Sub(s.opened) >= s.rotate {
+		if err := s.rotateLocked(now); err != nil {
+			return 0, err
+		}
+	}
+	return s.file.Write(p)
+}
+
+func (s *RTCMFileSink) rotateLocked(now time.Time) error {
+	if s.file != nil {
+		if err := s.file.Close(); err != nil {
+			return err
+		}
+		s.file = nil
+	}
+	name := filepath.Join(s.dir, rtcmFilePrefix+now.Format("20060102T150405Z")+rtcmFileExt)
+	f, err := os.OpenFile(name, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	s.file, s.opened = f, now
+
+	files, err := RTCMFiles(s.dir)
+	if err != nil {
+		return err
+	}
+	for len(files) > s.keep {
+		if err := os.Remove(files[0]); err != nil {
+			return err
+		}
+		files = files[1:]
+	}
+	return nil
+}
+
+// Close syncs the current file to disk and closes it. A later write starts a new file.
+func (s *RTCMFileSink) Close() error {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.file == nil {
+		return nil
+	}
+	err := multierr.Combine(s.file.Sync(), s.file.Close())
+	s.file = nil
+	return err
+}
+
+// RTCMFiles returns the files written by an RTCMFileSink in dir, oldest first.
+func RTCMFiles(dir string) ([]string, error) {
+	files, err := filepath.Glob(filepath.Join(dir, rtcmFilePrefix+"*"+rtcmFileExt))
+	if err != nil {
+		return nil, err
+	}
+	// the timestamps in the names sort in time order
+	sort.Strings(files)
+	return files, nil
+}
+
+// RTCMReplayReader replays a recorded rtcm file as a correction source. Frames are released at
+// the pace they were recorded at, taken from the epoch times of the GPS MSM messages.
+type RTCMReplayReader struct {
+	ctx  context.Context
+	loop bool
+
+	file      *os.File
+	frames    *FrameReader
+	lastEpoch int64 // GPS time of week in ms of the last replayed epoch, -1 before the first
+	pending   []byte
+}
+
+// NewRTCMReplayReader opens path for replay. With loop set the file starts over once it ends.
+func NewRTCMReplayReader(ctx context.Context, path string, loop bool) (*RTCMReplayReader, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	return &RTCMReplayReader{ctx: ctx, loop: loop, file: f, frames: NewFrameReader(f), lastEpoch: -1}, nil
+}
+
+// Read copies the next replayed frames into p, waiting as long as the recording did between epochs.
+// It returns io.EOF once the file ends, unless it loops.
+func (r *RTCMReplayReader) Read(p []byte) (int, error) {
+	for len(r.pending) == 0 {
+		frame, err := r.frames.Next()
+		if err == io.EOF && r.loop {
+			if _, err := r.file.Seek(0, io.SeekStart); err != nil {
+				return 0, err
+			}
+			r.frames.Reset(r.file)
+			r.lastEpoch = -1
+			continue
+		}
+		if err != nil {
+			return 0, err
+		}
+		if err := r.wait(frame); err != nil {
+			return 0, err
+		}
+		r.pending = frame
+	}
+	n := copy(p, r.pending)
+	r.pending = r.pending[n:]
+	return n, nil
+}
+
+// wait sleeps until frame is due, if it starts a new GPS epoch.
+func (r *RTCMReplayReader) wait(frame []byte) error {
+	epoch, ok := gpsMSMEpoch(frame)
+	if !ok {
+		return nil
+	}
+	last := r.lastEpoch
+	r.lastEpoch = epoch
+	if last < 0 || epoch == last {
+		return nil
+	}
+
+	gap := time.Duration((epoch-last+gpsWeekMillis)%gpsWeekMillis) * time.Millisecond
+	if gap > maxReplayGap {
+		gap = maxReplayGap
+	}
+	select {
+	case <-r.ctx.Done():
+		return r.ctx.Err()
+	case <-time.After(gap):
+		return nil
+	}
+}
+
+// Close closes the replayed file.
+func (r *RTCMReplayReader) Close() error {
+	return r.file.Close()
+}
+
+// gpsMSMEpoch returns the GPS time of week in ms of a GPS MSM frame.
+func gpsMSMEpoch(frame []byte) (int64, bool) {
+	// 3 header bytes, then 12 bits message number, 12 bits station id and 30 bits epoch time
+	if len(frame) < 3+7 {
+		return 0, false
+	}
+	payload := frame[3:]
+	msgNum := int(payload[0])<<4 | int(payload[1])>>4
+	if msgNum < rtcmGPSMSMFirst || msgNum > rtcmGPSMSMLast {
+		return 0, false
+	}
+	bits := uint64(payload[3])<<24 | uint64(payload[4])<<16 | uint64(payload[5])<<8 | uint64(payload[6])
+	return int64(bits >> 2), true
+}
@@ -0,0 +1,95 @@
+package rtkutils
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-gnss/rtcm/rtcm3"
+	"go.viam.com/test"
+)
+
+// gpsMSMFrame returns an rtcm 1074 frame with the given GPS epoch time in ms.
+func gpsMSMFrame(epoch int64) []byte {
+	bits := uint32(epoch) << 2
+	return rtcm3.EncapsulateByteArray([]byte{
+		0x43, 0x20, 0x01, byte(bits >> 24), byte(bits >> 16), byte(bits >> 8), byte(bits), 0x00,
+	}).Serialize()
+}
+
+func TestRTCMFileSink(t *testing.T) {
+	s, err := NewRTCMFileSink("", 0, 0)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, s, test.ShouldBeNil)
+	_, err = s.Write([]byte{0xD3})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, s.Close(), test.ShouldBeNil)
+
+	dir := filepath.Join(t.TempDir(), "rtcm")
+	s, err = NewRTCMFileSink(dir, time.Hour, 2)
+	test.That(t, err, test.ShouldBeNil)
+
+	// files of earlier runs beyond the ones to keep are deleted once a new file starts
+	for _, name := range []string{"rtcm-20230101T000000Z.rtcm3", "rtcm-20230101T010000Z.rtcm3"} {
+		test.That(t, os.WriteFile(filepath.Join(dir, name), []byte{0xD3}, 0o644), test.ShouldBeNil)
+	}
+	frame := gpsMSMFrame(1000)
+	_, err = s.Write(frame)
+	test.That(t, err, test.ShouldBeNil)
+	_, err = s.Write(frame)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, s.Close(), test.ShouldBeNil)
+
+	files, err := RTCMFiles(dir)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, len(files), test.ShouldEqual, 2)
+	test.That(t, filepath.Base(files[0]), test.ShouldEqual, "rtcm-20230101T010000Z.rtcm3")
+	data, err := os.ReadFile(files[1])
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, data, test.ShouldResemble, append(append([]byte{}, frame...), frame...))
+}
+
+func TestRTCMReplayReader(t *testing.T) {
+	_, err := NewRTCMReplayReader(context.Background(), filepath.Join(t.TempDir(), "missing.rtcm3"), false)
+	test.That(t, err, test.ShouldNotBeNil)
+
+	other := rtcm3.EncapsulateByteArray([]byte{0x3e, 0xd0, 0x01, 0x02, 0x03}).Serialize()
+	var recording []byte
+	recording = append(recording, 0x00, 0xD3) // noise before the first frame
+	recording = append(recording, gpsMSMFrame(gpsWeekMillis-100)...)
+	recording = append(recording, other...)
+	recording = append(recording, gpsMSMFrame(100)...) // 200ms later, across the week rollover
+	path := filepath.Join(t.TempDir(), "replay.rtcm3")
+	test.That(t, os.WriteFile(path, recording, 0o644), test.ShouldBeNil)
+
+	r, err := NewRTCMReplayReader(context.Background(), path, false)
+	test.That(t, err, test.ShouldBeNil)
+	start := time.Now()
+	out, err := io.ReadAll(r)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, time.Since(start), test.ShouldBeGreaterThanOrEqualTo, 200*time.Millisecond)
+	test.That(t, out, test.ShouldResemble, recording[2:])
+	test.That(t, r.Close(), test.ShouldBeNil)
+
+	// a looping replay keeps going until it is canceled
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	r, err = NewRTCMReplayReader(ctx, path, true)
+	test.That(t, err, test.ShouldBeNil)
+	out, err = io.ReadAll(r)
+	test.That(t, err, test.ShouldBeError, context.DeadlineExceeded)
+	test.That(t, len(out), test.ShouldBeGreaterThan, len(recording))
+	test.That(t, r.Close(), test.ShouldBeNil)
+}
+
+func TestGPSMSMEpoch(t *testing.T) {
+	epoch, ok := gpsMSMEpoch(gpsMSMFrame(123456))
+	test.That(t, ok, test.ShouldBeTrue)
+	test.That(t, epoch, test.ShouldEqual, 123456)
+
+	_, ok = gpsMSMEpoch(rtcm3.EncapsulateByteArray([]byte{0x3e, 0xd0, 0x01, 0x02, 0x03, 0x04, 0x05}).Serialize())
+	test.That(t, ok, test.ShouldBeFalse)
+}
@@ -0,0 +1,170 @@
+package rtkutils
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// RTCMStationsCommand returns the reference stations seen in the rtcm of corrections, and when each
+// sent them.
+const RTCMStationsCommand = "rtcm_stations"
+
+// Reference station event types.
+const (
+	// the corrections come from another reference station than before
+	EventRTCMStationSwitched = "rtcm_station_switched"
+	// the corrections mix the rtcm of several reference stations, such as bases sharing a radio
+	// frequency, which receivers can't use together
+	EventRTCMStationsInterleaved = "rtcm_stations_interleaved"
+)
+
+const (
+	// a reference station that sent nothing for this long is gone
+	rtcmStationTimeout = 10 * time.Second
+	// number of periods kept by an RTCMStationHistory
+	rtcmStationLogSize = 100
+)
+
+// RTCMStationPeriod is a time a reference station sent rtcm without a gap, from the time of its first
+// frame to that of its last.
+type RTCMStationPeriod struct {
+	Station  int
+	From, To time.Time
+	Frames   uint64
+}
+
+// RTCMStationHistory records the reference station IDs of the rtcm of corrections over time, which
+// shows a VRS network handing a rover over to another station, or several bases sharing a radio
+// frequency. It raises an event when the corrections come from another station than before, and
+// another when they come from several at once. The zero value is ready to use and safe for
+// concurrent use.
+type RTCMStationHistory struct {
+	mu       sync.Mutex
+	stations map[int]*RTCMStationPeriod // every station seen, from its first frame to its last
+	active   map[int]*RTCMStationPeriod // stations sending, by ID
+	periods  []*RTCMStationPeriod       // oldest to newest
+	last     int                        // station of the latest period, -1 before any
+	switches Counter
+}
+
+// frameStationID returns the reference station ID of an rtcm frame, and false if its message has none.
+func frameStationID(frame []byte) (int, bool) {
+	if stream, _, ok := msmEpoch(frame); ok {
+		return stream.station, true
+	}
+	// 3 header bytes, then 12 bits message number and 12 bits station id
+	if len(frame) < 3+3 {
+		return 0, false
+	}
+	payload := frame[3:]
+	return rtcmStationID(int(payload[0])<<4|int(payload[1])>>4, payload)
+}
+
+// Frame takes an rtcm frame read at now, adding an event to events if it starts a period of a station
+// that switches or interleaves the corrections.
+func (h *RTCMStationHistory) Frame(frame []byte, now time.Time, events *EventLog) {
+	station, ok := frameStationID(frame)
+	if !ok {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.stations == nil {
+		h.stations, h.active, h.last = map[int]*RTCMStationPeriod{}, map[int]*RTCMStationPeriod{}, -1
+	}
+	for id, p := range h.active {
+		if now.Sub(p.To) > rtcmStationTimeout {
+			delete(h.active, id)
+		}
+	}
+	if seen := h.stations[station]; seen != nil {
+		seen.To = now
+		seen.Frames++
+	} else {
+		h.stations[station] = &RTCMStationPeriod{Station: station, From: now, To: now, Frames: 1}
+	}
+	if p := h.active[station]; p != nil {
+		p.To = now
+		p.Frames++
+		return
+	}
+
+	switch {
+	case len(h.active) > 0:
+		events.Add(EventRTCMStationsInterleaved, "the corrections mix the rtcm of reference stations %s and %d, "+
+			"receivers can't use them together", joinInts(h.activeIDs()), station)
+	case h.last != -1 && h.last != station:
+		h.switches.Add(1)
+		events.Add(EventRTCMStationSwitched, "the corrections switched from reference station %d to %d", h.last, station)
+	}
+	p := &RTCMStationPeriod{Station: station, From: now, To: now, Frames: 1}
+	h.active[station] = p
+	h.periods = append(h.periods, p)
+	if len(h.periods) > rtcmStationLogSize {
+		h.periods = h.periods[1:]
+	}
+	h.last = station
+}
+
+// activeIDs returns the IDs of the stations sending, sorted. It must be called with mu held.
+func (h *RTCMStationHistory) activeIDs() []int {
+	ids := make([]int, 0, len(h.active))
+	for id := range h.active {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	return ids
+}
+
+// Readings returns the number of reference stations seen, those sending at now, and how many times the
+// corrections switched stations.
+func (h *RTCMStationHistory) Readings(now time.Time) map[string]interface{} {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	var sending []int
+	for _, id := range h.activeIDs() {
+		if now.Sub(h.active[id].To) <= rtcmStationTimeout {
+			sending = append(sending, id)
+		}
+	}
+	return map[string]interface{}{
+		"rtcm_stations_seen":    len(h.stations),
+		"rtcm_station_ids":      intsResult(sending),
+		"rtcm_station_switches": h.switches.Get(),
+	}
+}
+
+// StationsResult answers an RTCMStationsCommand at now with every station seen, by ID, and the
+// history of the periods they sent in, oldest first.
+func (h *RTCMStationHistory) StationsResult(now time.Time) map[string]interface{} {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	ids := make([]int, 0, len(h.stations))
+	for id := range h.stations {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	stations := []interface{}{}
+	for _, id := range ids {
+		s := h.stations[id]
+		stations = append(stations, map[string]interface{}{
+			"station_id": id,
+			"first_seen": s.From.UTC().Format(time.RFC3339Nano),
+			"last_seen":  s.To.UTC().Format(time.RFC3339Nano),
+			"frames":     s.Frames,
+		})
+	}
+	history := []interface{}{}
+	for _, p := range h.periods {
+		history = append(history, map[string]interface{}{
+			"station_id":   p.Station,
+			"from":         p.From.UTC().Format(time.RFC3339Nano),
+			"to":           p.To.UTC().Format(time.RFC3339Nano),
+			"duration_sec": p.To.Sub(p.From).Seconds(),
+			"frames":       p.Frames,
+			"sending":      h.active[p.Station] == p && now.Sub(p.To) <= rtcmStationTimeout,
+		})
+	}
+	return map[string]interface{}{"stations": stations, "history": history, "switches": h.switches.Get()}
+}
@@ -0,0 +1,86 @@
+package rtkutils
+
+import (
+	"testing"
+	"time"
+
+	"github.com/edaniels/golog"
+	"github.com/go-gnss/rtcm/rtcm3"
+	"go.viam.com/test"
+)
+
+// stationFrame returns an rtcm frame of message msgNum from reference station station.
+func stationFrame(msgNum, station int) []byte {
+	return rtcm3.EncapsulateByteArray([]byte{
+		byte(msgNum >> 4), byte(msgNum<<4) | byte(station>>8), byte(station), 0x00, 0x00, 0x00, 0x00, 0x00,
+	}).Serialize()
+}
+
+func TestFrameStationID(t *testing.T) {
+	id, ok := frameStationID(stationFrame(1077, 31))
+	test.That(t, ok, test.ShouldBeTrue)
+	test.That(t, id, test.ShouldEqual, 31)
+	id, ok = frameStationID(stationFrame(1005, 1012))
+	test.That(t, ok, test.ShouldBeTrue)
+	test.That(t, id, test.ShouldEqual, 1012)
+	// ephemerides come from no station
+	_, ok = frameStationID(stationFrame(1019, 0))
+	test.That(t, ok, test.ShouldBeFalse)
+}
+
+func TestRTCMStationHistory(t *testing.T) {
+	events := NewEventLog(golog.NewTestLogger(t))
+	now := time.Now()
+
+	var h RTCMStationHistory
+	test.That(t, h.Readings(now), test.ShouldResemble, map[string]interface{}{
+		"rtcm_stations_seen": 0, "rtcm_station_ids": []interface{}{}, "rtcm_station_switches": uint64(0),
+	})
+
+	// a VRS network hands the rover over from station 31 to 1012
+	for i := 0; i < 30; i++ {
+		h.Frame(stationFrame(1077, 31), now, events)
+		h.Frame(stationFrame(1019, 0), now, events)
+		now = now.Add(time.Second)
+	}
+	test.That(t, events.Events(""), test.ShouldBeEmpty)
+	now = now.Add(time.Minute)
+	for i := 0; i < 30; i++ {
+		h.Frame(stationFrame(1077, 1012), now, events)
+		now = now.Add(time.Second)
+	}
+	test.That(t, h.Readings(now), test.ShouldResemble, map[string]interface{}{
+		"rtcm_stations_seen": 2, "rtcm_station_ids": []interface{}{1012}, "rtcm_station_switches": uint64(1),
+	})
+	switched := events.Events(EventRTCMStationSwitched)
+	test.That(t, switched, test.ShouldHaveLength, 1)
+	test.That(t, switched[0].Message, test.ShouldEqual, "the corrections switched from reference station 31 to 1012")
+
+	// another base on the same radio frequency
+	for i := 0; i < 5; i++ {
+		h.Frame(stationFrame(1077, 1012), now, events)
+		h.Frame(stationFrame(1005, 7), now, events)
+		now = now.Add(time.Second)
+	}
+	test.That(t, h.Readings(now)["rtcm_station_ids"], test.ShouldResemble, []interface{}{7, 1012})
+	interleaved := events.Events(EventRTCMStationsInterleaved)
+	test.That(t, interleaved, test.ShouldHaveLength, 1)
+	test.That(t, interleaved[0].Message, test.ShouldEqual,
+		"the corrections mix the rtcm of reference stations 1012 and 7, receivers can't use them together")
+
+	result := h.StationsResult(now)
+	test.That(t, result["switches"], test.ShouldEqual, uint64(1))
+	test.That(t, result["stations"], test.ShouldHaveLength, 3)
+	test.That(t, result["stations"].([]interface{})[0].(map[string]interface{})["station_id"], test.ShouldEqual, 7)
+	history := result["history"].([]interface{})
+	test.That(t, history, test.ShouldHaveLength, 3)
+	first := history[0].(map[string]interface{})
+	test.That(t, first["station_id"], test.ShouldEqual, 31)
+	test.That(t, first["duration_sec"], test.ShouldEqual, 29.0)
+	test.That(t, first["frames"], test.ShouldEqual, uint64(30))
+	test.That(t, first["sending"], test.ShouldBeFalse)
+	test.That(t, history[1].(map[string]interface{})["sending"], test.ShouldBeTrue)
+
+	// once the stations went quiet none is sending
+	test.That(t, h.Readings(now.Add(time.Minute))["rtcm_station_ids"], test.ShouldResemble, []interface{}{})
+}
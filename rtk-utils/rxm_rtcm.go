@@ -0,0 +1,96 @@
+package rtkutils
+
+import (
+	"time"
+)
+
+// UBXRxmRTCM is the UBX-RXM-RTCM message a u-blox receiver outputs for every RTCM message it receives.
+const UBXRxmRTCM = 0x32
+
+const (
+	rxmRTCMPayloadLen     = 8
+	rxmRTCMFlagCRCFailed  = 1 << 0
+	rxmRTCMMsgUsedShift   = 1
+	rxmRTCMMsgUsedMask    = 0x3
+	rxmRTCMMsgUsedNotUsed = 1
+	rxmRTCMMsgUsedUsed    = 2
+)
+
+// ReceiverRTCMStats counts the RTCM messages a u-blox receiver reports receiving in UBX-RXM-RTCM
+// messages among its nmea sentences: those it used, those it didn't use, e.g. for signals it
+// doesn't track, and those that failed their crc on the way to it. Compared to the frames written
+// to the receiver, they tell a link losing or corrupting corrections from a receiver ignoring them,
+// e.g. because the port they arrive on doesn't take RTCM. A nil stats counts none.
+type ReceiverRTCMStats struct {
+	ubx []byte // start of a frame cut off by the end of the last write
+
+	used      Counter
+	unused    Counter
+	unknown   Counter // receivers that don't tell whether they used a message
+	crcFailed Counter
+}
+
+// NewReceiverRTCMStats returns stats if the receiver outputs UBX, nil otherwise.
+func NewReceiverRTCMStats(profile ReceiverProfile) *ReceiverRTCMStats {
+	if !profile.UBX {
+		return nil
+	}
+	return &ReceiverRTCMStats{}
+}
+
+// EnableReceiverRTCMStats asks a u-blox receiver to output UBX-RXM-RTCM for every RTCM message it
+// receives, on the port write sends to.
+func EnableReceiverRTCMStats(write func([]byte) error) error {
+	return write(UBXMessageRatePacket(UBXClassRxm, UBXRxmRTCM, 1))
+}
+
+// Write reads the next bytes output by the receiver. It never fails.
+func (s *ReceiverRTCMStats) Write(p []byte) (int, error) {
+	if s == nil {
+		return len(p), nil
+	}
+	var frames [][]byte
+	frames, s.ubx = SplitUBXFrames(append(s.ubx, p...))
+	for _, frame := range frames {
+		cls, id, payload := UBXFrame(frame)
+		if cls != UBXClassRxm || id != UBXRxmRTCM || len(payload) != rxmRTCMPayloadLen {
+			continue
+		}
+		flags := payload[1]
+		switch {
+		case flags&rxmRTCMFlagCRCFailed != 0:
+			s.crcFailed.Add(1)
+		case (flags>>rxmRTCMMsgUsedShift)&rxmRTCMMsgUsedMask == rxmRTCMMsgUsedUsed:
+			s.used.Add(1)
+		case (flags>>rxmRTCMMsgUsedShift)&rxmRTCMMsgUsedMask == rxmRTCMMsgUsedNotUsed:
+			s.unused.Add(1)
+		default:
+			s.unknown.Add(1)
+		}
+	}
+	return len(p), nil
+}
+
+// Readings returns the counts, and how long ago the receiver last reported a message, once it
+// reported any.
+func (s *ReceiverRTCMStats) Readings(now time.Time) map[string]interface{} {
+	if s == nil {
+		return map[string]interface{}{}
+	}
+	last := s.used.Last()
+	for _, c := range []*Counter{&s.unused, &s.unknown, &s.crcFailed} {
+		if c.Last().After(last) {
+			last = c.Last()
+		}
+	}
+	if last.IsZero() {
+		return map[string]interface{}{}
+	}
+	return map[string]interface{}{
+		"receiver_rtcm_used":         s.used.Get(),
+		"receiver_rtcm_unused":       s.unused.Get(),
+		"receiver_rtcm_unknown":      s.unknown.Get(),
+		"receiver_rtcm_crc_failed":   s.crcFailed.Get(),
+		"receiver_rtcm_last_age_sec": now.Sub(last).Seconds(),
+	}
+}
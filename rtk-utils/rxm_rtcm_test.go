@@ -0,0 +1,62 @@
+package rtkutils
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"go.viam.com/test"
+)
+
+func rxmRTCMPayload(flags byte) []byte {
+	payload := make([]byte, rxmRTCMPayloadLen)
+	payload[1] = flags
+	// message type 1077
+	payload[6], payload[7] = 0x35, 0x04
+	return payload
+}
+
+func TestReceiverRTCMStats(t *testing.T) {
+	var none *ReceiverRTCMStats
+	n, err := none.Write([]byte{0xb5, 0x62})
+	test.That(t, n, test.ShouldEqual, 2)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, none.Readings(time.Now()), test.ShouldBeEmpty)
+	test.That(t, NewReceiverRTCMStats(ReceiverProfile{}), test.ShouldBeNil)
+
+	stats := NewReceiverRTCMStats(ReceiverProfile{UBX: true})
+	test.That(t, stats.Readings(time.Now()), test.ShouldBeEmpty)
+
+	stream := append([]byte("$GNGGA,1*00\r\n"), UBXPacket(UBXClassRxm, UBXRxmRTCM, rxmRTCMPayload(2<<1))...)
+	stream = append(stream, UBXPacket(UBXClassRxm, UBXRxmRTCM, rxmRTCMPayload(2<<1))...)
+	stream = append(stream, UBXPacket(UBXClassRxm, UBXRxmRTCM, rxmRTCMPayload(1<<1))...)
+	stream = append(stream, UBXPacket(UBXClassRxm, UBXRxmRTCM, rxmRTCMPayload(0))...)
+	stream = append(stream, UBXPacket(UBXClassRxm, UBXRxmRTCM, rxmRTCMPayload(rxmRTCMFlagCRCFailed))...)
+	// other rxm messages aren't counted
+	stream = append(stream, UBXPacket(UBXClassRxm, UBXRxmRawx, make([]byte, 16))...)
+	// a frame split across writes is counted once complete
+	stream = append(stream, UBXPacket(UBXClassRxm, UBXRxmRTCM, rxmRTCMPayload(2<<1))...)
+	stats.Write(stream[:len(stream)-5])
+	test.That(t, stats.Readings(time.Now())["receiver_rtcm_used"], test.ShouldEqual, uint64(2))
+	stats.Write(stream[len(stream)-5:])
+
+	readings := stats.Readings(time.Now().Add(2 * time.Second))
+	test.That(t, readings["receiver_rtcm_used"], test.ShouldEqual, uint64(3))
+	test.That(t, readings["receiver_rtcm_unused"], test.ShouldEqual, uint64(1))
+	test.That(t, readings["receiver_rtcm_unknown"], test.ShouldEqual, uint64(1))
+	test.That(t, readings["receiver_rtcm_crc_failed"], test.ShouldEqual, uint64(1))
+	test.That(t, readings["receiver_rtcm_last_age_sec"], test.ShouldAlmostEqual, 2, 0.5)
+}
+
+func TestEnableReceiverRTCMStats(t *testing.T) {
+	var written []byte
+	err := EnableReceiverRTCMStats(func(p []byte) error {
+		written = p
+		return nil
+	})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, written, test.ShouldResemble, UBXMessageRatePacket(UBXClassRxm, UBXRxmRTCM, 1))
+
+	err = EnableReceiverRTCMStats(func(p []byte) error { return errors.New("port closed") })
+	test.That(t, err, test.ShouldBeError, errors.New("port closed"))
+}
@@ -0,0 +1,185 @@
+package rtkutils
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Transmit schedule event types.
+const (
+	EventScheduleStarted = "schedule_started"
+	EventScheduleStopped = "schedule_stopped"
+)
+
+// how far ahead Schedule.NextChange looks for the schedule to start or stop
+const scheduleHorizon = 366 * 24 * time.Hour
+
+// scheduleFields are the fields of a cron expression, with the range of their values.
+var scheduleFields = []struct {
+	name     string
+	min, max int
+}{
+	{"minute", 0, 59},
+	{"hour", 0, 23},
+	{"day of month", 1, 31},
+	{"month", 1, 12},
+	{"day of week", 0, 6},
+}
+
+// Schedule is when a station transmits corrections, for bases such as solar powered ones that can't
+// run around the clock: the minutes matching a cron expression of five fields, minute, hour, day of
+// month, month and day of week (0 for Sunday), in a time zone. Fields are a *, a value, a range such
+// as 7-18 or a list of them, with an optional /step. As with cron, a minute matches either day field
+// when both are restricted. A nil Schedule is always active.
+type Schedule struct {
+	fields   [5][]bool // the values of every field that match, by value
+	anyDay   [2]bool   // whether the day of month and day of week fields are *
+	location *time.Location
+}
+
+// ValidateSchedule checks the transmit schedule of a config and its time zone. Stopping the receiver
+// outside of it needs a u-blox receiver on the input, which stopGNSS reports.
+func ValidateSchedule(expr, tz string, lowPower, stopGNSS bool) error {
+	if expr == "" {
+		switch {
+		case tz != "":
+			return errors.New("schedule_timezone needs a transmit_schedule")
+		case lowPower:
+			return errors.New("schedule_low_power needs a transmit_schedule")
+		}
+		return nil
+	}
+	if lowPower && !stopGNSS {
+		return errors.New("schedule_low_power needs a u-blox receiver on a serial or i2c input")
+	}
+	_, err := ParseSchedule(expr, tz)
+	return err
+}
+
+// ParseSchedule parses the cron expression of a schedule in the IANA time zone tz, the system's if it
+// is empty. It returns nil for an empty expression.
+func ParseSchedule(expr, tz string) (*Schedule, error) {
+	if expr == "" {
+		return nil, nil
+	}
+	location := time.Local
+	if tz != "" {
+		var err error
+		if location, err = time.LoadLocation(tz); err != nil {
+			return nil, fmt.Errorf("schedule_timezone %q isn't a time zone: %w", tz, err)
+		}
+	}
+	parts := strings.Fields(expr)
+	if len(parts) != len(scheduleFields) {
+		return nil, fmt.Errorf("transmit_schedule %q must have 5 fields: minute, hour, day of month, month and day of week", expr)
+	}
+	s := &Schedule{location: location}
+	for i, part := range parts {
+		values, err := parseScheduleField(part, scheduleFields[i].min, scheduleFields[i].max)
+		if err != nil {
+			return nil, fmt.Errorf("transmit_schedule %s field %q: %w", scheduleFields[i].name, part, err)
+		}
+		s.fields[i] = values
+	}
+	s.anyDay = [2]bool{parts[2] == "*", parts[4] == "*"}
+	return s, nil
+}
+
+// parseScheduleField returns which values between min and max a field of a cron expression matches.
+func parseScheduleField(field string, min, max int) ([]bool, error) {
+	values := make([]bool, max+1)
+	for _, item := range strings.Split(field, ",") {
+		rangePart, step := item, 1
+		if i := strings.Index(item, "/"); i >= 0 {
+			var err error
+			if step, err = strconv.Atoi(item[i+1:]); err != nil || step <= 0 {
+				return nil, fmt.Errorf("step %q must be a positive number", item[i+1:])
+			}
+			rangePart = item[:i]
+		}
+		low, high := min, max
+		if rangePart != "*" {
+			bounds := strings.SplitN(rangePart, "-", 2)
+			var err error
+			if low, err = strconv.Atoi(bounds[0]); err != nil {
+				return nil, fmt.Errorf("%q isn't a number", bounds[0])
+			}
+			high = low
+			if len(bounds) == 2 {
+				if high, err = strconv.Atoi(bounds[1]); err != nil {
+					return nil, fmt.Errorf("%q isn't a number", bounds[1])
+				}
+			} else if step > 1 {
+				// a single value with a step runs to the end of the range, as with cron
+				high = max
+			}
+			if low < min || high > max || low > high {
+				return nil, fmt.Errorf("values must be between %d and %d", min, max)
+			}
+		}
+		for v := low; v <= high; v += step {
+			values[v] = true
+		}
+	}
+	return values, nil
+}
+
+// Active returns whether the station transmits at t.
+func (s *Schedule) Active(t time.Time) bool {
+	if s == nil {
+		return true
+	}
+	t = t.In(s.location)
+	if !s.fields[0][t.Minute()] || !s.fields[1][t.Hour()] || !s.fields[3][int(t.Month())] {
+		return false
+	}
+	dom, dow := s.fields[2][t.Day()], s.fields[4][int(t.Weekday())]
+	switch {
+	case s.anyDay[0] && s.anyDay[1]:
+		return true
+	case s.anyDay[0]:
+		return dow
+	case s.anyDay[1]:
+		return dom
+	}
+	return dom || dow
+}
+
+// NextChange returns when the schedule next starts or stops after t, and false if it doesn't within a
+// year, such as a schedule that is always active.
+func (s *Schedule) NextChange(t time.Time) (time.Time, bool) {
+	if s == nil {
+		return time.Time{}, false
+	}
+	active := s.Active(t)
+	for next := t.Truncate(time.Minute).Add(time.Minute); next.Sub(t) < scheduleHorizon; next = next.Add(time.Minute) {
+		if s.Active(next) != active {
+			return next, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// State returns StateOffSchedule for a station in state outside of the schedule at now, unless it
+// failed, and state otherwise.
+func (s *Schedule) State(state string, now time.Time) string {
+	if state != StateError && !s.Active(now) {
+		return StateOffSchedule
+	}
+	return state
+}
+
+// Readings returns whether the station transmits at now and when that next changes.
+func (s *Schedule) Readings(now time.Time) map[string]interface{} {
+	if s == nil {
+		return map[string]interface{}{}
+	}
+	readings := map[string]interface{}{"transmitting": s.Active(now)}
+	if next, ok := s.NextChange(now); ok {
+		readings["schedule_next_change"] = next.UTC().Format(time.RFC3339)
+	}
+	return readings
+}
@@ -0,0 +1,93 @@
+package rtkutils
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"go.viam.com/test"
+)
+
+func TestValidateSchedule(t *testing.T) {
+	test.That(t, ValidateSchedule("", "", false, false), test.ShouldBeNil)
+	test.That(t, ValidateSchedule("* 7-18 * * 1-5", "Europe/Paris", true, true), test.ShouldBeNil)
+	test.That(t, ValidateSchedule("", "UTC", false, true), test.ShouldBeError,
+		errors.New("schedule_timezone needs a transmit_schedule"))
+	test.That(t, ValidateSchedule("", "", true, true), test.ShouldBeError,
+		errors.New("schedule_low_power needs a transmit_schedule"))
+	test.That(t, ValidateSchedule("* 7-18 * * *", "", true, false), test.ShouldBeError,
+		errors.New("schedule_low_power needs a u-blox receiver on a serial or i2c input"))
+	test.That(t, ValidateSchedule("* 7-24 * * *", "", false, false), test.ShouldBeError,
+		errors.New(`transmit_schedule hour field "7-24": values must be between 0 and 23`))
+	test.That(t, ValidateSchedule("*/0 * * * *", "", false, false), test.ShouldBeError,
+		errors.New(`transmit_schedule minute field "*/0": step "0" must be a positive number`))
+	test.That(t, ValidateSchedule("* * * * mon", "", false, false), test.ShouldBeError,
+		errors.New(`transmit_schedule day of week field "mon": "mon" isn't a number`))
+	test.That(t, ValidateSchedule("* * * * *", "Mars/Olympus", false, false), test.ShouldNotBeNil)
+}
+
+func TestSchedule(t *testing.T) {
+	var always *Schedule
+	monday := time.Date(2026, time.March, 2, 6, 59, 0, 0, time.UTC)
+	test.That(t, always.Active(monday), test.ShouldBeTrue)
+	_, ok := always.NextChange(monday)
+	test.That(t, ok, test.ShouldBeFalse)
+	test.That(t, always.State(StateHealthy, monday), test.ShouldEqual, StateHealthy)
+
+	t.Run("work hours", func(t *testing.T) {
+		s, err := ParseSchedule("* 7-18 * * 1-5", "UTC")
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, s.Active(monday), test.ShouldBeFalse)
+		test.That(t, s.Active(monday.Add(time.Minute)), test.ShouldBeTrue)
+		test.That(t, s.Active(monday.Add(12*time.Hour)), test.ShouldBeTrue)
+		test.That(t, s.Active(monday.Add(12*time.Hour+time.Minute)), test.ShouldBeFalse)
+		// saturday
+		test.That(t, s.Active(monday.Add(5*24*time.Hour+6*time.Hour)), test.ShouldBeFalse)
+
+		next, ok := s.NextChange(monday)
+		test.That(t, ok, test.ShouldBeTrue)
+		test.That(t, next, test.ShouldEqual, monday.Add(time.Minute))
+		next, _ = s.NextChange(monday.Add(time.Hour))
+		test.That(t, next, test.ShouldEqual, monday.Add(12*time.Hour+time.Minute))
+
+		test.That(t, s.State(StateHealthy, monday), test.ShouldEqual, StateOffSchedule)
+		test.That(t, s.State(StateError, monday), test.ShouldEqual, StateError)
+		test.That(t, s.State(StateHealthy, monday.Add(time.Hour)), test.ShouldEqual, StateHealthy)
+		test.That(t, s.Readings(monday), test.ShouldResemble, map[string]interface{}{
+			"transmitting": false, "schedule_next_change": "2026-03-02T07:00:00Z",
+		})
+	})
+
+	t.Run("time zones", func(t *testing.T) {
+		s, err := ParseSchedule("* 7-18 * * *", "America/New_York")
+		test.That(t, err, test.ShouldBeNil)
+		// 06:59 UTC is 01:59 in New York
+		test.That(t, s.Active(monday), test.ShouldBeFalse)
+		test.That(t, s.Active(monday.Add(6*time.Hour+time.Minute)), test.ShouldBeTrue)
+	})
+
+	t.Run("lists and steps", func(t *testing.T) {
+		s, err := ParseSchedule("0-14,30/15 6,12 * * *", "UTC")
+		test.That(t, err, test.ShouldBeNil)
+		at := func(hour, minute int) bool {
+			return s.Active(time.Date(2026, time.March, 2, hour, minute, 0, 0, time.UTC))
+		}
+		test.That(t, at(6, 0), test.ShouldBeTrue)
+		test.That(t, at(6, 14), test.ShouldBeTrue)
+		test.That(t, at(6, 15), test.ShouldBeFalse)
+		test.That(t, at(12, 30), test.ShouldBeTrue)
+		test.That(t, at(12, 45), test.ShouldBeTrue)
+		test.That(t, at(12, 44), test.ShouldBeFalse)
+		test.That(t, at(7, 0), test.ShouldBeFalse)
+	})
+
+	t.Run("either day field matches when both are set", func(t *testing.T) {
+		// the 1st of the month, or sundays
+		s, err := ParseSchedule("* * 1 * 0", "UTC")
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, s.Active(time.Date(2026, time.March, 1, 12, 0, 0, 0, time.UTC)), test.ShouldBeTrue)
+		test.That(t, s.Active(time.Date(2026, time.April, 1, 12, 0, 0, 0, time.UTC)), test.ShouldBeTrue)
+		test.That(t, s.Active(time.Date(2026, time.March, 8, 12, 0, 0, 0, time.UTC)), test.ShouldBeTrue)
+		test.That(t, s.Active(time.Date(2026, time.March, 9, 12, 0, 0, 0, time.UTC)), test.ShouldBeFalse)
+	})
+}
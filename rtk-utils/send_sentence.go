@@ -0,0 +1,71 @@
+package rtkutils
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// SendSentenceCommand writes an NMEA or PMTK "sentence" to the receiver of a rover, e.g. to enable
+// a sentence or change the output rate of a chip the rover doesn't configure itself. The checksum
+// is added, so the sentence is given without it.
+const SendSentenceCommand = "send_sentence"
+
+// linkSentences change the baud rate, ports or protocols of the receiver, or reset it to its
+// factory settings, after which the rover may not be able to read it anymore. They are only sent
+// with "force".
+var linkSentences = []string{
+	"PMTK104", // full cold start, clears the configuration
+	"PMTK251", // baud rate
+	"PMTK253", // switch to binary output
+	"PUBX,41", // u-blox port configuration
+}
+
+// NMEASentence frames the body of a sentence, e.g. "PMTK220,1000", with '$', its checksum and CRLF.
+// A leading '$' and a trailing checksum in body are replaced.
+func NMEASentence(body string) ([]byte, error) {
+	body = strings.TrimPrefix(strings.TrimSpace(body), "$")
+	if i := strings.IndexByte(body, '*'); i >= 0 {
+		body = body[:i]
+	}
+	if body == "" {
+		return nil, errors.New("the sentence is empty")
+	}
+	// '$' + body + '*' + checksum + CRLF
+	if len(body)+6 > maxNMEASentence {
+		return nil, fmt.Errorf("the sentence is longer than %d bytes", maxNMEASentence)
+	}
+
+	var checksum byte
+	for i := 0; i < len(body); i++ {
+		c := body[i]
+		if c < 0x20 || c > 0x7E || c == '$' || c == '!' {
+			return nil, fmt.Errorf("the sentence has an invalid character %q at %d", c, i)
+		}
+		checksum ^= c
+	}
+	return []byte(fmt.Sprintf("$%s*%02X\r\n", body, checksum)), nil
+}
+
+// SendSentence answers a SendSentenceCommand, writing the sentence to the receiver with write.
+func SendSentence(cmd map[string]interface{}, write func([]byte) error) (map[string]interface{}, error) {
+	body, ok := cmd["sentence"].(string)
+	if !ok {
+		return nil, errors.New("send_sentence needs a \"sentence\", e.g. \"PMTK220,1000\"")
+	}
+	sentence, err := NMEASentence(body)
+	if err != nil {
+		return nil, err
+	}
+	if force, _ := cmd["force"].(bool); !force {
+		for _, prefix := range linkSentences {
+			if strings.HasPrefix(string(sentence[1:]), prefix) {
+				return nil, fmt.Errorf("%s may cut the receiver off from the rover, set \"force\" to send it anyway", prefix)
+			}
+		}
+	}
+	if err := write(sentence); err != nil {
+		return nil, fmt.Errorf("failed to send %q: %w", strings.TrimSpace(string(sentence)), err)
+	}
+	return map[string]interface{}{"sent": strings.TrimSpace(string(sentence))}, nil
+}
@@ -0,0 +1,53 @@
+package rtkutils
+
+import (
+	"errors"
+	"testing"
+
+	"go.viam.com/test"
+)
+
+func TestNMEASentence(t *testing.T) {
+	sentence, err := NMEASentence("PMTK220,1000")
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, string(sentence), test.ShouldEqual, "$PMTK220,1000*1F\r\n")
+
+	// a copied sentence keeps its body, with the checksum recomputed
+	sentence, err = NMEASentence(" $PMTK220,1000*00 ")
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, string(sentence), test.ShouldEqual, "$PMTK220,1000*1F\r\n")
+
+	_, err = NMEASentence("$*1F")
+	test.That(t, err, test.ShouldBeError, errors.New("the sentence is empty"))
+	_, err = NMEASentence("PMTK220,1000\r\n$PMTK251,0")
+	test.That(t, err, test.ShouldBeError, errors.New("the sentence has an invalid character '\\r' at 12"))
+}
+
+func TestSendSentence(t *testing.T) {
+	var written []byte
+	write := func(msg []byte) error {
+		written = msg
+		return nil
+	}
+
+	resp, err := SendSentence(map[string]interface{}{"sentence": "PMTK220,200"}, write)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, resp, test.ShouldResemble, map[string]interface{}{"sent": "$PMTK220,200*2C"})
+	test.That(t, string(written), test.ShouldEqual, "$PMTK220,200*2C\r\n")
+
+	_, err = SendSentence(map[string]interface{}{}, write)
+	test.That(t, err, test.ShouldNotBeNil)
+
+	// a baud rate change is only sent with force
+	written = nil
+	_, err = SendSentence(map[string]interface{}{"sentence": "$PMTK251,115200"}, write)
+	test.That(t, err, test.ShouldBeError,
+		errors.New("PMTK251 may cut the receiver off from the rover, set \"force\" to send it anyway"))
+	test.That(t, written, test.ShouldBeNil)
+	_, err = SendSentence(map[string]interface{}{"sentence": "PMTK251,115200", "force": true}, write)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, string(written), test.ShouldEqual, "$PMTK251,115200*1F\r\n")
+
+	_, err = SendSentence(map[string]interface{}{"sentence": "PMTK220,200"}, func([]byte) error { return errors.New("port closed") })
+	test.That(t, err, test.ShouldBeError, errors.New("failed to send \"$PMTK220,200*2C\": port closed"))
+}
@@ -0,0 +1,177 @@
+package rtkutils
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// SentenceStatsCommand returns how many sentences of each type a rover read from its receiver, and
+// how many of them were parsed, ignored or failed to parse.
+const SentenceStatsCommand = "sentence_stats"
+
+// ErrUnsupportedSentence is returned for a well formed sentence of a type the rovers don't use, such
+// as ZDA, TXT or an unknown proprietary sentence. Such sentences are ignored rather than reported as
+// parse failures.
+var ErrUnsupportedSentence = errors.New("unsupported sentence type")
+
+// maxSentenceTypes caps the types a SentenceStats keeps apart, corrupted addresses would grow it forever.
+const maxSentenceTypes = 64
+
+// keys of the sentences counted without a type of their own.
+const (
+	sentenceTypeInvalid = "invalid" // no address at all
+	sentenceTypeOther   = "other"   // past maxSentenceTypes
+)
+
+// nmeaSupportedTypes are the sentence types the nmea parser updates the gps data from.
+var nmeaSupportedTypes = map[string]bool{
+	"GGA": true, "GLL": true, "GNS": true, "GSA": true, "GSV": true, "RMC": true, "VTG": true,
+}
+
+// unsupportedSentence returns ErrUnsupportedSentence for a sentence with a valid checksum whose
+// type the nmea parser doesn't update the gps data from, and nil otherwise.
+func unsupportedSentence(line string) error {
+	start := strings.IndexByte(line, '$')
+	if start == -1 {
+		return nil
+	}
+	body, checksum, ok := strings.Cut(strings.TrimSpace(line[start+1:]), "*")
+	if !ok || !validNMEAChecksum(body, checksum) {
+		return nil
+	}
+	address, _, _ := strings.Cut(body, ",")
+	if address == "" || len(address) == 5 && address[0] != 'P' && nmeaSupportedTypes[address[2:]] {
+		return nil
+	}
+	return fmt.Errorf("%w %s", ErrUnsupportedSentence, address)
+}
+
+// validNMEAChecksum reports whether checksum is the xor of the bytes of body, in hex.
+func validNMEAChecksum(body, checksum string) bool {
+	want, err := strconv.ParseUint(checksum, 16, 8)
+	if err != nil {
+		return false
+	}
+	var sum byte
+	for i := 0; i < len(body); i++ {
+		sum ^= body[i]
+	}
+	return sum == byte(want)
+}
+
+// SentenceCounts are the sentences of one type read from a receiver.
+type SentenceCounts struct {
+	Talker  string // GP, GN... of standard sentences, P of proprietary ones
+	Type    string
+	Parsed  uint64
+	Ignored uint64 // well formed, but of a type the rover doesn't use
+	Failed  uint64
+}
+
+// SentenceStats counts the sentences a rover reads by talker and type, so it shows what the receiver
+// actually outputs. The zero value is ready to use and safe for concurrent use.
+type SentenceStats struct {
+	mu     sync.Mutex
+	counts map[string]*SentenceCounts
+}
+
+// Record counts line by whether parsing it returned err: nil when parsed, ErrUnsupportedSentence
+// when ignored, or another error when it failed to parse.
+func (s *SentenceStats) Record(line string, err error) {
+	key, talker, typ := sentenceKey(line)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.counts == nil {
+		s.counts = map[string]*SentenceCounts{}
+	}
+	counts, ok := s.counts[key]
+	if !ok {
+		if len(s.counts) >= maxSentenceTypes-1 {
+			key, talker, typ = sentenceTypeOther, "", sentenceTypeOther
+			counts = s.counts[key]
+		}
+		if counts == nil {
+			counts = &SentenceCounts{Talker: talker, Type: typ}
+			s.counts[key] = counts
+		}
+	}
+	switch {
+	case err == nil:
+		counts.Parsed++
+	case errors.Is(err, ErrUnsupportedSentence):
+		counts.Ignored++
+	default:
+		counts.Failed++
+	}
+}
+
+// Get returns the counts of every type read so far, sorted by talker and type.
+func (s *SentenceStats) Get() []SentenceCounts {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	all := make([]SentenceCounts, 0, len(s.counts))
+	for _, counts := range s.counts {
+		all = append(all, *counts)
+	}
+	sort.Slice(all, func(i, j int) bool {
+		if all[i].Talker != all[j].Talker {
+			return all[i].Talker < all[j].Talker
+		}
+		return all[i].Type < all[j].Type
+	})
+	return all
+}
+
+// sentenceKey returns the address of line, e.g. GNGGA, PUBX or PPPNAVA for a Unicore log, split
+// into its talker and type.
+func sentenceKey(line string) (key, talker, typ string) {
+	start := strings.IndexAny(line, "$#")
+	if start == -1 {
+		return sentenceTypeInvalid, "", sentenceTypeInvalid
+	}
+	address := line[start+1:]
+	if end := strings.IndexAny(address, ",*\r\n"); end != -1 {
+		address = address[:end]
+	}
+	if address == "" || len(address) > 10 {
+		return sentenceTypeInvalid, "", sentenceTypeInvalid
+	}
+	switch {
+	case line[start] == '#':
+		return address, "", address
+	case address[0] == 'P':
+		return address, "P", address[1:]
+	case len(address) == 5:
+		return address, address[:2], address[2:]
+	default:
+		return address, "", address
+	}
+}
+
+// SentenceStatsResult answers a SentenceStatsCommand.
+func SentenceStatsResult(counts []SentenceCounts) map[string]interface{} {
+	sentences := []interface{}{}
+	var parsed, ignored, failed uint64
+	for _, c := range counts {
+		sentences = append(sentences, map[string]interface{}{
+			"talker":  c.Talker,
+			"type":    c.Type,
+			"parsed":  c.Parsed,
+			"ignored": c.Ignored,
+			"failed":  c.Failed,
+		})
+		parsed += c.Parsed
+		ignored += c.Ignored
+		failed += c.Failed
+	}
+	return map[string]interface{}{
+		"sentences": sentences,
+		"parsed":    parsed,
+		"ignored":   ignored,
+		"failed":    failed,
+	}
+}
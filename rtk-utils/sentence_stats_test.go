@@ -0,0 +1,81 @@
+package rtkutils
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"go.viam.com/test"
+)
+
+const (
+	testZDA    = "$GPZDA,201530.00,04,07,2002,00,00*60\r\n"
+	testTXT    = "$GPTXT,01,01,02,ANTSTATUS=OK*3B\r\n"
+	testPQTMVE = "$PQTMVER,MODULE_LC29HEANR*64\r\n"
+)
+
+func TestUnsupportedSentence(t *testing.T) {
+	test.That(t, unsupportedSentence(testGGAEpoch1), test.ShouldBeNil)
+	test.That(t, errors.Is(unsupportedSentence(testZDA), ErrUnsupportedSentence), test.ShouldBeTrue)
+	test.That(t, unsupportedSentence(testTXT), test.ShouldBeError, errors.New("unsupported sentence type GPTXT"))
+	test.That(t, errors.Is(unsupportedSentence(testPQTMVE), ErrUnsupportedSentence), test.ShouldBeTrue)
+
+	// a corrupted sentence is a parse failure
+	test.That(t, unsupportedSentence("$GPZDA,201530.00,04,07,2002,00,00*61"), test.ShouldBeNil)
+	test.That(t, unsupportedSentence("$GPZDA,201530.00,04,07,2002,00,00"), test.ShouldBeNil)
+	test.That(t, unsupportedSentence("garbage"), test.ShouldBeNil)
+}
+
+func TestEpochTrackerUnsupportedSentence(t *testing.T) {
+	var tracker EpochTracker
+	_, _, err := tracker.ParseAndUpdate(testGGAEpoch1)
+	test.That(t, err, test.ShouldBeNil)
+	_, _, err = tracker.ParseAndUpdate(testZDA)
+	test.That(t, errors.Is(err, ErrUnsupportedSentence), test.ShouldBeTrue)
+	_, _, err = tracker.ParseAndUpdate(testPUBX00)
+	test.That(t, err, test.ShouldBeNil)
+}
+
+func TestSentenceStats(t *testing.T) {
+	var stats SentenceStats
+	test.That(t, stats.Get(), test.ShouldBeEmpty)
+
+	stats.Record(testGGAEpoch1, nil)
+	stats.Record(testGGAEpoch2, nil)
+	stats.Record(testZDA, unsupportedSentence(testZDA))
+	stats.Record(testPUBX00, nil)
+	stats.Record("$GNGGA,bad*00", errors.New("bad checksum"))
+	stats.Record("#PPPNAVA,COM1;SOL_COMPUTED*00", nil)
+	stats.Record("\x00\x01", errors.New("no sentence"))
+
+	test.That(t, stats.Get(), test.ShouldResemble, []SentenceCounts{
+		{Type: "PPPNAVA", Parsed: 1},
+		{Type: "invalid", Failed: 1},
+		{Talker: "GN", Type: "GGA", Failed: 1},
+		{Talker: "GP", Type: "GGA", Parsed: 2},
+		{Talker: "GP", Type: "ZDA", Ignored: 1},
+		{Talker: "P", Type: "UBX", Parsed: 1},
+	})
+
+	result := SentenceStatsResult(stats.Get())
+	test.That(t, result["parsed"], test.ShouldEqual, uint64(4))
+	test.That(t, result["ignored"], test.ShouldEqual, uint64(1))
+	test.That(t, result["failed"], test.ShouldEqual, uint64(2))
+	test.That(t, result["sentences"], test.ShouldHaveLength, 6)
+}
+
+func TestSentenceStatsCapsTypes(t *testing.T) {
+	var stats SentenceStats
+	for i := 0; i < maxSentenceTypes+10; i++ {
+		stats.Record(fmt.Sprintf("$X%04d,", i), errors.New("corrupted"))
+	}
+	counts := stats.Get()
+	test.That(t, counts, test.ShouldHaveLength, maxSentenceTypes)
+	var other SentenceCounts
+	for _, c := range counts {
+		if c.Type == sentenceTypeOther {
+			other = c
+		}
+	}
+	test.That(t, other.Failed, test.ShouldEqual, 11)
+}
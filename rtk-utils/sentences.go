@@ -0,0 +1,52 @@
+package rtkutils
+
+import "time"
+
+// maxNMEASentence is the longest sentence NMEASplitter gathers. Standard sentences are at most 82
+// bytes, proprietary ones a little longer; anything longer is garbage that lost its line ending.
+const maxNMEASentence = 256
+
+// nmeaPartialTimeout is how long a cut off sentence waits for the rest of it. The sentences of an
+// epoch are sent together, so a rest arriving later belongs to another sentence.
+const nmeaPartialTimeout = time.Second
+
+// NMEASplitter splits the bytes read from a receiver buffer into NMEA sentences. A sentence may be
+// cut off by the end of one read and completed by the next. The bytes of a sentence are gathered in
+// a buffer that is reused for every sentence, so splitting only allocates the sentences themselves.
+type NMEASplitter struct {
+	buf     []byte
+	updated time.Time // when the sentence being read was last added to
+}
+
+// Split adds data read at now to the sentence being read and calls f with every sentence it completes.
+// Sentences start with '$', or '#' for the logs of Unicore receivers. PMTK uses CRLF line endings to terminate sentences, but just LF to
+// blank data. Since CR should never appear except at the end of a sentence, it is used to determine
+// the sentence end. LF and 0xFF idle padding are ignored, as is anything outside of a sentence.
+// A sentence that starts before the previous one ended is read on its own, instead of being merged
+// into the start of the previous one. The start of a sentence is dropped if the rest of it doesn't
+// follow within a second.
+func (s *NMEASplitter) Split(data []byte, now time.Time, f func(sentence string)) {
+	if now.Sub(s.updated) > nmeaPartialTimeout {
+		s.Reset()
+	}
+	s.updated = now
+	for _, b := range data {
+		switch {
+		case b == '$', b == '#':
+			s.buf = append(s.buf[:0], b)
+		case len(s.buf) == 0, b == '\n', b == 0xFF:
+		case b == '\r':
+			f(string(s.buf))
+			s.buf = s.buf[:0]
+		case len(s.buf) == maxNMEASentence:
+			s.buf = s.buf[:0]
+		default:
+			s.buf = append(s.buf, b)
+		}
+	}
+}
+
+// Reset drops the sentence being read, e.g. after a failed read left a gap in the data.
+func (s *NMEASplitter) Reset() {
+	s.buf = s.buf[:0]
+}
@@ -0,0 +1,123 @@
+package rtkutils
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"go.viam.com/test"
+)
+
+// testI2CBuffer returns a receiver buffer holding an epoch of sentences, cut off partway through
+// the last one, and padded with idle bytes.
+func testI2CBuffer() []byte {
+	buf := []byte(testGGAEpoch1 + "\r\n" + testGSAEpoch1 + "\r\n\n" + testGGAEpoch2[:20])
+	return append(buf, bytes.Repeat([]byte{0xFF}, 1024-len(buf))...)
+}
+
+func TestNMEASplitter(t *testing.T) {
+	var s NMEASplitter
+	now := time.Now()
+	var sentences []string
+	collect := func(sentence string) { sentences = append(sentences, sentence) }
+
+	s.Split(testI2CBuffer(), now, collect)
+	test.That(t, sentences, test.ShouldResemble, []string{testGGAEpoch1, testGSAEpoch1})
+
+	// the cut off sentence is complete once the rest of it is read
+	sentences = nil
+	s.Split([]byte(testGGAEpoch2[20:]+"\r\n"), now, collect)
+	test.That(t, sentences, test.ShouldResemble, []string{testGGAEpoch2})
+
+	// a sentence that lost its end isn't merged into the next one
+	sentences = nil
+	s.Split([]byte("\xb5b\x02$GPGGA,1728"), now, collect)
+	s.Split([]byte(testGSAEpoch1+"\r\n"), now, collect)
+	test.That(t, sentences, test.ShouldResemble, []string{testGSAEpoch1})
+
+	// neither is garbage that never ends
+	sentences = nil
+	s.Split(append([]byte("$"), bytes.Repeat([]byte{'A'}, 2*maxNMEASentence)...), now, collect)
+	s.Split([]byte("\r\n"+testGSAEpoch1+"\r\n"), now, collect)
+	test.That(t, sentences, test.ShouldResemble, []string{testGSAEpoch1})
+
+	// or the start of a sentence from before a failed read
+	sentences = nil
+	s.Split([]byte(testGGAEpoch2[:20]), now, collect)
+	s.Reset()
+	s.Split([]byte(testGGAEpoch2[20:]+"\r\n"), now, collect)
+	test.That(t, sentences, test.ShouldBeEmpty)
+
+	// or one whose rest came too late
+	s.Split([]byte(testGGAEpoch2[:20]), now, collect)
+	s.Split([]byte(testGGAEpoch2[20:]+"\r\n"), now.Add(2*time.Second), collect)
+	test.That(t, sentences, test.ShouldBeEmpty)
+
+	// unicore logs start with '#'
+	s.Split([]byte(testPPPNAV+"\r\n"+testGSAEpoch1+"\r\n"), now, collect)
+	test.That(t, sentences, test.ShouldResemble, []string{testPPPNAV, testGSAEpoch1})
+
+	// only the sentences themselves are allocated
+	buf := testI2CBuffer()
+	allocs := testing.AllocsPerRun(100, func() {
+		s.Split(buf, now, func(string) {})
+	})
+	test.That(t, allocs, test.ShouldBeLessThanOrEqualTo, 2)
+}
+
+func TestNMEASplitterReadBoundaries(t *testing.T) {
+	stream := testGGAEpoch1 + "\r\n" + testGSAEpoch1 + "\r\n" + testGGAEpoch2 + "\r\n"
+	now := time.Now()
+
+	// cut the stream into two reads at every byte, as a receiver buffer that filled up would
+	for cut := 0; cut <= len(stream); cut++ {
+		var s NMEASplitter
+		var sentences []string
+		collect := func(sentence string) { sentences = append(sentences, sentence) }
+		first := append([]byte(stream[:cut]), 0xFF, 0xFF)
+		s.Split(first, now, collect)
+		s.Split([]byte(stream[cut:]), now.Add(50*time.Millisecond), collect)
+		test.That(t, sentences, test.ShouldResemble, []string{testGGAEpoch1, testGSAEpoch1, testGGAEpoch2})
+	}
+
+	// and into reads of a few bytes
+	var s NMEASplitter
+	var sentences []string
+	for i := 0; i < len(stream); i += 7 {
+		end := i + 7
+		if end > len(stream) {
+			end = len(stream)
+		}
+		s.Split([]byte(stream[i:end]), now, func(sentence string) { sentences = append(sentences, sentence) })
+	}
+	test.That(t, sentences, test.ShouldResemble, []string{testGGAEpoch1, testGSAEpoch1, testGGAEpoch2})
+}
+
+func BenchmarkNMEASplitter(b *testing.B) {
+	buf := testI2CBuffer()
+	var s NMEASplitter
+	now := time.Now()
+	b.ReportAllocs()
+	b.SetBytes(int64(len(buf)))
+	for i := 0; i < b.N; i++ {
+		s.Split(buf, now, func(string) {})
+	}
+}
+
+// BenchmarkNMEAConcatenation is the byte by byte string concatenation NMEASplitter replaced, kept
+// to compare against.
+func BenchmarkNMEAConcatenation(b *testing.B) {
+	buf := testI2CBuffer()
+	strBuf := ""
+	b.ReportAllocs()
+	b.SetBytes(int64(len(buf)))
+	for i := 0; i < b.N; i++ {
+		for _, c := range buf {
+			if c == '\r' {
+				strBuf = ""
+			} else if c != '\n' && c != 0xFF {
+				strBuf += string(c)
+			}
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package rtkutils
+
+import (
+	"encoding/binary"
+
+	"github.com/go-gnss/rtcm/rtcm3"
+)
+
+const (
+	ubxGnssGPS         = 0
+	sfrbxHeaderLen     = 8
+	lnavWords          = 10
+	lnavEphemerisFrame = 0x07 // subframes 1 to 3
+)
+
+// lnavSubframes are the 24 data bits of each word of GPS LNAV subframes 1 to 3.
+type lnavSubframes [3][lnavWords]uint32
+
+// GPSSubframes collects the GPS LNAV subframes u-blox receivers output in UBX-RXM-SFRBX messages
+// until they make up the ephemeris of a satellite, since u-blox receivers can't output ephemerides
+// as rtcm.
+type GPSSubframes struct {
+	subframes map[uint8]*lnavSubframes
+	received  map[uint8]uint8 // bit mask of the subframes received, by satellite
+}
+
+// Add adds the subframe of an RXM-SFRBX payload and returns the ephemeris of its satellite once
+// its subframes 1 to 3 are from the same issue of data.
+func (g *GPSSubframes) Add(payload []byte) (rtcm3.Message1019, bool) {
+	if len(payload) < sfrbxHeaderLen+4*lnavWords || payload[0] != ubxGnssGPS || payload[4] != lnavWords {
+		return rtcm3.Message1019{}, false
+	}
+	if g.subframes == nil {
+		g.subframes = map[uint8]*lnavSubframes{}
+		g.received = map[uint8]uint8{}
+	}
+	var words [lnavWords]uint32
+	for i := range words {
+		// the 30 bit words are right aligned, with their 6 parity bits last
+		words[i] = binary.LittleEndian.Uint32(payload[sfrbxHeaderLen+4*i:]) >> 6 & 0xFFFFFF
+	}
+	id := lnavBits(&words, 2, 20, 3)
+	if id < 1 || id > 3 {
+		return rtcm3.Message1019{}, false
+	}
+
+	sv := payload[1]
+	frames, ok := g.subframes[sv]
+	if !ok {
+		frames = &lnavSubframes{}
+		g.subframes[sv] = frames
+	}
+	frames[id-1] = words
+	g.received[sv] |= 1 << (id - 1)
+	if g.received[sv] != lnavEphemerisFrame {
+		return rtcm3.Message1019{}, false
+	}
+	return decodeLNAV(sv, frames)
+}
+
+// decodeLNAV decodes the ephemeris of satellite sv from its subframes 1 to 3, following IS-GPS-200,
+// and returns false if they aren't from the same issue of data.
+func decodeLNAV(sv uint8, frames *lnavSubframes) (rtcm3.Message1019, bool) {
+	sf1, sf2, sf3 := &frames[0], &frames[1], &frames[2]
+	iodc := lnavBits(sf1, 3, 23, 2)<<8 | lnavBits(sf1, 8, 1, 8)
+	iode := lnavBits(sf2, 3, 1, 8)
+	if iode != lnavBits(sf3, 10, 1, 8) || iode != iodc&0xFF {
+		return rtcm3.Message1019{}, false
+	}
+	return rtcm3.Message1019{
+		AbstractMessage: rtcm3.AbstractMessage{MessageNumber: 1019},
+		SatelliteID:     sv,
+		WeekNumber:      uint16(lnavBits(sf1, 3, 1, 10)),
+		CodeOnL2:        uint8(lnavBits(sf1, 3, 11, 2)),
+		SVAccuracy:      uint8(lnavBits(sf1, 3, 13, 4)),
+		SVHealth:        uint8(lnavBits(sf1, 3, 17, 6)),
+		IODC:            uint16(iodc),
+		L2PDataFlag:     lnavBits(sf1, 4, 1, 1) == 1,
+		Tgd:             int8(lnavBits(sf1, 7, 17, 8)),
+		Toc:             uint16(lnavBits(sf1, 8, 9, 16)),
+		Af2:             int8(lnavBits(sf1, 9, 1, 8)),
+		Af1:             int16(lnavBits(sf1, 9, 9, 16)),
+		Af0:             lnavSigned(lnavBits(sf1, 10, 1, 22), 22),
+		IODE:            uint8(iode),
+		Crs:             int16(lnavBits(sf2, 3, 9, 16)),
+		DeltaN:          int16(lnavBits(sf2, 4, 1, 16)),
+		M0:              int32(lnavSplit(sf2, 4)),
+		Cuc:             int16(lnavBits(sf2, 6, 1, 16)),
+		Eccentricity:    lnavSplit(sf2, 6),
+		Cus:             int16(lnavBits(sf2, 8, 1, 16)),
+		SrA:             lnavSplit(sf2, 8),
+		Toe:             uint16(lnavBits(sf2, 10, 1, 16)),
+		FitInterval:     lnavBits(sf2, 10, 17, 1) == 1,
+		Cic:             int16(lnavBits(sf3, 3, 1, 16)),
+		Omega0:          int32(lnavSplit(sf3, 3)),
+		Cis:             int16(lnavBits(sf3, 5, 1, 16)),
+		I0:              int32(lnavSplit(sf3, 5)),
+		Crc:             int16(lnavBits(sf3, 7, 1, 16)),
+		Perigee:         int32(lnavSplit(sf3, 7)),
+		OmegaDot:        lnavSigned(lnavBits(sf3, 9, 1, 24), 24),
+		IDOT:            int16(lnavSigned(lnavBits(sf3, 10, 9, 14), 14)),
+	}, true
+}
+
+// lnavBits returns n bits of word, numbered from 1 like IS-GPS-200, starting at bit start.
+func lnavBits(words *[lnavWords]uint32, word, start, n int) uint32 {
+	return words[word-1] >> (24 - (start - 1) - n) & (1<<n - 1)
+}
+
+// lnavSplit returns a 32 bit value split over the last 8 bits of word and all 24 of the next one.
+func lnavSplit(words *[lnavWords]uint32, word int) uint32 {
+	return lnavBits(words, word, 17, 8)<<24 | lnavBits(words, word+1, 1, 24)
+}
+
+// lnavSigned returns the two's complement value of the n bits of v.
+func lnavSigned(v uint32, n int) int32 {
+	return int32(v<<(32-n)) >> (32 - n)
+}
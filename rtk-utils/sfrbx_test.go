@@ -0,0 +1,109 @@
+package rtkutils
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/go-gnss/rtcm/rtcm3"
+	"go.viam.com/test"
+)
+
+// setLNAVBits sets n bits of word, numbered from 1 like IS-GPS-200, starting at bit start.
+func setLNAVBits(words *[lnavWords]uint32, word, start, n int, v uint32) {
+	shift := 24 - (start - 1) - n
+	mask := uint32(1<<n-1) << shift
+	words[word-1] = words[word-1]&^mask | v<<shift&mask
+}
+
+func setLNAVSplit(words *[lnavWords]uint32, word int, v uint32) {
+	setLNAVBits(words, word, 17, 8, v>>24)
+	setLNAVBits(words, word+1, 1, 24, v)
+}
+
+// sfrbxPayloads encodes an ephemeris as the RXM-SFRBX payloads of its subframes 1 to 3.
+func sfrbxPayloads(eph rtcm3.Message1019) [][]byte {
+	var frames lnavSubframes
+	sf1, sf2, sf3 := &frames[0], &frames[1], &frames[2]
+	setLNAVBits(sf1, 3, 1, 10, uint32(eph.WeekNumber))
+	setLNAVBits(sf1, 3, 11, 2, uint32(eph.CodeOnL2))
+	setLNAVBits(sf1, 3, 13, 4, uint32(eph.SVAccuracy))
+	setLNAVBits(sf1, 3, 17, 6, uint32(eph.SVHealth))
+	setLNAVBits(sf1, 3, 23, 2, uint32(eph.IODC>>8))
+	setLNAVBits(sf1, 7, 17, 8, uint32(eph.Tgd))
+	setLNAVBits(sf1, 8, 1, 8, uint32(eph.IODC))
+	setLNAVBits(sf1, 8, 9, 16, uint32(eph.Toc))
+	setLNAVBits(sf1, 9, 1, 8, uint32(eph.Af2))
+	setLNAVBits(sf1, 9, 9, 16, uint32(eph.Af1))
+	setLNAVBits(sf1, 10, 1, 22, uint32(eph.Af0))
+	setLNAVBits(sf2, 3, 1, 8, uint32(eph.IODE))
+	setLNAVBits(sf2, 3, 9, 16, uint32(eph.Crs))
+	setLNAVBits(sf2, 4, 1, 16, uint32(eph.DeltaN))
+	setLNAVSplit(sf2, 4, uint32(eph.M0))
+	setLNAVBits(sf2, 6, 1, 16, uint32(eph.Cuc))
+	setLNAVSplit(sf2, 6, eph.Eccentricity)
+	setLNAVBits(sf2, 8, 1, 16, uint32(eph.Cus))
+	setLNAVSplit(sf2, 8, eph.SrA)
+	setLNAVBits(sf2, 10, 1, 16, uint32(eph.Toe))
+	setLNAVBits(sf3, 3, 1, 16, uint32(eph.Cic))
+	setLNAVSplit(sf3, 3, uint32(eph.Omega0))
+	setLNAVBits(sf3, 5, 1, 16, uint32(eph.Cis))
+	setLNAVSplit(sf3, 5, uint32(eph.I0))
+	setLNAVBits(sf3, 7, 1, 16, uint32(eph.Crc))
+	setLNAVSplit(sf3, 7, uint32(eph.Perigee))
+	setLNAVBits(sf3, 9, 1, 24, uint32(eph.OmegaDot))
+	setLNAVBits(sf3, 10, 1, 8, uint32(eph.IODE))
+	setLNAVBits(sf3, 10, 9, 14, uint32(eph.IDOT))
+
+	var payloads [][]byte
+	for i := range frames {
+		setLNAVBits(&frames[i], 2, 20, 3, uint32(i+1))
+		payload := make([]byte, sfrbxHeaderLen+4*lnavWords)
+		payload[0], payload[1], payload[4] = ubxGnssGPS, eph.SatelliteID, lnavWords
+		for j, word := range frames[i] {
+			// random parity bits and padding, that are dropped
+			binary.LittleEndian.PutUint32(payload[sfrbxHeaderLen+4*j:], 0xC000_0000|word<<6|0x2A)
+		}
+		payloads = append(payloads, payload)
+	}
+	return payloads
+}
+
+func TestGPSSubframes(t *testing.T) {
+	eph := testEphemeris(7)
+	eph.WeekNumber = 250
+	eph.IODC, eph.IODE = 0x155, 0x55
+	eph.Af0, eph.Af1, eph.Tgd = -12345, -7, -3
+	eph.DeltaN, eph.Crs, eph.Cic = 4000, -900, -12
+	eph.Perigee, eph.OmegaDot, eph.IDOT = -1<<30, -23000, -300
+	payloads := sfrbxPayloads(eph)
+
+	var g GPSSubframes
+	_, ok := g.Add(payloads[0])
+	test.That(t, ok, test.ShouldBeFalse)
+	_, ok = g.Add(payloads[2])
+	test.That(t, ok, test.ShouldBeFalse)
+	decoded, ok := g.Add(payloads[1])
+	test.That(t, ok, test.ShouldBeTrue)
+	test.That(t, decoded, test.ShouldResemble, eph)
+
+	// a new issue of data is only decoded once all its subframes are in
+	next := eph
+	next.IODC, next.IODE = 0x156, 0x56
+	next.Toe++
+	payloads = sfrbxPayloads(next)
+	_, ok = g.Add(payloads[1])
+	test.That(t, ok, test.ShouldBeFalse)
+	_, ok = g.Add(payloads[2])
+	test.That(t, ok, test.ShouldBeFalse)
+	decoded, ok = g.Add(payloads[0])
+	test.That(t, ok, test.ShouldBeTrue)
+	test.That(t, decoded, test.ShouldResemble, next)
+
+	// other constellations and subframes are ignored
+	galileo := append([]byte{}, payloads[0]...)
+	galileo[0] = 2
+	_, ok = g.Add(galileo)
+	test.That(t, ok, test.ShouldBeFalse)
+	_, ok = g.Add(payloads[0][:20])
+	test.That(t, ok, test.ShouldBeFalse)
+}
@@ -0,0 +1,121 @@
+package rtkutils
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"go.uber.org/multierr"
+)
+
+// DefaultShutdownTimeout bounds how long a component waits for its background workers when closing.
+const DefaultShutdownTimeout = 5 * time.Second
+
+var errShutdownTimeout = errors.New("timed out waiting for resources to close")
+
+// closer is implemented by every resource in the module.
+type closer interface {
+	Close(ctx context.Context) error
+}
+
+var (
+	moduleCtx, cancelModule = context.WithCancel(context.Background())
+
+	trackedMu sync.Mutex
+	tracked   = map[closer]struct{}{}
+)
+
+// ModuleContext is canceled when the module shuts down. Components derive the context of
+// their background workers from it so that every worker stops together.
+func ModuleContext() context.Context {
+	return moduleCtx
+}
+
+// TrackResource registers a resource to be closed when the module shuts down.
+func TrackResource(c closer) {
+	trackedMu.Lock()
+	defer trackedMu.Unlock()
+	tracked[c] = struct{}{}
+}
+
+// UntrackResource removes a resource registered with TrackResource. Resources call it from Close.
+func UntrackResource(c closer) {
+	trackedMu.Lock()
+	defer trackedMu.Unlock()
+	delete(tracked, c)
+}
+
+// Shutdown cancels the module context and closes every tracked resource concurrently, so that
+// serial and i2c handles are released before the process exits. It returns once every resource
+// is closed or ctx is done. The robot may close the resources again as the module stops, which
+// their CloseOnce makes harmless.
+func Shutdown(ctx context.Context) error {
+	cancelModule()
+
+	trackedMu.Lock()
+	resources := make([]closer, 0, len(tracked))
+	for c := range tracked {
+		resources = append(resources, c)
+	}
+	tracked = map[closer]struct{}{}
+	trackedMu.Unlock()
+
+	var (
+		wg    sync.WaitGroup
+		errMu sync.Mutex
+		errs  error
+	)
+	for _, c := range resources {
+		wg.Add(1)
+		go func(c closer) {
+			defer wg.Done()
+			err := c.Close(ctx)
+			errMu.Lock()
+			errs = multierr.Combine(errs, err)
+			errMu.Unlock()
+		}(c)
+	}
+
+	if !WaitWithTimeout(ctx, &wg, 0) {
+		return multierr.Combine(errShutdownTimeout, ctx.Err())
+	}
+	return errs
+}
+
+// CloseOnce makes the Close of a resource safe to call more than once, as it is when the module
+// shuts down a resource the robot closes too: the close runs the first time, later calls return
+// its error.
+type CloseOnce struct {
+	once sync.Once
+	err  error
+}
+
+// Close runs close the first time it is called, and returns its error every time.
+func (c *CloseOnce) Close(close func() error) error {
+	c.once.Do(func() { c.err = close() })
+	return c.err
+}
+
+// WaitWithTimeout waits for wg, giving up when ctx is done or after timeout if it is positive.
+// It reports whether wg finished.
+func WaitWithTimeout(ctx context.Context, wg *sync.WaitGroup, timeout time.Duration) bool {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
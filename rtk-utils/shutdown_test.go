@@ -0,0 +1,39 @@
+package rtkutils
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"go.viam.com/test"
+)
+
+func TestWaitWithTimeout(t *testing.T) {
+	var wg sync.WaitGroup
+	wg.Add(1)
+
+	// a worker that never finishes should not block the caller past the timeout
+	test.That(t, WaitWithTimeout(context.Background(), &wg, 10*time.Millisecond), test.ShouldBeFalse)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	test.That(t, WaitWithTimeout(ctx, &wg, 0), test.ShouldBeFalse)
+
+	wg.Done()
+	test.That(t, WaitWithTimeout(context.Background(), &wg, 10*time.Millisecond), test.ShouldBeTrue)
+}
+
+func TestCloseOnce(t *testing.T) {
+	var c CloseOnce
+	closes := 0
+	close := func() error {
+		closes++
+		return errors.New("port already closed")
+	}
+
+	test.That(t, c.Close(close), test.ShouldBeError, errors.New("port already closed"))
+	test.That(t, c.Close(close), test.ShouldBeError, errors.New("port already closed"))
+	test.That(t, closes, test.ShouldEqual, 1)
+}
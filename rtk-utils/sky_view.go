@@ -0,0 +1,271 @@
+package rtkutils
+
+import (
+	"math"
+	"math/bits"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/go-gnss/rtcm/rtcm3"
+)
+
+// constellations not observed for this long are left out of the sky view
+const skyViewMaxAge = 10 * time.Second
+
+// msmConstellations are the constellations of the msm messages, by tens from 1071.
+var msmConstellations = []string{"gps", "glonass", "galileo", "sbas", "qzss", "beidou"}
+
+// cnrBuckets are the C/N0 histogram buckets by upper bound in dB-Hz. An antenna with a clear view of
+// the sky sees most satellites above 40.
+var cnrBuckets = []struct {
+	label string
+	below float64
+}{
+	{"<20", 20},
+	{"20-30", 30},
+	{"30-40", 40},
+	{"40-50", 50},
+	{"50+", math.Inf(1)},
+}
+
+// SkyView reports the satellites a station's receiver observes, their carrier to noise density (C/N0)
+// and the dilution of precision (DOP) of the GPS satellites, so that installers can check where a base
+// antenna is placed. It reads the MSM4 and MSM7 observations a StreamDecoder passes it. The DOP also
+// needs the base position of the 1005/1006 messages and the GPS ephemerides of the stream.
+type SkyView struct {
+	mu           sync.Mutex
+	epochs       map[string]skyEpoch // latest observations, by constellation
+	ephemerides  map[uint8]rtcm3.Message1019
+	reference    [3]float64 // ECEF in m
+	hasReference bool
+	dop          dilution
+	dopTime      time.Time
+}
+
+type skyEpoch struct {
+	time time.Time
+	cnrs []float64 // C/N0 in dB-Hz of each satellite observed, 0 if it isn't reported
+}
+
+type dilution struct {
+	h, v, p float64
+}
+
+// NewSkyView returns a sky view with no observations yet.
+func NewSkyView() *SkyView {
+	return &SkyView{epochs: map[string]skyEpoch{}, ephemerides: map[uint8]rtcm3.Message1019{}}
+}
+
+// Update adds a message of the station's stream to the sky view.
+func (s *SkyView) Update(msg rtcm3.Message) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	switch msg := msg.(type) {
+	case rtcm3.Message1005:
+		s.setReference(msg.AntennaReferencePoint)
+	case rtcm3.Message1006:
+		s.setReference(msg.AntennaReferencePoint)
+	case rtcm3.Message1019:
+		s.ephemerides[msg.SatelliteID] = msg
+	case rtcm3.Message1074:
+		s.addMSM4(msg.MessageMsm4, now)
+	case rtcm3.Message1084:
+		s.addMSM4(msg.MessageMsm4, now)
+	case rtcm3.Message1094:
+		s.addMSM4(msg.MessageMsm4, now)
+	case rtcm3.Message1104:
+		s.addMSM4(msg.MessageMsm4, now)
+	case rtcm3.Message1114:
+		s.addMSM4(msg.MessageMsm4, now)
+	case rtcm3.Message1124:
+		s.addMSM4(msg.MessageMsm4, now)
+	case rtcm3.Message1077:
+		s.addMSM7(msg.MessageMsm7, now)
+	case rtcm3.Message1087:
+		s.addMSM7(msg.MessageMsm7, now)
+	case rtcm3.Message1097:
+		s.addMSM7(msg.MessageMsm7, now)
+	case rtcm3.Message1107:
+		s.addMSM7(msg.MessageMsm7, now)
+	case rtcm3.Message1117:
+		s.addMSM7(msg.MessageMsm7, now)
+	case rtcm3.Message1127:
+		s.addMSM7(msg.MessageMsm7, now)
+	}
+}
+
+// setReference sets the position the satellites are seen from. The caller must hold mu.
+func (s *SkyView) setReference(arp rtcm3.AntennaReferencePoint) {
+	s.reference = [3]float64{
+		float64(arp.ReferencePointX) * 1e-4,
+		float64(arp.ReferencePointY) * 1e-4,
+		float64(arp.ReferencePointZ) * 1e-4,
+	}
+	s.hasReference = true
+}
+
+// addMSM4 adds an MSM4 epoch, whose C/N0 are in dB-Hz. The caller must hold mu.
+func (s *SkyView) addMSM4(msg rtcm3.MessageMsm4, now time.Time) {
+	cnrs := make([]float64, len(msg.SignalData.Cnrs))
+	for i, cnr := range msg.SignalData.Cnrs {
+		cnrs[i] = float64(cnr)
+	}
+	s.addEpoch(msg.MsmHeader, cnrs, now)
+}
+
+// addMSM7 adds an MSM7 epoch, whose C/N0 are in 2^-4 dB-Hz. The caller must hold mu.
+func (s *SkyView) addMSM7(msg rtcm3.MessageMsm7, now time.Time) {
+	cnrs := make([]float64, len(msg.SignalData.Cnrs))
+	for i, cnr := range msg.SignalData.Cnrs {
+		cnrs[i] = float64(cnr) / 16
+	}
+	s.addEpoch(msg.MsmHeader, cnrs, now)
+}
+
+// addEpoch keeps the C/N0 of the first signal of each satellite of an msm epoch, from the C/N0 of
+// its cells, and updates the DOP with GPS epochs. The caller must hold mu.
+func (s *SkyView) addEpoch(header rtcm3.MsmHeader, cells []float64, now time.Time) {
+	constellation := (int(header.MessageNumber) - 1071) / 10
+	if constellation < 0 || constellation >= len(msmConstellations) {
+		return
+	}
+	sats := msmSatellites(header)
+	nsig := bits.OnesCount32(header.SignalMask)
+	ncell := len(sats) * nsig
+
+	cnrs := make([]float64, len(sats))
+	cell := 0
+	for i := range sats {
+		for j := 0; j < nsig; j++ {
+			if header.CellMask&(1<<(ncell-1-(i*nsig+j))) == 0 {
+				continue
+			}
+			if cell < len(cells) && cnrs[i] == 0 {
+				cnrs[i] = cells[cell]
+			}
+			cell++
+		}
+	}
+	s.epochs[msmConstellations[constellation]] = skyEpoch{time: now, cnrs: cnrs}
+
+	if constellation == 0 && s.hasReference {
+		if dop, ok := gpsDOP(sats, float64(header.Epoch)/1000, s.reference, s.ephemerides); ok {
+			s.dop, s.dopTime = dop, now
+		}
+	}
+}
+
+// Readings returns the satellites observed in the last few seconds, in total and by constellation, the
+// minimum, median and maximum of their C/N0 and a histogram of it, and the DOP once it is known.
+func (s *SkyView) Readings(now time.Time) map[string]interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	satellites := 0
+	byConstellation := map[string]interface{}{}
+	var cnrs []float64
+	for constellation, epoch := range s.epochs {
+		if now.Sub(epoch.time) > skyViewMaxAge {
+			continue
+		}
+		satellites += len(epoch.cnrs)
+		byConstellation[constellation] = len(epoch.cnrs)
+		for _, cnr := range epoch.cnrs {
+			if cnr > 0 {
+				cnrs = append(cnrs, cnr)
+			}
+		}
+	}
+	readings := map[string]interface{}{
+		"satellites":                  satellites,
+		"satellites_by_constellation": byConstellation,
+	}
+
+	if len(cnrs) > 0 {
+		sort.Float64s(cnrs)
+		readings["cnr_dbhz"] = map[string]interface{}{
+			"min":    cnrs[0],
+			"median": cnrs[len(cnrs)/2],
+			"max":    cnrs[len(cnrs)-1],
+		}
+		counts := make([]int, len(cnrBuckets))
+		for _, cnr := range cnrs {
+			for i, bucket := range cnrBuckets {
+				if cnr < bucket.below {
+					counts[i]++
+					break
+				}
+			}
+		}
+		histogram := map[string]interface{}{}
+		for i, bucket := range cnrBuckets {
+			histogram[bucket.label] = counts[i]
+		}
+		readings["cnr_histogram"] = histogram
+	}
+
+	if !s.dopTime.IsZero() && now.Sub(s.dopTime) <= skyViewMaxAge {
+		readings["hDOP"] = s.dop.h
+		readings["vDOP"] = s.dop.v
+		readings["pDOP"] = s.dop.p
+	}
+	return readings
+}
+
+// gpsDOP returns the dilution of precision of the healthy GPS satellites above the horizon of a
+// receiver at GPS time of week tow, and false if fewer than 4 have an ephemeris.
+func gpsDOP(sats []int, tow float64, receiver [3]float64, ephemerides map[uint8]rtcm3.Message1019) (dilution, bool) {
+	up := ellipsoidNormal(receiver)
+	horizontal := math.Hypot(up[0], up[1])
+	east := [3]float64{-up[1] / horizontal, up[0] / horizontal, 0}
+	north := [3]float64{
+		up[1]*east[2] - up[2]*east[1],
+		up[2]*east[0] - up[0]*east[2],
+		up[0]*east[1] - up[1]*east[0],
+	}
+	dot := func(a, b [3]float64) float64 { return a[0]*b[0] + a[1]*b[1] + a[2]*b[2] }
+
+	var normal [4][5]float64
+	used := 0
+	for _, sat := range sats {
+		eph, ok := ephemerides[uint8(sat)]
+		if !ok || eph.SVHealth != 0 {
+			continue
+		}
+		pos, ok := gpsSatellitePosition(eph, tow)
+		if !ok {
+			continue
+		}
+		r := distance(pos, receiver)
+		los := [3]float64{(pos[0] - receiver[0]) / r, (pos[1] - receiver[1]) / r, (pos[2] - receiver[2]) / r}
+		if dot(los, up) <= 0 {
+			continue
+		}
+		row := [4]float64{-dot(los, east), -dot(los, north), -dot(los, up), 1}
+		for a := 0; a < 4; a++ {
+			for b := 0; b < 4; b++ {
+				normal[a][b] += row[a] * row[b]
+			}
+		}
+		used++
+	}
+	if used < 4 {
+		return dilution{}, false
+	}
+
+	// the diagonal of the inverse of the normal matrix, in east, north, up and clock order
+	var q [4]float64
+	for k := 0; k < 4; k++ {
+		m := normal
+		m[k][4] = 1
+		x, ok := solveLinear(m)
+		if !ok {
+			return dilution{}, false
+		}
+		q[k] = x[k]
+	}
+	return dilution{h: math.Sqrt(q[0] + q[1]), v: math.Sqrt(q[2]), p: math.Sqrt(q[0] + q[1] + q[2])}, true
+}
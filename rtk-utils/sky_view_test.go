@@ -0,0 +1,60 @@
+package rtkutils
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/go-gnss/rtcm/rtcm3"
+	"go.viam.com/test"
+)
+
+func TestSkyView(t *testing.T) {
+	s := NewSkyView()
+	now := time.Now()
+	test.That(t, s.Readings(now), test.ShouldResemble, map[string]interface{}{
+		"satellites":                  0,
+		"satellites_by_constellation": map[string]interface{}{},
+	})
+
+	ephemerides := testConstellation()
+	base := geodeticToECEF(37.4, -122.1, 30)
+	gps := testMSM4(base, ephemerides)
+	for i := range gps.SignalData.Pseudoranges {
+		gps.SignalData.Cnrs = append(gps.SignalData.Cnrs, uint8(25+i))
+	}
+	glonass := rtcm3.Message1087{}
+	glonass.MsmHeader = rtcm3.MsmHeader{MessageNumber: 1087, SatelliteMask: 0b111 << 61, SignalMask: 0b11 << 30, CellMask: 0b111111}
+	// the second satellite doesn't report the C/N0 of its second signal
+	glonass.SignalData.Cnrs = []uint16{45 * 16, 30 * 16, 44 * 16, 0, 52 * 16, 50 * 16}
+
+	// the DOP waits for the base position and ephemerides
+	s.Update(gps)
+	s.Update(glonass)
+	readings := s.Readings(time.Now())
+	test.That(t, readings["satellites"], test.ShouldEqual, 27)
+	test.That(t, readings["satellites_by_constellation"], test.ShouldResemble, map[string]interface{}{"gps": 24, "glonass": 3})
+	test.That(t, readings["cnr_dbhz"], test.ShouldResemble, map[string]interface{}{"min": 25.0, "median": 38.0, "max": 52.0})
+	test.That(t, readings["cnr_histogram"], test.ShouldResemble, map[string]interface{}{
+		"<20": 0, "20-30": 5, "30-40": 10, "40-50": 11, "50+": 1,
+	})
+	_, ok := readings["hDOP"]
+	test.That(t, ok, test.ShouldBeFalse)
+
+	s.Update(referenceMessage(base))
+	for _, eph := range ephemerides {
+		s.Update(eph)
+	}
+	s.Update(gps)
+	readings = s.Readings(time.Now())
+	hdop, vdop, pdop := readings["hDOP"].(float64), readings["vDOP"].(float64), readings["pDOP"].(float64)
+	test.That(t, hdop, test.ShouldBeBetween, 0.5, 2)
+	test.That(t, vdop, test.ShouldBeBetween, 0.5, 3)
+	test.That(t, pdop, test.ShouldAlmostEqual, math.Hypot(hdop, vdop), 1e-9)
+
+	// observations stop
+	test.That(t, s.Readings(time.Now().Add(time.Minute)), test.ShouldResemble, map[string]interface{}{
+		"satellites":                  0,
+		"satellites_by_constellation": map[string]interface{}{},
+	})
+}
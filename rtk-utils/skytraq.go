@@ -0,0 +1,92 @@
+package rtkutils
+
+import (
+	"encoding/binary"
+	"math"
+	"strings"
+)
+
+const (
+	skyTraqStart1 = 0xA0
+	skyTraqStart2 = 0xA1
+	skyTraqEnd1   = 0x0D
+	skyTraqEnd2   = 0x0A
+
+	skyTraqQuerySoftwareVersion = 0x02
+	skyTraqAck                  = 0x83
+	skyTraqNack                 = 0x84
+	skyTraqConfigureMessageType = 0x09
+	skyTraqRTK                  = 0x6A
+	skyTraqConfigureRTKMode     = 0x06 // sub-id of skyTraqRTK
+
+	skyTraqSystemCode   = 0x01
+	skyTraqOutputNMEA   = 0x01
+	skyTraqRTKRover     = 0x00
+	skyTraqRTKBase      = 0x01
+	skyTraqRoverNormal  = 0x00
+	skyTraqBaseSurvey   = 0x01
+	skyTraqSaveToFlash  = 0x01
+	skyTraqMinSurveyLen = 60 // seconds
+)
+
+// SkyTraqPacket frames the payload of a SkyTraq binary message, its id followed by its body, with
+// the start bytes, big endian length, xor checksum and end bytes.
+func SkyTraqPacket(payload ...byte) []byte {
+	packet := make([]byte, 0, len(payload)+7)
+	packet = append(packet, skyTraqStart1, skyTraqStart2, byte(len(payload)>>8), byte(len(payload)))
+	packet = append(packet, payload...)
+
+	var checksum byte
+	for _, b := range payload {
+		checksum ^= b
+	}
+	return append(packet, checksum, skyTraqEnd1, skyTraqEnd2)
+}
+
+// skyTraqRTKModePacket builds a message setting the rtk mode and operational function, saved to
+// flash. The base position fields are left to the survey.
+func skyTraqRTKModePacket(mode, function byte, surveyLen, stdDev uint32) []byte {
+	payload := make([]byte, 37)
+	payload[0] = skyTraqRTK
+	payload[1] = skyTraqConfigureRTKMode
+	payload[2] = mode
+	payload[3] = function
+	binary.BigEndian.PutUint32(payload[4:], surveyLen)
+	binary.BigEndian.PutUint32(payload[8:], stdDev)
+	// latitude, longitude, ellipsoidal height and baseline length constraint stay zero
+	payload[36] = skyTraqSaveToFlash
+	return SkyTraqPacket(payload...)
+}
+
+// SkyTraqRoverPackets puts a SkyTraq receiver in rtk rover mode with nmea output.
+func SkyTraqRoverPackets() [][]byte {
+	return [][]byte{
+		SkyTraqPacket(skyTraqConfigureMessageType, skyTraqOutputNMEA, skyTraqSaveToFlash),
+		skyTraqRTKModePacket(skyTraqRTKRover, skyTraqRoverNormal, 0, 0),
+	}
+}
+
+// SkyTraqBasePackets puts a SkyTraq receiver in rtk base mode, surveying its position for at least
+// surveyTime seconds and until its standard deviation is within accuracy meters. In base mode the
+// receiver outputs rtcm corrections instead of nmea.
+func SkyTraqBasePackets(accuracy float64, surveyTime int) [][]byte {
+	surveyLen := uint32(surveyTime)
+	if surveyLen < skyTraqMinSurveyLen {
+		surveyLen = skyTraqMinSurveyLen
+	}
+	return [][]byte{
+		skyTraqRTKModePacket(skyTraqRTKBase, skyTraqBaseSurvey, surveyLen, uint32(math.Ceil(accuracy))),
+	}
+}
+
+// SkyTraqQueryVersionPacket builds a message the receiver answers with its software version,
+// without changing its configuration.
+func SkyTraqQueryVersionPacket() []byte {
+	return SkyTraqPacket(skyTraqQuerySoftwareVersion, skyTraqSystemCode)
+}
+
+// isSkyTraqSentence reports whether line is a SkyTraq proprietary PSTI sentence, such as the rtk
+// status, that the nmea parser doesn't know.
+func isSkyTraqSentence(line string) bool {
+	return strings.Contains(line, "$PSTI")
+}
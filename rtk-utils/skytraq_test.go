@@ -0,0 +1,39 @@
+package rtkutils
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"go.viam.com/test"
+)
+
+func TestSkyTraqPacket(t *testing.T) {
+	test.That(t, SkyTraqQueryVersionPacket(), test.ShouldResemble,
+		[]byte{0xA0, 0xA1, 0x00, 0x02, 0x02, 0x01, 0x03, 0x0D, 0x0A})
+
+	packets := SkyTraqRoverPackets()
+	test.That(t, packets, test.ShouldHaveLength, 2)
+	test.That(t, packets[0], test.ShouldResemble, []byte{0xA0, 0xA1, 0x00, 0x03, 0x09, 0x01, 0x01, 0x09, 0x0D, 0x0A})
+	rtkMode := packets[1]
+	test.That(t, rtkMode[:8], test.ShouldResemble, []byte{0xA0, 0xA1, 0x00, 0x25, 0x6A, 0x06, 0x00, 0x00})
+}
+
+func TestSkyTraqBasePackets(t *testing.T) {
+	packets := SkyTraqBasePackets(2.5, 200)
+	test.That(t, packets, test.ShouldHaveLength, 1)
+	payload := packets[0][4 : len(packets[0])-3]
+	test.That(t, payload[:4], test.ShouldResemble, []byte{0x6A, 0x06, 0x01, 0x01})
+	test.That(t, binary.BigEndian.Uint32(payload[4:]), test.ShouldEqual, 200)
+	test.That(t, binary.BigEndian.Uint32(payload[8:]), test.ShouldEqual, 3)
+	test.That(t, payload[36], test.ShouldEqual, 0x01)
+
+	// the receiver surveys for at least a minute
+	payload = SkyTraqBasePackets(1, 10)[0][4:]
+	test.That(t, binary.BigEndian.Uint32(payload[4:]), test.ShouldEqual, 60)
+}
+
+func TestIsSkyTraqSentence(t *testing.T) {
+	test.That(t, isSkyTraqSentence("$PSTI,030,044543.000,A,2447.0895661,N,12100.5234562,E,62.025,0.03,-0.02,0.00,131117,R,1.2,4.2*01"),
+		test.ShouldBeTrue)
+	test.That(t, isSkyTraqSentence(testGGAEpoch1), test.ShouldBeFalse)
+}
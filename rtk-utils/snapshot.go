@@ -0,0 +1,291 @@
+// Package rtkutils contains helpers shared by the rtk-system models.
+package rtkutils
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"go.viam.com/rdk/components/movementsensor/gpsnmea"
+)
+
+// EpochKey is the extra key used to pin movement sensor calls to a single NMEA epoch.
+const EpochKey = "epoch"
+
+// GGA fix qualities of rtk solutions.
+const (
+	FixQualityRTKFixed = 4
+	FixQualityRTKFloat = 5
+)
+
+// IsRTKFix reports whether a GGA fix quality is an rtk fixed or float solution.
+func IsRTKFix(fixQuality int) bool {
+	return fixQuality == FixQualityRTKFixed || fixQuality == FixQualityRTKFloat
+}
+
+// number of published epochs kept around so callers can pin to a recent one.
+const snapshotHistorySize = 4
+
+// epochs further apart than this have no climb rate between them, the altitude may have changed
+// any way in between
+const maxClimbRateGap = 5.0 // s
+
+// Snapshot is a copy of the gps data as it stood at the end of a single NMEA epoch.
+type Snapshot struct {
+	Data    gpsnmea.GPSData
+	Heading Heading
+	Moving  bool
+	Epoch   uint64
+	Time    string // UTC time of the epoch as reported by the receiver (hhmmss.ss)
+
+	// the receiver was stationary long enough for a zero velocity update (ZUPT), see
+	// MotionDetector.ZeroVelocity
+	ZeroVelocity bool
+
+	// the rate the altitude changed at since the previous epoch with a fix, in m/s, if it was recent
+	ClimbRate      float64
+	ClimbRateValid bool
+
+	// only reported by Quectel receivers
+	PositionError PositionError
+	// only reported by Unicore receivers, the latest solution up to the epoch
+	PPP PPPStatus
+	// only reported by u-blox receivers that output PUBX,00
+	PUBX PUBXPosition
+
+	// DGPS reference station ID of the GGA, empty without corrections
+	ReferenceStation string
+
+	// position dilution of precision of the GSA, 0 without one
+	PDOP float64
+	// age of the corrections of the GGA in seconds, not valid without corrections
+	CorrectionAge      float64
+	CorrectionAgeValid bool
+}
+
+// EpochTracker groups NMEA sentences into epochs. Sentences are parsed into a pending copy of
+// the gps data, which is only published once the receiver starts reporting the next epoch, so a
+// published snapshot never mixes fields from two different fixes.
+// EpochTracker is not safe for concurrent use; callers guard it with their data mutex.
+type EpochTracker struct {
+	pending        gpsnmea.GPSData
+	pendingHeading Heading
+	pendingError   PositionError
+	pendingPPP     PPPStatus
+	pendingPUBX    PUBXPosition
+	pendingTime    string
+	pendingStation string
+	pendingPDOP    float64
+	pendingAge     float64
+	pendingAgeOK   bool
+	epoch          uint64
+	history        []Snapshot // oldest to newest
+	motion         *MotionDetector
+	velocity       *VelocityFilter // nil unless the speed and heading are smoothed
+	clampVelocity  bool            // report a speed of 0 for zero velocity epochs
+
+	// altitude and time of day of the last epoch with a fix, for the climb rate
+	lastAlt     float64
+	lastAltTime float64
+	hasLastAlt  bool
+}
+
+// SetMotionThresholds configures how the tracker decides whether the receiver is moving.
+func (t *EpochTracker) SetMotionThresholds(thresholds MotionThresholds) {
+	t.motion = NewMotionDetector(thresholds)
+}
+
+// SetVelocityFilter smooths the speed and heading of the published epochs with filter. Whether the
+// receiver is moving is still decided from the speed it reported.
+func (t *EpochTracker) SetVelocityFilter(filter *VelocityFilter) {
+	t.velocity = filter
+}
+
+// SetZeroVelocityClamp reports a speed of 0 for the epochs the receiver was stationary long enough
+// for a zero velocity update in, instead of the noise it reports while parked.
+func (t *EpochTracker) SetZeroVelocityClamp(clamp bool) {
+	t.clampVelocity = clamp
+}
+
+// ParseAndUpdate parses line into the pending epoch. If line starts a new epoch, the previously
+// pending data is published and returned with published set to true.
+func (t *EpochTracker) ParseAndUpdate(line string) (snap Snapshot, published bool, err error) {
+	if epochTime, ok := EpochTime(line); ok && epochTime != t.pendingTime {
+		if t.pendingTime != "" {
+			snap, published = t.publish(), true
+		}
+		t.pendingTime = epochTime
+	}
+	if id, ok := ggaStationID(line); ok {
+		t.pendingStation = id
+		t.pendingAge, t.pendingAgeOK = ggaCorrectionAge(line)
+	}
+	if pdop, ok := gsaPDOP(line); ok {
+		t.pendingPDOP = pdop
+	}
+	if t.pendingHeading.update(line) || t.pendingError.update(line) || t.pendingPPP.update(line) ||
+		t.pendingPUBX.update(line) || isSkyTraqSentence(line) {
+		return snap, published, nil
+	}
+	if err := unsupportedSentence(line); err != nil {
+		return snap, published, err
+	}
+	err = t.pending.ParseAndUpdate(line)
+	return snap, published, err
+}
+
+func (t *EpochTracker) publish() Snapshot {
+	t.epoch++
+	if t.motion == nil {
+		t.motion = NewMotionDetector(MotionThresholds{})
+	}
+	snap := Snapshot{
+		Data:               t.pending,
+		Heading:            t.pendingHeading,
+		Epoch:              t.epoch,
+		Time:               t.pendingTime,
+		PositionError:      t.pendingError,
+		PPP:                t.pendingPPP,
+		PUBX:               t.pendingPUBX,
+		ReferenceStation:   t.pendingStation,
+		PDOP:               t.pendingPDOP,
+		CorrectionAge:      t.pendingAge,
+		CorrectionAgeValid: t.pendingAgeOK,
+	}
+	snap.Moving = t.motion.Update(snap.Data)
+	snap.ClimbRate, snap.ClimbRateValid = t.climbRate(snap)
+	snap.ZeroVelocity = t.motion.ZeroVelocity()
+	// the course over ground is noise while standing still
+	if !snap.Moving && !snap.Heading.DualAntenna {
+		snap.Heading = Heading{}
+	}
+	snap.Data.Speed, snap.Heading = t.velocity.Update(snap.Data.Speed, snap.Heading)
+	if t.clampVelocity && snap.ZeroVelocity {
+		snap.Data.Speed = 0
+	}
+	// a heading and a position error are only reported for the epoch they were measured in
+	t.pendingHeading = Heading{}
+	t.pendingError = PositionError{}
+	t.pendingPUBX = PUBXPosition{}
+	t.history = append(t.history, snap)
+	if len(t.history) > snapshotHistorySize {
+		t.history = t.history[1:]
+	}
+	return snap
+}
+
+// climbRate returns the rate the altitude of snap changed at since the previous epoch with a fix,
+// false without a fix in both or if they are too far apart.
+func (t *EpochTracker) climbRate(snap Snapshot) (float64, bool) {
+	seconds, ok := epochSeconds(snap.Time)
+	if !ok || snap.Data.FixQuality == 0 {
+		t.hasLastAlt = false
+		return 0, false
+	}
+	lastAlt, lastTime, hasLast := t.lastAlt, t.lastAltTime, t.hasLastAlt
+	t.lastAlt, t.lastAltTime, t.hasLastAlt = snap.Data.Alt, seconds, true
+	if !hasLast {
+		return 0, false
+	}
+	dt := seconds - lastTime
+	if dt < 0 {
+		// past midnight
+		dt += 24 * 60 * 60
+	}
+	if dt <= 0 || dt > maxClimbRateGap {
+		return 0, false
+	}
+	return (snap.Data.Alt - lastAlt) / dt, true
+}
+
+// epochSeconds returns the seconds since midnight of an epoch time (hhmmss.ss).
+func epochSeconds(epochTime string) (float64, bool) {
+	if len(epochTime) < 6 {
+		return 0, false
+	}
+	h, errH := strconv.Atoi(epochTime[0:2])
+	m, errM := strconv.Atoi(epochTime[2:4])
+	sec, errS := strconv.ParseFloat(epochTime[4:], 64)
+	if errH != nil || errM != nil || errS != nil {
+		return 0, false
+	}
+	return float64(h*3600+m*60) + sec, true
+}
+
+// Latest returns the most recently published snapshot.
+func (t *EpochTracker) Latest() (Snapshot, bool) {
+	if len(t.history) == 0 {
+		return Snapshot{}, false
+	}
+	return t.history[len(t.history)-1], true
+}
+
+// Get returns the snapshot published for the given epoch if it is still retained.
+func (t *EpochTracker) Get(epoch uint64) (Snapshot, error) {
+	for _, snap := range t.history {
+		if snap.Epoch == epoch {
+			return snap, nil
+		}
+	}
+	return Snapshot{}, fmt.Errorf("epoch %d is no longer available, only the last %d epochs are kept", epoch, snapshotHistorySize)
+}
+
+// RequestedEpoch returns the epoch a caller pinned in extra, if any.
+func RequestedEpoch(extra map[string]interface{}) (uint64, bool, error) {
+	v, ok := extra[EpochKey]
+	if !ok {
+		return 0, false, nil
+	}
+	switch epoch := v.(type) {
+	case uint64:
+		return epoch, true, nil
+	case int:
+		if epoch < 0 {
+			return 0, false, fmt.Errorf("%q must not be negative", EpochKey)
+		}
+		return uint64(epoch), true, nil
+	case float64: // numbers decoded from json
+		if epoch < 0 {
+			return 0, false, fmt.Errorf("%q must not be negative", EpochKey)
+		}
+		return uint64(epoch), true, nil
+	default:
+		return 0, false, fmt.Errorf("%q must be a number, got %T", EpochKey, v)
+	}
+}
+
+// PinEpoch returns a copy of extra pinned to epoch, unless the caller already pinned one.
+func PinEpoch(extra map[string]interface{}, epoch uint64) map[string]interface{} {
+	pinned := map[string]interface{}{EpochKey: epoch}
+	for k, v := range extra {
+		pinned[k] = v
+	}
+	return pinned
+}
+
+// EpochTime returns the UTC time field of sentences that start or describe a fix epoch.
+func EpochTime(line string) (string, bool) {
+	ind := strings.Index(line, "$G")
+	if ind == -1 {
+		return "", false
+	}
+	line = line[ind:]
+	if star := strings.IndexByte(line, '*'); star != -1 {
+		line = line[:star]
+	}
+	fields := strings.Split(strings.TrimSpace(line), ",")
+	if len(fields[0]) < 6 {
+		return "", false
+	}
+	switch fields[0][3:] {
+	case "GGA", "RMC", "GNS", "ZDA":
+		if len(fields) > 1 && fields[1] != "" {
+			return fields[1], true
+		}
+	case "GLL":
+		if len(fields) > 5 && fields[5] != "" {
+			return fields[5], true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,145 @@
+package rtkutils
+
+import (
+	"testing"
+
+	"go.viam.com/test"
+)
+
+const (
+	testGGAEpoch1 = "$GPGGA,172814.00,3723.46587704,N,12202.26957864,W,2,6,1.2,18.893,M,-25.669,M,2.0,0031*7F"
+	testGSAEpoch1 = "$GPGSA,A,3,10,07,05,02,29,04,08,13,,,,,1.72,1.03,1.38*0A"
+	testGGAEpoch2 = "$GPGGA,172815.00,3723.46600000,N,12202.27000000,W,2,6,0.9,18.900,M,-25.669,M,2.0,0031*70"
+)
+
+func TestEpochTime(t *testing.T) {
+	epochTime, ok := EpochTime(testGGAEpoch1)
+	test.That(t, ok, test.ShouldBeTrue)
+	test.That(t, epochTime, test.ShouldEqual, "172814.00")
+
+	_, ok = EpochTime(testGSAEpoch1)
+	test.That(t, ok, test.ShouldBeFalse)
+
+	_, ok = EpochTime("garbage")
+	test.That(t, ok, test.ShouldBeFalse)
+}
+
+func TestEpochTracker(t *testing.T) {
+	var tracker EpochTracker
+
+	_, published, err := tracker.ParseAndUpdate(testGGAEpoch1)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, published, test.ShouldBeFalse)
+
+	_, published, err = tracker.ParseAndUpdate(testGSAEpoch1)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, published, test.ShouldBeFalse)
+
+	// a Quectel position error is kept with the epoch
+	_, published, err = tracker.ParseAndUpdate(testEPE)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, published, test.ShouldBeFalse)
+
+	_, ok := tracker.Latest()
+	test.That(t, ok, test.ShouldBeFalse)
+
+	// the first sentence of the next epoch publishes everything gathered for the previous one
+	snap, published, err := tracker.ParseAndUpdate(testGGAEpoch2)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, published, test.ShouldBeTrue)
+	test.That(t, snap.Epoch, test.ShouldEqual, 1)
+	test.That(t, snap.Time, test.ShouldEqual, "172814.00")
+	test.That(t, snap.Data.HDOP, test.ShouldEqual, 1.03)
+	test.That(t, snap.Data.VDOP, test.ShouldEqual, 1.38)
+	test.That(t, snap.Data.Alt, test.ShouldEqual, 18.893)
+	test.That(t, snap.PositionError.Horizontal, test.ShouldEqual, 0.016)
+	test.That(t, snap.ReferenceStation, test.ShouldEqual, "31")
+
+	latest, ok := tracker.Latest()
+	test.That(t, ok, test.ShouldBeTrue)
+	test.That(t, latest, test.ShouldResemble, snap)
+
+	pinned, err := tracker.Get(1)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, pinned, test.ShouldResemble, snap)
+
+	_, err = tracker.Get(2)
+	test.That(t, err, test.ShouldNotBeNil)
+}
+
+func TestZeroVelocityClamp(t *testing.T) {
+	var tracker EpochTracker
+	tracker.SetMotionThresholds(MotionThresholds{StationaryEpochs: 2})
+	tracker.SetZeroVelocityClamp(true)
+
+	publish := func(speed float64) Snapshot {
+		tracker.pending.Speed = speed
+		return tracker.publish()
+	}
+	// the noise of the first epochs is reported until the receiver was still for long enough
+	snap := publish(0.05)
+	test.That(t, snap.ZeroVelocity, test.ShouldBeFalse)
+	test.That(t, snap.Data.Speed, test.ShouldEqual, 0.05)
+	snap = publish(0.05)
+	test.That(t, snap.ZeroVelocity, test.ShouldBeTrue)
+	test.That(t, snap.Data.Speed, test.ShouldEqual, 0)
+
+	snap = publish(1)
+	test.That(t, snap.ZeroVelocity, test.ShouldBeFalse)
+	test.That(t, snap.Data.Speed, test.ShouldEqual, 1)
+}
+
+func TestClimbRate(t *testing.T) {
+	var tracker EpochTracker
+	publish := func(epochTime string, fixQuality int, alt float64) Snapshot {
+		tracker.pendingTime = epochTime
+		tracker.pending.FixQuality = fixQuality
+		tracker.pending.Alt = alt
+		return tracker.publish()
+	}
+	snap := publish("235959.50", 4, 100)
+	test.That(t, snap.ClimbRateValid, test.ShouldBeFalse)
+	// across midnight
+	snap = publish("000000.00", 4, 99)
+	test.That(t, snap.ClimbRateValid, test.ShouldBeTrue)
+	test.That(t, snap.ClimbRate, test.ShouldAlmostEqual, -2, 1e-9)
+	snap = publish("000002.00", 4, 100)
+	test.That(t, snap.ClimbRate, test.ShouldAlmostEqual, 0.5, 1e-9)
+
+	// an epoch without a fix has no altitude to climb from
+	snap = publish("000003.00", 0, 0)
+	test.That(t, snap.ClimbRateValid, test.ShouldBeFalse)
+	snap = publish("000004.00", 4, 100)
+	test.That(t, snap.ClimbRateValid, test.ShouldBeFalse)
+	// nor has one too long ago
+	snap = publish("000010.00", 4, 110)
+	test.That(t, snap.ClimbRateValid, test.ShouldBeFalse)
+}
+
+func TestRequestedEpoch(t *testing.T) {
+	_, pinned, err := RequestedEpoch(nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, pinned, test.ShouldBeFalse)
+
+	epoch, pinned, err := RequestedEpoch(map[string]interface{}{EpochKey: 3.0})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, pinned, test.ShouldBeTrue)
+	test.That(t, epoch, test.ShouldEqual, 3)
+
+	_, _, err = RequestedEpoch(map[string]interface{}{EpochKey: "3"})
+	test.That(t, err, test.ShouldNotBeNil)
+
+	extra := PinEpoch(map[string]interface{}{EpochKey: uint64(2)}, 5)
+	test.That(t, extra[EpochKey], test.ShouldEqual, uint64(2))
+}
+
+func BenchmarkEpochTracker(b *testing.B) {
+	epoch := []string{testGGAEpoch1, testGSAEpoch1, testGGAEpoch2, testGSAEpoch1}
+	var tracker EpochTracker
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, _, err := tracker.ParseAndUpdate(epoch[i%len(epoch)]); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package rtkutils
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// UBX navigation solutions, with the flags of the solution.
+const (
+	UBXNavStatus = 0x03
+	UBXNavPVT    = 0x07
+)
+
+const (
+	navPVTPayloadLen    = 92
+	navStatusPayloadLen = 16
+
+	navFlagGNSSFixOK      = 1 << 0
+	navFlagDiffSoln       = 1 << 1
+	navPVTCarrSolnShift   = 6
+	navPVTFlag3InvalidLLH = 1 << 0
+	navPVTVelDOffset      = 56 // NED down velocity, in mm/s
+	navPVTHAccOffset      = 40 // horizontal accuracy estimate, in mm
+	navPVTVAccOffset      = 44 // vertical accuracy estimate, in mm
+	navPVTSAccOffset      = 68 // speed accuracy estimate, in mm/s
+	navPVTHeadAccOffset   = 72 // heading accuracy estimate, in 1e-5 degrees
+	navPVTPDOPOffset      = 76 // position dilution of precision, in 0.01
+)
+
+// Sources of a SolutionStatus.
+const (
+	SolutionSourceUBX = "ubx"
+	SolutionSourceGGA = "gga"
+)
+
+// carrierSolutionNames are the names of the carrier phase solutions in readings.
+var carrierSolutionNames = map[int]string{
+	CarrierSolutionNone:  "none",
+	CarrierSolutionFloat: "float",
+	CarrierSolutionFixed: "fixed",
+}
+
+// SolutionStatus is what a receiver reports about the reliability of a navigation solution, from
+// the flags of a UBX-NAV-PVT or UBX-NAV-STATUS message, or inferred from a GGA fix quality for
+// receivers without UBX.
+type SolutionStatus struct {
+	FixOK           bool // the fix is within the receiver's accuracy masks
+	DiffSoln        bool // differential corrections were applied
+	CarrierSolution int
+	InvalidLLH      bool // the position is invalid or unreliable, only reported by NAV-PVT
+	Source          string
+}
+
+// ParseNavPVT parses the solution flags of the payload of a UBX-NAV-PVT message.
+func ParseNavPVT(payload []byte) (SolutionStatus, error) {
+	if len(payload) != navPVTPayloadLen {
+		return SolutionStatus{}, fmt.Errorf("nav-pvt payload is %d bytes, expected %d", len(payload), navPVTPayloadLen)
+	}
+	flags := payload[21]
+	return SolutionStatus{
+		FixOK:           flags&navFlagGNSSFixOK != 0,
+		DiffSoln:        flags&navFlagDiffSoln != 0,
+		CarrierSolution: int(flags >> navPVTCarrSolnShift),
+		InvalidLLH:      payload[78]&navPVTFlag3InvalidLLH != 0,
+		Source:          SolutionSourceUBX,
+	}, nil
+}
+
+// NavPVTVerticalVelocity returns the vertical velocity of the payload of a UBX-NAV-PVT message in m/s,
+// positive up, and false if the solution isn't a valid fix.
+func NavPVTVerticalVelocity(payload []byte) (float64, bool) {
+	if len(payload) != navPVTPayloadLen || payload[21]&navFlagGNSSFixOK == 0 {
+		return 0, false
+	}
+	velD := int32(binary.LittleEndian.Uint32(payload[navPVTVelDOffset:]))
+	return -float64(velD) / 1000, true
+}
+
+// NavAccuracy is the accuracy a u-blox receiver estimates for a navigation solution in UBX-NAV-PVT.
+type NavAccuracy struct {
+	Horizontal float64 // m
+	Vertical   float64 // m
+	Speed      float64 // m/s
+	Heading    float64 // degrees, of the heading of motion
+	PDOP       float64
+}
+
+// ParseNavPVTAccuracy returns the accuracy of the payload of a UBX-NAV-PVT message, and false if the
+// solution isn't a valid fix.
+func ParseNavPVTAccuracy(payload []byte) (NavAccuracy, bool) {
+	if len(payload) != navPVTPayloadLen || payload[21]&navFlagGNSSFixOK == 0 {
+		return NavAccuracy{}, false
+	}
+	return NavAccuracy{
+		Horizontal: float64(binary.LittleEndian.Uint32(payload[navPVTHAccOffset:])) / 1000,
+		Vertical:   float64(binary.LittleEndian.Uint32(payload[navPVTVAccOffset:])) / 1000,
+		Speed:      float64(binary.LittleEndian.Uint32(payload[navPVTSAccOffset:])) / 1000,
+		Heading:    float64(binary.LittleEndian.Uint32(payload[navPVTHeadAccOffset:])) * 1e-5,
+		PDOP:       float64(binary.LittleEndian.Uint16(payload[navPVTPDOPOffset:])) / 100,
+	}, true
+}
+
+// Add sets the accuracy of the solution in accuracy, over what the sentences of its epoch estimated.
+func (a NavAccuracy) Add(accuracy map[string]float32) {
+	accuracy[AccuracyHorizontal] = float32(a.Horizontal)
+	accuracy[AccuracyVertical] = float32(a.Vertical)
+	accuracy[AccuracySpeed] = float32(a.Speed)
+	accuracy[AccuracyHeading] = float32(a.Heading)
+	accuracy[AccuracyPDOP] = float32(a.PDOP)
+}
+
+// ParseNavStatus parses the solution flags of the payload of a UBX-NAV-STATUS message.
+func ParseNavStatus(payload []byte) (SolutionStatus, error) {
+	if len(payload) != navStatusPayloadLen {
+		return SolutionStatus{}, fmt.Errorf("nav-status payload is %d bytes, expected %d", len(payload), navStatusPayloadLen)
+	}
+	flags := payload[5]
+	return SolutionStatus{
+		FixOK:           flags&navFlagGNSSFixOK != 0,
+		DiffSoln:        flags&navFlagDiffSoln != 0,
+		CarrierSolution: int(payload[7] >> navPVTCarrSolnShift),
+		Source:          SolutionSourceUBX,
+	}, nil
+}
+
+// GGASolutionStatus infers the status of a solution from its GGA fix quality, for receivers that
+// don't output UBX.
+func GGASolutionStatus(fixQuality int) SolutionStatus {
+	status := SolutionStatus{FixOK: fixQuality > 0, DiffSoln: fixQuality > 1, Source: SolutionSourceGGA}
+	switch fixQuality {
+	case FixQualityRTKFixed:
+		status.CarrierSolution = CarrierSolutionFixed
+	case FixQualityRTKFloat:
+		status.CarrierSolution = CarrierSolutionFloat
+	}
+	return status
+}
+
+// Readings returns the status as readings.
+func (s SolutionStatus) Readings() map[string]interface{} {
+	return map[string]interface{}{
+		"gnss_fix_ok":            s.FixOK,
+		"diff_soln":              s.DiffSoln,
+		"carr_soln":              carrierSolutionNames[s.CarrierSolution],
+		"invalid_llh":            s.InvalidLLH,
+		"solution_status_source": s.Source,
+	}
+}
+
+// EnableSolutionStatus asks a u-blox receiver to output UBX-NAV-PVT on every navigation solution,
+// on the port write sends to.
+func EnableSolutionStatus(write func([]byte) error) error {
+	return write(UBXMessageRatePacket(UBXClassNav, UBXNavPVT, 1))
+}
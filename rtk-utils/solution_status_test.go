@@ -0,0 +1,107 @@
+package rtkutils
+
+import (
+	"encoding/binary"
+	"errors"
+	"testing"
+
+	"go.viam.com/test"
+)
+
+func TestParseNavPVT(t *testing.T) {
+	payload := make([]byte, navPVTPayloadLen)
+	payload[21] = navFlagGNSSFixOK | navFlagDiffSoln | CarrierSolutionFixed<<navPVTCarrSolnShift
+	status, err := ParseNavPVT(payload)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, status, test.ShouldResemble, SolutionStatus{
+		FixOK: true, DiffSoln: true, CarrierSolution: CarrierSolutionFixed, Source: SolutionSourceUBX,
+	})
+
+	payload[21] = 0
+	payload[78] = navPVTFlag3InvalidLLH
+	status, err = ParseNavPVT(payload)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, status.FixOK, test.ShouldBeFalse)
+	test.That(t, status.InvalidLLH, test.ShouldBeTrue)
+
+	_, err = ParseNavPVT(make([]byte, 84))
+	test.That(t, err, test.ShouldBeError, errors.New("nav-pvt payload is 84 bytes, expected 92"))
+}
+
+func TestParseNavPVTAccuracy(t *testing.T) {
+	payload := make([]byte, navPVTPayloadLen)
+	binary.LittleEndian.PutUint32(payload[navPVTHAccOffset:], 14)
+	binary.LittleEndian.PutUint32(payload[navPVTVAccOffset:], 21)
+	binary.LittleEndian.PutUint32(payload[navPVTSAccOffset:], 35)
+	binary.LittleEndian.PutUint32(payload[navPVTHeadAccOffset:], 250000)
+	binary.LittleEndian.PutUint16(payload[navPVTPDOPOffset:], 132)
+	_, ok := ParseNavPVTAccuracy(payload)
+	test.That(t, ok, test.ShouldBeFalse)
+
+	payload[21] = navFlagGNSSFixOK
+	accuracy, ok := ParseNavPVTAccuracy(payload)
+	test.That(t, ok, test.ShouldBeTrue)
+	test.That(t, accuracy.Horizontal, test.ShouldAlmostEqual, 0.014, 1e-9)
+	test.That(t, accuracy.Vertical, test.ShouldAlmostEqual, 0.021, 1e-9)
+	test.That(t, accuracy.Speed, test.ShouldAlmostEqual, 0.035, 1e-9)
+	test.That(t, accuracy.Heading, test.ShouldAlmostEqual, 2.5, 1e-9)
+	test.That(t, accuracy.PDOP, test.ShouldAlmostEqual, 1.32, 1e-9)
+
+	values := map[string]float32{AccuracyHorizontal: 0.5}
+	accuracy.Add(values)
+	test.That(t, values[AccuracyHorizontal], test.ShouldAlmostEqual, 0.014, 1e-6)
+	test.That(t, values[AccuracySpeed], test.ShouldAlmostEqual, 0.035, 1e-6)
+
+	_, ok = ParseNavPVTAccuracy(make([]byte, 84))
+	test.That(t, ok, test.ShouldBeFalse)
+}
+
+func TestNavPVTVerticalVelocity(t *testing.T) {
+	payload := make([]byte, navPVTPayloadLen)
+	// 1.5 m/s down
+	binary.LittleEndian.PutUint32(payload[navPVTVelDOffset:], 1500)
+	_, ok := NavPVTVerticalVelocity(payload)
+	test.That(t, ok, test.ShouldBeFalse)
+
+	payload[21] = navFlagGNSSFixOK
+	vertical, ok := NavPVTVerticalVelocity(payload)
+	test.That(t, ok, test.ShouldBeTrue)
+	test.That(t, vertical, test.ShouldAlmostEqual, -1.5, 1e-9)
+
+	v := int32(-250)
+	binary.LittleEndian.PutUint32(payload[navPVTVelDOffset:], uint32(v))
+	vertical, _ = NavPVTVerticalVelocity(payload)
+	test.That(t, vertical, test.ShouldAlmostEqual, 0.25, 1e-9)
+
+	_, ok = NavPVTVerticalVelocity(payload[:84])
+	test.That(t, ok, test.ShouldBeFalse)
+}
+
+func TestParseNavStatus(t *testing.T) {
+	payload := make([]byte, navStatusPayloadLen)
+	payload[5] = navFlagGNSSFixOK
+	payload[7] = CarrierSolutionFloat << navPVTCarrSolnShift
+	status, err := ParseNavStatus(payload)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, status, test.ShouldResemble, SolutionStatus{
+		FixOK: true, CarrierSolution: CarrierSolutionFloat, Source: SolutionSourceUBX,
+	})
+
+	_, err = ParseNavStatus(make([]byte, 4))
+	test.That(t, err, test.ShouldBeError, errors.New("nav-status payload is 4 bytes, expected 16"))
+}
+
+func TestGGASolutionStatus(t *testing.T) {
+	test.That(t, GGASolutionStatus(0).FixOK, test.ShouldBeFalse)
+	test.That(t, GGASolutionStatus(1).Readings(), test.ShouldResemble, map[string]interface{}{
+		"gnss_fix_ok":            true,
+		"diff_soln":              false,
+		"carr_soln":              "none",
+		"invalid_llh":            false,
+		"solution_status_source": SolutionSourceGGA,
+	})
+	test.That(t, GGASolutionStatus(FixQualityRTKFloat).CarrierSolution, test.ShouldEqual, CarrierSolutionFloat)
+	fixed := GGASolutionStatus(FixQualityRTKFixed)
+	test.That(t, fixed.DiffSoln, test.ShouldBeTrue)
+	test.That(t, fixed.CarrierSolution, test.ShouldEqual, CarrierSolutionFixed)
+}
@@ -0,0 +1,80 @@
+package rtkutils
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// Speed alarm event types.
+const (
+	EventOverspeed        = "overspeed"
+	EventOverspeedCleared = "overspeed_cleared"
+)
+
+// share of the speed limit the speed has to drop below to clear an overspeed by default.
+const defaultSpeedClearRatio = 0.9
+
+// SpeedAlarm raises an event when the speed goes over a limit and another once it drops back
+// below the clear speed, so a speed hovering around the limit does not flood the event log.
+// A nil alarm never fires.
+type SpeedAlarm struct {
+	limit float64 // m/s
+	clear float64 // m/s
+
+	mu        sync.Mutex
+	overspeed bool
+	maxSpeed  float64 // highest speed of the current overspeed
+}
+
+// NewSpeedAlarm returns an alarm for limit in m/s, or nil if limit is not positive.
+// A zero clear speed defaults to 90% of the limit.
+func NewSpeedAlarm(limit, clear float64) *SpeedAlarm {
+	if limit <= 0 {
+		return nil
+	}
+	if clear <= 0 {
+		clear = limit * defaultSpeedClearRatio
+	}
+	return &SpeedAlarm{limit: limit, clear: clear}
+}
+
+// ValidateSpeedAlarm checks the speed alarm thresholds of a config.
+func ValidateSpeedAlarm(limit, clear float64) error {
+	if limit < 0 || clear < 0 {
+		return errors.New("speed alarm thresholds must not be negative")
+	}
+	if clear > limit {
+		return fmt.Errorf("speed_limit_clear_mps (%v) must not be greater than speed_limit_mps (%v)", clear, limit)
+	}
+	return nil
+}
+
+// Update checks speed in m/s against the limit, adding an event to events when the alarm is raised or cleared.
+func (a *SpeedAlarm) Update(speed float64, events *EventLog) {
+	if a == nil {
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	switch {
+	case !a.overspeed && speed > a.limit:
+		a.overspeed, a.maxSpeed = true, speed
+		events.Add(EventOverspeed, "speed %.2f m/s is over the %.2f m/s limit", speed, a.limit)
+	case a.overspeed && speed < a.clear:
+		a.overspeed = false
+		events.Add(EventOverspeedCleared, "speed %.2f m/s is back under %.2f m/s, peaked at %.2f m/s", speed, a.clear, a.maxSpeed)
+	case a.overspeed && speed > a.maxSpeed:
+		a.maxSpeed = speed
+	}
+}
+
+// Overspeed reports whether the speed is currently over the limit.
+func (a *SpeedAlarm) Overspeed() bool {
+	if a == nil {
+		return false
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.overspeed
+}
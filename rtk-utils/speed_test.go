@@ -0,0 +1,43 @@
+package rtkutils
+
+import (
+	"testing"
+
+	"github.com/edaniels/golog"
+	"go.viam.com/test"
+)
+
+func TestSpeedAlarm(t *testing.T) {
+	test.That(t, NewSpeedAlarm(0, 0), test.ShouldBeNil)
+	test.That(t, ValidateSpeedAlarm(0, 0), test.ShouldBeNil)
+	test.That(t, ValidateSpeedAlarm(2, 1.5), test.ShouldBeNil)
+	test.That(t, ValidateSpeedAlarm(2, 3), test.ShouldNotBeNil)
+	test.That(t, ValidateSpeedAlarm(-1, 0), test.ShouldNotBeNil)
+
+	events := NewEventLog(golog.NewTestLogger(t))
+	alarm := NewSpeedAlarm(2, 0)
+
+	alarm.Update(1.9, events)
+	test.That(t, alarm.Overspeed(), test.ShouldBeFalse)
+
+	alarm.Update(2.5, events)
+	alarm.Update(3, events)
+	test.That(t, alarm.Overspeed(), test.ShouldBeTrue)
+
+	// hovering between the clear speed and the limit neither clears nor raises it again
+	alarm.Update(1.9, events)
+	test.That(t, alarm.Overspeed(), test.ShouldBeTrue)
+	alarm.Update(2.1, events)
+
+	alarm.Update(1.5, events)
+	test.That(t, alarm.Overspeed(), test.ShouldBeFalse)
+
+	test.That(t, events.Events(EventOverspeed), test.ShouldHaveLength, 1)
+	cleared := events.Events(EventOverspeedCleared)
+	test.That(t, cleared, test.ShouldHaveLength, 1)
+	test.That(t, cleared[0].Message, test.ShouldContainSubstring, "peaked at 3.00 m/s")
+
+	var nilAlarm *SpeedAlarm
+	nilAlarm.Update(10, events)
+	test.That(t, nilAlarm.Overspeed(), test.ShouldBeFalse)
+}
@@ -0,0 +1,196 @@
+package rtkutils
+
+import (
+	"math"
+	"math/bits"
+	"sort"
+
+	"github.com/go-gnss/rtcm/rtcm3"
+)
+
+const (
+	relativisticF    = -4.442807633e-10 // s/m^0.5, IS-GPS-200
+	sppMinSatellites = 5                // one more than the unknowns, so a bad satellite shows
+	sppMaxIterations = 10
+	sppElevationMask = 15 * math.Pi / 180
+)
+
+// pseudorange is the range in m a receiver measured to a satellite, corrected for the satellite clock.
+type pseudorange struct {
+	sat [3]float64 // ECEF position of the satellite when it sent the signal, in m
+	rng float64
+}
+
+// gpsSatelliteClock returns the clock offset in s of a GPS satellite at GPS time of week t, with the
+// relativistic correction and the L1 group delay, following IS-GPS-200.
+func gpsSatelliteClock(eph rtcm3.Message1019, t float64) float64 {
+	dt := weekDiff(t, float64(eph.Toc)*16)
+	sqrtA := float64(eph.SrA) * math.Exp2(-19)
+	e := float64(eph.Eccentricity) * math.Exp2(-33)
+	ek := gpsEccentricAnomaly(eph, weekDiff(t, float64(eph.Toe)*16))
+	return float64(eph.Af0)*math.Exp2(-31) + float64(eph.Af1)*math.Exp2(-43)*dt + float64(eph.Af2)*math.Exp2(-55)*dt*dt +
+		relativisticF*e*sqrtA*math.Sin(ek) - float64(eph.Tgd)*math.Exp2(-31)
+}
+
+// gpsPseudoranges returns the pseudoranges of the healthy GPS satellites of an msm epoch that have an
+// ephemeris, from the first signal of each with a valid fine pseudorange. fine are the fine
+// pseudoranges of the cells, signed values of width bits in units of 2^-scale ms.
+func gpsPseudoranges(header rtcm3.MsmHeader, roughMs []uint8, rough []uint16, fine []int64, width, scale int,
+	ephemerides map[uint8]rtcm3.Message1019,
+) []pseudorange {
+	invalid := int64(-1) << (width - 1)
+	tow := float64(header.Epoch) / 1000
+	sats := msmSatellites(header)
+	nsig := bits.OnesCount32(header.SignalMask)
+	ncell := len(sats) * nsig
+
+	var ranges []pseudorange
+	cell := 0
+	for i, sat := range sats {
+		rangeMs := math.NaN()
+		for j := 0; j < nsig; j++ {
+			if header.CellMask&(1<<(ncell-1-(i*nsig+j))) == 0 {
+				continue
+			}
+			if cell < len(fine) && fine[cell] != invalid && math.IsNaN(rangeMs) {
+				rangeMs = float64(fine[cell]) * math.Exp2(-float64(scale))
+			}
+			cell++
+		}
+		eph, ok := ephemerides[uint8(sat)]
+		if !ok || eph.SVHealth != 0 || math.IsNaN(rangeMs) || roughMs[i] == msmInvalidRoughRange {
+			continue
+		}
+		rangeMs += float64(roughMs[i]) + float64(rough[i])/1024
+
+		rng := rangeMs / 1000 * speedOfLight
+		sent := tow - rng/speedOfLight
+		clock := gpsSatelliteClock(eph, sent)
+		pos, ok := gpsSatellitePosition(eph, sent-clock)
+		if !ok {
+			continue
+		}
+		ranges = append(ranges, pseudorange{sat: pos, rng: rng + clock*speedOfLight})
+	}
+	return ranges
+}
+
+// solvePosition returns the ECEF position in m of the receiver that measured ranges, by least
+// squares from approx, and false if too few satellites are above the elevation mask or the solution
+// doesn't converge. Ionospheric delays aren't modeled, so it is only accurate to a few meters.
+func solvePosition(ranges []pseudorange, approx [3]float64) ([3]float64, bool) {
+	pos := approx
+	var clock float64 // receiver clock offset in m
+	for iter := 0; iter < sppMaxIterations; iter++ {
+		var normal [4][5]float64 // normal equations, with the right hand side in the last column
+		used := 0
+		for _, r := range ranges {
+			el := elevation(r.sat, pos)
+			if el < sppElevationMask {
+				continue
+			}
+			rng := geometricRange(r.sat, pos)
+			residual := r.rng - rng - clock - troposphereDelay(el)
+			row := [4]float64{(pos[0] - r.sat[0]) / rng, (pos[1] - r.sat[1]) / rng, (pos[2] - r.sat[2]) / rng, 1}
+			for a := 0; a < 4; a++ {
+				for b := 0; b < 4; b++ {
+					normal[a][b] += row[a] * row[b]
+				}
+				normal[a][4] += row[a] * residual
+			}
+			used++
+		}
+		if used < sppMinSatellites {
+			return pos, false
+		}
+		dx, ok := solveLinear(normal)
+		if !ok {
+			return pos, false
+		}
+		for k := 0; k < 3; k++ {
+			pos[k] += dx[k]
+		}
+		clock += dx[3]
+		if math.Sqrt(dx[0]*dx[0]+dx[1]*dx[1]+dx[2]*dx[2]) < 1e-4 {
+			return pos, true
+		}
+	}
+	return pos, false
+}
+
+// solveLinear solves 4 linear equations by gaussian elimination, and returns false if they are singular.
+func solveLinear(m [4][5]float64) ([4]float64, bool) {
+	for col := 0; col < 4; col++ {
+		pivot := col
+		for row := col + 1; row < 4; row++ {
+			if math.Abs(m[row][col]) > math.Abs(m[pivot][col]) {
+				pivot = row
+			}
+		}
+		if math.Abs(m[pivot][col]) < 1e-12 {
+			return [4]float64{}, false
+		}
+		m[col], m[pivot] = m[pivot], m[col]
+		for row := col + 1; row < 4; row++ {
+			f := m[row][col] / m[col][col]
+			for k := col; k < 5; k++ {
+				m[row][k] -= f * m[col][k]
+			}
+		}
+	}
+	var x [4]float64
+	for row := 3; row >= 0; row-- {
+		sum := m[row][4]
+		for k := row + 1; k < 4; k++ {
+			sum -= m[row][k] * x[k]
+		}
+		x[row] = sum / m[row][row]
+	}
+	return x, true
+}
+
+// elevation returns the elevation in radians of a satellite seen from a receiver, both ECEF in m.
+func elevation(sat, receiver [3]float64) float64 {
+	up := ellipsoidNormal(receiver)
+	d := [3]float64{sat[0] - receiver[0], sat[1] - receiver[1], sat[2] - receiver[2]}
+	return math.Asin((d[0]*up[0] + d[1]*up[1] + d[2]*up[2]) / distance(sat, receiver))
+}
+
+// troposphereDelay returns the delay in m of a signal at elevation el in radians, for a standard
+// atmosphere at sea level.
+func troposphereDelay(el float64) float64 {
+	return 2.47 / (math.Sin(el) + 0.0121)
+}
+
+// horizontalDistance returns the distance in m between two ECEF positions in the horizontal plane
+// of the second.
+func horizontalDistance(a, b [3]float64) float64 {
+	up := ellipsoidNormal(b)
+	d := [3]float64{a[0] - b[0], a[1] - b[1], a[2] - b[2]}
+	vertical := d[0]*up[0] + d[1]*up[1] + d[2]*up[2]
+	return math.Sqrt(math.Max(0, d[0]*d[0]+d[1]*d[1]+d[2]*d[2]-vertical*vertical))
+}
+
+// ellipsoidNormal returns the unit vector of the WGS84 vertical at an ECEF position in m, which is
+// exact on the ellipsoid and close enough to it for a receiver.
+func ellipsoidNormal(p [3]float64) [3]float64 {
+	b2 := wgs84A * wgs84A * (1 - wgs84F) * (1 - wgs84F)
+	v := [3]float64{p[0] / (wgs84A * wgs84A), p[1] / (wgs84A * wgs84A), p[2] / b2}
+	n := math.Sqrt(v[0]*v[0] + v[1]*v[1] + v[2]*v[2])
+	return [3]float64{v[0] / n, v[1] / n, v[2] / n}
+}
+
+// medianPosition returns the median of each coordinate of positions, so that a few bad solutions
+// don't move it.
+func medianPosition(positions [][3]float64) [3]float64 {
+	var median [3]float64
+	values := make([]float64, len(positions))
+	for k := 0; k < 3; k++ {
+		for i, p := range positions {
+			values[i] = p[k]
+		}
+		sort.Float64s(values)
+		median[k] = values[len(values)/2]
+	}
+	return median
+}
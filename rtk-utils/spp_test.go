@@ -0,0 +1,115 @@
+package rtkutils
+
+import (
+	"math"
+	"testing"
+
+	"github.com/go-gnss/rtcm/rtcm3"
+	"go.viam.com/test"
+)
+
+const (
+	testTow         = 7200.0 // s
+	testClockOffset = 1e-4   // s
+)
+
+// testConstellation returns the ephemerides of 24 satellites in 6 orbital planes.
+func testConstellation() map[uint8]rtcm3.Message1019 {
+	ephemerides := map[uint8]rtcm3.Message1019{}
+	for plane := 0; plane < 6; plane++ {
+		for slot := 0; slot < 4; slot++ {
+			sat := uint8(plane*4 + slot + 1)
+			ephemerides[sat] = rtcm3.Message1019{
+				AbstractMessage: rtcm3.AbstractMessage{MessageNumber: 1019},
+				SatelliteID:     sat,
+				SrA:             uint32(5153.6 * math.Exp2(19)),
+				Eccentricity:    uint32(0.005 * math.Exp2(33)),
+				I0:              int32(55.0 / 180 * math.Exp2(31)),
+				Omega0:          int32((float64(plane)/3 - 1) * math.Exp2(31)),
+				M0:              int32((float64(slot)/2 - 1 + float64(plane)*0.08) * math.Exp2(31)),
+				Toe:             uint16(testTow / 16),
+				Toc:             uint16(testTow / 16),
+			}
+		}
+	}
+	return ephemerides
+}
+
+// testMSM4 returns the MSM4 epoch a receiver at pos with a clock offset of testClockOffset measures of
+// the satellites of ephemerides, with the model solvePosition uses.
+func testMSM4(pos [3]float64, ephemerides map[uint8]rtcm3.Message1019) rtcm3.Message1074 {
+	msg := rtcm3.Message1074{}
+	msg.MsmHeader = rtcm3.MsmHeader{MessageNumber: 1074, Epoch: uint32(testTow * 1000), SignalMask: 1 << 30}
+	n := 0
+	for sat := uint8(1); sat <= 32; sat++ {
+		eph, ok := ephemerides[sat]
+		if !ok {
+			continue
+		}
+		rng := 0.075 * speedOfLight
+		for i := 0; i < 5; i++ {
+			sent := testTow - rng/speedOfLight
+			clock := gpsSatelliteClock(eph, sent)
+			satPos, _ := gpsSatellitePosition(eph, sent-clock)
+			el := math.Max(elevation(satPos, pos), 0.01)
+			rng = geometricRange(satPos, pos) + testClockOffset*speedOfLight + troposphereDelay(el) - clock*speedOfLight
+		}
+		rangeMs := rng / speedOfLight * 1000
+		whole := math.Floor(rangeMs)
+		rough := math.Round((rangeMs - whole) * 1024)
+		fine := math.Round((rangeMs - whole - rough/1024) * math.Exp2(24))
+
+		msg.MsmHeader.SatelliteMask |= 1 << (64 - uint(sat))
+		msg.SatelliteData.RangeMilliseconds = append(msg.SatelliteData.RangeMilliseconds, uint8(whole))
+		msg.SatelliteData.Ranges = append(msg.SatelliteData.Ranges, uint16(rough))
+		msg.SignalData.Pseudoranges = append(msg.SignalData.Pseudoranges, int16(fine))
+		n++
+	}
+	msg.MsmHeader.CellMask = 1<<n - 1
+	return msg
+}
+
+func msm4Pseudoranges(msg rtcm3.Message1074, ephemerides map[uint8]rtcm3.Message1019) []pseudorange {
+	fine := make([]int64, len(msg.SignalData.Pseudoranges))
+	for i, v := range msg.SignalData.Pseudoranges {
+		fine[i] = int64(v)
+	}
+	return gpsPseudoranges(msg.MsmHeader, msg.SatelliteData.RangeMilliseconds, msg.SatelliteData.Ranges, fine, 15, 24,
+		ephemerides)
+}
+
+func TestSolvePosition(t *testing.T) {
+	ephemerides := testConstellation()
+	truth := geodeticToECEF(37.4, -122.1, 30)
+	ranges := msm4Pseudoranges(testMSM4(truth, ephemerides), ephemerides)
+	test.That(t, len(ranges), test.ShouldEqual, 24)
+
+	// from a position 1km away
+	approx := geodeticToECEF(37.41, -122.1, 30)
+	pos, ok := solvePosition(ranges, approx)
+	test.That(t, ok, test.ShouldBeTrue)
+	test.That(t, distance(pos, truth), test.ShouldBeLessThan, 0.1)
+
+	// satellites without an ephemeris or unhealthy are left out
+	delete(ephemerides, 1)
+	eph := ephemerides[2]
+	eph.SVHealth = 1
+	ephemerides[2] = eph
+	test.That(t, len(msm4Pseudoranges(testMSM4(truth, testConstellation()), ephemerides)), test.ShouldEqual, 22)
+
+	// too few satellites above the elevation mask
+	_, ok = solvePosition(ranges[:4], approx)
+	test.That(t, ok, test.ShouldBeFalse)
+}
+
+func TestHorizontalDistance(t *testing.T) {
+	base := geodeticToECEF(37.4, -122.1, 30)
+	test.That(t, horizontalDistance(geodeticToECEF(37.4, -122.1, 80), base), test.ShouldAlmostEqual, 0, 1e-3)
+	// 0.0001 degrees of longitude at 37.4 degrees of latitude
+	test.That(t, horizontalDistance(geodeticToECEF(37.4, -122.0999, 30), base), test.ShouldAlmostEqual, 8.86, 0.01)
+}
+
+func TestMedianPosition(t *testing.T) {
+	positions := [][3]float64{{1, 5, 0}, {2, 4, 100}, {3, 3, 1}, {100, 2, 2}, {4, 1, 3}}
+	test.That(t, medianPosition(positions), test.ShouldResemble, [3]float64{3, 3, 2})
+}
@@ -0,0 +1,58 @@
+package rtkutils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ValidateStartupTimeout checks the startup timeout of a config in seconds.
+func ValidateStartupTimeout(timeout float64) error {
+	if timeout < 0 {
+		return errors.New("startup_fix_timeout_sec must not be negative")
+	}
+	return nil
+}
+
+// StartupWatchdog declares a component that read no data within its startup timeout as getting
+// none, so that a receiver wired wrong or set to another baud rate shows up as such instead of as a
+// sensor that stays empty while initializing. A nil watchdog never does.
+type StartupWatchdog struct {
+	timeout time.Duration
+	started time.Time
+}
+
+// NewStartupWatchdog returns a watchdog of a component started now, or nil if timeout is 0.
+func NewStartupWatchdog(timeout time.Duration, now time.Time) *StartupWatchdog {
+	if timeout <= 0 {
+		return nil
+	}
+	return &StartupWatchdog{timeout: timeout, started: now}
+}
+
+// State returns StateNoData for a component still in StateInitializing past the timeout, and state
+// otherwise.
+func (w *StartupWatchdog) State(state string, now time.Time) string {
+	if w != nil && state == StateInitializing && now.Sub(w.started) >= w.timeout {
+		return StateNoData
+	}
+	return state
+}
+
+// Wait waits for the timeout and returns an error if activity was never added to by then. It
+// returns nil once ctx is done.
+func (w *StartupWatchdog) Wait(ctx context.Context, activity *Counter) error {
+	if w == nil {
+		return nil
+	}
+	select {
+	case <-ctx.Done():
+		return nil
+	case <-time.After(time.Until(w.started.Add(w.timeout))):
+	}
+	if activity.Get() > 0 {
+		return nil
+	}
+	return fmt.Errorf("no valid nmea within %s of starting, check the wiring and baud rate of the receiver", w.timeout)
+}
@@ -0,0 +1,43 @@
+package rtkutils
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go.viam.com/test"
+)
+
+func TestValidateStartupTimeout(t *testing.T) {
+	test.That(t, ValidateStartupTimeout(0), test.ShouldBeNil)
+	test.That(t, ValidateStartupTimeout(30), test.ShouldBeNil)
+	test.That(t, ValidateStartupTimeout(-1), test.ShouldBeError, errors.New("startup_fix_timeout_sec must not be negative"))
+}
+
+func TestStartupWatchdog(t *testing.T) {
+	var none *StartupWatchdog
+	test.That(t, NewStartupWatchdog(0, time.Now()), test.ShouldBeNil)
+	test.That(t, none.State(StateInitializing, time.Now()), test.ShouldEqual, StateInitializing)
+	test.That(t, none.Wait(context.Background(), &Counter{}), test.ShouldBeNil)
+
+	started := time.Now()
+	w := NewStartupWatchdog(30*time.Second, started)
+	test.That(t, w.State(StateInitializing, started.Add(29*time.Second)), test.ShouldEqual, StateInitializing)
+	test.That(t, w.State(StateInitializing, started.Add(30*time.Second)), test.ShouldEqual, StateNoData)
+	// a component that read data, or is waiting for its device, says so
+	test.That(t, w.State(StateDegraded, started.Add(time.Minute)), test.ShouldEqual, StateDegraded)
+	test.That(t, w.State(StateWaitingForDevice, started.Add(time.Minute)), test.ShouldEqual, StateWaitingForDevice)
+
+	var epochs Counter
+	w = NewStartupWatchdog(10*time.Millisecond, time.Now())
+	test.That(t, w.Wait(context.Background(), &epochs), test.ShouldBeError,
+		errors.New("no valid nmea within 10ms of starting, check the wiring and baud rate of the receiver"))
+	epochs.Add(1)
+	test.That(t, w.Wait(context.Background(), &epochs), test.ShouldBeNil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	w = NewStartupWatchdog(time.Hour, time.Now())
+	test.That(t, w.Wait(ctx, &Counter{}), test.ShouldBeNil)
+}
@@ -0,0 +1,78 @@
+package rtkutils
+
+import "time"
+
+// StateKey is the reading with the state of a component, so that users can tell what it is doing
+// beyond it being configured.
+const StateKey = "state"
+
+// States of a component.
+const (
+	StateInitializing     = "initializing"       // no data read yet
+	StateNoData           = "no_data"            // no data read within the startup timeout, see StartupWatchdog
+	StateWaitingForDevice = "waiting_for_device" // no data read since the device isn't there yet
+	StateSurveying        = "surveying"          // a station surveying its position, no corrections yet
+	StateOffSchedule      = "off_schedule"       // a station outside of its transmit schedule, see Schedule
+	StateBrownout         = "brownout"           // a station whose supply is too low to transmit, see BrownoutGuard
+	StateWaitingForFix    = "waiting_for_fix"    // a rover without an rtk solution
+	StateGPSOnly          = "gps_only"           // a rover serving standalone positions without corrections
+	StateHealthy          = "healthy"
+	StateDegraded         = "degraded" // data stopped arriving
+	StateError            = "error"    // failed for good, the component has to be reconfigured
+)
+
+// StaleAfter is how long a component can go without new data before it is degraded. Receivers send
+// an epoch, and stations corrections, every second.
+const StaleAfter = 5 * time.Second
+
+// RoverState returns the state of a rover that published epochs, the latest of them with fixQuality.
+func RoverState(errs *ErrorHistory, epochs *Counter, fixQuality int, now time.Time) string {
+	switch {
+	case errs.Fatal() != nil:
+		return StateError
+	case epochs.Get() == 0:
+		return StateInitializing
+	case now.Sub(epochs.Last()) > StaleAfter:
+		return StateDegraded
+	case !IsRTKFix(fixQuality):
+		return StateWaitingForFix
+	}
+	return StateHealthy
+}
+
+// GPSOnlyState returns StateGPSOnly for a rover in state that is waiting for a fix while no
+// corrections were forwarded to it for StaleAfter, and state otherwise.
+func GPSOnlyState(state string, corrections *Counter, now time.Time) string {
+	if state == StateWaitingForFix && (corrections.Get() == 0 || now.Sub(corrections.Last()) > StaleAfter) {
+		return StateGPSOnly
+	}
+	return state
+}
+
+// DeviceState returns StateWaitingForDevice for a component in state that reads no data while
+// recovery waits for a device that isn't there, and state otherwise.
+func DeviceState(state string, recovery *Recovery) string {
+	switch state {
+	case StateInitializing, StateSurveying, StateDegraded:
+		if recovery.WaitingForDevice() {
+			return StateWaitingForDevice
+		}
+	}
+	return state
+}
+
+// StationState returns the state of a correction station that read frames. Until the first frame
+// a station that surveys its position is surveying, one that relays corrections is initializing.
+func StationState(errs *ErrorHistory, frames *Counter, surveys bool, now time.Time) string {
+	switch {
+	case errs.Fatal() != nil:
+		return StateError
+	case frames.Get() == 0 && surveys:
+		return StateSurveying
+	case frames.Get() == 0:
+		return StateInitializing
+	case now.Sub(frames.Last()) > StaleAfter:
+		return StateDegraded
+	}
+	return StateHealthy
+}
@@ -0,0 +1,70 @@
+package rtkutils
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"testing"
+	"time"
+
+	"github.com/edaniels/golog"
+	"go.viam.com/test"
+)
+
+func TestRoverState(t *testing.T) {
+	errs := NewErrorHistory()
+	var epochs Counter
+	now := time.Now()
+
+	test.That(t, RoverState(errs, &epochs, 0, now), test.ShouldEqual, StateInitializing)
+	epochs.Add(1)
+	test.That(t, RoverState(errs, &epochs, 1, now), test.ShouldEqual, StateWaitingForFix)
+	test.That(t, RoverState(errs, &epochs, FixQualityRTKFixed, now), test.ShouldEqual, StateHealthy)
+	test.That(t, RoverState(errs, &epochs, FixQualityRTKFixed, now.Add(StaleAfter+time.Second)),
+		test.ShouldEqual, StateDegraded)
+
+	errs.SetFatal(ErrorSerial, errors.New("permission denied"))
+	test.That(t, RoverState(errs, &epochs, FixQualityRTKFixed, now), test.ShouldEqual, StateError)
+}
+
+func TestGPSOnlyState(t *testing.T) {
+	var corrections Counter
+	now := time.Now()
+
+	test.That(t, GPSOnlyState(StateWaitingForFix, &corrections, now), test.ShouldEqual, StateGPSOnly)
+	test.That(t, GPSOnlyState(StateInitializing, &corrections, now), test.ShouldEqual, StateInitializing)
+	corrections.Add(1)
+	test.That(t, GPSOnlyState(StateWaitingForFix, &corrections, now), test.ShouldEqual, StateWaitingForFix)
+	test.That(t, GPSOnlyState(StateWaitingForFix, &corrections, now.Add(StaleAfter+time.Second)),
+		test.ShouldEqual, StateGPSOnly)
+	test.That(t, GPSOnlyState(StateHealthy, &corrections, now.Add(StaleAfter+time.Second)), test.ShouldEqual, StateHealthy)
+}
+
+func TestDeviceState(t *testing.T) {
+	recovery := NewRecovery(RecoveryPolicy{MaxBackoff: time.Millisecond}, NewErrorHistory(), golog.NewTestLogger(t))
+	open := ErrorClass{Category: ErrorSerial, Op: OpOpen}
+	absent := &fs.PathError{Op: "open", Path: "/dev/ttyACM0", Err: fs.ErrNotExist}
+
+	test.That(t, DeviceState(StateInitializing, recovery), test.ShouldEqual, StateInitializing)
+	recovery.Handle(context.Background(), open, absent)
+	test.That(t, DeviceState(StateInitializing, recovery), test.ShouldEqual, StateWaitingForDevice)
+	test.That(t, DeviceState(StateDegraded, recovery), test.ShouldEqual, StateWaitingForDevice)
+	test.That(t, DeviceState(StateError, recovery), test.ShouldEqual, StateError)
+	recovery.Succeeded(open)
+	test.That(t, DeviceState(StateInitializing, recovery), test.ShouldEqual, StateInitializing)
+}
+
+func TestStationState(t *testing.T) {
+	errs := NewErrorHistory()
+	var frames Counter
+	now := time.Now()
+
+	test.That(t, StationState(errs, &frames, true, now), test.ShouldEqual, StateSurveying)
+	test.That(t, StationState(errs, &frames, false, now), test.ShouldEqual, StateInitializing)
+	frames.Add(3)
+	test.That(t, StationState(errs, &frames, true, now), test.ShouldEqual, StateHealthy)
+	test.That(t, StationState(errs, &frames, true, now.Add(StaleAfter+time.Second)), test.ShouldEqual, StateDegraded)
+
+	errs.SetFatal(ErrorI2C, errors.New("bus gone"))
+	test.That(t, StationState(errs, &frames, true, now), test.ShouldEqual, StateError)
+}
@@ -0,0 +1,46 @@
+package rtkutils
+
+import (
+	"github.com/go-gnss/rtcm/rtcm3"
+)
+
+// StreamDecoder decodes the messages of a station's stream as it is read, for the checks that look at
+// the observations rather than forward the frames. The rtcm messages, and the GPS ephemerides of the
+// UBX-RXM-SFRBX subframes a u-blox receiver outputs among them, are passed to each handler in order.
+type StreamDecoder struct {
+	handlers []func(rtcm3.Message)
+
+	rtcm      []byte // start of a frame cut off by the end of the last write
+	ubx       []byte
+	subframes GPSSubframes
+}
+
+// NewStreamDecoder returns a decoder passing the messages of the stream to handlers.
+func NewStreamDecoder(handlers ...func(rtcm3.Message)) *StreamDecoder {
+	return &StreamDecoder{handlers: handlers}
+}
+
+// Write decodes the next bytes of the stream. It never fails, so that a decoder read through an
+// io.TeeReader can't interrupt the stream.
+func (d *StreamDecoder) Write(p []byte) (int, error) {
+	var frames [][]byte
+	frames, d.rtcm = SplitRTCMFrames(append(d.rtcm, p...))
+	for _, frame := range frames {
+		d.handle(rtcm3.DeserializeMessage(frame[3 : len(frame)-3]))
+	}
+	frames, d.ubx = SplitUBXFrames(append(d.ubx, p...))
+	for _, frame := range frames {
+		if cls, id, payload := UBXFrame(frame); cls == UBXClassRxm && id == UBXRxmSfrbx {
+			if eph, ok := d.subframes.Add(payload); ok {
+				d.handle(eph)
+			}
+		}
+	}
+	return len(p), nil
+}
+
+func (d *StreamDecoder) handle(msg rtcm3.Message) {
+	for _, handler := range d.handlers {
+		handler(msg)
+	}
+}
@@ -0,0 +1,35 @@
+package rtkutils
+
+import (
+	"testing"
+
+	"github.com/go-gnss/rtcm/rtcm3"
+	"go.viam.com/test"
+)
+
+func TestStreamDecoder(t *testing.T) {
+	var messages []rtcm3.Message
+	d := NewStreamDecoder(func(msg rtcm3.Message) { messages = append(messages, msg) })
+
+	// an rtcm frame between the subframes of an ephemeris, split across writes
+	eph := testEphemeris(7)
+	var stream []byte
+	for i, payload := range sfrbxPayloads(eph) {
+		stream = append(stream, UBXPacket(UBXClassRxm, UBXRxmSfrbx, payload)...)
+		if i == 0 {
+			stream = append(stream, rtcm3.EncapsulateByteArray([]byte{0x3E, 0xD0, 0x00}).Serialize()...)
+		}
+	}
+	for len(stream) > 0 {
+		n := 17
+		if n > len(stream) {
+			n = len(stream)
+		}
+		written, err := d.Write(stream[:n])
+		test.That(t, written, test.ShouldEqual, n)
+		test.That(t, err, test.ShouldBeNil)
+		stream = stream[n:]
+	}
+	test.That(t, messages, test.ShouldHaveLength, 2)
+	test.That(t, messages[1], test.ShouldResemble, eph)
+}
@@ -0,0 +1,94 @@
+package rtkutils
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/edaniels/golog"
+)
+
+// RestartsKey is the reading with the number of background worker restarts.
+const RestartsKey = "worker_restarts"
+
+const (
+	minRestartBackoff = time.Second
+	maxRestartBackoff = time.Minute
+	// a worker that ran for this long before stopping is restarted after the minimum backoff again
+	restartBackoffReset = 5 * time.Minute
+)
+
+// Supervisor runs the background workers of a component and restarts those that stop
+// unexpectedly, with a backoff between restarts, so that one bad read doesn't stop the
+// component for good.
+type Supervisor struct {
+	ctx      context.Context
+	workers  *sync.WaitGroup
+	logger   golog.Logger
+	restarts Counter
+
+	minBackoff time.Duration
+}
+
+// NewSupervisor returns a supervisor of workers that stop once ctx is done. Workers are added to
+// workers so that the component can wait for them when closing.
+func NewSupervisor(ctx context.Context, workers *sync.WaitGroup, logger golog.Logger) *Supervisor {
+	return &Supervisor{ctx: ctx, workers: workers, logger: logger, minBackoff: minRestartBackoff}
+}
+
+// Go runs worker in the background. A worker returns nil when it is done on purpose, e.g. once ctx
+// is done or a replay has finished. If it panics or returns an error before ctx is done it is
+// restarted, unless the error is fatal.
+func (s *Supervisor) Go(name string, worker func() error) {
+	s.workers.Add(1)
+	go func() {
+		defer s.workers.Done()
+		backoff := s.minBackoff
+		for {
+			started := time.Now()
+			err := s.run(worker)
+			if err == nil || s.ctx.Err() != nil {
+				return
+			}
+			if IsFatal(err) {
+				s.logger.Errorf("%s stopped: %s", name, err)
+				return
+			}
+
+			if time.Since(started) > restartBackoffReset {
+				backoff = s.minBackoff
+			}
+			s.logger.Errorf("%s stopped unexpectedly, restarting it in %s: %s", name, backoff, err)
+			select {
+			case <-s.ctx.Done():
+				return
+			case <-time.After(backoff):
+			}
+			s.restarts.Add(1)
+			if backoff *= 2; backoff > maxRestartBackoff {
+				backoff = maxRestartBackoff
+			}
+		}
+	}()
+}
+
+// run runs worker, turning a panic into an error.
+func (s *Supervisor) run(worker func() error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			s.logger.Errorf("worker panicked: %v\n%s", r, debug.Stack())
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return worker()
+}
+
+// Restarts returns how many times workers were restarted.
+func (s *Supervisor) Restarts() uint64 {
+	if s == nil {
+		return 0
+	}
+	return s.restarts.Get()
+}
@@ -0,0 +1,55 @@
+package rtkutils
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/edaniels/golog"
+	"go.viam.com/test"
+)
+
+func TestSupervisor(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var workers sync.WaitGroup
+	s := NewSupervisor(ctx, &workers, golog.NewTestLogger(t))
+	s.minBackoff = time.Millisecond
+
+	// a worker that panics, then fails, then finishes
+	runs := 0
+	s.Go("flaky", func() error {
+		runs++
+		switch runs {
+		case 1:
+			var m map[string]int
+			m["a"]++
+		case 2:
+			return errors.New("port closed")
+		}
+		return nil
+	})
+	// fatal errors are not restarted
+	s.Go("fatal", func() error { return ErrRebuildRequired })
+	workers.Wait()
+	test.That(t, runs, test.ShouldEqual, 3)
+	test.That(t, s.Restarts(), test.ShouldEqual, 2)
+
+	// workers stop restarting once ctx is done
+	s.Go("failing", func() error { return errors.New("no data") })
+	cancel()
+	done := make(chan struct{})
+	go func() {
+		workers.Wait()
+		close(done)
+	}()
+	stopped := false
+	select {
+	case <-done:
+		stopped = true
+	case <-time.After(time.Second):
+	}
+	test.That(t, stopped, test.ShouldBeTrue)
+}
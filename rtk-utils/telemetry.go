@@ -0,0 +1,181 @@
+package rtkutils
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	geo "github.com/kellydunn/golang-geo"
+)
+
+// Formats of the position packets a rover sends as telemetry.
+const (
+	// a json object, see Telemetry
+	TelemetryFormatJSON = "json"
+	// a GGA sentence
+	TelemetryFormatNMEA = "nmea"
+)
+
+// ValidateTelemetry checks the udp endpoints of a rover's telemetry, host:port each, and its format.
+func ValidateTelemetry(endpoints []string, format string) error {
+	switch format {
+	case "", TelemetryFormatJSON, TelemetryFormatNMEA:
+	default:
+		return fmt.Errorf("telemetry_format %q isn't supported, use %s or %s", format, TelemetryFormatJSON, TelemetryFormatNMEA)
+	}
+	if format != "" && len(endpoints) == 0 {
+		return errors.New("telemetry_format needs telemetry_endpoints")
+	}
+	for _, endpoint := range endpoints {
+		_, port, err := net.SplitHostPort(endpoint)
+		if n, portErr := strconv.Atoi(port); err != nil || portErr != nil || n < 1 || n > 65535 {
+			return fmt.Errorf("telemetry_endpoints %q isn't a host:port", endpoint)
+		}
+	}
+	return nil
+}
+
+// TelemetryPosition is the position of an epoch with a fix a rover sends as telemetry.
+type TelemetryPosition struct {
+	Name       string // of the rover
+	Position   *geo.Point
+	Alt        float64 // m above mean sea level
+	FixQuality int
+	Satellites int
+	HDOP       float64
+	Speed      float64 // m/s
+	Heading    float64 // compass heading in degrees, NaN without one
+	Accuracy   float64 // horizontal, in m, 0 if the receiver doesn't estimate it
+}
+
+// Telemetry sends the position of every epoch with a fix to udp endpoints, for dashboards that can't
+// read the movement sensor's API. A packet is a json object with the "name" of the rover, the "time"
+// it was sent, "lat", "lng", "alt", the "fix" and its "fix_quality", "satellites", "hdop",
+// "speed_mps", and "heading_deg" and "h_acc_m" when there is one, or a GGA sentence. An endpoint
+// that fails is dialed again with the next packet. A nil Telemetry sends nothing.
+type Telemetry struct {
+	endpoints []string
+	format    string
+
+	mu     sync.Mutex
+	conns  []net.Conn // by endpoint, nil until dialed
+	sent   Counter
+	failed Counter
+}
+
+// NewTelemetry returns a Telemetry sending packets in format, json if empty, to endpoints, or nil if
+// there are none.
+func NewTelemetry(endpoints []string, format string) *Telemetry {
+	if len(endpoints) == 0 {
+		return nil
+	}
+	if format == "" {
+		format = TelemetryFormatJSON
+	}
+	return &Telemetry{endpoints: endpoints, format: format, conns: make([]net.Conn, len(endpoints))}
+}
+
+// Send sends pos, at now, to every endpoint.
+func (t *Telemetry) Send(pos TelemetryPosition, now time.Time) {
+	if t == nil || pos.Position == nil {
+		return
+	}
+	packet, err := t.packet(pos, now)
+	if err != nil {
+		t.failed.Add(1)
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for i, endpoint := range t.endpoints {
+		if t.conns[i] == nil {
+			conn, err := net.Dial("udp", endpoint)
+			if err != nil {
+				t.failed.Add(1)
+				continue
+			}
+			t.conns[i] = conn
+		}
+		if _, err := t.conns[i].Write(packet); err != nil {
+			t.failed.Add(1)
+			t.conns[i].Close()
+			t.conns[i] = nil
+			continue
+		}
+		t.sent.Add(1)
+	}
+}
+
+// packet returns the packet of pos in the format of t.
+func (t *Telemetry) packet(pos TelemetryPosition, now time.Time) ([]byte, error) {
+	if t.format == TelemetryFormatNMEA {
+		return ggaSentence(pos, now)
+	}
+	packet := map[string]interface{}{
+		"name":        pos.Name,
+		"time":        now.UTC().Format(time.RFC3339Nano),
+		"lat":         pos.Position.Lat(),
+		"lng":         pos.Position.Lng(),
+		"alt":         pos.Alt,
+		"fix":         fixName(pos.FixQuality),
+		"fix_quality": pos.FixQuality,
+		"satellites":  pos.Satellites,
+		"hdop":        pos.HDOP,
+		"speed_mps":   pos.Speed,
+	}
+	if !math.IsNaN(pos.Heading) {
+		packet["heading_deg"] = pos.Heading
+	}
+	if pos.Accuracy > 0 {
+		packet[AccuracyHorizontal] = pos.Accuracy
+	}
+	return json.Marshal(packet)
+}
+
+// ggaSentence returns a GGA sentence of pos at now, without the geoid separation and the age and
+// station of the corrections.
+func ggaSentence(pos TelemetryPosition, now time.Time) ([]byte, error) {
+	lat, ns := pos.Position.Lat(), "N"
+	if lat < 0 {
+		lat, ns = -lat, "S"
+	}
+	lng, ew := pos.Position.Lng(), "E"
+	if lng < 0 {
+		lng, ew = -lng, "W"
+	}
+	latDeg, lngDeg := math.Floor(lat), math.Floor(lng)
+	return NMEASentence(fmt.Sprintf("GNGGA,%s,%02.0f%010.7f,%s,%03.0f%010.7f,%s,%d,%02d,%.1f,%.3f,M,,M,,",
+		now.UTC().Format("150405.00"), latDeg, (lat-latDeg)*60, ns, lngDeg, (lng-lngDeg)*60, ew,
+		pos.FixQuality, pos.Satellites, pos.HDOP, pos.Alt))
+}
+
+// Readings returns how many packets were sent, and failed to be.
+func (t *Telemetry) Readings() map[string]interface{} {
+	if t == nil {
+		return map[string]interface{}{}
+	}
+	return map[string]interface{}{
+		"telemetry_packets_sent": t.sent.Get(),
+		"telemetry_send_errors":  t.failed.Get(),
+	}
+}
+
+// Close closes the connections to the endpoints.
+func (t *Telemetry) Close() {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for i, conn := range t.conns {
+		if conn != nil {
+			conn.Close()
+			t.conns[i] = nil
+		}
+	}
+}
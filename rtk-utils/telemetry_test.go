@@ -0,0 +1,69 @@
+package rtkutils
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"net"
+	"testing"
+	"time"
+
+	geo "github.com/kellydunn/golang-geo"
+	"go.viam.com/test"
+)
+
+func TestValidateTelemetry(t *testing.T) {
+	test.That(t, ValidateTelemetry(nil, ""), test.ShouldBeNil)
+	test.That(t, ValidateTelemetry([]string{"192.168.1.10:5005", "dashboard.local:5006"}, TelemetryFormatNMEA), test.ShouldBeNil)
+	test.That(t, ValidateTelemetry(nil, TelemetryFormatJSON), test.ShouldBeError,
+		errors.New("telemetry_format needs telemetry_endpoints"))
+	test.That(t, ValidateTelemetry([]string{"localhost:5005"}, "csv"), test.ShouldBeError,
+		errors.New(`telemetry_format "csv" isn't supported, use json or nmea`))
+	test.That(t, ValidateTelemetry([]string{"localhost"}, ""), test.ShouldBeError,
+		errors.New(`telemetry_endpoints "localhost" isn't a host:port`))
+	test.That(t, ValidateTelemetry([]string{"localhost:0"}, ""), test.ShouldBeError,
+		errors.New(`telemetry_endpoints "localhost:0" isn't a host:port`))
+}
+
+func TestTelemetry(t *testing.T) {
+	test.That(t, NewTelemetry(nil, ""), test.ShouldBeNil)
+
+	dashboard, err := net.ListenPacket("udp", "127.0.0.1:0")
+	test.That(t, err, test.ShouldBeNil)
+	defer dashboard.Close()
+	read := func() []byte {
+		t.Helper()
+		buf := make([]byte, 1024)
+		test.That(t, dashboard.SetReadDeadline(time.Now().Add(time.Second)), test.ShouldBeNil)
+		n, _, err := dashboard.ReadFrom(buf)
+		test.That(t, err, test.ShouldBeNil)
+		return buf[:n]
+	}
+
+	pos := TelemetryPosition{
+		Name: "rover", Position: geo.NewPoint(40.5, -105.25), Alt: 1600.5, FixQuality: FixQualityRTKFixed,
+		Satellites: 12, HDOP: 0.8, Speed: 1.5, Heading: math.NaN(), Accuracy: 0.014,
+	}
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	telemetry := NewTelemetry([]string{dashboard.LocalAddr().String()}, "")
+	defer telemetry.Close()
+	telemetry.Send(pos, now)
+	var packet map[string]interface{}
+	test.That(t, json.Unmarshal(read(), &packet), test.ShouldBeNil)
+	test.That(t, packet, test.ShouldResemble, map[string]interface{}{
+		"name": "rover", "time": "2026-10-16T12:00:00Z", "lat": 40.5, "lng": -105.25, "alt": 1600.5,
+		"fix": "rtk_fixed", "fix_quality": 4.0, "satellites": 12.0, "hdop": 0.8, "speed_mps": 1.5, "h_acc_m": 0.014,
+	})
+
+	// epochs without a position aren't sent
+	telemetry.Send(TelemetryPosition{}, now)
+	test.That(t, telemetry.Readings(), test.ShouldResemble, map[string]interface{}{
+		"telemetry_packets_sent": uint64(1), "telemetry_send_errors": uint64(0),
+	})
+
+	nmea := NewTelemetry([]string{dashboard.LocalAddr().String()}, TelemetryFormatNMEA)
+	defer nmea.Close()
+	nmea.Send(pos, now)
+	test.That(t, string(read()), test.ShouldEqual,
+		"$GNGGA,120000.00,4030.0000000,N,10515.0000000,W,4,12,0.8,1600.500,M,,M,,*46\r\n")
+}
@@ -0,0 +1,197 @@
+package rtkutils
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"sync"
+	"time"
+
+	"go.viam.com/rdk/components/movementsensor/gpsnmea"
+)
+
+// ExportTrackCommand returns the fixes of the last "minutes" minutes as a GeoJSON or GPX document.
+const ExportTrackCommand = "export_track"
+
+// Track export formats.
+const (
+	TrackFormatGeoJSON = "geojson"
+	TrackFormatGPX     = "gpx"
+)
+
+// TrackPoint is a single fix of a track.
+type TrackPoint struct {
+	Time       time.Time
+	Lat, Lng   float64
+	Alt        float64
+	FixQuality int
+}
+
+// Track keeps the fixes of the last few minutes in memory and optionally appends every fix to a
+// csv file on disk. A nil track records nothing.
+type Track struct {
+	keep time.Duration
+
+	mu     sync.Mutex
+	points []TrackPoint // oldest to newest
+	file   *os.File
+}
+
+// NewTrack returns a track keeping keep worth of fixes, also written to path if it is set.
+// It returns nil if keep is not positive.
+func NewTrack(keep time.Duration, path string) (*Track, error) {
+	if keep <= 0 {
+		return nil, nil
+	}
+	t := &Track{keep: keep}
+	if path != "" {
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return nil, err
+		}
+		t.file = f
+	}
+	return t, nil
+}
+
+// Add records the location of a complete epoch. Epochs without a valid location are skipped.
+func (t *Track) Add(data gpsnmea.GPSData, now time.Time) error {
+	if t == nil || data.Location == nil {
+		return nil
+	}
+	lat, lng := data.Location.Lat(), data.Location.Lng()
+	if math.IsNaN(lat) || math.IsNaN(lng) || (lat == 0 && lng == 0) {
+		return nil
+	}
+	p := TrackPoint{Time: now.UTC(), Lat: lat, Lng: lng, Alt: data.Alt, FixQuality: data.FixQuality}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.points = append(t.points, p)
+	drop := 0
+	for drop < len(t.points) && now.Sub(t.points[drop].Time) > t.keep {
+		drop++
+	}
+	t.points = t.points[drop:]
+
+	if t.file == nil {
+		return nil
+	}
+	_, err := fmt.Fprintf(t.file, "%s,%.9f,%.9f,%.3f,%d\n", p.Time.Format(time.RFC3339Nano), p.Lat, p.Lng, p.Alt, p.FixQuality)
+	return err
+}
+
+// Since returns the points recorded at or after start.
+func (t *Track) Since(start time.Time) []TrackPoint {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var points []TrackPoint
+	for _, p := range t.points {
+		if !p.Time.Before(start) {
+			points = append(points, p)
+		}
+	}
+	return points
+}
+
+// Close closes the track file.
+func (t *Track) Close() error {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.file == nil {
+		return nil
+	}
+	err := t.file.Close()
+	t.file = nil
+	return err
+}
+
+// ExportTrackResult answers an ExportTrackCommand with the "format" (geojson by default) and
+// "minutes" (everything kept by default) arguments.
+func ExportTrackResult(t *Track, cmd map[string]interface{}) (map[string]interface{}, error) {
+	if t == nil {
+		return nil, errors.New("track recording is not enabled, set track_minutes")
+	}
+	start := time.Time{}
+	if minutes, ok := cmd["minutes"].(float64); ok && minutes > 0 {
+		start = time.Now().Add(-time.Duration(minutes * float64(time.Minute)))
+	}
+	points := t.Since(start)
+
+	format, _ := cmd["format"].(string)
+	var (
+		doc []byte
+		err error
+	)
+	switch format {
+	case TrackFormatGeoJSON, "":
+		format = TrackFormatGeoJSON
+		doc, err = TrackGeoJSON(points)
+	case TrackFormatGPX:
+		doc, err = TrackGPX(points)
+	default:
+		return nil, fmt.Errorf("unknown track format %q, must be %s or %s", format, TrackFormatGeoJSON, TrackFormatGPX)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"format": format, "points": len(points), "track": string(doc)}, nil
+}
+
+// TrackGeoJSON returns the points as a GeoJSON feature collection holding a single line string,
+// with the time and fix quality of every point as properties.
+func TrackGeoJSON(points []TrackPoint) ([]byte, error) {
+	coords := make([][]float64, 0, len(points))
+	times := make([]string, 0, len(points))
+	fixes := make([]int, 0, len(points))
+	for _, p := range points {
+		coords = append(coords, []float64{p.Lng, p.Lat, p.Alt})
+		times = append(times, p.Time.Format(time.RFC3339Nano))
+		fixes = append(fixes, p.FixQuality)
+	}
+	return json.Marshal(map[string]interface{}{
+		"type": "FeatureCollection",
+		"features": []interface{}{map[string]interface{}{
+			"type":       "Feature",
+			"geometry":   map[string]interface{}{"type": "LineString", "coordinates": coords},
+			"properties": map[string]interface{}{"times": times, "fix_quality": fixes},
+		}},
+	})
+}
+
+type gpx struct {
+	XMLName xml.Name `xml:"gpx"`
+	Xmlns   string   `xml:"xmlns,attr"`
+	Version string   `xml:"version,attr"`
+	Creator string   `xml:"creator,attr"`
+	Points  []gpxPt  `xml:"trk>trkseg>trkpt"`
+}
+
+type gpxPt struct {
+	Lat  float64 `xml:"lat,attr"`
+	Lon  float64 `xml:"lon,attr"`
+	Ele  float64 `xml:"ele"`
+	Time string  `xml:"time"`
+}
+
+// TrackGPX returns the points as a GPX 1.1 document holding a single track segment.
+func TrackGPX(points []TrackPoint) ([]byte, error) {
+	doc := gpx{Xmlns: "http://www.topografix.com/GPX/1/1", Version: "1.1", Creator: "rtk-system"}
+	for _, p := range points {
+		doc.Points = append(doc.Points, gpxPt{Lat: p.Lat, Lon: p.Lng, Ele: p.Alt, Time: p.Time.Format(time.RFC3339Nano)})
+	}
+	out, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), out...), nil
+}
@@ -0,0 +1,58 @@
+package rtkutils
+
+import (
+	"encoding/json"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	geo "github.com/kellydunn/golang-geo"
+	"go.viam.com/rdk/components/movementsensor/gpsnmea"
+	"go.viam.com/test"
+)
+
+func TestTrack(t *testing.T) {
+	track, err := NewTrack(0, "")
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, track, test.ShouldBeNil)
+	_, err = ExportTrackResult(track, map[string]interface{}{})
+	test.That(t, err, test.ShouldNotBeNil)
+
+	path := filepath.Join(t.TempDir(), "track.csv")
+	track, err = NewTrack(10*time.Minute, path)
+	test.That(t, err, test.ShouldBeNil)
+
+	now := time.Now()
+	fix := gpsnmea.GPSData{Location: geo.NewPoint(37.3911, -122.0378), Alt: 18.9, FixQuality: FixQualityRTKFixed}
+	test.That(t, track.Add(fix, now.Add(-20*time.Minute)), test.ShouldBeNil)
+	test.That(t, track.Add(fix, now.Add(-5*time.Minute)), test.ShouldBeNil)
+	test.That(t, track.Add(gpsnmea.GPSData{Location: geo.NewPoint(math.NaN(), math.NaN())}, now), test.ShouldBeNil)
+	test.That(t, track.Add(fix, now), test.ShouldBeNil)
+
+	// points older than the kept window are dropped, and invalid locations skipped
+	test.That(t, track.Since(time.Time{}), test.ShouldHaveLength, 2)
+
+	resp, err := ExportTrackResult(track, map[string]interface{}{"minutes": 1.0})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, resp["format"], test.ShouldEqual, TrackFormatGeoJSON)
+	test.That(t, resp["points"], test.ShouldEqual, 1)
+	var doc map[string]interface{}
+	test.That(t, json.Unmarshal([]byte(resp["track"].(string)), &doc), test.ShouldBeNil)
+	test.That(t, doc["type"], test.ShouldEqual, "FeatureCollection")
+
+	resp, err = ExportTrackResult(track, map[string]interface{}{"format": "gpx"})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, resp["points"], test.ShouldEqual, 2)
+	test.That(t, resp["track"], test.ShouldContainSubstring, `<trkpt lat="37.3911" lon="-122.0378">`)
+
+	_, err = ExportTrackResult(track, map[string]interface{}{"format": "kml"})
+	test.That(t, err, test.ShouldNotBeNil)
+
+	test.That(t, track.Close(), test.ShouldBeNil)
+	written, err := os.ReadFile(path)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, strings.Count(string(written), "\n"), test.ShouldEqual, 3)
+}
@@ -0,0 +1,184 @@
+package rtkutils
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"time"
+
+	"github.com/d2r2/go-i2c"
+	"github.com/d2r2/go-logger"
+)
+
+// I2CReader reads the data buffer of a receiver on an i2c bus as a stream, waiting for the
+// receiver to have new data. The bus is opened and closed for every read, so that other devices
+// can share it.
+type I2CReader struct {
+	ctx     context.Context
+	bus     int
+	addr    byte
+	poll    time.Duration // wait after a read with no data
+	usage   *ReadBufferUsage
+	buf     []byte
+	pending []byte // read from the receiver but not returned yet
+}
+
+// NewI2CReader returns a reader of the receiver at addr on bus, polling it every poll while it has
+// no data, until ctx is done. Each read takes up to the size of usage from the receiver's buffer.
+func NewI2CReader(ctx context.Context, bus int, addr byte, poll time.Duration, usage *ReadBufferUsage) *I2CReader {
+	return &I2CReader{ctx: ctx, bus: bus, addr: addr, poll: poll, usage: usage, buf: make([]byte, usage.Size())}
+}
+
+// Read copies the next bytes of the data buffer into p, without the idle padding.
+func (r *I2CReader) Read(p []byte) (int, error) {
+	for len(r.pending) == 0 {
+		if err := r.ctx.Err(); err != nil {
+			return 0, err
+		}
+		handle, err := i2c.NewI2C(r.addr, r.bus)
+		if err != nil {
+			return 0, err
+		}
+		// change so you don't see a million logs
+		logger.ChangePackageLogLevel("i2c", logger.InfoLevel)
+
+		n, err := handle.ReadBytes(r.buf)
+		if closeErr := handle.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return 0, err
+		}
+		r.pending = bytes.TrimRight(r.buf[:n], "\xff")
+		r.usage.Record(len(r.pending))
+		if len(r.pending) == 0 {
+			// the receiver has nothing to send yet
+			select {
+			case <-r.ctx.Done():
+			case <-time.After(r.poll):
+			}
+		}
+	}
+	n := copy(p, r.pending)
+	r.pending = r.pending[n:]
+	return n, nil
+}
+
+// Close does nothing, the bus is already closed after every read.
+func (r *I2CReader) Close() error {
+	return nil
+}
+
+// TimeoutConn is a connection whose reads and writes fail instead of blocking for longer than
+// their timeout, if set.
+type TimeoutConn struct {
+	net.Conn
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+}
+
+func (c *TimeoutConn) Read(p []byte) (int, error) {
+	if c.ReadTimeout > 0 {
+		if err := c.Conn.SetReadDeadline(time.Now().Add(c.ReadTimeout)); err != nil {
+			return 0, err
+		}
+	}
+	return c.Conn.Read(p)
+}
+
+func (c *TimeoutConn) Write(p []byte) (int, error) {
+	if c.WriteTimeout > 0 {
+		if err := c.Conn.SetWriteDeadline(time.Now().Add(c.WriteTimeout)); err != nil {
+			return 0, err
+		}
+	}
+	return c.Conn.Write(p)
+}
+
+// TLSConfig secures a tcp or ntrip connection with TLS. The server is verified against the system
+// roots, or against CAFile for a station with its own certificate authority, and CertFile and
+// KeyFile authenticate the client to servers that require it.
+type TLSConfig struct {
+	Enabled  bool
+	CAFile   string
+	CertFile string
+	KeyFile  string
+}
+
+// Validate checks that the files of the config exist and that a client certificate has a key.
+func (c TLSConfig) Validate(prefix string) error {
+	if !c.Enabled {
+		if c.CAFile != "" || c.CertFile != "" || c.KeyFile != "" {
+			return fmt.Errorf("%stls_ca_file, %stls_cert_file and %stls_key_file are only used with %stls", prefix, prefix, prefix, prefix)
+		}
+		return nil
+	}
+	if (c.CertFile == "") != (c.KeyFile == "") {
+		return fmt.Errorf("%stls_cert_file and %stls_key_file must be set together", prefix, prefix)
+	}
+	files := []struct{ field, file string }{
+		{"tls_ca_file", c.CAFile}, {"tls_cert_file", c.CertFile}, {"tls_key_file", c.KeyFile},
+	}
+	for _, f := range files {
+		if f.file == "" {
+			continue
+		}
+		if _, err := os.Stat(f.file); err != nil {
+			return fmt.Errorf("%s%s %q can't be read: %w", prefix, f.field, f.file, err)
+		}
+	}
+	return nil
+}
+
+// ClientConfig returns the configuration of a TLS connection to serverName.
+func (c TLSConfig) ClientConfig(serverName string) (*tls.Config, error) {
+	conf := &tls.Config{ServerName: serverName, MinVersion: tls.VersionTLS12}
+	if c.CAFile != "" {
+		pem, err := os.ReadFile(c.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		conf.RootCAs = x509.NewCertPool()
+		if !conf.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("tls_ca_file %q has no PEM certificates", c.CAFile)
+		}
+	}
+	if c.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		conf.Certificates = []tls.Certificate{cert}
+	}
+	return conf, nil
+}
+
+// DialTCP connects to the host:port addr, over TLS if conf enables it, giving up after timeout.
+func DialTCP(ctx context.Context, addr string, timeout time.Duration, conf TLSConfig) (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: timeout}
+	if !conf.Enabled {
+		return dialer.DialContext(ctx, "tcp", addr)
+	}
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	tlsConf, err := conf.ClientConfig(host)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := (&tls.Dialer{NetDialer: dialer, Config: tlsConf}).DialContext(ctx, "tcp", addr)
+	if err != nil {
+		var unknownErr x509.UnknownAuthorityError
+		if errors.As(err, &unknownErr) {
+			return nil, fmt.Errorf("%s isn't trusted, set tls_ca_file to the authority that signed its certificate: %w", addr, err)
+		}
+		return nil, err
+	}
+	return conn, nil
+}
@@ -0,0 +1,110 @@
+package rtkutils
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"go.viam.com/test"
+)
+
+func TestTimeoutConn(t *testing.T) {
+	client, server := net.Pipe()
+	defer server.Close()
+	conn := &TimeoutConn{Conn: client, ReadTimeout: 10 * time.Millisecond, WriteTimeout: 10 * time.Millisecond}
+	defer conn.Close()
+
+	// nothing is sent or read on the other end
+	_, err := conn.Read(make([]byte, 1))
+	test.That(t, errors.Is(err, os.ErrDeadlineExceeded), test.ShouldBeTrue)
+	_, err = conn.Write([]byte{1})
+	test.That(t, errors.Is(err, os.ErrDeadlineExceeded), test.ShouldBeTrue)
+
+	go server.Write([]byte{2})
+	buf := make([]byte, 1)
+	n, err := conn.Read(buf)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, buf[:n], test.ShouldResemble, []byte{2})
+}
+
+// tlsServer serves "ok" over TLS on a local port with a self-signed certificate, returning its
+// address and the path of the certificate.
+func tlsServer(t *testing.T) (string, string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	test.That(t, err, test.ShouldBeNil)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	test.That(t, err, test.ShouldBeNil)
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	test.That(t, os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600), test.ShouldBeNil)
+
+	l, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}},
+	})
+	test.That(t, err, test.ShouldBeNil)
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			conn.Write([]byte("ok"))
+			conn.Close()
+		}
+	}()
+	return l.Addr().String(), caFile
+}
+
+func TestDialTCP(t *testing.T) {
+	addr, caFile := tlsServer(t)
+	ctx := context.Background()
+
+	conf := TLSConfig{Enabled: true, CAFile: caFile}
+	test.That(t, conf.Validate("input."), test.ShouldBeNil)
+	conn, err := DialTCP(ctx, addr, time.Second, conf)
+	test.That(t, err, test.ShouldBeNil)
+	buf := make([]byte, 2)
+	_, err = conn.Read(buf)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, string(buf), test.ShouldEqual, "ok")
+	test.That(t, conn.Close(), test.ShouldBeNil)
+
+	// the self-signed certificate isn't trusted by the system roots
+	_, err = DialTCP(ctx, addr, time.Second, TLSConfig{Enabled: true})
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, strings.Contains(err.Error(), "set tls_ca_file"), test.ShouldBeTrue)
+}
+
+func TestValidateTLSConfig(t *testing.T) {
+	test.That(t, TLSConfig{}.Validate("input."), test.ShouldBeNil)
+	test.That(t, TLSConfig{CAFile: "ca.pem"}.Validate("input."), test.ShouldBeError,
+		errors.New("input.tls_ca_file, input.tls_cert_file and input.tls_key_file are only used with input.tls"))
+	test.That(t, TLSConfig{Enabled: true, CertFile: "cert.pem"}.Validate(""), test.ShouldBeError,
+		errors.New("tls_cert_file and tls_key_file must be set together"))
+	err := TLSConfig{Enabled: true, CAFile: "missing.pem"}.Validate("")
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, strings.HasPrefix(err.Error(), "tls_ca_file \"missing.pem\" can't be read"), test.ShouldBeTrue)
+}
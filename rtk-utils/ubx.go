@@ -0,0 +1,167 @@
+package rtkutils
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strings"
+)
+
+// UBX port ids used by CFG-PRT and CFG-MSG.
+const (
+	UBXPortI2C   = 0
+	UBXPortUART1 = 1
+	UBXPortUART2 = 2
+	UBXPortUSB   = 3
+)
+
+// UBX protocol masks used by CFG-PRT.
+const (
+	UBXProtoUBX   = 0x01
+	UBXProtoNMEA  = 0x02
+	UBXProtoRTCM3 = 0x20
+)
+
+const (
+	ubxSync1       = 0xB5
+	ubxSync2       = 0x62
+	ubxHeaderLen   = 6 // sync characters, class, id and length
+	ubxClassAck    = 0x05
+	ubxAckNak      = 0x00
+	ubxAckAck      = 0x01
+	ubxClassCfg    = 0x06
+	ubxCfgPrt      = 0x00
+	ubxCfgMsg      = 0x01
+	ubxCfgRst      = 0x04
+	ubxCfgCfg      = 0x09
+	ubxDefaultAddr = 0x42 // default i2c (DDC) address of u-blox receivers
+	ubxMode8N1     = 0x08D0
+
+	// CFG-RST reset modes that stop and start the GNSS engine without resetting the receiver
+	ubxGNSSStop  = 0x08
+	ubxGNSSStart = 0x09
+)
+
+// UBX raw measurement messages used for post processing.
+const (
+	UBXClassRxm = 0x02
+	UBXRxmRawx  = 0x15 // multi-GNSS raw measurements
+	UBXRxmSfrbx = 0x13 // broadcast navigation data subframes
+)
+
+var ubxPorts = map[string]int{
+	"i2c":   UBXPortI2C,
+	"uart1": UBXPortUART1,
+	"uart2": UBXPortUART2,
+	"usb":   UBXPortUSB,
+}
+
+// UBXPortArg returns the receiver port named by the command argument key, or def if it is not set.
+func UBXPortArg(cmd map[string]interface{}, key string, def int) (int, error) {
+	v, ok := cmd[key]
+	if !ok {
+		return def, nil
+	}
+	name, ok := v.(string)
+	if !ok {
+		return 0, fmt.Errorf("%q must be one of i2c, uart1, uart2 or usb", key)
+	}
+	return UBXPort(name, def)
+}
+
+// UBXPort returns the receiver port with the name, one of i2c, uart1, uart2 or usb, or def if the name is empty.
+func UBXPort(name string, def int) (int, error) {
+	if name == "" {
+		return def, nil
+	}
+	port, ok := ubxPorts[strings.ToLower(name)]
+	if !ok {
+		return 0, fmt.Errorf("unknown receiver port %q, must be one of i2c, uart1, uart2 or usb", name)
+	}
+	return port, nil
+}
+
+// ValidateRTCMOutputPort checks the port a base receiver is configured to output rtcm on, which only
+// receivers configured with UBX messages have a choice of.
+func ValidateRTCMOutputPort(field, port, receiver string) error {
+	if port == "" {
+		return nil
+	}
+	if _, err := UBXPort(port, 0); err != nil {
+		return fmt.Errorf("%s: %w", field, err)
+	}
+	if profile, err := Receiver(receiver); err == nil && profile.BasePackets != nil {
+		return fmt.Errorf("%s is only supported on u-blox receivers, %q outputs rtcm on the port it is read from", field, receiver)
+	}
+	return nil
+}
+
+// UBXPacket frames a UBX message with its sync characters, length and checksum.
+func UBXPacket(cls, id byte, payload []byte) []byte {
+	packet := make([]byte, 0, len(payload)+8)
+	packet = append(packet, ubxSync1, ubxSync2, cls, id, byte(len(payload)), byte(len(payload)>>8))
+	packet = append(packet, payload...)
+
+	var checksumA, checksumB byte
+	for _, b := range packet[2:] {
+		checksumA += b
+		checksumB += checksumA
+	}
+	return append(packet, checksumA, checksumB)
+}
+
+// UBXAckPackets returns the ACK-ACK and ACK-NAK messages a receiver replies to a CFG message with,
+// for a ReplyWatcher.
+func UBXAckPackets(cls, id byte) (ack, nak []byte) {
+	return UBXPacket(ubxClassAck, ubxAckAck, []byte{cls, id}), UBXPacket(ubxClassAck, ubxAckNak, []byte{cls, id})
+}
+
+// UBXFrame returns the class, id and payload of a frame split by SplitUBXFrames.
+func UBXFrame(frame []byte) (cls, id byte, payload []byte) {
+	return frame[2], frame[3], frame[ubxHeaderLen : len(frame)-2]
+}
+
+// UBXPortConfigPacket builds a CFG-PRT message setting the protocols accepted and sent on a port.
+func UBXPortConfigPacket(portID, baudRate int, inProto, outProto uint16) []byte {
+	return UBXPacket(ubxClassCfg, ubxCfgPrt, UBXPortConfigPayload(portID, baudRate, inProto, outProto))
+}
+
+// UBXPortConfigPayload returns the payload of a CFG-PRT message. The baud rate only applies to the uarts.
+func UBXPortConfigPayload(portID, baudRate int, inProto, outProto uint16) []byte {
+	payload := make([]byte, 20)
+	payload[0] = byte(portID)
+	switch portID {
+	case UBXPortUART1, UBXPortUART2:
+		binary.LittleEndian.PutUint32(payload[4:], ubxMode8N1)
+		binary.LittleEndian.PutUint32(payload[8:], uint32(baudRate))
+	case UBXPortI2C:
+		binary.LittleEndian.PutUint32(payload[4:], ubxDefaultAddr<<1)
+	}
+	binary.LittleEndian.PutUint16(payload[12:], inProto)
+	binary.LittleEndian.PutUint16(payload[14:], outProto)
+	return payload
+}
+
+// UBXSaveConfigPacket builds a CFG-CFG message saving the current configuration to non-volatile memory.
+func UBXSaveConfigPacket() []byte {
+	payload := make([]byte, 12)
+	binary.LittleEndian.PutUint32(payload[4:], 0xFFFF)
+	return UBXPacket(ubxClassCfg, ubxCfgCfg, payload)
+}
+
+// UBXGNSSStopPacket builds a CFG-RST message stopping the GNSS engine, which puts the receiver in a
+// low power mode that keeps its configuration until UBXGNSSStartPacket starts it again. The receiver
+// doesn't acknowledge either.
+func UBXGNSSStopPacket() []byte {
+	return UBXPacket(ubxClassCfg, ubxCfgRst, []byte{0, 0, ubxGNSSStop, 0})
+}
+
+// UBXGNSSStartPacket builds a CFG-RST message starting the GNSS engine stopped by UBXGNSSStopPacket.
+func UBXGNSSStartPacket() []byte {
+	return UBXPacket(ubxClassCfg, ubxCfgRst, []byte{0, 0, ubxGNSSStart, 0})
+}
+
+// UBXMessageRatePacket builds a CFG-MSG message setting how often a message is output, in navigation
+// solutions, on the port the packet is sent to.
+func UBXMessageRatePacket(cls, id, rate byte) []byte {
+	return UBXPacket(ubxClassCfg, ubxCfgMsg, []byte{cls, id, rate})
+}
@@ -0,0 +1,157 @@
+package rtkutils
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Precise point positioning services broadcast by the satellites, set by the "ppp_service" attribute
+// of rovers.
+const (
+	// Galileo High Accuracy Service, broadcast on E6
+	PPPServiceHAS = "has"
+	// BeiDou PPP-B2b, broadcast on B2b by the geostationary satellites over Asia
+	PPPServiceB2b = "b2b"
+)
+
+// PPP solutions of a PPPStatus.
+const (
+	PPPSolutionNone       = "none"
+	PPPSolutionConverging = "converging"
+	PPPSolutionConverged  = "converged"
+)
+
+// unicorePPPServices are the Unicore names of the ppp services.
+var unicorePPPServices = map[string]string{
+	PPPServiceHAS: "E6-HAS",
+	PPPServiceB2b: "B2B-PPP",
+}
+
+// ValidatePPPService checks the ppp service of a rover with the named receiver.
+func ValidatePPPService(field, service, receiver string) error {
+	if service == "" {
+		return nil
+	}
+	if _, ok := unicorePPPServices[service]; !ok {
+		return fmt.Errorf("%s %q isn't supported, use %s or %s", field, service, PPPServiceHAS, PPPServiceB2b)
+	}
+	profile, err := Receiver(receiver)
+	if err != nil {
+		return err
+	}
+	if !profile.PPP {
+		return fmt.Errorf("receiver %q can't apply the corrections of %s %q", profile.Name, field, service)
+	}
+	return nil
+}
+
+// UnicoreCommand returns a command for a Unicore receiver, such as "MODE ROVER", as sent over its uart.
+func UnicoreCommand(command string) []byte {
+	return []byte(command + "\r\n")
+}
+
+// UnicoreRoverPackets are the commands that set up a Unicore receiver as an rtk rover sending the
+// sentences the rover reads every second, and its PPPNAV solution when it can apply ppp corrections.
+func UnicoreRoverPackets() [][]byte {
+	var packets [][]byte
+	for _, command := range []string{"MODE ROVER", "GNGGA 1", "GNGSA 1", "GNGSV 1", "GNRMC 1", "GNVTG 1", "PPPNAVA 1"} {
+		packets = append(packets, UnicoreCommand(command))
+	}
+	return packets
+}
+
+// UnicorePPPPackets are the commands that have a Unicore receiver apply the corrections of a ppp
+// service, or stop applying any if service is empty.
+func UnicorePPPPackets(service string) [][]byte {
+	if service == "" {
+		return [][]byte{UnicoreCommand("CONFIG PPP DISABLE")}
+	}
+	return [][]byte{
+		UnicoreCommand("CONFIG PPP ENABLE " + unicorePPPServices[service]),
+		// the ITRF datum of the services is reported as WGS84, as are the rtk positions
+		UnicoreCommand("CONFIG PPP DATUM WGS84"),
+	}
+}
+
+// PPPStatus is the precise point positioning solution a Unicore receiver reports in its PPPNAVA log.
+type PPPStatus struct {
+	Solution        string
+	HorizontalStdev float64 // m
+	VerticalStdev   float64 // m
+	Valid           bool
+}
+
+// update keeps the ppp solution reported by line. It reports whether line is a Unicore log or reply to
+// a command, that the nmea parser doesn't know.
+func (s *PPPStatus) update(line string) (unicore bool) {
+	if strings.HasPrefix(line, "$command,") {
+		return true
+	}
+	if !strings.HasPrefix(line, "#") {
+		return false
+	}
+	if star := strings.IndexByte(line, '*'); star != -1 {
+		line = line[:star]
+	}
+	// #PPPNAVA,<port>,...;<solution status>,<position type>,<lat>,<lon>,<height>,<undulation>,<datum>,
+	// <lat stdev>,<lon stdev>,<height stdev>,...
+	header, body, ok := strings.Cut(line, ";")
+	if !ok || !strings.HasPrefix(header, "#PPPNAVA,") {
+		return true
+	}
+	fields := strings.Split(strings.TrimSpace(body), ",")
+	if len(fields) < 10 {
+		return true
+	}
+	status := PPPStatus{Solution: PPPSolutionNone, Valid: true}
+	if fields[0] == "SOL_COMPUTED" {
+		switch fields[1] {
+		case "PPP_CONVERGING":
+			status.Solution = PPPSolutionConverging
+		case "PPP":
+			status.Solution = PPPSolutionConverged
+		}
+	}
+	var stdevs [3]float64
+	for i := range stdevs {
+		v, err := strconv.ParseFloat(fields[i+7], 64)
+		if err != nil {
+			return true
+		}
+		stdevs[i] = v
+	}
+	status.HorizontalStdev = math.Hypot(stdevs[0], stdevs[1])
+	status.VerticalStdev = stdevs[2]
+	*s = status
+	return true
+}
+
+// Converged reports whether the receiver has a converged ppp solution.
+func (s PPPStatus) Converged() bool {
+	return s.Solution == PPPSolutionConverged
+}
+
+// Readings returns the status as readings, whether the corrections of the ppp service are applied
+// and, once the receiver reported a solution, its standard deviations.
+func (s PPPStatus) Readings() map[string]interface{} {
+	if !s.Valid {
+		return map[string]interface{}{"ppp_solution": PPPSolutionNone, "ppp_corrections_applied": false}
+	}
+	return map[string]interface{}{
+		"ppp_solution":            s.Solution,
+		"ppp_corrections_applied": s.Solution != PPPSolutionNone,
+		"ppp_horizontal_stdev_m":  s.HorizontalStdev,
+		"ppp_vertical_stdev_m":    s.VerticalStdev,
+	}
+}
+
+// PPPState returns StateHealthy for a rover in state that is waiting for an rtk fix while its
+// receiver has a converged ppp solution, and state otherwise.
+func PPPState(state string, ppp PPPStatus) string {
+	if state == StateWaitingForFix && ppp.Converged() {
+		return StateHealthy
+	}
+	return state
+}
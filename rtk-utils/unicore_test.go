@@ -0,0 +1,66 @@
+package rtkutils
+
+import (
+	"errors"
+	"testing"
+
+	"go.viam.com/test"
+)
+
+const (
+	testPPPNAV = "#PPPNAVA,COM1,0,55.0,FINE,2209,110326.000,4363,18,18;SOL_COMPUTED,PPP,40.07898426103,116.23660826542," +
+		"60.7413,-9.2000,WGS84,0.0300,0.0400,0.0600,\"0\",0.000,0.000,38,30,30,30,0,06,0,33*7d2e0ee7"
+	testPPPNAVConverging = "#PPPNAVA,COM1,0,55.0,FINE,2209,110326.000,4363,18,18;SOL_COMPUTED,PPP_CONVERGING,40.07898426103," +
+		"116.23660826542,60.7413,-9.2000,WGS84,0.3000,0.4000,0.9000,\"0\",0.000,0.000,38,30,30,30,0,06,0,33*2a3e9c01"
+	testUnicoreReply = "$command,CONFIG PPP ENABLE E6-HAS,response: OK*6A"
+)
+
+func TestValidatePPPService(t *testing.T) {
+	test.That(t, ValidatePPPService("ppp_service", "", ""), test.ShouldBeNil)
+	test.That(t, ValidatePPPService("ppp_service", PPPServiceHAS, ReceiverUM980), test.ShouldBeNil)
+	test.That(t, ValidatePPPService("ppp_service", PPPServiceB2b, ReceiverUM980), test.ShouldBeNil)
+	test.That(t, ValidatePPPService("ppp_service", "rtx", ReceiverUM980), test.ShouldBeError,
+		errors.New("ppp_service \"rtx\" isn't supported, use has or b2b"))
+	test.That(t, ValidatePPPService("ppp_service", PPPServiceHAS, ""), test.ShouldBeError,
+		errors.New("receiver \"zed-f9p\" can't apply the corrections of ppp_service \"has\""))
+}
+
+func TestUnicorePackets(t *testing.T) {
+	test.That(t, UnicoreCommand("MODE ROVER"), test.ShouldResemble, []byte("MODE ROVER\r\n"))
+	test.That(t, UnicorePPPPackets(PPPServiceHAS), test.ShouldResemble, [][]byte{
+		[]byte("CONFIG PPP ENABLE E6-HAS\r\n"),
+		[]byte("CONFIG PPP DATUM WGS84\r\n"),
+	})
+	test.That(t, UnicorePPPPackets(""), test.ShouldResemble, [][]byte{[]byte("CONFIG PPP DISABLE\r\n")})
+}
+
+func TestPPPStatus(t *testing.T) {
+	var s PPPStatus
+	test.That(t, s.update(testGGAEpoch1), test.ShouldBeFalse)
+	test.That(t, s.update(testEPE), test.ShouldBeFalse)
+	test.That(t, s.Readings(), test.ShouldResemble, map[string]interface{}{
+		"ppp_solution": PPPSolutionNone, "ppp_corrections_applied": false,
+	})
+
+	test.That(t, s.update(testPPPNAVConverging), test.ShouldBeTrue)
+	test.That(t, s.Solution, test.ShouldEqual, PPPSolutionConverging)
+	test.That(t, s.Converged(), test.ShouldBeFalse)
+	test.That(t, s.HorizontalStdev, test.ShouldAlmostEqual, 0.5, 1e-9)
+	test.That(t, PPPState(StateWaitingForFix, s), test.ShouldEqual, StateWaitingForFix)
+
+	test.That(t, s.update(testPPPNAV), test.ShouldBeTrue)
+	test.That(t, s.Converged(), test.ShouldBeTrue)
+	test.That(t, s.Readings(), test.ShouldResemble, map[string]interface{}{
+		"ppp_solution":            PPPSolutionConverged,
+		"ppp_corrections_applied": true,
+		"ppp_horizontal_stdev_m":  0.05,
+		"ppp_vertical_stdev_m":    0.06,
+	})
+	test.That(t, PPPState(StateWaitingForFix, s), test.ShouldEqual, StateHealthy)
+	test.That(t, PPPState(StateDegraded, s), test.ShouldEqual, StateDegraded)
+
+	// replies to commands and other logs are known, but hold no solution
+	test.That(t, s.update(testUnicoreReply), test.ShouldBeTrue)
+	test.That(t, s.update("#BESTNAVA,COM1,0,55.0,FINE,2209,110326.000,4363,18,18;SOL_COMPUTED,SINGLE*00000000"), test.ShouldBeTrue)
+	test.That(t, s.Converged(), test.ShouldBeTrue)
+}
@@ -0,0 +1,138 @@
+package rtkutils
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// Filters that smooth the speed and heading a rover reports.
+const (
+	VelocityFilterMovingAverage = "moving_average"
+	VelocityFilterExponential   = "exponential"
+)
+
+const (
+	// DefaultVelocityFilterWindow is how many epochs a velocity filter smooths over.
+	DefaultVelocityFilterWindow = 5
+	maxVelocityFilterWindow     = 100
+)
+
+// ValidateVelocityFilter checks the velocity filter of a config and its window in epochs.
+func ValidateVelocityFilter(filter string, window int) error {
+	switch filter {
+	case "":
+		if window != 0 {
+			return errors.New("velocity_filter_window is only used with velocity_filter")
+		}
+		return nil
+	case VelocityFilterMovingAverage, VelocityFilterExponential:
+	default:
+		return fmt.Errorf("velocity_filter %q isn't supported, use %s or %s",
+			filter, VelocityFilterMovingAverage, VelocityFilterExponential)
+	}
+	if window < 0 || window > maxVelocityFilterWindow {
+		return fmt.Errorf("velocity_filter_window must be between 1 and %d epochs", maxVelocityFilterWindow)
+	}
+	return nil
+}
+
+// VelocityFilter smooths the speed and heading of consecutive epochs, since the velocity receivers
+// report at low speeds is noisy enough to make the control loops following it oscillate. The moving
+// average weighs the last window epochs equally, the exponential filter weighs older epochs less
+// with the smoothing factor of a window long moving average, 2/(window+1), and lags less. Headings
+// are averaged as unit vectors so that they wrap around north. A nil filter passes the velocity
+// through. VelocityFilter is not safe for concurrent use.
+type VelocityFilter struct {
+	filter string
+	window int
+
+	speeds []float64 // of the last window epochs, for a moving average
+	speed  float64   // exponential average
+	// unit vectors of the headings since the heading was last lost or changed source
+	headings    [][2]float64
+	heading     [2]float64
+	dualAntenna bool
+}
+
+// NewVelocityFilter returns the named filter over window epochs, DefaultVelocityFilterWindow if it
+// is 0, or nil if filter is empty.
+func NewVelocityFilter(filter string, window int) *VelocityFilter {
+	if filter == "" {
+		return nil
+	}
+	if window <= 0 {
+		window = DefaultVelocityFilterWindow
+	}
+	return &VelocityFilter{filter: filter, window: window}
+}
+
+// Update adds the speed and heading of an epoch and returns them smoothed. An invalid heading, e.g.
+// the course over ground while standing still, is returned as is and starts the heading over.
+func (f *VelocityFilter) Update(speed float64, heading Heading) (float64, Heading) {
+	if f == nil {
+		return speed, heading
+	}
+	speed = f.smoothSpeed(speed)
+	if !heading.Valid || heading.DualAntenna != f.dualAntenna {
+		f.headings = f.headings[:0]
+		f.dualAntenna = heading.DualAntenna
+	}
+	if heading.Valid {
+		heading.Degrees = f.smoothHeading(heading.Degrees)
+	}
+	return speed, heading
+}
+
+func (f *VelocityFilter) smoothSpeed(speed float64) float64 {
+	first := len(f.speeds) == 0
+	f.speeds = append(f.speeds, speed)
+	if len(f.speeds) > f.window {
+		f.speeds = f.speeds[1:]
+	}
+	if f.filter == VelocityFilterExponential {
+		if !first {
+			speed = f.speed + f.alpha()*(speed-f.speed)
+		}
+		f.speed = speed
+		return speed
+	}
+	var sum float64
+	for _, s := range f.speeds {
+		sum += s
+	}
+	return sum / float64(len(f.speeds))
+}
+
+func (f *VelocityFilter) smoothHeading(degrees float64) float64 {
+	rad := degrees * math.Pi / 180
+	v := [2]float64{math.Sin(rad), math.Cos(rad)}
+	first := len(f.headings) == 0
+	f.headings = append(f.headings, v)
+	if len(f.headings) > f.window {
+		f.headings = f.headings[1:]
+	}
+	if f.filter == VelocityFilterExponential {
+		if !first {
+			alpha := f.alpha()
+			v = [2]float64{f.heading[0] + alpha*(v[0]-f.heading[0]), f.heading[1] + alpha*(v[1]-f.heading[1])}
+		}
+		f.heading = v
+	} else {
+		v = [2]float64{}
+		for _, h := range f.headings {
+			v[0] += h[0]
+			v[1] += h[1]
+		}
+	}
+	if v[0] == 0 && v[1] == 0 {
+		// headings cancelling out have no mean, keep the latest
+		return degrees
+	}
+	return NormalizeHeading(math.Atan2(v[0], v[1]) * 180 / math.Pi)
+}
+
+// alpha is the smoothing factor of the exponential filter.
+func (f *VelocityFilter) alpha() float64 {
+	return 2 / float64(f.window+1)
+}
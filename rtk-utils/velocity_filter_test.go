@@ -0,0 +1,76 @@
+package rtkutils
+
+import (
+	"errors"
+	"testing"
+
+	"go.viam.com/test"
+)
+
+func TestValidateVelocityFilter(t *testing.T) {
+	test.That(t, ValidateVelocityFilter("", 0), test.ShouldBeNil)
+	test.That(t, ValidateVelocityFilter(VelocityFilterMovingAverage, 0), test.ShouldBeNil)
+	test.That(t, ValidateVelocityFilter(VelocityFilterExponential, 20), test.ShouldBeNil)
+	test.That(t, ValidateVelocityFilter("", 5), test.ShouldBeError,
+		errors.New("velocity_filter_window is only used with velocity_filter"))
+	test.That(t, ValidateVelocityFilter("median", 5), test.ShouldBeError,
+		errors.New(`velocity_filter "median" isn't supported, use moving_average or exponential`))
+	test.That(t, ValidateVelocityFilter(VelocityFilterMovingAverage, 101), test.ShouldBeError,
+		errors.New("velocity_filter_window must be between 1 and 100 epochs"))
+}
+
+func TestMovingAverageVelocityFilter(t *testing.T) {
+	f := NewVelocityFilter(VelocityFilterMovingAverage, 3)
+	cog := func(degrees float64) Heading { return Heading{Degrees: degrees, Valid: true} }
+
+	speed, heading := f.Update(1, cog(350))
+	test.That(t, speed, test.ShouldAlmostEqual, 1, 1e-9)
+	test.That(t, heading.Degrees, test.ShouldAlmostEqual, 350, 1e-9)
+
+	// headings average across north
+	speed, heading = f.Update(2, cog(10))
+	test.That(t, speed, test.ShouldAlmostEqual, 1.5, 1e-9)
+	test.That(t, heading.Degrees, test.ShouldAlmostEqual, 0, 1e-9)
+
+	f.Update(3, cog(0))
+	// only the last 3 epochs are averaged
+	speed, _ = f.Update(6, cog(0))
+	test.That(t, speed, test.ShouldAlmostEqual, 11./3, 1e-9)
+
+	// a lost heading starts over, the speed doesn't
+	speed, heading = f.Update(6, Heading{})
+	test.That(t, speed, test.ShouldAlmostEqual, 5, 1e-9)
+	test.That(t, heading, test.ShouldResemble, Heading{})
+	_, heading = f.Update(6, cog(90))
+	test.That(t, heading.Degrees, test.ShouldAlmostEqual, 90, 1e-9)
+
+	// so does a dual antenna heading replacing the course over ground
+	_, heading = f.Update(6, Heading{Degrees: 180, DualAntenna: true, Valid: true})
+	test.That(t, heading.Degrees, test.ShouldAlmostEqual, 180, 1e-9)
+	test.That(t, heading.DualAntenna, test.ShouldBeTrue)
+}
+
+func TestExponentialVelocityFilter(t *testing.T) {
+	// a window of 3 smooths by 2/(3+1)
+	f := NewVelocityFilter(VelocityFilterExponential, 3)
+	speed, _ := f.Update(2, Heading{})
+	test.That(t, speed, test.ShouldAlmostEqual, 2, 1e-9)
+	speed, _ = f.Update(4, Heading{})
+	test.That(t, speed, test.ShouldAlmostEqual, 3, 1e-9)
+	speed, _ = f.Update(4, Heading{})
+	test.That(t, speed, test.ShouldAlmostEqual, 3.5, 1e-9)
+
+	_, heading := f.Update(4, Heading{Degrees: 90, Valid: true})
+	test.That(t, heading.Degrees, test.ShouldAlmostEqual, 90, 1e-9)
+	_, heading = f.Update(4, Heading{Degrees: 0, Valid: true})
+	test.That(t, heading.Degrees, test.ShouldAlmostEqual, 45, 1e-9)
+}
+
+func TestNilVelocityFilter(t *testing.T) {
+	f := NewVelocityFilter("", 5)
+	test.That(t, f, test.ShouldBeNil)
+	heading := Heading{Degrees: 12, Valid: true}
+	speed, smoothed := f.Update(0.3, heading)
+	test.That(t, speed, test.ShouldEqual, 0.3)
+	test.That(t, smoothed, test.ShouldResemble, heading)
+}
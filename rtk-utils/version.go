@@ -0,0 +1,46 @@
+package rtkutils
+
+import (
+	"runtime"
+	"runtime/debug"
+)
+
+// VersionCommand returns the version of the module a component runs in, the git commit it was built
+// from and when, since a fleet runs mixed versions and debugging one needs to know which code it runs.
+const VersionCommand = "version"
+
+// Set when the module is built, with
+// -ldflags "-X rtksystem/rtk-utils.version=<version> -X rtksystem/rtk-utils.gitSHA=<sha> -X rtksystem/rtk-utils.buildDate=<date>".
+var (
+	version   = "dev"
+	gitSHA    string
+	buildDate string
+)
+
+// VersionResult answers a VersionCommand. Without a git commit set when the module was built, it is
+// the one go stamps binaries built in a git checkout with.
+func VersionResult() map[string]interface{} {
+	result := map[string]interface{}{
+		"version":    version,
+		"git_sha":    gitSHA,
+		"build_date": buildDate,
+		"go_version": runtime.Version(),
+	}
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return result
+	}
+	for _, setting := range info.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			if gitSHA == "" {
+				result["git_sha"] = setting.Value
+			}
+		case "vcs.time":
+			result["commit_date"] = setting.Value
+		case "vcs.modified":
+			result["modified"] = setting.Value == "true"
+		}
+	}
+	return result
+}
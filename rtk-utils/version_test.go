@@ -0,0 +1,20 @@
+package rtkutils
+
+import (
+	"runtime"
+	"testing"
+
+	"go.viam.com/test"
+)
+
+func TestVersionResult(t *testing.T) {
+	result := VersionResult()
+	test.That(t, result["version"], test.ShouldEqual, "dev")
+	test.That(t, result["go_version"], test.ShouldEqual, runtime.Version())
+
+	defer func(sha, date string) { gitSHA, buildDate = sha, date }(gitSHA, buildDate)
+	gitSHA, buildDate = "5d1a5c7", "2023-07-13T19:21:27Z"
+	result = VersionResult()
+	test.That(t, result["git_sha"], test.ShouldEqual, "5d1a5c7")
+	test.That(t, result["build_date"], test.ShouldEqual, "2023-07-13T19:21:27Z")
+}
@@ -0,0 +1,363 @@
+package rtkutils
+
+import (
+	"fmt"
+	"math"
+	"math/bits"
+	"sync"
+
+	"github.com/go-gnss/rtcm/rtcm3"
+)
+
+const (
+	speedOfLight = 299792458.0       // m/s
+	earthRate    = 7.2921151467e-5   // WGS84 earth rotation rate in rad/s
+	earthGM      = 3.986005e14       // WGS84 earth gravitational constant in m^3/s^2
+	wgs84A       = 6378137.0         // WGS84 semi major axis in m
+	wgs84F       = 1 / 298.257223563 // WGS84 flattening
+
+	gpsHalfWeek = 302400.0 // s
+	// broadcast ephemerides older or newer than this are not used
+	maxEphemerisAge = 4 * 3600.0 // s
+
+	msmInvalidRoughRange = 255
+)
+
+// VirtualBase re-references a correction stream to a virtual reference station, so that rovers far
+// from the physical base solve against a base near them. The base position in 1005/1006 messages is
+// replaced by the virtual one and the GPS MSM4 and MSM7 observations are shifted by the difference of
+// the geometric ranges from both positions to each satellite, using the GPS ephemerides (1019) in the
+// stream. Atmospheric and orbit errors are not interpolated, so the benefit shrinks as the baseline
+// to the physical base grows. Observations that can't be shifted are dropped. A nil VirtualBase
+// passes messages through unchanged.
+type VirtualBase struct {
+	pos [3]float64 // ECEF position of the virtual base in m
+
+	mu          sync.Mutex
+	base        [3]float64 // ECEF position of the physical base in m, from its 1005/1006 messages
+	hasBase     bool
+	ephemerides map[uint8]rtcm3.Message1019
+}
+
+// NewVirtualBase returns a virtual base at the given WGS84 position, or nil if lat and lng are both zero.
+func NewVirtualBase(lat, lng, alt float64) *VirtualBase {
+	if lat == 0 && lng == 0 {
+		return nil
+	}
+	return &VirtualBase{pos: geodeticToECEF(lat, lng, alt), ephemerides: map[uint8]rtcm3.Message1019{}}
+}
+
+// ValidateVirtualBase checks the virtual base position of a config.
+func ValidateVirtualBase(lat, lng float64) error {
+	if lat < -90 || lat > 90 || lng < -180 || lng > 180 {
+		return fmt.Errorf("virtual base position (%v, %v) is not a valid latitude and longitude", lat, lng)
+	}
+	return nil
+}
+
+// Transform returns msg re-referenced to the virtual base, and false if it should be dropped.
+func (v *VirtualBase) Transform(msg rtcm3.Message) (rtcm3.Message, bool) {
+	if v == nil {
+		return msg, true
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	switch m := msg.(type) {
+	case rtcm3.Message1005:
+		m.AntennaReferencePoint = v.moveARP(m.AntennaReferencePoint)
+		return m, true
+	case rtcm3.Message1006:
+		m.AntennaReferencePoint = v.moveARP(m.AntennaReferencePoint)
+		return m, true
+	case rtcm3.Message1019:
+		v.ephemerides[m.SatelliteID] = m
+		return m, true
+	case rtcm3.Message1074:
+		shifted, ok := v.shiftMSM4(m.MessageMsm4)
+		return rtcm3.Message1074{MessageMsm4: shifted}, ok
+	case rtcm3.Message1077:
+		shifted, ok := v.shiftMSM7(m.MessageMsm7)
+		return rtcm3.Message1077{MessageMsm7: shifted}, ok
+	case rtcm3.Message1007, rtcm3.Message1008, rtcm3.Message1033:
+		// antenna and receiver descriptors still apply
+		return m, true
+	default:
+		// observations of other constellations and legacy messages are not re-referenced
+		return msg, false
+	}
+}
+
+// TransformFrame is Transform for a serialized rtcm frame.
+func (v *VirtualBase) TransformFrame(frame []byte) ([]byte, bool) {
+	if v == nil {
+		return frame, true
+	}
+	if len(frame) < 6 {
+		return nil, false
+	}
+	msg, ok := v.Transform(rtcm3.DeserializeMessage(frame[3 : len(frame)-3]))
+	if !ok {
+		return nil, false
+	}
+	return rtcm3.EncapsulateMessage(msg).Serialize(), true
+}
+
+// moveARP records the physical base position of arp and returns it moved to the virtual base.
+func (v *VirtualBase) moveARP(arp rtcm3.AntennaReferencePoint) rtcm3.AntennaReferencePoint {
+	v.base = [3]float64{
+		float64(arp.ReferencePointX) * 1e-4,
+		float64(arp.ReferencePointY) * 1e-4,
+		float64(arp.ReferencePointZ) * 1e-4,
+	}
+	v.hasBase = true
+	arp.ReferencePointX = int64(math.Round(v.pos[0] * 1e4))
+	arp.ReferencePointY = int64(math.Round(v.pos[1] * 1e4))
+	arp.ReferencePointZ = int64(math.Round(v.pos[2] * 1e4))
+	return arp
+}
+
+// msmShifts returns the rough ranges of an msm message shifted to the virtual base, the residual shift
+// in ms left for the fine ranges of each satellite, and which satellites could be shifted.
+func (v *VirtualBase) msmShifts(header rtcm3.MsmHeader, roughMs []uint8, rough []uint16) ([]uint8, []uint16, []float64, []bool) {
+	n := len(roughMs)
+	newMs, newRough := make([]uint8, n), make([]uint16, n)
+	residuals, keep := make([]float64, n), make([]bool, n)
+	if !v.hasBase {
+		return newMs, newRough, residuals, keep
+	}
+
+	tow := float64(header.Epoch) / 1000
+	for i, sat := range msmSatellites(header) {
+		eph, ok := v.ephemerides[uint8(sat)]
+		if !ok || roughMs[i] == msmInvalidRoughRange {
+			continue
+		}
+		rangeMs := float64(roughMs[i]) + float64(rough[i])/1024
+		satPos, ok := gpsSatellitePosition(eph, tow-rangeMs/1000)
+		if !ok {
+			continue
+		}
+		shiftedMs := rangeMs + (geometricRange(satPos, v.pos)-geometricRange(satPos, v.base))/speedOfLight*1000
+
+		whole := math.Floor(shiftedMs)
+		frac := math.Round((shiftedMs - whole) * 1024)
+		if frac == 1024 {
+			whole, frac = whole+1, 0
+		}
+		if whole < 0 || whole >= msmInvalidRoughRange {
+			continue
+		}
+		newMs[i], newRough[i] = uint8(whole), uint16(frac)
+		residuals[i] = shiftedMs - (whole + frac/1024)
+		keep[i] = true
+	}
+	return newMs, newRough, residuals, keep
+}
+
+func (v *VirtualBase) shiftMSM4(m rtcm3.MessageMsm4) (rtcm3.MessageMsm4, bool) {
+	roughMs, rough, residuals, keep := v.msmShifts(m.MsmHeader, m.SatelliteData.RangeMilliseconds, m.SatelliteData.Ranges)
+	header, sats, cells, cellSats := filterMSM(m.MsmHeader, keep)
+	if len(sats) == 0 {
+		return m, false
+	}
+
+	out := rtcm3.MessageMsm4{MsmHeader: header}
+	for _, i := range sats {
+		out.SatelliteData.RangeMilliseconds = append(out.SatelliteData.RangeMilliseconds, roughMs[i])
+		out.SatelliteData.Ranges = append(out.SatelliteData.Ranges, rough[i])
+	}
+	sig := m.SignalData
+	for k, c := range cells {
+		residual := residuals[cellSats[k]]
+		out.SignalData.Pseudoranges = append(out.SignalData.Pseudoranges,
+			int16(shiftFine(int64(sig.Pseudoranges[c]), 15, residual, 24)))
+		out.SignalData.PhaseRanges = append(out.SignalData.PhaseRanges,
+			int32(shiftFine(int64(sig.PhaseRanges[c]), 22, residual, 29)))
+		out.SignalData.PhaseRangeLocks = append(out.SignalData.PhaseRangeLocks, sig.PhaseRangeLocks[c])
+		out.SignalData.HalfCycles = append(out.SignalData.HalfCycles, sig.HalfCycles[c])
+		out.SignalData.Cnrs = append(out.SignalData.Cnrs, sig.Cnrs[c])
+	}
+	return out, true
+}
+
+func (v *VirtualBase) shiftMSM7(m rtcm3.MessageMsm7) (rtcm3.MessageMsm7, bool) {
+	roughMs, rough, residuals, keep := v.msmShifts(m.MsmHeader, m.SatelliteData.RangeMilliseconds, m.SatelliteData.Ranges)
+	header, sats, cells, cellSats := filterMSM(m.MsmHeader, keep)
+	if len(sats) == 0 {
+		return m, false
+	}
+
+	out := rtcm3.MessageMsm7{MsmHeader: header}
+	for _, i := range sats {
+		out.SatelliteData.RangeMilliseconds = append(out.SatelliteData.RangeMilliseconds, roughMs[i])
+		out.SatelliteData.Extended = append(out.SatelliteData.Extended, m.SatelliteData.Extended[i])
+		out.SatelliteData.Ranges = append(out.SatelliteData.Ranges, rough[i])
+		out.SatelliteData.PhaseRangeRates = append(out.SatelliteData.PhaseRangeRates, m.SatelliteData.PhaseRangeRates[i])
+	}
+	sig := m.SignalData
+	for k, c := range cells {
+		residual := residuals[cellSats[k]]
+		out.SignalData.Pseudoranges = append(out.SignalData.Pseudoranges,
+			int32(shiftFine(int64(sig.Pseudoranges[c]), 20, residual, 29)))
+		out.SignalData.PhaseRanges = append(out.SignalData.PhaseRanges,
+			int32(shiftFine(int64(sig.PhaseRanges[c]), 24, residual, 31)))
+		out.SignalData.PhaseRangeLocks = append(out.SignalData.PhaseRangeLocks, sig.PhaseRangeLocks[c])
+		out.SignalData.HalfCycles = append(out.SignalData.HalfCycles, sig.HalfCycles[c])
+		out.SignalData.Cnrs = append(out.SignalData.Cnrs, sig.Cnrs[c])
+		out.SignalData.PhaseRangeRates = append(out.SignalData.PhaseRangeRates, sig.PhaseRangeRates[c])
+	}
+	return out, true
+}
+
+// shiftFine adds residual ms to a signed fine range of width bits in units of 2^-scale ms. Invalid values,
+// the most negative of the width, stay invalid and shifted values out of range become invalid.
+func shiftFine(value int64, width int, residual float64, scale int) int64 {
+	invalid := int64(-1) << (width - 1)
+	if value == invalid {
+		return value
+	}
+	shifted := value + int64(math.Round(residual*math.Exp2(float64(scale))))
+	if shifted <= invalid || shifted >= -invalid {
+		return invalid
+	}
+	return shifted
+}
+
+// msmSatellites returns the satellite ids in the satellite mask of an msm message, in message order.
+func msmSatellites(header rtcm3.MsmHeader) []int {
+	var sats []int
+	for id := 1; id <= 64; id++ {
+		if header.SatelliteMask&(1<<(64-id)) != 0 {
+			sats = append(sats, id)
+		}
+	}
+	return sats
+}
+
+// filterMSM returns the header of an msm message with only the satellites for which keep is true,
+// the indices of the kept satellites and cells, and the satellite index of each kept cell.
+func filterMSM(header rtcm3.MsmHeader, keep []bool) (rtcm3.MsmHeader, []int, []int, []int) {
+	sats := msmSatellites(header)
+	nsig := bits.OnesCount32(header.SignalMask)
+	ncell := len(sats) * nsig
+
+	out := header
+	out.SatelliteMask, out.CellMask = 0, 0
+	var keptSats, keptCells, cellSats []int
+	cell := 0
+	for i, sat := range sats {
+		if keep[i] {
+			out.SatelliteMask |= 1 << (64 - sat)
+			keptSats = append(keptSats, i)
+		}
+		for j := 0; j < nsig; j++ {
+			present := header.CellMask&(1<<(ncell-1-(i*nsig+j))) != 0
+			if keep[i] {
+				out.CellMask <<= 1
+				if present {
+					out.CellMask |= 1
+					keptCells = append(keptCells, cell)
+					cellSats = append(cellSats, i)
+				}
+			}
+			if present {
+				cell++
+			}
+		}
+	}
+	return out, keptSats, keptCells, cellSats
+}
+
+// gpsSatellitePosition returns the ECEF position in m of a GPS satellite at GPS time of week t,
+// following IS-GPS-200, and false if the ephemeris is too old.
+func gpsSatellitePosition(eph rtcm3.Message1019, t float64) ([3]float64, bool) {
+	sqrtA := float64(eph.SrA) * math.Exp2(-19)
+	a := sqrtA * sqrtA
+	e := float64(eph.Eccentricity) * math.Exp2(-33)
+	toe := float64(eph.Toe) * 16
+
+	tk := weekDiff(t, toe)
+	if math.Abs(tk) > maxEphemerisAge || a == 0 {
+		return [3]float64{}, false
+	}
+
+	ek := gpsEccentricAnomaly(eph, tk)
+	nu := math.Atan2(math.Sqrt(1-e*e)*math.Sin(ek), math.Cos(ek)-e)
+	phi := nu + semicircles(float64(eph.Perigee), -31)
+	sin2, cos2 := math.Sin(2*phi), math.Cos(2*phi)
+
+	harmonic := func(s, c int16) float64 { return float64(s)*sin2 + float64(c)*cos2 }
+	u := phi + harmonic(eph.Cus, eph.Cuc)*math.Exp2(-29)
+	r := a*(1-e*math.Cos(ek)) + harmonic(eph.Crs, eph.Crc)*math.Exp2(-5)
+	i := semicircles(float64(eph.I0), -31) + harmonic(eph.Cis, eph.Cic)*math.Exp2(-29) +
+		semicircles(float64(eph.IDOT), -43)*tk
+	omega := semicircles(float64(eph.Omega0), -31) + (semicircles(float64(eph.OmegaDot), -43)-earthRate)*tk - earthRate*toe
+
+	x, y := r*math.Cos(u), r*math.Sin(u)
+	return [3]float64{
+		x*math.Cos(omega) - y*math.Cos(i)*math.Sin(omega),
+		x*math.Sin(omega) + y*math.Cos(i)*math.Cos(omega),
+		y * math.Sin(i),
+	}, true
+}
+
+// semicircles converts an ephemeris angle in units of 2^scale semicircles to radians.
+func semicircles(v float64, scale int) float64 {
+	return v * math.Exp2(float64(scale)) * math.Pi
+}
+
+// gpsEccentricAnomaly returns the eccentric anomaly in radians of a GPS satellite tk seconds after
+// the reference time of its ephemeris.
+func gpsEccentricAnomaly(eph rtcm3.Message1019, tk float64) float64 {
+	sqrtA := float64(eph.SrA) * math.Exp2(-19)
+	a := sqrtA * sqrtA
+	e := float64(eph.Eccentricity) * math.Exp2(-33)
+	n := math.Sqrt(earthGM/(a*a*a)) + semicircles(float64(eph.DeltaN), -43)
+	mk := semicircles(float64(eph.M0), -31) + n*tk
+	ek := mk
+	for i := 0; i < 10; i++ {
+		ek = mk + e*math.Sin(ek)
+	}
+	return ek
+}
+
+// weekDiff returns t - ref in s for GPS times of week, accounting for the week rollover.
+func weekDiff(t, ref float64) float64 {
+	d := t - ref
+	if d > gpsHalfWeek {
+		d -= 2 * gpsHalfWeek
+	} else if d < -gpsHalfWeek {
+		d += 2 * gpsHalfWeek
+	}
+	return d
+}
+
+// geometricRange returns the range in m from a receiver to a satellite, corrected for the earth's
+// rotation while the signal travels.
+func geometricRange(sat, receiver [3]float64) float64 {
+	r := distance(sat, receiver)
+	theta := earthRate * r / speedOfLight
+	rotated := [3]float64{
+		sat[0]*math.Cos(theta) + sat[1]*math.Sin(theta),
+		-sat[0]*math.Sin(theta) + sat[1]*math.Cos(theta),
+		sat[2],
+	}
+	return distance(rotated, receiver)
+}
+
+func distance(a, b [3]float64) float64 {
+	return math.Sqrt((a[0]-b[0])*(a[0]-b[0]) + (a[1]-b[1])*(a[1]-b[1]) + (a[2]-b[2])*(a[2]-b[2]))
+}
+
+// geodeticToECEF converts a WGS84 latitude and longitude in degrees and height in m to ECEF in m.
+func geodeticToECEF(lat, lng, alt float64) [3]float64 {
+	phi, lambda := lat*math.Pi/180, lng*math.Pi/180
+	e2 := wgs84F * (2 - wgs84F)
+	n := wgs84A / math.Sqrt(1-e2*math.Sin(phi)*math.Sin(phi))
+	return [3]float64{
+		(n + alt) * math.Cos(phi) * math.Cos(lambda),
+		(n + alt) * math.Cos(phi) * math.Sin(lambda),
+		(n*(1-e2) + alt) * math.Sin(phi),
+	}
+}
@@ -0,0 +1,142 @@
+package rtkutils
+
+import (
+	"math"
+	"testing"
+
+	"github.com/go-gnss/rtcm/rtcm3"
+	"go.viam.com/test"
+)
+
+func testEphemeris(sat uint8) rtcm3.Message1019 {
+	return rtcm3.Message1019{
+		AbstractMessage: rtcm3.AbstractMessage{MessageNumber: 1019},
+		SatelliteID:     sat,
+		SrA:             uint32(5153.6 * math.Exp2(19)),
+		Eccentricity:    uint32(0.01 * math.Exp2(33)),
+		I0:              int32(0.3 * math.Exp2(31)),
+		M0:              int32(0.2 * math.Exp2(31)),
+		Omega0:          int32(-0.6 * math.Exp2(31)),
+		Toe:             7200 / 16,
+	}
+}
+
+func TestGeodeticToECEF(t *testing.T) {
+	p := geodeticToECEF(0, 0, 0)
+	test.That(t, p[0], test.ShouldAlmostEqual, wgs84A, 1e-6)
+	test.That(t, p[1], test.ShouldAlmostEqual, 0, 1e-6)
+	p = geodeticToECEF(90, 0, 10)
+	test.That(t, p[2], test.ShouldAlmostEqual, 6356752.314+10, 1e-3)
+}
+
+func TestGPSSatellitePosition(t *testing.T) {
+	eph := testEphemeris(5)
+	p, ok := gpsSatellitePosition(eph, 7200)
+	test.That(t, ok, test.ShouldBeTrue)
+	radius := distance(p, [3]float64{})
+	test.That(t, radius, test.ShouldBeBetween, 5153.6*5153.6*0.99, 5153.6*5153.6*1.01)
+
+	_, ok = gpsSatellitePosition(eph, 7200+5*3600)
+	test.That(t, ok, test.ShouldBeFalse)
+}
+
+func TestShiftFine(t *testing.T) {
+	test.That(t, shiftFine(100, 15, math.Exp2(-24), 24), test.ShouldEqual, 101)
+	test.That(t, shiftFine(-1<<14, 15, math.Exp2(-24), 24), test.ShouldEqual, -1<<14)
+	test.That(t, shiftFine(1<<14-1, 15, math.Exp2(-24), 24), test.ShouldEqual, -1<<14)
+}
+
+func TestVirtualBase(t *testing.T) {
+	var nilBase *VirtualBase
+	msg, ok := nilBase.Transform(rtcm3.MessageUnknown{})
+	test.That(t, ok, test.ShouldBeTrue)
+	test.That(t, msg, test.ShouldResemble, rtcm3.MessageUnknown{})
+	test.That(t, NewVirtualBase(0, 0, 0), test.ShouldBeNil)
+	test.That(t, ValidateVirtualBase(40, -105), test.ShouldBeNil)
+	test.That(t, ValidateVirtualBase(91, 0), test.ShouldNotBeNil)
+
+	// physical base 11km south of the virtual one
+	base := geodeticToECEF(40, -105, 1600)
+	virtual := geodeticToECEF(40.1, -105, 1600)
+	v := NewVirtualBase(40.1, -105, 1600)
+
+	// one signal of satellite 5 and two of satellite 7, which has no ephemeris
+	const tow = 7200000
+	eph := testEphemeris(5)
+	rangeMs := func(sat, receiver [3]float64) float64 { return geometricRange(sat, receiver) / speedOfLight * 1000 }
+	satPos, ok := gpsSatellitePosition(eph, tow/1000-0.075)
+	test.That(t, ok, test.ShouldBeTrue)
+	baseMs := rangeMs(satPos, base)
+	whole, frac := math.Floor(baseMs), math.Round((baseMs-math.Floor(baseMs))*1024)
+	fine := int32(math.Round((baseMs - whole - frac/1024) * math.Exp2(29)))
+	msm := rtcm3.Message1077{MessageMsm7: rtcm3.MessageMsm7{
+		MsmHeader: rtcm3.MsmHeader{
+			MessageNumber: 1077,
+			Epoch:         tow,
+			SatelliteMask: 1<<(64-5) | 1<<(64-7),
+			SignalMask:    1<<(32-2) | 1<<(32-16),
+			CellMask:      0b1011,
+		},
+		SatelliteData: rtcm3.SatelliteDataMsm57{
+			RangeMilliseconds: []uint8{uint8(whole), 75},
+			Extended:          []uint8{0, 0},
+			Ranges:            []uint16{uint16(frac), 0},
+			PhaseRangeRates:   []int16{1, 2},
+		},
+		SignalData: rtcm3.SignalDataMsm7{
+			Pseudoranges:    []int32{fine, 0, 0},
+			PhaseRanges:     []int32{fine * 4, 0, 0},
+			PhaseRangeLocks: []uint16{1, 2, 3},
+			HalfCycles:      []bool{false, false, false},
+			Cnrs:            []uint16{40, 41, 42},
+			PhaseRangeRates: []int16{0, 0, 0},
+		},
+	}}
+
+	// observations are dropped until the physical base position is known
+	_, ok = v.Transform(msm)
+	test.That(t, ok, test.ShouldBeFalse)
+
+	arp := rtcm3.Message1005{
+		AbstractMessage: rtcm3.AbstractMessage{MessageNumber: 1005},
+		AntennaReferencePoint: rtcm3.AntennaReferencePoint{
+			ReferencePointX: int64(math.Round(base[0] * 1e4)),
+			ReferencePointY: int64(math.Round(base[1] * 1e4)),
+			ReferencePointZ: int64(math.Round(base[2] * 1e4)),
+		},
+	}
+	msg, ok = v.Transform(arp)
+	test.That(t, ok, test.ShouldBeTrue)
+	moved := msg.(rtcm3.Message1005)
+	test.That(t, float64(moved.ReferencePointX)*1e-4, test.ShouldAlmostEqual, virtual[0], 1e-3)
+	test.That(t, float64(moved.ReferencePointY)*1e-4, test.ShouldAlmostEqual, virtual[1], 1e-3)
+	test.That(t, float64(moved.ReferencePointZ)*1e-4, test.ShouldAlmostEqual, virtual[2], 1e-3)
+
+	// without any ephemeris no satellite can be shifted
+	_, ok = v.Transform(msm)
+	test.That(t, ok, test.ShouldBeFalse)
+
+	_, ok = v.Transform(eph)
+	test.That(t, ok, test.ShouldBeTrue)
+	msg, ok = v.Transform(msm)
+	test.That(t, ok, test.ShouldBeTrue)
+	shifted := msg.(rtcm3.Message1077)
+	test.That(t, shifted.SatelliteMask, test.ShouldEqual, uint64(1<<(64-5)))
+	test.That(t, shifted.SignalMask, test.ShouldEqual, msm.SignalMask)
+	test.That(t, shifted.CellMask, test.ShouldEqual, uint64(0b10))
+	test.That(t, shifted.SatelliteData.PhaseRangeRates, test.ShouldResemble, []int16{1})
+	test.That(t, shifted.SignalData.Cnrs, test.ShouldResemble, []uint16{40})
+
+	sat := shifted.SatelliteData
+	roughMs := float64(sat.RangeMilliseconds[0]) + float64(sat.Ranges[0])/1024
+	// both ranges are computed from where the satellite was when the signal left it
+	satPos, _ = gpsSatellitePosition(eph, tow/1000-(whole+frac/1024)/1000)
+	expected := baseMs + rangeMs(satPos, virtual) - rangeMs(satPos, base)
+	test.That(t, expected-baseMs, test.ShouldNotAlmostEqual, 0, 1e-6)
+	test.That(t, roughMs+float64(shifted.SignalData.Pseudoranges[0])*math.Exp2(-29), test.ShouldAlmostEqual, expected, 1e-8)
+	test.That(t, roughMs+float64(shifted.SignalData.PhaseRanges[0])*math.Exp2(-31), test.ShouldAlmostEqual, expected, 1e-8)
+
+	// other observations are dropped
+	_, ok = v.Transform(rtcm3.MessageUnknown{})
+	test.That(t, ok, test.ShouldBeFalse)
+}